@@ -0,0 +1,250 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/jeroenrinzema/psql-wire/pkg/types"
+
+	"github.com/daviszhen/plan/pkg/plan"
+)
+
+/*
+CancelRequest of the Postgres protocol.
+
+The psql-wire does not support it. The connection is wrapped:
+
+	write: the BackendKeyData with the process id and the secret key
+	       is sent before the first ReadyForQuery. The messages are
+	       split by the length in their header. The single byte response
+	       of the SSLRequest has no header.
+	read: the startup packet of the new connection is sniffed. The
+	      CancelRequest cancels the running query of the backend with
+	      the same process id and secret key.
+
+The psql sends the CancelRequest on the new connection on Ctrl-C.
+*/
+
+var (
+	backendPid atomic.Uint32
+	//process id -> backend
+	backends sync.Map
+	//remote address -> backend
+	backendAddrs sync.Map
+)
+
+// backend is the server side of the client connection
+type backend struct {
+	pid uint32
+	key uint32
+
+	lock sync.Mutex
+	//cancels the running query
+	cancel context.CancelCauseFunc
+}
+
+// run registers the cancel of the running query. nil denotes no query.
+func (b *backend) run(cancel context.CancelCauseFunc) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.cancel = cancel
+}
+
+func (b *backend) cancelQuery() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.cancel != nil {
+		b.cancel(plan.ErrQueryCanceled)
+	}
+}
+
+type backendKey struct{}
+
+// withBackend puts the backend of the session into the context
+func withBackend(ctx context.Context) context.Context {
+	addr := wire.RemoteAddress(ctx)
+	if addr == nil {
+		return ctx
+	}
+	if b, ok := backendAddrs.Load(addr.String()); ok {
+		return context.WithValue(ctx, backendKey{}, b)
+	}
+	return ctx
+}
+
+func sessionBackend(ctx context.Context) *backend {
+	if b, ok := ctx.Value(backendKey{}).(*backend); ok {
+		return b
+	}
+	return nil
+}
+
+func cancelBackend(pid, key uint32) {
+	if b, ok := backends.Load(pid); ok && b.(*backend).key == key {
+		b.(*backend).cancelQuery()
+	}
+}
+
+// cancelListener wraps the accepted connections
+type cancelListener struct {
+	net.Listener
+}
+
+func (l cancelListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	var key [4]byte
+	_, err = rand.Read(key[:])
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	b := &backend{
+		pid: backendPid.Add(1),
+		key: binary.BigEndian.Uint32(key[:]),
+	}
+	backends.Store(b.pid, b)
+	backendAddrs.Store(conn.RemoteAddr().String(), b)
+	return &backendConn{Conn: conn, backend: b}, nil
+}
+
+type backendConn struct {
+	net.Conn
+	backend *backend
+	//the head of the startup packet
+	head      []byte
+	startedUp bool
+	keySent   bool
+	//the count of the responses of the SSLRequest to be written
+	sslResps int
+	//the header of the message in the write
+	msgHead []byte
+	//the rest of the message body in the write
+	msgRest int
+}
+
+func (conn *backendConn) Read(p []byte) (int, error) {
+	n, err := conn.Conn.Read(p)
+	if !conn.startedUp {
+		conn.sniff(p[:n])
+	}
+	return n, err
+}
+
+// sniff reads the startup packets until the startup message
+// or the CancelRequest.
+func (conn *backendConn) sniff(data []byte) {
+	for len(data) > 0 && !conn.startedUp {
+		need := 8
+		if len(conn.head) >= 8 &&
+			types.Version(binary.BigEndian.Uint32(conn.head[4:8])) == types.VersionCancel {
+			need = 16
+		}
+		cnt := min(need-len(conn.head), len(data))
+		conn.head = append(conn.head, data[:cnt]...)
+		data = data[cnt:]
+		if len(conn.head) < need {
+			continue
+		}
+		switch types.Version(binary.BigEndian.Uint32(conn.head[4:8])) {
+		case types.VersionSSLRequest, types.VersionGSSENC:
+			//the startup packet follows the response
+			if types.Version(binary.BigEndian.Uint32(conn.head[4:8])) == types.VersionSSLRequest {
+				conn.sslResps++
+			}
+			conn.head = conn.head[:0]
+		case types.VersionCancel:
+			if need == 16 {
+				conn.startedUp = true
+				cancelBackend(
+					binary.BigEndian.Uint32(conn.head[8:12]),
+					binary.BigEndian.Uint32(conn.head[12:16]))
+			}
+		default:
+			conn.startedUp = true
+		}
+	}
+}
+
+func (conn *backendConn) Write(p []byte) (int, error) {
+	if conn.keySent {
+		return conn.Conn.Write(p)
+	}
+	at := conn.findReady(p)
+	if at < 0 {
+		return conn.Conn.Write(p)
+	}
+	n, err := conn.Conn.Write(p[:at])
+	if err != nil {
+		return n, err
+	}
+	conn.keySent = true
+	msg := make([]byte, 13)
+	msg[0] = 'K'
+	binary.BigEndian.PutUint32(msg[1:5], 12)
+	binary.BigEndian.PutUint32(msg[5:9], conn.backend.pid)
+	binary.BigEndian.PutUint32(msg[9:13], conn.backend.key)
+	_, err = conn.Conn.Write(msg)
+	if err != nil {
+		return n, err
+	}
+	m, err := conn.Conn.Write(p[at:])
+	return n + m, err
+}
+
+// findReady returns the offset of the first ReadyForQuery in the p.
+// -1 if it is not in the p. The message may span the writes.
+// The message is the type byte and the length including itself.
+func (conn *backendConn) findReady(p []byte) int {
+	for i := 0; i < len(p); {
+		switch {
+		case conn.sslResps > 0:
+			//the 'N' without the header
+			conn.sslResps--
+			i++
+		case conn.msgRest > 0:
+			cnt := min(conn.msgRest, len(p)-i)
+			conn.msgRest -= cnt
+			i += cnt
+		default:
+			if len(conn.msgHead) == 0 && types.ServerMessage(p[i]) == types.ServerReady {
+				return i
+			}
+			cnt := min(5-len(conn.msgHead), len(p)-i)
+			conn.msgHead = append(conn.msgHead, p[i:i+cnt]...)
+			i += cnt
+			if len(conn.msgHead) == 5 {
+				conn.msgRest = int(binary.BigEndian.Uint32(conn.msgHead[1:5])) - 4
+				conn.msgHead = conn.msgHead[:0]
+			}
+		}
+	}
+	return -1
+}
+
+func (conn *backendConn) Close() error {
+	backends.Delete(conn.backend.pid)
+	backendAddrs.Delete(conn.Conn.RemoteAddr().String())
+	return conn.Conn.Close()
+}
@@ -16,11 +16,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/BurntSushi/toml"
 	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 	"go.uber.org/zap"
 
 	"github.com/daviszhen/plan/pkg/plan"
@@ -57,10 +62,44 @@ func loadConfig() {
 		util.Error("tester.toml does not exist")
 		os.Exit(1)
 	}
+	if runCfg.Exec.Threads == 0 {
+		runCfg.Exec.Threads = runtime.NumCPU()
+	}
+	if err := storage.GBufferMgr.SetMemoryLimit(runCfg.Exec.MemoryLimit); err != nil {
+		util.Error("set memory limit failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+type sessionCfgKey struct{}
+
+// newSession copies the config for the session.
+// The SET changes the copy only.
+func newSession(ctx context.Context) (context.Context, error) {
+	cfg := runCfg
+	ctx = withBackend(ctx)
+	return context.WithValue(ctx, sessionCfgKey{}, &cfg), nil
+}
+
+func sessionCfg(ctx context.Context) *util.Config {
+	if cfg, ok := ctx.Value(sessionCfgKey{}).(*util.Config); ok {
+		return cfg
+	}
+	return &runCfg
 }
 
 func main() {
-	wire.ListenAndServe("127.0.0.1:5432", handler)
+	server, err := wire.NewServer(handler, wire.SessionMiddleware(newSession))
+	if err != nil {
+		util.Error("new server failed", zap.Error(err))
+		os.Exit(1)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:5432")
+	if err != nil {
+		util.Error("listen failed", zap.Error(err))
+		os.Exit(1)
+	}
+	server.Serve(cancelListener{Listener: listener})
 }
 
 func handler(ctx context.Context, query string) (wire.PreparedStatements, error) {
@@ -73,12 +112,13 @@ func handler(ctx context.Context, query string) (wire.PreparedStatements, error)
 	storage.BeginQuery(txn)
 
 	//init runner
-	run, err := plan.InitRunner(&runCfg, txn, query)
+	cfg := sessionCfg(ctx)
+	run, err := plan.InitRunner(cfg, txn, query)
 	if err != nil {
 		return nil, err
 	}
 	execCtx := ExecCtx{
-		cfg: &runCfg,
+		cfg: cfg,
 		run: run,
 	}
 
@@ -109,7 +149,16 @@ func (exec *ExecCtx) handleX(ctx context.Context, writer wire.DataWriter, parame
 	}()
 
 	//run stmt
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if b := sessionBackend(ctx); b != nil {
+		b.run(cancel)
+		defer b.run(nil)
+	}
 	err = exec.run.Run(ctx, writer)
+	if errors.Is(err, plan.ErrQueryCanceled) || errors.Is(err, plan.ErrStatementTimeout) {
+		return psqlerr.WithCode(err, codes.QueryCanceled)
+	}
 	if err != nil {
 		return err
 	}
@@ -15,6 +15,8 @@
 package chunk
 
 import (
+	"math"
+
 	"github.com/daviszhen/plan/pkg/common"
 	"github.com/daviszhen/plan/pkg/util"
 )
@@ -138,6 +140,24 @@ func (op HashOpDate) operation(input common.Date, isNull bool) uint64 {
 	}
 }
 
+type HashFuncInterval struct {
+}
+
+func (hfun HashFuncInterval) fun(value common.Interval) uint64 {
+	return murmurhash64(uint64(value.Months)) ^ murmurhash64(uint64(value.Days)) ^ murmurhash64(uint64(value.Micros))
+}
+
+type HashOpInterval struct {
+}
+
+func (op HashOpInterval) operation(input common.Interval, isNull bool) uint64 {
+	if isNull {
+		return NULL_HASH
+	} else {
+		return HashFuncInterval{}.fun(input)
+	}
+}
+
 type HashFuncDecimal struct {
 }
 
@@ -179,6 +199,88 @@ func (op HashOpHugeint) operation(input common.Hugeint, isNull bool) uint64 {
 	}
 }
 
+type HashFuncInt16 struct {
+}
+
+func (hfun HashFuncInt16) fun(value int16) uint64 {
+	return murmurhash32(uint32(value))
+}
+
+type HashOpInt16 struct {
+}
+
+func (op HashOpInt16) operation(input int16, isNull bool) uint64 {
+	if isNull {
+		return NULL_HASH
+	} else {
+		return HashFuncInt16{}.fun(input)
+	}
+}
+
+type HashFuncBool struct {
+}
+
+func (hfun HashFuncBool) fun(value bool) uint64 {
+	if value {
+		return murmurhash32(1)
+	}
+	return murmurhash32(0)
+}
+
+type HashOpBool struct {
+}
+
+func (op HashOpBool) operation(input bool, isNull bool) uint64 {
+	if isNull {
+		return NULL_HASH
+	} else {
+		return HashFuncBool{}.fun(input)
+	}
+}
+
+type HashFuncDouble struct {
+}
+
+// fun hashes the bits of the value. -0 and 0 have the same hash,
+// all the NaN have the same hash.
+func (hfun HashFuncDouble) fun(value float64) uint64 {
+	if value == 0 {
+		value = 0
+	} else if math.IsNaN(value) {
+		value = math.NaN()
+	}
+	return murmurhash64(math.Float64bits(value))
+}
+
+type HashOpDouble struct {
+}
+
+func (op HashOpDouble) operation(input float64, isNull bool) uint64 {
+	if isNull {
+		return NULL_HASH
+	} else {
+		return HashFuncDouble{}.fun(input)
+	}
+}
+
+type HashFuncFloat struct {
+}
+
+func (hfun HashFuncFloat) fun(value float32) uint64 {
+	return HashFuncDouble{}.fun(float64(value))
+}
+
+type HashOpFloat struct {
+}
+
+func (op HashOpFloat) operation(input float32, isNull bool) uint64 {
+	if isNull {
+		return NULL_HASH
+	} else {
+		return HashFuncFloat{}.fun(input)
+	}
+}
+
 func HashTypeSwitch(
 	input, result *Vector,
 	rsel *SelectVector,
@@ -186,6 +288,9 @@ func HashTypeSwitch(
 	hasRsel bool,
 ) {
 	util.AssertFunc(result.Typ().Id == common.LTID_UBIGINT)
+	if input.Typ().IsNested() {
+		input = encodeForHash(input, rsel, count, hasRsel)
+	}
 	switch input.Typ().GetInternalType() {
 	case common.INT32:
 		TemplatedLoopHash[int32](input, result, rsel, count, hasRsel, HashOpInt32{}, HashFuncInt32{})
@@ -193,6 +298,14 @@ func HashTypeSwitch(
 		TemplatedLoopHash[int64](input, result, rsel, count, hasRsel, HashOpInt64{}, HashFuncInt64{})
 	case common.INT8:
 		TemplatedLoopHash[int8](input, result, rsel, count, hasRsel, HashOpInt8{}, HashFuncInt8{})
+	case common.INT16:
+		TemplatedLoopHash[int16](input, result, rsel, count, hasRsel, HashOpInt16{}, HashFuncInt16{})
+	case common.BOOL:
+		TemplatedLoopHash[bool](input, result, rsel, count, hasRsel, HashOpBool{}, HashFuncBool{})
+	case common.FLOAT:
+		TemplatedLoopHash[float32](input, result, rsel, count, hasRsel, HashOpFloat{}, HashFuncFloat{})
+	case common.DOUBLE:
+		TemplatedLoopHash[float64](input, result, rsel, count, hasRsel, HashOpDouble{}, HashFuncDouble{})
 	case common.VARCHAR:
 		TemplatedLoopHash[common.String](input, result, rsel, count, hasRsel, HashOpString{}, HashFuncString{})
 	case common.DECIMAL:
@@ -201,11 +314,24 @@ func HashTypeSwitch(
 		TemplatedLoopHash[common.Hugeint](input, result, rsel, count, hasRsel, HashOpHugeint{}, HashFuncHugeint{})
 	case common.DATE:
 		TemplatedLoopHash[common.Date](input, result, rsel, count, hasRsel, HashOpDate{}, HashFuncDate{})
+	case common.INTERVAL:
+		TemplatedLoopHash[common.Interval](input, result, rsel, count, hasRsel, HashOpInterval{}, HashFuncInterval{})
 	default:
 		panic("Unknown input type")
 	}
 }
 
+// encodeForHash encodes the nested values. the equal values have the same encoding.
+func encodeForHash(input *Vector, rsel *SelectVector, count int, hasRsel bool) *Vector {
+	n := count
+	if hasRsel {
+		for i := 0; i < count; i++ {
+			n = max(n, rsel.GetIndex(i)+1)
+		}
+	}
+	return EncodeVector(input, n)
+}
+
 func TemplatedLoopHash[T any](
 	input, result *Vector,
 	rsel *SelectVector,
@@ -278,6 +404,9 @@ func CombineHashTypeSwitch(
 	hasRsel bool,
 ) {
 	util.AssertFunc(hashes.Typ().Id == common.LTID_UBIGINT)
+	if input.Typ().IsNested() {
+		input = encodeForHash(input, rsel, count, hasRsel)
+	}
 	switch input.Typ().GetInternalType() {
 	case common.INT32:
 		TemplatedLoopCombineHash[int32](input, hashes, rsel, count, hasRsel, HashOpInt32{}, HashFuncInt32{})
@@ -285,10 +414,24 @@ func CombineHashTypeSwitch(
 		TemplatedLoopCombineHash[int64](input, hashes, rsel, count, hasRsel, HashOpInt64{}, HashFuncInt64{})
 	case common.DATE:
 		TemplatedLoopCombineHash[common.Date](input, hashes, rsel, count, hasRsel, HashOpDate{}, HashFuncDate{})
+	case common.INTERVAL:
+		TemplatedLoopCombineHash[common.Interval](input, hashes, rsel, count, hasRsel, HashOpInterval{}, HashFuncInterval{})
 	case common.DECIMAL:
 		TemplatedLoopCombineHash[common.Decimal](input, hashes, rsel, count, hasRsel, HashOpDecimal{}, HashFuncDecimal{})
 	case common.VARCHAR:
 		TemplatedLoopCombineHash[common.String](input, hashes, rsel, count, hasRsel, HashOpString{}, HashFuncString{})
+	case common.INT8:
+		TemplatedLoopCombineHash[int8](input, hashes, rsel, count, hasRsel, HashOpInt8{}, HashFuncInt8{})
+	case common.INT16:
+		TemplatedLoopCombineHash[int16](input, hashes, rsel, count, hasRsel, HashOpInt16{}, HashFuncInt16{})
+	case common.INT128:
+		TemplatedLoopCombineHash[common.Hugeint](input, hashes, rsel, count, hasRsel, HashOpHugeint{}, HashFuncHugeint{})
+	case common.BOOL:
+		TemplatedLoopCombineHash[bool](input, hashes, rsel, count, hasRsel, HashOpBool{}, HashFuncBool{})
+	case common.FLOAT:
+		TemplatedLoopCombineHash[float32](input, hashes, rsel, count, hasRsel, HashOpFloat{}, HashFuncFloat{})
+	case common.DOUBLE:
+		TemplatedLoopCombineHash[float64](input, hashes, rsel, count, hasRsel, HashOpDouble{}, HashFuncDouble{})
 	default:
 		panic("Unknown input type")
 	}
@@ -0,0 +1,437 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// nested vectors
+//
+// LIST (and MAP): Data holds common.ListEntry per row. Aux is a VBT_LIST
+// buffer holding the child vector and the number of child rows in use.
+// Entries of different rows may share the same child range.
+//
+// STRUCT: Data is empty. Aux is a VBT_STRUCT buffer holding one vector
+// per field. The struct rows are the rows of the field vectors.
+
+func NewListBuffer(typ common.LType, cap int) *VecBuffer {
+	cap = max(cap, 1)
+	return &VecBuffer{
+		BufTyp:   VBT_LIST,
+		Child:    NewFlatVector(typ.ListChild(), cap),
+		Capacity: cap,
+	}
+}
+
+func NewStructBuffer(typ common.LType, cap int) *VecBuffer {
+	buf := &VecBuffer{
+		BufTyp:   VBT_STRUCT,
+		Capacity: cap,
+	}
+	for _, child := range typ.Children {
+		buf.Children = append(buf.Children, NewFlatVector(child, cap))
+	}
+	return buf
+}
+
+func newNestedBuffer(typ common.LType, cap int) *VecBuffer {
+	switch typ.Id {
+	case common.LTID_LIST, common.LTID_MAP:
+		return NewListBuffer(typ, cap)
+	case common.LTID_STRUCT:
+		return NewStructBuffer(typ, cap)
+	default:
+		panic("usp")
+	}
+}
+
+func nestedAux(vec *Vector) *VecBuffer {
+	if vec.PhyFormat().IsDict() {
+		return nestedAux(GetChildInPhyFormatDict(vec))
+	}
+	util.AssertFunc(vec.Aux != nil)
+	return vec.Aux
+}
+
+// GetListChild returns the child vector of LIST or MAP vector.
+func GetListChild(vec *Vector) *Vector {
+	buf := nestedAux(vec)
+	util.AssertFunc(buf.BufTyp == VBT_LIST)
+	return buf.Child
+}
+
+// GetListSize returns the count of child rows in use.
+func GetListSize(vec *Vector) int {
+	return nestedAux(vec).Size
+}
+
+func SetListSize(vec *Vector, size int) {
+	buf := nestedAux(vec)
+	ReserveList(vec, size)
+	buf.Size = size
+}
+
+// ReserveList makes sure the child vector can hold cap rows.
+func ReserveList(vec *Vector, cap int) {
+	buf := nestedAux(vec)
+	if cap <= buf.Capacity {
+		return
+	}
+	newCap := buf.Capacity
+	for newCap < cap {
+		newCap *= 2
+	}
+	growVector(buf.Child, buf.Capacity, newCap)
+	buf.Capacity = newCap
+}
+
+func growVector(vec *Vector, oldCap, newCap int) {
+	util.AssertFunc(vec.PhyFormat().IsFlat())
+	if sz := vec.Typ().GetInternalType().Size(); sz > 0 {
+		buf := NewStandardBuffer(vec.Typ(), newCap)
+		copy(buf.Data, vec.Data)
+		vec.Buf = buf
+		vec.Data = buf.Data
+	}
+	if vec.Mask.IsMaskSet() {
+		vec.Mask.Resize(oldCap, newCap)
+	}
+	if vec.Typ().Id == common.LTID_STRUCT {
+		for _, child := range vec.Aux.Children {
+			growVector(child, oldCap, newCap)
+		}
+		vec.Aux.Capacity = newCap
+	}
+}
+
+// AppendToList appends one value to the child of the list vector
+// and returns its position in the child.
+func AppendToList(vec *Vector, val *Value) int {
+	buf := nestedAux(vec)
+	pos := buf.Size
+	ReserveList(vec, pos+1)
+	if val.IsNull {
+		buf.Child.Mask.PrepareSpace(buf.Capacity)
+	}
+	buf.Child.SetValue(pos, val)
+	buf.Size++
+	return pos
+}
+
+// GetStructChildren returns the field vectors of STRUCT vector.
+func GetStructChildren(vec *Vector) []*Vector {
+	buf := nestedAux(vec)
+	util.AssertFunc(buf.BufTyp == VBT_STRUCT)
+	return buf.Children
+}
+
+func (vec *Vector) getNestedValue(idx int) *Value {
+	ret := &Value{
+		Typ: vec.Typ(),
+	}
+	switch vec.Typ().Id {
+	case common.LTID_LIST, common.LTID_MAP:
+		entry := GetSliceInPhyFormatFlat[common.ListEntry](vec)[idx]
+		child := GetListChild(vec)
+		ret.Children = make([]*Value, 0, entry.Length)
+		for i := entry.Offset; i < entry.Offset+entry.Length; i++ {
+			ret.Children = append(ret.Children, child.GetValue(int(i)))
+		}
+	case common.LTID_STRUCT:
+		for _, child := range GetStructChildren(vec) {
+			ret.Children = append(ret.Children, child.GetValue(idx))
+		}
+	default:
+		panic("usp")
+	}
+	return ret
+}
+
+func (vec *Vector) setNestedValue(idx int, val *Value) {
+	switch vec.Typ().Id {
+	case common.LTID_LIST, common.LTID_MAP:
+		slice := GetSliceInPhyFormatFlat[common.ListEntry](vec)
+		if val.IsNull {
+			slice[idx] = common.ListEntry{}
+			return
+		}
+		offset := GetListSize(vec)
+		for _, child := range val.Children {
+			AppendToList(vec, child)
+		}
+		slice[idx] = common.ListEntry{
+			Offset: uint64(offset),
+			Length: uint64(len(val.Children)),
+		}
+	case common.LTID_STRUCT:
+		for i, child := range GetStructChildren(vec) {
+			if val.IsNull {
+				child.SetValue(idx, &Value{Typ: child.Typ(), IsNull: true})
+			} else {
+				child.SetValue(idx, val.Children[i])
+			}
+		}
+	default:
+		panic("usp")
+	}
+}
+
+// referenceNestedValue makes the children of constant STRUCT
+// constant too. Flatten then expands them together.
+func (vec *Vector) referenceNestedValue(val *Value) {
+	vec.Aux = newNestedBuffer(vec.Typ(), 1)
+	if vec.Typ().Id == common.LTID_STRUCT {
+		for i, child := range vec.Aux.Children {
+			constChild := NewConstVector(child.Typ())
+			if val.IsNull {
+				constChild.ReferenceValue(&Value{Typ: child.Typ(), IsNull: true})
+			} else {
+				constChild.ReferenceValue(val.Children[i])
+			}
+			vec.Aux.Children[i] = constChild
+		}
+		vec.Mask.Set(0, !val.IsNull)
+		return
+	}
+	vec.SetValue(0, val)
+}
+
+func (vec *Vector) flattenStruct(cnt int) {
+	for _, child := range vec.Aux.Children {
+		child.Flatten(cnt)
+	}
+}
+
+func (val Value) nestedString() string {
+	switch val.Typ.Id {
+	case common.LTID_LIST:
+		elems := make([]string, len(val.Children))
+		for i, child := range val.Children {
+			elems[i] = child.String()
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case common.LTID_STRUCT:
+		fields := make([]string, len(val.Children))
+		for i, child := range val.Children {
+			fields[i] = fmt.Sprintf("'%s': %v", val.Typ.ChildNames[i], child)
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case common.LTID_MAP:
+		entries := make([]string, len(val.Children))
+		for i, child := range val.Children {
+			entries[i] = fmt.Sprintf("%v=%v", child.Children[0], child.Children[1])
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	default:
+		panic("usp")
+	}
+}
+
+// NewListValue builds a LIST value from the elements.
+func NewListValue(childTyp common.LType, elems []*Value) *Value {
+	return &Value{
+		Typ:      common.ListType(childTyp),
+		Children: elems,
+	}
+}
+
+// NewStructValue builds a STRUCT value from the fields.
+func NewStructValue(typ common.LType, fields []*Value) *Value {
+	util.AssertFunc(len(typ.Children) == len(fields))
+	return &Value{
+		Typ:      typ,
+		Children: fields,
+	}
+}
+
+// fields of the encoded leaf value
+const (
+	encBool uint8 = 1 << iota
+	encI64
+	encI64_1
+	encI64_2
+	encU64
+	encF64
+	encStr
+)
+
+// EncodeVector encodes the nested values into a flat VARCHAR vector.
+func EncodeVector(vec *Vector, cnt int) *Vector {
+	typ := common.VarcharType()
+	encoded := NewFlatVector(typ, max(cnt, util.DefaultVectorSize))
+	for i := 0; i < cnt; i++ {
+		val := vec.GetValue(i)
+		if val.IsNull {
+			encoded.SetValue(i, &Value{Typ: typ, IsNull: true})
+			continue
+		}
+		encoded.SetValue(i, &Value{Typ: typ, Str: string(EncodeValue(val))})
+	}
+	return encoded
+}
+
+// DecodeVector decodes the VARCHAR vector encoded by EncodeVector.
+func DecodeVector(encoded, result *Vector, cnt int) {
+	result.Init(max(cnt, util.DefaultVectorSize))
+	result.SetPhyFormat(PF_FLAT)
+	for i := 0; i < cnt; i++ {
+		result.SetValue(i, DecodeString(result.Typ(), encoded.GetValue(i)))
+	}
+}
+
+// DecodeString decodes the VARCHAR value encoded by EncodeVector.
+// The encoded value is never empty. The empty string is NULL.
+func DecodeString(typ common.LType, val *Value) *Value {
+	if val.IsNull || len(val.Str) == 0 {
+		return &Value{Typ: typ, IsNull: true}
+	}
+	return DecodeValue(typ, []byte(val.Str))
+}
+
+// EncodeValue encodes the value into bytes. The type is not encoded.
+//
+// NULL: 0
+// nested: 1, count of children, children
+// leaf: mask of the non-zero fields, fields
+func EncodeValue(val *Value) []byte {
+	return appendValue(nil, val)
+}
+
+func appendValue(buf []byte, val *Value) []byte {
+	if val.IsNull {
+		return append(buf, 0)
+	}
+	if val.Typ.IsNested() {
+		buf = append(buf, 1)
+		buf = binary.AppendUvarint(buf, uint64(len(val.Children)))
+		for _, child := range val.Children {
+			buf = appendValue(buf, child)
+		}
+		return buf
+	}
+	mask := uint8(0)
+	if val.Bool {
+		mask |= encBool
+	}
+	if val.I64 != 0 {
+		mask |= encI64
+	}
+	if val.I64_1 != 0 {
+		mask |= encI64_1
+	}
+	if val.I64_2 != 0 {
+		mask |= encI64_2
+	}
+	if val.U64 != 0 {
+		mask |= encU64
+	}
+	if val.F64 != 0 {
+		mask |= encF64
+	}
+	if val.Str != "" {
+		mask |= encStr
+	}
+	//leaf is not NULL even if all fields are zero
+	buf = append(buf, 2, mask)
+	if mask&encI64 != 0 {
+		buf = binary.AppendVarint(buf, val.I64)
+	}
+	if mask&encI64_1 != 0 {
+		buf = binary.AppendVarint(buf, val.I64_1)
+	}
+	if mask&encI64_2 != 0 {
+		buf = binary.AppendVarint(buf, val.I64_2)
+	}
+	if mask&encU64 != 0 {
+		buf = binary.AppendUvarint(buf, val.U64)
+	}
+	if mask&encF64 != 0 {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(val.F64))
+	}
+	if mask&encStr != 0 {
+		buf = binary.AppendUvarint(buf, uint64(len(val.Str)))
+		buf = append(buf, val.Str...)
+	}
+	return buf
+}
+
+// DecodeValue decodes the value of the type encoded by the EncodeValue
+func DecodeValue(typ common.LType, data []byte) *Value {
+	val, _ := decodeValue(typ, data)
+	return val
+}
+
+func decodeValue(typ common.LType, data []byte) (*Value, []byte) {
+	val := &Value{Typ: typ}
+	flag := data[0]
+	data = data[1:]
+	switch flag {
+	case 0:
+		val.IsNull = true
+	case 1:
+		cnt, n := binary.Uvarint(data)
+		data = data[n:]
+		val.Children = make([]*Value, cnt)
+		for i := range val.Children {
+			var childTyp common.LType
+			switch typ.Id {
+			case common.LTID_STRUCT:
+				childTyp = typ.Children[i]
+			default:
+				childTyp = typ.ListChild()
+			}
+			val.Children[i], data = decodeValue(childTyp, data)
+		}
+	default:
+		mask := data[0]
+		data = data[1:]
+		var n int
+		val.Bool = mask&encBool != 0
+		if mask&encI64 != 0 {
+			val.I64, n = binary.Varint(data)
+			data = data[n:]
+		}
+		if mask&encI64_1 != 0 {
+			val.I64_1, n = binary.Varint(data)
+			data = data[n:]
+		}
+		if mask&encI64_2 != 0 {
+			val.I64_2, n = binary.Varint(data)
+			data = data[n:]
+		}
+		if mask&encU64 != 0 {
+			val.U64, n = binary.Uvarint(data)
+			data = data[n:]
+		}
+		if mask&encF64 != 0 {
+			val.F64 = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		}
+		if mask&encStr != 0 {
+			l, n := binary.Uvarint(data)
+			data = data[n:]
+			val.Str = string(data[:l])
+			data = data[l:]
+		}
+	}
+	return val, data
+}
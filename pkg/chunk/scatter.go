@@ -210,3 +210,22 @@ func (scatter DateScatterOp) Store(src common.Date, rowLoc unsafe.Pointer, offse
 	dst := src
 	util.Store[common.Date](dst, util.PointerAdd(rowLoc, offsetInRow))
 }
+
+type IntervalScatterOp struct {
+}
+
+func (scatter IntervalScatterOp) NullValue() common.Interval {
+	return common.Interval{}
+}
+
+func (scatter IntervalScatterOp) RandValue() common.Interval {
+	return common.Interval{
+		Months: rand.Int32N(24),
+		Days:   rand.Int32N(31),
+		Micros: rand.Int64N(common.MicrosPerDay),
+	}
+}
+
+func (scatter IntervalScatterOp) Store(src common.Interval, rowLoc unsafe.Pointer, offsetInRow int, heapLoc *unsafe.Pointer) {
+	util.Store[common.Interval](src, util.PointerAdd(rowLoc, offsetInRow))
+}
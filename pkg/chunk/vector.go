@@ -117,6 +117,8 @@ const (
 	VBT_DICT
 	VBT_CHILD
 	VBT_STRING
+	VBT_LIST
+	VBT_STRUCT
 )
 
 type UnifiedFormat struct {
@@ -132,6 +134,10 @@ type VecBuffer struct {
 	Data   []byte
 	Sel    *SelectVector
 	Child  *Vector
+	//nested
+	Children []*Vector
+	Size     int
+	Capacity int
 }
 
 func (buf *VecBuffer) GetSelVector() *SelectVector {
@@ -150,6 +156,8 @@ type Value struct {
 	U64   uint64
 	F64   float64
 	Str   string
+	//elements of LIST, MAP. fields of STRUCT
+	Children []*Value
 }
 
 func (val Value) String() string {
@@ -157,11 +165,11 @@ func (val Value) String() string {
 		return "NULL"
 	}
 	switch val.Typ.Id {
-	case common.LTID_INTEGER:
+	case common.LTID_SMALLINT, common.LTID_INTEGER:
 		return fmt.Sprintf("%d", val.I64)
 	case common.LTID_BOOLEAN:
 		return fmt.Sprintf("%v", val.Bool)
-	case common.LTID_VARCHAR:
+	case common.LTID_VARCHAR, common.LTID_JSON:
 		return val.Str
 	case common.LTID_DECIMAL:
 		if len(val.Str) != 0 {
@@ -187,12 +195,34 @@ func (val Value) String() string {
 		return fmt.Sprintf("%v", val.F64)
 	case common.LTID_POINTER:
 		return fmt.Sprintf("0x%x", val.I64)
+	case common.LTID_TIMESTAMP:
+		return common.TimestampToString(val.I64)
+	case common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		return common.TimestampToString(common.TimestampToMicros(val.Typ.Id, val.I64))
+	case common.LTID_TIMESTAMP_TZ:
+		return common.TimestampToString(val.I64) + "+00"
+	case common.LTID_TIME:
+		return common.TimeToString(val.I64)
+	case common.LTID_INTERVAL:
+		interval := common.Interval{
+			Months: int32(val.I64),
+			Days:   int32(val.I64_1),
+			Micros: val.I64_2,
+		}
+		return interval.String()
+	case common.LTID_BLOB:
+		return common.BlobToString([]byte(val.Str))
+	case common.LTID_UUID:
+		return common.UUIDToString(&common.Hugeint{Upper: val.I64, Lower: uint64(val.I64_1)})
 	case common.LTID_HUGEINT:
 		h := big.NewInt(val.I64)
-		l := big.NewInt(val.I64_1)
+		//the lower part is unsigned
+		l := new(big.Int).SetUint64(uint64(val.I64_1))
 		h.Lsh(h, 64)
 		h.Add(h, l)
 		return fmt.Sprintf("%v", h.String())
+	case common.LTID_LIST, common.LTID_STRUCT, common.LTID_MAP:
+		return val.nestedString()
 	default:
 		panic("usp")
 	}
@@ -231,7 +261,9 @@ func MaxValue(typ common.LType) *Value {
 		ret.Bool = true
 	case common.LTID_INTEGER:
 		ret.I64 = math.MaxInt32
-	case common.LTID_BIGINT:
+	case common.LTID_BIGINT, common.LTID_TIME,
+		common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
 		ret.I64 = math.MaxInt64
 	case common.LTID_UBIGINT:
 		ret.U64 = math.MaxUint64
@@ -256,7 +288,9 @@ func MinValue(typ common.LType) *Value {
 		ret.Bool = false
 	case common.LTID_INTEGER:
 		ret.I64 = math.MinInt32
-	case common.LTID_BIGINT:
+	case common.LTID_BIGINT, common.LTID_TIME,
+		common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
 		ret.I64 = math.MinInt64
 	case common.LTID_UBIGINT:
 		ret.I64 = 0
@@ -292,6 +326,9 @@ func (vec *Vector) Init(cap int) {
 	if cap > util.DefaultVectorSize {
 		vec.Mask.Resize(util.DefaultVectorSize, cap)
 	}
+	if vec.Typ().IsNested() {
+		vec.Aux = newNestedBuffer(vec.Typ(), cap)
+	}
 }
 
 func (vec *Vector) Typ() common.LType {
@@ -330,6 +367,9 @@ func (vec *Vector) Flatten(cnt int) {
 		vec.Buf = NewStandardBuffer(vec._Typ, int(max(util.DefaultVectorSize, cnt)))
 		vec.Data = vec.Buf.Data
 		vec._PhyFormat = PF_FLAT
+		if vec.Typ().Id == common.LTID_STRUCT {
+			vec.flattenStruct(cnt)
+		}
 		if null {
 			vec.Mask.SetAllInvalid(cnt)
 			return
@@ -359,7 +399,10 @@ func (vec *Vector) Flatten(cnt int) {
 			FlattenConstVector[float32](vec.Data, oldData, pTyp.Size(), cnt)
 		case common.DOUBLE:
 			FlattenConstVector[float64](vec.Data, oldData, pTyp.Size(), cnt)
-		case common.INTERVAL, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.LIST:
+			FlattenConstVector[common.ListEntry](vec.Data, oldData, pTyp.Size(), cnt)
+		case common.STRUCT:
+		case common.INTERVAL, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -451,6 +494,10 @@ func (vec *Vector) ReferenceValue(val *Value) {
 	vec.Buf = NewConstBuffer(val.Typ)
 	vec.Aux = nil
 	vec.Data = GetDataInPhyFormatConst(vec)
+	if vec.Typ().IsNested() {
+		vec.referenceNestedValue(val)
+		return
+	}
 	vec.SetValue(0, val)
 }
 
@@ -489,6 +536,12 @@ func (vec *Vector) GetValue(idx int) *Value {
 	}
 
 	switch vec.Typ().Id {
+	case common.LTID_SMALLINT:
+		data := GetSliceInPhyFormatFlat[int16](vec)
+		return &Value{
+			Typ: vec.Typ(),
+			I64: int64(data[idx]),
+		}
 	case common.LTID_INTEGER:
 		data := GetSliceInPhyFormatFlat[int32](vec)
 		return &Value{
@@ -501,7 +554,7 @@ func (vec *Vector) GetValue(idx int) *Value {
 			Typ:  vec.Typ(),
 			Bool: data[idx],
 		}
-	case common.LTID_VARCHAR:
+	case common.LTID_VARCHAR, common.LTID_JSON:
 		data := GetSliceInPhyFormatFlat[common.String](vec)
 		return &Value{
 			Typ: vec.Typ(),
@@ -538,12 +591,28 @@ func (vec *Vector) GetValue(idx int) *Value {
 			Typ: vec.Typ(),
 			I64: int64(data[idx]),
 		}
-	case common.LTID_BIGINT:
+	case common.LTID_BIGINT, common.LTID_TIME,
+		common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
 		data := GetSliceInPhyFormatFlat[int64](vec)
 		return &Value{
 			Typ: vec.Typ(),
 			I64: data[idx],
 		}
+	case common.LTID_BLOB:
+		data := GetSliceInPhyFormatFlat[common.String](vec)
+		return &Value{
+			Typ: vec.Typ(),
+			Str: data[idx].String(),
+		}
+	case common.LTID_INTERVAL:
+		data := GetSliceInPhyFormatFlat[common.Interval](vec)
+		return &Value{
+			Typ:   vec.Typ(),
+			I64:   int64(data[idx].Months),
+			I64_1: int64(data[idx].Days),
+			I64_2: data[idx].Micros,
+		}
 	case common.LTID_DOUBLE:
 		data := GetSliceInPhyFormatFlat[float64](vec)
 		return &Value{
@@ -562,13 +631,15 @@ func (vec *Vector) GetValue(idx int) *Value {
 			Typ: vec.Typ(),
 			I64: int64(uintptr(data[idx])),
 		}
-	case common.LTID_HUGEINT:
+	case common.LTID_HUGEINT, common.LTID_UUID:
 		data := GetSliceInPhyFormatFlat[common.Hugeint](vec)
 		return &Value{
 			Typ:   vec.Typ(),
 			I64:   data[idx].Upper,
 			I64_1: int64(data[idx].Lower),
 		}
+	case common.LTID_LIST, common.LTID_STRUCT, common.LTID_MAP:
+		return vec.getNestedValue(idx)
 	default:
 		panic("usp")
 	}
@@ -585,6 +656,9 @@ func (vec *Vector) SetValue(idx int, val *Value) {
 	vec.Mask.Set(uint64(idx), !val.IsNull)
 	pTyp := vec.Typ().GetInternalType()
 	switch pTyp {
+	case common.INT16:
+		slice := util.ToSlice[int16](vec.Data, pTyp.Size())
+		slice[idx] = int16(val.I64)
 	case common.INT32:
 		slice := util.ToSlice[int32](vec.Data, pTyp.Size())
 		slice[idx] = int32(val.I64)
@@ -612,16 +686,15 @@ func (vec *Vector) SetValue(idx int, val *Value) {
 		interVal := common.Interval{}
 		switch strings.ToLower(val.Str) {
 		case "year":
-			interVal.Year = int32(val.I64)
-			interVal.Unit = val.Str
+			interVal.Months = int32(val.I64) * 12
 		case "month":
 			interVal.Months = int32(val.I64)
-			interVal.Unit = val.Str
 		case "day":
 			interVal.Days = int32(val.I64)
-			interVal.Unit = val.Str
-			//default:
-			//	panic("usp")
+		default:
+			interVal.Months = int32(val.I64)
+			interVal.Days = int32(val.I64_1)
+			interVal.Micros = val.I64_2
 		}
 		slice[idx] = interVal
 	case common.DATE:
@@ -662,6 +735,8 @@ func (vec *Vector) SetValue(idx int, val *Value) {
 		slice := util.ToSlice[common.Hugeint](vec.Data, pTyp.Size())
 		slice[idx].Upper = val.I64
 		slice[idx].Lower = uint64(val.I64_1)
+	case common.LIST, common.STRUCT:
+		vec.setNestedValue(idx, val)
 	default:
 		panic("usp")
 	}
@@ -739,6 +814,13 @@ func (vec *Vector) Serialize(count int, serial util.Serialize) error {
 					}
 				}
 			}
+		case common.LIST, common.STRUCT:
+			for i := 0; i < count; i++ {
+				err = util.WriteString(string(EncodeValue(vec.GetValue(i))), serial)
+				if err != nil {
+					return err
+				}
+			}
 		default:
 			panic("usp")
 		}
@@ -794,6 +876,14 @@ func (vec *Vector) Deserialize(count int, deserial util.Deserialize) error {
 					strSlice[i] = str
 				}
 			}
+		case common.LIST, common.STRUCT:
+			for i := 0; i < count; i++ {
+				data, err := util.ReadString(deserial)
+				if err != nil {
+					return err
+				}
+				vec.SetValue(i, DecodeValue(typ, []byte(data)))
+			}
 		default:
 			panic("usp")
 		}
@@ -1120,6 +1210,30 @@ func Copy(
 			dstOffset,
 			copyCount,
 		)
+	case common.BOOL:
+		TemplatedCopy[bool](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.UINT8:
+		TemplatedCopy[uint8](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.INT8:
+		TemplatedCopy[int8](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.UINT16:
+		TemplatedCopy[uint16](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.INT16:
+		TemplatedCopy[int16](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.UINT32:
+		TemplatedCopy[uint32](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.UINT64:
+		TemplatedCopy[uint64](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.INT64:
+		TemplatedCopy[int64](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.FLOAT:
+		TemplatedCopy[float32](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.DOUBLE:
+		TemplatedCopy[float64](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.INT128:
+		TemplatedCopy[common.Hugeint](src, sel, dstP, srcOffset, dstOffset, copyCount)
+	case common.INTERVAL:
+		TemplatedCopy[common.Interval](src, sel, dstP, srcOffset, dstOffset, copyCount)
 	case common.VARCHAR:
 		srcSlice := GetSliceInPhyFormatFlat[common.String](src)
 		dstSlice := GetSliceInPhyFormatFlat[common.String](dstP)
@@ -1134,6 +1248,14 @@ func Copy(
 				dstSlice[dstIdx] = common.String{Data: ptr, Len: srcStr.Length()}
 			}
 		}
+	case common.LIST, common.STRUCT:
+		for i := 0; i < copyCount; i++ {
+			srcIdx := sel.GetIndex(srcOffset + i)
+			dstIdx := dstOffset + i
+			if dstBitmap.RowIsValid(uint64(dstIdx)) {
+				dstP.SetValue(dstIdx, src.GetValue(srcIdx))
+			}
+		}
 	default:
 		panic("usp")
 	}
@@ -1184,6 +1306,8 @@ func WriteToStorage(
 		SaveLoop[common.Decimal](&vdata, count, ptr, DecimalScatterOp{})
 	case common.DATE:
 		SaveLoop[common.Date](&vdata, count, ptr, DateScatterOp{})
+	case common.INTERVAL:
+		SaveLoop[common.Interval](&vdata, count, ptr, IntervalScatterOp{})
 	case common.INT64:
 		SaveLoop[int64](&vdata, count, ptr, Int64ScatterOp{})
 	case common.UINT64:
@@ -1224,6 +1348,8 @@ func ReadFromStorage(
 		ReadLoop[common.Decimal](ptr, count, res)
 	case common.DATE:
 		ReadLoop[common.Date](ptr, count, res)
+	case common.INTERVAL:
+		ReadLoop[common.Interval](ptr, count, res)
 	case common.INT64:
 		ReadLoop[int64](ptr, count, res)
 	case common.UINT64:
@@ -0,0 +1,425 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MicrosPerMilli  int64 = 1000
+	MicrosPerSecond int64 = 1000 * MicrosPerMilli
+	MicrosPerMinute int64 = 60 * MicrosPerSecond
+	MicrosPerHour   int64 = 60 * MicrosPerMinute
+	MicrosPerDay    int64 = 24 * MicrosPerHour
+)
+
+// timestamp is the microseconds since 1970-01-01 00:00:00 UTC.
+// time is the microseconds since 00:00:00.
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	time.DateOnly,
+}
+
+func TimeToTimestamp(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func TimestampToTime(ts int64) time.Time {
+	return time.UnixMicro(ts).UTC()
+}
+
+func ParseTimestamp(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return TimeToTimestamp(t), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp '%s'", s)
+}
+
+func TimestampToString(ts int64) string {
+	t := TimestampToTime(ts)
+	if t.Nanosecond() == 0 {
+		return t.Format(time.DateTime)
+	}
+	return t.Format("2006-01-02 15:04:05.999999")
+}
+
+// TimestampToMicros converts the timestamp in the unit of the type
+// into the microseconds.
+func TimestampToMicros(id LTypeId, ts int64) int64 {
+	switch id {
+	case LTID_TIMESTAMP_SEC:
+		return ts * MicrosPerSecond
+	case LTID_TIMESTAMP_MS:
+		return ts * MicrosPerMilli
+	case LTID_TIMESTAMP_NS:
+		return ts / 1000
+	default:
+		return ts
+	}
+}
+
+// MicrosToTimestamp converts the microseconds into the timestamp
+// in the unit of the type.
+func MicrosToTimestamp(id LTypeId, micros int64) int64 {
+	switch id {
+	case LTID_TIMESTAMP_SEC:
+		return micros / MicrosPerSecond
+	case LTID_TIMESTAMP_MS:
+		return micros / MicrosPerMilli
+	case LTID_TIMESTAMP_NS:
+		return micros * 1000
+	default:
+		return micros
+	}
+}
+
+func DateToTimestamp(d *Date) int64 {
+	return TimeToTimestamp(d.ToDate())
+}
+
+func TimestampToDate(ts int64) Date {
+	y, m, d := TimestampToTime(ts).Date()
+	return Date{
+		Year:  int32(y),
+		Month: int32(m),
+		Day:   int32(d),
+	}
+}
+
+// TimestampAddInterval adds the months, the days and the micros in order.
+func TimestampAddInterval(ts int64, interval *Interval) int64 {
+	t := addMonthsDays(TimestampToTime(ts), interval.Months, interval.Days)
+	return TimeToTimestamp(t) + interval.Micros
+}
+
+// addMonthsDays adds the months first. the day is clamped to the last day
+// of the month. 2024-01-31 + 1 month is 2024-02-29.
+// then adds the days.
+func addMonthsDays(t time.Time, months, days int32) time.Time {
+	if months != 0 {
+		y, m, d := t.Date()
+		//the first day of the result month
+		first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		d = min(d, last)
+		hh, mm, ss := t.Clock()
+		t = time.Date(first.Year(), first.Month(), d, hh, mm, ss, t.Nanosecond(), time.UTC)
+	}
+	if days != 0 {
+		t = t.AddDate(0, 0, int(days))
+	}
+	return t
+}
+
+// TimestampToTimeOfDay extracts the time part of the timestamp
+func TimestampToTimeOfDay(ts int64) int64 {
+	t := ts % MicrosPerDay
+	if t < 0 {
+		t += MicrosPerDay
+	}
+	return t
+}
+
+func ParseTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return int64(t.Hour())*MicrosPerHour +
+				int64(t.Minute())*MicrosPerMinute +
+				int64(t.Second())*MicrosPerSecond +
+				int64(t.Nanosecond())/1000, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time '%s'", s)
+}
+
+func TimeToString(micros int64) string {
+	return formatTimeOfDay(micros, true)
+}
+
+// formatTimeOfDay formats the micros as HH:MM:SS[.ffffff].
+// the hour is not bounded to 24.
+func formatTimeOfDay(micros int64, padHour bool) string {
+	h := micros / MicrosPerHour
+	micros %= MicrosPerHour
+	m := micros / MicrosPerMinute
+	micros %= MicrosPerMinute
+	sec := micros / MicrosPerSecond
+	micros %= MicrosPerSecond
+	var ret string
+	if padHour {
+		ret = fmt.Sprintf("%02d:%02d:%02d", h, m, sec)
+	} else {
+		ret = fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	if micros != 0 {
+		ret += strings.TrimRight(fmt.Sprintf(".%06d", micros), "0")
+	}
+	return ret
+}
+
+func (i *Interval) String() string {
+	parts := make([]string, 0)
+	plural := func(n int64, unit string) {
+		if n == 0 {
+			return
+		}
+		if n == 1 || n == -1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, unit))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit))
+		}
+	}
+	months := int64(i.Months)
+	plural(months/12, "year")
+	plural(months%12, "mon")
+	plural(int64(i.Days), "day")
+	if i.Micros != 0 {
+		if i.Micros < 0 {
+			parts = append(parts, "-"+formatTimeOfDay(-i.Micros, true))
+		} else {
+			parts = append(parts, formatTimeOfDay(i.Micros, true))
+		}
+	}
+	if len(parts) == 0 {
+		return "00:00:00"
+	}
+	return strings.Join(parts, " ")
+}
+
+var intervalUnits = map[string]string{
+	"year": "year", "years": "year", "y": "year", "yr": "year", "yrs": "year",
+	"month": "month", "months": "month", "mon": "month", "mons": "month",
+	"week": "week", "weeks": "week", "w": "week",
+	"day": "day", "days": "day", "d": "day",
+	"hour": "hour", "hours": "hour", "h": "hour", "hr": "hour", "hrs": "hour",
+	"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute", "m": "minute",
+	"second": "second", "seconds": "second", "sec": "second", "secs": "second", "s": "second",
+	"millisecond": "millisecond", "milliseconds": "millisecond", "ms": "millisecond", "msec": "millisecond",
+	"microsecond": "microsecond", "microseconds": "microsecond", "us": "microsecond", "usec": "microsecond",
+}
+
+// ParseInterval parses the interval like '1 year 2 months 3 days 04:05:06'
+// or '3 hours ago'.
+func ParseInterval(s string) (Interval, error) {
+	ret := Interval{}
+	invalid := fmt.Errorf("invalid interval '%s'", s)
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return ret, invalid
+	}
+	ago := false
+	if fields[len(fields)-1] == "ago" {
+		ago = true
+		fields = fields[:len(fields)-1]
+	}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Contains(field, ":") {
+			neg := strings.HasPrefix(field, "-")
+			micros, err := parseIntervalTime(strings.TrimLeft(field, "+-"))
+			if err != nil {
+				return ret, invalid
+			}
+			if neg {
+				micros = -micros
+			}
+			ret.Micros += micros
+			continue
+		}
+		num, err := strconv.ParseFloat(field, 64)
+		if err != nil || i+1 >= len(fields) {
+			return ret, invalid
+		}
+		i++
+		switch intervalUnits[fields[i]] {
+		case "year":
+			ret.Months += int32(num * 12)
+		case "month":
+			ret.Months += int32(num)
+		case "week":
+			ret.Days += int32(num * 7)
+		case "day":
+			ret.Days += int32(num)
+		case "hour":
+			ret.Micros += int64(num * float64(MicrosPerHour))
+		case "minute":
+			ret.Micros += int64(num * float64(MicrosPerMinute))
+		case "second":
+			ret.Micros += int64(num * float64(MicrosPerSecond))
+		case "millisecond":
+			ret.Micros += int64(num * float64(MicrosPerMilli))
+		case "microsecond":
+			ret.Micros += int64(num)
+		default:
+			return ret, invalid
+		}
+	}
+	if ago {
+		ret.Months, ret.Days, ret.Micros = -ret.Months, -ret.Days, -ret.Micros
+	}
+	return ret, nil
+}
+
+func parseIntervalTime(s string) (int64, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	h, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	ret := h*MicrosPerHour + m*MicrosPerMinute
+	if len(parts) == 3 {
+		sec, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return 0, err
+		}
+		ret += int64(sec * float64(MicrosPerSecond))
+	}
+	return ret, nil
+}
+
+// Strftime formats the time with the strftime format specifiers
+func Strftime(t time.Time, format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return "", fmt.Errorf("trailing %% in the format '%s'", format)
+		}
+		switch format[i] {
+		case 'a':
+			sb.WriteString(t.Format("Mon"))
+		case 'A':
+			sb.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			sb.WriteString(t.Format("Jan"))
+		case 'B':
+			sb.WriteString(t.Format("January"))
+		case 'd':
+			fmt.Fprintf(&sb, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&sb, "%d", t.Day())
+		case 'f':
+			fmt.Fprintf(&sb, "%06d", t.Nanosecond()/1000)
+		case 'H':
+			fmt.Fprintf(&sb, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&sb, "%02d", (t.Hour()+11)%12+1)
+		case 'j':
+			fmt.Fprintf(&sb, "%03d", t.YearDay())
+		case 'm':
+			fmt.Fprintf(&sb, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(&sb, "%02d", t.Minute())
+		case 'p':
+			sb.WriteString(t.Format("PM"))
+		case 'S':
+			fmt.Fprintf(&sb, "%02d", t.Second())
+		case 'u':
+			fmt.Fprintf(&sb, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&sb, "%d", int(t.Weekday()))
+		case 'y':
+			fmt.Fprintf(&sb, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(&sb, "%d", t.Year())
+		case 'z':
+			sb.WriteString(t.Format("-0700"))
+		case 'Z':
+			sb.WriteString(t.Format("MST"))
+		case '%':
+			sb.WriteByte('%')
+		default:
+			return "", fmt.Errorf("unsupported format specifier %%%c", format[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// strptimeLayouts maps the strptime specifiers into the layout of the time.Parse
+var strptimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'f': "000000",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// Strptime parses the string with the strptime format specifiers
+func Strptime(s string, format string) (time.Time, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return time.Time{}, fmt.Errorf("trailing %% in the format '%s'", format)
+		}
+		layout, ok := strptimeLayouts[format[i]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("unsupported format specifier %%%c", format[i])
+		}
+		sb.WriteString(layout)
+	}
+	t, err := time.Parse(sb.String(), s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse '%s' with the format '%s'", s, format)
+	}
+	return t.UTC(), nil
+}
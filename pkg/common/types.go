@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -57,6 +59,7 @@ const (
 	LTID_AGGREGATE_STATE LTypeId = 105
 	LTID_LAMBDA          LTypeId = 106
 	LTID_UNION           LTypeId = 107
+	LTID_JSON            LTypeId = 108 //stored as the validated VARCHAR
 )
 
 var lTypeIdToStr = map[LTypeId]string{
@@ -102,6 +105,7 @@ var lTypeIdToStr = map[LTypeId]string{
 	LTID_AGGREGATE_STATE: "LTID_AGGREGATE_STATE",
 	LTID_LAMBDA:          "LTID_LAMBDA",
 	LTID_UNION:           "LTID_UNION",
+	LTID_JSON:            "LTID_JSON",
 }
 
 func (id LTypeId) String() string {
@@ -174,18 +178,19 @@ func (pt PhyType) String() string {
 }
 
 var (
-	BoolSize     int
-	Int8Size     int
-	Int16Size    int
-	Int32Size    int
-	Int64Size    int
-	Int128Size   int
-	IntervalSize int
-	DateSize     int
-	VarcharSize  int
-	PointerSize  int
-	DecimalSize  int
-	Float32Size  int
+	BoolSize      int
+	Int8Size      int
+	Int16Size     int
+	Int32Size     int
+	Int64Size     int
+	Int128Size    int
+	IntervalSize  int
+	DateSize      int
+	VarcharSize   int
+	PointerSize   int
+	DecimalSize   int
+	Float32Size   int
+	ListEntrySize int
 )
 
 func init() {
@@ -204,6 +209,13 @@ func init() {
 	DecimalSize = int(unsafe.Sizeof(Decimal{}))
 	f := float32(0)
 	Float32Size = int(unsafe.Sizeof(f))
+	ListEntrySize = int(unsafe.Sizeof(ListEntry{}))
+}
+
+// ListEntry is the physical value of a LIST row: a range in the child vector.
+type ListEntry struct {
+	Offset uint64
+	Length uint64
 }
 
 func (pt PhyType) Size() int {
@@ -238,11 +250,10 @@ func (pt PhyType) Size() int {
 		return VarcharSize
 	case INTERVAL:
 		return IntervalSize
-	case STRUCT:
-	case UNKNOWN:
+	case STRUCT, UNKNOWN:
 		return 0
 	case LIST:
-		panic("usp")
+		return ListEntrySize
 	case DATE:
 		return DateSize
 	case POINTER:
@@ -281,6 +292,67 @@ func (h *Hugeint) Equal(o *Hugeint) bool {
 	return h.Lower == o.Lower && h.Upper == o.Upper
 }
 
+// ParseUUID parses the uuid into the hugeint.
+// The top bit is flipped to keep the order of the uuid.
+func ParseUUID(s string) (Hugeint, error) {
+	ret := Hugeint{}
+	hexStr := strings.ReplaceAll(strings.Trim(strings.TrimSpace(s), "{}"), "-", "")
+	if len(hexStr) != 32 {
+		return ret, fmt.Errorf("invalid uuid '%s'", s)
+	}
+	hi, err := strconv.ParseUint(hexStr[:16], 16, 64)
+	if err != nil {
+		return ret, fmt.Errorf("invalid uuid '%s'", s)
+	}
+	lo, err := strconv.ParseUint(hexStr[16:], 16, 64)
+	if err != nil {
+		return ret, fmt.Errorf("invalid uuid '%s'", s)
+	}
+	ret.Upper = int64(hi ^ (uint64(1) << 63))
+	ret.Lower = lo
+	return ret, nil
+}
+
+func UUIDToString(h *Hugeint) string {
+	hi := uint64(h.Upper) ^ (uint64(1) << 63)
+	hexStr := fmt.Sprintf("%016x%016x", hi, h.Lower)
+	return hexStr[:8] + "-" + hexStr[8:12] + "-" + hexStr[12:16] + "-" + hexStr[16:20] + "-" + hexStr[20:]
+}
+
+// ParseBlob decodes the escaped bytes like 'a\x00b' into the blob
+func ParseBlob(s string) ([]byte, error) {
+	ret := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			ret = append(ret, s[i])
+			continue
+		}
+		if i+3 >= len(s) || (s[i+1] != 'x' && s[i+1] != 'X') {
+			return nil, fmt.Errorf("invalid blob '%s'", s)
+		}
+		b, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blob '%s'", s)
+		}
+		ret = append(ret, byte(b))
+		i += 3
+	}
+	return ret, nil
+}
+
+// BlobToString escapes the non-printable bytes as \xHH
+func BlobToString(data []byte) string {
+	sb := strings.Builder{}
+	for _, b := range data {
+		if b >= 32 && b <= 126 && b != '\\' {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteString(fmt.Sprintf("\\x%02X", b))
+		}
+	}
+	return sb.String()
+}
+
 func NegateHugeint(input *Hugeint, result *Hugeint) {
 	if input.Upper == math.MinInt64 && input.Lower == 0 {
 		panic("-hugeint overflow")
@@ -337,14 +409,14 @@ func (h *Hugeint) Greater(lhs, rhs *Hugeint) bool {
 	panic("usp")
 }
 
+// Interval is the months, days and micros.
+// They are not normalized into each other.
+//
 //lint:ignore U1000
 type Interval struct {
 	Months int32
 	Days   int32
-	Micros int32
-
-	Unit string
-	Year int32
+	Micros int64
 }
 
 func (i *Interval) Equal(o *Interval) bool {
@@ -353,36 +425,22 @@ func (i *Interval) Equal(o *Interval) bool {
 		i.Micros == o.Micros
 }
 
-func (i *Interval) Less(o *Interval) bool {
-	panic("usp")
+// Normalize converts the interval into the days and the micros
+// in [0, MicrosPerDay) with 30 days per month for the comparison.
+func (i *Interval) Normalize() (int64, int64) {
+	days := int64(i.Months)*30 + int64(i.Days) + i.Micros/MicrosPerDay
+	micros := i.Micros % MicrosPerDay
+	if micros < 0 {
+		days--
+		micros += MicrosPerDay
+	}
+	return days, micros
 }
 
-func (i *Interval) Milli() int64 {
-	switch i.Unit {
-	case "year":
-		d := time.Date(
-			int(1970+i.Year),
-			1,
-			1,
-			0, 0, 0, 0, time.UTC)
-		return d.UnixMilli()
-	case "month":
-		d := time.Date(
-			1970,
-			time.Month(1+i.Months),
-			1,
-			0, 0, 0, 0, time.UTC)
-		return d.UnixMilli()
-	case "day":
-		d := time.Date(
-			1970,
-			1,
-			int(1+i.Days),
-			0, 0, 0, 0, time.UTC)
-		return d.UnixMilli()
-	default:
-		panic("usp")
-	}
+func (i *Interval) Less(o *Interval) bool {
+	ld, lm := i.Normalize()
+	rd, rm := o.Normalize()
+	return ld < rd || ld == rd && lm < rm
 }
 
 //lint:ignore U1000
@@ -408,7 +466,7 @@ func (d *Date) ToDate() time.Time {
 
 func (d *Date) AddInterval(rhs *Interval) Date {
 	lhsD := d.ToDate()
-	resD := lhsD.AddDate(int(rhs.Year), int(rhs.Months), int(rhs.Days))
+	resD := addMonthsDays(lhsD, rhs.Months, rhs.Days)
 	y, m, day := resD.Date()
 	return Date{
 		Year:  int32(y),
@@ -419,7 +477,7 @@ func (d *Date) AddInterval(rhs *Interval) Date {
 
 func (d *Date) SubInterval(rhs *Interval) Date {
 	lhsD := d.ToDate()
-	resD := lhsD.AddDate(int(-rhs.Year), int(-rhs.Months), int(-rhs.Days))
+	resD := addMonthsDays(lhsD, -rhs.Months, -rhs.Days)
 	y, m, day := resD.Date()
 	return Date{
 		Year:  int32(y),
@@ -513,6 +571,10 @@ type LType struct {
 	PTyp  PhyType
 	Width int
 	Scale int
+	//child types of LIST, STRUCT and MAP.
+	//MAP is stored as LIST(STRUCT(key, value)).
+	Children   []LType
+	ChildNames []string
 }
 
 func Numeric() []LType {
@@ -543,6 +605,27 @@ func (lt LType) Serialize(serial util.Serialize) error {
 	if err != nil {
 		return err
 	}
+	if !lt.IsNested() {
+		return err
+	}
+	err = util.Write[int](len(lt.Children), serial)
+	if err != nil {
+		return err
+	}
+	for i, child := range lt.Children {
+		name := ""
+		if i < len(lt.ChildNames) {
+			name = lt.ChildNames[i]
+		}
+		err = util.WriteString(name, serial)
+		if err != nil {
+			return err
+		}
+		err = child.Serialize(serial)
+		if err != nil {
+			return err
+		}
+	}
 	return err
 }
 
@@ -569,6 +652,26 @@ func DeserializeLType(deserial util.Deserialize) (LType, error) {
 		Scale: scale,
 	}
 	ret.PTyp = ret.GetInternalType()
+	if !ret.IsNested() {
+		return ret, err
+	}
+	cnt := 0
+	err = util.Read[int](&cnt, deserial)
+	if err != nil {
+		return LType{}, err
+	}
+	for i := 0; i < cnt; i++ {
+		name, err := util.ReadString(deserial)
+		if err != nil {
+			return LType{}, err
+		}
+		child, err := DeserializeLType(deserial)
+		if err != nil {
+			return LType{}, err
+		}
+		ret.ChildNames = append(ret.ChildNames, name)
+		ret.Children = append(ret.Children, child)
+	}
 	return ret, err
 }
 
@@ -620,6 +723,10 @@ func SmallintType() LType {
 	return MakeLType(LTID_SMALLINT)
 }
 
+func AnyType() LType {
+	return MakeLType(LTID_ANY)
+}
+
 func VarcharType() LType {
 	return MakeLType(LTID_VARCHAR)
 }
@@ -629,6 +736,10 @@ func VarcharType2(width int) LType {
 	return ret
 }
 
+func JSONType() LType {
+	return MakeLType(LTID_JSON)
+}
+
 func DateType() LType {
 	return MakeLType(LTID_DATE)
 }
@@ -657,6 +768,62 @@ func UbigintType() LType {
 	return MakeLType(LTID_UBIGINT)
 }
 
+func ListType(child LType) LType {
+	ret := MakeLType(LTID_LIST)
+	ret.Children = []LType{child}
+	ret.ChildNames = []string{""}
+	return ret
+}
+
+func StructType(names []string, typs []LType) LType {
+	util.AssertFunc(len(names) == len(typs))
+	ret := MakeLType(LTID_STRUCT)
+	ret.Children = CopyLTypes(typs...)
+	ret.ChildNames = append([]string{}, names...)
+	return ret
+}
+
+// MapType is LIST(STRUCT(key, value)) physically.
+func MapType(key, value LType) LType {
+	ret := MakeLType(LTID_MAP)
+	ret.Children = []LType{StructType([]string{"key", "value"}, []LType{key, value})}
+	ret.ChildNames = []string{""}
+	return ret
+}
+
+func (lt LType) IsNested() bool {
+	switch lt.Id {
+	case LTID_LIST, LTID_STRUCT, LTID_MAP:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListChild returns the element type of LIST and the entry STRUCT of MAP.
+func (lt LType) ListChild() LType {
+	util.AssertFunc(lt.Id == LTID_LIST || lt.Id == LTID_MAP)
+	return lt.Children[0]
+}
+
+func (lt LType) MapKey() LType {
+	return lt.ListChild().Children[0]
+}
+
+func (lt LType) MapValue() LType {
+	return lt.ListChild().Children[1]
+}
+
+// StructFieldIndex returns the index of the field or -1.
+func (lt LType) StructFieldIndex(name string) int {
+	for i, childName := range lt.ChildNames {
+		if strings.EqualFold(childName, name) {
+			return i
+		}
+	}
+	return -1
+}
+
 func CopyLTypes(typs ...LType) []LType {
 	ret := make([]LType, 0)
 	ret = append(ret, typs...)
@@ -764,6 +931,18 @@ func (lt LType) Equal(o LType) bool {
 	switch lt.Id {
 	case LTID_DECIMAL:
 		return lt.Width == o.Width && lt.Scale == o.Scale
+	case LTID_LIST, LTID_MAP, LTID_STRUCT:
+		if len(lt.Children) != len(o.Children) {
+			return false
+		}
+		for i := range lt.Children {
+			if !lt.Children[i].Equal(o.Children[i]) {
+				return false
+			}
+			if lt.Id == LTID_STRUCT && lt.ChildNames[i] != o.ChildNames[i] {
+				return false
+			}
+		}
 	default:
 
 	}
@@ -814,7 +993,7 @@ func (lt LType) GetInternalType() PhyType {
 
 		//}
 		return DECIMAL
-	case LTID_VARCHAR, LTID_CHAR, LTID_BLOB, LTID_BIT:
+	case LTID_VARCHAR, LTID_CHAR, LTID_BLOB, LTID_BIT, LTID_JSON:
 		return VARCHAR
 	case LTID_INTERVAL:
 		return INTERVAL
@@ -846,6 +1025,20 @@ func (lt LType) String() string {
 	if lt.Id == LTID_DECIMAL {
 		return fmt.Sprintf("%v(%d,%d)", lt.PTyp, lt.Width, lt.Scale)
 	}
+	switch lt.Id {
+	case LTID_JSON:
+		return "JSON"
+	case LTID_LIST:
+		return fmt.Sprintf("LIST(%v)", lt.ListChild())
+	case LTID_MAP:
+		return fmt.Sprintf("MAP(%v, %v)", lt.MapKey(), lt.MapValue())
+	case LTID_STRUCT:
+		fields := make([]string, len(lt.Children))
+		for i, child := range lt.Children {
+			fields[i] = fmt.Sprintf("%s %v", lt.ChildNames[i], child)
+		}
+		return fmt.Sprintf("STRUCT(%s)", strings.Join(fields, ", "))
+	}
 	return fmt.Sprintf("%v", lt.PTyp)
 }
 
@@ -1079,6 +1272,10 @@ func ImplicitCast(from, to LType) int64 {
 		//anything cast to varchar
 		return TargetTypeCost(to)
 	}
+	if from.Id == LTID_VARCHAR && to.Id == LTID_JSON {
+		//the string is validated
+		return TargetTypeCost(to)
+	}
 	switch from.Id {
 	case LTID_TINYINT:
 		return ImplicitCastTinyint(to)
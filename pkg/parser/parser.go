@@ -15,13 +15,648 @@
 package parser
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	pg_query "github.com/pganalyze/pg_query_go/v5"
 )
 
 func Parse(s string) ([]*pg_query.RawStmt, error) {
+	s = rewriteStructLiteral(s)
+	s, err := rewriteMacro(s)
+	if err != nil {
+		return nil, err
+	}
+	s, err = rewriteSample(s)
+	if err != nil {
+		return nil, err
+	}
+	s, err = rewriteNested(s)
+	if err != nil {
+		return nil, err
+	}
+	s, err = rewriteTryCast(s)
+	if err != nil {
+		return nil, err
+	}
 	result, err := pg_query.Parse(s)
 	if err != nil {
 		return nil, err
 	}
 	return result.Stmts, nil
 }
+
+// macroQuote quotes the body of the macro
+const macroQuote = "$macro$"
+
+// rewriteMacro rewrites the macro definition that the postgres grammar does not accept
+// into the CREATE FUNCTION with the quoted body.
+//
+//	CREATE MACRO f(a, b) AS a + b => CREATE FUNCTION f("a", "b") AS $macro$a + b$macro$ LANGUAGE macro
+//	CREATE MACRO t(x) AS TABLE SELECT ... => CREATE FUNCTION t("x") AS $macro$SELECT ...$macro$ LANGUAGE table_macro
+//
+// CREATE FUNCTION f(a) AS expression is the same as the CREATE MACRO.
+func rewriteMacro(s string) (string, error) {
+	lower := strings.ToLower(s)
+	if !strings.Contains(lower, "macro") && !strings.Contains(lower, "function") {
+		return s, nil
+	}
+	scan, err := pg_query.Scan(s)
+	if err != nil {
+		return "", err
+	}
+	tokens := scan.Tokens
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	repls := make([]replacement, 0)
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Token != pg_query.Token_CREATE ||
+			i > 0 && tokens[i-1].Token != pg_query.Token_ASCII_59 {
+			continue
+		}
+		start := int(tokens[i].Start)
+		j := i + 1
+		orReplace := false
+		if j+1 < len(tokens) &&
+			tokens[j].Token == pg_query.Token_OR &&
+			tokens[j+1].Token == pg_query.Token_REPLACE {
+			orReplace = true
+			j += 2
+		}
+		if j >= len(tokens) ||
+			tokens[j].Token != pg_query.Token_FUNCTION &&
+				strings.ToLower(s[tokens[j].Start:tokens[j].End]) != "macro" {
+			continue
+		}
+		j++
+		//the name
+		nameStart := j
+		for j < len(tokens) && tokens[j].Token != pg_query.Token_ASCII_40 {
+			j++
+		}
+		if j == nameStart || j >= len(tokens) {
+			continue
+		}
+		name := s[tokens[nameStart].Start:tokens[j-1].End]
+		//the parameters
+		params := make([]string, 0)
+		//the parameter of the macro is a single name
+		invalidParam := false
+		for j++; j < len(tokens) && tokens[j].Token != pg_query.Token_ASCII_41; j++ {
+			if tokens[j].Token == pg_query.Token_ASCII_44 {
+				continue
+			}
+			if len(params) != 0 && tokens[j-1].Token != pg_query.Token_ASCII_44 {
+				invalidParam = true
+			}
+			param := s[tokens[j].Start:tokens[j].End]
+			if tokens[j].Token != pg_query.Token_IDENT || !strings.HasPrefix(param, `"`) {
+				param = `"` + strings.ToLower(param) + `"`
+			}
+			params = append(params, param)
+		}
+		if j+1 >= len(tokens) || tokens[j+1].Token != pg_query.Token_AS {
+			continue
+		}
+		j += 2
+		if j >= len(tokens) || tokens[j].Token == pg_query.Token_SCONST {
+			//the postgres function
+			continue
+		}
+		if invalidParam {
+			return "", fmt.Errorf("invalid parameter of the macro %s", name)
+		}
+		lang := "macro"
+		if tokens[j].Token == pg_query.Token_TABLE {
+			lang = "table_macro"
+			j++
+		}
+		if j >= len(tokens) {
+			return "", fmt.Errorf("no body of the macro %s", name)
+		}
+		//the body ends at the ';'
+		bodyStart := int(tokens[j].Start)
+		end := len(s)
+		for ; j < len(tokens); j++ {
+			if tokens[j].Token == pg_query.Token_ASCII_59 {
+				end = int(tokens[j].Start)
+				break
+			}
+		}
+		body := strings.TrimSpace(s[bodyStart:end])
+		if strings.Contains(body, macroQuote) {
+			return "", fmt.Errorf("the body of the macro %s can not contain %s", name, macroQuote)
+		}
+		text := "CREATE "
+		if orReplace {
+			text += "OR REPLACE "
+		}
+		text += fmt.Sprintf("FUNCTION %s(%s) AS %s%s%s LANGUAGE %s",
+			name, strings.Join(params, ", "), macroQuote, body, macroQuote, lang)
+		repls = append(repls, replacement{start, end, text})
+		i = j
+	}
+	for i := len(repls) - 1; i >= 0; i-- {
+		repl := repls[i]
+		s = s[:repl.start] + repl.text + s[repl.end:]
+	}
+	return s, nil
+}
+
+// rewriteSample rewrites the USING SAMPLE clause into the TABLESAMPLE
+// on the single table in the FROM. The sample is taken before the WHERE.
+//
+//	FROM t WHERE a > 1 USING SAMPLE 10% => FROM t TABLESAMPLE system(10) WHERE a > 1
+//	USING SAMPLE 100 ROWS => TABLESAMPLE reservoir(100)
+//	USING SAMPLE 10 PERCENT (bernoulli, 42) => TABLESAMPLE bernoulli(10) REPEATABLE(42)
+//	USING SAMPLE bernoulli(10%) REPEATABLE (42) => TABLESAMPLE bernoulli(10) REPEATABLE(42)
+func rewriteSample(s string) (string, error) {
+	if !strings.Contains(strings.ToLower(s), "sample") {
+		return s, nil
+	}
+	scan, err := pg_query.Scan(s)
+	if err != nil {
+		return "", err
+	}
+	tokens := scan.Tokens
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	repls := make([]replacement, 0)
+	text := func(tok *pg_query.ScanToken) string {
+		return s[tok.Start:tok.End]
+	}
+	isNumber := func(j int) bool {
+		return j < len(tokens) &&
+			(tokens[j].Token == pg_query.Token_ICONST || tokens[j].Token == pg_query.Token_FCONST)
+	}
+	isToken := func(j int, tok pg_query.Token) bool {
+		return j < len(tokens) && tokens[j].Token == tok
+	}
+	isMethod := func(j int) bool {
+		return j+1 < len(tokens) &&
+			(tokens[j].Token == pg_query.Token_IDENT || isNonReserved(tokens[j])) &&
+			tokens[j+1].Token == pg_query.Token_ASCII_40
+	}
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].Token != pg_query.Token_USING ||
+			strings.ToLower(text(tokens[i+1])) != "sample" ||
+			!isNumber(i+2) && !isMethod(i+2) {
+			continue
+		}
+		//the size and the unit. the unit is '%', rows or empty.
+		size, unit, method, seed := "", "", "", ""
+		j := i + 2
+		readSize := func() bool {
+			if !isNumber(j) {
+				return false
+			}
+			size = text(tokens[j])
+			j++
+			switch {
+			case isToken(j, pg_query.Token_ASCII_37),
+				j < len(tokens) && strings.ToLower(text(tokens[j])) == "percent":
+				unit = "%"
+				j++
+			case isToken(j, pg_query.Token_ROWS):
+				unit = "rows"
+				j++
+			}
+			return true
+		}
+		invalid := fmt.Errorf("invalid USING SAMPLE at %d", tokens[i].Start)
+		if isMethod(j) {
+			//method(size)
+			method = strings.ToLower(text(tokens[j]))
+			j += 2
+			if !readSize() || !isToken(j, pg_query.Token_ASCII_41) {
+				return "", invalid
+			}
+			j++
+		} else {
+			//size (method[, seed])
+			readSize()
+			if isToken(j, pg_query.Token_ASCII_40) {
+				if j+1 >= len(tokens) {
+					return "", invalid
+				}
+				method = strings.ToLower(text(tokens[j+1]))
+				j += 2
+				if isToken(j, pg_query.Token_ASCII_44) {
+					if !isNumber(j + 1) {
+						return "", invalid
+					}
+					seed = text(tokens[j+1])
+					j += 2
+				}
+				if !isToken(j, pg_query.Token_ASCII_41) {
+					return "", invalid
+				}
+				j++
+			}
+		}
+		if isToken(j, pg_query.Token_REPEATABLE) {
+			if !isToken(j+1, pg_query.Token_ASCII_40) || !isNumber(j+2) || !isToken(j+3, pg_query.Token_ASCII_41) {
+				return "", invalid
+			}
+			seed = text(tokens[j+2])
+			j += 4
+		}
+		if method == "" {
+			method = "reservoir"
+			if unit == "%" {
+				method = "system"
+			}
+		}
+		switch {
+		case method == "reservoir" && unit == "%":
+			return "", fmt.Errorf("usp reservoir sample in percentage")
+		case method != "reservoir" && unit == "rows":
+			return "", fmt.Errorf("sample method %s requires the percentage", method)
+		}
+
+		//the FROM of the same query
+		from := -1
+		depth := 0
+	backward:
+		for k := i - 1; k >= 0; k-- {
+			switch tokens[k].Token {
+			case pg_query.Token_ASCII_41:
+				depth++
+			case pg_query.Token_ASCII_40:
+				if depth == 0 {
+					break backward
+				}
+				depth--
+			case pg_query.Token_SELECT:
+				if depth == 0 {
+					break backward
+				}
+			case pg_query.Token_FROM:
+				if depth == 0 {
+					from = k
+					break backward
+				}
+			}
+		}
+		if from < 0 {
+			return "", fmt.Errorf("USING SAMPLE requires the FROM")
+		}
+		//the single table in the FROM
+		end := from + 1
+	forward:
+		for ; end < i; end++ {
+			switch tokens[end].Token {
+			case pg_query.Token_WHERE, pg_query.Token_GROUP_P, pg_query.Token_HAVING, pg_query.Token_WINDOW:
+				break forward
+			case pg_query.Token_ASCII_40, pg_query.Token_ASCII_44, pg_query.Token_JOIN, pg_query.Token_TABLESAMPLE:
+				return "", fmt.Errorf("usp USING SAMPLE on the FROM other than the single table")
+			}
+		}
+		if end == from+1 {
+			return "", fmt.Errorf("USING SAMPLE requires the FROM")
+		}
+		sample := fmt.Sprintf(" TABLESAMPLE %s(%s)", method, size)
+		if seed != "" {
+			sample += fmt.Sprintf(" REPEATABLE(%s)", seed)
+		}
+		//the insertion is after the removal if the table is just before the USING
+		repls = append(repls,
+			replacement{int(tokens[end-1].End), int(tokens[end-1].End), sample},
+			replacement{int(tokens[i-1].End), int(tokens[j-1].End), ""},
+		)
+		i = j - 1
+	}
+	sort.SliceStable(repls, func(i, j int) bool {
+		return repls[i].start < repls[j].start
+	})
+	for i := len(repls) - 1; i >= 0; i-- {
+		repl := repls[i]
+		s = s[:repl.start] + repl.text + s[repl.end:]
+	}
+	return s, nil
+}
+
+// rewriteTryCast rewrites the TRY_CAST(x AS t) into try_cast(CAST(x AS t))
+// that the postgres grammar accepts.
+func rewriteTryCast(s string) (string, error) {
+	if !strings.Contains(strings.ToLower(s), "try_cast") {
+		return s, nil
+	}
+	scan, err := pg_query.Scan(s)
+	if err != nil {
+		return "", err
+	}
+	type insertion struct {
+		pos  int
+		text string
+	}
+	inserts := make([]insertion, 0)
+	tokens := scan.Tokens
+	for i := 0; i+1 < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Token != pg_query.Token_IDENT ||
+			strings.ToLower(s[tok.Start:tok.End]) != "try_cast" ||
+			tokens[i+1].Token != pg_query.Token_ASCII_40 {
+			continue
+		}
+		//find the matched ')'
+		depth := 0
+		for j := i + 1; j < len(tokens); j++ {
+			switch tokens[j].Token {
+			case pg_query.Token_ASCII_40:
+				depth++
+			case pg_query.Token_ASCII_41:
+				depth--
+			}
+			if depth == 0 {
+				inserts = append(inserts,
+					insertion{pos: int(tokens[i+1].End), text: "CAST("},
+					insertion{pos: int(tokens[j].Start), text: ")"},
+				)
+				break
+			}
+		}
+	}
+	sort.SliceStable(inserts, func(i, j int) bool {
+		return inserts[i].pos > inserts[j].pos
+	})
+	for _, ins := range inserts {
+		s = s[:ins.pos] + ins.text + s[ins.pos:]
+	}
+	return s, nil
+}
+
+// rewriteStructLiteral rewrites the struct literal {'a': 1, 'b': x}
+// into struct_pack("a" := 1, "b" := x).
+// The postgres scanner does not accept the braces.
+func rewriteStructLiteral(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	out := strings.Builder{}
+	//depth of the parentheses and brackets at the open brace
+	braces := make([]int, 0)
+	depth := 0
+	expectKey := false
+	i := 0
+	//tryKey writes the key if the text[i:j] is followed by ':'
+	tryKey := func(key string, j int) bool {
+		k := j
+		for k < len(s) && isSpace(s[k]) {
+			k++
+		}
+		if k >= len(s) || s[k] != ':' ||
+			(k+1 < len(s) && (s[k+1] == ':' || s[k+1] == '=')) {
+			return false
+		}
+		out.WriteString(`"` + strings.ReplaceAll(key, `"`, `""`) + `" :=`)
+		i = k + 1
+		return true
+	}
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := skipQuoted(s, i)
+			if expectKey {
+				expectKey = false
+				quoted := s[i+1 : j-1]
+				quoted = strings.ReplaceAll(quoted, string([]byte{c, c}), string(c))
+				if tryKey(quoted, j) {
+					continue
+				}
+			}
+			out.WriteString(s[i:j])
+			i = j
+			continue
+		case c == '-' && i+1 < len(s) && s[i+1] == '-':
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				j = len(s) - i
+			}
+			out.WriteString(s[i : i+j])
+			i += j
+			continue
+		case isIdentStart(c) && expectKey:
+			expectKey = false
+			j := i
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			if tryKey(s[i:j], j) {
+				continue
+			}
+			out.WriteString(s[i:j])
+			i = j
+			continue
+		case c == '{':
+			out.WriteString("struct_pack(")
+			braces = append(braces, depth)
+			expectKey = true
+			i++
+			continue
+		case c == '}' && len(braces) > 0:
+			out.WriteString(")")
+			braces = braces[:len(braces)-1]
+			expectKey = false
+			i++
+			continue
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',':
+			if len(braces) > 0 && braces[len(braces)-1] == depth {
+				out.WriteByte(c)
+				expectKey = true
+				i++
+				continue
+			}
+		}
+		if !isSpace(c) {
+			expectKey = false
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// skipQuoted returns the position after the quoted text starting at i.
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	j := i + 1
+	for j < len(s) {
+		if s[j] == quote {
+			if j+1 < len(s) && s[j+1] == quote {
+				j += 2
+				continue
+			}
+			return j + 1
+		}
+		j++
+	}
+	return len(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// rewriteNested rewrites the nested syntax the postgres grammar does not accept.
+//
+//	[1, 2] => ARRAY[1, 2]
+//	[1, 2][1] => (ARRAY[1, 2])[1]
+//	f(x).a => (f(x)).a
+//	STRUCT(a INT, b VARCHAR) => "STRUCT(a INT, b VARCHAR)" in the type name
+//	MAP(VARCHAR, INT) => "MAP(VARCHAR, INT)" in the type name
+func rewriteNested(s string) (string, error) {
+	if !strings.Contains(s, "[") &&
+		!strings.Contains(strings.ToLower(s), "struct") &&
+		!strings.Contains(strings.ToLower(s), "map") {
+		return s, nil
+	}
+	scan, err := pg_query.Scan(s)
+	if err != nil {
+		return "", err
+	}
+	type replacement struct {
+		start, end int
+		//order of the insertions at the same position
+		order int
+		text  string
+	}
+	//the operand begins with the open parenthesis or bracket
+	type operand struct {
+		start int
+		//function call or list literal
+		wrap bool
+	}
+	repls := make([]replacement, 0)
+	opens := make([]operand, 0)
+	tokens := scan.Tokens
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		var prev *pg_query.ScanToken
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+		switch tok.Token {
+		case pg_query.Token_ASCII_91:
+			switch {
+			case prev != nil && prev.Token == pg_query.Token_ARRAY:
+				opens = append(opens, operand{int(prev.Start), true})
+			case prev == nil || !endsOperand(prev):
+				repls = append(repls, replacement{int(tok.Start), int(tok.Start), 1, "ARRAY"})
+				opens = append(opens, operand{int(tok.Start), true})
+			default:
+				opens = append(opens, operand{int(tok.Start), false})
+			}
+		case pg_query.Token_ASCII_40:
+			if prev != nil && (prev.Token == pg_query.Token_IDENT || isNonReserved(prev)) {
+				opens = append(opens, operand{int(prev.Start), true})
+			} else {
+				opens = append(opens, operand{int(tok.Start), false})
+			}
+		case pg_query.Token_ASCII_41, pg_query.Token_ASCII_93:
+			if len(opens) == 0 {
+				continue
+			}
+			open := opens[len(opens)-1]
+			opens = opens[:len(opens)-1]
+			if open.wrap && i+1 < len(tokens) &&
+				(tokens[i+1].Token == pg_query.Token_ASCII_91 ||
+					tokens[i+1].Token == pg_query.Token_ASCII_46) {
+				repls = append(repls,
+					replacement{open.start, open.start, 0, "("},
+					replacement{int(tok.End), int(tok.End), -1, ")"})
+			}
+		case pg_query.Token_IDENT:
+			name := strings.ToLower(s[tok.Start:tok.End])
+			if (name != "struct" && name != "map") ||
+				i+1 >= len(tokens) ||
+				tokens[i+1].Token != pg_query.Token_ASCII_40 ||
+				prev == nil || !beforeTypeName(prev) {
+				continue
+			}
+			depth := 0
+			for j := i + 1; j < len(tokens); j++ {
+				switch tokens[j].Token {
+				case pg_query.Token_ASCII_40:
+					depth++
+				case pg_query.Token_ASCII_41:
+					depth--
+				}
+				if depth == 0 {
+					text := s[tok.Start:tokens[j].End]
+					repls = append(repls, replacement{
+						int(tok.Start),
+						int(tokens[j].End),
+						1,
+						`"` + strings.ReplaceAll(text, `"`, `""`) + `"`,
+					})
+					//skip the type name
+					i = j
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(repls, func(i, j int) bool {
+		if repls[i].start != repls[j].start {
+			return repls[i].start < repls[j].start
+		}
+		return repls[i].order < repls[j].order
+	})
+	for i := len(repls) - 1; i >= 0; i-- {
+		repl := repls[i]
+		s = s[:repl.start] + repl.text + s[repl.end:]
+	}
+	return s, nil
+}
+
+func isNonReserved(tok *pg_query.ScanToken) bool {
+	return tok.KeywordKind != pg_query.KeywordKind_NO_KEYWORD &&
+		tok.KeywordKind != pg_query.KeywordKind_RESERVED_KEYWORD
+}
+
+// endsOperand decides the '[' after the token is a subscript.
+func endsOperand(tok *pg_query.ScanToken) bool {
+	switch tok.Token {
+	case pg_query.Token_IDENT,
+		pg_query.Token_ASCII_41,
+		pg_query.Token_ASCII_93,
+		pg_query.Token_SCONST,
+		pg_query.Token_ICONST,
+		pg_query.Token_FCONST,
+		pg_query.Token_PARAM,
+		pg_query.Token_ARRAY:
+		return true
+	}
+	return isNonReserved(tok)
+}
+
+// beforeTypeName decides the token is followed by a type name.
+// column name in the column definition, AS in the CAST and ::
+func beforeTypeName(tok *pg_query.ScanToken) bool {
+	switch tok.Token {
+	case pg_query.Token_IDENT,
+		pg_query.Token_AS,
+		pg_query.Token_TYPECAST:
+		return true
+	}
+	return tok.KeywordKind == pg_query.KeywordKind_UNRESERVED_KEYWORD
+}
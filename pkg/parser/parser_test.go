@@ -69,3 +69,81 @@ func TestSchema(t *testing.T) {
 		require.Equal(t, 1, len(stmts))
 	}
 }
+
+func TestTryCast(t *testing.T) {
+	sql, err := rewriteTryCast("select TRY_CAST(a AS int), try_cast(try_cast(b as int) as varchar) from t")
+	require.NoError(t, err)
+	assert.Equal(t, "select TRY_CAST(CAST(a AS int)), try_cast(CAST(try_cast(CAST(b as int)) as varchar)) from t", sql)
+
+	stmts, err := Parse("select try_cast('x' as int)")
+	require.NoError(t, err)
+	fcall := stmts[0].Stmt.GetSelectStmt().GetTargetList()[0].GetResTarget().GetVal().GetFuncCall()
+	require.NotNil(t, fcall)
+	require.NotNil(t, fcall.Args[0].GetTypeCast())
+}
+
+func TestNestedLiteral(t *testing.T) {
+	sql, err := rewriteNested(rewriteStructLiteral("select {'a': 1, 'b': 'x'}.a"))
+	require.NoError(t, err)
+	assert.Equal(t, `select (struct_pack("a" := 1, "b" := 'x')).a`, sql)
+
+	sql, err = rewriteNested("select [1,2][1], x[2], [[1],[]], cast(a as struct(a int)) from t")
+	require.NoError(t, err)
+	assert.Equal(t, `select (ARRAY[1,2])[1], x[2], ARRAY[ARRAY[1],ARRAY[]], cast(a as "struct(a int)") from t`, sql)
+
+	_, err = Parse("select [1, 2], {'k': [3]} from t")
+	require.NoError(t, err)
+}
+
+func TestMacro(t *testing.T) {
+	sql, err := rewriteMacro("create macro f(a, B) as a * b + 1; create macro s.t(x) AS TABLE select * from t1 where a > x")
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE FUNCTION f("a", "b") AS $macro$a * b + 1$macro$ LANGUAGE macro; `+
+		`CREATE FUNCTION s.t("x") AS $macro$select * from t1 where a > x$macro$ LANGUAGE table_macro`, sql)
+
+	//the postgres function is kept
+	sql, err = rewriteMacro("create function f(a int) as 'select 1' language sql")
+	require.NoError(t, err)
+	assert.Equal(t, "create function f(a int) as 'select 1' language sql", sql)
+
+	_, err = rewriteMacro("create macro f(a int) as a")
+	assert.Error(t, err)
+
+	stmts, err := Parse("create macro f(a) as a + 1")
+	require.NoError(t, err)
+	fstmt := stmts[0].Stmt.GetCreateFunctionStmt()
+	require.NotNil(t, fstmt)
+	assert.Equal(t, 1, len(fstmt.GetParameters()))
+}
+
+func TestSample(t *testing.T) {
+	sql, err := rewriteSample("select * from t x where a > 1 using sample 10% order by a")
+	require.NoError(t, err)
+	assert.Equal(t, "select * from t x TABLESAMPLE system(10) where a > 1 order by a", sql)
+
+	_, err = rewriteSample("select * from (select a from t using sample 5 rows repeatable (7)) s, t using sample 10 percent (bernoulli, 42)")
+	assert.Error(t, err)
+	sql, err = rewriteSample("select * from (select a from t using sample 5 rows repeatable (7)) s")
+	require.NoError(t, err)
+	assert.Equal(t, "select * from (select a from t TABLESAMPLE reservoir(5) REPEATABLE(7)) s", sql)
+
+	sql, err = rewriteSample("select * from t using sample bernoulli(2.5%) repeatable(1); select * from t using sample 10 percent (bernoulli, 42)")
+	require.NoError(t, err)
+	assert.Equal(t, "select * from t TABLESAMPLE bernoulli(2.5) REPEATABLE(1); select * from t TABLESAMPLE bernoulli(10) REPEATABLE(42)", sql)
+
+	_, err = rewriteSample("select * from t1, t2 using sample 10%")
+	assert.Error(t, err)
+	_, err = rewriteSample("select * from t using sample system(10 rows)")
+	assert.Error(t, err)
+
+	//the join condition is kept
+	sql, err = rewriteSample("select * from t1 join sample using (a)")
+	require.NoError(t, err)
+	assert.Equal(t, "select * from t1 join sample using (a)", sql)
+
+	stmts, err := Parse("select * from t tablesample bernoulli(10) repeatable(3)")
+	require.NoError(t, err)
+	sample := stmts[0].Stmt.GetSelectStmt().GetFromClause()[0].GetRangeTableSample()
+	require.NotNil(t, sample)
+	assert.NotNil(t, sample.GetRepeatable())
+}
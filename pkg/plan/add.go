@@ -15,6 +15,7 @@
 package plan
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/daviszhen/plan/pkg/common"
@@ -91,7 +92,7 @@ func addInt8CheckOf(left, right, result *int8) {
 	ures := int16(0)
 	addInt16(&ul, &ur, &ures)
 	if ures > math.MaxInt8 || ures < math.MinInt8 {
-		panic("int8 + int8 overflow")
+		panic(fmt.Errorf("int8 + int8 overflow"))
 	}
 	*result = int8(ures)
 }
@@ -102,7 +103,7 @@ func addInt16CheckOf(left, right, result *int16) {
 	ures := int32(0)
 	addInt32(&ul, &ur, &ures)
 	if ures > math.MaxInt16 || ures < math.MinInt16 {
-		panic("int16 + int16 overflow")
+		panic(fmt.Errorf("int16 + int16 overflow"))
 	}
 	*result = int16(ures)
 }
@@ -113,30 +114,27 @@ func addInt32CheckOf(left, right, result *int32) {
 	ures := int64(0)
 	addInt64(&ul, &ur, &ures)
 	if ures > math.MaxInt32 || ures < math.MinInt32 {
-		panic("int32 + int32 overflow")
+		panic(fmt.Errorf("int32 + int32 overflow"))
 	}
 	*result = int32(ures)
 }
 
 func addInt64CheckOf(left, right, result *int64) {
-	ul := uint64(*left)
-	ur := uint64(*right)
-	ures := int64(ul - ur)
+	ures := *left + *right
 	if (*left < 0 && *right < 0 && ures >= 0) ||
 		(*left >= 0 && *right >= 0 && ures < 0) {
-		*result = ures
-	} else {
-		panic("int64 + int64 overflow")
+		panic(fmt.Errorf("int64 + int64 overflow"))
 	}
+	*result = ures
 }
 
 func addUint8CheckOf(left, right, result *uint8) {
 	ul := uint16(*left)
 	ur := uint16(*right)
-	ures := uint16(*result)
+	ures := uint16(0)
 	addUint16(&ul, &ur, &ures)
 	if ures > math.MaxUint8 {
-		panic("uint8 + uint8 overflow")
+		panic(fmt.Errorf("uint8 + uint8 overflow"))
 	}
 	*result = uint8(ures)
 }
@@ -147,7 +145,7 @@ func addUint16CheckOf(left, right, result *uint16) {
 	ures := uint32(0)
 	addUint32(&ul, &ur, &ures)
 	if ures > math.MaxUint16 {
-		panic("uint16 + uint16 overflow")
+		panic(fmt.Errorf("uint16 + uint16 overflow"))
 	}
 	*result = uint16(ures)
 }
@@ -158,14 +156,14 @@ func addUint32CheckOf(left, right, result *uint32) {
 	ures := uint64(0)
 	addUint64(&ul, &ur, &ures)
 	if ures > math.MaxUint32 {
-		panic("uint16 + uint16 overflow")
+		panic(fmt.Errorf("uint32 + uint32 overflow"))
 	}
 	*result = uint32(ures)
 }
 
 func addUint64CheckOf(left, right, result *uint64) {
 	if math.MaxUint64-*left < *right {
-		panic("uint64 + uint64 overflow")
+		panic(fmt.Errorf("uint64 + uint64 overflow"))
 	}
 	*result = *left + *right
 }
@@ -239,6 +239,10 @@ func GetMaxAggr(retPhyTyp common.PhyType, inputPhyTyp common.PhyType) *FunctionV
 		default:
 			panic("usp")
 		}
+	case common.INT64:
+		return minMaxAggr[int64](common.BigintType(), &MaxStateOp[int64]{}, Int64(0))
+	case common.INTERVAL:
+		return minMaxAggr[common.Interval](common.IntervalType(), &MaxStateOp[common.Interval]{}, IntervalOp{})
 	default:
 		panic("usp")
 	}
@@ -262,11 +266,29 @@ func GetMinAggr(retPhyTyp common.PhyType, inputPhyTyp common.PhyType) *FunctionV
 		default:
 			panic("usp")
 		}
+	case common.INT64:
+		return minMaxAggr[int64](common.BigintType(), &MinStateOp[int64]{}, Int64(0))
+	case common.INTERVAL:
+		return minMaxAggr[common.Interval](common.IntervalType(), &MinStateOp[common.Interval]{}, IntervalOp{})
 	default:
 		panic("usp")
 	}
 }
 
+// minMaxAggr is the min/max on the type that
+// has no specific implementation.
+func minMaxAggr[T any](typ common.LType, sop StateOp[T], top TypeOp[T]) *FunctionV2 {
+	return UnaryAggregate[T, State[T], T, MinMaxOp[T, T]](
+		typ,
+		typ,
+		DefaultNullHandling,
+		MinMaxOp[T, T]{},
+		sop,
+		&MinMaxAssign[T]{},
+		top,
+	)
+}
+
 type StateType int
 
 const (
@@ -374,7 +396,7 @@ func (SumStateOp[T]) Combine(
 	target *State[T],
 	_ *AggrInputData,
 	top TypeOp[T]) {
-	src.Combine(target, top)
+	target.Combine(src, top)
 }
 func (SumStateOp[T]) AddValues(s *State[T], _ int) {
 
@@ -394,7 +416,7 @@ func (as *AvgStateOp[T]) Combine(
 	target *State[T],
 	_ *AggrInputData,
 	top TypeOp[T]) {
-	src.Combine(target, top)
+	target.Combine(src, top)
 }
 
 func (as *AvgStateOp[T]) AddValues(s *State[T], cnt int) {
@@ -414,7 +436,7 @@ func (as *CountStateOp[T]) Combine(
 	target *State[T],
 	_ *AggrInputData,
 	top TypeOp[T]) {
-	src.Combine(target, top)
+	target.Combine(src, top)
 }
 
 func (as *CountStateOp[T]) AddValues(s *State[T], cnt int) {
@@ -624,6 +646,67 @@ func (Double) Greater(lhs, rhs *float64) bool {
 	return *lhs > *rhs
 }
 
+type Int64 int64
+
+func (Int64) Add(lhs, rhs *int64) {
+	*lhs = (*lhs) + (*rhs)
+}
+func (Int64) Mul(lhs, rhs *int64) {
+	*lhs = (*lhs) * (*rhs)
+}
+
+func (Int64) Less(lhs, rhs *int64) bool {
+	return *lhs < *rhs
+}
+func (Int64) Greater(lhs, rhs *int64) bool {
+	return *lhs > *rhs
+}
+
+type IntervalOp struct{}
+
+func (IntervalOp) Add(lhs, rhs *common.Interval) {
+	lhs.Months += rhs.Months
+	lhs.Days += rhs.Days
+	lhs.Micros += rhs.Micros
+}
+func (IntervalOp) Mul(*common.Interval, *common.Interval) {
+	panic("usp")
+}
+
+func (IntervalOp) Less(lhs, rhs *common.Interval) bool {
+	return lhs.Less(rhs)
+}
+func (IntervalOp) Greater(lhs, rhs *common.Interval) bool {
+	return rhs.Less(lhs)
+}
+
+// MinMaxAssign keeps the min/max value in the state
+type MinMaxAssign[T any] struct{}
+
+func (*MinMaxAssign[T]) AddNumber(*State[T], *T, TypeOp[T]) {
+	panic("usp")
+}
+func (*MinMaxAssign[T]) AddConstant(*State[T], *T, int, TypeOp[T]) {
+	panic("usp")
+}
+
+func (*MinMaxAssign[T]) Assign(s *State[T], input *T) {
+	s._value = *input
+}
+func (*MinMaxAssign[T]) Execute(s *State[T], input *T, top TypeOp[T]) {
+	if s._typ == STATE_MAX {
+		if top.Greater(input, &s._value) {
+			s._value = *input
+		}
+	} else if s._typ == STATE_MIN {
+		if top.Less(input, &s._value) {
+			s._value = *input
+		}
+	} else {
+		panic("usp")
+	}
+}
+
 //func (*DoubleAdd)AddNumber(*State[ResultT], *InputT, TypeOp[ResultT]){}
 //AddConstant(*State[ResultT], *InputT, int, TypeOp[ResultT])
 
@@ -811,14 +894,12 @@ func (CountOp[ResultT, InputT]) Finalize(
 	s3 *State[ResultT],
 	target *ResultT,
 	data *AggrFinalizeData) {
-	if s3._count == 0 {
-		data.ReturnNull()
-	} else {
-		ret := common.Hugeint{
-			Lower: s3._count,
-		}
-		*target = any(ret).(ResultT)
+	//count never returns NULL. the group whose rows are all NULL
+	//or filtered out by the FILTER clause counts 0.
+	ret := common.Hugeint{
+		Lower: s3._count,
 	}
+	*target = any(ret).(ResultT)
 }
 
 func (CountOp[ResultT, InputT]) IgnoreNull() bool {
@@ -1214,8 +1295,11 @@ func FinalizeStates(
 			//cast
 			src := target.Typ()
 			dst := result.Data[targetOffset].Typ()
-			castInfo := castFuncs.GetCastFunc(src, dst)
-			castParams := &CastParams{}
+			castInfo, err := castFuncs.GetCastFunc(src, dst)
+			if err != nil {
+				panic(err)
+			}
+			castParams := &CastParams{_strict: true}
 			castInfo._fun(target, result.Data[targetOffset], result.Card(), castParams)
 		}
 
@@ -1223,3 +1307,37 @@ func FinalizeStates(
 		AddInPlace(addresses, int64(aggr._payloadSize), result.Card())
 	}
 }
+
+// DestroyStates calls the destructors of the aggregates on the states
+// that are not finalized.
+// CombineStates merges the aggregate states in the sources into the targets
+func CombineStates(
+	layout *TupleDataLayout,
+	sources *chunk.Vector,
+	targets *chunk.Vector,
+	count int,
+) {
+	AddInPlace(sources, int64(layout.aggrOffset()), count)
+	AddInPlace(targets, int64(layout.aggrOffset()), count)
+	for _, aggr := range layout._aggregates {
+		aggr._func._combine(sources, targets, NewAggrInputData(), count)
+		//next aggr state
+		AddInPlace(sources, int64(aggr._payloadSize), count)
+		AddInPlace(targets, int64(aggr._payloadSize), count)
+	}
+}
+
+func DestroyStates(
+	layout *TupleDataLayout,
+	addresses *chunk.Vector,
+	count int,
+) {
+	AddInPlace(addresses, int64(layout.aggrOffset()), count)
+	for _, aggr := range layout._aggregates {
+		if aggr._func._destructor != nil {
+			aggr._func._destructor(addresses, count)
+		}
+		//next aggr state
+		AddInPlace(addresses, int64(aggr._payloadSize), count)
+	}
+}
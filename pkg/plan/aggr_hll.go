@@ -0,0 +1,215 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"encoding/base64"
+	"fmt"
+	"runtime/cgo"
+	"unsafe"
+
+	hll "github.com/axiomhq/hyperloglog"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+type HllKind int
+
+const (
+	//approx_count_distinct
+	HLL_COUNT HllKind = iota
+	//hll_sketch
+	HLL_SKETCH
+	//hll_merge
+	HLL_MERGE
+)
+
+// encodeSketch serializes the sketch into the text
+func encodeSketch(sketch *hll.Sketch) string {
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func decodeSketch(s string) (*hll.Sketch, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hll sketch: %v", err)
+	}
+	sketch := hll.New14()
+	err = sketch.UnmarshalBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hll sketch: %v", err)
+	}
+	return sketch, nil
+}
+
+// hllHashable decides the type can be hashed into the sketch
+func hllHashable(typ common.LType) bool {
+	switch typ.GetInternalType() {
+	case common.BOOL, common.INT8, common.INT16, common.INT32, common.INT64,
+		common.FLOAT, common.DOUBLE, common.VARCHAR, common.DECIMAL,
+		common.INT128, common.DATE, common.INTERVAL:
+		return true
+	default:
+		return false
+	}
+}
+
+// HllAggregate creates the aggregate on the HyperLogLog sketch.
+// The state is the cgo handle of the sketch like the HolisticAggregate.
+func HllAggregate(
+	args []common.LType,
+	retTyp common.LType,
+	kind HllKind,
+) *FunctionV2 {
+	var size aggrStateSize
+	var init aggrInit
+	var update aggrUpdate
+	var combine aggrCombine
+	var finalize aggrFinalize
+	var destructor aggrDestructor
+	size = func() int {
+		var handle cgo.Handle
+		return int(unsafe.Sizeof(handle))
+	}
+	init = func(pointer unsafe.Pointer) {
+		*(*cgo.Handle)(pointer) = 0
+	}
+	getSketch := func(pointer unsafe.Pointer) *hll.Sketch {
+		handle := (*cgo.Handle)(pointer)
+		if *handle == 0 {
+			*handle = cgo.NewHandle(hll.New14())
+		}
+		return handle.Value().(*hll.Sketch)
+	}
+	update = func(inputs []*chunk.Vector, data *AggrInputData, inputCount int, states *chunk.Vector, count int) {
+		util.AssertFunc(inputCount == 1)
+		var sdata, idata chunk.UnifiedFormat
+		states.ToUnifiedFormat(count, &sdata)
+		statesPtrSlice := chunk.GetSliceInPhyFormatUnifiedFormat[unsafe.Pointer](&sdata)
+		inputs[0].ToUnifiedFormat(count, &idata)
+
+		if kind == HLL_MERGE {
+			strSlice := chunk.GetSliceInPhyFormatUnifiedFormat[common.String](&idata)
+			for i := 0; i < count; i++ {
+				idx := idata.Sel.GetIndex(i)
+				if !idata.Mask.RowIsValid(uint64(idx)) {
+					continue
+				}
+				other, err := decodeSketch(strSlice[idx].String())
+				if err != nil {
+					panic(err)
+				}
+				sketch := getSketch(statesPtrSlice[sdata.Sel.GetIndex(i)])
+				err = sketch.Merge(other)
+				if err != nil {
+					panic(err)
+				}
+			}
+			return
+		}
+
+		hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+		chunk.HashTypeSwitch(inputs[0], hashes, nil, count, false)
+		var hdata chunk.UnifiedFormat
+		hashes.ToUnifiedFormat(count, &hdata)
+		hashSlice := chunk.GetSliceInPhyFormatUnifiedFormat[uint64](&hdata)
+		for i := 0; i < count; i++ {
+			idx := idata.Sel.GetIndex(i)
+			if !idata.Mask.RowIsValid(uint64(idx)) {
+				continue
+			}
+			sketch := getSketch(statesPtrSlice[sdata.Sel.GetIndex(i)])
+			sketch.InsertHash(hashSlice[hdata.Sel.GetIndex(i)])
+		}
+	}
+	combine = func(source *chunk.Vector, target *chunk.Vector, data *AggrInputData, count int) {
+		sourcePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](source)
+		targetPtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](target)
+		for i := 0; i < count; i++ {
+			src := (*cgo.Handle)(sourcePtrSlice[i])
+			if *src == 0 {
+				continue
+			}
+			err := getSketch(targetPtrSlice[i]).Merge(src.Value().(*hll.Sketch))
+			if err != nil {
+				panic(err)
+			}
+			src.Delete()
+			*src = 0
+		}
+	}
+	finalize = func(states *chunk.Vector, data *AggrInputData, result *chunk.Vector, count int, offset int) {
+		util.AssertFunc(states.PhyFormat().IsFlat())
+		result.SetPhyFormat(chunk.PF_FLAT)
+		statePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+		for i := 0; i < count; i++ {
+			handle := (*cgo.Handle)(statePtrSlice[i])
+			var sketch *hll.Sketch
+			if *handle != 0 {
+				sketch = handle.Value().(*hll.Sketch)
+				handle.Delete()
+				*handle = 0
+			}
+			if kind == HLL_COUNT {
+				var cnt uint64
+				if sketch != nil {
+					cnt = sketch.Estimate()
+				}
+				result.SetValue(i+offset, &chunk.Value{Typ: result.Typ(), I64: int64(cnt)})
+			} else if sketch == nil {
+				chunk.SetNullInPhyFormatFlat(result, uint64(i+offset), true)
+			} else {
+				result.SetValue(i+offset, &chunk.Value{Typ: result.Typ(), Str: encodeSketch(sketch)})
+			}
+		}
+	}
+	destructor = func(states *chunk.Vector, count int) {
+		statePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+		for i := 0; i < count; i++ {
+			handle := (*cgo.Handle)(statePtrSlice[i])
+			if *handle != 0 {
+				handle.Delete()
+				*handle = 0
+			}
+		}
+	}
+	return &FunctionV2{
+		_funcTyp:      AggregateFuncType,
+		_args:         args,
+		_retType:      retTyp,
+		_stateSize:    size,
+		_init:         init,
+		_update:       update,
+		_combine:      combine,
+		_finalize:     finalize,
+		_destructor:   destructor,
+		_nullHandling: DefaultNullHandling,
+	}
+}
+
+// hllEstimate is the scalar hll_estimate(sketch)
+func hllEstimate(input *common.String, result *int64) {
+	sketch, err := decodeSketch(input.String())
+	if err != nil {
+		panic(err)
+	}
+	*result = int64(sketch.Estimate())
+}
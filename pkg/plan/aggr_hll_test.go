@@ -0,0 +1,106 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_HllCombine(t *testing.T) {
+	fun := HllAggregate([]common.LType{common.IntegerType()}, common.BigintType(), HLL_COUNT)
+	left := util.CMalloc(fun._stateSize())
+	defer util.CFree(left)
+	right := util.CMalloc(fun._stateSize())
+	defer util.CFree(right)
+	fun._init(left)
+	fun._init(right)
+
+	//rows 0..5 go to left, rows 6..9 go to right
+	input := newIntVector([]int32{1, 2, 3, 1, 2, 3, 3, 4, 5, 5})
+	states := chunk.NewFlatVector(common.PointerType(), util.DefaultVectorSize)
+	statesSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+	for i := 0; i < 10; i++ {
+		if i < 6 {
+			statesSlice[i] = left
+		} else {
+			statesSlice[i] = right
+		}
+	}
+	fun._update([]*chunk.Vector{input}, NewAggrInputData(), 1, states, 10)
+
+	source := chunk.NewFlatVector(common.PointerType(), 1)
+	chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](source)[0] = right
+	target := chunk.NewFlatVector(common.PointerType(), 1)
+	chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](target)[0] = left
+	fun._combine(source, target, NewAggrInputData(), 1)
+
+	result := chunk.NewFlatVector(common.BigintType(), 1)
+	fun._finalize(target, NewAggrInputData(), result, 1, 0)
+	assert.Equal(t, int64(5), result.GetValue(0).I64)
+}
+
+func Test_HllSketchMerge(t *testing.T) {
+	sketch := HllAggregate([]common.LType{common.IntegerType()}, common.VarcharType(), HLL_SKETCH)
+	s1 := runAggr(sketch, []*chunk.Vector{newIntVector([]int32{1, 2, 3})}, 3).Str
+	s2 := runAggr(sketch, []*chunk.Vector{newIntVector([]int32{3, 4})}, 2).Str
+
+	merge := HllAggregate([]common.LType{common.VarcharType()}, common.VarcharType(), HLL_MERGE)
+	merged := runAggr(merge, []*chunk.Vector{chunk.NewVarcharFlatVector([]string{s1, s2}, 2)}, 2).Str
+
+	var cnt int64
+	str := chunk.NewVarcharFlatVector([]string{merged}, 1)
+	hllEstimate(&chunk.GetSliceInPhyFormatFlat[common.String](str)[0], &cnt)
+	assert.Equal(t, int64(4), cnt)
+
+	_, err := decodeSketch("not a sketch")
+	assert.Error(t, err)
+}
+
+func Test_HllTypes(t *testing.T) {
+	tests := []struct {
+		typ  common.LType
+		vals []*chunk.Value
+	}{
+		{common.DoubleType(), []*chunk.Value{{F64: 1.5}, {F64: 0}, {F64: math.Copysign(0, -1)}, {F64: 1.5}}},
+		{common.FloatType(), []*chunk.Value{{F64: 1.5}, {F64: 2.5}, {F64: 2.5}}},
+		{common.BooleanType(), []*chunk.Value{{Bool: true}, {Bool: false}, {Bool: true}}},
+		{common.TimestampType(), []*chunk.Value{{I64: 1000}, {I64: 2000}, {I64: 1000}}},
+	}
+	for _, tt := range tests {
+		assert.True(t, hllHashable(tt.typ), tt.typ.String())
+		input := chunk.NewFlatVector(tt.typ, util.DefaultVectorSize)
+		for i, val := range tt.vals {
+			val.Typ = tt.typ
+			input.SetValue(i, val)
+		}
+		fun := HllAggregate([]common.LType{tt.typ}, common.BigintType(), HLL_COUNT)
+		assert.Equal(t, int64(2), runAggr(fun, []*chunk.Vector{input}, len(tt.vals)).I64, tt.typ.String())
+	}
+
+	smallint := common.SmallintType()
+	assert.True(t, hllHashable(smallint))
+	input := chunk.NewFlatVector(smallint, util.DefaultVectorSize)
+	copy(chunk.GetSliceInPhyFormatFlat[int16](input), []int16{1, -1, 1})
+	fun := HllAggregate([]common.LType{smallint}, common.BigintType(), HLL_COUNT)
+	assert.Equal(t, int64(2), runAggr(fun, []*chunk.Vector{input}, 3).I64)
+}
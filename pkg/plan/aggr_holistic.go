@@ -0,0 +1,563 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"cmp"
+	"math"
+	"runtime/cgo"
+	"slices"
+	"strings"
+	"unsafe"
+
+	dec "github.com/govalues/decimal"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// HolisticOp is the operator of the aggregate that
+// keeps its state in a go object.
+// The inputs are handled row by row.
+type HolisticOp[S any] interface {
+	Init() *S
+	Update(state *S, row []*chunk.Value)
+	// Combine merges the source into the target
+	Combine(source, target *S)
+	// Finalize returns the result. nil denotes NULL.
+	Finalize(state *S, retTyp common.LType) *chunk.Value
+	// IgnoreNull skips the rows whose first input is NULL
+	IgnoreNull() bool
+}
+
+// HolisticAggregate creates the aggregate on the HolisticOp.
+// The state is the cgo handle of the object. The state memory
+// is allocated by C and can not hold the go pointer.
+// The object is created on the first row and released in the finalize
+// or in the destructor if the states are not finalized.
+func HolisticAggregate[S any](
+	args []common.LType,
+	retTyp common.LType,
+	op HolisticOp[S],
+) *FunctionV2 {
+	var size aggrStateSize
+	var init aggrInit
+	var update aggrUpdate
+	var combine aggrCombine
+	var finalize aggrFinalize
+	var destructor aggrDestructor
+	size = func() int {
+		var handle cgo.Handle
+		return int(unsafe.Sizeof(handle))
+	}
+	init = func(pointer unsafe.Pointer) {
+		*(*cgo.Handle)(pointer) = 0
+	}
+	update = func(inputs []*chunk.Vector, data *AggrInputData, inputCount int, states *chunk.Vector, count int) {
+		util.AssertFunc(inputCount == len(inputs))
+		var sdata chunk.UnifiedFormat
+		states.ToUnifiedFormat(count, &sdata)
+		statesPtrSlice := chunk.GetSliceInPhyFormatUnifiedFormat[unsafe.Pointer](&sdata)
+		row := make([]*chunk.Value, inputCount)
+		for i := 0; i < count; i++ {
+			for j := 0; j < inputCount; j++ {
+				row[j] = inputs[j].GetValue(i)
+			}
+			if op.IgnoreNull() && inputCount > 0 && row[0].IsNull {
+				continue
+			}
+			handle := (*cgo.Handle)(statesPtrSlice[sdata.Sel.GetIndex(i)])
+			if *handle == 0 {
+				*handle = cgo.NewHandle(op.Init())
+			}
+			op.Update(handle.Value().(*S), row)
+		}
+	}
+	combine = func(source *chunk.Vector, target *chunk.Vector, data *AggrInputData, count int) {
+		sourcePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](source)
+		targetPtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](target)
+		for i := 0; i < count; i++ {
+			src := (*cgo.Handle)(sourcePtrSlice[i])
+			dst := (*cgo.Handle)(targetPtrSlice[i])
+			if *src == 0 {
+				continue
+			}
+			if *dst == 0 {
+				*dst = *src
+			} else {
+				op.Combine(src.Value().(*S), dst.Value().(*S))
+				src.Delete()
+			}
+			*src = 0
+		}
+	}
+	finalize = func(states *chunk.Vector, data *AggrInputData, result *chunk.Vector, count int, offset int) {
+		util.AssertFunc(states.PhyFormat().IsFlat())
+		result.SetPhyFormat(chunk.PF_FLAT)
+		statePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+		for i := 0; i < count; i++ {
+			handle := (*cgo.Handle)(statePtrSlice[i])
+			var state *S
+			if *handle == 0 {
+				state = op.Init()
+			} else {
+				state = handle.Value().(*S)
+				handle.Delete()
+				*handle = 0
+			}
+			val := op.Finalize(state, result.Typ())
+			if val == nil || val.IsNull {
+				chunk.SetNullInPhyFormatFlat(result, uint64(i+offset), true)
+			} else {
+				val.Typ = result.Typ()
+				result.SetValue(i+offset, val)
+			}
+		}
+	}
+	destructor = func(states *chunk.Vector, count int) {
+		statePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+		for i := 0; i < count; i++ {
+			handle := (*cgo.Handle)(statePtrSlice[i])
+			if *handle != 0 {
+				handle.Delete()
+				*handle = 0
+			}
+		}
+	}
+	return &FunctionV2{
+		_funcTyp:      AggregateFuncType,
+		_args:         args,
+		_retType:      retTyp,
+		_stateSize:    size,
+		_init:         init,
+		_update:       update,
+		_combine:      combine,
+		_finalize:     finalize,
+		_destructor:   destructor,
+		_nullHandling: DefaultNullHandling,
+	}
+}
+
+// compareValue compares two values of the same type.
+// NULL is greater than any other value.
+func compareValue(a, b *chunk.Value) int {
+	if a.IsNull || b.IsNull {
+		if a.IsNull && b.IsNull {
+			return 0
+		} else if a.IsNull {
+			return 1
+		}
+		return -1
+	}
+	switch a.Typ.Id {
+	case common.LTID_BOOLEAN:
+		if a.Bool == b.Bool {
+			return 0
+		} else if !a.Bool {
+			return -1
+		}
+		return 1
+	case common.LTID_INTEGER, common.LTID_BIGINT, common.LTID_POINTER,
+		common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ, common.LTID_TIMESTAMP_SEC,
+		common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS, common.LTID_TIME:
+		return cmp.Compare(a.I64, b.I64)
+	case common.LTID_INTERVAL:
+		ad, am := valueToInterval(a).Normalize()
+		bd, bm := valueToInterval(b).Normalize()
+		if ret := cmp.Compare(ad, bd); ret != 0 {
+			return ret
+		}
+		return cmp.Compare(am, bm)
+	case common.LTID_UBIGINT:
+		return cmp.Compare(uint64(a.I64), uint64(b.I64))
+	case common.LTID_HUGEINT:
+		if ret := cmp.Compare(a.I64, b.I64); ret != 0 {
+			return ret
+		}
+		return cmp.Compare(uint64(a.I64_1), uint64(b.I64_1))
+	case common.LTID_FLOAT, common.LTID_DOUBLE:
+		return cmp.Compare(a.F64, b.F64)
+	case common.LTID_VARCHAR:
+		return strings.Compare(a.Str, b.Str)
+	case common.LTID_DATE:
+		if ret := cmp.Compare(a.I64, b.I64); ret != 0 {
+			return ret
+		}
+		if ret := cmp.Compare(a.I64_1, b.I64_1); ret != 0 {
+			return ret
+		}
+		return cmp.Compare(a.I64_2, b.I64_2)
+	case common.LTID_DECIMAL:
+		return valueToDecimal(a).Cmp(valueToDecimal(b))
+	default:
+		panic("usp")
+	}
+}
+
+func valueToDecimal(val *chunk.Value) dec.Decimal {
+	util.AssertFunc(val.Typ.Id == common.LTID_DECIMAL)
+	var ret dec.Decimal
+	var err error
+	if len(val.Str) != 0 {
+		ret, err = dec.ParseExact(val.Str, val.Typ.Scale)
+	} else {
+		ret, err = dec.NewFromInt64(val.I64, val.I64_1, val.Typ.Scale)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func valueToInterval(val *chunk.Value) *common.Interval {
+	return &common.Interval{
+		Months: int32(val.I64),
+		Days:   int32(val.I64_1),
+		Micros: val.I64_2,
+	}
+}
+
+// valueToFloat64 converts the numeric value into float64.
+// the temporal value is converted into the micros.
+func valueToFloat64(val *chunk.Value) float64 {
+	switch val.Typ.Id {
+	case common.LTID_INTEGER, common.LTID_BIGINT,
+		common.LTID_TIMESTAMP, common.LTID_TIME:
+		return float64(val.I64)
+	case common.LTID_INTERVAL:
+		days, micros := valueToInterval(val).Normalize()
+		return float64(days)*float64(common.MicrosPerDay) + float64(micros)
+	case common.LTID_UBIGINT:
+		return float64(uint64(val.I64))
+	case common.LTID_FLOAT, common.LTID_DOUBLE:
+		return val.F64
+	case common.LTID_DECIMAL:
+		f, _ := valueToDecimal(val).Float64()
+		return f
+	default:
+		panic("usp")
+	}
+}
+
+type StringAggState struct {
+	_isset bool
+	_sep   string
+	_buf   strings.Builder
+}
+
+// StringAggOp concatenates the strings with the separator.
+// The separator is ',' if it is not specified.
+type StringAggOp struct {
+}
+
+func (StringAggOp) Init() *StringAggState {
+	return &StringAggState{_sep: ","}
+}
+
+func (StringAggOp) Update(state *StringAggState, row []*chunk.Value) {
+	if len(row) > 1 {
+		state._sep = ""
+		if !row[1].IsNull {
+			state._sep = row[1].Str
+		}
+	}
+	if state._isset {
+		state._buf.WriteString(state._sep)
+	}
+	state._buf.WriteString(row[0].Str)
+	state._isset = true
+}
+
+func (StringAggOp) Combine(source, target *StringAggState) {
+	if !source._isset {
+		return
+	}
+	if target._isset {
+		target._buf.WriteString(source._sep)
+	}
+	target._buf.WriteString(source._buf.String())
+	target._isset = true
+}
+
+func (StringAggOp) Finalize(state *StringAggState, retTyp common.LType) *chunk.Value {
+	if !state._isset {
+		return nil
+	}
+	return &chunk.Value{
+		Typ: retTyp,
+		Str: state._buf.String(),
+	}
+}
+
+func (StringAggOp) IgnoreNull() bool {
+	return true
+}
+
+type QuantileState struct {
+	_values []*chunk.Value
+}
+
+// QuantileOp computes the quantile of the values.
+// The discrete one returns the first value whose position
+// reaches the fraction. The continuous one interpolates
+// between the adjacent values.
+// The values are in descending order if _desc is true.
+type QuantileOp struct {
+	_discrete bool
+	_desc     bool
+	_frac     float64
+}
+
+func (op QuantileOp) Init() *QuantileState {
+	return &QuantileState{}
+}
+
+func (op QuantileOp) Update(state *QuantileState, row []*chunk.Value) {
+	state._values = append(state._values, row[0])
+}
+
+func (op QuantileOp) Combine(source, target *QuantileState) {
+	target._values = append(target._values, source._values...)
+}
+
+func (op QuantileOp) Finalize(state *QuantileState, retTyp common.LType) *chunk.Value {
+	n := len(state._values)
+	if n == 0 {
+		return nil
+	}
+	slices.SortFunc(state._values, func(a, b *chunk.Value) int {
+		if op._desc {
+			return compareValue(b, a)
+		}
+		return compareValue(a, b)
+	})
+	if op._discrete {
+		idx := max(int(math.Ceil(op._frac*float64(n)))-1, 0)
+		return state._values[idx]
+	}
+	pos := op._frac * float64(n-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	lval := valueToFloat64(state._values[lo])
+	hval := valueToFloat64(state._values[hi])
+	res := lval + (pos-float64(lo))*(hval-lval)
+	switch retTyp.Id {
+	case common.LTID_TIMESTAMP, common.LTID_TIME:
+		return &chunk.Value{
+			Typ: retTyp,
+			I64: int64(math.Round(res)),
+		}
+	case common.LTID_INTERVAL:
+		micros := int64(math.Round(res))
+		return &chunk.Value{
+			Typ:   retTyp,
+			I64_1: micros / common.MicrosPerDay,
+			I64_2: micros % common.MicrosPerDay,
+		}
+	default:
+		return &chunk.Value{
+			Typ: retTyp,
+			F64: res,
+		}
+	}
+}
+
+// interpolable decides the continuous quantile of the type
+// keeps the type instead of the double.
+func interpolable(typ common.LType) bool {
+	switch typ.Id {
+	case common.LTID_TIMESTAMP, common.LTID_TIME, common.LTID_INTERVAL:
+		return true
+	default:
+		return false
+	}
+}
+
+func (op QuantileOp) IgnoreNull() bool {
+	return true
+}
+
+type modeEntry struct {
+	_value *chunk.Value
+	_count int
+}
+
+type ModeState struct {
+	_entries map[string]*modeEntry
+}
+
+// ModeOp returns the most frequent value.
+// The smallest one wins if there are multiple.
+type ModeOp struct {
+}
+
+func (ModeOp) Init() *ModeState {
+	return &ModeState{_entries: make(map[string]*modeEntry)}
+}
+
+func (ModeOp) Update(state *ModeState, row []*chunk.Value) {
+	key := row[0].String()
+	if ent, has := state._entries[key]; has {
+		ent._count++
+	} else {
+		state._entries[key] = &modeEntry{_value: row[0], _count: 1}
+	}
+}
+
+func (ModeOp) Combine(source, target *ModeState) {
+	for key, ent := range source._entries {
+		if tEnt, has := target._entries[key]; has {
+			tEnt._count += ent._count
+		} else {
+			target._entries[key] = ent
+		}
+	}
+}
+
+func (ModeOp) Finalize(state *ModeState, retTyp common.LType) *chunk.Value {
+	var best *modeEntry
+	for _, ent := range state._entries {
+		if best == nil ||
+			ent._count > best._count ||
+			ent._count == best._count && compareValue(ent._value, best._value) < 0 {
+			best = ent
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best._value
+}
+
+func (ModeOp) IgnoreNull() bool {
+	return true
+}
+
+type AnyValueState struct {
+	_value *chunk.Value
+}
+
+// AnyValueOp returns the first non-NULL value
+type AnyValueOp struct {
+}
+
+func (AnyValueOp) Init() *AnyValueState {
+	return &AnyValueState{}
+}
+
+func (AnyValueOp) Update(state *AnyValueState, row []*chunk.Value) {
+	if state._value == nil {
+		state._value = row[0]
+	}
+}
+
+func (AnyValueOp) Combine(source, target *AnyValueState) {
+	if target._value == nil {
+		target._value = source._value
+	}
+}
+
+func (AnyValueOp) Finalize(state *AnyValueState, retTyp common.LType) *chunk.Value {
+	return state._value
+}
+
+func (AnyValueOp) IgnoreNull() bool {
+	return true
+}
+
+type ArgMinMaxState struct {
+	_arg *chunk.Value
+	_val *chunk.Value
+}
+
+// ArgMinMaxOp returns the first argument of the row
+// that has the minimum or maximum second argument.
+// The rows with NULL second argument are skipped.
+type ArgMinMaxOp struct {
+	_max bool
+}
+
+func (ArgMinMaxOp) Init() *ArgMinMaxState {
+	return &ArgMinMaxState{}
+}
+
+func (op ArgMinMaxOp) better(val, old *chunk.Value) bool {
+	if old == nil {
+		return true
+	}
+	ret := compareValue(val, old)
+	if op._max {
+		return ret > 0
+	}
+	return ret < 0
+}
+
+func (op ArgMinMaxOp) Update(state *ArgMinMaxState, row []*chunk.Value) {
+	if row[1].IsNull {
+		return
+	}
+	if op.better(row[1], state._val) {
+		state._arg = row[0]
+		state._val = row[1]
+	}
+}
+
+func (op ArgMinMaxOp) Combine(source, target *ArgMinMaxState) {
+	if source._val != nil && op.better(source._val, target._val) {
+		target._arg = source._arg
+		target._val = source._val
+	}
+}
+
+func (ArgMinMaxOp) Finalize(state *ArgMinMaxState, retTyp common.LType) *chunk.Value {
+	return state._arg
+}
+
+func (ArgMinMaxOp) IgnoreNull() bool {
+	return false
+}
+
+type ListAggState struct {
+	_values []*chunk.Value
+}
+
+// ListAggOp collects the values of the group into a list.
+// NULL values are kept.
+type ListAggOp struct {
+}
+
+func (ListAggOp) Init() *ListAggState {
+	return &ListAggState{}
+}
+
+func (ListAggOp) Update(state *ListAggState, row []*chunk.Value) {
+	state._values = append(state._values, row[0])
+}
+
+func (ListAggOp) Combine(source, target *ListAggState) {
+	target._values = append(target._values, source._values...)
+}
+
+func (ListAggOp) Finalize(state *ListAggState, retTyp common.LType) *chunk.Value {
+	if len(state._values) == 0 {
+		return nil
+	}
+	return chunk.NewListValue(retTyp.ListChild(), state._values)
+}
+
+func (ListAggOp) IgnoreNull() bool {
+	return false
+}
@@ -0,0 +1,146 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"runtime/cgo"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// runAggr aggregates all the rows into one state and returns the result
+func runAggr(fun *FunctionV2, inputs []*chunk.Vector, count int) *chunk.Value {
+	state := util.CMalloc(fun._stateSize())
+	defer util.CFree(state)
+	fun._init(state)
+	states := chunk.NewFlatVector(common.PointerType(), util.DefaultVectorSize)
+	statesSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+	for i := 0; i < count; i++ {
+		statesSlice[i] = state
+	}
+	fun._update(inputs, NewAggrInputData(), len(inputs), states, count)
+	result := chunk.NewFlatVector(fun._retType, 1)
+	fun._finalize(states, NewAggrInputData(), result, 1, 0)
+	return result.GetValue(0)
+}
+
+func newIntVector(vals []int32) *chunk.Vector {
+	vec := chunk.NewFlatVector(common.IntegerType(), util.DefaultVectorSize)
+	copy(chunk.GetSliceInPhyFormatFlat[int32](vec), vals)
+	return vec
+}
+
+func Test_SortedStringAgg(t *testing.T) {
+	strs := chunk.NewVarcharFlatVector([]string{"b", "c", "a"}, 3)
+	keys := newIntVector([]int32{2, 1, 3})
+	inner := aggrFuncs["string_agg"].GetFunc(0)
+
+	asc := SortedAggregate(inner, []common.LType{common.IntegerType()}, []bool{false}, []bool{false})
+	assert.Equal(t, "c,b,a", runAggr(asc, []*chunk.Vector{strs, keys}, 3).Str)
+
+	desc := SortedAggregate(inner, []common.LType{common.IntegerType()}, []bool{true}, []bool{true})
+	assert.Equal(t, "a,b,c", runAggr(desc, []*chunk.Vector{strs, keys}, 3).Str)
+}
+
+func Test_Quantile(t *testing.T) {
+	vals := newIntVector([]int32{40, 10, 30, 20})
+	col := &Expr{Typ: ET_Column, DataTyp: common.IntegerType()}
+	frac := &Expr{Typ: ET_FConst, DataTyp: common.DoubleType(), Fvalue: 0.5}
+
+	cont := aggrFuncs["percentile_cont"].GetFunc(0)
+	_, err := cont._bind(cont, []*Expr{col, frac})
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, runAggr(cont, []*chunk.Vector{vals}, 4).F64)
+
+	disc := aggrFuncs["percentile_disc"].GetFunc(0)
+	_, err = disc._bind(disc, []*Expr{col, frac})
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), runAggr(disc, []*chunk.Vector{vals}, 4).I64)
+
+	//the fraction is checked in the bind
+	quantile := aggrFuncs["quantile"].GetFunc(0)
+	_, err = quantile._bind(quantile, []*Expr{col, {Typ: ET_IConst, DataTyp: common.IntegerType(), Ivalue: 2}})
+	assert.ErrorContains(t, err, "out of range")
+	_, err = quantile._bind(quantile, []*Expr{col, col})
+	assert.ErrorContains(t, err, "must be a constant")
+
+	mode := aggrFuncs["mode"].GetFunc(0)
+	mode._retType = common.IntegerType()
+	modeVals := newIntVector([]int32{3, 1, 3, 1, 2})
+	assert.Equal(t, int64(1), runAggr(mode, []*chunk.Vector{modeVals}, 5).I64)
+}
+
+func Test_orderedAggrSQL(t *testing.T) {
+	tests := []struct {
+		sql    string
+		expect []string
+	}{
+		{
+			"select percentile_disc(0.5) within group (order by i) from generate_series(1,4) t(i)",
+			[]string{"2"},
+		},
+		{
+			"select percentile_disc(0.5) within group (order by i desc) from generate_series(1,4) t(i)",
+			[]string{"3"},
+		},
+		{
+			"select percentile_cont(0.25) within group (order by i desc) from generate_series(1,4) t(i)",
+			[]string{"3.25"},
+		},
+		{
+			"select string_agg(cast(i as varchar), ',' order by i desc) from generate_series(1,4) t(i)",
+			[]string{"4,3,2,1"},
+		},
+		{
+			"select i % 2, count(*) filter (where i > 2) from generate_series(1,6) t(i) group by i % 2",
+			[]string{"0|2", "1|2"},
+		},
+		//count is 0 if all the rows of the group are filtered out
+		{
+			"select i % 2, count(i) filter (where i > 10), string_agg(cast(i as varchar), '-' order by i desc) filter (where i < 5) " +
+				"from generate_series(1,6) t(i) group by i % 2",
+			[]string{"0|0|4-2", "1|0|3-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.expect, runSelectSQL(t, tt.sql))
+		})
+	}
+}
+
+func Test_holisticDestructor(t *testing.T) {
+	fun := aggrFuncs["string_agg"].GetFunc(0)
+	state := util.CMalloc(fun._stateSize())
+	defer util.CFree(state)
+	fun._init(state)
+	states := chunk.NewFlatVector(common.PointerType(), util.DefaultVectorSize)
+	chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)[0] = state
+	strs := chunk.NewVarcharFlatVector([]string{"a"}, 1)
+	fun._update([]*chunk.Vector{strs}, NewAggrInputData(), 1, states, 1)
+	handle := (*cgo.Handle)(state)
+	assert.NotZero(t, *handle)
+
+	//the state is not finalized
+	fun._destructor(states, 1)
+	assert.Zero(t, *handle)
+}
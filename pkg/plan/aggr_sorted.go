@@ -0,0 +1,134 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"slices"
+	"unsafe"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// SortedAggrState buffers the arguments and the sort keys
+// of the rows in the group.
+type SortedAggrState struct {
+	_rows [][]*chunk.Value
+}
+
+// SortedAggrOp evaluates the aggregate with ORDER BY.
+// It sorts the rows of the group by the keys and then
+// feeds them into the inner aggregate in order.
+type SortedAggrOp struct {
+	_inner      *FunctionV2
+	_argCount   int
+	_desc       []bool
+	_nullsFirst []bool
+}
+
+func (op *SortedAggrOp) Init() *SortedAggrState {
+	return &SortedAggrState{}
+}
+
+func (op *SortedAggrOp) Update(state *SortedAggrState, row []*chunk.Value) {
+	state._rows = append(state._rows, slices.Clone(row))
+}
+
+func (op *SortedAggrOp) Combine(source, target *SortedAggrState) {
+	target._rows = append(target._rows, source._rows...)
+}
+
+func (op *SortedAggrOp) IgnoreNull() bool {
+	return false
+}
+
+func (op *SortedAggrOp) compare(a, b []*chunk.Value) int {
+	for i := range op._desc {
+		lhs := a[op._argCount+i]
+		rhs := b[op._argCount+i]
+		if lhs.IsNull != rhs.IsNull {
+			if lhs.IsNull == op._nullsFirst[i] {
+				return -1
+			}
+			return 1
+		}
+		ret := compareValue(lhs, rhs)
+		if op._desc[i] {
+			ret = -ret
+		}
+		if ret != 0 {
+			return ret
+		}
+	}
+	return 0
+}
+
+func (op *SortedAggrOp) Finalize(state *SortedAggrState, retTyp common.LType) *chunk.Value {
+	slices.SortStableFunc(state._rows, op.compare)
+
+	inner := op._inner
+	statePtr := util.CMalloc(inner._stateSize())
+	inner._init(statePtr)
+
+	states := chunk.NewFlatVector(common.PointerType(), util.DefaultVectorSize)
+	statesSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+	for i := range statesSlice {
+		statesSlice[i] = statePtr
+	}
+	defer func() {
+		if inner._destructor != nil {
+			inner._destructor(states, 1)
+		}
+		util.CFree(statePtr)
+	}()
+
+	inputs := make([]*chunk.Vector, op._argCount)
+	for i := 0; i < len(state._rows); i += util.DefaultVectorSize {
+		cnt := min(util.DefaultVectorSize, len(state._rows)-i)
+		for j := 0; j < op._argCount; j++ {
+			inputs[j] = chunk.NewFlatVector(inner._args[j], util.DefaultVectorSize)
+			for k := 0; k < cnt; k++ {
+				inputs[j].SetValue(k, state._rows[i+k][j])
+			}
+		}
+		inner._update(inputs, NewAggrInputData(), op._argCount, states, cnt)
+	}
+
+	result := chunk.NewFlatVector(inner._retType, 1)
+	inner._finalize(states, NewAggrInputData(), result, 1, 0)
+	return result.GetValue(0)
+}
+
+// SortedAggregate wraps the aggregate with ORDER BY.
+// The keys are the arguments after the ones of the inner aggregate.
+func SortedAggregate(
+	inner *FunctionV2,
+	keyTypes []common.LType,
+	desc []bool,
+	nullsFirst []bool,
+) *FunctionV2 {
+	op := &SortedAggrOp{
+		_inner:      inner,
+		_argCount:   len(inner._args),
+		_desc:       desc,
+		_nullsFirst: nullsFirst,
+	}
+	args := util.CopyTo(inner._args)
+	args = append(args, keyTypes...)
+	ret := HolisticAggregate[SortedAggrState](args, inner._retType, op)
+	ret._name = inner._name
+	return ret
+}
@@ -0,0 +1,477 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// decodeAs reads the vector into the values of type T.
+// The valid[i] is false if the row i is NULL.
+func decodeAs[S any, T any](vec *chunk.Vector, count int, conv func(S) T) ([]T, []bool) {
+	var uni chunk.UnifiedFormat
+	vec.ToUnifiedFormat(count, &uni)
+	slice := chunk.GetSliceInPhyFormatUnifiedFormat[S](&uni)
+	vals := make([]T, count)
+	valid := make([]bool, count)
+	for i := 0; i < count; i++ {
+		idx := uni.Sel.GetIndex(i)
+		if !uni.Mask.RowIsValid(uint64(idx)) {
+			continue
+		}
+		valid[i] = true
+		vals[i] = conv(slice[idx])
+	}
+	return vals, valid
+}
+
+func toFloat64[S int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64](v S) float64 {
+	return float64(v)
+}
+
+func toInt64[S int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64](v S) int64 {
+	return int64(v)
+}
+
+func decodeFloat64s(vec *chunk.Vector, count int) ([]float64, []bool) {
+	switch vec.Typ().GetInternalType() {
+	case common.INT8:
+		return decodeAs(vec, count, toFloat64[int8])
+	case common.INT16:
+		return decodeAs(vec, count, toFloat64[int16])
+	case common.INT32:
+		return decodeAs(vec, count, toFloat64[int32])
+	case common.INT64:
+		return decodeAs(vec, count, toFloat64[int64])
+	case common.UINT8:
+		return decodeAs(vec, count, toFloat64[uint8])
+	case common.UINT16:
+		return decodeAs(vec, count, toFloat64[uint16])
+	case common.UINT32:
+		return decodeAs(vec, count, toFloat64[uint32])
+	case common.UINT64:
+		return decodeAs(vec, count, toFloat64[uint64])
+	case common.FLOAT:
+		return decodeAs(vec, count, toFloat64[float32])
+	case common.DOUBLE:
+		return decodeAs(vec, count, toFloat64[float64])
+	case common.INT128:
+		return decodeAs(vec, count, func(v common.Hugeint) float64 {
+			return float64(v.Upper)*math.Pow(2, 64) + float64(v.Lower)
+		})
+	case common.DECIMAL:
+		return decodeAs(vec, count, func(v common.Decimal) float64 {
+			f, _ := v.Float64()
+			return f
+		})
+	default:
+		panic("usp")
+	}
+}
+
+func decodeInt64s(vec *chunk.Vector, count int) ([]int64, []bool) {
+	switch vec.Typ().GetInternalType() {
+	case common.INT8:
+		return decodeAs(vec, count, toInt64[int8])
+	case common.INT16:
+		return decodeAs(vec, count, toInt64[int16])
+	case common.INT32:
+		return decodeAs(vec, count, toInt64[int32])
+	case common.INT64:
+		return decodeAs(vec, count, toInt64[int64])
+	case common.UINT8:
+		return decodeAs(vec, count, toInt64[uint8])
+	case common.UINT16:
+		return decodeAs(vec, count, toInt64[uint16])
+	case common.UINT32:
+		return decodeAs(vec, count, toInt64[uint32])
+	case common.UINT64:
+		return decodeAs(vec, count, toInt64[uint64])
+	default:
+		panic("usp")
+	}
+}
+
+func decodeBools(vec *chunk.Vector, count int) ([]bool, []bool) {
+	return decodeAs(vec, count, func(v bool) bool { return v })
+}
+
+// FixedStateOp is the operator of the aggregate whose state
+// is a plain struct in the aggregate row.
+// The state must not have any go pointer.
+type FixedStateOp[S any, T any] interface {
+	Init(state *S)
+	// Update handles the row that has no NULL
+	Update(state *S, row []T)
+	// Combine merges the source into the target
+	Combine(source, target *S)
+	// Finalize returns the result. nil denotes NULL.
+	Finalize(state *S, retTyp common.LType) *chunk.Value
+}
+
+// FixedStateAggregate creates the aggregate on the FixedStateOp.
+// The inputs are decoded into T by the decode.
+// The rows with NULL inputs are skipped.
+func FixedStateAggregate[S any, T any](
+	args []common.LType,
+	retTyp common.LType,
+	decode func(*chunk.Vector, int) ([]T, []bool),
+	op FixedStateOp[S, T],
+) *FunctionV2 {
+	var size aggrStateSize
+	var init aggrInit
+	var update aggrUpdate
+	var combine aggrCombine
+	var finalize aggrFinalize
+	size = func() int {
+		var state S
+		return int(unsafe.Sizeof(state))
+	}
+	init = func(pointer unsafe.Pointer) {
+		op.Init((*S)(pointer))
+	}
+	update = func(inputs []*chunk.Vector, data *AggrInputData, inputCount int, states *chunk.Vector, count int) {
+		util.AssertFunc(inputCount == len(inputs))
+		vals := make([][]T, inputCount)
+		valids := make([][]bool, inputCount)
+		for j := 0; j < inputCount; j++ {
+			vals[j], valids[j] = decode(inputs[j], count)
+		}
+		var sdata chunk.UnifiedFormat
+		states.ToUnifiedFormat(count, &sdata)
+		statesPtrSlice := chunk.GetSliceInPhyFormatUnifiedFormat[unsafe.Pointer](&sdata)
+		row := make([]T, inputCount)
+	next:
+		for i := 0; i < count; i++ {
+			for j := 0; j < inputCount; j++ {
+				if !valids[j][i] {
+					continue next
+				}
+				row[j] = vals[j][i]
+			}
+			op.Update((*S)(statesPtrSlice[sdata.Sel.GetIndex(i)]), row)
+		}
+	}
+	combine = func(source *chunk.Vector, target *chunk.Vector, data *AggrInputData, count int) {
+		sourcePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](source)
+		targetPtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](target)
+		for i := 0; i < count; i++ {
+			op.Combine((*S)(sourcePtrSlice[i]), (*S)(targetPtrSlice[i]))
+		}
+	}
+	finalize = func(states *chunk.Vector, data *AggrInputData, result *chunk.Vector, count int, offset int) {
+		util.AssertFunc(states.PhyFormat().IsFlat())
+		result.SetPhyFormat(chunk.PF_FLAT)
+		statePtrSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](states)
+		for i := 0; i < count; i++ {
+			val := op.Finalize((*S)(statePtrSlice[i]), result.Typ())
+			if val == nil {
+				chunk.SetNullInPhyFormatFlat(result, uint64(i+offset), true)
+			} else {
+				result.SetValue(i+offset, val)
+			}
+		}
+	}
+	return &FunctionV2{
+		_funcTyp:      AggregateFuncType,
+		_args:         args,
+		_retType:      retTyp,
+		_stateSize:    size,
+		_init:         init,
+		_update:       update,
+		_combine:      combine,
+		_finalize:     finalize,
+		_nullHandling: DefaultNullHandling,
+	}
+}
+
+type VarianceState struct {
+	_count uint64
+	_mean  float64
+	_m2    float64
+}
+
+type VarianceKind int
+
+const (
+	VAR_SAMP VarianceKind = iota
+	VAR_POP
+	STDDEV_SAMP
+	STDDEV_POP
+)
+
+// VarianceOp computes the variance and the standard deviation
+// with the Welford's algorithm.
+type VarianceOp struct {
+	_kind VarianceKind
+}
+
+func (VarianceOp) Init(state *VarianceState) {
+	*state = VarianceState{}
+}
+
+func (VarianceOp) Update(state *VarianceState, row []float64) {
+	x := row[0]
+	state._count++
+	delta := x - state._mean
+	state._mean += delta / float64(state._count)
+	state._m2 += delta * (x - state._mean)
+}
+
+func (VarianceOp) Combine(source, target *VarianceState) {
+	if source._count == 0 {
+		return
+	}
+	if target._count == 0 {
+		*target = *source
+		return
+	}
+	n1 := float64(target._count)
+	n2 := float64(source._count)
+	n := n1 + n2
+	delta := source._mean - target._mean
+	target._mean += delta * n2 / n
+	target._m2 += source._m2 + delta*delta*n1*n2/n
+	target._count += source._count
+}
+
+func (op VarianceOp) Finalize(state *VarianceState, retTyp common.LType) *chunk.Value {
+	var ret float64
+	switch op._kind {
+	case VAR_SAMP, STDDEV_SAMP:
+		if state._count < 2 {
+			return nil
+		}
+		ret = state._m2 / float64(state._count-1)
+	case VAR_POP, STDDEV_POP:
+		if state._count == 0 {
+			return nil
+		}
+		ret = state._m2 / float64(state._count)
+	}
+	if op._kind == STDDEV_SAMP || op._kind == STDDEV_POP {
+		ret = math.Sqrt(ret)
+	}
+	return &chunk.Value{Typ: retTyp, F64: ret}
+}
+
+// RegrState keeps the moments of the pairs (y,x)
+type RegrState struct {
+	_count uint64
+	_meanX float64
+	_meanY float64
+	_m2X   float64
+	_m2Y   float64
+	_cXY   float64
+}
+
+type RegrKind int
+
+const (
+	COVAR_POP RegrKind = iota
+	COVAR_SAMP
+	CORR
+	REGR_COUNT
+	REGR_AVGX
+	REGR_AVGY
+	REGR_SXX
+	REGR_SYY
+	REGR_SXY
+	REGR_SLOPE
+	REGR_INTERCEPT
+	REGR_R2
+)
+
+// RegrOp computes the covariance, the correlation and
+// the linear regression of the pairs (y,x).
+type RegrOp struct {
+	_kind RegrKind
+}
+
+func (RegrOp) Init(state *RegrState) {
+	*state = RegrState{}
+}
+
+func (RegrOp) Update(state *RegrState, row []float64) {
+	y, x := row[0], row[1]
+	state._count++
+	n := float64(state._count)
+	dx := x - state._meanX
+	dy := y - state._meanY
+	state._meanX += dx / n
+	state._meanY += dy / n
+	state._cXY += dx * (y - state._meanY)
+	state._m2X += dx * (x - state._meanX)
+	state._m2Y += dy * (y - state._meanY)
+}
+
+func (RegrOp) Combine(source, target *RegrState) {
+	if source._count == 0 {
+		return
+	}
+	if target._count == 0 {
+		*target = *source
+		return
+	}
+	n1 := float64(target._count)
+	n2 := float64(source._count)
+	n := n1 + n2
+	dx := source._meanX - target._meanX
+	dy := source._meanY - target._meanY
+	target._meanX += dx * n2 / n
+	target._meanY += dy * n2 / n
+	target._cXY += source._cXY + dx*dy*n1*n2/n
+	target._m2X += source._m2X + dx*dx*n1*n2/n
+	target._m2Y += source._m2Y + dy*dy*n1*n2/n
+	target._count += source._count
+}
+
+func (op RegrOp) Finalize(state *RegrState, retTyp common.LType) *chunk.Value {
+	if op._kind == REGR_COUNT {
+		return &chunk.Value{Typ: retTyp, I64: int64(state._count)}
+	}
+	if state._count == 0 {
+		return nil
+	}
+	n := float64(state._count)
+	var ret float64
+	switch op._kind {
+	case COVAR_POP:
+		ret = state._cXY / n
+	case COVAR_SAMP:
+		if state._count < 2 {
+			return nil
+		}
+		ret = state._cXY / (n - 1)
+	case CORR:
+		if state._m2X == 0 || state._m2Y == 0 {
+			return nil
+		}
+		ret = state._cXY / math.Sqrt(state._m2X*state._m2Y)
+	case REGR_AVGX:
+		ret = state._meanX
+	case REGR_AVGY:
+		ret = state._meanY
+	case REGR_SXX:
+		ret = state._m2X
+	case REGR_SYY:
+		ret = state._m2Y
+	case REGR_SXY:
+		ret = state._cXY
+	case REGR_SLOPE:
+		if state._m2X == 0 {
+			return nil
+		}
+		ret = state._cXY / state._m2X
+	case REGR_INTERCEPT:
+		if state._m2X == 0 {
+			return nil
+		}
+		ret = state._meanY - state._cXY/state._m2X*state._meanX
+	case REGR_R2:
+		if state._m2X == 0 {
+			return nil
+		}
+		if state._m2Y == 0 {
+			ret = 1
+		} else {
+			ret = state._cXY * state._cXY / (state._m2X * state._m2Y)
+		}
+	default:
+		panic("usp")
+	}
+	return &chunk.Value{Typ: retTyp, F64: ret}
+}
+
+type BoolState struct {
+	_isset bool
+	_value bool
+}
+
+// BoolOp computes bool_and or bool_or
+type BoolOp struct {
+	_and bool
+}
+
+func (BoolOp) Init(state *BoolState) {
+	*state = BoolState{}
+}
+
+func (op BoolOp) Update(state *BoolState, row []bool) {
+	if !state._isset {
+		state._value = row[0]
+		state._isset = true
+	} else if op._and {
+		state._value = state._value && row[0]
+	} else {
+		state._value = state._value || row[0]
+	}
+}
+
+func (op BoolOp) Combine(source, target *BoolState) {
+	if source._isset {
+		op.Update(target, []bool{source._value})
+	}
+}
+
+func (BoolOp) Finalize(state *BoolState, retTyp common.LType) *chunk.Value {
+	if !state._isset {
+		return nil
+	}
+	return &chunk.Value{Typ: retTyp, Bool: state._value}
+}
+
+type BitState struct {
+	_isset bool
+	_value int64
+}
+
+// BitOp computes bit_and or bit_or
+type BitOp struct {
+	_and bool
+}
+
+func (BitOp) Init(state *BitState) {
+	*state = BitState{}
+}
+
+func (op BitOp) Update(state *BitState, row []int64) {
+	if !state._isset {
+		state._value = row[0]
+		state._isset = true
+	} else if op._and {
+		state._value &= row[0]
+	} else {
+		state._value |= row[0]
+	}
+}
+
+func (op BitOp) Combine(source, target *BitState) {
+	if source._isset {
+		op.Update(target, []int64{source._value})
+	}
+}
+
+func (BitOp) Finalize(state *BitState, retTyp common.LType) *chunk.Value {
+	if !state._isset {
+		return nil
+	}
+	return &chunk.Value{Typ: retTyp, I64: state._value}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func Test_VarianceCombine(t *testing.T) {
+	op := VarianceOp{_kind: VAR_SAMP}
+	all, left, right := VarianceState{}, VarianceState{}, VarianceState{}
+	vals := []float64{1, 2, 4, 8, 16, 32}
+	for i, v := range vals {
+		op.Update(&all, []float64{v})
+		if i < 2 {
+			op.Update(&left, []float64{v})
+		} else {
+			op.Update(&right, []float64{v})
+		}
+	}
+	op.Combine(&right, &left)
+	assert.Equal(t, all._count, left._count)
+	assert.InDelta(t, all._mean, left._mean, 1e-9)
+	assert.InDelta(t, all._m2, left._m2, 1e-9)
+	assert.InDelta(t, 140.7, op.Finalize(&left, common.DoubleType()).F64, 1e-9)
+}
+
+func Test_RegrCombine(t *testing.T) {
+	op := RegrOp{_kind: REGR_SLOPE}
+	all, left, right := RegrState{}, RegrState{}, RegrState{}
+	xs := []float64{1, 2, 3, 4, 5}
+	for i, x := range xs {
+		row := []float64{2*x + 1, x}
+		op.Update(&all, row)
+		if i < 3 {
+			op.Update(&left, row)
+		} else {
+			op.Update(&right, row)
+		}
+	}
+	op.Combine(&right, &left)
+	assert.InDelta(t, 2.0, op.Finalize(&left, common.DoubleType()).F64, 1e-9)
+	assert.InDelta(t, 1.0, RegrOp{_kind: REGR_INTERCEPT}.Finalize(&left, common.DoubleType()).F64, 1e-9)
+	assert.InDelta(t, 1.0, RegrOp{_kind: CORR}.Finalize(&all, common.DoubleType()).F64, 1e-9)
+}
+
+func Test_bindAggrError(t *testing.T) {
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{"select stddev(cast(a as varchar)) from " + groupingTable, "stddev does not support type VARCHAR"},
+		{"select quantile(a, 2) from " + groupingTable, "out of range"},
+		{"select quantile(a, b) from " + groupingTable, "must be a constant"},
+		{"select percentile_disc(1.5) within group (order by a) from " + groupingTable, "out of range"},
+	}
+	for _, tt := range tests {
+		_, err := bindSelectSQL(t, tt.sql)
+		assert.ErrorContains(t, err, tt.err, tt.sql)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"unsafe"
 
 	"github.com/daviszhen/plan/pkg/chunk"
@@ -67,6 +68,28 @@ func (gs GroupingSet) count() int {
 	return len(gs)
 }
 
+func (gs GroupingSet) union(o GroupingSet) GroupingSet {
+	ret := make(GroupingSet)
+	for id := range gs {
+		ret.insert(id)
+	}
+	for id := range o {
+		ret.insert(id)
+	}
+	return ret
+}
+
+func groupingSetsString(sets []GroupingSet) string {
+	sb := strings.Builder{}
+	for i, set := range sets {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("%v", set.ordered()))
+	}
+	return sb.String()
+}
+
 type GroupedAggrData struct {
 	_groups              []*Expr
 	_groupingFuncs       [][]int //GROUPING functions
@@ -349,6 +372,7 @@ type HashAggr struct {
 	_nonDistinctFilter      []int
 	_distinctFilter         []int
 	_printHash              bool
+	_ctxCheck               ctxCheck
 }
 
 func NewHashAggr(
@@ -401,6 +425,33 @@ func NewHashAggr(
 	return ha
 }
 
+// SetMemoryLimit sets the memory budget of the hash tables.
+// The aggregate with the distinct does not spill.
+func (haggr *HashAggr) SetMemoryLimit(limit int) {
+	if haggr._distinctCollectionInfo != nil {
+		return
+	}
+	for _, grouping := range haggr._groupings {
+		grouping._tableData._memoryLimit = limit
+	}
+}
+
+// SetCtxCheck sets the cancellation check of the finalize
+// and the aggregation of the spilled partitions.
+func (haggr *HashAggr) SetCtxCheck(check ctxCheck) {
+	haggr._ctxCheck = check
+	for _, grouping := range haggr._groupings {
+		grouping._tableData._ctxCheck = check
+	}
+}
+
+// Close releases the hash tables
+func (haggr *HashAggr) Close() {
+	for _, grouping := range haggr._groupings {
+		grouping._tableData.Close()
+	}
+}
+
 // TODO: add project on aggregate
 func createGroupChunkTypes(groups []*Expr) []common.LType {
 	if len(groups) == 0 {
@@ -480,6 +531,28 @@ func (haggr *HashAggr) Sink(data *chunk.Chunk) {
 	}
 }
 
+// Combine merges the hash tables of the other into the haggr.
+// The other is the local state of the worker on the same aggregates.
+func (haggr *HashAggr) Combine(other *HashAggr) error {
+	for i, grouping := range haggr._groupings {
+		err := haggr._ctxCheck.err()
+		if err != nil {
+			return err
+		}
+		local := other._groupings[i]
+		if grouping._distinctData != nil {
+			for j, radixTable := range grouping._distinctData._radixTables {
+				if radixTable == nil {
+					continue
+				}
+				radixTable.Combine(local._distinctData._radixTables[j])
+			}
+		}
+		grouping._tableData.Combine(local._tableData)
+	}
+	return nil
+}
+
 func (haggr *HashAggr) FetechAggregates(state *HashAggrScanState, groups, output *chunk.Chunk) OperatorResult {
 	//1. table_data.GetData
 	for {
@@ -533,21 +606,29 @@ func (haggr *HashAggr) GetData(state *HashAggrScanState, output, rawInput *chunk
 	}
 }
 
-func (haggr *HashAggr) Finalize() {
-	haggr.FinalizeInternal(true)
+func (haggr *HashAggr) Finalize() error {
+	return haggr.FinalizeInternal(true)
 }
 
-func (haggr *HashAggr) FinalizeInternal(checkDistinct bool) {
+func (haggr *HashAggr) FinalizeInternal(checkDistinct bool) error {
 	if checkDistinct && haggr._distinctCollectionInfo != nil {
-		haggr.FinalizeDistinct()
+		err := haggr.FinalizeDistinct()
+		if err != nil {
+			return err
+		}
 	}
 	for i := 0; i < len(haggr._groupings); i++ {
+		err := haggr._ctxCheck.err()
+		if err != nil {
+			return err
+		}
 		grouping := haggr._groupings[i]
 		grouping._tableData.Finalize()
 	}
+	return nil
 }
 
-func (haggr *HashAggr) FinalizeDistinct() {
+func (haggr *HashAggr) FinalizeDistinct() error {
 	for i := 0; i < len(haggr._groupings); i++ {
 		grouping := haggr._groupings[i]
 		distinctData := grouping._distinctData
@@ -564,14 +645,18 @@ func (haggr *HashAggr) FinalizeDistinct() {
 	//finish distinct
 	for i := 0; i < len(haggr._groupings); i++ {
 		grouping := haggr._groupings[i]
-		haggr.DistinctGrouping(grouping, i)
+		err := haggr.DistinctGrouping(grouping, i)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (haggr *HashAggr) DistinctGrouping(
 	groupingData *HashAggrGroupingData,
 	groupingIdx int,
-) {
+) error {
 	aggregates := haggr._distinctCollectionInfo._aggregates
 	data := groupingData._distinctData
 
@@ -618,6 +703,10 @@ func (haggr *HashAggr) DistinctGrouping(
 		scanState := &TupleDataScanState{}
 
 		for {
+			err := haggr._ctxCheck.err()
+			if err != nil {
+				return err
+			}
 			outputChunk.Reset()
 			groupChunk.Reset()
 			aggrInputChunk.Reset()
@@ -650,6 +739,7 @@ func (haggr *HashAggr) DistinctGrouping(
 			groupingData._tableData.Sink(groupChunk, aggrInputChunk, childrenChunk, []int{i})
 		}
 	}
+	return nil
 }
 
 const (
@@ -720,6 +810,25 @@ type RadixPartitionedHashTable struct {
 	_finalizedHT     *GroupedAggrHashTable
 	_printHash       bool
 	_finalized       bool
+
+	//the memory budget of the hash table in bytes. 0 denotes no limit
+	_memoryLimit int
+	//the partition of the group is the radix of its hash on the level
+	_radixLevel int
+	//the new groups go into the partitions after the hash table
+	//exceeds the memory budget. nil if there is no spilled group.
+	_partitions []*spillWriter
+	_filter     []int
+	//the hash table is scanned
+	_tableScanned bool
+	//the partition in the scan
+	_partitionIdx   int
+	_partitionHT    *RadixPartitionedHashTable
+	_partitionState *TupleDataScanState
+	_ctxCheck       ctxCheck
+	//the partitions of the combined hash tables.
+	//they are sunk again in the finalize.
+	_combined []*spillWriter
 }
 
 func NewRadixPartitionedHashTable(
@@ -740,10 +849,11 @@ func NewRadixPartitionedHashTable(
 	ret._radixLimit = 10000
 
 	if ret._groupingSet.empty() {
-		ret._groupTypes = append(ret._groupTypes, common.TinyintType())
+		//group by a constant for the empty grouping set
+		ret._groupTypes = append(ret._groupTypes, common.IntegerType())
 	}
 
-	for ent := range ret._groupingSet.ordered() {
+	for _, ent := range ret._groupingSet.ordered() {
 		util.AssertFunc(ent < len(ret._groupedAggrData._groupTypes))
 		ret._groupTypes = append(ret._groupTypes,
 			ret._groupedAggrData._groupTypes[ent])
@@ -756,45 +866,70 @@ func (rpht *RadixPartitionedHashTable) SetGroupingValues() {
 	groupFuncs := rpht._groupedAggrData._groupingFuncs
 	for _, group := range groupFuncs {
 		util.AssertFunc(len(group) < 64)
+		groupingValue := int64(0)
 		for i, gval := range group {
-			groupingValue := int64(0)
 			if !rpht._groupingSet.find(gval) {
 				//do not group on this value
 				groupingValue += 1 << (len(group) - (i + 1))
 			}
-			rpht._groupingValues = append(rpht._groupingValues,
-				&chunk.Value{
-					Typ: common.BigintType(),
-					I64: groupingValue,
-				})
 		}
+		rpht._groupingValues = append(rpht._groupingValues,
+			&chunk.Value{
+				Typ: common.BigintType(),
+				I64: groupingValue,
+			})
+	}
+}
 
+func (rpht *RadixPartitionedHashTable) initHT() {
+	if rpht._finalizedHT != nil {
+		return
 	}
+	fmt.Println("init aggregate finalize ht")
+	//prepare aggr objs
+	aggrObjs := CreateAggrObjects(rpht._groupedAggrData._bindings)
+
+	rpht._finalizedHT = NewGroupedAggrHashTable(
+		rpht._groupTypes,
+		rpht._groupedAggrData._payloadTypes,
+		rpht._groupedAggrData._childrenOutputTypes,
+		aggrObjs,
+		2*util.DefaultVectorSize,
+		storage.GBufferMgr,
+	)
+	rpht._finalizedHT._printHash = rpht._printHash
 }
 
 func (rpht *RadixPartitionedHashTable) Sink(data, payload, childrenOutput *chunk.Chunk, filter []int) {
-	if rpht._finalizedHT == nil {
-		fmt.Println("init aggregate finalize ht")
-		//prepare aggr objs
-		aggrObjs := CreateAggrObjects(rpht._groupedAggrData._bindings)
-
-		rpht._finalizedHT = NewGroupedAggrHashTable(
-			rpht._groupTypes,
-			rpht._groupedAggrData._payloadTypes,
-			rpht._groupedAggrData._childrenOutputTypes,
-			aggrObjs,
-			2*util.DefaultVectorSize,
-			storage.GBufferMgr,
-		)
-		rpht._finalizedHT._printHash = rpht._printHash
-	}
+	rpht.initHT()
 	groupChunk := &chunk.Chunk{}
 	groupChunk.Init(rpht._groupTypes, util.DefaultVectorSize)
 	for i, idx := range rpht._groupingSet.ordered() {
 		groupChunk.Data[i].Reference(data.Data[idx])
 	}
+	if rpht._groupingSet.empty() {
+		groupChunk.Data[0].ReferenceValue(&chunk.Value{
+			Typ: common.IntegerType(),
+			I64: 1,
+		})
+	}
 	groupChunk.SetCard(data.Card())
 	state := NewAggrHTAppendState()
+	if rpht._partitions == nil && rpht.exceedMemoryLimit() {
+		rpht._partitions = make([]*spillWriter, radixPartitions)
+		rpht._filter = filter
+	}
+	if rpht._partitions != nil {
+		sel, count := rpht.spill(state, groupChunk, data, payload, childrenOutput)
+		if count == 0 {
+			return
+		}
+		if sel != nil {
+			groupChunk = sliceChunk(groupChunk, sel, count)
+			payload = sliceChunk(payload, sel, count)
+			childrenOutput = sliceChunk(childrenOutput, sel, count)
+		}
+	}
 	rpht._finalizedHT.AddChunk2(
 		state,
 		groupChunk,
@@ -804,6 +939,115 @@ func (rpht *RadixPartitionedHashTable) Sink(data, payload, childrenOutput *chunk
 	)
 }
 
+// Combine merges the groups of the other into the rpht.
+// The groups in the hash table of the other are merged by the states.
+// The partitions of the other are moved into the rpht. Their rows are
+// sunk in the finalize after all hash tables are merged. Then the groups
+// in the partitions are not in the hash table.
+func (rpht *RadixPartitionedHashTable) Combine(other *RadixPartitionedHashTable) {
+	util.AssertFunc(!rpht._finalized)
+	if other._finalizedHT == nil {
+		return
+	}
+	other.Finalize()
+	rpht.initHT()
+	rpht._finalizedHT.Combine(other._finalizedHT)
+	for _, part := range other._partitions {
+		if part != nil {
+			rpht._combined = append(rpht._combined, part)
+		}
+	}
+	if other._partitions != nil {
+		rpht._filter = other._filter
+	}
+	other._partitions = nil
+}
+
+const (
+	radixBits       = 4
+	radixPartitions = 1 << radixBits
+	//the bits above are the salt of the hash table
+	radixShift    = (HASH_WIDTH-2)*8 - radixBits
+	maxRadixLevel = 4
+)
+
+func radixPartition(hash uint64, level int) int {
+	return int(hash>>(radixShift-level*radixBits)) & (radixPartitions - 1)
+}
+
+func (rpht *RadixPartitionedHashTable) exceedMemoryLimit() bool {
+	return rpht._memoryLimit > 0 &&
+		rpht._radixLevel < maxRadixLevel &&
+		rpht._finalizedHT.SizeInBytes() > rpht._memoryLimit
+}
+
+// spill writes the rows of the new groups into the partitions.
+// The groups in the hash table are still aggregated in it.
+// It returns the selection of the rows for the hash table and the count of them.
+// The selection is nil if all rows are for the hash table.
+func (rpht *RadixPartitionedHashTable) spill(
+	state *AggrHTAppendState,
+	groupChunk, data, payload, childrenOutput *chunk.Chunk,
+) (*chunk.SelectVector, int) {
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	groupChunk.Hash(hashes)
+	missing := chunk.NewSelectVector(util.DefaultVectorSize)
+	missingCount := rpht._finalizedHT.FindGroups(state, groupChunk, hashes, childrenOutput, missing)
+	if missingCount == 0 {
+		return nil, data.Card()
+	}
+
+	hashesSlice := chunk.GetSliceInPhyFormatFlat[uint64](hashes)
+	spilled := make([]bool, data.Card())
+	sels := make([]*chunk.SelectVector, radixPartitions)
+	counts := make([]int, radixPartitions)
+	for i := 0; i < missingCount; i++ {
+		idx := missing.GetIndex(i)
+		spilled[idx] = true
+		part := radixPartition(hashesSlice[idx], rpht._radixLevel)
+		if sels[part] == nil {
+			sels[part] = chunk.NewSelectVector(util.DefaultVectorSize)
+		}
+		sels[part].SetIndex(counts[part], idx)
+		counts[part]++
+	}
+	for part, sel := range sels {
+		if sel == nil {
+			continue
+		}
+		if rpht._partitions[part] == nil {
+			rpht._partitions[part] = newSpillWriter(rpht._finalizedHT._bufMgr)
+		}
+		for _, src := range []*chunk.Chunk{data, payload, childrenOutput} {
+			err := rpht._partitions[part].Append(sliceChunk(src, sel, counts[part]))
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	count := 0
+	for i := 0; i < data.Card(); i++ {
+		if !spilled[i] {
+			sel.SetIndex(count, i)
+			count++
+		}
+	}
+	return sel, count
+}
+
+func sliceChunk(src *chunk.Chunk, sel *chunk.SelectVector, count int) *chunk.Chunk {
+	typs := make([]common.LType, src.ColumnCount())
+	for i, vec := range src.Data {
+		typs[i] = vec.Typ()
+	}
+	ret := &chunk.Chunk{}
+	ret.Init(typs, util.DefaultVectorSize)
+	ret.Slice(src, sel, count, 0)
+	return ret
+}
+
 func (rpht *RadixPartitionedHashTable) FetchAggregates(groups, result *chunk.Chunk) {
 	groupChunk := &chunk.Chunk{}
 	groupChunk.Init(rpht._groupTypes, util.DefaultVectorSize)
@@ -815,6 +1059,84 @@ func (rpht *RadixPartitionedHashTable) FetchAggregates(groups, result *chunk.Chu
 }
 
 func (rpht *RadixPartitionedHashTable) GetData(state *TupleDataScanState, output, childrenOutput *chunk.Chunk) OperatorResult {
+	if !rpht._tableScanned {
+		res := rpht.getTableData(state, output, childrenOutput)
+		if res == haveMoreOutput || rpht._partitions == nil {
+			return res
+		}
+		rpht._tableScanned = true
+		//the groups in the partitions are not in the hash table
+		rpht._finalizedHT.Close()
+	}
+	return rpht.getPartitionData(output, childrenOutput)
+}
+
+// getPartitionData aggregates the partitions one by one
+func (rpht *RadixPartitionedHashTable) getPartitionData(output, childrenOutput *chunk.Chunk) OperatorResult {
+	for rpht._partitionIdx < len(rpht._partitions) {
+		part := rpht._partitions[rpht._partitionIdx]
+		if part == nil {
+			rpht._partitionIdx++
+			continue
+		}
+		if rpht._partitionHT == nil {
+			rpht._partitionHT = rpht.aggregatePartition(part)
+			rpht._partitionState = &TupleDataScanState{}
+		}
+		res := rpht._partitionHT.GetData(rpht._partitionState, output, childrenOutput)
+		if res == haveMoreOutput {
+			return res
+		}
+		rpht._partitionHT.Close()
+		rpht._partitionHT = nil
+		rpht._partitions[rpht._partitionIdx] = nil
+		rpht._partitionIdx++
+	}
+	return Done
+}
+
+// aggregatePartition aggregates the groups in the partition.
+// The partition spills also if it exceeds the memory budget.
+func (rpht *RadixPartitionedHashTable) aggregatePartition(part *spillWriter) *RadixPartitionedHashTable {
+	ret := NewRadixPartitionedHashTable(rpht._groupingSet, rpht._groupedAggrData)
+	ret._memoryLimit = rpht._memoryLimit
+	ret._radixLevel = rpht._radixLevel + 1
+	ret._ctxCheck = rpht._ctxCheck
+	ret.sinkPartition(part, rpht._filter)
+	ret.Finalize()
+	return ret
+}
+
+// sinkPartition sinks the rows in the partition
+func (rpht *RadixPartitionedHashTable) sinkPartition(part *spillWriter, filter []int) {
+	reader := newSpillReader(part)
+	defer reader.Close()
+	for {
+		err := rpht._ctxCheck.err()
+		if err != nil {
+			panic(err)
+		}
+		data := &chunk.Chunk{}
+		err = reader.Next(data)
+		if err != nil {
+			panic(err)
+		}
+		if data.Card() == 0 {
+			break
+		}
+		payload := &chunk.Chunk{}
+		childrenOutput := &chunk.Chunk{}
+		for _, dst := range []*chunk.Chunk{payload, childrenOutput} {
+			err = reader.Next(dst)
+			if err != nil {
+				panic(err)
+			}
+		}
+		rpht.Sink(data, payload, childrenOutput, filter)
+	}
+}
+
+func (rpht *RadixPartitionedHashTable) getTableData(state *TupleDataScanState, output, childrenOutput *chunk.Chunk) OperatorResult {
 	if !state._init {
 		if rpht._finalizedHT == nil {
 			return Done
@@ -842,7 +1164,7 @@ func (rpht *RadixPartitionedHashTable) GetData(state *TupleDataScanState, output
 		output.Data[ent].Reference(scanChunk.Data[i])
 	}
 
-	for ent := range rpht._nullGroups {
+	for _, ent := range rpht._nullGroups {
 		output.Data[ent].SetPhyFormat(chunk.PF_CONST)
 		chunk.SetNullInPhyFormatConst(output.Data[ent], true)
 	}
@@ -872,8 +1194,47 @@ func (rpht *RadixPartitionedHashTable) GetData(state *TupleDataScanState, output
 
 func (rpht *RadixPartitionedHashTable) Finalize() {
 	util.AssertFunc(!rpht._finalized)
+	for len(rpht._combined) != 0 {
+		part := rpht._combined[0]
+		rpht._combined = rpht._combined[1:]
+		rpht.sinkPartition(part, rpht._filter)
+		part.Destroy()
+	}
 	rpht._finalized = true
+	if rpht._finalizedHT == nil {
+		return
+	}
 	rpht._finalizedHT.Finalize()
+	for _, part := range rpht._partitions {
+		if part == nil {
+			continue
+		}
+		err := part.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Close releases the memory and the spilled partitions
+func (rpht *RadixPartitionedHashTable) Close() {
+	if rpht._finalizedHT != nil {
+		rpht._finalizedHT.Close()
+	}
+	if rpht._partitionHT != nil {
+		rpht._partitionHT.Close()
+		rpht._partitionHT = nil
+	}
+	for _, part := range rpht._partitions {
+		if part != nil {
+			part.Destroy()
+		}
+	}
+	rpht._partitions = nil
+	for _, part := range rpht._combined {
+		part.Destroy()
+	}
+	rpht._combined = nil
 }
 
 type TupleDataScanState struct {
@@ -902,6 +1263,7 @@ type GroupedAggrHashTable struct {
 	_dataCollection  *TupleDataCollection
 	_pinState        *TupleDataPinState
 	_payloadHdsPtrs  []unsafe.Pointer
+	_hashesBlock     *storage.BlockHandle
 	_hashesHdl       *storage.BufferHandle
 	_hashesHdlPtr    unsafe.Pointer
 	_hashOffset      int
@@ -927,6 +1289,8 @@ type AggrObject struct {
 	_payloadSize int
 	_aggrType    AggrType
 	_retType     common.PhyType
+	//the last child is the FILTER clause
+	_filtered bool
 }
 
 func NewAggrObject(aggr *Expr) *AggrObject {
@@ -934,6 +1298,7 @@ func NewAggrObject(aggr *Expr) *AggrObject {
 	ret := new(AggrObject)
 	ret._childCount = len(aggr.Children)
 	ret._aggrType = aggr.AggrTyp
+	ret._filtered = aggr.HasFilter
 	ret._retType = aggr.DataTyp.GetInternalType()
 	ret._name = aggr.Svalue
 	ret._func = aggr.FunImpl
@@ -1018,7 +1383,7 @@ func NewGroupedAggrHashTable(
 	ret._dataCollection.InitAppend(ret._pinState, PIN_PRRP_KEEP_PINNED)
 
 	//allocate hash header
-	ret._hashesHdl = ret._bufMgr.Allocate(storage.BLOCK_SIZE, true, nil)
+	ret._hashesHdl = ret._bufMgr.Allocate(storage.BLOCK_SIZE, true, &ret._hashesBlock)
 	ret._hashesHdlPtr = ret._hashesHdl.Ptr()
 	ret._hashPrefixShift = (HASH_WIDTH - 2) * 8
 	ret.Resize(initCap)
@@ -1080,13 +1445,23 @@ func (aht *GroupedAggrHashTable) AddChunk(
 			continue
 		}
 		util.AssertFunc(i == filter[filterIdx])
-		UpdateStates(
-			aggr,
-			state._addresses,
-			payload,
-			payloadIdx,
-			payload.Card(),
-		)
+		if aggr._filtered {
+			UpdateFilteredStates(
+				aggr,
+				state._addresses,
+				payload,
+				payloadIdx,
+				payload.Card(),
+			)
+		} else {
+			UpdateStates(
+				aggr,
+				state._addresses,
+				payload,
+				payloadIdx,
+				payload.Card(),
+			)
+		}
 		payloadIdx += aggr._childCount
 		AddInPlace(state._addresses, int64(aggr._payloadSize), payload.Card())
 		filterIdx++
@@ -1116,52 +1491,11 @@ func (aht *GroupedAggrHashTable) FindOrCreateGroups(
 		aht.Resize(aht._capacity * 2)
 	}
 	util.AssertFunc(aht._capacity-aht.Count() >= groups.Card())
-	groupHashes.Flatten(groups.Card())
-	groupHashesSlice := chunk.GetSliceInPhyFormatFlat[uint64](groupHashes)
+	groupHashesSlice, htOffsetsPtr, hashSaltsPtr := aht.prepareGroups(state, groups, groupHashes, childrenOutput)
 
 	addresses.Flatten(groups.Card())
 	addresessSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](addresses)
-
-	htOffsetsPtr := chunk.GetSliceInPhyFormatFlat[uint64](state._htOffsets)
-	hashSaltsPtr := chunk.GetSliceInPhyFormatFlat[uint16](state._hashSalts)
-	for i := 0; i < groups.Card(); i++ {
-		ele := groupHashesSlice[i]
-		util.AssertFunc((ele & aht._bitmask) == (ele % uint64(aht._capacity)))
-		htOffsetsPtr[i] = ele & aht._bitmask
-		hashSaltsPtr[i] = uint16(ele >> aht._hashPrefixShift)
-	}
-
 	selVec := chunk.IncrSelectVectorInPhyFormatFlat()
-	if state._groupChunk.ColumnCount() == 0 {
-		state._groupChunk.Init(aht._layout.types(), util.DefaultVectorSize)
-	}
-
-	util.AssertFunc(state._groupChunk.ColumnCount() ==
-		len(aht._layout.types()))
-
-	for i := 0; i < groups.ColumnCount(); i++ {
-		state._groupChunk.Data[i].Reference(groups.Data[i])
-	}
-
-	state._groupChunk.Data[groups.ColumnCount()].Reference(groupHashes)
-	state._groupChunk.SetCard(groups.Card())
-
-	//prepare data structure holding incoming Chunk
-	state._chunkState = NewTupleDataChunkState(aht._layout.columnCount(), aht._layout.childrenOutputCount())
-
-	//groupChunk converted into chunkstate.data unified format
-	toUnifiedFormat(state._chunkState, state._groupChunk)
-	toUnifiedFormatForChildrenOutput(state._chunkState, childrenOutput)
-
-	if state._groupData == nil {
-		state._groupData = make([]*chunk.UnifiedFormat, state._groupChunk.ColumnCount())
-		for i := 0; i < state._groupChunk.ColumnCount(); i++ {
-			state._groupData[i] = &chunk.UnifiedFormat{}
-		}
-	}
-
-	//group data refers to the chunk state.data unified format
-	getVectorData(state._chunkState, state._groupData)
 
 	newGroupCount := 0
 	remainingEntries := groups.Card()
@@ -1286,6 +1620,139 @@ func (aht *GroupedAggrHashTable) FindOrCreateGroups(
 	return newGroupCount
 }
 
+// prepareGroups computes the offsets and the salts of the groups in the hash table
+// and converts the groups into the unified format.
+func (aht *GroupedAggrHashTable) prepareGroups(
+	state *AggrHTAppendState,
+	groups *chunk.Chunk,
+	groupHashes *chunk.Vector,
+	childrenOutput *chunk.Chunk,
+) ([]uint64, []uint64, []uint16) {
+	groupHashes.Flatten(groups.Card())
+	groupHashesSlice := chunk.GetSliceInPhyFormatFlat[uint64](groupHashes)
+
+	htOffsetsPtr := chunk.GetSliceInPhyFormatFlat[uint64](state._htOffsets)
+	hashSaltsPtr := chunk.GetSliceInPhyFormatFlat[uint16](state._hashSalts)
+	for i := 0; i < groups.Card(); i++ {
+		ele := groupHashesSlice[i]
+		util.AssertFunc((ele & aht._bitmask) == (ele % uint64(aht._capacity)))
+		htOffsetsPtr[i] = ele & aht._bitmask
+		hashSaltsPtr[i] = uint16(ele >> aht._hashPrefixShift)
+	}
+
+	if state._groupChunk.ColumnCount() == 0 {
+		state._groupChunk.Init(aht._layout.types(), util.DefaultVectorSize)
+	}
+
+	util.AssertFunc(state._groupChunk.ColumnCount() ==
+		len(aht._layout.types()))
+
+	for i := 0; i < groups.ColumnCount(); i++ {
+		state._groupChunk.Data[i].Reference(groups.Data[i])
+	}
+
+	state._groupChunk.Data[groups.ColumnCount()].Reference(groupHashes)
+	state._groupChunk.SetCard(groups.Card())
+
+	//prepare data structure holding incoming Chunk
+	state._chunkState = NewTupleDataChunkState(aht._layout.columnCount(), aht._layout.childrenOutputCount())
+
+	//groupChunk converted into chunkstate.data unified format
+	toUnifiedFormat(state._chunkState, state._groupChunk)
+	toUnifiedFormatForChildrenOutput(state._chunkState, childrenOutput)
+
+	if state._groupData == nil {
+		state._groupData = make([]*chunk.UnifiedFormat, state._groupChunk.ColumnCount())
+		for i := 0; i < state._groupChunk.ColumnCount(); i++ {
+			state._groupData[i] = &chunk.UnifiedFormat{}
+		}
+	}
+
+	//group data refers to the chunk state.data unified format
+	getVectorData(state._chunkState, state._groupData)
+	return groupHashesSlice, htOffsetsPtr, hashSaltsPtr
+}
+
+// FindGroups looks up the groups without creating the new groups.
+// The rows that have no group in the hash table are put into the missing.
+// It returns the count of them.
+func (aht *GroupedAggrHashTable) FindGroups(
+	state *AggrHTAppendState,
+	groups *chunk.Chunk,
+	groupHashes *chunk.Vector,
+	childrenOutput *chunk.Chunk,
+	missing *chunk.SelectVector,
+) int {
+	util.AssertFunc(!aht._finalized)
+	util.AssertFunc(groups.ColumnCount()+1 == aht._layout.columnCount())
+	if groups.Card() == 0 {
+		return 0
+	}
+	_, htOffsetsPtr, hashSaltsPtr := aht.prepareGroups(state, groups, groupHashes, childrenOutput)
+
+	addresses := state._addresses
+	addresses.Flatten(groups.Card())
+	addresessSlice := chunk.GetSliceInPhyFormatFlat[unsafe.Pointer](addresses)
+	selVec := chunk.IncrSelectVectorInPhyFormatFlat()
+
+	missingCount := 0
+	remainingEntries := groups.Card()
+	htEntrySlice := util.PointerToSlice[aggrHTEntry](aht._hashesHdlPtr, aht._capacity)
+	for remainingEntries > 0 {
+		needCompareCount := 0
+		noMatchCount := 0
+		for i := 0; i < remainingEntries; i++ {
+			idx := selVec.GetIndex(i)
+			htEntry := &htEntrySlice[htOffsetsPtr[idx]]
+			if htEntry._pageNr == 0 {
+				//no such group
+				missing.SetIndex(missingCount, idx)
+				missingCount++
+			} else if htEntry._salt == hashSaltsPtr[idx] {
+				state._groupCompareVector.SetIndex(needCompareCount, idx)
+				needCompareCount++
+			} else {
+				state._noMatchVector.SetIndex(noMatchCount, idx)
+				noMatchCount++
+			}
+		}
+
+		if needCompareCount > 0 {
+			for j := 0; j < needCompareCount; j++ {
+				idx := state._groupCompareVector.GetIndex(j)
+				htEntry := &htEntrySlice[htOffsetsPtr[idx]]
+				pagePtr := aht._payloadHdsPtrs[htEntry._pageNr-1]
+				pageOffset := int(htEntry._pageOffset) * aht._tupleSize
+				addresessSlice[idx] = util.PointerAdd(pagePtr, pageOffset)
+			}
+
+			Match(
+				state._groupChunk,
+				state._groupData,
+				aht._layout,
+				addresses,
+				aht._predicates,
+				state._groupCompareVector,
+				needCompareCount,
+				state._noMatchVector,
+				&noMatchCount,
+			)
+		}
+
+		for i := 0; i < noMatchCount; i++ {
+			idx := state._noMatchVector.GetIndex(i)
+			htOffsetsPtr[idx]++
+			if htOffsetsPtr[idx] >= uint64(aht._capacity) {
+				htOffsetsPtr[idx] = 0
+			}
+		}
+
+		selVec = state._noMatchVector
+		remainingEntries = noMatchCount
+	}
+	return missingCount
+}
+
 func (aht *GroupedAggrHashTable) FetchAggregates(groups, result *chunk.Chunk) {
 	util.AssertFunc(groups.ColumnCount()+1 == aht._layout.columnCount())
 	for i := 0; i < result.ColumnCount(); i++ {
@@ -1333,6 +1800,56 @@ func (aht *GroupedAggrHashTable) Scan(state *TupleDataScanState, result *chunk.C
 	return result.Card()
 }
 
+// Combine merges the groups and the aggregate states of the other.
+// The other is finalized.
+func (aht *GroupedAggrHashTable) Combine(other *GroupedAggrHashTable) {
+	util.AssertFunc(!aht._finalized)
+	if other.Count() == 0 {
+		return
+	}
+	//without the hash
+	groupCnt := other._layout.columnCount() - 1
+	groupTypes := other._layout.types()[:groupCnt]
+	scanTypes := append(common.CopyLTypes(groupTypes...), other._layout._childrenOutputTypes...)
+	state := &TupleDataScanState{}
+	for i := 0; i < groupCnt; i++ {
+		state._colIds = append(state._colIds, i)
+	}
+	other._dataCollection.InitScan(state)
+	appendState := NewAggrHTAppendState()
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	for {
+		rows := &chunk.Chunk{}
+		rows.Init(scanTypes, util.DefaultVectorSize)
+		if !other._dataCollection.Scan(state, rows) {
+			break
+		}
+		groups := &chunk.Chunk{}
+		groups.Init(groupTypes, util.DefaultVectorSize)
+		for i := range groups.Data {
+			groups.Data[i].Reference(rows.Data[i])
+		}
+		groups.SetCard(rows.Card())
+		childrenOutput := &chunk.Chunk{}
+		childrenOutput.Init(other._layout._childrenOutputTypes, util.DefaultVectorSize)
+		for i := range childrenOutput.Data {
+			childrenOutput.Data[i].Reference(rows.Data[groupCnt+i])
+		}
+		childrenOutput.SetCard(rows.Card())
+
+		groups.Hash(hashes)
+		aht.FindOrCreateGroups(
+			appendState,
+			groups,
+			hashes,
+			appendState._addresses,
+			appendState._newGroups,
+			childrenOutput,
+		)
+		CombineStates(aht._layout, state._chunkState._rowLocations, appendState._addresses, rows.Card())
+	}
+}
+
 func (aht *GroupedAggrHashTable) Resize(size int) {
 	util.AssertFunc(!aht._finalized)
 	util.AssertFunc(size >= util.DefaultVectorSize)
@@ -1344,7 +1861,8 @@ func (aht *GroupedAggrHashTable) Resize(size int) {
 	aht._bitmask = uint64(aht._capacity - 1)
 	byteSize := aht._capacity * aggrEntrySize
 	if byteSize > BLOCK_SIZE {
-		aht._hashesHdl = aht._bufMgr.Allocate(uint64(byteSize), true, nil)
+		aht._bufMgr.Destroy(aht._hashesBlock)
+		aht._hashesHdl = aht._bufMgr.Allocate(uint64(byteSize), true, &aht._hashesBlock)
 		aht._hashesHdlPtr = aht._hashesHdl.Ptr()
 	}
 	hashesArr := util.PointerToSlice[aggrHTEntry](aht._hashesHdlPtr, aht._capacity)
@@ -1431,6 +1949,49 @@ func (aht *GroupedAggrHashTable) Count() int {
 	return aht._dataCollection.Count()
 }
 
+// SizeInBytes returns the memory of the groups and the hash entries
+func (aht *GroupedAggrHashTable) SizeInBytes() int {
+	return aht._dataCollection.SizeInBytes() + aht._capacity*aggrEntrySize
+}
+
+// Close releases the memory of the hash table
+func (aht *GroupedAggrHashTable) Close() {
+	aht.destroyStates()
+	aht._dataCollection.Close()
+	aht._bufMgr.Destroy(aht._hashesBlock)
+	aht._payloadHdsPtrs = nil
+	aht._hashesHdl = nil
+	aht._hashesHdlPtr = nil
+}
+
+// destroyStates releases the aggregate states of the groups.
+// The states of the query that fails or stops early are not finalized.
+func (aht *GroupedAggrHashTable) destroyStates() {
+	hasDestructor := false
+	for _, aggr := range aht._layout._aggregates {
+		if aggr._func._destructor != nil {
+			hasDestructor = true
+		}
+	}
+	if !hasDestructor || aht.Count() == 0 {
+		return
+	}
+	collection := aht._dataCollection
+	pinState := &TupleDataPinState{
+		_rowHandles:  make(map[uint32]*storage.BufferHandle),
+		_heapHandles: make(map[uint32]*storage.BufferHandle),
+		_properties:  PIN_PRRP_KEEP_PINNED,
+	}
+	chunkState := NewTupleDataChunkState(aht._layout.columnCount(), aht._layout.childrenOutputCount())
+	for _, seg := range collection._segments {
+		for i, data := range seg._chunks {
+			seg._allocator.InitChunkState(seg, pinState, chunkState, i, false)
+			DestroyStates(aht._layout, chunkState._rowLocations, int(data._count))
+		}
+		collection.FinalizePinState2(pinState, seg)
+	}
+}
+
 func (aht *GroupedAggrHashTable) ResizeThreshold() int {
 	return int(float32(aht._capacity) / LOAD_FACTOR)
 }
@@ -1492,7 +2053,7 @@ func UpdateStates(
 	inputData := &AggrInputData{}
 	var input []*chunk.Vector
 	if aggr._childCount != 0 {
-		input = []*chunk.Vector{payload.Data[argIdx]}
+		input = payload.Data[argIdx : argIdx+aggr._childCount]
 	}
 	aggr._func._update(
 		input,
@@ -1502,3 +2063,46 @@ func UpdateStates(
 		cnt,
 	)
 }
+
+// UpdateFilteredStates updates the states with the rows
+// that pass the FILTER clause.
+func UpdateFilteredStates(
+	aggr *AggrObject,
+	addresses *chunk.Vector,
+	payload *chunk.Chunk,
+	argIdx int,
+	cnt int,
+) {
+	inputCount := aggr._childCount - 1
+	filter := payload.Data[argIdx+inputCount]
+	var fdata chunk.UnifiedFormat
+	filter.ToUnifiedFormat(cnt, &fdata)
+	filterSlice := chunk.GetSliceInPhyFormatUnifiedFormat[bool](&fdata)
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	selCnt := 0
+	for i := 0; i < cnt; i++ {
+		idx := fdata.Sel.GetIndex(i)
+		if fdata.Mask.RowIsValid(uint64(idx)) && filterSlice[idx] {
+			sel.SetIndex(selCnt, i)
+			selCnt++
+		}
+	}
+	if selCnt == 0 {
+		return
+	}
+
+	input := make([]*chunk.Vector, inputCount)
+	for i := 0; i < inputCount; i++ {
+		input[i] = chunk.NewVector(payload.Data[argIdx+i].Typ(), false, 0)
+		input[i].Slice(payload.Data[argIdx+i], sel, selCnt)
+	}
+	selAddresses := chunk.NewVector(addresses.Typ(), false, 0)
+	selAddresses.Slice(addresses, sel, selCnt)
+	aggr._func._update(
+		input,
+		&AggrInputData{},
+		inputCount,
+		selAddresses,
+		selCnt,
+	)
+}
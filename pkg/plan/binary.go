@@ -105,22 +105,38 @@ func binDateInterAddOp(left *common.Date, right *common.Interval, result *common
 
 //lint:ignore U1000
 func binDateInt32AddOp(left *common.Date, right *int32, result *common.Date) {
-	panic("usp")
+	*result = left.AddInterval(&common.Interval{Days: *right})
 }
 
 //lint:ignore U1000
 func binInt32DateAddOp(left *int32, right *common.Date, result *common.Date) {
-	panic("usp")
+	binDateInt32AddOp(right, left, result)
 }
 
 //lint:ignore U1000
 func binIntervalIntervalAddOp(left *common.Interval, right *common.Interval, result *common.Interval) {
-	panic("usp")
+	*result = common.Interval{
+		Months: left.Months + right.Months,
+		Days:   left.Days + right.Days,
+		Micros: left.Micros + right.Micros,
+	}
 }
 
 //lint:ignore U1000
 func binIntervalDateAddOp(left *common.Interval, right *common.Date, result *common.Date) {
-	panic("usp")
+	*result = right.AddInterval(left)
+}
+
+func binTimestampIntervalAddOp(left *int64, right *common.Interval, result *int64) {
+	*result = common.TimestampAddInterval(*left, right)
+}
+
+func binIntervalTimestampAddOp(left *common.Interval, right *int64, result *int64) {
+	*result = common.TimestampAddInterval(*right, left)
+}
+
+func binTimeIntervalAddOp(left *int64, right *common.Interval, result *int64) {
+	*result = common.TimestampToTimeOfDay(*left + right.Micros)
 }
 
 //lint:ignore U1000
@@ -150,6 +166,41 @@ func binDateInterSubOp(left *common.Date, right *common.Interval, result *common
 	*result = res
 }
 
+// binDateDateSubOp returns the days between the dates
+func binDateDateSubOp(left *common.Date, right *common.Date, result *int64) {
+	*result = (common.DateToTimestamp(left) - common.DateToTimestamp(right)) / common.MicrosPerDay
+}
+
+func binDateInt32SubOp(left *common.Date, right *int32, result *common.Date) {
+	*result = left.AddInterval(&common.Interval{Days: -*right})
+}
+
+func binIntervalIntervalSubOp(left *common.Interval, right *common.Interval, result *common.Interval) {
+	*result = common.Interval{
+		Months: left.Months - right.Months,
+		Days:   left.Days - right.Days,
+		Micros: left.Micros - right.Micros,
+	}
+}
+
+func binTimestampIntervalSubOp(left *int64, right *common.Interval, result *int64) {
+	neg := common.Interval{Months: -right.Months, Days: -right.Days, Micros: -right.Micros}
+	*result = common.TimestampAddInterval(*left, &neg)
+}
+
+// binTimestampTimestampSubOp returns the difference in days and micros
+func binTimestampTimestampSubOp(left *int64, right *int64, result *common.Interval) {
+	diff := *left - *right
+	*result = common.Interval{
+		Days:   int32(diff / common.MicrosPerDay),
+		Micros: diff % common.MicrosPerDay,
+	}
+}
+
+func binTimeIntervalSubOp(left *int64, right *common.Interval, result *int64) {
+	*result = common.TimestampToTimeOfDay(*left - right.Micros)
+}
+
 //lint:ignore U1000
 func binFloat32Float32SubOp(left *float32, right *float32, result *float32) {
 	*result = *left - *right
@@ -188,15 +239,34 @@ func binDecimalDecimalMulOp(left *common.Decimal, right *common.Decimal, result
 
 }
 
+// binInt64IntervalMulOp multiplies each part of the interval
+func binInt64IntervalMulOp(left *int64, right *common.Interval, result *common.Interval) {
+	*result = common.Interval{
+		Months: right.Months * int32(*left),
+		Days:   right.Days * int32(*left),
+		Micros: right.Micros * *left,
+	}
+}
+
+func binIntervalInt64MulOp(left *common.Interval, right *int64, result *common.Interval) {
+	binInt64IntervalMulOp(right, left, result)
+}
+
 // /
 //
 //lint:ignore U1000
 func binFloat32DivOp(left, right *float32, result *float32) {
+	if *right == 0 {
+		panic(errDivisionByZero)
+	}
 	*result = *left / *right
 }
 
 //lint:ignore U1000
 func binDecimalDivOp(left, right *common.Decimal, result *common.Decimal) {
+	if right.Decimal.IsZero() {
+		panic(errDivisionByZero)
+	}
 	quo, err := left.Decimal.Quo(right.Decimal)
 	if err != nil {
 		panic(err)
@@ -255,11 +325,7 @@ func binStringLikeOp(left, right *common.String, result *bool) {
 //
 //lint:ignore U1000
 func binStringInt32ExtractOp(left *common.String, right *common.Date, result *int32) {
-	if left.String() == "year" {
-		*result = right.Year
-	} else {
-		panic("usp")
-	}
+	*result = int32(timestampPart(left.String(), common.DateToTimestamp(right)))
 }
 
 func binaryExecSwitch[T any, S any, R any](
@@ -17,7 +17,9 @@ package plan
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
+	dec "github.com/govalues/decimal"
 	pg_query "github.com/pganalyze/pg_query_go/v5"
 
 	"github.com/daviszhen/plan/pkg/common"
@@ -43,6 +45,9 @@ func (b *Builder) bindExpr(
 	case *pg_query.Node_ResTarget:
 		panic("usp")
 	case *pg_query.Node_ColumnRef:
+		if len(realExpr.ColumnRef.Fields) > 2 {
+			return b.bindStructFieldRef(ctx, iwc, realExpr.ColumnRef, depth)
+		}
 		tableName, colName := getTableColumn(realExpr.ColumnRef)
 		switch iwc {
 		case IWC_WHERE:
@@ -60,6 +65,12 @@ func (b *Builder) bindExpr(
 		}
 		bind, d, err := ctx.GetMatchingBinding(tableName, colName)
 		if err != nil {
+			if tableName != "" {
+				//s.a where s is a struct column
+				if ret, err2 := b.bindStructFieldRef(ctx, iwc, realExpr.ColumnRef, depth); err2 == nil {
+					return ret, nil
+				}
+			}
 			return nil, err
 		}
 		colIdx := bind.HasColumn(colName)
@@ -94,13 +105,28 @@ func (b *Builder) bindExpr(
 	case *pg_query.Node_SortBy:
 		ret, err = b.bindSortBy(ctx, iwc, realExpr.SortBy, depth)
 	case *pg_query.Node_TypeCast:
-		ret, err = b.bindTypeCast(ctx, iwc, realExpr.TypeCast, depth)
+		ret, err = b.bindTypeCast(ctx, iwc, realExpr.TypeCast, false, depth)
 	case *pg_query.Node_List:
 		ret, err = b.bindList(ctx, iwc, realExpr.List, depth)
 	case *pg_query.Node_CaseExpr:
 		ret, err = b.bindCaseExpr(ctx, iwc, realExpr.CaseExpr, depth)
 	case *pg_query.Node_CaseWhen:
 		ret, err = b.bindCaseWhen(ctx, iwc, realExpr.CaseWhen, depth)
+	case *pg_query.Node_GroupingFunc:
+		ret, err = b.bindGroupingFunc(ctx, iwc, realExpr.GroupingFunc, depth)
+	case *pg_query.Node_MinMaxExpr:
+		ret, err = b.bindMinMaxExpr(ctx, iwc, realExpr.MinMaxExpr, depth)
+	case *pg_query.Node_SqlvalueFunction:
+		ret, err = b.bindSQLValueFunction(realExpr.SqlvalueFunction)
+	case *pg_query.Node_AArrayExpr:
+		ret, err = b.bindArrayExpr(ctx, iwc, realExpr.AArrayExpr, depth)
+	case *pg_query.Node_AIndirection:
+		ret, err = b.bindIndirection(ctx, iwc, realExpr.AIndirection, depth)
+	case *pg_query.Node_NamedArgExpr:
+		ret, err = b.bindExpr(ctx, iwc, realExpr.NamedArgExpr.Arg, depth)
+		if err == nil {
+			ret.Alias = realExpr.NamedArgExpr.Name
+		}
 	default:
 		panic(fmt.Sprintf("bindExpr: unexpected node type %T", realExpr))
 	}
@@ -122,7 +148,7 @@ func (b *Builder) bindList(ctx *BindContext, iwc InWhichClause, expr *pg_query.L
 	}, nil
 }
 
-func (b *Builder) bindTypeCast(ctx *BindContext, iwc InWhichClause, expr *pg_query.TypeCast, depth int) (*Expr, error) {
+func (b *Builder) bindTypeCast(ctx *BindContext, iwc InWhichClause, expr *pg_query.TypeCast, tryCast bool, depth int) (*Expr, error) {
 	var retExpr *Expr
 	var err error
 	var resultTyp common.LType
@@ -131,30 +157,240 @@ func (b *Builder) bindTypeCast(ctx *BindContext, iwc InWhichClause, expr *pg_que
 		return nil, err
 	}
 
-	//find type
-	typName := ""
-	for _, name := range expr.TypeName.Names {
-		if name.GetString_().Sval == "pg_catalog" {
+	resultTyp, err = typeNameToLType(expr.TypeName)
+	if err != nil {
+		return nil, err
+	}
+	if resultTyp.Id == common.LTID_DECIMAL &&
+		len(expr.TypeName.Typmods) == 0 &&
+		len(expr.TypeName.ArrayBounds) == 0 {
+		//numeric without precision and scale
+		resultTyp, err = decimalTypeOf(retExpr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//cast
+	retExpr, err = AddCastToType(retExpr, resultTyp, tryCast)
+	if err != nil {
+		return nil, err
+	}
+	return retExpr, nil
+}
+
+// decimalTypeOf decides the precision and scale of the CAST to the numeric
+// without them. the decimal keeps its type. the integer has no fraction.
+// the constant keeps all its digits.
+func decimalTypeOf(expr *Expr) (common.LType, error) {
+	var text string
+	switch expr.DataTyp.Id {
+	case common.LTID_DECIMAL:
+		return expr.DataTyp, nil
+	case common.LTID_BOOLEAN:
+		return common.DecimalType(1, 0), nil
+	case common.LTID_SMALLINT:
+		return common.DecimalType(5, 0), nil
+	case common.LTID_INTEGER:
+		return common.DecimalType(10, 0), nil
+	case common.LTID_BIGINT:
+		return common.DecimalType(common.DecimalMaxWidthInt64, 0), nil
+	}
+	switch expr.Typ {
+	case ET_SConst:
+		text = strings.TrimSpace(expr.Svalue)
+	case ET_FConst:
+		text = strconv.FormatFloat(expr.Fvalue, 'f', -1, 64)
+	default:
+		return common.LType{}, fmt.Errorf("cast %s to numeric needs the precision and scale",
+			expr.DataTyp.String())
+	}
+	val, err := dec.Parse(text)
+	if err != nil {
+		//the cast reports the invalid text
+		return common.DecimalType(common.DecimalMaxWidthInt64, 0), nil
+	}
+	//keep the integer digits first
+	width := common.DecimalMaxWidthInt64
+	scale := min(val.Scale(), max(width-(val.Prec()-val.Scale()), 0))
+	return common.DecimalType(width, scale), nil
+}
+
+// typeNameToLType converts the type name in the CAST into the logical type
+func typeNameToLType(typName *pg_query.TypeName) (common.LType, error) {
+	name := ""
+	for _, node := range typName.Names {
+		if node.GetString_().GetSval() == "pg_catalog" {
 			continue
 		}
-		typName = name.GetString_().GetSval()
+		name = node.GetString_().GetSval()
+	}
+	typMods := make([]int, 0)
+	for _, mod := range typName.GetTypmods() {
+		typMods = append(typMods, int(mod.GetAConst().GetIval().GetIval()))
 	}
 
-	switch typName {
+	var typ common.LType
+	var err error
+	lname := strings.ToLower(name)
+	if strings.HasPrefix(lname, "struct(") || strings.HasPrefix(lname, "map(") {
+		//the parser quotes the nested type name
+		typ, err = parseTypeString(name)
+	} else {
+		typ, err = lTypeByName(name, typMods)
+	}
+	if err != nil {
+		return common.LType{}, err
+	}
+	for range typName.ArrayBounds {
+		typ = common.ListType(typ)
+	}
+	return typ, nil
+}
+
+func lTypeByName(name string, typMods []int) (common.LType, error) {
+	switch strings.ToLower(name) {
+	case "int2", "smallint":
+		return common.SmallintType(), nil
+	case "int", "int4", "integer":
+		return common.IntegerType(), nil
+	case "int8", "bigint":
+		return common.BigintType(), nil
+	case "float4", "real":
+		return common.FloatType(), nil
+	case "float8", "double", "float":
+		return common.DoubleType(), nil
+	case "numeric", "decimal":
+		switch len(typMods) {
+		case 0:
+			return common.DecimalType(common.DecimalMaxWidthInt64, 3), nil
+		case 1:
+			return common.DecimalType(typMods[0], 0), nil
+		default:
+			return common.DecimalType(typMods[0], typMods[1]), nil
+		}
+	case "varchar", "text", "bpchar", "string":
+		return common.VarcharType(), nil
+	case "bool", "boolean":
+		return common.BooleanType(), nil
 	case "date":
-		resultTyp = common.DateType()
+		return common.DateType(), nil
+	case "time":
+		return common.TimeType(), nil
+	case "timestamp", "datetime":
+		return common.TimestampType(), nil
+	case "timestamptz":
+		return common.MakeLType(common.LTID_TIMESTAMP_TZ), nil
+	case "timestamp_s":
+		return common.MakeLType(common.LTID_TIMESTAMP_SEC), nil
+	case "timestamp_ms":
+		return common.MakeLType(common.LTID_TIMESTAMP_MS), nil
+	case "timestamp_ns":
+		return common.MakeLType(common.LTID_TIMESTAMP_NS), nil
 	case "interval":
-		resultTyp = common.IntervalType()
+		return common.IntervalType(), nil
+	case "bytea", "blob":
+		return common.MakeLType(common.LTID_BLOB), nil
+	case "uuid":
+		return common.MakeLType(common.LTID_UUID), nil
+	case "json":
+		return common.JSONType(), nil
 	default:
-		panic(fmt.Sprintf("usp typename %v", expr.TypeName))
+		return common.LType{}, fmt.Errorf("unsupported type %s", name)
 	}
+}
 
-	//cast
-	retExpr, err = AddCastToType(retExpr, resultTyp, resultTyp.Id == common.LTID_ENUM)
+// parseTypeString parses the type text like
+// STRUCT(a INT, b VARCHAR[]), MAP(VARCHAR, DECIMAL(10,2)).
+func parseTypeString(s string) (common.LType, error) {
+	s = strings.TrimSpace(s)
+	wraps := 0
+	for strings.HasSuffix(s, "[]") {
+		wraps++
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	var typ common.LType
+	var err error
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		typ, err = lTypeByName(s, nil)
+	} else {
+		if !strings.HasSuffix(s, ")") {
+			return common.LType{}, fmt.Errorf("invalid type %s", s)
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:open]))
+		args := splitTypeArgs(s[open+1 : len(s)-1])
+		switch name {
+		case "struct":
+			names := make([]string, 0, len(args))
+			typs := make([]common.LType, 0, len(args))
+			for _, arg := range args {
+				arg = strings.TrimSpace(arg)
+				sp := strings.IndexAny(arg, " \t\n")
+				if sp < 0 {
+					return common.LType{}, fmt.Errorf("invalid struct field %s", arg)
+				}
+				fieldTyp, err := parseTypeString(arg[sp+1:])
+				if err != nil {
+					return common.LType{}, err
+				}
+				names = append(names, strings.Trim(arg[:sp], `"`))
+				typs = append(typs, fieldTyp)
+			}
+			typ = common.StructType(names, typs)
+		case "map":
+			if len(args) != 2 {
+				return common.LType{}, fmt.Errorf("MAP requires key and value type")
+			}
+			key, err := parseTypeString(args[0])
+			if err != nil {
+				return common.LType{}, err
+			}
+			value, err := parseTypeString(args[1])
+			if err != nil {
+				return common.LType{}, err
+			}
+			typ = common.MapType(key, value)
+		default:
+			typMods := make([]int, 0, len(args))
+			for _, arg := range args {
+				mod, err := strconv.Atoi(strings.TrimSpace(arg))
+				if err != nil {
+					return common.LType{}, fmt.Errorf("invalid type %s", s)
+				}
+				typMods = append(typMods, mod)
+			}
+			typ, err = lTypeByName(name, typMods)
+		}
+	}
 	if err != nil {
-		return nil, err
+		return common.LType{}, err
 	}
-	return retExpr, nil
+	for i := 0; i < wraps; i++ {
+		typ = common.ListType(typ)
+	}
+	return typ, nil
+}
+
+// splitTypeArgs splits the text by the top level commas.
+func splitTypeArgs(s string) []string {
+	ret := make([]string, 0)
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, s[start:])
 }
 
 func (b *Builder) bindSortBy(ctx *BindContext, iwc InWhichClause, expr *pg_query.SortBy, depth int) (*Expr, error) {
@@ -200,6 +436,19 @@ func (b *Builder) bindFuncCall(ctx *BindContext, iwc InWhichClause, expr *pg_que
 	var err error
 	//real function
 	name := getFuncName(expr)
+	if macro := b.lookupMacro(expr.Funcname, false); macro != nil {
+		return b.bindMacro(ctx, iwc, macro, expr, depth)
+	}
+	if name == "try_cast" {
+		//try_cast(x AS t) is rewritten into try_cast(CAST(x AS t)) by the parser
+		if len(expr.Args) != 1 || expr.Args[0].GetTypeCast() == nil {
+			return nil, fmt.Errorf("try_cast requires the form TRY_CAST(expr AS type)")
+		}
+		return b.bindTypeCast(ctx, iwc, expr.Args[0].GetTypeCast(), true, depth)
+	}
+	if name == "unnest" {
+		return b.bindUnnest(ctx, iwc, expr, depth)
+	}
 	if name == "count" {
 		if expr.AggStar {
 			//replace * by the column 0 of the first table
@@ -235,6 +484,15 @@ func (b *Builder) bindFuncCall(ctx *BindContext, iwc InWhichClause, expr *pg_que
 		argsTypes = append(argsTypes, child.DataTyp)
 	}
 
+	if expr.AggWithinGroup || len(expr.AggOrder) != 0 || expr.AggFilter != nil {
+		if !IsAgg(name) {
+			return nil, fmt.Errorf("%s is not an aggregate function", name)
+		}
+		return b.bindOrderedAggrFunc(ctx, iwc, name, expr, args, depth)
+	} else if isOrderedSetAggr(name) {
+		return nil, fmt.Errorf("WITHIN GROUP is required for ordered-set aggregate %s", name)
+	}
+
 	ret, err = b.bindFunc(
 		name,
 		ET_SubFunc,
@@ -248,6 +506,222 @@ func (b *Builder) bindFuncCall(ctx *BindContext, iwc InWhichClause, expr *pg_que
 	return ret, nil
 }
 
+// isOrderedSetAggr decides the aggregate must be
+// used with WITHIN GROUP (ORDER BY ...)
+func isOrderedSetAggr(name string) bool {
+	switch name {
+	case "percentile_cont", "percentile_disc":
+		return true
+	default:
+		return false
+	}
+}
+
+// bindOrderedAggrFunc binds the aggregate with ORDER BY, WITHIN GROUP or FILTER.
+//
+// f(args) WITHIN GROUP (ORDER BY x) is bound as f(x, args).
+// f(args ORDER BY keys) sorts the rows of the group by the keys
+// before the aggregation.
+// The FILTER clause is the last child of the aggregate.
+func (b *Builder) bindOrderedAggrFunc(
+	ctx *BindContext,
+	iwc InWhichClause,
+	name string,
+	expr *pg_query.FuncCall,
+	args []*Expr,
+	depth int) (*Expr, error) {
+	var err error
+	var keys []*Expr
+	var desc, nullsFirst []bool
+	var frac float64
+	for _, node := range expr.AggOrder {
+		sortBy := node.GetSortBy()
+		key, err := b.bindSortBy(ctx, iwc, sortBy, depth)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.Children[0])
+		desc = append(desc, key.Desc)
+		switch sortBy.SortbyNulls {
+		case pg_query.SortByNulls_SORTBY_NULLS_FIRST:
+			nullsFirst = append(nullsFirst, true)
+		case pg_query.SortByNulls_SORTBY_NULLS_LAST:
+			nullsFirst = append(nullsFirst, false)
+		default:
+			nullsFirst = append(nullsFirst, key.Desc)
+		}
+	}
+
+	if expr.AggWithinGroup {
+		switch name {
+		case "percentile_cont", "percentile_disc", "mode":
+		default:
+			return nil, fmt.Errorf("WITHIN GROUP is not supported for %s", name)
+		}
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("%s requires exactly one ORDER BY in WITHIN GROUP", name)
+		}
+		if name != "mode" {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s requires one fraction argument", name)
+			}
+			frac, err = quantileFraction(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+		} else if len(args) != 0 {
+			return nil, fmt.Errorf("mode does not have arguments")
+		}
+		args = append([]*Expr{keys[0]}, args...)
+		keys = nil
+	}
+
+	funBinder := FunctionBinder{}
+	ret, err := funBinder.BindAggrFunc(name, args, ET_SubFunc, false)
+	if err != nil {
+		return nil, err
+	}
+	if expr.AggWithinGroup && name != "mode" && desc[0] {
+		//the fraction counts from the largest value
+		setQuantileOp(ret.FunImpl, QuantileOp{
+			_discrete: name == "percentile_disc",
+			_desc:     true,
+			_frac:     frac,
+		})
+	}
+	if expr.AggDistinct {
+		if len(keys) != 0 || expr.AggFilter != nil {
+			return nil, fmt.Errorf("DISTINCT with ORDER BY or FILTER is not supported for %s", name)
+		}
+		ret.AggrTyp = DISTINCT
+	}
+
+	if len(keys) != 0 {
+		keyTypes := make([]common.LType, 0, len(keys))
+		for _, key := range keys {
+			keyTypes = append(keyTypes, key.DataTyp)
+		}
+		ret.FunImpl = SortedAggregate(ret.FunImpl, keyTypes, desc, nullsFirst)
+		ret.Children = append(ret.Children, keys...)
+	}
+
+	if expr.AggFilter != nil {
+		var filter *Expr
+		filter, err = b.bindExpr(ctx, iwc, expr.AggFilter, depth)
+		if err != nil {
+			return nil, err
+		}
+		if filter.DataTyp.Id != common.LTID_BOOLEAN {
+			return nil, fmt.Errorf("argument of FILTER must be type boolean, not %s", filter.DataTyp)
+		}
+		ret.Children = append(ret.Children, filter)
+		ret.HasFilter = true
+	}
+
+	b.aggs = append(b.aggs, ret)
+	return &Expr{
+		Typ:     ET_Column,
+		DataTyp: ret.DataTyp,
+		Table:   fmt.Sprintf("AggNode_%v", b.aggTag),
+		Name:    expr.String(),
+		ColRef:  ColumnBind{uint64(b.aggTag), uint64(len(b.aggs) - 1)},
+	}, nil
+}
+
+// groupingFunc is the placeholder implementation of GROUPING.
+// the values of it are decided by the grouping sets in the hash aggr.
+var groupingFunc = &FunctionV2{
+	_name:    ET_Grouping.String(),
+	_retType: common.BigintType(),
+	_funcTyp: AggregateFuncType,
+}
+
+// bindSQLValueFunction binds the current_date, current_timestamp, etc.
+func (b *Builder) bindSQLValueFunction(expr *pg_query.SQLValueFunction) (*Expr, error) {
+	var name string
+	switch expr.Op {
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_DATE:
+		name = "current_date"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME,
+		pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME_N,
+		pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME,
+		pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME_N:
+		name = "current_time"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP,
+		pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP_N,
+		pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP,
+		pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP_N:
+		name = "current_timestamp"
+	default:
+		return nil, fmt.Errorf("unsupported sql value function %v", expr.Op)
+	}
+	return b.bindFunc(name, ET_SubFunc, expr.String(), []*Expr{}, []common.LType{}, false)
+}
+
+// bindGroupingFunc binds GROUPING(a,b,...).
+// It is evaluated by the aggregate node like an aggregate function.
+// The children are the positions of the arguments in the group by list.
+func (b *Builder) bindGroupingFunc(ctx *BindContext, iwc InWhichClause, expr *pg_query.GroupingFunc, depth int) (*Expr, error) {
+	switch iwc {
+	case IWC_SELECT, IWC_HAVING, IWC_ORDER:
+	default:
+		return nil, fmt.Errorf("GROUPING is not allowed here")
+	}
+	if len(expr.Args) == 0 || len(expr.Args) >= 64 {
+		return nil, fmt.Errorf("invalid argument count %d for GROUPING", len(expr.Args))
+	}
+	children := make([]*Expr, 0)
+	for _, arg := range expr.Args {
+		argExpr, err := b.bindExpr(ctx, IWC_GROUP, arg, depth)
+		if err != nil {
+			return nil, err
+		}
+		idx := b.findGroupbyExpr(argExpr)
+		if idx < 0 {
+			return nil, fmt.Errorf("argument of GROUPING must be a group by expression: %s", argExpr.String())
+		}
+		children = append(children, &Expr{
+			Typ:     ET_IConst,
+			DataTyp: common.IntegerType(),
+			Ivalue:  int64(idx),
+		})
+	}
+	b.aggs = append(b.aggs, &Expr{
+		Typ:      ET_Func,
+		SubTyp:   ET_Grouping,
+		Svalue:   ET_Grouping.String(),
+		DataTyp:  common.BigintType(),
+		Children: children,
+		FunImpl:  groupingFunc,
+	})
+	return &Expr{
+		Typ:     ET_Column,
+		DataTyp: common.BigintType(),
+		Table:   fmt.Sprintf("AggNode_%v", b.aggTag),
+		Name:    expr.String(),
+		ColRef:  ColumnBind{uint64(b.aggTag), uint64(len(b.aggs) - 1)},
+	}, nil
+}
+
+// bindMinMaxExpr binds greatest(...) and least(...) as the functions
+func (b *Builder) bindMinMaxExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.MinMaxExpr, depth int) (*Expr, error) {
+	name := "greatest"
+	if expr.Op == pg_query.MinMaxOp_IS_LEAST {
+		name = "least"
+	}
+	args := make([]*Expr, 0)
+	argsTypes := make([]common.LType, 0)
+	for _, arg := range expr.Args {
+		argExpr, err := b.bindExpr(ctx, iwc, arg, depth)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argExpr)
+		argsTypes = append(argsTypes, argExpr.DataTyp)
+	}
+	return b.bindFunc(name, ET_SubFunc, expr.String(), args, argsTypes, false)
+}
+
 func (b *Builder) bindAConst(ctx *BindContext, iwc InWhichClause, expr *pg_query.A_Const, depth int) (*Expr, error) {
 	var ret *Expr
 	var fval float64
@@ -307,7 +781,25 @@ func (b *Builder) bindAExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.
 		return nil, err
 	}
 
+	if expr.Kind == pg_query.A_Expr_Kind_AEXPR_OP &&
+		expr.Name[0].GetString_().GetSval() == "||" {
+		//string concatenation. the arguments are cast to varchar by the function
+		return b.bindFunc("||", ET_SubFunc, expr.String(), []*Expr{left, right}, []common.LType{left.DataTyp, right.DataTyp}, false)
+	}
+
+	if expr.Kind == pg_query.A_Expr_Kind_AEXPR_OP {
+		//json access. j->'a' is json_extract(j, 'a')
+		jsonFuncs := map[string]string{
+			"->":  "json_extract",
+			"->>": "json_extract_string",
+		}
+		if name, has := jsonFuncs[expr.Name[0].GetString_().GetSval()]; has {
+			return b.bindFunc(name, ET_SubFunc, expr.String(), []*Expr{left, right}, []common.LType{left.DataTyp, right.DataTyp}, false)
+		}
+	}
+
 	var et ET_SubTyp
+	funcName := ""
 	switch expr.Kind {
 	case pg_query.A_Expr_Kind_AEXPR_LIKE:
 		opName := expr.Name[0].GetString_().GetSval()
@@ -335,6 +827,10 @@ func (b *Builder) bindAExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.
 			et = ET_Mul
 		case "/":
 			et = ET_Div
+		case "%":
+			//modulo is the function mod
+			et = ET_SubFunc
+			funcName = "mod"
 		case ">":
 			et = ET_Greater
 		case ">=":
@@ -364,6 +860,22 @@ func (b *Builder) bindAExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.
 		right.DataTyp.IsInterval() {
 		//date - interval => date
 		et = ET_DateSub
+	} else if (et == ET_Add || et == ET_Sub) &&
+		(isTimeOrInterval(left.DataTyp) || isTimeOrInterval(right.DataTyp)) {
+		//timestamp|time (+|-) interval, interval + timestamp,
+		//timestamp - timestamp, interval (+|-) interval
+	} else if et == ET_Mul &&
+		(left.DataTyp.IsInterval() && right.DataTyp.IsIntegral() ||
+			left.DataTyp.IsIntegral() && right.DataTyp.IsInterval()) {
+		//integer * interval, interval * integer
+		if left.DataTyp.IsIntegral() {
+			left, err = AddCastToType(left, common.BigintType(), false)
+		} else {
+			right, err = AddCastToType(right, common.BigintType(), false)
+		}
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		resultTyp = decideResultType(left.DataTyp, right.DataTyp)
 
@@ -378,7 +890,10 @@ func (b *Builder) bindAExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.
 		}
 	}
 
-	bindFunc, err := b.bindFunc(et.String(), et, expr.String(), []*Expr{left, right}, []common.LType{left.DataTyp, right.DataTyp}, false)
+	if funcName == "" {
+		funcName = et.String()
+	}
+	bindFunc, err := b.bindFunc(funcName, et, expr.String(), []*Expr{left, right}, []common.LType{left.DataTyp, right.DataTyp}, false)
 	if err != nil {
 		return nil, err
 	}
@@ -467,7 +982,10 @@ func (b *Builder) bindBoolExpr(ctx *BindContext, iwc InWhichClause, expr *pg_que
 func (b *Builder) bindFunc(name string, subTyp ET_SubTyp, astStr string, args []*Expr, argsTypes []common.LType, distinct bool) (*Expr, error) {
 	funBinder := FunctionBinder{}
 	if IsAgg(name) {
-		ret := funBinder.BindAggrFunc(name, args, subTyp, false)
+		ret, err := funBinder.BindAggrFunc(name, args, subTyp, false)
+		if err != nil {
+			return nil, err
+		}
 		if distinct {
 			ret.AggrTyp = DISTINCT
 		}
@@ -507,7 +1025,12 @@ func decideResultType(left common.LType, right common.LType) common.LType {
 		maxWidth = min(maxWidth, common.DecimalMaxWidth)
 		return common.DecimalType(maxWidth, maxScale)
 	case common.LTID_VARCHAR:
-		//
+		//the string literal is converted into the temporal type
+		if isTemporal(left) {
+			return left
+		} else if isTemporal(right) {
+			return right
+		}
 		if left.IsNumeric() || left.Id == common.LTID_BOOLEAN {
 			return left
 		} else if right.IsNumeric() || right.Id == common.LTID_BOOLEAN {
@@ -520,6 +1043,26 @@ func decideResultType(left common.LType, right common.LType) common.LType {
 
 }
 
+func isTimeOrInterval(typ common.LType) bool {
+	switch typ.Id {
+	case common.LTID_TIME, common.LTID_TIMESTAMP, common.LTID_INTERVAL:
+		return true
+	default:
+		return false
+	}
+}
+
+func isTemporal(typ common.LType) bool {
+	switch typ.Id {
+	case common.LTID_DATE, common.LTID_TIME, common.LTID_INTERVAL,
+		common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		return true
+	default:
+		return false
+	}
+}
+
 func (b *Builder) bindBetweenExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.A_Expr, depth int) (*Expr, error) {
 	var betExpr *Expr
 	var listExppr *Expr
@@ -697,3 +1240,177 @@ func (b *Builder) isInSelectList(alias string) int {
 	}
 	return -1
 }
+
+// bindArrayExpr binds the list literal [a, b, ...]
+func (b *Builder) bindArrayExpr(ctx *BindContext, iwc InWhichClause, expr *pg_query.A_ArrayExpr, depth int) (*Expr, error) {
+	args := make([]*Expr, 0, len(expr.Elements))
+	argsTypes := make([]common.LType, 0, len(expr.Elements))
+	for _, elem := range expr.Elements {
+		child, err := b.bindExpr(ctx, iwc, elem, depth)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, child)
+		argsTypes = append(argsTypes, child.DataTyp)
+	}
+	return b.bindFunc("list_value", ET_SubFunc, expr.String(), args, argsTypes, false)
+}
+
+// bindIndirection binds the element access l[i], l[i:j], m[k] and (s).a
+func (b *Builder) bindIndirection(ctx *BindContext, iwc InWhichClause, expr *pg_query.A_Indirection, depth int) (*Expr, error) {
+	ret, err := b.bindExpr(ctx, iwc, expr.Arg, depth)
+	if err != nil {
+		return nil, err
+	}
+	for _, ind := range expr.Indirection {
+		switch realInd := ind.GetNode().(type) {
+		case *pg_query.Node_AIndices:
+			ret, err = b.bindIndices(ctx, iwc, ret, realInd.AIndices, depth)
+		case *pg_query.Node_String_:
+			ret, err = b.bindStructExtract(ret, realInd.String_.GetSval())
+		default:
+			return nil, fmt.Errorf("unsupported indirection %T", realInd)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (b *Builder) bindIndices(ctx *BindContext, iwc InWhichClause, base *Expr, expr *pg_query.A_Indices, depth int) (*Expr, error) {
+	bindIdx := func(node *pg_query.Node, def int64) (*Expr, error) {
+		if node == nil {
+			return &Expr{
+				Typ:     ET_IConst,
+				DataTyp: common.IntegerType(),
+				Ivalue:  def,
+			}, nil
+		}
+		return b.bindExpr(ctx, iwc, node, depth)
+	}
+	var name string
+	args := []*Expr{base}
+	if expr.IsSlice {
+		if base.DataTyp.Id != common.LTID_LIST {
+			return nil, fmt.Errorf("slice on non-list type %s", base.DataTyp)
+		}
+		lidx, err := bindIdx(expr.Lidx, 1)
+		if err != nil {
+			return nil, err
+		}
+		uidx, err := bindIdx(expr.Uidx, -1)
+		if err != nil {
+			return nil, err
+		}
+		name = "list_slice"
+		args = append(args, lidx, uidx)
+	} else {
+		idx, err := b.bindExpr(ctx, iwc, expr.Uidx, depth)
+		if err != nil {
+			return nil, err
+		}
+		switch base.DataTyp.Id {
+		case common.LTID_LIST:
+			name = "list_extract"
+		case common.LTID_MAP:
+			name = "map_extract"
+		case common.LTID_STRUCT:
+			if idx.Typ != ET_SConst {
+				return nil, fmt.Errorf("struct field must be a constant string")
+			}
+			return b.bindStructExtract(base, idx.Svalue)
+		default:
+			return nil, fmt.Errorf("subscript on non-nested type %s", base.DataTyp)
+		}
+		args = append(args, idx)
+	}
+	argsTypes := make([]common.LType, len(args))
+	for i, arg := range args {
+		argsTypes[i] = arg.DataTyp
+	}
+	return b.bindFunc(name, ET_SubFunc, name, args, argsTypes, false)
+}
+
+func (b *Builder) bindStructExtract(base *Expr, field string) (*Expr, error) {
+	if base.DataTyp.Id != common.LTID_STRUCT {
+		return nil, fmt.Errorf("field %s on non-struct type %s", field, base.DataTyp)
+	}
+	args := []*Expr{
+		base,
+		{
+			Typ:     ET_SConst,
+			DataTyp: common.VarcharType(),
+			Svalue:  field,
+		},
+	}
+	return b.bindFunc(
+		"struct_extract",
+		ET_SubFunc,
+		field,
+		args,
+		[]common.LType{args[0].DataTyp, args[1].DataTyp},
+		false)
+}
+
+// bindStructFieldRef binds the column reference whose tail
+// fields are the fields of the struct column.
+// t.s.a.b or s.a.b
+func (b *Builder) bindStructFieldRef(ctx *BindContext, iwc InWhichClause, expr *pg_query.ColumnRef, depth int) (*Expr, error) {
+	var lastErr error
+	for _, prefix := range []int{2, 1} {
+		if prefix >= len(expr.Fields) {
+			continue
+		}
+		col := &pg_query.Node{
+			Node: &pg_query.Node_ColumnRef{
+				ColumnRef: &pg_query.ColumnRef{
+					Fields: expr.Fields[:prefix],
+				},
+			},
+		}
+		ret, err := b.bindExpr(ctx, iwc, col, depth)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, field := range expr.Fields[prefix:] {
+			ret, err = b.bindStructExtract(ret, field.GetString_().GetSval())
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	}
+	return nil, lastErr
+}
+
+// bindUnnest binds unnest(list) in the select list.
+// The elements come from the Unnest node.
+func (b *Builder) bindUnnest(ctx *BindContext, iwc InWhichClause, expr *pg_query.FuncCall, depth int) (*Expr, error) {
+	if iwc != IWC_SELECT {
+		return nil, fmt.Errorf("unnest is only supported in the select list")
+	}
+	if len(expr.Args) != 1 {
+		return nil, fmt.Errorf("unnest requires one argument")
+	}
+	arg, err := b.bindExpr(ctx, iwc, expr.Args[0], depth)
+	if err != nil {
+		return nil, err
+	}
+	if arg.DataTyp.Id != common.LTID_LIST {
+		return nil, fmt.Errorf("unnest requires a list. got %s", arg.DataTyp)
+	}
+	arg = b.replaceGroupbyExprs(arg)
+	if b.unnestTag == 0 {
+		b.unnestTag = b.GetTag()
+	}
+	b.unnests = append(b.unnests, arg)
+	return &Expr{
+		Typ:     ET_Column,
+		DataTyp: arg.DataTyp.ListChild(),
+		Table:   fmt.Sprintf("UnnestNode_%v", b.unnestTag),
+		Name:    expr.String(),
+		ColRef:  ColumnBind{uint64(b.unnestTag), uint64(len(b.unnests) - 1)},
+	}, nil
+}
@@ -382,6 +382,78 @@ func (e likeOp) operation(left, right *common.String) bool {
 	return wildcardMatch(right.String(), left.String())
 }
 
+// int64. (bigint, time, timestamp)
+type lessInt64Op struct {
+}
+
+func (e lessInt64Op) operation(left, right *int64) bool {
+	return *left < *right
+}
+
+type lessEqualInt64Op struct {
+}
+
+func (e lessEqualInt64Op) operation(left, right *int64) bool {
+	return *left <= *right
+}
+
+type greatInt64Op struct {
+}
+
+func (e greatInt64Op) operation(left, right *int64) bool {
+	return *left > *right
+}
+
+type greatEqualInt64Op struct {
+}
+
+func (e greatEqualInt64Op) operation(left, right *int64) bool {
+	return *left >= *right
+}
+
+// interval
+type equalIntervalOp struct {
+}
+
+func (e equalIntervalOp) operation(left, right *common.Interval) bool {
+	return left.Equal(right)
+}
+
+type notEqualIntervalOp struct {
+}
+
+func (e notEqualIntervalOp) operation(left, right *common.Interval) bool {
+	return !left.Equal(right)
+}
+
+type lessIntervalOp struct {
+}
+
+func (e lessIntervalOp) operation(left, right *common.Interval) bool {
+	return left.Less(right)
+}
+
+type lessEqualIntervalOp struct {
+}
+
+func (e lessEqualIntervalOp) operation(left, right *common.Interval) bool {
+	return !right.Less(left)
+}
+
+type greatIntervalOp struct {
+}
+
+func (e greatIntervalOp) operation(left, right *common.Interval) bool {
+	return right.Less(left)
+}
+
+type greatEqualIntervalOp struct {
+}
+
+func (e greatEqualIntervalOp) operation(left, right *common.Interval) bool {
+	return !left.Less(right)
+}
+
 // not like
 //
 //lint:ignore U1000
@@ -398,11 +470,15 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 		switch left.Typ().GetInternalType() {
 		case common.INT32:
 			return selectBinary[int32](left, right, sel, count, trueSel, falseSel, equalOp[int32]{})
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, equalOp[int64]{})
 		case common.VARCHAR:
 			return selectBinary[common.String](left, right, sel, count, trueSel, falseSel, equalStrOp{})
 		case common.BOOL:
 			return selectBinary[bool](left, right, sel, count, trueSel, falseSel, equalOp[bool]{})
-		case common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.FLOAT, common.DOUBLE, common.INTERVAL, common.LIST, common.STRUCT, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, equalIntervalOp{})
+		case common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.FLOAT, common.DOUBLE, common.LIST, common.STRUCT, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -411,9 +487,13 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 		switch left.Typ().GetInternalType() {
 		case common.INT32:
 			return selectBinary[int32](left, right, sel, count, trueSel, falseSel, notEqualOp[int32]{})
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, notEqualOp[int64]{})
 		case common.VARCHAR:
 			return selectBinary[common.String](left, right, sel, count, trueSel, falseSel, notEqualStrOp{})
-		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.FLOAT, common.DOUBLE, common.INTERVAL, common.LIST, common.STRUCT, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, notEqualIntervalOp{})
+		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.FLOAT, common.DOUBLE, common.LIST, common.STRUCT, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -441,7 +521,11 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 			return selectBinary[float32](left, right, sel, count, trueSel, falseSel, greatFloat32Op{})
 		case common.DECIMAL:
 			return selectBinary[common.Decimal](left, right, sel, count, trueSel, falseSel, greatDecimalOp{})
-		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.DOUBLE, common.INTERVAL, common.LIST, common.STRUCT, common.VARCHAR, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, greatInt64Op{})
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, greatIntervalOp{})
+		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.DOUBLE, common.LIST, common.STRUCT, common.VARCHAR, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -454,7 +538,11 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 			return selectBinary[common.Date](left, right, sel, count, trueSel, falseSel, greatEqualDateOp{})
 		case common.FLOAT:
 			return selectBinary[float32](left, right, sel, count, trueSel, falseSel, greatEqualFloat32Op{})
-		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.DOUBLE, common.INTERVAL, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, greatEqualInt64Op{})
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, greatEqualIntervalOp{})
+		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.DOUBLE, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -467,7 +555,11 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 			return selectBinary[common.Date](left, right, sel, count, trueSel, falseSel, lessDateOp{})
 		case common.DOUBLE:
 			return selectBinary[float64](left, right, sel, count, trueSel, falseSel, lessFloat64Op{})
-		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.FLOAT, common.INTERVAL, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, lessInt64Op{})
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, lessIntervalOp{})
+		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.FLOAT, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -480,7 +572,11 @@ func selectOperation(left, right *chunk.Vector, sel *chunk.SelectVector, count i
 			return selectBinary[common.Date](left, right, sel, count, trueSel, falseSel, lessEqualDateOp{})
 		case common.FLOAT:
 			return selectBinary[float32](left, right, sel, count, trueSel, falseSel, lessEqualFloat32Op{})
-		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.INT64, common.DOUBLE, common.INTERVAL, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
+		case common.INT64:
+			return selectBinary[int64](left, right, sel, count, trueSel, falseSel, lessEqualInt64Op{})
+		case common.INTERVAL:
+			return selectBinary[common.Interval](left, right, sel, count, trueSel, falseSel, lessEqualIntervalOp{})
+		case common.BOOL, common.UINT8, common.INT8, common.UINT16, common.INT16, common.UINT32, common.UINT64, common.DOUBLE, common.LIST, common.STRUCT, common.VARCHAR, common.INT128, common.UNKNOWN, common.BIT, common.INVALID:
 			panic("usp")
 		default:
 			panic("usp")
@@ -17,6 +17,7 @@ package plan
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	pg_query "github.com/pganalyze/pg_query_go/v5"
@@ -250,10 +251,14 @@ type Builder struct {
 	whereExpr    *Expr
 	aggs         []*Expr
 	groupbyExprs []*Expr
+	groupingSets []GroupingSet
 	havingExpr   *Expr
 	orderbyExprs []*Expr
 	limitCount   *Expr
 	limitOffset  *Expr
+	//unnest(list) in the select list
+	unnests   []*Expr
+	unnestTag int
 
 	//for insert
 	expectedTypes []common.LType
@@ -263,6 +268,8 @@ type Builder struct {
 	columnCount int      // count of the select exprs (after expanding star)
 	phyId       int
 	txn         *storage.Txn
+	//depth of the macro expansion
+	macroDepth int
 }
 
 func NewBuilder(txn *storage.Txn) *Builder {
@@ -520,13 +527,9 @@ func (b *Builder) buildSelect(sel *pg_query.SelectStmt, ctx *BindContext, depth
 
 	//group by
 	if len(sel.GroupClause) != 0 {
-		var retExpr *Expr
-		for _, expr := range sel.GroupClause {
-			retExpr, err = b.bindExpr(ctx, IWC_GROUP, expr, depth)
-			if err != nil {
-				return err
-			}
-			b.groupbyExprs = append(b.groupbyExprs, retExpr)
+		err = b.buildGroupBy(sel.GroupClause, ctx, depth)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -537,7 +540,7 @@ func (b *Builder) buildSelect(sel *pg_query.SelectStmt, ctx *BindContext, depth
 		if err != nil {
 			return err
 		}
-		b.havingExpr = retExpr
+		b.havingExpr = b.replaceGroupbyExprs(retExpr)
 	}
 	//select exprs
 	var retExpr *Expr
@@ -546,6 +549,7 @@ func (b *Builder) buildSelect(sel *pg_query.SelectStmt, ctx *BindContext, depth
 		if err != nil {
 			return err
 		}
+		retExpr = b.replaceGroupbyExprs(retExpr)
 		retExpr.Alias = b.names[i]
 		b.projectExprs = append(b.projectExprs, retExpr)
 	}
@@ -557,7 +561,7 @@ func (b *Builder) buildSelect(sel *pg_query.SelectStmt, ctx *BindContext, depth
 			if err != nil {
 				return err
 			}
-			b.orderbyExprs = append(b.orderbyExprs, retExpr)
+			b.orderbyExprs = append(b.orderbyExprs, b.replaceGroupbyExprs(retExpr))
 		}
 	}
 
@@ -572,6 +576,190 @@ func (b *Builder) buildSelect(sel *pg_query.SelectStmt, ctx *BindContext, depth
 	return err
 }
 
+// buildGroupBy binds the group by clause.
+// GROUPING SETS, ROLLUP and CUBE are expanded into the grouping sets
+// on the distinct group by exprs.
+func (b *Builder) buildGroupBy(groupClause []*pg_query.Node, ctx *BindContext, depth int) error {
+	hasGroupingSets := false
+	//the cross product of grouping sets of every item
+	sets := []GroupingSet{make(GroupingSet)}
+	for _, expr := range groupClause {
+		if expr.GetGroupingSet() != nil {
+			hasGroupingSets = true
+		}
+		itemSets, err := b.bindGroupingElem(expr, ctx, depth)
+		if err != nil {
+			return err
+		}
+		newSets := make([]GroupingSet, 0, len(sets)*len(itemSets))
+		for _, set := range sets {
+			for _, itemSet := range itemSets {
+				newSets = append(newSets, set.union(itemSet))
+			}
+		}
+		sets = newSets
+		if len(sets) > maxGroupingSets {
+			return fmt.Errorf("too many grouping sets. max %d", maxGroupingSets)
+		}
+	}
+	if hasGroupingSets {
+		b.groupingSets = sets
+	}
+	return nil
+}
+
+const maxGroupingSets = 4096
+
+func (b *Builder) bindGroupingElem(node *pg_query.Node, ctx *BindContext, depth int) ([]GroupingSet, error) {
+	switch realNode := node.GetNode().(type) {
+	case *pg_query.Node_GroupingSet:
+		content := realNode.GroupingSet.GetContent()
+		switch realNode.GroupingSet.GetKind() {
+		case pg_query.GroupingSetKind_GROUPING_SET_EMPTY:
+			return []GroupingSet{make(GroupingSet)}, nil
+		case pg_query.GroupingSetKind_GROUPING_SET_SIMPLE:
+			set, err := b.bindGroupingCols(content, ctx, depth)
+			if err != nil {
+				return nil, err
+			}
+			return []GroupingSet{set}, nil
+		case pg_query.GroupingSetKind_GROUPING_SET_ROLLUP:
+			//ROLLUP(a,b,c) => (a,b,c),(a,b),(a),()
+			elems, err := b.bindGroupingSetElems(content, ctx, depth)
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]GroupingSet, 0, len(elems)+1)
+			cur := make(GroupingSet)
+			ret = append(ret, cur)
+			for _, elem := range elems {
+				cur = cur.union(elem)
+				ret = append(ret, cur)
+			}
+			slices.Reverse(ret)
+			return ret, nil
+		case pg_query.GroupingSetKind_GROUPING_SET_CUBE:
+			//CUBE(a,b) => (a,b),(a),(b),()
+			elems, err := b.bindGroupingSetElems(content, ctx, depth)
+			if err != nil {
+				return nil, err
+			}
+			if len(elems) > 12 {
+				return nil, fmt.Errorf("CUBE is limited to 12 elements")
+			}
+			cnt := 1 << len(elems)
+			ret := make([]GroupingSet, 0, cnt)
+			for mask := cnt - 1; mask >= 0; mask-- {
+				set := make(GroupingSet)
+				for i, elem := range elems {
+					if mask&(1<<(len(elems)-i-1)) != 0 {
+						set = set.union(elem)
+					}
+				}
+				ret = append(ret, set)
+			}
+			return ret, nil
+		case pg_query.GroupingSetKind_GROUPING_SET_SETS:
+			ret := make([]GroupingSet, 0)
+			for _, item := range content {
+				itemSets, err := b.bindGroupingElem(item, ctx, depth)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, itemSets...)
+			}
+			return ret, nil
+		default:
+			return nil, fmt.Errorf("usp grouping set kind %v", realNode.GroupingSet.GetKind())
+		}
+	case *pg_query.Node_RowExpr:
+		//(a,b)
+		set, err := b.bindGroupingCols(realNode.RowExpr.GetArgs(), ctx, depth)
+		if err != nil {
+			return nil, err
+		}
+		return []GroupingSet{set}, nil
+	default:
+		set, err := b.bindGroupingCols([]*pg_query.Node{node}, ctx, depth)
+		if err != nil {
+			return nil, err
+		}
+		return []GroupingSet{set}, nil
+	}
+}
+
+// bindGroupingSetElems binds the elements of the ROLLUP or CUBE.
+// every element is a column or a composite column (a,b)
+func (b *Builder) bindGroupingSetElems(content []*pg_query.Node, ctx *BindContext, depth int) ([]GroupingSet, error) {
+	ret := make([]GroupingSet, 0, len(content))
+	for _, item := range content {
+		var cols []*pg_query.Node
+		if row := item.GetRowExpr(); row != nil {
+			cols = row.GetArgs()
+		} else {
+			cols = []*pg_query.Node{item}
+		}
+		set, err := b.bindGroupingCols(cols, ctx, depth)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, set)
+	}
+	return ret, nil
+}
+
+func (b *Builder) bindGroupingCols(cols []*pg_query.Node, ctx *BindContext, depth int) (GroupingSet, error) {
+	set := make(GroupingSet)
+	for _, col := range cols {
+		retExpr, err := b.bindExpr(ctx, IWC_GROUP, col, depth)
+		if err != nil {
+			return nil, err
+		}
+		idx := b.findGroupbyExpr(retExpr)
+		if idx < 0 {
+			idx = len(b.groupbyExprs)
+			b.groupbyExprs = append(b.groupbyExprs, retExpr)
+		}
+		set.insert(idx)
+	}
+	return set, nil
+}
+
+func (b *Builder) findGroupbyExpr(e *Expr) int {
+	for i, group := range b.groupbyExprs {
+		if group.equal(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceGroupbyExprs replaces the exprs that equal to the group by exprs
+// by the column refs to the group by exprs.
+// In grouping sets, the group by exprs that are not in the
+// grouping set are NULL. They can not be evaluated on the children.
+func (b *Builder) replaceGroupbyExprs(e *Expr) *Expr {
+	if e == nil || len(b.groupingSets) == 0 {
+		return e
+	}
+	if e.Typ != ET_Orderby {
+		idx := b.findGroupbyExpr(e)
+		if idx >= 0 {
+			return &Expr{
+				Typ:     ET_Column,
+				DataTyp: e.DataTyp,
+				Table:   e.Table,
+				Name:    e.Name,
+				ColRef:  ColumnBind{uint64(b.groupTag), uint64(idx)},
+			}
+		}
+	}
+	for i, child := range e.Children {
+		e.Children[i] = b.replaceGroupbyExprs(child)
+	}
+	return e
+}
+
 func (b *Builder) findCte(name string, skip bool, ctx *BindContext) *pg_query.CommonTableExpr {
 	if val, has := ctx.ctes[name]; has {
 		if !skip {
@@ -727,11 +915,16 @@ func (b *Builder) buildTable(table *pg_query.Node, ctx *BindContext, depth int)
 		//}
 	case *pg_query.Node_JoinExpr:
 		return b.buildJoinTable(rangeNode.JoinExpr, ctx, depth)
+	case *pg_query.Node_RangeTableSample:
+		return b.buildTableSample(rangeNode.RangeTableSample, ctx, depth)
+	case *pg_query.Node_RangeFunction:
+		return b.buildTableFunc(rangeNode.RangeFunction, ctx, depth)
 	case *pg_query.Node_RangeSubselect:
 		subqueryAst := rangeNode.RangeSubselect
 		subBuilder := NewBuilder(b.txn)
 		subBuilder.tag = b.tag
 		subBuilder.rootCtx.parent = ctx
+		subBuilder.macroDepth = b.macroDepth
 		if len(subqueryAst.Alias.Aliasname) == 0 {
 			return nil, errors.New("need alias for subquery")
 		}
@@ -959,6 +1152,16 @@ func (b *Builder) CreatePlan(ctx *BindContext, root *LogicalOperator) (*LogicalO
 		root, err = b.createWhere(b.havingExpr, root)
 	}
 
+	//unnest
+	if len(b.unnests) > 0 {
+		root = &LogicalOperator{
+			Typ:      LOT_Unnest,
+			Index:    uint64(b.unnestTag),
+			Projects: b.unnests,
+			Children: []*LogicalOperator{root},
+		}
+	}
+
 	//projects
 	if len(b.projectExprs) > 0 {
 		root, err = b.createProject(root)
@@ -1002,6 +1205,7 @@ func (b *Builder) createFrom(expr *Expr, root *LogicalOperator) (*LogicalOperato
 				BelongCtx: expr.BelongCtx,
 				Stats:     stats,
 				TableEnt:  tabEnt,
+				Sample:    expr.Sample,
 			}, err
 		}
 		//{
@@ -1071,6 +1275,41 @@ func (b *Builder) createFrom(expr *Expr, root *LogicalOperator) (*LogicalOperato
 			Values:      expr.Values,
 			ColName2Idx: expr.ColName2Idx,
 		}, err
+	case ET_TableFunc:
+		if expr.ScanInfo == nil {
+			//generate_series or range
+			info, err := newSeriesInfo(expr.Values[0], expr.Types[0], expr.Table != "range")
+			if err != nil {
+				return nil, err
+			}
+			return &LogicalOperator{
+				Typ:         LOT_Scan,
+				Index:       expr.Index,
+				Table:       expr.Table,
+				Alias:       expr.Alias,
+				BelongCtx:   expr.BelongCtx,
+				Stats:       &Stats{RowCount: max(info.estimatedCount(), 1)},
+				TableIndex:  int(expr.Index),
+				ScanTyp:     ScanTypeSeries,
+				Types:       expr.Types,
+				Names:       expr.Names,
+				Values:      expr.Values,
+				ColName2Idx: expr.ColName2Idx,
+			}, err
+		}
+		return &LogicalOperator{
+			Typ:         LOT_Scan,
+			Index:       expr.Index,
+			Table:       expr.Table,
+			Alias:       expr.Alias,
+			BelongCtx:   expr.BelongCtx,
+			Stats:       &Stats{RowCount: float64(expr.Ivalue)},
+			TableIndex:  int(expr.Index),
+			ScanTyp:     ScanTypeTableFunc,
+			ScanInfo:    expr.ScanInfo,
+			Types:       expr.ScanInfo.ReturnedTypes,
+			ColName2Idx: expr.ColName2Idx,
+		}, err
 	default:
 		panic("usp")
 	}
@@ -1532,6 +1771,10 @@ func (b *Builder) replaceCorrFiltersInAggr(subRoot *LogicalOperator, filter *Exp
 	if !hasCorrCol(filter) {
 		idx := len(subRoot.GroupBys)
 		subRoot.GroupBys = append(subRoot.GroupBys, filter)
+		//the correlated column is grouped in every grouping set
+		for _, set := range subRoot.GroupingSets {
+			set.insert(idx)
+		}
 		return &Expr{
 			Typ:     ET_Column,
 			ColRef:  ColumnBind{subRoot.Index, uint64(idx)},
@@ -1547,12 +1790,13 @@ func (b *Builder) replaceCorrFiltersInAggr(subRoot *LogicalOperator, filter *Exp
 
 func (b *Builder) createAggGroup(root *LogicalOperator) (*LogicalOperator, error) {
 	return &LogicalOperator{
-		Typ:      LOT_AggGroup,
-		Index:    uint64(b.groupTag),
-		Index2:   uint64(b.aggTag),
-		Aggs:     b.aggs,
-		GroupBys: b.groupbyExprs,
-		Children: []*LogicalOperator{root},
+		Typ:          LOT_AggGroup,
+		Index:        uint64(b.groupTag),
+		Index2:       uint64(b.aggTag),
+		Aggs:         b.aggs,
+		GroupBys:     b.groupbyExprs,
+		GroupingSets: b.groupingSets,
+		Children:     []*LogicalOperator{root},
 	}, nil
 }
 
@@ -1786,7 +2030,11 @@ func (b *Builder) pushdownFilters(root *LogicalOperator, filters []*Expr) (*Logi
 
 	case LOT_AggGroup:
 		for _, f := range filters {
-			if referTo(f, root.Index2) {
+			if len(root.GroupingSets) != 0 && referTo(f, root.Index) {
+				//the group by exprs may be NULL in the grouping sets.
+				//expr that refer to them must be evaluated on the output.
+				left = append(left, f)
+			} else if referTo(f, root.Index2) {
 				//expr that refer to the agg exprs can not be pushdown.
 				root.Filters = append(root.Filters, f)
 			} else {
@@ -1843,8 +2091,8 @@ func (b *Builder) pushdownFilters(root *LogicalOperator, filters []*Expr) (*Logi
 		}
 
 	default:
-		if root.Typ == LOT_Limit {
-			//can not pushdown filter through LIMIT
+		if root.Typ == LOT_Limit || root.Typ == LOT_Unnest {
+			//can not pushdown filter through LIMIT or UNNEST
 			left, filters = filters, nil
 		}
 		if len(root.Children) > 0 {
@@ -2094,6 +2342,9 @@ func (b *Builder) CreatePhyPlan(root *LogicalOperator) (*PhysicalOperator, error
 		return nil, nil
 	}
 	var err error
+	if root.Typ == LOT_Filter {
+		pushRangeConds(root)
+	}
 	children := make([]*PhysicalOperator, 0)
 	for _, child := range root.Children {
 		childPlan, err := b.CreatePhyPlan(child)
@@ -2139,6 +2390,14 @@ func (b *Builder) CreatePhyPlan(root *LogicalOperator) (*PhysicalOperator, error
 		if err != nil {
 			return nil, err
 		}
+	case LOT_Unnest:
+		proot = &PhysicalOperator{
+			Typ:      POT_Unnest,
+			Index:    root.Index,
+			Projects: root.Projects,
+			Outputs:  root.Outputs,
+			Children: children,
+		}
 	case LOT_CreateSchema:
 		proot, err = b.createPhyCreateSchema(root, children)
 		if err != nil {
@@ -2149,6 +2408,20 @@ func (b *Builder) CreatePhyPlan(root *LogicalOperator) (*PhysicalOperator, error
 		if err != nil {
 			return nil, err
 		}
+	case LOT_CreateMacro:
+		proot = &PhysicalOperator{
+			Typ:      POT_CreateMacro,
+			Database: root.Database,
+			Table:    root.Table,
+			Macro:    root.Macro,
+			Children: children,
+		}
+	case LOT_Set:
+		proot = &PhysicalOperator{
+			Typ:      POT_Set,
+			Setting:  root.Setting,
+			Children: children,
+		}
 	case LOT_Insert:
 		proot, err = b.createPhyInsert(root, children)
 		if err != nil {
@@ -2191,6 +2464,7 @@ func (b *Builder) createPhyScan(root *LogicalOperator, children []*PhysicalOpera
 		ScanTyp:     root.ScanTyp,
 		Types:       root.Types,
 		ColName2Idx: root.ColName2Idx,
+		Sample:      root.Sample,
 		Children:    children}
 
 	switch root.ScanTyp {
@@ -2219,8 +2493,15 @@ func (b *Builder) createPhyScan(root *LogicalOperator, children []*PhysicalOpera
 			}
 			ret.collection = collection
 		}
+	case ScanTypeSeries:
+		var err error
+		//range excludes the stop
+		ret.series, err = newSeriesInfo(root.Values[0], root.Types[0], root.Table != "range")
+		if err != nil {
+			return nil, err
+		}
 	case ScanTypeTable:
-	case ScanTypeCopyFrom:
+	case ScanTypeCopyFrom, ScanTypeTableFunc:
 		ret.ScanInfo = root.ScanInfo
 	}
 
@@ -2228,13 +2509,22 @@ func (b *Builder) createPhyScan(root *LogicalOperator, children []*PhysicalOpera
 }
 
 func (b *Builder) createPhyJoin(root *LogicalOperator, children []*PhysicalOperator) (*PhysicalOperator, error) {
-	return &PhysicalOperator{
+	ret := &PhysicalOperator{
 		Typ:      POT_Join,
 		Index:    root.Index,
 		JoinTyp:  root.JoinTyp,
 		OnConds:  root.OnConds,
 		Outputs:  root.Outputs,
-		Children: children}, nil
+		Children: children}
+	if ret.JoinTyp == LOT_JoinTypeInner && isRangeJoin(ret.OnConds) {
+		//no equality keys
+		ret.Typ = POT_RangeJoin
+		return ret, nil
+	}
+	if len(ret.OnConds) != 0 {
+		ret.joinFilters = planJoinFilters(ret)
+	}
+	return ret, nil
 }
 
 func (b *Builder) createPhyOrder(root *LogicalOperator, children []*PhysicalOperator) (*PhysicalOperator, error) {
@@ -2247,14 +2537,15 @@ func (b *Builder) createPhyOrder(root *LogicalOperator, children []*PhysicalOper
 
 func (b *Builder) createPhyAgg(root *LogicalOperator, children []*PhysicalOperator) (*PhysicalOperator, error) {
 	return &PhysicalOperator{
-		Typ:      POT_Agg,
-		Index:    root.Index,
-		Index2:   root.Index2,
-		Filters:  root.Filters,
-		Aggs:     root.Aggs,
-		GroupBys: root.GroupBys,
-		Outputs:  root.Outputs,
-		Children: children}, nil
+		Typ:          POT_Agg,
+		Index:        root.Index,
+		Index2:       root.Index2,
+		Filters:      root.Filters,
+		Aggs:         root.Aggs,
+		GroupBys:     root.GroupBys,
+		GroupingSets: root.GroupingSets,
+		Outputs:      root.Outputs,
+		Children:     children}, nil
 }
 
 func (b *Builder) createPhyLimit(root *LogicalOperator, children []*PhysicalOperator) (*PhysicalOperator, error) {
@@ -2300,6 +2591,10 @@ func (b *Builder) buildDDL(txn *storage.Txn, ddl *pg_query.RawStmt, ctx *BindCon
 			impl.CreateStmt,
 			ctx,
 			depth)
+	case *pg_query.Node_CreateFunctionStmt:
+		return b.buildCreateMacro(txn, impl.CreateFunctionStmt, ctx, depth)
+	case *pg_query.Node_VariableSetStmt:
+		return b.buildSet(impl.VariableSetStmt)
 	case *pg_query.Node_InsertStmt:
 		return b.buildInsert(txn, impl.InsertStmt, ctx, depth)
 	case *pg_query.Node_CopyStmt:
@@ -2362,6 +2657,7 @@ func (b *Builder) buildCreateTable(
 		IfNotExists: stmt.GetIfNotExists(),
 	}
 
+	var err error
 	colDefs := make([]*storage.ColumnDefinition, 0)
 	tableCons := make([]*storage.Constraint, 0)
 	for colIdx, node := range stmt.GetTableElts() {
@@ -2382,6 +2678,13 @@ func (b *Builder) buildCreateTable(
 			default:
 				panic("usp")
 			}
+			lname := strings.ToLower(typName)
+			if len(colDef.TypeName.ArrayBounds) != 0 ||
+				strings.HasPrefix(lname, "struct(") ||
+				strings.HasPrefix(lname, "map(") {
+				//nested type name is quoted by the parser
+				typName = "nested"
+			}
 			switch strings.ToLower(typName) {
 			case "int4":
 				colDefExpr.Type = common.IntegerType()
@@ -2396,6 +2699,18 @@ func (b *Builder) buildCreateTable(
 				colDefExpr.Type = common.DecimalType(int(width), int(pres))
 			case "date":
 				colDefExpr.Type = common.DateType()
+			case "time", "timestamp", "timestamptz",
+				"timestamp_s", "timestamp_ms", "timestamp_ns", "interval",
+				"json":
+				colDefExpr.Type, err = typeNameToLType(colDef.TypeName)
+				if err != nil {
+					return nil, err
+				}
+			case "nested":
+				colDefExpr.Type, err = typeNameToLType(colDef.TypeName)
+				if err != nil {
+					return nil, err
+				}
 			default:
 				panic("")
 			}
@@ -2516,7 +2831,7 @@ func (b *Builder) buildInsertInternal(
 			columnNameMap[colName] = i
 			colIdx := tabEnt.GetColumnIndex(colName)
 			if colIdx == -1 {
-				return nil, fmt.Errorf("invalid column %s", colIdx)
+				return nil, fmt.Errorf("invalid column %d", colIdx)
 			}
 			colDef := tabEnt.GetColumn(colIdx)
 			insert.ExpectedTypes = append(insert.ExpectedTypes, colDef.Type)
@@ -13,3 +13,284 @@
 // limitations under the License.
 
 package plan
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/parser"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// groupingTable has 6 rows: a = i % 2, b = i % 3, c = i. i in [1,6].
+// the table is only in the catalog during the binding. the rows
+// are read from the stub instead of the scan.
+const groupingTable = "grouping_t"
+
+// withGroupingTable creates the table in the txn and rolls it back after the fn
+func withGroupingTable(t *testing.T, fn func(txn *storage.Txn)) {
+	txn, err := storage.GTxnMgr.NewTxn("grouping")
+	require.NoError(t, err)
+	defer storage.GTxnMgr.Rollback(txn)
+	run, err := InitRunner(&util.Config{}, txn, "create table "+groupingTable+" (a int, b int, c int)")
+	require.NoError(t, err)
+	output := &chunk.Chunk{}
+	output.SetCap(util.DefaultVectorSize)
+	_, err = run.Execute(nil, output, run.state)
+	run.Close()
+	require.NoError(t, err)
+	fn(txn)
+}
+
+func bindSelectSQL(t *testing.T, sql string) (builder *Builder, err error) {
+	stmts, err := parser.Parse(sql)
+	require.NoError(t, err)
+	withGroupingTable(t, func(txn *storage.Txn) {
+		builder = NewBuilder(txn)
+		err = builder.buildSelect(stmts[0].Stmt.GetSelectStmt(), builder.rootCtx, 0)
+	})
+	return builder, err
+}
+
+func genSelectPhyPlan(t *testing.T, sql string) (root *PhysicalOperator) {
+	stmts, err := parser.Parse(sql)
+	require.NoError(t, err)
+	withGroupingTable(t, func(txn *storage.Txn) {
+		root, err = genPhyPlan(txn, stmts[0].Stmt.GetSelectStmt())
+	})
+	require.NoError(t, err)
+	return root
+}
+
+// stubGroupingTable replaces the scan of the table by the stub.
+// the stub does not evaluate the filters of the scan.
+func stubGroupingTable(t *testing.T, root *PhysicalOperator) {
+	if root.Typ == POT_Scan && root.Table == groupingTable {
+		require.Empty(t, root.Filters)
+		types := make([]common.LType, 0)
+		for _, output := range root.Outputs {
+			types = append(types, output.DataTyp)
+		}
+		data := &chunk.Chunk{}
+		data.Init(types, util.DefaultVectorSize)
+		for i := 1; i <= 6; i++ {
+			for j, col := range root.Columns {
+				val := map[string]int{"a": i % 2, "b": i % 3, "c": i}[col]
+				data.Data[j].SetValue(i-1, &chunk.Value{Typ: types[j], I64: int64(val)})
+			}
+		}
+		data.SetCard(6)
+
+		fname := filepath.Join(t.TempDir(), "grouping_t")
+		serial, err := util.NewFileSerialize(fname)
+		require.NoError(t, err)
+		require.NoError(t, data.Serialize(serial))
+		//the end of the stub
+		data.SetCard(0)
+		require.NoError(t, data.Serialize(serial))
+		require.NoError(t, serial.Close())
+
+		root.Typ = POT_Stub
+		root.Table = fname
+		return
+	}
+	for _, child := range root.Children {
+		stubGroupingTable(t, child)
+	}
+}
+
+// runSelectSQL returns the sorted rows of the query
+func runSelectSQL(t *testing.T, sql string) []string {
+	root := genSelectPhyPlan(t, sql)
+	stubGroupingTable(t, root)
+	run := &Runner{
+		op:    root,
+		state: &OperatorState{},
+		cfg:   &util.Config{},
+	}
+	require.NoError(t, run.Init())
+	defer run.Close()
+	rows := make([]string, 0)
+	for {
+		output := &chunk.Chunk{}
+		output.SetCap(util.DefaultVectorSize)
+		result, err := run.Execute(nil, output, run.state)
+		require.NoError(t, err)
+		if result == Done {
+			break
+		}
+		for i := 0; i < output.Card(); i++ {
+			vals := make([]string, 0)
+			for _, vec := range output.Data {
+				vals = append(vals, vec.GetValue(i).String())
+			}
+			rows = append(rows, strings.Join(vals, "|"))
+		}
+	}
+	sort.Strings(rows)
+	return rows
+}
+
+func Test_bindGroupingSets(t *testing.T) {
+	tests := []struct {
+		groupBy  string
+		groupbys int
+		sets     string
+	}{
+		{"a, b", 2, ""},
+		{"rollup(a, b)", 2, "[0 1],[0],[]"},
+		{"cube(a, b)", 2, "[0 1],[0],[1],[]"},
+		{"grouping sets ((a, b), a, ())", 2, "[0 1],[0],[]"},
+		//the cross product of the items
+		{"c, rollup(a, b)", 3, "[0 1 2],[0 1],[0]"},
+		{"cube(a), grouping sets (b, c)", 3, "[0 1],[0 2],[1],[2]"},
+		//the composite column and the duplicate group by expr
+		{"rollup((a, b), c), a", 3, "[0 1 2],[0 1],[0]"},
+	}
+	for _, tt := range tests {
+		b, err := bindSelectSQL(t, "select count(*) from "+groupingTable+" group by "+tt.groupBy)
+		require.NoError(t, err, tt.groupBy)
+		assert.Equal(t, tt.groupbys, len(b.groupbyExprs), tt.groupBy)
+		assert.Equal(t, tt.sets, groupingSetsString(b.groupingSets), tt.groupBy)
+	}
+
+	//GROUPING is bound to an aggr expr on the positions in the group by list
+	b, err := bindSelectSQL(t, "select grouping(b, a), grouping(b) from "+groupingTable+" group by rollup(a, b)")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(b.aggs))
+	positions := func(e *Expr) []int64 {
+		assert.Equal(t, ET_Grouping, e.SubTyp)
+		ret := make([]int64, 0)
+		for _, child := range e.Children {
+			ret = append(ret, child.Ivalue)
+		}
+		return ret
+	}
+	assert.Equal(t, []int64{1, 0}, positions(b.aggs[0]))
+	assert.Equal(t, []int64{1}, positions(b.aggs[1]))
+	//the group by exprs in the select list refer to the output of the aggregate
+	b, err = bindSelectSQL(t, "select a + 1 from "+groupingTable+" group by rollup(a + 1)")
+	require.NoError(t, err)
+	assert.Equal(t, ET_Column, b.projectExprs[0].Typ)
+	assert.Equal(t, uint64(b.groupTag), b.projectExprs[0].ColRef.table())
+
+	for _, sql := range []string{
+		"select a from " + groupingTable + " where grouping(a) = 0 group by a",
+		"select grouping(c) from " + groupingTable + " group by rollup(a, b)",
+		"select count(*) from " + groupingTable + " group by cube(a, b, c, a+1, a+2, a+3, a+4, a+5, a+6, a+7, a+8, a+9, a+10)",
+	} {
+		_, err = bindSelectSQL(t, sql)
+		assert.Error(t, err, sql)
+	}
+}
+
+func Test_buildGroupingSets(t *testing.T) {
+	root := genSelectPhyPlan(t, "select a, b, count(*) from "+groupingTable+" group by cube(a, b) having b = 0")
+	aggs := findOperator(root, func(root *PhysicalOperator) bool {
+		return root.Typ == POT_Agg
+	})
+	require.Equal(t, 1, len(aggs))
+	assert.Equal(t, "[0 1],[0],[1],[]", groupingSetsString(aggs[0].GroupingSets))
+	//the filter on the group by expr is not pushed down into the aggregate
+	assert.Empty(t, aggs[0].Filters)
+	filters := findOperator(root, func(root *PhysicalOperator) bool {
+		return root.Typ == POT_Filter && len(root.Children) == 1 && root.Children[0] == aggs[0]
+	})
+	assert.Equal(t, 1, len(filters))
+
+	//no grouping sets on the plain group by
+	root = genSelectPhyPlan(t, "select a, count(*) from "+groupingTable+" group by a")
+	aggs = findOperator(root, func(root *PhysicalOperator) bool {
+		return root.Typ == POT_Agg
+	})
+	require.Equal(t, 1, len(aggs))
+	assert.Empty(t, aggs[0].GroupingSets)
+}
+
+func Test_runGroupingSets(t *testing.T) {
+	tests := []struct {
+		sql    string
+		expect []string
+	}{
+		{
+			//the rows of the rolled up exprs are NULL.
+			//GROUPING is the bitmask. the last argument is the lowest bit
+			sql: "select a, b, sum(c), grouping(a, b), grouping(b) from " + groupingTable + " group by rollup(a, b)",
+			expect: []string{
+				"0|0|6|0|0", "0|1|4|0|0", "0|2|2|0|0", "0|NULL|12|1|1",
+				"1|0|3|0|0", "1|1|1|0|0", "1|2|5|0|0", "1|NULL|9|1|1",
+				"NULL|NULL|21|3|1",
+			},
+		},
+		{
+			sql: "select a, b, count(*), grouping(a, b) from " + groupingTable + " group by cube(a, b)",
+			expect: []string{
+				"0|0|1|0", "0|1|1|0", "0|2|1|0", "0|NULL|3|1",
+				"1|0|1|0", "1|1|1|0", "1|2|1|0", "1|NULL|3|1",
+				"NULL|0|2|2", "NULL|1|2|2", "NULL|2|2|2",
+				"NULL|NULL|6|3",
+			},
+		},
+		{
+			sql: "select a, b, count(*) from " + groupingTable + " group by grouping sets ((a), (b))",
+			expect: []string{
+				"0|NULL|3", "1|NULL|3",
+				"NULL|0|2", "NULL|1|2", "NULL|2|2",
+			},
+		},
+		{
+			//the filter on the group by expr is evaluated on the rolled up rows
+			sql:    "select a, b, count(*) from " + groupingTable + " group by rollup(b, a) having b = 0",
+			expect: []string{"0|0|1", "1|0|1", "NULL|0|2"},
+		},
+		{
+			sql:    "select a, count(*) from " + groupingTable + " group by rollup(a) having grouping(a) = 0",
+			expect: []string{"0|3", "1|3"},
+		},
+		//the plain group by.
+		//the group by expr a is not in the select list
+		{
+			sql:    "select b, count(*) from " + groupingTable + " group by a, b having sum(c) > 4",
+			expect: []string{"0|1", "2|1"},
+		},
+		//the aggr expr is only in the HAVING
+		{
+			sql:    "select a, count(*) from " + groupingTable + " group by a having sum(c) > 10",
+			expect: []string{"0|3"},
+		},
+		{
+			sql:    "select sum(c), b from " + groupingTable + " group by b",
+			expect: []string{"5|1", "7|2", "9|0"},
+		},
+		{
+			sql:    "select sum(c), count(*), b, sum(a) from " + groupingTable + " group by b having sum(c) > 6",
+			expect: []string{"7|2|2|1", "9|2|0|1"},
+		},
+		//no aggr exprs
+		{
+			sql:    "select b from " + groupingTable + " group by b",
+			expect: []string{"0", "1", "2"},
+		},
+		{
+			sql: "select a, b from " + groupingTable + " group by rollup(a, b)",
+			expect: []string{
+				"0|0", "0|1", "0|2", "0|NULL",
+				"1|0", "1|1", "1|2", "1|NULL",
+				"NULL|NULL",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.expect, runSelectSQL(t, tt.sql))
+		})
+	}
+}
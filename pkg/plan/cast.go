@@ -17,9 +17,12 @@ package plan
 import (
 	"fmt"
 	"math"
+	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unsafe"
 
 	dec "github.com/govalues/decimal"
 
@@ -38,12 +41,48 @@ func tryCastInt32ToInt64(input *int32, result *int64, _ bool) bool {
 	return true
 }
 
-func tryCastInt32ToHugeint(input *int32, result *common.Hugeint, _ bool) bool {
-	result.Upper = 0
+// tryCastIntToInt fails if the value is out of the range of the result
+func tryCastIntToInt[T, R int16 | int32 | int64](input *T, result *R, _ bool) bool {
+	*result = R(*input)
+	return T(*result) == *input
+}
+
+func tryCastIntToHugeint[T int16 | int32 | int64](input *T, result *common.Hugeint, _ bool) bool {
+	//sign extension
+	result.Upper = int64(*input) >> 63
 	result.Lower = uint64(*input)
 	return true
 }
 
+func tryCastNumericToBool[T int16 | int32 | int64 | float32 | float64](input *T, result *bool, _ bool) bool {
+	*result = *input != 0
+	return true
+}
+
+func tryCastBoolToNumeric[R int16 | int32 | int64 | float32 | float64](input *bool, result *R, _ bool) bool {
+	*result = 0
+	if *input {
+		*result = 1
+	}
+	return true
+}
+
+func tryCastBoolToHugeint(input *bool, result *common.Hugeint, _ bool) bool {
+	*result = common.Hugeint{}
+	if *input {
+		result.Lower = 1
+	}
+	return true
+}
+
+func tryCastBoolToDecimal(input *bool, result *common.Decimal, tScale int, _ bool) bool {
+	val := int64(0)
+	if *input {
+		val = 1
+	}
+	return tryCastInt64ToDecimal(&val, result, tScale, true)
+}
+
 func tryCastInt32ToFloat32(input *int32, result *float32, _ bool) bool {
 	*result = float32(*input)
 	return true
@@ -54,18 +93,57 @@ func tryCastInt32ToFloat64(input *int32, result *float64, _ bool) bool {
 	return true
 }
 
+func tryCastIntToFloat[T int16 | int32 | int64, R float32 | float64](input *T, result *R, _ bool) bool {
+	*result = R(*input)
+	return true
+}
+
 func tryCastInt32ToDecimal(input *int32, result *common.Decimal, tScale int, _ bool) bool {
-	nDec, err := dec.NewFromInt64(int64(*input), 0, tScale)
-	if err != nil {
-		panic(err)
+	val := int64(*input)
+	return tryCastInt64ToDecimal(&val, result, tScale, true)
+}
+
+func tryCastInt64ToInt32(input *int64, result *int32, _ bool) bool {
+	if *input < math.MinInt32 || *input > math.MaxInt32 {
+		return false
 	}
+	*result = int32(*input)
+	return true
+}
 
+func tryCastInt64ToFloat32(input *int64, result *float32, _ bool) bool {
+	*result = float32(*input)
+	return true
+}
+
+func tryCastInt64ToFloat64(input *int64, result *float64, _ bool) bool {
+	*result = float64(*input)
+	return true
+}
+
+func tryCastInt64ToDecimal(input *int64, result *common.Decimal, tScale int, _ bool) bool {
+	nDec, err := dec.NewFromInt64(*input, 0, tScale)
+	if err != nil {
+		return false
+	}
+	nDec = nDec.Pad(tScale)
+	if nDec.Scale() != tScale {
+		//no room for the fraction digits
+		return false
+	}
 	*result = common.Decimal{
 		Decimal: nDec,
 	}
 	return true
 }
 
+func tryCastDecimalToFloat64(input *common.Decimal, result *float64, _ bool) bool {
+	v, ok := input.Float64()
+	util.AssertFunc(ok)
+	*result = v
+	return true
+}
+
 func tryCastDecimalToFloat32(input *common.Decimal, result *float32, _ bool) bool {
 	v, ok := input.Float64()
 	util.AssertFunc(ok)
@@ -73,79 +151,190 @@ func tryCastDecimalToFloat32(input *common.Decimal, result *float32, _ bool) boo
 	return true
 }
 
-func tryCastBigintToInt32(input *common.Hugeint, result *int32, _ bool) bool {
-	val := int32(input.Lower)
-	if uint64(val) != input.Lower {
-		fmt.Println(input)
+func tryCastDecimalToInt[R int16 | int32 | int64](input *common.Decimal, result *R, _ bool) bool {
+	//round half away from zero
+	res, err := roundDecimal(input.Decimal, 0, false)
+	if err != nil {
+		return false
 	}
-	*result = val
+	w, _, ok := res.Int64(0)
+	if !ok {
+		return false
+	}
+	return tryCastIntToInt(&w, result, true)
+}
+
+func tryCastDecimalToHugeint(input *common.Decimal, result *common.Hugeint, _ bool) bool {
+	var w int64
+	if !tryCastDecimalToInt(input, &w, true) {
+		return false
+	}
+	return tryCastIntToHugeint(&w, result, true)
+}
+
+func tryCastDecimalToBool(input *common.Decimal, result *bool, _ bool) bool {
+	*result = !input.IsZero()
 	return true
 }
 
-func tryCastBigintToFloat32(input *common.Hugeint, result *float32, _ bool) bool {
-	switch input.Upper {
-	case -1:
-		*result = -float32(math.MaxUint64-input.Lower) - 1
-	default:
-		*result = float32(input.Lower) +
-			float32(input.Upper)*float32(math.MaxUint64)
+// hugeintToBig is Upper * 2^64 + Lower
+func hugeintToBig(input *common.Hugeint) *big.Int {
+	ret := big.NewInt(input.Upper)
+	ret.Lsh(ret, 64)
+	return ret.Add(ret, new(big.Int).SetUint64(input.Lower))
+}
+
+// bigToHugeint fails if the value is out of the range of the hugeint
+func bigToHugeint(input *big.Int, result *common.Hugeint) bool {
+	if input.BitLen() > 127 {
+		//the -2^127 is not used
+		return false
 	}
+	lower := new(big.Int).And(input, new(big.Int).SetUint64(math.MaxUint64))
+	upper := new(big.Int).Rsh(input, 64)
+	result.Lower = lower.Uint64()
+	result.Upper = upper.Int64()
 	return true
 }
 
-func tryCastBigintToDecimal(input *common.Hugeint, result *common.Decimal, _ bool) bool {
-	panic("usp")
+func tryCastHugeintToInt[R int16 | int32 | int64](input *common.Hugeint, result *R, _ bool) bool {
+	//the int64 has the upper part with the sign extension only
+	val := int64(input.Lower)
+	if input.Upper != val>>63 {
+		return false
+	}
+	return tryCastIntToInt(&val, result, true)
 }
 
-func tryCastDecimalToDecimal(input *common.Decimal, result *common.Decimal, srcScale, dstScale int, _ bool) bool {
-	//if numCast.srcScale == numCast.dstScale {
-	//	*result = *input
-	//} else if numCast.srcScale > numCast.dstScale {
-	//
-	//} else {
-	//
-	//}
-	w, f, ok := input.Int64(dstScale)
-	if ok {
-		ndec, err := dec.NewFromInt64(w, f, dstScale)
-		if err != nil {
-			panic(err)
-		}
-		result.Decimal = ndec
-	} else {
+func tryCastHugeintToFloat64(input *common.Hugeint, result *float64, _ bool) bool {
+	*result, _ = new(big.Float).SetInt(hugeintToBig(input)).Float64()
+	return true
+}
 
-		ndec, err := dec.ParseExact(input.String(), dstScale)
-		if err != nil {
-			panic(err)
-		}
-		result.Decimal = ndec
+func tryCastHugeintToFloat32(input *common.Hugeint, result *float32, _ bool) bool {
+	*result, _ = new(big.Float).SetInt(hugeintToBig(input)).Float32()
+	return true
+}
+
+func tryCastHugeintToBool(input *common.Hugeint, result *bool, _ bool) bool {
+	*result = input.Upper != 0 || input.Lower != 0
+	return true
+}
+
+func tryCastHugeintToDecimal(input *common.Hugeint, result *common.Decimal, tScale int, _ bool) bool {
+	res, err := dec.Parse(hugeintToBig(input).String())
+	if err != nil {
+		return false
 	}
+	res = res.Pad(tScale)
+	if res.Scale() != tScale {
+		//no room for the fraction digits
+		return false
+	}
+	result.Decimal = res
 	return true
 }
 
-func tryCastFloat32ToInt32(input *float32, result *int32, _ bool) bool {
-	*result = int32(*input)
+func tryCastVarcharToHugeint(input *common.String, result *common.Hugeint, _ bool) bool {
+	val, ok := new(big.Int).SetString(strings.TrimSpace(input.String()), 10)
+	if !ok {
+		return false
+	}
+	return bigToHugeint(val, result)
+}
+
+func formatHugeint(input *common.Hugeint) string {
+	return hugeintToBig(input).String()
+}
+
+// decimalFits decides the value has at most width-scale digits
+// before the decimal point.
+func decimalFits(val *common.Decimal, typ common.LType) bool {
+	return val.Prec()-val.Scale() <= typ.Width-typ.Scale
+}
+
+// tryCastDecimalToDecimal rounds half away from zero to the scale of the result.
+func tryCastDecimalToDecimal(input *common.Decimal, result *common.Decimal, _, dstScale int, _ bool) bool {
+	res, err := roundDecimal(input.Decimal, dstScale, false)
+	if err != nil {
+		return false
+	}
+	res = res.Pad(dstScale)
+	if res.Scale() != dstScale {
+		return false
+	}
+	result.Decimal = res
 	return true
 }
 
+// tryCastFloatToInt rounds half away from zero.
+// NaN, Inf and the value out of the range of the result fail.
+func tryCastFloatToInt[T float32 | float64, R int16 | int32 | int64](input *T, result *R, _ bool) bool {
+	val := math.Round(float64(*input))
+	//[-2^(n-1), 2^(n-1))
+	limit := math.Ldexp(1, int(unsafe.Sizeof(*result))*8-1)
+	if math.IsNaN(val) || val < -limit || val >= limit {
+		return false
+	}
+	*result = R(val)
+	return true
+}
+
+func tryCastFloatToHugeint[T float32 | float64](input *T, result *common.Hugeint, _ bool) bool {
+	val := math.Round(float64(*input))
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return false
+	}
+	res, _ := big.NewFloat(val).Int(nil)
+	return bigToHugeint(res, result)
+}
+
 func tryCastFloat32ToFloat64(input *float32, result *float64, _ bool) bool {
 	*result = float64(*input)
 	return true
 }
 
-func tryCastFloat32ToDecimal(input *float32, result *common.Decimal, _ bool) bool {
-	res, err := dec.NewFromFloat64(float64(*input))
+func tryCastFloat32ToDecimal(input *float32, result *common.Decimal, tScale int, _ bool) bool {
+	//the shortest representation of the float32. 1.55 instead of 1.5499999523
+	res, err := dec.Parse(strconv.FormatFloat(float64(*input), 'f', -1, 32))
 	if err != nil {
 		panic(err)
 	}
-	result.Decimal = res
+	res, err = roundDecimal(res, tScale, false)
+	if err != nil {
+		panic(err)
+	}
+	result.Decimal = res.Pad(tScale)
+	return true
+}
+
+func tryCastFloat64ToFloat32(input *float64, result *float32, _ bool) bool {
+	val := float32(*input)
+	if math.IsInf(float64(val), 0) && !math.IsInf(*input, 0) {
+		//out of the range of the float
+		return false
+	}
+	*result = val
+	return true
+}
+
+func tryCastFloat64ToDecimal(input *float64, result *common.Decimal, tScale int, _ bool) bool {
+	res, err := dec.NewFromFloat64(*input)
+	if err != nil {
+		return false
+	}
+	res, err = roundDecimal(res, tScale, false)
+	if err != nil {
+		return false
+	}
+	result.Decimal = res.Pad(tScale)
 	return true
 }
 
 func tryCastVarcharToDate(input *common.String, result *common.Date, _ bool) bool {
-	ti, err := time.Parse(time.DateOnly, input.String())
+	ti, err := time.Parse(time.DateOnly, strings.TrimSpace(input.String()))
 	if err != nil {
-		panic(err)
+		return false
 	}
 	y, m, d := ti.Date()
 	*result = common.Date{
@@ -157,40 +346,187 @@ func tryCastVarcharToDate(input *common.String, result *common.Date, _ bool) boo
 }
 
 func tryCastVarcharToInterval(input *common.String, result *common.Interval, _ bool) bool {
-	is := input.String()
-	seps := strings.Split(is, " ")
-	if len(seps) != 2 {
-		panic(fmt.Sprintf("invalid interval string %v", is))
-	}
-	switch strings.ToLower(seps[1]) {
-	case "year":
-		result.Unit = "year"
-		parseInt, err := strconv.ParseInt(seps[0], 10, 64)
-		if err != nil {
-			panic(fmt.Sprintf("invalid interval string %v", is))
-		}
-		result.Year = int32(parseInt)
-	case "month":
-		result.Unit = "month"
-		parseInt, err := strconv.ParseInt(seps[0], 10, 64)
-		if err != nil {
-			panic(fmt.Sprintf("invalid interval string %v", is))
-		}
-		result.Months = int32(parseInt)
+	interval, err := common.ParseInterval(input.String())
+	if err != nil {
+		return false
+	}
+	*result = interval
+	return true
+}
 
-	case "day":
-		result.Unit = "day"
-		parseInt, err := strconv.ParseInt(seps[0], 10, 64)
-		if err != nil {
-			panic(fmt.Sprintf("invalid interval string %v", is))
-		}
-		result.Days = int32(parseInt)
+func tryCastVarcharToTimestamp(input *common.String, result *int64, _ bool) bool {
+	ts, err := common.ParseTimestamp(input.String())
+	if err != nil {
+		return false
+	}
+	*result = ts
+	return true
+}
+
+func tryCastVarcharToTime(input *common.String, result *int64, _ bool) bool {
+	t, err := common.ParseTime(input.String())
+	if err != nil {
+		return false
+	}
+	*result = t
+	return true
+}
+
+func tryCastVarcharToBlob(input *common.String, result *common.String, _ bool) bool {
+	data, err := common.ParseBlob(input.String())
+	if err != nil {
+		return false
+	}
+	makeString(data, result)
+	return true
+}
+
+func tryCastVarcharToUUID(input *common.String, result *common.Hugeint, _ bool) bool {
+	uuid, err := common.ParseUUID(input.String())
+	if err != nil {
+		return false
+	}
+	*result = uuid
+	return true
+}
+
+func tryCastVarcharToBool(input *common.String, result *bool, _ bool) bool {
+	switch strings.ToLower(strings.TrimSpace(input.String())) {
+	case "true", "t", "yes", "y", "on", "1":
+		*result = true
+	case "false", "f", "no", "n", "off", "0":
+		*result = false
 	default:
-		panic(fmt.Sprintf("invalid interval unit %v", is))
+		return false
+	}
+	return true
+}
+
+func tryCastVarcharToInt16(input *common.String, result *int16, _ bool) bool {
+	v, err := strconv.ParseInt(strings.TrimSpace(input.String()), 10, 16)
+	if err != nil {
+		return false
+	}
+	*result = int16(v)
+	return true
+}
+
+func tryCastVarcharToInt32(input *common.String, result *int32, _ bool) bool {
+	v, err := strconv.ParseInt(strings.TrimSpace(input.String()), 10, 32)
+	if err != nil {
+		return false
+	}
+	*result = int32(v)
+	return true
+}
+
+func tryCastVarcharToInt64(input *common.String, result *int64, _ bool) bool {
+	v, err := strconv.ParseInt(strings.TrimSpace(input.String()), 10, 64)
+	if err != nil {
+		return false
+	}
+	*result = v
+	return true
+}
+
+func tryCastVarcharToFloat32(input *common.String, result *float32, _ bool) bool {
+	v, err := strconv.ParseFloat(strings.TrimSpace(input.String()), 32)
+	if err != nil {
+		return false
+	}
+	*result = float32(v)
+	return true
+}
+
+func tryCastVarcharToFloat64(input *common.String, result *float64, _ bool) bool {
+	v, err := strconv.ParseFloat(strings.TrimSpace(input.String()), 64)
+	if err != nil {
+		return false
 	}
+	*result = v
 	return true
 }
 
+func tryCastVarcharToDecimal(input *common.String, result *common.Decimal, tScale int, _ bool) bool {
+	res, err := dec.Parse(strings.TrimSpace(input.String()))
+	if err != nil {
+		return false
+	}
+	res, err = roundDecimal(res, tScale, false)
+	if err != nil {
+		return false
+	}
+	result.Decimal = res.Pad(tScale)
+	return true
+}
+
+func tryCastDateToTimestamp(input *common.Date, result *int64, _ bool) bool {
+	*result = common.DateToTimestamp(input)
+	return true
+}
+
+func tryCastTimestampToDate(input *int64, result *common.Date, _ bool) bool {
+	*result = common.TimestampToDate(*input)
+	return true
+}
+
+func tryCastTimestampToTime(input *int64, result *int64, _ bool) bool {
+	*result = common.TimestampToTimeOfDay(*input)
+	return true
+}
+
+// tryCastToVarchar makes the cast that formats the value into the text
+func tryCastToVarchar[T any](format func(*T) string) CastOp[T, common.String] {
+	return func(input *T, result *common.String, _ bool) bool {
+		makeString([]byte(format(input)), result)
+		return true
+	}
+}
+
+func formatBool(input *bool) string {
+	return strconv.FormatBool(*input)
+}
+
+func formatInt[T int16 | int32 | int64](input *T) string {
+	return strconv.FormatInt(int64(*input), 10)
+}
+
+func formatFloat32(input *float32) string {
+	return strconv.FormatFloat(float64(*input), 'g', -1, 32)
+}
+
+func formatFloat64(input *float64) string {
+	return strconv.FormatFloat(*input, 'g', -1, 64)
+}
+
+func formatDecimal(input *common.Decimal) string {
+	return input.String()
+}
+
+func formatDate(input *common.Date) string {
+	return input.ToDate().Format(time.DateOnly)
+}
+
+func formatTimestamp(input *int64) string {
+	return common.TimestampToString(*input)
+}
+
+func formatTime(input *int64) string {
+	return common.TimeToString(*input)
+}
+
+func formatInterval(input *common.Interval) string {
+	return input.String()
+}
+
+func formatBlob(input *common.String) string {
+	return common.BlobToString(input.DataSlice())
+}
+
+func formatUUID(input *common.Hugeint) string {
+	return common.UUIDToString(input)
+}
+
 func castExec(
 	source, result *chunk.Vector,
 	count int,
@@ -199,13 +535,21 @@ func castExec(
 }
 
 func AddCastToType(expr *Expr, dstTyp common.LType, tryCast bool) (*Expr, error) {
-	var err error
 	var retExpr *Expr
 	if expr.DataTyp.Equal(dstTyp) {
 		return expr, nil
 	}
 
-	castInfo := castFuncs.GetCastFunc(expr.DataTyp, dstTyp)
+	castInfo, err := castFuncs.GetCastFunc(expr.DataTyp, dstTyp)
+	if err != nil {
+		return nil, err
+	}
+	if tryCast {
+		castInfo = &BoundCastInfo{
+			_fun:      castInfo._fun,
+			_castData: &BoundCastData{_tryCast: true},
+		}
+	}
 
 	args := []*Expr{
 		expr, //expr to be cast
@@ -233,7 +577,7 @@ func AddCastToType(expr *Expr, dstTyp common.LType, tryCast bool) (*Expr, error)
 		},
 	}
 
-	return retExpr, err
+	return retExpr, nil
 }
 
 //lint:ignore U1000
@@ -271,9 +615,9 @@ var (
 	}
 )
 
-func (castSet *CastFunctionSet) GetCastFunc(src, dst common.LType) *BoundCastInfo {
+func (castSet *CastFunctionSet) GetCastFunc(src, dst common.LType) (*BoundCastInfo, error) {
 	if src.Equal(dst) {
-		return defNoCast
+		return defNoCast, nil
 	}
 
 	for i := len(castSet._bindFuncs); i > 0; i-- {
@@ -284,10 +628,26 @@ func (castSet *CastFunctionSet) GetCastFunc(src, dst common.LType) *BoundCastInf
 		}
 		ret := bindFunc._fun(input, src, dst)
 		if ret != nil && ret._fun != nil {
-			return ret
+			return ret, nil
 		}
 	}
-	panic("usp")
+	return nil, fmt.Errorf("unsupported cast from %s to %s", castTypeName(src), castTypeName(dst))
+}
+
+// castValueString formats the value in the cast error
+func castValueString(input any) string {
+	if str, ok := input.(fmt.Stringer); ok {
+		return fmt.Sprintf("'%s'", str.String())
+	}
+	return fmt.Sprintf("%v", reflect.ValueOf(input).Elem())
+}
+
+func castTypeName(typ common.LType) string {
+	name := strings.TrimPrefix(typ.Id.String(), "LTID_")
+	if typ.Id == common.LTID_DECIMAL {
+		return fmt.Sprintf("%s(%d,%d)", name, typ.Width, typ.Scale)
+	}
+	return name
 }
 
 func NewCastFunctionSet() *CastFunctionSet {
@@ -0,0 +1,255 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func Test_castMatrix(t *testing.T) {
+	pairs := [][2]common.LType{
+		{common.VarcharType(), common.TimestampType()},
+		{common.TimestampType(), common.VarcharType()},
+		{common.DateType(), common.TimestampType()},
+		{common.TimestampType(), common.DateType()},
+		{common.IntervalType(), common.VarcharType()},
+		{common.VarcharType(), common.MakeLType(common.LTID_UUID)},
+		{common.MakeLType(common.LTID_BLOB), common.VarcharType()},
+		{common.Null(), common.IntegerType()},
+	}
+	for _, pair := range pairs {
+		info, err := castFuncs.GetCastFunc(pair[0], pair[1])
+		require.NoError(t, err, "%v -> %v", pair[0].Id, pair[1].Id)
+		require.NotNil(t, info._fun)
+	}
+	_, err := castFuncs.GetCastFunc(common.DateType(), common.MakeLType(common.LTID_UUID))
+	assert.EqualError(t, err, "unsupported cast from DATE to UUID")
+}
+
+func Test_castOps(t *testing.T) {
+	var str common.String
+	var ts int64
+	makeString([]byte("2024-01-02 03:04:05.5"), &str)
+	require.True(t, tryCastVarcharToTimestamp(&str, &ts, false))
+	assert.Equal(t, "2024-01-02 03:04:05.5", formatTimestamp(&ts))
+
+	var tod int64
+	require.True(t, tryCastTimestampToTime(&ts, &tod, false))
+	assert.Equal(t, "03:04:05.5", formatTime(&tod))
+
+	var i32 int32
+	makeString([]byte("abc"), &str)
+	assert.False(t, tryCastVarcharToInt32(&str, &i32, false))
+	i64 := int64(1 << 40)
+	assert.False(t, tryCastInt64ToInt32(&i64, &i32, false))
+
+	var interval common.Interval
+	makeString([]byte("1 year 3 days 01:00:00 ago"), &str)
+	require.True(t, tryCastVarcharToInterval(&str, &interval, false))
+	assert.Equal(t, "-1 year -3 days -01:00:00", formatInterval(&interval))
+}
+
+func Test_castNumericMatrix(t *testing.T) {
+	boolean := common.BooleanType()
+	smallint := common.SmallintType()
+	integer := common.IntegerType()
+	bigint := common.BigintType()
+	hugeint := common.HugeintType()
+	float := common.FloatType()
+	double := common.DoubleType()
+	decimal := common.DecimalType(10, 2)
+	narrow := common.DecimalType(3, 1)
+	varchar := common.VarcharType()
+
+	//every pair of the numeric types and the varchar
+	typs := []common.LType{boolean, smallint, integer, bigint, hugeint, float, double, decimal, varchar}
+	for _, src := range typs {
+		for _, dst := range typs {
+			_, err := castFuncs.GetCastFunc(src, dst)
+			assert.NoError(t, err, "%v -> %v", src.Id, dst.Id)
+		}
+	}
+
+	//the result is NULL on failure
+	castValue := func(val *chunk.Value, dst common.LType) (string, bool) {
+		info, err := castFuncs.GetCastFunc(val.Typ, dst)
+		require.NoError(t, err)
+		src := chunk.NewFlatVector(val.Typ, 1)
+		src.SetValue(0, val)
+		res := chunk.NewFlatVector(dst, 1)
+		ok := info._fun(src, res, 1, &CastParams{})
+		if dst.Id == common.LTID_DECIMAL && ok {
+			//keep the trailing zeros of the scale
+			return formatDecimal(&chunk.GetSliceInPhyFormatFlat[common.Decimal](res)[0]), ok
+		}
+		return res.GetValue(0).String(), ok
+	}
+	hugeintValue := func(upper int64, lower uint64) *chunk.Value {
+		return &chunk.Value{Typ: hugeint, I64: upper, I64_1: int64(lower)}
+	}
+	const fail = "NULL"
+	tests := []struct {
+		val    *chunk.Value
+		dst    common.LType
+		expect string
+	}{
+		{&chunk.Value{Typ: boolean, Bool: true}, integer, "1"},
+		{&chunk.Value{Typ: boolean, Bool: true}, bigint, "1"},
+		{&chunk.Value{Typ: boolean, Bool: true}, hugeint, "1"},
+		{&chunk.Value{Typ: boolean, Bool: false}, float, "0"},
+		{&chunk.Value{Typ: boolean, Bool: true}, double, "1"},
+		{&chunk.Value{Typ: boolean, Bool: true}, decimal, "1.00"},
+		{&chunk.Value{Typ: boolean, Bool: true}, varchar, "true"},
+
+		{&chunk.Value{Typ: smallint, I64: 7}, boolean, "true"},
+		{&chunk.Value{Typ: smallint, I64: -7}, integer, "-7"},
+		{&chunk.Value{Typ: smallint, I64: math.MaxInt16}, bigint, "32767"},
+		{&chunk.Value{Typ: smallint, I64: -1}, hugeint, "-1"},
+		{&chunk.Value{Typ: smallint, I64: 7}, double, "7"},
+		{&chunk.Value{Typ: smallint, I64: 7}, decimal, "7.00"},
+		{&chunk.Value{Typ: smallint, I64: 700}, narrow, fail},
+		{&chunk.Value{Typ: smallint, I64: -7}, varchar, "-7"},
+
+		{&chunk.Value{Typ: integer, I64: -7}, smallint, "-7"},
+		{&chunk.Value{Typ: integer, I64: math.MaxInt16 + 1}, smallint, fail},
+		{&chunk.Value{Typ: integer, I64: 7}, boolean, "true"},
+		{&chunk.Value{Typ: integer, I64: 0}, boolean, "false"},
+		{&chunk.Value{Typ: integer, I64: -7}, bigint, "-7"},
+		{&chunk.Value{Typ: integer, I64: -1}, hugeint, "-1"},
+		{&chunk.Value{Typ: integer, I64: 7}, float, "7"},
+		{&chunk.Value{Typ: integer, I64: 7}, double, "7"},
+		{&chunk.Value{Typ: integer, I64: -7}, decimal, "-7.00"},
+		{&chunk.Value{Typ: integer, I64: -7}, varchar, "-7"},
+
+		{&chunk.Value{Typ: bigint, I64: 3}, boolean, "true"},
+		{&chunk.Value{Typ: bigint, I64: math.MaxInt32}, integer, "2147483647"},
+		{&chunk.Value{Typ: bigint, I64: math.MaxInt32 + 1}, integer, fail},
+		{&chunk.Value{Typ: bigint, I64: math.MinInt64}, hugeint, "-9223372036854775808"},
+		{&chunk.Value{Typ: bigint, I64: 1<<24 + 1}, float, "1.6777216e+07"},
+		{&chunk.Value{Typ: bigint, I64: 1 << 40}, double, "1.099511627776e+12"},
+		{&chunk.Value{Typ: bigint, I64: 12}, decimal, "12.00"},
+		{&chunk.Value{Typ: bigint, I64: 1e18}, decimal, fail},
+		{&chunk.Value{Typ: bigint, I64: 1e8}, decimal, fail},
+		{&chunk.Value{Typ: bigint, I64: 1 << 40}, varchar, "1099511627776"},
+
+		{hugeintValue(0, 0), boolean, "false"},
+		{hugeintValue(-1, math.MaxUint64-6), integer, "-7"},
+		{hugeintValue(0, math.MaxInt32+1), integer, fail},
+		{hugeintValue(0, math.MaxInt64), bigint, "9223372036854775807"},
+		{hugeintValue(1, 0), bigint, fail},
+		{hugeintValue(-1, 0), bigint, fail},
+		{hugeintValue(-1, math.MaxUint64-6), float, "-7"},
+		{hugeintValue(1, 0), double, "1.8446744073709552e+19"},
+		{hugeintValue(-1, math.MaxUint64-6), decimal, "-7.00"},
+		{hugeintValue(1, 0), decimal, fail},
+		{hugeintValue(1, 0), varchar, "18446744073709551616"},
+		{hugeintValue(math.MinInt64, 1), varchar, "-170141183460469231731687303715884105727"},
+
+		{&chunk.Value{Typ: float, F64: 0.5}, boolean, "true"},
+		{&chunk.Value{Typ: float, F64: 2.5}, integer, "3"},
+		{&chunk.Value{Typ: float, F64: -2.5}, integer, "-3"},
+		{&chunk.Value{Typ: float, F64: 1e10}, integer, fail},
+		{&chunk.Value{Typ: float, F64: math.NaN()}, integer, fail},
+		{&chunk.Value{Typ: float, F64: 1e10}, bigint, "10000000000"},
+		{&chunk.Value{Typ: float, F64: -2.5}, bigint, "-3"},
+		{&chunk.Value{Typ: float, F64: 1e19}, bigint, fail},
+		{&chunk.Value{Typ: float, F64: 1e19}, hugeint, "9999999980506447872"},
+		{&chunk.Value{Typ: float, F64: math.Inf(1)}, hugeint, fail},
+		{&chunk.Value{Typ: float, F64: 0.25}, double, "0.25"},
+		{&chunk.Value{Typ: float, F64: 1.555}, decimal, "1.56"},
+		{&chunk.Value{Typ: float, F64: 0.25}, varchar, "0.25"},
+
+		{&chunk.Value{Typ: double, F64: 0}, boolean, "false"},
+		{&chunk.Value{Typ: double, F64: -0.4}, integer, "0"},
+		{&chunk.Value{Typ: double, F64: 2147483647.4}, integer, "2147483647"},
+		{&chunk.Value{Typ: double, F64: 2147483647.5}, integer, fail},
+		{&chunk.Value{Typ: double, F64: 9.3e18}, bigint, fail},
+		{&chunk.Value{Typ: double, F64: -9.2e18}, bigint, "-9200000000000000000"},
+		{&chunk.Value{Typ: double, F64: 1e30}, hugeint, "1000000000000000019884624838656"},
+		{&chunk.Value{Typ: double, F64: 1e40}, hugeint, fail},
+		{&chunk.Value{Typ: double, F64: 2.5}, float, "2.5"},
+		{&chunk.Value{Typ: double, F64: 1e300}, float, fail},
+		{&chunk.Value{Typ: double, F64: 2.345}, decimal, "2.35"},
+		{&chunk.Value{Typ: double, F64: 99.96}, narrow, fail},
+		{&chunk.Value{Typ: double, F64: 2.5}, smallint, "3"},
+		{&chunk.Value{Typ: double, F64: 2.5}, varchar, "2.5"},
+
+		{&chunk.Value{Typ: decimal, Str: "0.00"}, boolean, "false"},
+		{&chunk.Value{Typ: decimal, Str: "2.50"}, integer, "3"},
+		{&chunk.Value{Typ: decimal, Str: "-2.50"}, integer, "-3"},
+		{&chunk.Value{Typ: decimal, Str: "2.49"}, integer, "2"},
+		{&chunk.Value{Typ: decimal, Str: "3000000000.00"}, integer, fail},
+		{&chunk.Value{Typ: decimal, Str: "3000000000.00"}, bigint, "3000000000"},
+		{&chunk.Value{Typ: decimal, Str: "-2.50"}, hugeint, "-3"},
+		{&chunk.Value{Typ: decimal, Str: "2.50"}, float, "2.5"},
+		{&chunk.Value{Typ: decimal, Str: "-2.25"}, double, "-2.25"},
+		{&chunk.Value{Typ: decimal, Str: "-2.25"}, varchar, "-2.25"},
+		{&chunk.Value{Typ: decimal, Str: "12.35"}, narrow, "12.4"},
+		{&chunk.Value{Typ: decimal, Str: "123.45"}, narrow, fail},
+		{&chunk.Value{Typ: decimal, Str: "-300.00"}, smallint, "-300"},
+
+		{&chunk.Value{Typ: varchar, Str: "yes"}, boolean, "true"},
+		{&chunk.Value{Typ: varchar, Str: "abc"}, integer, fail},
+		{&chunk.Value{Typ: varchar, Str: "32768"}, smallint, fail},
+		{&chunk.Value{Typ: varchar, Str: "2147483648"}, integer, fail},
+		{&chunk.Value{Typ: varchar, Str: " -12 "}, bigint, "-12"},
+		{&chunk.Value{Typ: varchar, Str: "170141183460469231731687303715884105727"}, hugeint,
+			"170141183460469231731687303715884105727"},
+		{&chunk.Value{Typ: varchar, Str: "170141183460469231731687303715884105728"}, hugeint, fail},
+		{&chunk.Value{Typ: varchar, Str: "1.5"}, float, "1.5"},
+		{&chunk.Value{Typ: varchar, Str: "1e-3"}, double, "0.001"},
+		{&chunk.Value{Typ: varchar, Str: "1.005"}, decimal, "1.01"},
+	}
+	for _, tt := range tests {
+		res, ok := castValue(tt.val, tt.dst)
+		assert.Equal(t, tt.expect, res, "%v %v -> %v", tt.val.Typ.Id, tt.val, tt.dst.Id)
+		assert.Equal(t, tt.expect != fail, ok, "%v %v -> %v", tt.val.Typ.Id, tt.val, tt.dst.Id)
+	}
+}
+
+func Test_castSQL(t *testing.T) {
+	from := " from generate_series(1,1) t(i)"
+	tests := []struct {
+		sql    string
+		expect string
+	}{
+		{"select cast('1.23456' as numeric)", "1.23456"},
+		{"select cast(1.25 as numeric), cast(42 as decimal)", "1.25|42"},
+		{"select cast(123.678 as decimal(4,1))", "123.7"},
+		{"select try_cast(12345.678 as decimal(4,1))", "NULL"},
+		{"select cast(42 as smallint), cast('-7' as int2) + 1", "42|-6"},
+		{"select try_cast(100000 as smallint)", "NULL"},
+		//float is the double precision
+		{"select cast(0.1 as float) = cast(0.1 as double)", "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, []string{tt.expect}, runSelectSQL(t, tt.sql+from))
+		})
+	}
+
+	assert.Panics(t, func() {
+		runSelectSQL(t, "select cast(12345.678 as decimal(4,1))"+from)
+	})
+	_, err := bindSelectSQL(t, "select cast(cast(i as double) as numeric)"+from)
+	assert.ErrorContains(t, err, "needs the precision and scale")
+}
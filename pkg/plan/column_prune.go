@@ -16,12 +16,12 @@ package plan
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/daviszhen/plan/pkg/common"
 	"github.com/daviszhen/plan/pkg/storage"
-	"github.com/daviszhen/plan/pkg/util"
 )
 
 type ColumnBindCountMap map[ColumnBind]int
@@ -321,6 +321,9 @@ func (cp *ColumnPrune) prune(root *LogicalOperator) (*LogicalOperator, error) {
 	case LOT_Limit:
 	case LOT_Order:
 		cp.colRefs.addExpr(root.OrderBys...)
+	case LOT_Unnest:
+		//the unnest decides the row count. keep all of them.
+		cp.colRefs.addExpr(root.Projects...)
 	case LOT_Project:
 		cmap := make(ColumnBindMap)
 		newId := uint64(0)
@@ -342,9 +345,9 @@ func (cp *ColumnPrune) prune(root *LogicalOperator) (*LogicalOperator, error) {
 				return nil, err
 			}
 		}
-		//remove unused columns
+		//remove unused columns. keep the order of the rest
 		for i := len(removed) - 1; i >= 0; i-- {
-			root.Projects = util.Erase(root.Projects, removed[i])
+			root.Projects = slices.Delete(root.Projects, removed[i], removed[i]+1)
 		}
 		cp.colRefs.replaceAll(cmap)
 		if len(root.Projects) == 0 {
@@ -378,12 +381,12 @@ func (cp *ColumnPrune) prune(root *LogicalOperator) (*LogicalOperator, error) {
 				return nil, err
 			}
 		}
-		//remove unused columns
+		//remove unused columns. keep the order of the rest
 		for i := len(removed) - 1; i >= 0; i-- {
-			root.Aggs = util.Erase(root.Aggs, removed[i])
+			root.Aggs = slices.Delete(root.Aggs, removed[i], removed[i]+1)
 		}
 		cp.colRefs.replaceAll(cmap)
-		if len(root.Aggs) == 0 {
+		if len(root.Aggs) == 0 && len(root.GroupBys) == 0 {
 			return root.Children[0], nil
 		}
 		return root, nil
@@ -408,9 +411,9 @@ func (cp *ColumnPrune) prune(root *LogicalOperator) (*LogicalOperator, error) {
 			//	}
 			//	columns = catalogTable.Columns
 			//}
-		case ScanTypeValuesList:
+		case ScanTypeValuesList, ScanTypeSeries:
 			columns = root.Names
-		case ScanTypeCopyFrom:
+		case ScanTypeCopyFrom, ScanTypeTableFunc:
 			columns = root.ScanInfo.Names
 		}
 
@@ -529,7 +532,7 @@ func (update *countsUpdater) generateCounts(root *LogicalOperator, upCounts Colu
 		if err != nil {
 			return nil, err
 		}
-	case LOT_Project:
+	case LOT_Project, LOT_Unnest:
 		colRefOnThisNode = upCounts.splitByTableIdx(root.Index)
 		err = updateCounts(upCounts, root.Projects...)
 		if err != nil {
@@ -716,7 +719,7 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 			})
 		}
 
-	case LOT_Project:
+	case LOT_Project, LOT_Unnest:
 		err = genChildren()
 		if err != nil {
 			return nil, err
@@ -728,9 +731,13 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 		for _, bind := range binds {
 			if bind.table() == root.Index {
 				proj := root.Projects[bind.column()]
+				dataTyp := proj.DataTyp
+				if root.Typ == LOT_Unnest {
+					dataTyp = dataTyp.ListChild()
+				}
 				root.Outputs = append(root.Outputs, &Expr{
 					Typ:      ET_Column,
-					DataTyp:  proj.DataTyp,
+					DataTyp:  dataTyp,
 					Database: proj.Database,
 					Table:    proj.Table,
 					Name:     proj.Name,
@@ -776,7 +783,13 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 
 		binds := root.ColRefToPos.sortByColumnBind()
 		for _, bind := range binds {
-			colIdx := len(root.Outputs)
+			//the output of the aggregate is [group by exprs, aggr exprs].
+			//the position is decided by the bind instead of the count
+			//of the referred ones. the unreferred group by exprs
+			//(not in the select list or NULL in the grouping set) and
+			//the aggr exprs only referred by the HAVING are still
+			//in the output.
+			colIdx := int(bind.column())
 			if bind.table() == root.Index {
 				groupby := root.GroupBys[bind.column()]
 				root.Outputs = append(root.Outputs, &Expr{
@@ -790,11 +803,10 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 				continue
 			}
 		}
-		rIdx := 0
 		for _, bind := range binds {
 			if bind.table() == root.Index2 {
-				colIdx := len(root.GroupBys) + rIdx
-				rIdx++
+				//the aggregates are after the group by exprs
+				colIdx := len(root.GroupBys) + int(bind.column())
 				agg := root.Aggs[bind.column()]
 				root.Outputs = append(root.Outputs, &Expr{
 					Typ:      ET_Column,
@@ -857,7 +869,7 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 					collectColRefs(cond.Children[0], lset)
 					collectColRefs(cond.Children[1], rset)
 				case ET_SubFunc:
-				case ET_And, ET_Or, ET_Equal, ET_NotEqual, ET_Like, ET_NotLike, ET_GreaterEqual, ET_Less, ET_Greater:
+				case ET_And, ET_Or, ET_Equal, ET_NotEqual, ET_Like, ET_NotLike, ET_GreaterEqual, ET_Less, ET_LessEqual, ET_Greater:
 					collectColRefs(cond.Children[0], lset)
 					collectColRefs(cond.Children[1], rset)
 				default:
@@ -954,10 +966,10 @@ func (update *outputsUpdater) generateOutputs(root *LogicalOperator) (*LogicalOp
 				//column2Idx = catalogTable.Column2Idx
 				//columnTyps = catalogTable.Types
 			}
-		case ScanTypeValuesList:
+		case ScanTypeValuesList, ScanTypeSeries:
 			column2Idx = root.ColName2Idx
 			columnTyps = root.Types
-		case ScanTypeCopyFrom:
+		case ScanTypeCopyFrom, ScanTypeTableFunc:
 			column2Idx = root.ColName2Idx
 			columnTyps = root.ScanInfo.ReturnedTypes
 		default:
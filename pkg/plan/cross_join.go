@@ -358,6 +358,11 @@ func (cdc *ColumnDataCollection) ReadVector(
 	if count == 0 {
 		return 0
 	}
+	if dstVecType.IsNested() {
+		//share the child vectors
+		dstVec.Reference(srcVec)
+		return count
+	}
 	srcPtr := util.BytesSliceToPointer(srcVec.Data)
 	dstPtr := util.BytesSliceToPointer(dstVec.Data)
 	dstBitmap := chunk.GetMaskInPhyFormatFlat(dstVec)
@@ -403,6 +408,54 @@ type BaseValueCopy[T any] interface {
 	Operation(dst, src *T)
 }
 
+type boolValueCopy struct {
+}
+
+func (copy *boolValueCopy) Assign(
+	metaData *ColumnDataMetaData,
+	dst, src unsafe.Pointer,
+	dstIdx, srcIdx int) {
+	dPtr := util.PointerAdd(dst, dstIdx*common.BoolSize)
+	sPtr := util.PointerAdd(src, srcIdx*common.BoolSize)
+	copy.Operation((*bool)(dPtr), (*bool)(sPtr))
+}
+
+func (copy *boolValueCopy) Operation(dst, src *bool) {
+	*dst = *src
+}
+
+type int8ValueCopy struct {
+}
+
+func (copy *int8ValueCopy) Assign(
+	metaData *ColumnDataMetaData,
+	dst, src unsafe.Pointer,
+	dstIdx, srcIdx int) {
+	dPtr := util.PointerAdd(dst, dstIdx*common.Int8Size)
+	sPtr := util.PointerAdd(src, srcIdx*common.Int8Size)
+	copy.Operation((*int8)(dPtr), (*int8)(sPtr))
+}
+
+func (copy *int8ValueCopy) Operation(dst, src *int8) {
+	*dst = *src
+}
+
+type int16ValueCopy struct {
+}
+
+func (copy *int16ValueCopy) Assign(
+	metaData *ColumnDataMetaData,
+	dst, src unsafe.Pointer,
+	dstIdx, srcIdx int) {
+	dPtr := util.PointerAdd(dst, dstIdx*common.Int16Size)
+	sPtr := util.PointerAdd(src, srcIdx*common.Int16Size)
+	copy.Operation((*int16)(dPtr), (*int16)(sPtr))
+}
+
+func (copy *int16ValueCopy) Operation(dst, src *int16) {
+	*dst = *src
+}
+
 type int32ValueCopy struct {
 }
 
@@ -451,6 +504,22 @@ func (copy *float32ValueCopy) Operation(dst, src *float32) {
 	*dst = *src
 }
 
+type float64ValueCopy struct {
+}
+
+func (copy *float64ValueCopy) Assign(
+	metaData *ColumnDataMetaData,
+	dst, src unsafe.Pointer,
+	dstIdx, srcIdx int) {
+	dPtr := util.PointerAdd(dst, dstIdx*common.Int64Size)
+	sPtr := util.PointerAdd(src, srcIdx*common.Int64Size)
+	copy.Operation((*float64)(dPtr), (*float64)(sPtr))
+}
+
+func (copy *float64ValueCopy) Operation(dst, src *float64) {
+	*dst = *src
+}
+
 type decimalValueCopy struct {
 }
 
@@ -483,6 +552,22 @@ func (copy *dateValueCopy) Operation(dst, src *common.Date) {
 	*dst = *src
 }
 
+type intervalValueCopy struct {
+}
+
+func (copy *intervalValueCopy) Assign(
+	metaData *ColumnDataMetaData,
+	dst, src unsafe.Pointer,
+	dstIdx, srcIdx int) {
+	dPtr := util.PointerAdd(dst, dstIdx*common.IntervalSize)
+	sPtr := util.PointerAdd(src, srcIdx*common.IntervalSize)
+	copy.Operation((*common.Interval)(dPtr), (*common.Interval)(sPtr))
+}
+
+func (copy *intervalValueCopy) Operation(dst, src *common.Interval) {
+	*dst = *src
+}
+
 type hugeintValueCopy struct {
 }
 
@@ -530,6 +615,33 @@ func ColumnDataCopySwitch(
 	count int,
 ) {
 	switch src.Typ().GetInternalType() {
+	case common.BOOL:
+		TemplatedColumnDataCopy[bool](
+			metaData,
+			srcData,
+			src,
+			offset,
+			count,
+			&boolValueCopy{},
+		)
+	case common.INT8:
+		TemplatedColumnDataCopy[int8](
+			metaData,
+			srcData,
+			src,
+			offset,
+			count,
+			&int8ValueCopy{},
+		)
+	case common.INT16:
+		TemplatedColumnDataCopy[int16](
+			metaData,
+			srcData,
+			src,
+			offset,
+			count,
+			&int16ValueCopy{},
+		)
 	case common.INT32:
 		TemplatedColumnDataCopy[int32](
 			metaData,
@@ -557,6 +669,15 @@ func ColumnDataCopySwitch(
 			count,
 			&float32ValueCopy{},
 		)
+	case common.DOUBLE:
+		TemplatedColumnDataCopy[float64](
+			metaData,
+			srcData,
+			src,
+			offset,
+			count,
+			&float64ValueCopy{},
+		)
 	case common.DECIMAL:
 		TemplatedColumnDataCopy[common.Decimal](
 			metaData,
@@ -584,6 +705,15 @@ func ColumnDataCopySwitch(
 			count,
 			&dateValueCopy{},
 		)
+	case common.INTERVAL:
+		TemplatedColumnDataCopy[common.Interval](
+			metaData,
+			srcData,
+			src,
+			offset,
+			count,
+			&intervalValueCopy{},
+		)
 	case common.INT128:
 		TemplatedColumnDataCopy[common.Hugeint](
 			metaData,
@@ -593,11 +723,33 @@ func ColumnDataCopySwitch(
 			count,
 			&hugeintValueCopy{},
 		)
+	case common.LIST, common.STRUCT:
+		nestedColumnDataCopy(metaData, src, offset, count)
 	default:
 		panic("usp")
 	}
 }
 
+// nestedColumnDataCopy copies LIST, MAP and STRUCT values one by one
+// into the child vectors of the destination.
+func nestedColumnDataCopy(
+	metaData *ColumnDataMetaData,
+	src *chunk.Vector,
+	offset int,
+	count int,
+) {
+	vec := metaData._dst.Data[metaData._vecIdx]
+	if count > util.DefaultVectorSize-metaData._dst.Card() {
+		panic("usp")
+	}
+	if metaData._dst.Card() == 0 {
+		vec.Mask.SetAllValid(util.DefaultVectorSize)
+	}
+	for i := 0; i < count; i++ {
+		vec.SetValue(metaData._dst.Card()+i, src.GetValue(offset+i))
+	}
+}
+
 func TemplatedColumnDataCopy[T any](
 	metaData *ColumnDataMetaData,
 	srcData *chunk.UnifiedFormat,
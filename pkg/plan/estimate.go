@@ -284,7 +284,7 @@ func (est *CardinalityEstimator) EstimateBaseTableCard(node *JoinNode, op *Logic
 	{
 		cardAfterFilters := node.getBaseCard()
 		//key := ColumnBind{relId, col}
-		//var tableFilters *TableFilterSet
+		//var tableFilters *storage.TableFilterSet
 		//if est.relationColumnToOriginalColumn.find(key) {
 		//	//actualBind := est.relationColumnToOriginalColumn.get(key)
 		//	//tableFilters = est.GetTableFilters(op, actualBind[0])
@@ -300,11 +300,10 @@ func (est *CardinalityEstimator) EstimateBaseTableCard(node *JoinNode, op *Logic
 	node.setEstimatedCard(lowestCardFound)
 }
 
-func (est *CardinalityEstimator) GetTableFilters(op *LogicalOperator, tableIndex uint64) *TableFilterSet {
+func (est *CardinalityEstimator) GetTableFilters(op *LogicalOperator, tableIndex uint64) *storage.TableFilterSet {
 	get := getLogicalGet(op, tableIndex)
 	if get != nil {
-		//TODO:
-		return nil
+		return pushdownTableFilters(get.Filters)
 	} else {
 		return nil
 	}
@@ -312,6 +311,10 @@ func (est *CardinalityEstimator) GetTableFilters(op *LogicalOperator, tableIndex
 
 func (est *CardinalityEstimator) UpdateTotalDomains(node *JoinNode, op *LogicalOperator) error {
 	relId := node.set.relations[0]
+	if _, has := est.relationAttributes[relId]; !has {
+		//the subquery without the column in any join condition
+		est.relationAttributes[relId] = NewRelationAttributes()
+	}
 	est.relationAttributes[relId].cardinality = node.getCard()
 	distinctCount := uint64(node.getBaseCard())
 	var get *LogicalOperator
@@ -420,13 +423,13 @@ func (est *CardinalityEstimator) AddRelationColumnMapping(
 		//	}
 		//}
 
-	case ScanTypeValuesList:
+	case ScanTypeValuesList, ScanTypeSeries:
 		for i := range get.Names {
 			key := ColumnBind{relId, uint64(i)}
 			value := ColumnBind{get.Index, uint64(i)}
 			est.AddRelationToColumnMapping(key, value)
 		}
-	case ScanTypeCopyFrom:
+	case ScanTypeCopyFrom, ScanTypeTableFunc:
 		for i := range get.ScanInfo.Names {
 			key := ColumnBind{relId, uint64(i)}
 			value := ColumnBind{get.Index, uint64(i)}
@@ -584,9 +587,6 @@ func IsLogicalFilter(op *LogicalOperator) bool {
 	return op.Typ == LOT_Filter
 }
 
-type TableFilterSet struct {
-}
-
 func getLogicalGet(op *LogicalOperator, tableIndex uint64) *LogicalOperator {
 	switch op.Typ {
 	case LOT_Scan:
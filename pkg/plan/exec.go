@@ -303,6 +303,10 @@ func (exec *ExprExec) executeConst(expr *Expr, state *ExprState, sel *chunk.Sele
 
 func (exec *ExprExec) executeFunc(expr *Expr, eState *ExprState, sel *chunk.SelectVector, count int, result *chunk.Vector) error {
 	var err error
+	if expr.FunImpl._scalar == nil && expr.FunImpl._boundCastInfo == nil &&
+		expr.DataTyp.Id == common.LTID_BOOLEAN {
+		return exec.executeFuncBySelect(expr, eState, sel, count, result)
+	}
 	eState._interChunk.Reset()
 	for i, child := range expr.Children {
 		err = exec.execute(child,
@@ -316,7 +320,10 @@ func (exec *ExprExec) executeFunc(expr *Expr, eState *ExprState, sel *chunk.Sele
 	}
 	eState._interChunk.SetCard(count)
 	if expr.FunImpl._boundCastInfo != nil {
-		params := &CastParams{}
+		castData := expr.FunImpl._boundCastInfo._castData
+		params := &CastParams{
+			_strict: castData == nil || !castData._tryCast,
+		}
 		expr.FunImpl._boundCastInfo._fun(eState._interChunk.Data[0], result, count, params)
 	} else {
 		expr.FunImpl._scalar(eState._interChunk, eState, result)
@@ -325,6 +332,35 @@ func (exec *ExprExec) executeFunc(expr *Expr, eState *ExprState, sel *chunk.Sele
 	return nil
 }
 
+// executeFuncBySelect evaluates the boolean function
+// that only has the select implementation. (e.g. comparison)
+func (exec *ExprExec) executeFuncBySelect(expr *Expr, eState *ExprState, sel *chunk.SelectVector, count int, result *chunk.Vector) error {
+	trueSel := chunk.NewSelectVector(util.DefaultVectorSize)
+	falseSel := chunk.NewSelectVector(util.DefaultVectorSize)
+	tCnt, err := exec.execSelectExpr(expr, eState, sel, count, trueSel, falseSel)
+	if err != nil {
+		return err
+	}
+	//the select vectors keep the row index in the sel
+	pos := make(map[int]int, count)
+	for i := 0; i < count; i++ {
+		if sel != nil {
+			pos[sel.GetIndex(i)] = i
+		} else {
+			pos[i] = i
+		}
+	}
+	result.SetPhyFormat(chunk.PF_FLAT)
+	resSlice := chunk.GetSliceInPhyFormatFlat[bool](result)
+	for i := 0; i < count; i++ {
+		resSlice[i] = false
+	}
+	for i := 0; i < tCnt; i++ {
+		resSlice[pos[trueSel.GetIndex(i)]] = true
+	}
+	return nil
+}
+
 func (exec *ExprExec) executeSelect(datas []*chunk.Chunk, sel *chunk.SelectVector) (int, error) {
 	card := 0
 	for _, data := range datas {
@@ -371,6 +407,11 @@ func (exec *ExprExec) execSelectExpr(expr *Expr, eState *ExprState, sel *chunk.S
 			return exec.execSelectAnd(expr, eState, sel, count, trueSel, falseSel)
 		case ET_Or:
 			return exec.execSelectOr(expr, eState, sel, count, trueSel, falseSel)
+		case ET_SubFunc:
+			if expr.DataTyp.Id != common.LTID_BOOLEAN {
+				panic("usp")
+			}
+			return exec.execSelectBool(expr, eState, sel, count, trueSel, falseSel)
 		default:
 			panic("usp")
 		}
@@ -425,6 +466,39 @@ func (exec *ExprExec) execSelectCompare(expr *Expr, eState *ExprState, sel *chun
 
 }
 
+// execSelectBool evaluates the boolean function and
+// selects the rows that are true. NULL is false.
+func (exec *ExprExec) execSelectBool(expr *Expr, eState *ExprState, sel *chunk.SelectVector, count int, trueSel, falseSel *chunk.SelectVector) (int, error) {
+	res := chunk.NewFlatVector(common.BooleanType(), util.DefaultVectorSize)
+	err := exec.executeFunc(expr, eState, sel, count, res)
+	if err != nil {
+		return 0, err
+	}
+	var rdata chunk.UnifiedFormat
+	res.ToUnifiedFormat(count, &rdata)
+	resSlice := chunk.GetSliceInPhyFormatUnifiedFormat[bool](&rdata)
+	trueCount, falseCount := 0, 0
+	for i := 0; i < count; i++ {
+		ridx := i
+		if sel != nil {
+			ridx = sel.GetIndex(i)
+		}
+		idx := rdata.Sel.GetIndex(i)
+		if rdata.Mask.RowIsValid(uint64(idx)) && resSlice[idx] {
+			if trueSel != nil {
+				trueSel.SetIndex(trueCount, ridx)
+			}
+			trueCount++
+		} else {
+			if falseSel != nil {
+				falseSel.SetIndex(falseCount, ridx)
+			}
+			falseCount++
+		}
+	}
+	return trueCount, nil
+}
+
 func (exec *ExprExec) execSelectAnd(expr *Expr, eState *ExprState, sel *chunk.SelectVector, count int, trueSel, falseSel *chunk.SelectVector) (int, error) {
 	var err error
 	curSel := sel
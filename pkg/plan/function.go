@@ -30,9 +30,50 @@ var aggNames = map[string]int{
 	"sum":   1,
 	"max":   1,
 	"avg":   1,
+	//holistic aggregates
+	"string_agg":      1,
+	"percentile_cont": 1,
+	"percentile_disc": 1,
+	"mode":            1,
+	"median":          1,
+	"quantile":        1,
+	"any_value":       1,
+	"list_agg":        1,
+	"array_agg":       1,
+	"arg_min":         1,
+	"arg_max":         1,
+	//statistical aggregates
+	"var_samp":       1,
+	"variance":       1,
+	"var_pop":        1,
+	"stddev_samp":    1,
+	"stddev":         1,
+	"stddev_pop":     1,
+	"covar_pop":      1,
+	"covar_samp":     1,
+	"corr":           1,
+	"regr_count":     1,
+	"regr_avgx":      1,
+	"regr_avgy":      1,
+	"regr_sxx":       1,
+	"regr_syy":       1,
+	"regr_sxy":       1,
+	"regr_slope":     1,
+	"regr_intercept": 1,
+	"regr_r2":        1,
+	"bool_and":       1,
+	"bool_or":        1,
+	"bit_and":        1,
+	"bit_or":         1,
+	//approximate aggregates
+	"approx_count_distinct": 1,
+	"hll_sketch":            1,
+	"hll_merge":             1,
 }
 
 func IsAgg(name string) bool {
+	funcLock.RLock()
+	defer funcLock.RUnlock()
 	if _, ok := aggNames[name]; ok {
 		return ok
 	}
@@ -64,6 +105,7 @@ const (
 type FunctionV2 struct {
 	_name         string
 	_args         []common.LType
+	_varargs      common.LType
 	_retType      common.LType
 	_funcTyp      FuncType
 	_sideEffects  FuncSideEffects
@@ -78,15 +120,24 @@ type FunctionV2 struct {
 	_update    aggrUpdate
 	_combine   aggrCombine
 	_finalize  aggrFinalize
+	//_destructor releases the states that are not finalized. it can be nil.
+	_destructor aggrDestructor
 	//_func         aggrFunction
 	_simpleUpdate aggrSimpleUpdate
 	//_window       aggrWindow
 }
 
+// hasVarargs decides the function accepts any number of
+// the arguments of type _varargs after the _args.
+func (fun *FunctionV2) hasVarargs() bool {
+	return fun._varargs.Id != common.LTID_INVALID
+}
+
 func (fun *FunctionV2) Copy() *FunctionV2 {
 	ret := &FunctionV2{
 		_name:         fun._name,
 		_args:         util.CopyTo(fun._args),
+		_varargs:      fun._varargs,
 		_retType:      fun._retType,
 		_funcTyp:      fun._funcTyp,
 		_sideEffects:  fun._sideEffects,
@@ -98,6 +149,7 @@ func (fun *FunctionV2) Copy() *FunctionV2 {
 		_update:       fun._update,
 		_combine:      fun._combine,
 		_finalize:     fun._finalize,
+		_destructor:   fun._destructor,
 		//_func:         fun._func,
 		_simpleUpdate: fun._simpleUpdate,
 		//_window:       fun._window,
@@ -112,13 +164,14 @@ type aggrInit func(pointer unsafe.Pointer)
 type aggrUpdate func([]*chunk.Vector, *AggrInputData, int, *chunk.Vector, int)
 type aggrCombine func(*chunk.Vector, *chunk.Vector, *AggrInputData, int)
 type aggrFinalize func(*chunk.Vector, *AggrInputData, *chunk.Vector, int, int)
+type aggrDestructor func(*chunk.Vector, int)
 
 // type aggrFunction func(*AggrFunc, []*Expr)
 type aggrSimpleUpdate func([]*chunk.Vector, *AggrInputData, int, unsafe.Pointer, int)
 
 //type aggrWindow func([]*Vector, *Bitmap, *AggrInputData)
 
-type bindScalarFunc func(fun *FunctionV2, args []*Expr) *FunctionData
+type bindScalarFunc func(fun *FunctionV2, args []*Expr) (*FunctionData, error)
 
 const (
 	DecimalBindData    = "decimal"
@@ -178,7 +231,9 @@ func (binder *FunctionBinder) BindScalarFunc(
 	subTyp ET_SubTyp,
 	isOperator bool,
 ) *Expr {
+	funcLock.RLock()
 	fset := scalarFuncs[name]
+	funcLock.RUnlock()
 	if fset == nil {
 		panic(fmt.Sprintf("function %s not found", name))
 	}
@@ -198,9 +253,20 @@ func (binder *FunctionBinder) BindScalarFunc2(
 	isOperator bool,
 ) *Expr {
 	var bindInfo *FunctionData
+	if fun.hasVarargs() {
+		//the extra arguments are typed by the varargs
+		for len(fun._args) < len(args) {
+			fun._args = append(fun._args, fun._varargs)
+		}
+	}
 	if fun._bind != nil {
-		bindInfo = fun._bind(fun, args)
+		var err error
+		bindInfo, err = fun._bind(fun, args)
+		if err != nil {
+			panic(err)
+		}
 	}
+	binder.CastToFuncArgs(fun, args)
 
 	return &Expr{
 		Typ:        ET_Func,
@@ -236,14 +302,16 @@ func (binder *FunctionBinder) BindAggrFunc(
 	args []*Expr,
 	subTyp ET_SubTyp,
 	isOperator bool,
-) *Expr {
+) (*Expr, error) {
+	funcLock.RLock()
 	fset := aggrFuncs[name]
+	funcLock.RUnlock()
 	if fset == nil {
-		panic(fmt.Sprintf("function %s not found", name))
+		return nil, fmt.Errorf("function %s not found", name)
 	}
 	best := binder.BindFunc2(name, fset, args)
 	if best == -1 {
-		panic(fmt.Sprintf("function %s not found %v", name, args))
+		return nil, fmt.Errorf("function %s not found %v", name, args)
 	}
 
 	fun := fset.GetFunc(best)
@@ -255,10 +323,14 @@ func (binder *FunctionBinder) BindAggrFunc2(
 	args []*Expr,
 	subTyp ET_SubTyp,
 	isOperator bool,
-) *Expr {
+) (*Expr, error) {
 	var bindInfo *FunctionData
 	if fun._bind != nil {
-		bindInfo = fun._bind(fun, args)
+		var err error
+		bindInfo, err = fun._bind(fun, args)
+		if err != nil {
+			return nil, err
+		}
 	}
 	binder.CastToFuncArgs(fun, args)
 	return &Expr{
@@ -270,7 +342,7 @@ func (binder *FunctionBinder) BindAggrFunc2(
 		IsOperator: isOperator,
 		BindInfo:   bindInfo,
 		FunImpl:    fun,
-	}
+	}, nil
 }
 
 type LTypeCmpResult int
@@ -361,13 +433,21 @@ func (binder *FunctionBinder) BindFuncCost(
 	fun *FunctionV2,
 	args []common.LType,
 ) int64 {
-	if len(fun._args) != len(args) {
+	if fun.hasVarargs() {
+		if len(args) < len(fun._args) {
+			return -1
+		}
+	} else if len(fun._args) != len(args) {
 		return -1
 	}
 
 	cost := int64(0)
 	for i, arg := range args {
-		castCost := castFuncs.ImplicitCastCost(arg, fun._args[i])
+		target := fun._varargs
+		if i < len(fun._args) {
+			target = fun._args[i]
+		}
+		castCost := castFuncs.ImplicitCastCost(arg, target)
 		if castCost >= 0 {
 			cost += castCost
 		} else {
@@ -417,6 +497,12 @@ func RegisterOps() {
 	CaseFunc{}.Register(scalarFuncs)
 	ExtractFunc{}.Register(scalarFuncs)
 	SubstringFunc{}.Register(scalarFuncs)
+	HllEstimateFunc{}.Register(scalarFuncs)
+	StringFunc{}.Register(scalarFuncs)
+	MathFunc{}.Register(scalarFuncs)
+	DateFunc{}.Register(scalarFuncs)
+	NestedFunc{}.Register(scalarFuncs)
+	JSONFunc{}.Register(scalarFuncs)
 }
 
 func RegisterAggrs() {
@@ -425,4 +511,20 @@ func RegisterAggrs() {
 	CountFunc{}.Register(aggrFuncs)
 	MaxFunc{}.Register(aggrFuncs)
 	MinFunc{}.Register(aggrFuncs)
+	StringAggFunc{}.Register(aggrFuncs)
+	PercentileContFunc{}.Register(aggrFuncs)
+	PercentileDiscFunc{}.Register(aggrFuncs)
+	ModeFunc{}.Register(aggrFuncs)
+	MedianFunc{}.Register(aggrFuncs)
+	QuantileFunc{}.Register(aggrFuncs)
+	AnyValueFunc{}.Register(aggrFuncs)
+	ListAggFunc{}.Register(aggrFuncs)
+	ArgMinMaxFunc{}.Register(aggrFuncs)
+	VarianceFunc{}.Register(aggrFuncs)
+	RegrFunc{}.Register(aggrFuncs)
+	BoolAndOrFunc{}.Register(aggrFuncs)
+	BitAndOrFunc{}.Register(aggrFuncs)
+	ApproxCountDistinctFunc{}.Register(aggrFuncs)
+	HllSketchFunc{}.Register(aggrFuncs)
+	HllMergeFunc{}.Register(aggrFuncs)
 }
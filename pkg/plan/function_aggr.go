@@ -15,6 +15,8 @@
 package plan
 
 import (
+	"fmt"
+
 	"github.com/daviszhen/plan/pkg/common"
 )
 
@@ -40,13 +42,13 @@ func (SumFunc) Register(funcList FunctionList) {
 	funcList.Add("sum", set)
 }
 
-func BindDecimalSum(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalSum(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	decTyp := args[0].DataTyp
 	*fun = *GetSumAggr(decTyp.GetInternalType())
 	fun._name = "sum"
 	fun._args[0] = decTyp
 	fun._retType = common.DecimalType(common.DecimalMaxWidth, decTyp.Scale)
-	return nil
+	return nil, nil
 }
 
 type AvgFunc struct {
@@ -71,13 +73,13 @@ func (AvgFunc) Register(funcList FunctionList) {
 	funcList.Add("avg", set)
 }
 
-func BindDecimalAvg(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalAvg(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	decTyp := args[0].DataTyp
 	*fun = *GetAvgAggr(decTyp.GetInternalType(), decTyp.GetInternalType())
 	fun._name = "avg"
 	fun._args[0] = decTyp
 	fun._retType = common.DecimalType(common.DecimalMaxWidth, decTyp.Scale)
-	return nil
+	return nil, nil
 }
 
 type CountFunc struct {
@@ -123,11 +125,14 @@ func (MaxFunc) Register(funcList FunctionList) {
 		_bind:    BindDecimalMinMax,
 	}
 	set.Add(maxDec)
+	for _, fun := range temporalMinMax("max") {
+		set.Add(fun)
+	}
 
 	funcList.Add("max", set)
 }
 
-func BindDecimalMinMax(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalMinMax(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	decTyp := args[0].DataTyp
 	name := fun._name
 	if name == "max" {
@@ -138,7 +143,29 @@ func BindDecimalMinMax(fun *FunctionV2, args []*Expr) *FunctionData {
 	fun._name = name
 	fun._args[0] = decTyp
 	fun._retType = decTyp
-	return nil
+	return nil, nil
+}
+
+// temporalMinMax is the min/max on the TIME, TIMESTAMP and INTERVAL
+func temporalMinMax(name string) []*FunctionV2 {
+	ret := make([]*FunctionV2, 0)
+	for _, typ := range []common.LType{
+		common.TimeType(),
+		common.TimestampType(),
+		common.IntervalType(),
+	} {
+		var fun *FunctionV2
+		if name == "max" {
+			fun = GetMaxAggr(typ.GetInternalType(), typ.GetInternalType())
+		} else {
+			fun = GetMinAggr(typ.GetInternalType(), typ.GetInternalType())
+		}
+		fun._name = name
+		fun._args = []common.LType{typ}
+		fun._retType = typ
+		ret = append(ret, fun)
+	}
+	return ret
 }
 
 type MinFunc struct {
@@ -155,6 +182,426 @@ func (MinFunc) Register(funcList FunctionList) {
 		_bind:    BindDecimalMinMax,
 	}
 	set.Add(minDec)
+	for _, fun := range temporalMinMax("min") {
+		set.Add(fun)
+	}
 
 	funcList.Add("min", set)
 }
+
+// BindAnyArgs fixes the arguments of type ANY with the types of the real arguments.
+func BindAnyArgs(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	for i, arg := range fun._args {
+		if arg.Id == common.LTID_ANY {
+			fun._args[i] = args[i].DataTyp
+		}
+	}
+	return nil, nil
+}
+
+type StringAggFunc struct {
+}
+
+func (StringAggFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("string_agg", AggregateFuncType)
+
+	strAgg := HolisticAggregate[StringAggState](
+		[]common.LType{common.VarcharType()},
+		common.VarcharType(),
+		StringAggOp{})
+	strAgg._name = "string_agg"
+
+	strAggSep := HolisticAggregate[StringAggState](
+		[]common.LType{common.VarcharType(), common.VarcharType()},
+		common.VarcharType(),
+		StringAggOp{})
+	strAggSep._name = "string_agg"
+
+	set.Add(strAgg)
+	set.Add(strAggSep)
+
+	funcList.Add("string_agg", set)
+}
+
+type PercentileContFunc struct {
+}
+
+func (PercentileContFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("percentile_cont", AggregateFuncType)
+
+	percentile := HolisticAggregate[QuantileState](
+		[]common.LType{common.AnyType(), common.AnyType()},
+		common.DoubleType(),
+		QuantileOp{})
+	percentile._name = "percentile_cont"
+	percentile._bind = BindQuantile
+	set.Add(percentile)
+
+	funcList.Add("percentile_cont", set)
+}
+
+type PercentileDiscFunc struct {
+}
+
+func (PercentileDiscFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("percentile_disc", AggregateFuncType)
+
+	percentile := HolisticAggregate[QuantileState](
+		[]common.LType{common.AnyType(), common.AnyType()},
+		common.AnyType(),
+		QuantileOp{_discrete: true})
+	percentile._name = "percentile_disc"
+	percentile._bind = BindQuantile
+	set.Add(percentile)
+
+	funcList.Add("percentile_disc", set)
+}
+
+// BindQuantile fixes the fraction of the quantile that must be
+// a constant in [0,1]. The discrete one returns the type of the values.
+func BindQuantile(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	frac, err := quantileFraction(fun._name, args[1])
+	if err != nil {
+		return nil, err
+	}
+	BindAnyArgs(fun, args)
+	discrete := fun._name != "percentile_cont"
+	if discrete || interpolable(args[0].DataTyp) {
+		fun._retType = args[0].DataTyp
+	}
+	setQuantileOp(fun, QuantileOp{_discrete: discrete, _frac: frac})
+	return nil, nil
+}
+
+// quantileFraction returns the value of the constant fraction
+func quantileFraction(name string, arg *Expr) (float64, error) {
+	var frac float64
+	switch arg.Typ {
+	case ET_FConst:
+		frac = arg.Fvalue
+	case ET_IConst:
+		frac = float64(arg.Ivalue)
+	default:
+		return 0, fmt.Errorf("fraction of %s must be a constant", name)
+	}
+	if frac < 0 || frac > 1 {
+		return 0, fmt.Errorf("fraction %v of %s is out of range [0,1]", frac, name)
+	}
+	return frac, nil
+}
+
+// setQuantileOp replaces the implementation of the bound quantile with the op
+func setQuantileOp(fun *FunctionV2, op QuantileOp) {
+	impl := HolisticAggregate[QuantileState](fun._args, fun._retType, op)
+	fun._stateSize = impl._stateSize
+	fun._init = impl._init
+	fun._update = impl._update
+	fun._combine = impl._combine
+	fun._finalize = impl._finalize
+	fun._destructor = impl._destructor
+}
+
+type ModeFunc struct {
+}
+
+func (ModeFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("mode", AggregateFuncType)
+
+	mode := HolisticAggregate[ModeState](
+		[]common.LType{common.AnyType()},
+		common.AnyType(),
+		ModeOp{})
+	mode._name = "mode"
+	mode._bind = BindArgType
+	set.Add(mode)
+
+	funcList.Add("mode", set)
+}
+
+// BindArgType decides the return type by the first argument.
+func BindArgType(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	BindAnyArgs(fun, args)
+	fun._retType = args[0].DataTyp
+	return nil, nil
+}
+
+// BindNumericArgs fixes the arguments of type ANY with the types of
+// the real arguments that must be numeric.
+func BindNumericArgs(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	for i, arg := range fun._args {
+		if arg.Id != common.LTID_ANY {
+			continue
+		}
+		if !args[i].DataTyp.IsNumeric() {
+			return nil, fmt.Errorf("%s does not support type %s", fun._name, args[i].DataTyp)
+		}
+		fun._args[i] = args[i].DataTyp
+	}
+	return nil, nil
+}
+
+// BindMedian accepts the numeric and the timestamp, time, interval.
+// the median of the latter has the same type.
+func BindMedian(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	if !interpolable(args[0].DataTyp) {
+		return BindNumericArgs(fun, args)
+	}
+	fun._args[0] = args[0].DataTyp
+	fun._retType = args[0].DataTyp
+	setQuantileOp(fun, QuantileOp{_frac: 0.5})
+	return nil, nil
+}
+
+var varianceKinds = map[string]VarianceKind{
+	"var_samp":    VAR_SAMP,
+	"variance":    VAR_SAMP,
+	"var_pop":     VAR_POP,
+	"stddev_samp": STDDEV_SAMP,
+	"stddev":      STDDEV_SAMP,
+	"stddev_pop":  STDDEV_POP,
+}
+
+type VarianceFunc struct {
+}
+
+func (VarianceFunc) Register(funcList FunctionList) {
+	for name, kind := range varianceKinds {
+		set := NewFunctionSet(name, AggregateFuncType)
+		fun := FixedStateAggregate[VarianceState, float64](
+			[]common.LType{common.AnyType()},
+			common.DoubleType(),
+			decodeFloat64s,
+			VarianceOp{_kind: kind})
+		fun._name = name
+		fun._bind = BindNumericArgs
+		set.Add(fun)
+		funcList.Add(name, set)
+	}
+}
+
+var regrKinds = map[string]RegrKind{
+	"covar_pop":      COVAR_POP,
+	"covar_samp":     COVAR_SAMP,
+	"corr":           CORR,
+	"regr_count":     REGR_COUNT,
+	"regr_avgx":      REGR_AVGX,
+	"regr_avgy":      REGR_AVGY,
+	"regr_sxx":       REGR_SXX,
+	"regr_syy":       REGR_SYY,
+	"regr_sxy":       REGR_SXY,
+	"regr_slope":     REGR_SLOPE,
+	"regr_intercept": REGR_INTERCEPT,
+	"regr_r2":        REGR_R2,
+}
+
+// RegrFunc registers the aggregates on the pairs (y,x)
+type RegrFunc struct {
+}
+
+func (RegrFunc) Register(funcList FunctionList) {
+	for name, kind := range regrKinds {
+		set := NewFunctionSet(name, AggregateFuncType)
+		retTyp := common.DoubleType()
+		if kind == REGR_COUNT {
+			retTyp = common.BigintType()
+		}
+		fun := FixedStateAggregate[RegrState, float64](
+			[]common.LType{common.AnyType(), common.AnyType()},
+			retTyp,
+			decodeFloat64s,
+			RegrOp{_kind: kind})
+		fun._name = name
+		fun._bind = BindNumericArgs
+		set.Add(fun)
+		funcList.Add(name, set)
+	}
+}
+
+type BoolAndOrFunc struct {
+}
+
+func (BoolAndOrFunc) Register(funcList FunctionList) {
+	for _, name := range []string{"bool_and", "bool_or"} {
+		set := NewFunctionSet(name, AggregateFuncType)
+		fun := FixedStateAggregate[BoolState, bool](
+			[]common.LType{common.BooleanType()},
+			common.BooleanType(),
+			decodeBools,
+			BoolOp{_and: name == "bool_and"})
+		fun._name = name
+		set.Add(fun)
+		funcList.Add(name, set)
+	}
+}
+
+type BitAndOrFunc struct {
+}
+
+func (BitAndOrFunc) Register(funcList FunctionList) {
+	for _, name := range []string{"bit_and", "bit_or"} {
+		set := NewFunctionSet(name, AggregateFuncType)
+		for _, typ := range []common.LType{common.IntegerType(), common.BigintType()} {
+			fun := FixedStateAggregate[BitState, int64](
+				[]common.LType{typ},
+				typ,
+				decodeInt64s,
+				BitOp{_and: name == "bit_and"})
+			fun._name = name
+			set.Add(fun)
+		}
+		funcList.Add(name, set)
+	}
+}
+
+type AnyValueFunc struct {
+}
+
+func (AnyValueFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("any_value", AggregateFuncType)
+
+	anyValue := HolisticAggregate[AnyValueState](
+		[]common.LType{common.AnyType()},
+		common.AnyType(),
+		AnyValueOp{})
+	anyValue._name = "any_value"
+	anyValue._bind = BindArgType
+	set.Add(anyValue)
+
+	funcList.Add("any_value", set)
+}
+
+type ListAggFunc struct {
+}
+
+func (ListAggFunc) Register(funcList FunctionList) {
+	for _, name := range []string{"list_agg", "array_agg"} {
+		set := NewFunctionSet(name, AggregateFuncType)
+		fun := HolisticAggregate[ListAggState](
+			[]common.LType{common.AnyType()},
+			common.AnyType(),
+			ListAggOp{})
+		fun._name = name
+		fun._bind = func(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+			BindAnyArgs(fun, args)
+			fun._retType = common.ListType(args[0].DataTyp)
+			return nil, nil
+		}
+		set.Add(fun)
+		funcList.Add(name, set)
+	}
+}
+
+type ArgMinMaxFunc struct {
+}
+
+func (ArgMinMaxFunc) Register(funcList FunctionList) {
+	for _, name := range []string{"arg_min", "arg_max"} {
+		set := NewFunctionSet(name, AggregateFuncType)
+		fun := HolisticAggregate[ArgMinMaxState](
+			[]common.LType{common.AnyType(), common.AnyType()},
+			common.AnyType(),
+			ArgMinMaxOp{_max: name == "arg_max"})
+		fun._name = name
+		fun._bind = BindArgType
+		set.Add(fun)
+		funcList.Add(name, set)
+	}
+}
+
+type MedianFunc struct {
+}
+
+func (MedianFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("median", AggregateFuncType)
+
+	median := HolisticAggregate[QuantileState](
+		[]common.LType{common.AnyType()},
+		common.DoubleType(),
+		QuantileOp{_frac: 0.5})
+	median._name = "median"
+	median._bind = BindMedian
+	set.Add(median)
+
+	funcList.Add("median", set)
+}
+
+// QuantileFunc is the discrete quantile.
+// quantile(x, q) is same as percentile_disc(q) WITHIN GROUP (ORDER BY x).
+type QuantileFunc struct {
+}
+
+func (QuantileFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("quantile", AggregateFuncType)
+
+	quantile := HolisticAggregate[QuantileState](
+		[]common.LType{common.AnyType(), common.AnyType()},
+		common.AnyType(),
+		QuantileOp{_discrete: true})
+	quantile._name = "quantile"
+	quantile._bind = BindQuantile
+	set.Add(quantile)
+
+	funcList.Add("quantile", set)
+}
+
+// BindHllArgs fixes the argument of type ANY with the type
+// of the real argument that must be hashable.
+func BindHllArgs(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	if !hllHashable(args[0].DataTyp) {
+		return nil, fmt.Errorf("%s does not support type %s", fun._name, args[0].DataTyp)
+	}
+	return BindAnyArgs(fun, args)
+}
+
+type ApproxCountDistinctFunc struct {
+}
+
+func (ApproxCountDistinctFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("approx_count_distinct", AggregateFuncType)
+
+	approx := HllAggregate(
+		[]common.LType{common.AnyType()},
+		common.BigintType(),
+		HLL_COUNT)
+	approx._name = "approx_count_distinct"
+	approx._bind = BindHllArgs
+	set.Add(approx)
+
+	funcList.Add("approx_count_distinct", set)
+}
+
+// HllSketchFunc builds the HyperLogLog sketch of the values.
+// The sketch is serialized into the base64 text that can be
+// merged by hll_merge or estimated by hll_estimate later.
+type HllSketchFunc struct {
+}
+
+func (HllSketchFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("hll_sketch", AggregateFuncType)
+
+	sketch := HllAggregate(
+		[]common.LType{common.AnyType()},
+		common.VarcharType(),
+		HLL_SKETCH)
+	sketch._name = "hll_sketch"
+	sketch._bind = BindHllArgs
+	set.Add(sketch)
+
+	funcList.Add("hll_sketch", set)
+}
+
+type HllMergeFunc struct {
+}
+
+func (HllMergeFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("hll_merge", AggregateFuncType)
+
+	merge := HllAggregate(
+		[]common.LType{common.VarcharType()},
+		common.VarcharType(),
+		HLL_MERGE)
+	merge._name = "hll_merge"
+	set.Add(merge)
+
+	funcList.Add("hll_merge", set)
+}
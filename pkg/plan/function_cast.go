@@ -23,6 +23,8 @@ import (
 // BoundCastData generated during bind of cast,
 // used during execution of cast
 type BoundCastData struct {
+	//TRY_CAST. yields NULL on failure
+	_tryCast bool
 }
 
 type CastParams struct {
@@ -54,46 +56,35 @@ func DefaultCastFunc(
 		common.LTID_UTINYINT, common.LTID_USMALLINT, common.LTID_UINTEGER, common.LTID_UBIGINT, common.LTID_HUGEINT,
 		common.LTID_FLOAT, common.LTID_DOUBLE:
 		return NumericCastSwitch(input, src, dst)
-	case common.LTID_POINTER:
-		panic("usp")
 	case common.LTID_UUID:
-		panic("usp")
+		return UUIDCastToSwitch(input, src, dst)
 	case common.LTID_DECIMAL:
 		return DecimalCastToSwitch(input, src, dst)
 	case common.LTID_DATE:
-		panic("usp")
-	case common.LTID_TIME,
-		common.LTID_TIME_TZ,
-		common.LTID_TIMESTAMP,
+		return DateCastToSwitch(input, src, dst)
+	case common.LTID_TIMESTAMP,
 		common.LTID_TIMESTAMP_TZ,
-		common.LTID_TIMESTAMP_NS,
+		common.LTID_TIMESTAMP_SEC,
 		common.LTID_TIMESTAMP_MS,
-		common.LTID_TIMESTAMP_SEC:
-		panic("usp")
+		common.LTID_TIMESTAMP_NS:
+		return TimestampCastToSwitch(input, src, dst)
+	case common.LTID_TIME:
+		return TimeCastToSwitch(input, src, dst)
 	case common.LTID_INTERVAL:
-		panic("usp")
+		return IntervalCastToSwitch(input, src, dst)
 	case common.LTID_VARCHAR:
 		return StringCastToSwitch(input, src, dst)
 	case common.LTID_BLOB:
-		panic("usp")
-	case common.LTID_BIT:
-		panic("usp")
+		return BlobCastToSwitch(input, src, dst)
+	case common.LTID_JSON:
+		return JSONCastToSwitch(input, src, dst)
+	case common.LTID_LIST, common.LTID_MAP, common.LTID_STRUCT:
+		return NestedCastToSwitch(input, src, dst)
 	case common.LTID_NULL:
-		panic("usp")
-	case common.LTID_MAP:
-		panic("usp")
-	case common.LTID_STRUCT:
-		panic("usp")
-	case common.LTID_LIST:
-		panic("usp")
-	case common.LTID_UNION:
-		panic("usp")
-	case common.LTID_ENUM:
-		panic("usp")
-	case common.LTID_AGGREGATE_STATE:
-		panic("usp")
+		return &BoundCastInfo{_fun: NullCast}
 	default:
-		panic("usp")
+		//unsupported cast is reported by the GetCastFunc
+		return nil
 	}
 }
 
@@ -107,9 +98,11 @@ func NumericCastSwitch(
 		ret = BoolCastToSwitch(input, src, dst)
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret = SmallintCastToSwitch(input, src, dst)
 	case common.LTID_INTEGER:
 		ret = IntegerCastToSwitch(input, src, dst)
 	case common.LTID_BIGINT:
+		ret = BigintCastToSwitch(input, src, dst)
 	case common.LTID_UTINYINT:
 	case common.LTID_USMALLINT:
 	case common.LTID_UINTEGER:
@@ -119,11 +112,10 @@ func NumericCastSwitch(
 	case common.LTID_FLOAT:
 		ret = FloatCastToSwitch(input, src, dst)
 	case common.LTID_DOUBLE:
-	default:
-		panic("usp")
+		ret = DoubleCastToSwitch(input, src, dst)
 	}
 	if ret == nil || ret._fun == nil {
-		panic("usp")
+		return nil
 	}
 	return ret
 }
@@ -137,17 +129,60 @@ func BoolCastToSwitch(
 	case common.LTID_BOOLEAN:
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[bool, int16](tryCastBoolToNumeric[int16])
 	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[bool, int32](tryCastBoolToNumeric[int32])
 	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[bool, int64](tryCastBoolToNumeric[int64])
 	case common.LTID_UTINYINT:
 	case common.LTID_USMALLINT:
 	case common.LTID_UINTEGER:
 	case common.LTID_UBIGINT:
 	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[bool, common.Hugeint](tryCastBoolToHugeint)
 	case common.LTID_FLOAT:
+		ret._fun = MakeCastFunc[bool, float32](tryCastBoolToNumeric[float32])
 	case common.LTID_DOUBLE:
-	default:
-		panic("usp")
+		ret._fun = MakeCastFunc[bool, float64](tryCastBoolToNumeric[float64])
+	case common.LTID_DECIMAL:
+		decCast := func(input *bool, result *common.Decimal, _ bool) bool {
+			return tryCastBoolToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[bool, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[bool, common.String](tryCastToVarchar(formatBool))
+	}
+	return ret
+}
+
+func SmallintCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[int16, bool](tryCastNumericToBool[int16])
+	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[int16, int32](tryCastIntToInt[int16, int32])
+	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[int16, int64](tryCastIntToInt[int16, int64])
+	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[int16, common.Hugeint](tryCastIntToHugeint[int16])
+	case common.LTID_FLOAT:
+		ret._fun = MakeCastFunc[int16, float32](tryCastIntToFloat[int16, float32])
+	case common.LTID_DOUBLE:
+		ret._fun = MakeCastFunc[int16, float64](tryCastIntToFloat[int16, float64])
+	case common.LTID_DECIMAL:
+		decCast := func(input *int16, result *common.Decimal, _ bool) bool {
+			val := int64(*input)
+			return tryCastInt64ToDecimal(&val, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[int16, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[int16, common.String](tryCastToVarchar(formatInt[int16]))
 	}
 	return ret
 }
@@ -159,8 +194,10 @@ func IntegerCastToSwitch(
 	ret := &BoundCastInfo{}
 	switch dst.Id {
 	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[int32, bool](tryCastNumericToBool[int32])
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[int32, int16](tryCastIntToInt[int32, int16])
 	case common.LTID_INTEGER:
 		ret._fun = MakeCastFunc[int32, int32](tryCastInt32ToInt32)
 	case common.LTID_BIGINT:
@@ -170,18 +207,19 @@ func IntegerCastToSwitch(
 	case common.LTID_UINTEGER:
 	case common.LTID_UBIGINT:
 	case common.LTID_HUGEINT:
-		ret._fun = MakeCastFunc[int32, common.Hugeint](tryCastInt32ToHugeint)
+		ret._fun = MakeCastFunc[int32, common.Hugeint](tryCastIntToHugeint[int32])
 	case common.LTID_FLOAT:
 		ret._fun = MakeCastFunc[int32, float32](tryCastInt32ToFloat32)
 	case common.LTID_DOUBLE:
 		ret._fun = MakeCastFunc[int32, float64](tryCastInt32ToFloat64)
 	case common.LTID_DECIMAL:
 		decCast := func(input *int32, result *common.Decimal, _ bool) bool {
-			return tryCastInt32ToDecimal(input, result, dst.Scale, true)
+			return tryCastInt32ToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
 		}
 		ret._fun = MakeCastFunc[int32, common.Decimal](decCast)
-	default:
-		panic("usp")
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[int32, common.String](tryCastToVarchar(formatInt[int32]))
 	}
 	return ret
 }
@@ -193,23 +231,103 @@ func FloatCastToSwitch(
 	ret := &BoundCastInfo{}
 	switch dst.Id {
 	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[float32, bool](tryCastNumericToBool[float32])
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[float32, int16](tryCastFloatToInt[float32, int16])
 	case common.LTID_INTEGER:
-		ret._fun = MakeCastFunc[float32, int32](tryCastFloat32ToInt32)
+		ret._fun = MakeCastFunc[float32, int32](tryCastFloatToInt[float32, int32])
 	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[float32, int64](tryCastFloatToInt[float32, int64])
 	case common.LTID_UTINYINT:
 	case common.LTID_USMALLINT:
 	case common.LTID_UINTEGER:
 	case common.LTID_UBIGINT:
 	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[float32, common.Hugeint](tryCastFloatToHugeint[float32])
 	case common.LTID_FLOAT:
 	case common.LTID_DOUBLE:
 		ret._fun = MakeCastFunc[float32, float64](tryCastFloat32ToFloat64)
 	case common.LTID_DECIMAL:
-		ret._fun = MakeCastFunc[float32, common.Decimal](tryCastFloat32ToDecimal)
-	default:
-		panic("usp")
+		decCast := func(input *float32, result *common.Decimal, _ bool) bool {
+			return tryCastFloat32ToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[float32, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[float32, common.String](tryCastToVarchar(formatFloat32))
+	}
+	return ret
+}
+
+func BigintCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[int64, bool](tryCastNumericToBool[int64])
+	case common.LTID_TINYINT:
+	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[int64, int16](tryCastIntToInt[int64, int16])
+	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[int64, int32](tryCastInt64ToInt32)
+	case common.LTID_BIGINT:
+	case common.LTID_UTINYINT:
+	case common.LTID_USMALLINT:
+	case common.LTID_UINTEGER:
+	case common.LTID_UBIGINT:
+	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[int64, common.Hugeint](tryCastIntToHugeint[int64])
+	case common.LTID_FLOAT:
+		ret._fun = MakeCastFunc[int64, float32](tryCastInt64ToFloat32)
+	case common.LTID_DOUBLE:
+		ret._fun = MakeCastFunc[int64, float64](tryCastInt64ToFloat64)
+	case common.LTID_DECIMAL:
+		decCast := func(input *int64, result *common.Decimal, _ bool) bool {
+			return tryCastInt64ToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[int64, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[int64, common.String](tryCastToVarchar(formatInt[int64]))
+	}
+	return ret
+}
+
+func DoubleCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[float64, bool](tryCastNumericToBool[float64])
+	case common.LTID_TINYINT:
+	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[float64, int16](tryCastFloatToInt[float64, int16])
+	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[float64, int32](tryCastFloatToInt[float64, int32])
+	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[float64, int64](tryCastFloatToInt[float64, int64])
+	case common.LTID_UTINYINT:
+	case common.LTID_USMALLINT:
+	case common.LTID_UINTEGER:
+	case common.LTID_UBIGINT:
+	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[float64, common.Hugeint](tryCastFloatToHugeint[float64])
+	case common.LTID_FLOAT:
+		ret._fun = MakeCastFunc[float64, float32](tryCastFloat64ToFloat32)
+	case common.LTID_DOUBLE:
+	case common.LTID_DECIMAL:
+		decCast := func(input *float64, result *common.Decimal, _ bool) bool {
+			return tryCastFloat64ToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[float64, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[float64, common.String](tryCastToVarchar(formatFloat64))
 	}
 	return ret
 }
@@ -221,23 +339,31 @@ func HugeintCastToSwitch(
 	ret := &BoundCastInfo{}
 	switch dst.Id {
 	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[common.Hugeint, bool](tryCastHugeintToBool)
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[common.Hugeint, int16](tryCastHugeintToInt[int16])
 	case common.LTID_INTEGER:
-		ret._fun = MakeCastFunc[common.Hugeint, int32](tryCastBigintToInt32)
+		ret._fun = MakeCastFunc[common.Hugeint, int32](tryCastHugeintToInt[int32])
 	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[common.Hugeint, int64](tryCastHugeintToInt[int64])
 	case common.LTID_UTINYINT:
 	case common.LTID_USMALLINT:
 	case common.LTID_UINTEGER:
 	case common.LTID_UBIGINT:
 	case common.LTID_HUGEINT:
 	case common.LTID_FLOAT:
-		ret._fun = MakeCastFunc[common.Hugeint, float32](tryCastBigintToFloat32)
+		ret._fun = MakeCastFunc[common.Hugeint, float32](tryCastHugeintToFloat32)
 	case common.LTID_DOUBLE:
+		ret._fun = MakeCastFunc[common.Hugeint, float64](tryCastHugeintToFloat64)
 	case common.LTID_DECIMAL:
-		ret._fun = MakeCastFunc[common.Hugeint, common.Decimal](tryCastBigintToDecimal)
-	default:
-		panic("usp")
+		decCast := func(input *common.Hugeint, result *common.Decimal, _ bool) bool {
+			return tryCastHugeintToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[common.Hugeint, common.Decimal](decCast)
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.Hugeint, common.String](tryCastToVarchar(formatHugeint))
 	}
 	return ret
 }
@@ -249,25 +375,32 @@ func DecimalCastToSwitch(
 	ret := &BoundCastInfo{}
 	switch dst.Id {
 	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[common.Decimal, bool](tryCastDecimalToBool)
 	case common.LTID_TINYINT:
 	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[common.Decimal, int16](tryCastDecimalToInt[int16])
 	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[common.Decimal, int32](tryCastDecimalToInt[int32])
 	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[common.Decimal, int64](tryCastDecimalToInt[int64])
 	case common.LTID_UTINYINT:
 	case common.LTID_USMALLINT:
 	case common.LTID_UINTEGER:
 	case common.LTID_UBIGINT:
 	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[common.Decimal, common.Hugeint](tryCastDecimalToHugeint)
 	case common.LTID_FLOAT:
 		ret._fun = MakeCastFunc[common.Decimal, float32](tryCastDecimalToFloat32)
 	case common.LTID_DOUBLE:
+		ret._fun = MakeCastFunc[common.Decimal, float64](tryCastDecimalToFloat64)
 	case common.LTID_DECIMAL:
 		decCast := func(input *common.Decimal, result *common.Decimal, _ bool) bool {
-			return tryCastDecimalToDecimal(input, result, src.Scale, dst.Scale, true)
+			return tryCastDecimalToDecimal(input, result, src.Scale, dst.Scale, true) &&
+				decimalFits(result, dst)
 		}
 		ret._fun = MakeCastFunc[common.Decimal, common.Decimal](decCast)
-	default:
-		panic("usp")
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.Decimal, common.String](tryCastToVarchar(formatDecimal))
 	}
 	return ret
 }
@@ -278,16 +411,264 @@ func StringCastToSwitch(
 ) *BoundCastInfo {
 	ret := &BoundCastInfo{}
 	switch dst.Id {
+	case common.LTID_BOOLEAN:
+		ret._fun = MakeCastFunc[common.String, bool](tryCastVarcharToBool)
+	case common.LTID_SMALLINT:
+		ret._fun = MakeCastFunc[common.String, int16](tryCastVarcharToInt16)
+	case common.LTID_INTEGER:
+		ret._fun = MakeCastFunc[common.String, int32](tryCastVarcharToInt32)
+	case common.LTID_BIGINT:
+		ret._fun = MakeCastFunc[common.String, int64](tryCastVarcharToInt64)
+	case common.LTID_HUGEINT:
+		ret._fun = MakeCastFunc[common.String, common.Hugeint](tryCastVarcharToHugeint)
+	case common.LTID_FLOAT:
+		ret._fun = MakeCastFunc[common.String, float32](tryCastVarcharToFloat32)
+	case common.LTID_DOUBLE:
+		ret._fun = MakeCastFunc[common.String, float64](tryCastVarcharToFloat64)
+	case common.LTID_DECIMAL:
+		decCast := func(input *common.String, result *common.Decimal, _ bool) bool {
+			return tryCastVarcharToDecimal(input, result, dst.Scale, true) &&
+				decimalFits(result, dst)
+		}
+		ret._fun = MakeCastFunc[common.String, common.Decimal](decCast)
 	case common.LTID_DATE:
 		ret._fun = MakeCastFunc[common.String, common.Date](tryCastVarcharToDate)
+	case common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ:
+		ret._fun = MakeCastFunc[common.String, int64](tryCastVarcharToTimestamp)
+	case common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		tsCast := func(input *common.String, result *int64, strict bool) bool {
+			if !tryCastVarcharToTimestamp(input, result, strict) {
+				return false
+			}
+			*result = common.MicrosToTimestamp(dst.Id, *result)
+			return true
+		}
+		ret._fun = MakeCastFunc[common.String, int64](tsCast)
+	case common.LTID_TIME:
+		ret._fun = MakeCastFunc[common.String, int64](tryCastVarcharToTime)
 	case common.LTID_INTERVAL:
 		ret._fun = MakeCastFunc[common.String, common.Interval](tryCastVarcharToInterval)
-	default:
-		panic("usp")
+	case common.LTID_BLOB:
+		ret._fun = MakeCastFunc[common.String, common.String](tryCastVarcharToBlob)
+	case common.LTID_UUID:
+		ret._fun = MakeCastFunc[common.String, common.Hugeint](tryCastVarcharToUUID)
+	case common.LTID_JSON:
+		ret._fun = MakeCastFunc[common.String, common.String](tryCastVarcharToJSON)
+	case common.LTID_VARCHAR:
+		//varchar with different width
+		ret._fun = NoCast
 	}
 	return ret
 }
 
+func DateCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.Date, common.String](tryCastToVarchar(formatDate))
+	case common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		tsCast := func(input *common.Date, result *int64, strict bool) bool {
+			tryCastDateToTimestamp(input, result, strict)
+			*result = common.MicrosToTimestamp(dst.Id, *result)
+			return true
+		}
+		ret._fun = MakeCastFunc[common.Date, int64](tsCast)
+	}
+	return ret
+}
+
+// TimestampCastToSwitch casts the timestamp in any unit.
+// the value is converted into the micros first.
+func TimestampCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	toMicros := func(input *int64) int64 {
+		return common.TimestampToMicros(src.Id, *input)
+	}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		format := func(input *int64) string {
+			micros := toMicros(input)
+			if src.Id == common.LTID_TIMESTAMP_TZ {
+				return formatTimestamp(&micros) + "+00"
+			}
+			return formatTimestamp(&micros)
+		}
+		ret._fun = MakeCastFunc[int64, common.String](tryCastToVarchar(format))
+	case common.LTID_DATE:
+		dateCast := func(input *int64, result *common.Date, strict bool) bool {
+			micros := toMicros(input)
+			return tryCastTimestampToDate(&micros, result, strict)
+		}
+		ret._fun = MakeCastFunc[int64, common.Date](dateCast)
+	case common.LTID_TIME:
+		timeCast := func(input *int64, result *int64, strict bool) bool {
+			micros := toMicros(input)
+			return tryCastTimestampToTime(&micros, result, strict)
+		}
+		ret._fun = MakeCastFunc[int64, int64](timeCast)
+	case common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		tsCast := func(input *int64, result *int64, _ bool) bool {
+			*result = common.MicrosToTimestamp(dst.Id, toMicros(input))
+			return true
+		}
+		ret._fun = MakeCastFunc[int64, int64](tsCast)
+	}
+	return ret
+}
+
+func TimeCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[int64, common.String](tryCastToVarchar(formatTime))
+	}
+	return ret
+}
+
+func IntervalCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.Interval, common.String](tryCastToVarchar(formatInterval))
+	}
+	return ret
+}
+
+func BlobCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.String, common.String](tryCastToVarchar(formatBlob))
+	}
+	return ret
+}
+
+func UUIDCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		ret._fun = MakeCastFunc[common.Hugeint, common.String](tryCastToVarchar(formatUUID))
+	}
+	return ret
+}
+
+func NestedCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	ret := &BoundCastInfo{}
+	switch {
+	case dst.Id == common.LTID_VARCHAR:
+		ret._fun = nestedToVarcharCast
+	case src.Id == dst.Id && src.Id != common.LTID_STRUCT:
+		//LIST and MAP cast the child
+		childCast, err := input._funcSet.GetCastFunc(src.ListChild(), dst.ListChild())
+		if err != nil {
+			return nil
+		}
+		ret._fun = listCast(childCast)
+	case src.Id == dst.Id && len(src.Children) == len(dst.Children):
+		//the fields are cast by position
+		fieldCasts := make([]*BoundCastInfo, len(src.Children))
+		for i := range src.Children {
+			fieldCast, err := input._funcSet.GetCastFunc(src.Children[i], dst.Children[i])
+			if err != nil {
+				return nil
+			}
+			fieldCasts[i] = fieldCast
+		}
+		ret._fun = structCast(fieldCasts)
+	}
+	return ret
+}
+
+// flatNested copies the nested vector into the flat one
+// if it is not flat.
+func flatNested(src *chunk.Vector, count int) *chunk.Vector {
+	if src.PhyFormat().IsFlat() {
+		return src
+	}
+	flat := chunk.NewFlatVector(src.Typ(), max(count, util.DefaultVectorSize))
+	for i := 0; i < count; i++ {
+		flat.SetValue(i, src.GetValue(i))
+	}
+	return flat
+}
+
+func nestedToVarcharCast(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
+	res.SetPhyFormat(chunk.PF_FLAT)
+	for i := 0; i < count; i++ {
+		val := src.GetValue(i)
+		if val.IsNull {
+			res.SetValue(i, &chunk.Value{Typ: res.Typ(), IsNull: true})
+			continue
+		}
+		res.SetValue(i, &chunk.Value{Typ: res.Typ(), Str: val.String()})
+	}
+	return true
+}
+
+func listCast(childCast *BoundCastInfo) CastFuncType {
+	return func(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
+		src = flatNested(src, count)
+		res.Init(max(count, util.DefaultVectorSize))
+		res.SetPhyFormat(chunk.PF_FLAT)
+		size := chunk.GetListSize(src)
+		chunk.SetListSize(res, size)
+		if !childCast._fun(chunk.GetListChild(src), chunk.GetListChild(res), size, params) {
+			return false
+		}
+		copy(chunk.GetSliceInPhyFormatFlat[common.ListEntry](res)[:count],
+			chunk.GetSliceInPhyFormatFlat[common.ListEntry](src)[:count])
+		res.Mask.CopyFrom(src.Mask, count)
+		return true
+	}
+}
+
+func structCast(fieldCasts []*BoundCastInfo) CastFuncType {
+	return func(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
+		src = flatNested(src, count)
+		res.Init(max(count, util.DefaultVectorSize))
+		res.SetPhyFormat(chunk.PF_FLAT)
+		srcFields := chunk.GetStructChildren(src)
+		resFields := chunk.GetStructChildren(res)
+		for i, fieldCast := range fieldCasts {
+			if !fieldCast._fun(srcFields[i], resFields[i], count, params) {
+				return false
+			}
+		}
+		res.Mask.CopyFrom(src.Mask, count)
+		return true
+	}
+}
+
+// NullCast casts the NULL into any type
+func NullCast(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
+	res.SetPhyFormat(chunk.PF_CONST)
+	chunk.SetNullInPhyFormatConst(res, true)
+	return true
+}
+
 func MakeCastFunc[T any, R any](op CastOp[T, R]) CastFuncType {
 	temp := func(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
 		return TryCastLoop[T, R](
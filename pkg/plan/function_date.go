@@ -0,0 +1,359 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+var datePartSpecifiers = map[string]string{
+	"millennium": "millennium", "millennia": "millennium", "millenniums": "millennium", "mil": "millennium",
+	"century": "century", "centuries": "century", "cent": "century",
+	"decade": "decade", "decades": "decade", "dec": "decade",
+	"year": "year", "years": "year", "y": "year", "yr": "year", "yrs": "year",
+	"quarter": "quarter", "quarters": "quarter",
+	"month": "month", "months": "month", "mon": "month", "mons": "month",
+	"week": "week", "weeks": "week", "w": "week",
+	"day": "day", "days": "day", "d": "day",
+	"hour": "hour", "hours": "hour", "h": "hour", "hr": "hour", "hrs": "hour",
+	"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute", "m": "minute",
+	"second": "second", "seconds": "second", "sec": "second", "secs": "second", "s": "second",
+	"millisecond": "millisecond", "milliseconds": "millisecond", "ms": "millisecond", "msec": "millisecond",
+	"microsecond": "microsecond", "microseconds": "microsecond", "us": "microsecond", "usec": "microsecond",
+	"dow": "dow", "dayofweek": "dow", "weekday": "dow",
+	"isodow": "isodow", "epoch": "epoch",
+	"doy": "doy", "dayofyear": "doy",
+}
+
+func getDatePartSpecifier(s string) string {
+	part, ok := datePartSpecifiers[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		panic(fmt.Errorf("unsupported date part '%s'", s))
+	}
+	return part
+}
+
+// timestampPart extracts the part of the timestamp
+func timestampPart(part string, ts int64) int64 {
+	t := common.TimestampToTime(ts)
+	switch getDatePartSpecifier(part) {
+	case "millennium":
+		return int64((t.Year()-1)/1000 + 1)
+	case "century":
+		return int64((t.Year()-1)/100 + 1)
+	case "decade":
+		return int64(t.Year() / 10)
+	case "year":
+		return int64(t.Year())
+	case "quarter":
+		return int64((t.Month()-1)/3 + 1)
+	case "month":
+		return int64(t.Month())
+	case "week":
+		_, week := t.ISOWeek()
+		return int64(week)
+	case "day":
+		return int64(t.Day())
+	case "hour":
+		return int64(t.Hour())
+	case "minute":
+		return int64(t.Minute())
+	case "second":
+		return int64(t.Second())
+	case "millisecond":
+		return int64(t.Second())*1000 + int64(t.Nanosecond())/1000000
+	case "microsecond":
+		return int64(t.Second())*common.MicrosPerSecond + int64(t.Nanosecond())/1000
+	case "dow":
+		return int64(t.Weekday())
+	case "isodow":
+		return int64((t.Weekday()+6)%7 + 1)
+	case "doy":
+		return int64(t.YearDay())
+	case "epoch":
+		return t.Unix()
+	default:
+		panic(fmt.Errorf("unsupported date part '%s'", part))
+	}
+}
+
+// timePart extracts the part of the time of the day
+func timePart(part string, micros int64) int64 {
+	switch getDatePartSpecifier(part) {
+	case "hour", "minute", "second", "millisecond", "microsecond":
+		return timestampPart(part, micros)
+	case "epoch":
+		return micros / common.MicrosPerSecond
+	default:
+		panic(fmt.Errorf("unsupported time part '%s'", part))
+	}
+}
+
+// intervalPart extracts the part of the interval
+func intervalPart(part string, interval *common.Interval) int64 {
+	micros := interval.Micros
+	switch getDatePartSpecifier(part) {
+	case "millennium":
+		return int64(interval.Months / 12000)
+	case "century":
+		return int64(interval.Months / 1200)
+	case "decade":
+		return int64(interval.Months / 120)
+	case "year":
+		return int64(interval.Months / 12)
+	case "quarter":
+		return int64(interval.Months%12/3 + 1)
+	case "month":
+		return int64(interval.Months % 12)
+	case "day":
+		return int64(interval.Days)
+	case "hour":
+		return micros / common.MicrosPerHour
+	case "minute":
+		return micros % common.MicrosPerHour / common.MicrosPerMinute
+	case "second":
+		return micros % common.MicrosPerMinute / common.MicrosPerSecond
+	case "millisecond":
+		return micros % common.MicrosPerMinute / common.MicrosPerMilli
+	case "microsecond":
+		return micros % common.MicrosPerMinute
+	case "epoch":
+		days := int64(interval.Months)*30 + int64(interval.Days)
+		return days*(common.MicrosPerDay/common.MicrosPerSecond) + micros/common.MicrosPerSecond
+	default:
+		panic(fmt.Errorf("unsupported interval part '%s'", part))
+	}
+}
+
+// truncTimestamp truncates the timestamp to the precision of the part
+func truncTimestamp(part string, ts int64) int64 {
+	t := common.TimestampToTime(ts)
+	y, m, d := t.Date()
+	date := func(y int, m time.Month, d int) int64 {
+		return common.TimeToTimestamp(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	}
+	switch getDatePartSpecifier(part) {
+	case "millennium":
+		return date((y-1)/1000*1000+1, 1, 1)
+	case "century":
+		return date((y-1)/100*100+1, 1, 1)
+	case "decade":
+		return date(y/10*10, 1, 1)
+	case "year":
+		return date(y, 1, 1)
+	case "quarter":
+		return date(y, (m-1)/3*3+1, 1)
+	case "month":
+		return date(y, m, 1)
+	case "week":
+		//monday of the week
+		return date(y, m, d-int((t.Weekday()+6)%7))
+	case "day", "dow", "isodow", "doy":
+		return date(y, m, d)
+	case "hour":
+		return ts - common.TimestampToTimeOfDay(ts)%common.MicrosPerHour
+	case "minute":
+		return ts - common.TimestampToTimeOfDay(ts)%common.MicrosPerMinute
+	case "second":
+		return ts - common.TimestampToTimeOfDay(ts)%common.MicrosPerSecond
+	case "millisecond":
+		return ts - common.TimestampToTimeOfDay(ts)%common.MicrosPerMilli
+	case "microsecond", "epoch":
+		return ts
+	default:
+		panic(fmt.Errorf("unsupported date part '%s'", part))
+	}
+}
+
+// ageInterval computes the symbolic difference left - right
+// in years, months and days like the postgres.
+func ageInterval(left, right int64) common.Interval {
+	if left < right {
+		ret := ageInterval(right, left)
+		return common.Interval{Months: -ret.Months, Days: -ret.Days, Micros: -ret.Micros}
+	}
+	lt := common.TimestampToTime(left)
+	rt := common.TimestampToTime(right)
+	months := (lt.Year()-rt.Year())*12 + int(lt.Month()-rt.Month())
+	days := lt.Day() - rt.Day()
+	micros := common.TimestampToTimeOfDay(left) - common.TimestampToTimeOfDay(right)
+	if micros < 0 {
+		micros += common.MicrosPerDay
+		days--
+	}
+	if days < 0 {
+		//borrow the days of the month before the left
+		prev := time.Date(lt.Year(), lt.Month(), 0, 0, 0, 0, 0, time.UTC)
+		days += prev.Day()
+		months--
+	}
+	return common.Interval{
+		Months: int32(months),
+		Days:   int32(days),
+		Micros: micros,
+	}
+}
+
+func datePartOp(part *common.String, input *common.Date, result *int64) {
+	*result = timestampPart(part.String(), common.DateToTimestamp(input))
+}
+
+func timestampPartOp(part *common.String, input *int64, result *int64) {
+	*result = timestampPart(part.String(), *input)
+}
+
+func timePartOp(part *common.String, input *int64, result *int64) {
+	*result = timePart(part.String(), *input)
+}
+
+func intervalPartOp(part *common.String, input *common.Interval, result *int64) {
+	*result = intervalPart(part.String(), input)
+}
+
+func dateTruncOp(part *common.String, input *common.Date, result *common.Date) {
+	*result = common.TimestampToDate(truncTimestamp(part.String(), common.DateToTimestamp(input)))
+}
+
+func timestampTruncOp(part *common.String, input *int64, result *int64) {
+	*result = truncTimestamp(part.String(), *input)
+}
+
+func ageOp(left *int64, right *int64, result *common.Interval) {
+	*result = ageInterval(*left, *right)
+}
+
+// ageNowOp is the age(ts) that is the age from the current date
+func ageNowOp(input *int64, result *common.Interval) {
+	now := common.TimeToTimestamp(time.Now().UTC())
+	*result = ageInterval(now-common.TimestampToTimeOfDay(now), *input)
+}
+
+func toTimestampOp(input *float64, result *int64) {
+	if math.IsNaN(*input) || math.IsInf(*input, 0) {
+		panic(fmt.Errorf("invalid epoch %v for to_timestamp", *input))
+	}
+	*result = int64(math.Round(*input * float64(common.MicrosPerSecond)))
+}
+
+func strftimeOp(input *int64, format *common.String, result *common.String) {
+	s, err := common.Strftime(common.TimestampToTime(*input), format.String())
+	if err != nil {
+		panic(err)
+	}
+	makeString([]byte(s), result)
+}
+
+func dateStrftimeOp(input *common.Date, format *common.String, result *common.String) {
+	ts := common.DateToTimestamp(input)
+	strftimeOp(&ts, format, result)
+}
+
+func strptimeOp(input *common.String, format *common.String, result *int64) {
+	t, err := common.Strptime(input.String(), format.String())
+	if err != nil {
+		panic(err)
+	}
+	*result = common.TimeToTimestamp(t)
+}
+
+// nowFunction returns the current timestamp, current date or current time
+func nowFunction(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+	now := common.TimeToTimestamp(time.Now().UTC())
+	val := &chunk.Value{Typ: result.Typ()}
+	switch result.Typ().Id {
+	case common.LTID_DATE:
+		date := common.TimestampToDate(now)
+		val.I64, val.I64_1, val.I64_2 = int64(date.Year), int64(date.Month), int64(date.Day)
+	case common.LTID_TIME:
+		val.I64 = common.TimestampToTimeOfDay(now)
+	default:
+		val.I64 = now
+	}
+	result.ReferenceValue(val)
+}
+
+// temporalCompareFuncs creates the comparison on the time, timestamp and interval.
+// they are evaluated by the select on the physical type.
+func temporalCompareFuncs(name string) []*FunctionV2 {
+	ret := make([]*FunctionV2, 0)
+	for _, typ := range []common.LType{common.TimeType(), common.TimestampType(), common.IntervalType()} {
+		ret = append(ret, &FunctionV2{
+			_name:    name,
+			_args:    []common.LType{typ, typ},
+			_retType: common.BooleanType(),
+			_funcTyp: ScalarFuncType,
+		})
+	}
+	return ret
+}
+
+// dateFunc creates the overload of the date function
+func dateFunc(args []common.LType, retTyp common.LType, fun ScalarFunc) *FunctionV2 {
+	return &FunctionV2{
+		_args:    args,
+		_retType: retTyp,
+		_funcTyp: ScalarFuncType,
+		_scalar:  fun,
+	}
+}
+
+type DateFunc struct {
+}
+
+func (DateFunc) Register(funcList FunctionList) {
+	varchar := common.VarcharType()
+	bigint := common.BigintType()
+	date := common.DateType()
+	timeTyp := common.TimeType()
+	timestamp := common.TimestampType()
+	interval := common.IntervalType()
+
+	registerScalarFunc(funcList, []string{"now", "current_timestamp", "transaction_timestamp"},
+		dateFunc([]common.LType{}, timestamp, nowFunction))
+	registerScalarFunc(funcList, []string{"current_date", "today"},
+		dateFunc([]common.LType{}, date, nowFunction))
+	registerScalarFunc(funcList, []string{"current_time"},
+		dateFunc([]common.LType{}, timeTyp, nowFunction))
+
+	registerScalarFunc(funcList, []string{"date_part", "datepart"},
+		dateFunc([]common.LType{varchar, date}, bigint, BinaryFunction[common.String, common.Date, int64](datePartOp)),
+		dateFunc([]common.LType{varchar, timestamp}, bigint, BinaryFunction[common.String, int64, int64](timestampPartOp)),
+		dateFunc([]common.LType{varchar, timeTyp}, bigint, BinaryFunction[common.String, int64, int64](timePartOp)),
+		dateFunc([]common.LType{varchar, interval}, bigint, BinaryFunction[common.String, common.Interval, int64](intervalPartOp)))
+
+	registerScalarFunc(funcList, []string{"date_trunc", "datetrunc"},
+		dateFunc([]common.LType{varchar, date}, date, BinaryFunction[common.String, common.Date, common.Date](dateTruncOp)),
+		dateFunc([]common.LType{varchar, timestamp}, timestamp, BinaryFunction[common.String, int64, int64](timestampTruncOp)))
+
+	registerScalarFunc(funcList, []string{"age"},
+		dateFunc([]common.LType{timestamp}, interval, UnaryFunction[int64, common.Interval](ageNowOp)),
+		dateFunc([]common.LType{timestamp, timestamp}, interval, BinaryFunction[int64, int64, common.Interval](ageOp)))
+
+	registerScalarFunc(funcList, []string{"to_timestamp"},
+		dateFunc([]common.LType{common.DoubleType()}, timestamp, UnaryFunction[float64, int64](toTimestampOp)))
+
+	registerScalarFunc(funcList, []string{"strftime"},
+		dateFunc([]common.LType{date, varchar}, varchar, BinaryFunction[common.Date, common.String, common.String](dateStrftimeOp)),
+		dateFunc([]common.LType{timestamp, varchar}, varchar, BinaryFunction[int64, common.String, common.String](strftimeOp)))
+
+	registerScalarFunc(funcList, []string{"strptime"},
+		dateFunc([]common.LType{varchar, varchar}, timestamp, BinaryFunction[common.String, common.String, int64](strptimeOp)))
+}
@@ -0,0 +1,95 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func Test_timestampFuncs(t *testing.T) {
+	ts, err := common.ParseTimestamp("2023-06-07 08:09:10.5")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2023), timestampPart("year", ts))
+	assert.Equal(t, int64(2), timestampPart("quarter", ts))
+	assert.Equal(t, int64(158), timestampPart("doy", ts))
+	assert.Equal(t, int64(10500), timestampPart("millisecond", ts))
+	assert.Panics(t, func() { getDatePartSpecifier("fortnight") })
+
+	assert.Equal(t, "2023-06-05 00:00:00", common.TimestampToString(truncTimestamp("week", ts)))
+	assert.Equal(t, "2023-04-01 00:00:00", common.TimestampToString(truncTimestamp("quarter", ts)))
+	assert.Equal(t, "2023-06-07 08:09:00", common.TimestampToString(truncTimestamp("minute", ts)))
+
+	other, err := common.ParseTimestamp("2024-03-01 00:00:00")
+	require.NoError(t, err)
+	age := ageInterval(other, ts)
+	assert.Equal(t, "8 mons 22 days 15:50:49.5", age.String())
+
+	iv := common.Interval{Months: 14, Days: 3, Micros: 3_600_000_000}
+	assert.Equal(t, int64(1), intervalPart("year", &iv))
+	assert.Equal(t, int64(2), intervalPart("month", &iv))
+	assert.Equal(t, int64(1), intervalPart("hour", &iv))
+}
+
+func Test_strftime(t *testing.T) {
+	ts, err := common.ParseTimestamp("2024-01-02 03:04:05")
+	require.NoError(t, err)
+	s, err := common.Strftime(common.TimestampToTime(ts), "%Y/%m/%d %H:%M:%S %a %j")
+	require.NoError(t, err)
+	assert.Equal(t, "2024/01/02 03:04:05 Tue 002", s)
+
+	parsed, err := common.Strptime("02/03/2024 10:11", "%d/%m/%Y %H:%M")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-02 10:11:00", common.TimestampToString(common.TimeToTimestamp(parsed)))
+	_, err = common.Strptime("2024", "%Q")
+	assert.Error(t, err)
+}
+
+func Test_intervalArith(t *testing.T) {
+	from := " from generate_series(1,4) g(i)"
+	tests := []struct {
+		sql    string
+		expect []string
+	}{
+		//the day is clamped to the end of the month
+		{"select timestamp '2024-01-31 10:00' + interval '1 month' from generate_series(1,1) g(i)",
+			[]string{"2024-02-29 10:00:00"}},
+		{"select date '2024-01-31' + interval '1 month', date '2024-03-31' - interval '1 month' from generate_series(1,1) g(i)",
+			[]string{"2024-02-29|2024-02-29"}},
+		{"select timestamp '2024-01-31 23:00' + interval '1 month 1 day 2 hours' from generate_series(1,1) g(i)",
+			[]string{"2024-03-02 01:00:00"}},
+		{"select * from generate_series(timestamp '2024-01-31', timestamp '2024-04-30', interval '1 month')",
+			[]string{"2024-01-31 00:00:00", "2024-02-29 00:00:00", "2024-03-31 00:00:00", "2024-04-30 00:00:00"}},
+		{"select i * interval '1 hour', interval '1 day' * i" + from,
+			[]string{"01:00:00|1 day", "02:00:00|2 days", "03:00:00|3 days", "04:00:00|4 days"}},
+		{"select median(timestamp '2024-01-01' + i * interval '1 hour')" + from,
+			[]string{"2024-01-01 02:30:00"}},
+		{"select median(i * interval '1 hour')" + from, []string{"02:30:00"}},
+		{"select arg_min(i, timestamp '2024-01-01' - i * interval '1 day')" + from, []string{"4"}},
+		//1 day - 1 hour is greater than 2 hours
+		{"select string_agg(cast(i as varchar), ',' order by (i - 2) * interval '1 hour' + interval '1 day' * (i % 2))" + from,
+			[]string{"2,4,1,3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.expect, runSelectSQL(t, tt.sql))
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func tryCastVarcharToJSON(input *common.String, result *common.String, _ bool) bool {
+	data := input.DataSlice()
+	if !json.Valid(data) {
+		return false
+	}
+	makeString(data, result)
+	return true
+}
+
+// ReinterpretCast shares the data with the source
+func ReinterpretCast(src *chunk.Vector, res *chunk.Vector, count int, params *CastParams) bool {
+	res.Reinterpret(src)
+	return true
+}
+
+func JSONCastToSwitch(
+	input *BindCastInput,
+	src, dst common.LType,
+) *BoundCastInfo {
+	switch dst.Id {
+	case common.LTID_VARCHAR:
+		return &BoundCastInfo{_fun: ReinterpretCast}
+	default:
+		//the text of the json is cast
+		return StringCastToSwitch(input, common.VarcharType(), dst)
+	}
+}
+
+type jsonPathKind int
+
+const (
+	JSON_PATH_KEY jsonPathKind = iota
+	JSON_PATH_INDEX
+	//the key of the object or the index of the array
+	JSON_PATH_ANY
+)
+
+type jsonPathElem struct {
+	kind jsonPathKind
+	key  string
+	//the negative index counts from the end
+	idx int64
+}
+
+// parseJSONPath parses the path.
+// '$.a[0]."b c"', the json pointer '/a/0' and the plain key 'a' are supported.
+func parseJSONPath(path string) ([]jsonPathElem, error) {
+	switch {
+	case strings.HasPrefix(path, "$"):
+		return parseDollarPath(path)
+	case strings.HasPrefix(path, "/"):
+		elems := make([]jsonPathElem, 0)
+		for _, seg := range strings.Split(path[1:], "/") {
+			//json pointer escapes
+			seg = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+			elem := jsonPathElem{kind: JSON_PATH_KEY, key: seg}
+			if idx, err := strconv.ParseInt(seg, 10, 64); err == nil && idx >= 0 {
+				elem.kind = JSON_PATH_ANY
+				elem.idx = idx
+			}
+			elems = append(elems, elem)
+		}
+		return elems, nil
+	default:
+		return []jsonPathElem{{kind: JSON_PATH_KEY, key: path}}, nil
+	}
+}
+
+func parseDollarPath(path string) ([]jsonPathElem, error) {
+	elems := make([]jsonPathElem, 0)
+	i := 1
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+			if i < len(path) && path[i] == '"' {
+				end := strings.IndexByte(path[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unclosed quote in json path %s", path)
+				}
+				elems = append(elems, jsonPathElem{kind: JSON_PATH_KEY, key: path[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in json path %s", path)
+			}
+			elems = append(elems, jsonPathElem{kind: JSON_PATH_KEY, key: path[i : i+end]})
+			i += end
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in json path %s", path)
+			}
+			text := path[i+1 : i+end]
+			//[#-1] is the last element
+			text = strings.Replace(text, "#", "", 1)
+			idx, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %s in json path %s", path[i+1:i+end], path)
+			}
+			elems = append(elems, jsonPathElem{kind: JSON_PATH_INDEX, idx: idx})
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("invalid json path %s", path)
+		}
+	}
+	return elems, nil
+}
+
+// jsonObject returns the entries of the object in the order of the text
+func jsonObject(raw []byte) ([]string, []json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil || tok != json.Delim('{') {
+		return nil, nil, false
+	}
+	keys := make([]string, 0)
+	values := make([]json.RawMessage, 0)
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, nil, false
+		}
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, nil, false
+		}
+		keys = append(keys, tok.(string))
+		values = append(values, value)
+	}
+	return keys, values, true
+}
+
+func jsonArray(raw []byte) ([]json.RawMessage, bool) {
+	if len(raw) == 0 || raw[0] != '[' {
+		return nil, false
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return nil, false
+	}
+	return elems, true
+}
+
+// jsonLookup returns the value at the path. false if it does not exist.
+func jsonLookup(raw []byte, path []jsonPathElem) ([]byte, bool) {
+	raw = bytes.TrimSpace(raw)
+	for _, elem := range path {
+		switch {
+		case elem.kind != JSON_PATH_INDEX && len(raw) > 0 && raw[0] == '{':
+			keys, values, _ := jsonObject(raw)
+			found := false
+			for i, key := range keys {
+				if key == elem.key {
+					raw = values[i]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case elem.kind != JSON_PATH_KEY && len(raw) > 0 && raw[0] == '[':
+			elems, _ := jsonArray(raw)
+			idx := elem.idx
+			if idx < 0 {
+				idx += int64(len(elems))
+			}
+			if idx < 0 || idx >= int64(len(elems)) {
+				return nil, false
+			}
+			raw = elems[idx]
+		default:
+			return nil, false
+		}
+	}
+	return raw, true
+}
+
+func checkJSON(doc string) []byte {
+	raw := []byte(doc)
+	if !json.Valid(raw) {
+		panic(fmt.Errorf("malformed JSON: %s", doc))
+	}
+	return raw
+}
+
+func compactJSON(raw []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+// jsonPathArg converts the path argument. the integer is the array index.
+func jsonPathArg(arg *chunk.Value) []jsonPathElem {
+	if arg.Typ.Id != common.LTID_VARCHAR {
+		return []jsonPathElem{{kind: JSON_PATH_INDEX, idx: arg.I64}}
+	}
+	path, err := parseJSONPath(arg.Str)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// jsonFunction evaluates the function on the value at the optional path.
+// fun returns nil for NULL.
+func jsonFunction(fun func(raw []byte) *chunk.Value) ScalarFunc {
+	return nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		for _, arg := range args {
+			if arg.IsNull {
+				return nil
+			}
+		}
+		if len(args[0].Str) == 0 {
+			//the empty text is not a json. it is the NULL from the storage.
+			return nil
+		}
+		raw := checkJSON(args[0].Str)
+		if len(args) > 1 {
+			var ok bool
+			raw, ok = jsonLookup(raw, jsonPathArg(args[1]))
+			if !ok {
+				return nil
+			}
+		}
+		return fun(raw)
+	})
+}
+
+func jsonExtract(raw []byte) *chunk.Value {
+	return &chunk.Value{Str: compactJSON(raw)}
+}
+
+func jsonExtractString(raw []byte) *chunk.Value {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return nil
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			panic(err)
+		}
+		return &chunk.Value{Str: s}
+	default:
+		return &chunk.Value{Str: compactJSON(raw)}
+	}
+}
+
+func jsonArrayLength(raw []byte) *chunk.Value {
+	elems, _ := jsonArray(bytes.TrimSpace(raw))
+	return &chunk.Value{I64: int64(len(elems))}
+}
+
+func jsonKeys(raw []byte) *chunk.Value {
+	keys, _, _ := jsonObject(raw)
+	elems := make([]*chunk.Value, len(keys))
+	for i, key := range keys {
+		elems[i] = &chunk.Value{Typ: common.VarcharType(), Str: key}
+	}
+	return chunk.NewListValue(common.VarcharType(), elems)
+}
+
+func jsonValid(args []*chunk.Value) *chunk.Value {
+	if args[0].IsNull {
+		return nil
+	}
+	return &chunk.Value{Bool: json.Valid([]byte(args[0].Str))}
+}
+
+func jsonFunc(args []common.LType, retTyp common.LType, fun ScalarFunc) *FunctionV2 {
+	return &FunctionV2{
+		_args:    args,
+		_retType: retTyp,
+		_funcTyp: ScalarFuncType,
+		_scalar:  fun,
+	}
+}
+
+type JSONFunc struct {
+}
+
+func (JSONFunc) Register(funcList FunctionList) {
+	varchar := common.VarcharType()
+	bigint := common.BigintType()
+	jsonTyp := common.JSONType()
+
+	//the json argument is VARCHAR. JSON is cast to VARCHAR without copy.
+	withPath := func(retTyp common.LType, fun func(raw []byte) *chunk.Value) []*FunctionV2 {
+		return []*FunctionV2{
+			jsonFunc([]common.LType{varchar, varchar}, retTyp, jsonFunction(fun)),
+			jsonFunc([]common.LType{varchar, bigint}, retTyp, jsonFunction(fun)),
+		}
+	}
+
+	registerScalarFunc(funcList, []string{"json_extract", "json_extract_path"},
+		withPath(jsonTyp, jsonExtract)...)
+
+	registerScalarFunc(funcList, []string{"json_extract_string", "json_extract_path_text"},
+		withPath(varchar, jsonExtractString)...)
+
+	registerScalarFunc(funcList, []string{"json_array_length"},
+		append(withPath(bigint, jsonArrayLength),
+			jsonFunc([]common.LType{varchar}, bigint, jsonFunction(jsonArrayLength)))...)
+
+	registerScalarFunc(funcList, []string{"json_keys"},
+		append(withPath(common.ListType(varchar), jsonKeys),
+			jsonFunc([]common.LType{varchar}, common.ListType(varchar), jsonFunction(jsonKeys)))...)
+
+	registerScalarFunc(funcList, []string{"json_valid"},
+		jsonFunc([]common.LType{varchar}, common.BooleanType(), nestedFunction(jsonValid)))
+
+	registerScalarFunc(funcList, []string{"json"},
+		jsonFunc([]common.LType{varchar}, jsonTyp, jsonFunction(jsonExtract)))
+}
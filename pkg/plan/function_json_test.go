@@ -0,0 +1,63 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func Test_jsonPath(t *testing.T) {
+	doc := []byte(`{"a": {"b c": [1, {"d": "x"}, 3]}, "e": null}`)
+	lookup := func(path string) string {
+		elems, err := parseJSONPath(path)
+		require.NoError(t, err)
+		raw, ok := jsonLookup(doc, elems)
+		if !ok {
+			return "<missing>"
+		}
+		return compactJSON(raw)
+	}
+	assert.Equal(t, `{"b c":[1,{"d":"x"},3]}`, lookup("a"))
+	assert.Equal(t, `"x"`, lookup(`$.a."b c"[1].d`))
+	assert.Equal(t, `3`, lookup(`$.a."b c"[#-1]`))
+	assert.Equal(t, `1`, lookup("/a/b c/0"))
+	assert.Equal(t, `null`, lookup("$.e"))
+	assert.Equal(t, "<missing>", lookup("$.a.z"))
+	assert.Equal(t, "<missing>", lookup(`$.a."b c"[3]`))
+
+	_, err := parseJSONPath("$.a[x]")
+	assert.Error(t, err)
+
+	assert.Nil(t, jsonExtractString([]byte("null")))
+	assert.Equal(t, "x y", jsonExtractString([]byte(`"x y"`)).Str)
+	assert.Equal(t, `[1,2]`, jsonExtractString([]byte(`[1, 2]`)).Str)
+	assert.Equal(t, "[k, a]", jsonKeys([]byte(`{"k": 1, "a": 2}`)).String())
+}
+
+func Test_jsonCast(t *testing.T) {
+	info, err := castFuncs.GetCastFunc(common.VarcharType(), common.JSONType())
+	require.NoError(t, err)
+	assert.NotNil(t, info._fun)
+	info, err = castFuncs.GetCastFunc(common.JSONType(), common.BigintType())
+	require.NoError(t, err)
+	assert.NotNil(t, info._fun)
+	assert.Equal(t, int64(common.TargetTypeCost(common.JSONType())),
+		common.ImplicitCast(common.VarcharType(), common.JSONType()))
+}
@@ -0,0 +1,532 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	dec "github.com/govalues/decimal"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+var (
+	errDivisionByZero = fmt.Errorf("division by zero")
+	errMathOverflow   = fmt.Errorf("value out of range: overflow")
+)
+
+type mathInt interface {
+	int32 | int64
+}
+
+func absIntOp[T mathInt](input *T, result *T) {
+	if *input < 0 && -*input < 0 {
+		panic(fmt.Errorf("abs(%d) overflow", *input))
+	}
+	*result = max(*input, -*input)
+}
+
+func absDoubleOp(input *float64, result *float64) {
+	*result = math.Abs(*input)
+}
+
+func absDecimalOp(input *common.Decimal, result *common.Decimal) {
+	result.Decimal = input.Decimal.Abs()
+}
+
+func signIntOp[T mathInt](input *T, result *T) {
+	switch {
+	case *input > 0:
+		*result = 1
+	case *input < 0:
+		*result = -1
+	default:
+		*result = 0
+	}
+}
+
+func signDoubleOp(input *float64, result *float64) {
+	switch {
+	case *input > 0:
+		*result = 1
+	case *input < 0:
+		*result = -1
+	default:
+		*result = 0
+	}
+}
+
+func signDecimalOp(input *common.Decimal, result *common.Decimal) {
+	result.Decimal = dec.MustNew(int64(input.Sign()), 0).Pad(input.Scale())
+}
+
+func doubleOp(op func(float64) float64) func(*float64, *float64) {
+	return func(input *float64, result *float64) {
+		*result = op(*input)
+	}
+}
+
+// decimalOp applies the op that rounds the decimal to the integer
+func decimalOp(op func(dec.Decimal, int) dec.Decimal) func(*common.Decimal, *common.Decimal) {
+	return func(input *common.Decimal, result *common.Decimal) {
+		result.Decimal = op(input.Decimal, 0)
+	}
+}
+
+// roundInt64 rounds x to 10^-d half away from zero.
+// d >= 0 keeps x.
+func roundInt64(x int64, d int32, trunc bool) int64 {
+	if d >= 0 {
+		return x
+	}
+	if d < -18 {
+		return 0
+	}
+	p := int64(1)
+	for i := int32(0); i < -d; i++ {
+		p *= 10
+	}
+	q, r := x/p, x%p
+	if !trunc && max(r, -r) >= p-max(r, -r) {
+		if x < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	var res int64
+	mulInt64CheckOf(&q, &p, &res)
+	return res
+}
+
+func roundIntOp[T mathInt](trunc bool) func(*T, *int32, *T) {
+	return func(input *T, d *int32, result *T) {
+		res := roundInt64(int64(*input), *d, trunc)
+		if int64(T(res)) != res {
+			panic(fmt.Errorf("round(%d, %d) overflow", *input, *d))
+		}
+		*result = T(res)
+	}
+}
+
+func roundDoubleOp(trunc bool) func(*float64, *int32, *float64) {
+	return func(input *float64, d *int32, result *float64) {
+		p := math.Pow(10, float64(*d))
+		if trunc {
+			*result = math.Trunc(*input*p) / p
+		} else {
+			*result = math.Round(*input*p) / p
+		}
+	}
+}
+
+// roundDecimal rounds x to d digits after the decimal point
+// half away from zero. d can be negative.
+func roundDecimal(x dec.Decimal, d int, trunc bool) (dec.Decimal, error) {
+	if d < 0 {
+		if -d > 18 {
+			return dec.Decimal{}, nil
+		}
+		//shift the digits to the right of the decimal point and back
+		shifted, err := x.Mul(dec.MustNew(1, -d))
+		if err != nil {
+			return dec.Decimal{}, err
+		}
+		shifted, err = roundDecimal(shifted, 0, trunc)
+		if err != nil {
+			return dec.Decimal{}, err
+		}
+		return shifted.Mul(dec.MustNew(int64(math.Pow10(-d)), 0))
+	}
+	if d >= x.Scale() {
+		return x, nil
+	}
+	res := x.Trunc(d)
+	if trunc {
+		return res, nil
+	}
+	rem, err := x.Sub(res)
+	if err != nil {
+		return dec.Decimal{}, err
+	}
+	if rem.CmpAbs(dec.MustNew(5, d+1)) >= 0 {
+		step := dec.MustNew(1, d)
+		if x.IsNeg() {
+			step = step.Neg()
+		}
+		return res.Add(step)
+	}
+	return res, nil
+}
+
+func roundDecimalOp(trunc bool) func(*common.Decimal, *int32, *common.Decimal) {
+	return func(input *common.Decimal, d *int32, result *common.Decimal) {
+		res, err := roundDecimal(input.Decimal, int(*d), trunc)
+		if err != nil {
+			panic(err)
+		}
+		//keep the scale of the type
+		result.Decimal = res.Pad(input.Scale())
+	}
+}
+
+func roundDecimalIntegralOp(trunc bool) func(*common.Decimal, *common.Decimal) {
+	return func(input *common.Decimal, result *common.Decimal) {
+		res, err := roundDecimal(input.Decimal, 0, trunc)
+		if err != nil {
+			panic(err)
+		}
+		result.Decimal = res
+	}
+}
+
+func modIntOp[T mathInt](left, right *T, result *T) {
+	if *right == 0 {
+		panic(errDivisionByZero)
+	}
+	*result = *left % *right
+}
+
+func modDoubleOp(left, right *float64, result *float64) {
+	if *right == 0 {
+		panic(errDivisionByZero)
+	}
+	*result = math.Mod(*left, *right)
+}
+
+func modDecimalOp(left, right *common.Decimal, result *common.Decimal) {
+	if right.IsZero() {
+		panic(errDivisionByZero)
+	}
+	_, rem, err := left.QuoRem(right.Decimal)
+	if err != nil {
+		panic(err)
+	}
+	result.Decimal = rem.Pad(max(left.Scale(), right.Scale()))
+}
+
+func powerOp(left, right *float64, result *float64) {
+	if *left == 0 && *right < 0 {
+		panic(fmt.Errorf("zero raised to a negative power is undefined"))
+	}
+	if *left < 0 && math.Floor(*right) != *right {
+		panic(fmt.Errorf("a negative number raised to a non-integer power yields a complex result"))
+	}
+	*result = math.Pow(*left, *right)
+	checkDoubleOverflow(*result, *left, *right)
+}
+
+func sqrtOp(input *float64, result *float64) {
+	if *input < 0 {
+		panic(fmt.Errorf("cannot take square root of a negative number"))
+	}
+	*result = math.Sqrt(*input)
+}
+
+func expOp(input *float64, result *float64) {
+	*result = math.Exp(*input)
+	checkDoubleOverflow(*result, *input)
+}
+
+func logOp(log func(float64) float64) func(*float64, *float64) {
+	return func(input *float64, result *float64) {
+		if *input == 0 {
+			panic(fmt.Errorf("cannot take logarithm of zero"))
+		} else if *input < 0 {
+			panic(fmt.Errorf("cannot take logarithm of a negative number"))
+		}
+		*result = log(*input)
+	}
+}
+
+// checkDoubleOverflow reports the infinite result on the finite inputs
+func checkDoubleOverflow(res float64, inputs ...float64) {
+	if !math.IsInf(res, 0) {
+		return
+	}
+	for _, in := range inputs {
+		if math.IsInf(in, 0) {
+			return
+		}
+	}
+	panic(errMathOverflow)
+}
+
+// extremeFunction is greatest or least. NULL arguments are ignored.
+// The result is NULL only if all arguments are NULL.
+func extremeFunction[T any](better func(a, b *T) bool, assign func(src *T, dst *T)) ScalarFunc {
+	return func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+		count := input.Card()
+		allConst := true
+		for _, vec := range input.Data {
+			if !vec.PhyFormat().IsConst() {
+				allConst = false
+				break
+			}
+		}
+		if allConst {
+			count = 1
+			result.SetPhyFormat(chunk.PF_CONST)
+		} else {
+			result.SetPhyFormat(chunk.PF_FLAT)
+		}
+
+		datas := make([]chunk.UnifiedFormat, len(input.Data))
+		slices := make([][]T, len(input.Data))
+		for i, vec := range input.Data {
+			vec.ToUnifiedFormat(count, &datas[i])
+			slices[i] = chunk.GetSliceInPhyFormatUnifiedFormat[T](&datas[i])
+		}
+		resSlice := chunk.GetSliceInPhyFormatFlat[T](result)
+		for i := 0; i < count; i++ {
+			var best *T
+			for j := range datas {
+				idx := datas[j].Sel.GetIndex(i)
+				if !datas[j].Mask.RowIsValid(uint64(idx)) {
+					continue
+				}
+				if best == nil || better(&slices[j][idx], best) {
+					best = &slices[j][idx]
+				}
+			}
+			if best == nil {
+				if allConst {
+					chunk.SetNullInPhyFormatConst(result, true)
+				} else {
+					chunk.SetNullInPhyFormatFlat(result, uint64(i), true)
+				}
+				continue
+			}
+			if assign != nil {
+				assign(best, &resSlice[i])
+			} else {
+				resSlice[i] = *best
+			}
+		}
+	}
+}
+
+func getExtremeFunction(typ common.LType, greatest bool) ScalarFunc {
+	pick := func(less bool) bool {
+		return less != greatest
+	}
+	switch typ.GetInternalType() {
+	case common.INT32:
+		return extremeFunction[int32](func(a, b *int32) bool { return *a != *b && pick(*a < *b) }, nil)
+	case common.INT64:
+		return extremeFunction[int64](func(a, b *int64) bool { return *a != *b && pick(*a < *b) }, nil)
+	case common.DOUBLE:
+		return extremeFunction[float64](func(a, b *float64) bool { return *a != *b && pick(*a < *b) }, nil)
+	case common.DECIMAL:
+		return extremeFunction[common.Decimal](func(a, b *common.Decimal) bool {
+			cmp := a.Cmp(b.Decimal)
+			return cmp != 0 && pick(cmp < 0)
+		}, nil)
+	case common.DATE:
+		return extremeFunction[common.Date](func(a, b *common.Date) bool {
+			return !a.Equal(b) && pick(a.Less(b))
+		}, nil)
+	case common.VARCHAR:
+		return extremeFunction[common.String](func(a, b *common.String) bool {
+			return !a.Equal(b) && pick(a.Less(b))
+		}, func(src *common.String, dst *common.String) {
+			makeString(src.DataSlice(), dst)
+		})
+	default:
+		panic(fmt.Errorf("greatest/least on %v is not supported", typ))
+	}
+}
+
+func bindExtremeFunction(greatest bool) bindScalarFunc {
+	return func(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+		typ := args[0].DataTyp
+		for _, arg := range args[1:] {
+			typ = decideResultType(typ, arg.DataTyp)
+		}
+		for i := range fun._args {
+			fun._args[i] = typ
+		}
+		fun._retType = typ
+		fun._scalar = getExtremeFunction(typ, greatest)
+		return nil, nil
+	}
+}
+
+// DecimalIntegralBind binds the function that rounds
+// the decimal to the integer. e.g. ceil, floor
+func DecimalIntegralBind(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	fun._args[0] = args[0].DataTyp
+	fun._retType = common.DecimalType(args[0].DataTyp.Width, 0)
+	return nil, nil
+}
+
+func DecimalModBind(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	typ := decideResultType(args[0].DataTyp, args[1].DataTyp)
+	if typ.Id != common.LTID_DECIMAL {
+		//decimal and float. the float is cast to the decimal
+		typ = args[0].DataTyp
+		if typ.Id != common.LTID_DECIMAL {
+			typ = args[1].DataTyp
+		}
+	}
+	fun._args[0] = typ
+	fun._args[1] = typ
+	fun._retType = typ
+	return nil, nil
+}
+
+// gRandom is the generator of the random() and reseeded by the setseed()
+var gRandom = struct {
+	sync.Mutex
+	rnd *rand.Rand
+}{
+	rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+func randomFunction(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+	count := input.Card()
+	result.SetPhyFormat(chunk.PF_FLAT)
+	resSlice := chunk.GetSliceInPhyFormatFlat[float64](result)
+	gRandom.Lock()
+	defer gRandom.Unlock()
+	for i := 0; i < count; i++ {
+		resSlice[i] = gRandom.rnd.Float64()
+	}
+}
+
+func setseedFunction(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+	count := input.Card()
+	var data chunk.UnifiedFormat
+	input.Data[0].ToUnifiedFormat(count, &data)
+	seedSlice := chunk.GetSliceInPhyFormatUnifiedFormat[float64](&data)
+	gRandom.Lock()
+	defer gRandom.Unlock()
+	for i := 0; i < count; i++ {
+		idx := data.Sel.GetIndex(i)
+		if !data.Mask.RowIsValid(uint64(idx)) {
+			continue
+		}
+		seed := seedSlice[idx]
+		if seed < -1 || seed > 1 || math.IsNaN(seed) {
+			panic(fmt.Errorf("setseed parameter %v is out of allowed range [-1,1]", seed))
+		}
+		gRandom.rnd.Seed(int64(seed * math.MaxInt32))
+	}
+	result.SetPhyFormat(chunk.PF_CONST)
+	chunk.SetNullInPhyFormatConst(result, true)
+}
+
+// mathFunc creates the overload of the math function
+func mathFunc(args []common.LType, retTyp common.LType, fun ScalarFunc, bind bindScalarFunc) *FunctionV2 {
+	return &FunctionV2{
+		_args:    args,
+		_retType: retTyp,
+		_funcTyp: ScalarFuncType,
+		_scalar:  fun,
+		_bind:    bind,
+	}
+}
+
+type MathFunc struct {
+}
+
+func (MathFunc) Register(funcList FunctionList) {
+	integer := common.IntegerType()
+	bigint := common.BigintType()
+	double := common.DoubleType()
+	decimal := common.DecimalType(common.DecimalMaxWidthInt64, 0)
+
+	registerScalarFunc(funcList, []string{"abs"},
+		mathFunc([]common.LType{integer}, integer, UnaryFunction[int32, int32](absIntOp[int32]), nil),
+		mathFunc([]common.LType{bigint}, bigint, UnaryFunction[int64, int64](absIntOp[int64]), nil),
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](absDoubleOp), nil),
+		mathFunc([]common.LType{decimal}, decimal, UnaryFunction[common.Decimal, common.Decimal](absDecimalOp), NopDecimalBind))
+
+	registerScalarFunc(funcList, []string{"sign"},
+		mathFunc([]common.LType{integer}, integer, UnaryFunction[int32, int32](signIntOp[int32]), nil),
+		mathFunc([]common.LType{bigint}, bigint, UnaryFunction[int64, int64](signIntOp[int64]), nil),
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](signDoubleOp), nil),
+		mathFunc([]common.LType{decimal}, decimal, UnaryFunction[common.Decimal, common.Decimal](signDecimalOp), NopDecimalBind))
+
+	//ceil, floor, trunc, round on the integer are the integer itself
+	integrals := []struct {
+		names   []string
+		double  func(float64) float64
+		decimal func(*common.Decimal, *common.Decimal)
+	}{
+		{[]string{"ceil", "ceiling"}, math.Ceil, decimalOp(dec.Decimal.Ceil)},
+		{[]string{"floor"}, math.Floor, decimalOp(dec.Decimal.Floor)},
+		{[]string{"trunc"}, math.Trunc, roundDecimalIntegralOp(true)},
+		{[]string{"round"}, math.Round, roundDecimalIntegralOp(false)},
+	}
+	for _, integral := range integrals {
+		funs := []*FunctionV2{
+			mathFunc([]common.LType{integer}, integer, ScalarNopFunc, nil),
+			mathFunc([]common.LType{bigint}, bigint, ScalarNopFunc, nil),
+			mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](doubleOp(integral.double)), nil),
+			mathFunc([]common.LType{decimal}, decimal, UnaryFunction[common.Decimal, common.Decimal](integral.decimal), DecimalIntegralBind),
+		}
+		if integral.names[0] == "round" || integral.names[0] == "trunc" {
+			trunc := integral.names[0] == "trunc"
+			funs = append(funs,
+				mathFunc([]common.LType{integer, integer}, integer, BinaryFunction[int32, int32, int32](roundIntOp[int32](trunc)), nil),
+				mathFunc([]common.LType{bigint, integer}, bigint, BinaryFunction[int64, int32, int64](roundIntOp[int64](trunc)), nil),
+				mathFunc([]common.LType{double, integer}, double, BinaryFunction[float64, int32, float64](roundDoubleOp(trunc)), nil),
+				mathFunc([]common.LType{decimal, integer}, decimal, BinaryFunction[common.Decimal, int32, common.Decimal](roundDecimalOp(trunc)), NopDecimalBind),
+			)
+		}
+		registerScalarFunc(funcList, integral.names, funs...)
+	}
+
+	registerScalarFunc(funcList, []string{"mod"},
+		mathFunc([]common.LType{integer, integer}, integer, BinaryFunction[int32, int32, int32](modIntOp[int32]), nil),
+		mathFunc([]common.LType{bigint, bigint}, bigint, BinaryFunction[int64, int64, int64](modIntOp[int64]), nil),
+		mathFunc([]common.LType{double, double}, double, BinaryFunction[float64, float64, float64](modDoubleOp), nil),
+		mathFunc([]common.LType{decimal, decimal}, decimal, BinaryFunction[common.Decimal, common.Decimal, common.Decimal](modDecimalOp), DecimalModBind))
+
+	registerScalarFunc(funcList, []string{"power", "pow"},
+		mathFunc([]common.LType{double, double}, double, BinaryFunction[float64, float64, float64](powerOp), nil))
+
+	registerScalarFunc(funcList, []string{"sqrt"},
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](sqrtOp), nil))
+
+	registerScalarFunc(funcList, []string{"exp"},
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](expOp), nil))
+
+	registerScalarFunc(funcList, []string{"ln"},
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](logOp(math.Log)), nil))
+
+	registerScalarFunc(funcList, []string{"log10", "log"},
+		mathFunc([]common.LType{double}, double, UnaryFunction[float64, float64](logOp(math.Log10)), nil))
+
+	for name, greatest := range map[string]bool{"greatest": true, "least": false} {
+		extreme := mathFunc([]common.LType{common.AnyType()}, common.AnyType(), nil, bindExtremeFunction(greatest))
+		extreme._varargs = common.AnyType()
+		registerScalarFunc(funcList, []string{name}, extreme)
+	}
+
+	random := mathFunc([]common.LType{}, double, randomFunction, nil)
+	random._sideEffects = HasSideEffects
+	registerScalarFunc(funcList, []string{"random"}, random)
+
+	setseed := mathFunc([]common.LType{double}, common.VarcharType(), setseedFunction, nil)
+	setseed._sideEffects = HasSideEffects
+	registerScalarFunc(funcList, []string{"setseed"}, setseed)
+}
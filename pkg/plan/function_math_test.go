@@ -0,0 +1,64 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math"
+	"testing"
+
+	dec "github.com/govalues/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_roundOps(t *testing.T) {
+	round := func(s string, d int) string {
+		res, err := roundDecimal(dec.MustParse(s), d, false)
+		assert.NoError(t, err)
+		return res.String()
+	}
+	assert.Equal(t, "1.6", round("1.55", 1))
+	assert.Equal(t, "-2.5", round("-2.45", 1))
+	assert.Equal(t, "2", round("1.5", 0))
+	assert.Equal(t, "1200", round("1249.99", -2))
+	assert.Equal(t, "-1300", round("-1250", -2))
+
+	assert.Equal(t, int64(-10), roundInt64(-7, -1, false))
+	assert.Equal(t, int64(20), roundInt64(25, -1, true))
+	assert.Equal(t, int64(1234), roundInt64(1234, 2, false))
+}
+
+func Test_checkedArith(t *testing.T) {
+	var i64 int64
+	l, r := int64(3), int64(-5)
+	addInt64CheckOf(&l, &r, &i64)
+	assert.Equal(t, int64(-2), i64)
+	mulInt64CheckOf(&l, &r, &i64)
+	assert.Equal(t, int64(-15), i64)
+
+	l, r = math.MaxInt64, 1
+	assert.Panics(t, func() { addInt64CheckOf(&l, &r, &i64) })
+	l, r = math.MinInt64, -1
+	assert.Panics(t, func() { mulInt64CheckOf(&l, &r, &i64) })
+	l, r = 1<<32, 1<<31
+	assert.Panics(t, func() { mulInt64CheckOf(&l, &r, &i64) })
+
+	var i32 int32
+	a := int32(math.MinInt32)
+	assert.Panics(t, func() { absIntOp(&a, &i32) })
+	b := int32(0)
+	assert.PanicsWithError(t, errDivisionByZero.Error(), func() { modIntOp(&a, &b, &i32) })
+	f, z := float32(1), float32(0)
+	assert.PanicsWithError(t, errDivisionByZero.Error(), func() { binFloat32DivOp(&f, &z, &f) })
+}
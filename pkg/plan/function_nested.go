@@ -0,0 +1,323 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// nestedFunction evaluates the function on the values row by row.
+// fun returns nil for NULL.
+func nestedFunction(fun func(args []*chunk.Value) *chunk.Value) ScalarFunc {
+	return func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+		count := input.Card()
+		allConst := true
+		for _, vec := range input.Data {
+			if !vec.PhyFormat().IsConst() {
+				allConst = false
+			}
+		}
+		if allConst {
+			count = 1
+		}
+		result.Init(util.DefaultVectorSize)
+		result.SetPhyFormat(chunk.PF_FLAT)
+		args := make([]*chunk.Value, len(input.Data))
+		for i := 0; i < count; i++ {
+			for j, vec := range input.Data {
+				args[j] = vec.GetValue(i)
+			}
+			val := fun(args)
+			if val == nil {
+				val = &chunk.Value{IsNull: true}
+			}
+			val.Typ = result.Typ()
+			if allConst {
+				result.ReferenceValue(val)
+				return
+			}
+			result.SetValue(i, val)
+		}
+	}
+}
+
+func nestedFunc(args []common.LType, retTyp common.LType, bind bindScalarFunc) *FunctionV2 {
+	return &FunctionV2{
+		_args:    args,
+		_retType: retTyp,
+		_funcTyp: ScalarFuncType,
+		_bind:    bind,
+	}
+}
+
+// listIndex converts the 1-based index into the 0-based position.
+// The negative index counts from the end.
+func listIndex(idx int64, length int) int {
+	if idx < 0 {
+		return length + int(idx)
+	}
+	return int(idx) - 1
+}
+
+func bindListValue(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	typ := common.Null()
+	for i, arg := range args {
+		if i == 0 {
+			typ = arg.DataTyp
+		} else {
+			typ = decideResultType(typ, arg.DataTyp)
+		}
+	}
+	for i := range fun._args {
+		fun._args[i] = typ
+	}
+	fun._retType = common.ListType(typ)
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		elems := make([]*chunk.Value, len(args))
+		copy(elems, args)
+		return chunk.NewListValue(typ, elems)
+	})
+	return nil, nil
+}
+
+func bindStructPack(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	names := make([]string, len(args))
+	typs := make([]common.LType, len(args))
+	for i, arg := range args {
+		switch {
+		case arg.Alias != "":
+			names[i] = arg.Alias
+		case arg.Typ == ET_Column:
+			names[i] = arg.Name
+		default:
+			names[i] = fmt.Sprintf("v%d", i+1)
+		}
+		typs[i] = arg.DataTyp
+		fun._args[i] = arg.DataTyp
+	}
+	typ := common.StructType(names, typs)
+	fun._retType = typ
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		fields := make([]*chunk.Value, len(args))
+		copy(fields, args)
+		return chunk.NewStructValue(typ, fields)
+	})
+	return nil, nil
+}
+
+func bindStructExtract(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	typ := args[0].DataTyp
+	if typ.Id != common.LTID_STRUCT {
+		panic(fmt.Errorf("struct_extract on non-struct type %s", typ))
+	}
+	if args[1].Typ != ET_SConst {
+		panic(fmt.Errorf("struct_extract requires a constant field name"))
+	}
+	idx := typ.StructFieldIndex(args[1].Svalue)
+	if idx < 0 {
+		panic(fmt.Errorf("no field %s in %s", args[1].Svalue, typ))
+	}
+	fun._args[0] = typ
+	fun._retType = typ.Children[idx]
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull {
+			return nil
+		}
+		return args[0].Children[idx]
+	})
+	return nil, nil
+}
+
+func checkListArg(name string, typ common.LType) {
+	if typ.Id != common.LTID_LIST {
+		panic(fmt.Errorf("%s requires a list. got %s", name, typ))
+	}
+}
+
+func checkMapArg(name string, typ common.LType) {
+	if typ.Id != common.LTID_MAP {
+		panic(fmt.Errorf("%s requires a map. got %s", name, typ))
+	}
+}
+
+func bindListExtract(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	checkListArg("list_extract", args[0].DataTyp)
+	fun._args[0] = args[0].DataTyp
+	fun._retType = args[0].DataTyp.ListChild()
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull || args[1].IsNull {
+			return nil
+		}
+		pos := listIndex(args[1].I64, len(args[0].Children))
+		if pos < 0 || pos >= len(args[0].Children) {
+			return nil
+		}
+		return args[0].Children[pos]
+	})
+	return nil, nil
+}
+
+func bindListSlice(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	checkListArg("list_slice", args[0].DataTyp)
+	typ := args[0].DataTyp
+	fun._args[0] = typ
+	fun._retType = typ
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull || args[1].IsNull || args[2].IsNull {
+			return nil
+		}
+		length := len(args[0].Children)
+		begin := max(listIndex(args[1].I64, length), 0)
+		end := min(listIndex(args[2].I64, length)+1, length)
+		elems := make([]*chunk.Value, 0)
+		if begin < end {
+			elems = append(elems, args[0].Children[begin:end]...)
+		}
+		return chunk.NewListValue(typ.ListChild(), elems)
+	})
+	return nil, nil
+}
+
+func bindMapExtract(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	checkMapArg("map_extract", args[0].DataTyp)
+	fun._args[0] = args[0].DataTyp
+	fun._args[1] = args[0].DataTyp.MapKey()
+	fun._retType = args[0].DataTyp.MapValue()
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull || args[1].IsNull {
+			return nil
+		}
+		key := args[1].String()
+		for _, entry := range args[0].Children {
+			if entry.Children[0].String() == key {
+				return entry.Children[1]
+			}
+		}
+		return nil
+	})
+	return nil, nil
+}
+
+func bindMap(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	checkListArg("map", args[0].DataTyp)
+	checkListArg("map", args[1].DataTyp)
+	fun._args[0] = args[0].DataTyp
+	fun._args[1] = args[1].DataTyp
+	typ := common.MapType(args[0].DataTyp.ListChild(), args[1].DataTyp.ListChild())
+	entryTyp := typ.ListChild()
+	fun._retType = typ
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull || args[1].IsNull {
+			return nil
+		}
+		keys, values := args[0].Children, args[1].Children
+		if len(keys) != len(values) {
+			panic(fmt.Errorf("map requires the same count of keys and values. %d != %d", len(keys), len(values)))
+		}
+		entries := make([]*chunk.Value, len(keys))
+		for i := range keys {
+			if keys[i].IsNull {
+				panic(fmt.Errorf("map key can not be NULL"))
+			}
+			entries[i] = chunk.NewStructValue(entryTyp, []*chunk.Value{keys[i], values[i]})
+		}
+		return &chunk.Value{Children: entries}
+	})
+	return nil, nil
+}
+
+func bindMapEntries(keys bool) bindScalarFunc {
+	return func(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+		checkMapArg("map_keys", args[0].DataTyp)
+		typ := args[0].DataTyp
+		fun._args[0] = typ
+		field := 1
+		fun._retType = common.ListType(typ.MapValue())
+		if keys {
+			field = 0
+			fun._retType = common.ListType(typ.MapKey())
+		}
+		fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+			if args[0].IsNull {
+				return nil
+			}
+			elems := make([]*chunk.Value, len(args[0].Children))
+			for i, entry := range args[0].Children {
+				elems[i] = entry.Children[field]
+			}
+			return &chunk.Value{Children: elems}
+		})
+		return nil, nil
+	}
+}
+
+func bindListLength(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
+	typ := args[0].DataTyp
+	if typ.Id != common.LTID_LIST && typ.Id != common.LTID_MAP {
+		panic(fmt.Errorf("len requires a list or map. got %s", typ))
+	}
+	fun._args[0] = typ
+	fun._scalar = nestedFunction(func(args []*chunk.Value) *chunk.Value {
+		if args[0].IsNull {
+			return nil
+		}
+		return &chunk.Value{I64: int64(len(args[0].Children))}
+	})
+	return nil, nil
+}
+
+type NestedFunc struct {
+}
+
+func (NestedFunc) Register(funcList FunctionList) {
+	anyTyp := common.AnyType()
+	bigint := common.BigintType()
+
+	listValue := nestedFunc([]common.LType{}, anyTyp, bindListValue)
+	listValue._varargs = anyTyp
+	registerScalarFunc(funcList, []string{"list_value", "list_pack"}, listValue)
+
+	structPack := nestedFunc([]common.LType{}, anyTyp, bindStructPack)
+	structPack._varargs = anyTyp
+	registerScalarFunc(funcList, []string{"struct_pack"}, structPack)
+
+	registerScalarFunc(funcList, []string{"struct_extract"},
+		nestedFunc([]common.LType{anyTyp, common.VarcharType()}, anyTyp, bindStructExtract))
+
+	registerScalarFunc(funcList, []string{"list_extract", "list_element"},
+		nestedFunc([]common.LType{anyTyp, bigint}, anyTyp, bindListExtract))
+
+	registerScalarFunc(funcList, []string{"list_slice"},
+		nestedFunc([]common.LType{anyTyp, bigint, bigint}, anyTyp, bindListSlice))
+
+	registerScalarFunc(funcList, []string{"map_extract"},
+		nestedFunc([]common.LType{anyTyp, anyTyp}, anyTyp, bindMapExtract))
+
+	registerScalarFunc(funcList, []string{"map"},
+		nestedFunc([]common.LType{anyTyp, anyTyp}, anyTyp, bindMap))
+
+	registerScalarFunc(funcList, []string{"map_keys"},
+		nestedFunc([]common.LType{anyTyp}, anyTyp, bindMapEntries(true)))
+
+	registerScalarFunc(funcList, []string{"map_values"},
+		nestedFunc([]common.LType{anyTyp}, anyTyp, bindMapEntries(false)))
+
+	registerScalarFunc(funcList, []string{"len", "array_length", "cardinality"},
+		nestedFunc([]common.LType{anyTyp}, bigint, bindListLength))
+}
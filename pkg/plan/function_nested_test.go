@@ -0,0 +1,85 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_nestedVector(t *testing.T) {
+	structTyp := common.StructType(
+		[]string{"a", "b"},
+		[]common.LType{common.IntegerType(), common.VarcharType()})
+	listTyp := common.ListType(structTyp)
+	assert.Equal(t, "LIST(STRUCT(a INT32, b VARCHAR))", listTyp.String())
+
+	vec := chunk.NewFlatVector(listTyp, util.DefaultVectorSize)
+	elem := func(a int64, b string) *chunk.Value {
+		return chunk.NewStructValue(structTyp, []*chunk.Value{
+			{Typ: common.IntegerType(), I64: a},
+			{Typ: common.VarcharType(), Str: b},
+		})
+	}
+	vec.SetValue(0, chunk.NewListValue(structTyp, []*chunk.Value{elem(1, "x"), elem(2, "y")}))
+	vec.SetValue(1, &chunk.Value{Typ: listTyp, IsNull: true})
+	vec.SetValue(2, chunk.NewListValue(structTyp, nil))
+	assert.Equal(t, "[{'a': 1, 'b': x}, {'a': 2, 'b': y}]", vec.GetValue(0).String())
+	assert.Equal(t, 2, chunk.GetListSize(vec))
+
+	//encoded into VARCHAR and back
+	encoded := chunk.EncodeVector(vec, 3)
+	assert.Equal(t, common.LTID_VARCHAR, encoded.Typ().Id)
+	decoded := chunk.NewFlatVector(listTyp, util.DefaultVectorSize)
+	chunk.DecodeVector(encoded, decoded, 3)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, vec.GetValue(i).String(), decoded.GetValue(i).String())
+	}
+	assert.True(t, decoded.GetValue(1).IsNull)
+
+	assert.Equal(t, []common.LType{common.IntegerType(), common.VarcharType()},
+		rowTypes([]common.LType{common.IntegerType(), listTyp}))
+}
+
+func Test_listIndex(t *testing.T) {
+	assert.Equal(t, 0, listIndex(1, 3))
+	assert.Equal(t, 2, listIndex(-1, 3))
+	assert.Equal(t, -1, listIndex(0, 3))
+	assert.Equal(t, 3, listIndex(4, 3))
+}
+
+func Test_unnestTable(t *testing.T) {
+	tests := []struct {
+		sql    string
+		expect []string
+	}{
+		{"select x from unnest([1,2,3]) u(x)", []string{"1", "2", "3"}},
+		{"select * from unnest(['a','b'])", []string{"a", "b"}},
+		{"select u.x + g.i from unnest([10,20]) u(x), generate_series(1,2) g(i)",
+			[]string{"11", "12", "21", "22"}},
+		{"select u.x, g.i from unnest([1,2,3]) u(x) join generate_series(1,2) g(i) on u.x = g.i",
+			[]string{"1|1", "2|2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.expect, runSelectSQL(t, tt.sql))
+		})
+	}
+}
@@ -28,13 +28,13 @@ func ScalarNopFunc(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
 	result.Reference(input.Data[0])
 }
 
-func NopDecimalBind(fun *FunctionV2, args []*Expr) *FunctionData {
+func NopDecimalBind(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	fun._retType = args[0].DataTyp
 	fun._args[0] = args[0].DataTyp
-	return nil
+	return nil, nil
 }
 
-func BindDecimalAddSubstract(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalAddSubstract(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	maxWidth := 0
 	maxScale := 0
 	maxWidthOverScale := 0
@@ -80,7 +80,7 @@ func BindDecimalAddSubstract(fun *FunctionV2, args []*Expr) *FunctionData {
 	} else {
 		fun._scalar = GetScalarBinaryFunction(resTyp.GetInternalType(), fun._name, false)
 	}
-	return bindData
+	return bindData, nil
 }
 
 type AddFunc struct {
@@ -188,12 +188,38 @@ func (add AddFunc) Func2(lTyp, rTyp common.LType) *FunctionV2 {
 				_funcTyp: ScalarFuncType,
 				_scalar:  BinaryFunction[common.Interval, common.Date, common.Date](binIntervalDateAddOp),
 			}
+		case common.LTID_TIMESTAMP:
+			return &FunctionV2{
+				_name:    "+",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.TimestampType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[common.Interval, int64, int64](binIntervalTimestampAddOp),
+			}
 		default:
 			panic("usp")
 		}
 	case common.LTID_TIME:
+		if rTyp.Id == common.LTID_INTERVAL {
+			return &FunctionV2{
+				_name:    "+",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.TimeType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[int64, common.Interval, int64](binTimeIntervalAddOp),
+			}
+		}
 		panic("usp")
 	case common.LTID_TIMESTAMP:
+		if rTyp.Id == common.LTID_INTERVAL {
+			return &FunctionV2{
+				_name:    "+",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.TimestampType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[int64, common.Interval, int64](binTimestampIntervalAddOp),
+			}
+		}
 		panic("usp")
 	default:
 		panic(fmt.Sprintf("no addFunc for %s %s", lTyp, rTyp))
@@ -218,13 +244,10 @@ func (add AddFunc) Register(funcList FunctionList) {
 	//date|time|timestamp + interval
 	funcs.Add(add.Func2(common.DateType(), common.IntervalType()))
 	funcs.Add(add.Func2(common.IntervalType(), common.DateType()))
+	funcs.Add(add.Func2(common.TimeType(), common.IntervalType()))
+	funcs.Add(add.Func2(common.TimestampType(), common.IntervalType()))
+	funcs.Add(add.Func2(common.IntervalType(), common.TimestampType()))
 
-	//funcs.Add(add.Func2(timeLTyp(), intervalLType()))
-	//funcs.Add(add.Func2(intervalLType(), timeLTyp()))
-	//
-	//funcs.Add(add.Func2(timestampLTyp(), intervalLType()))
-	//funcs.Add(add.Func2(intervalLType(), timestampLTyp()))
-	//
 	////time + date, date + time
 	//funcs.Add(add.Func2(timeLTyp(), dateLTyp()))
 	//funcs.Add(add.Func2(dateLTyp(), timeLTyp()))
@@ -237,15 +260,15 @@ type SubFunc struct {
 func negateInterval(input *common.Interval, result *common.Interval) {
 	negateInt32(&input.Months, &result.Months)
 	negateInt32(&input.Days, &result.Days)
-	negateInt32(&input.Year, &result.Year)
+	negateInt64(&input.Micros, &result.Micros)
 }
 
-func DecimalNegateBind(fun *FunctionV2, args []*Expr) *FunctionData {
+func DecimalNegateBind(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	decTyp := args[0].DataTyp
 	fun._scalar = GetScalarUnaryFunction(decTyp, "-")
 	fun._args[0] = decTyp
 	fun._retType = decTyp
-	return nil
+	return nil, nil
 }
 
 func (sub SubFunc) Func(typ common.LType) *FunctionV2 {
@@ -316,7 +339,7 @@ func (sub SubFunc) Func2(lTyp, rTyp common.LType) *FunctionV2 {
 				_args:    []common.LType{lTyp, rTyp},
 				_retType: common.BigintType(),
 				_funcTyp: ScalarFuncType,
-				_scalar:  BinaryFunction[common.Date, common.Date, int64](nil),
+				_scalar:  BinaryFunction[common.Date, common.Date, int64](binDateDateSubOp),
 			}
 		} else if rTyp.Id == common.LTID_INTEGER {
 			return &FunctionV2{
@@ -324,7 +347,7 @@ func (sub SubFunc) Func2(lTyp, rTyp common.LType) *FunctionV2 {
 				_args:    []common.LType{lTyp, rTyp},
 				_retType: common.DateType(),
 				_funcTyp: ScalarFuncType,
-				_scalar:  BinaryFunction[common.Date, int32, common.Date](nil),
+				_scalar:  BinaryFunction[common.Date, int32, common.Date](binDateInt32SubOp),
 			}
 		} else if rTyp.Id == common.LTID_INTERVAL {
 			return &FunctionV2{
@@ -332,7 +355,7 @@ func (sub SubFunc) Func2(lTyp, rTyp common.LType) *FunctionV2 {
 				_args:    []common.LType{lTyp, rTyp},
 				_retType: common.DateType(),
 				_funcTyp: ScalarFuncType,
-				_scalar:  BinaryFunction[common.Date, common.Interval, common.Date](nil),
+				_scalar:  BinaryFunction[common.Date, common.Interval, common.Date](binDateInterSubOp),
 			}
 		}
 	case common.LTID_INTERVAL:
@@ -343,15 +366,43 @@ func (sub SubFunc) Func2(lTyp, rTyp common.LType) *FunctionV2 {
 				_args:    []common.LType{lTyp, rTyp},
 				_retType: common.IntervalType(),
 				_funcTyp: ScalarFuncType,
-				_scalar:  BinaryFunction[common.Interval, common.Interval, common.Interval](nil),
+				_scalar:  BinaryFunction[common.Interval, common.Interval, common.Interval](binIntervalIntervalSubOp),
 			}
 		default:
 			panic("usp")
 		}
 	case common.LTID_TIME:
+		if rTyp.Id == common.LTID_INTERVAL {
+			return &FunctionV2{
+				_name:    "-",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.TimeType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[int64, common.Interval, int64](binTimeIntervalSubOp),
+			}
+		}
 		panic("usp")
 	case common.LTID_TIMESTAMP:
-		panic("usp")
+		switch rTyp.Id {
+		case common.LTID_INTERVAL:
+			return &FunctionV2{
+				_name:    "-",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.TimestampType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[int64, common.Interval, int64](binTimestampIntervalSubOp),
+			}
+		case common.LTID_TIMESTAMP:
+			return &FunctionV2{
+				_name:    "-",
+				_args:    []common.LType{lTyp, rTyp},
+				_retType: common.IntervalType(),
+				_funcTyp: ScalarFuncType,
+				_scalar:  BinaryFunction[int64, int64, common.Interval](binTimestampTimestampSubOp),
+			}
+		default:
+			panic("usp")
+		}
 	default:
 		panic(fmt.Sprintf("no addFunc for %s %s", lTyp, rTyp))
 	}
@@ -372,6 +423,10 @@ func (sub SubFunc) Register(funcList FunctionList) {
 	//date - integer
 	subs.Add(sub.Func2(common.DateType(), common.IntegerType()))
 	//timestamp - timestamp
+	subs.Add(sub.Func2(common.TimestampType(), common.TimestampType()))
+	//timestamp|time - interval
+	subs.Add(sub.Func2(common.TimestampType(), common.IntervalType()))
+	subs.Add(sub.Func2(common.TimeType(), common.IntervalType()))
 	//interval - interval
 	subs.Add(sub.Func2(common.IntervalType(), common.IntervalType()))
 	//date - interval
@@ -418,11 +473,26 @@ func (MultiplyFunc) Register(funcList FunctionList) {
 			set.Add(fun)
 		}
 	}
+	//bigint * interval, interval * bigint
+	set.Add(&FunctionV2{
+		_name:    "*",
+		_args:    []common.LType{common.BigintType(), common.IntervalType()},
+		_retType: common.IntervalType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  BinaryFunction[int64, common.Interval, common.Interval](binInt64IntervalMulOp),
+	})
+	set.Add(&FunctionV2{
+		_name:    "*",
+		_args:    []common.LType{common.IntervalType(), common.BigintType()},
+		_retType: common.IntervalType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  BinaryFunction[common.Interval, int64, common.Interval](binIntervalInt64MulOp),
+	})
 
 	funcList.Add(ET_Mul.String(), set)
 }
 
-func BindDecimalMultiply(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalMultiply(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	bindData := &FunctionData{
 		_funDataTyp: DecimalBindData,
 	}
@@ -467,7 +537,7 @@ func BindDecimalMultiply(fun *FunctionV2, args []*Expr) *FunctionData {
 	} else {
 		fun._scalar = GetScalarBinaryFunction(resTyp.GetInternalType(), "*", false)
 	}
-	return bindData
+	return bindData, nil
 }
 
 type DevideFunc struct {
@@ -498,13 +568,13 @@ func (DevideFunc) Register(funcList FunctionList) {
 	funcList.Add(ET_Div.String(), set)
 }
 
-func BindDecimalDivide(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalDivide(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	fun._retType = args[0].DataTyp
 	for i, arg := range args {
 		fun._args[i] = arg.DataTyp
 	}
 	fun._scalar = BinaryFunction[common.Decimal, common.Decimal, common.Decimal](binDecimalDivOp)
-	return nil
+	return nil, nil
 }
 
 type LikeFunc struct {
@@ -590,18 +660,18 @@ func mulUint64CheckOf(left *uint64, right *uint64, result *uint64) {
 		left, right = right, left
 	}
 	if *left > math.MaxUint32 {
-		panic("uint64 * uint64 overflow")
+		panic(fmt.Errorf("uint64 * uint64 overflow"))
 	}
 	c := uint32(*right >> 32)
 	d := uint32(math.MaxUint32 & *right)
 	r := *left * uint64(c)
 	s := *left * uint64(d)
 	if r > math.MaxUint32 {
-		panic("uint64 * uint64 overflow")
+		panic(fmt.Errorf("uint64 * uint64 overflow"))
 	}
 	r <<= 32
 	if math.MaxUint64-s < r {
-		panic("uint64 * uint64 overflow")
+		panic(fmt.Errorf("uint64 * uint64 overflow"))
 	}
 	mulUint64(left, right, result)
 }
@@ -612,7 +682,7 @@ func mulUint32CheckOf(left *uint32, right *uint32, result *uint32) {
 	uresult := uint64(0)
 	mulUint64(&ul, &ur, &uresult)
 	if uresult > math.MaxUint32 {
-		panic("uint32 * uint32 overflow")
+		panic(fmt.Errorf("uint32 * uint32 overflow"))
 	}
 	*result = uint32(uresult)
 }
@@ -623,7 +693,7 @@ func mulUint16CheckOf(left *uint16, right *uint16, result *uint16) {
 	uresult := uint32(0)
 	mulUint32(&ul, &ur, &uresult)
 	if uresult > math.MaxUint16 {
-		panic("uint16 * uint16 overflow")
+		panic(fmt.Errorf("uint16 * uint16 overflow"))
 	}
 	*result = uint16(uresult)
 }
@@ -634,61 +704,18 @@ func mulUint8CheckOf(left *uint8, right *uint8, result *uint8) {
 	uresult := uint16(0)
 	mulUint16(&ul, &ur, &uresult)
 	if uresult > math.MaxUint8 {
-		panic("uint8 * uint8 overflow")
+		panic(fmt.Errorf("uint8 * uint8 overflow"))
 	}
 	*result = uint8(uresult)
 }
 
 func mulInt64CheckOf(left *int64, right *int64, result *int64) {
-	if *left == math.MinInt64 {
-		if *right == 0 {
-			*result = 0
-			return
-		}
-		if *right == 1 {
-			*result = *left
-			return
-		}
-		panic("int64 * int64 overflow")
+	ures := *left * *right
+	if *left != 0 &&
+		(ures/(*left) != *right || (*left == -1 && *right == math.MinInt64)) {
+		panic(fmt.Errorf("int64 * int64 overflow"))
 	}
-	if *right == math.MinInt64 {
-		if *left == 0 {
-			*result = 0
-			return
-		}
-		if *left == 1 {
-			*result = *right
-			return
-		}
-		panic("int64 * int64 overflow")
-	}
-	leftNonNegative := uint64(*left)
-	rightNonNegative := uint64(*right)
-	leftHighBits := leftNonNegative >> 32
-	leftLowBits := leftNonNegative & 0xffffffff
-	rightHighBits := rightNonNegative >> 32
-	rightLowBits := rightNonNegative & 0xffffffff
-
-	if leftHighBits == 0 {
-		if rightHighBits != 0 {
-			lowLow := leftLowBits * rightLowBits
-			lowHigh := leftLowBits * rightHighBits
-			highBits := lowHigh + (lowLow >> 32)
-			if (highBits & 0xffffff80000000) != 0 {
-				panic("int64 * int64 overflow")
-			}
-		}
-	} else if rightHighBits == 0 {
-		lowLow := leftLowBits * rightLowBits
-		highLow := leftHighBits * rightLowBits
-		highBits := highLow + (lowLow >> 32)
-		if (highBits & 0xffffff80000000) != 0 {
-			panic("int64 * int64 overflow")
-		}
-	} else {
-		panic("int64 * int64 overflow")
-	}
-	*result = *left * *right
+	*result = ures
 }
 
 func mulInt32CheckOf(left *int32, right *int32, result *int32) {
@@ -697,7 +724,7 @@ func mulInt32CheckOf(left *int32, right *int32, result *int32) {
 	ures := int64(0)
 	mulInt64(&ul, &ur, &ures)
 	if ures < math.MinInt32 || ures > math.MaxInt32 {
-		panic("int32 * int32 overflow")
+		panic(fmt.Errorf("int32 * int32 overflow"))
 	}
 	*result = int32(ures)
 }
@@ -708,7 +735,7 @@ func mulInt16CheckOf(left *int16, right *int16, result *int16) {
 	ures := int32(0)
 	mulInt32(&ul, &ur, &ures)
 	if ures < math.MinInt16 || ures > math.MaxInt16 {
-		panic("int16 * int16 overflow")
+		panic(fmt.Errorf("int16 * int16 overflow"))
 	}
 	*result = int16(ures)
 }
@@ -719,7 +746,7 @@ func mulInt8CheckOf(left *int8, right *int8, result *int8) {
 	ures := int16(0)
 	mulInt16(&ul, &ur, &ures)
 	if ures < math.MinInt8 || ures > math.MaxInt8 {
-		panic("int8 * int8 overflow")
+		panic(fmt.Errorf("int8 * int8 overflow"))
 	}
 	*result = int8(ures)
 }
@@ -818,28 +845,28 @@ func GetScalarIntegerSubFunctionWithOverflow(ptyp common.PhyType) ScalarFunc {
 
 func subUint64CheckOf(left *uint64, right *uint64, result *uint64) {
 	if *right > *left {
-		panic("uint64 - uint64 overflow")
+		panic(fmt.Errorf("uint64 - uint64 overflow"))
 	}
 	subUint64(left, right, result)
 }
 
 func subUint32CheckOf(left *uint32, right *uint32, result *uint32) {
 	if *right > *left {
-		panic("uint32 - uint32 overflow")
+		panic(fmt.Errorf("uint32 - uint32 overflow"))
 	}
 	subUint32(left, right, result)
 }
 
 func subUint16CheckOf(left *uint16, right *uint16, result *uint16) {
 	if *right > *left {
-		panic("uint16 - uint16 overflow")
+		panic(fmt.Errorf("uint16 - uint16 overflow"))
 	}
 	subUint16(left, right, result)
 }
 
 func subUint8CheckOf(left *uint8, right *uint8, result *uint8) {
 	if *right > *left {
-		panic("uint8 - uint8 overflow")
+		panic(fmt.Errorf("uint8 - uint8 overflow"))
 	}
 	subUint8(left, right, result)
 }
@@ -847,11 +874,11 @@ func subUint8CheckOf(left *uint8, right *uint8, result *uint8) {
 func subInt64CheckOf(left *int64, right *int64, result *int64) {
 	if *right < 0 {
 		if math.MaxInt64+*right < *left {
-			panic("int64 - int64 overflow")
+			panic(fmt.Errorf("int64 - int64 overflow"))
 		}
 	} else {
 		if math.MinInt64+*right > *left {
-			panic("int64 - int64 overflow")
+			panic(fmt.Errorf("int64 - int64 overflow"))
 		}
 	}
 	*result = *left - *right
@@ -863,7 +890,7 @@ func subInt32CheckOf(left *int32, right *int32, result *int32) {
 	ures := int64(0)
 	subInt64(&ul, &ur, &ures)
 	if ures < math.MinInt32 || ures > math.MaxInt32 {
-		panic("int32 - int32 overflow")
+		panic(fmt.Errorf("int32 - int32 overflow"))
 	}
 	*result = int32(ures)
 }
@@ -874,7 +901,7 @@ func subInt16CheckOf(left *int16, right *int16, result *int16) {
 	ures := int32(0)
 	subInt32(&ul, &ur, &ures)
 	if ures < math.MinInt16 || ures > math.MaxInt16 {
-		panic("int16 - int16 overflow")
+		panic(fmt.Errorf("int16 - int16 overflow"))
 	}
 	*result = int16(ures)
 }
@@ -885,7 +912,7 @@ func subInt8CheckOf(left *int8, right *int8, result *int8) {
 	ures := int16(0)
 	subInt16(&ul, &ur, &ures)
 	if ures < math.MinInt8 || ures > math.MaxInt8 {
-		panic("int8 - int8 overflow")
+		panic(fmt.Errorf("int8 - int8 overflow"))
 	}
 	*result = int8(ures)
 }
@@ -1206,6 +1233,9 @@ func (equal EqualFunc) Register(funcList FunctionList) {
 	set.Add(equalStr)
 	set.Add(equalBool)
 
+	for _, fun := range temporalCompareFuncs(ET_Equal.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_Equal.String(), set)
 }
 
@@ -1232,6 +1262,9 @@ func (equal NotEqualFunc) Register(funcList FunctionList) {
 	set.Add(notEqualFunc1)
 	set.Add(notEqualStr)
 
+	for _, fun := range temporalCompareFuncs(ET_NotEqual.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_NotEqual.String(), set)
 }
 
@@ -1320,6 +1353,9 @@ func (Greater) Register(funcList FunctionList) {
 	set.Add(gt3)
 	set.Add(gt4)
 
+	for _, fun := range temporalCompareFuncs(ET_Greater.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_Greater.String(), set)
 }
 
@@ -1355,6 +1391,9 @@ func (GreaterThan) Register(funcList FunctionList) {
 	set.Add(gtDate)
 	set.Add(gtFloat)
 
+	for _, fun := range temporalCompareFuncs(ET_GreaterEqual.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_GreaterEqual.String(), set)
 }
 
@@ -1431,6 +1470,9 @@ func (LessFunc) Register(funcList FunctionList) {
 	set.Add(lInt)
 	set.Add(lFloat)
 	set.Add(lDouble)
+	for _, fun := range temporalCompareFuncs(ET_Less.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_Less.String(), set)
 }
 
@@ -1460,6 +1502,9 @@ func (LessEqualFunc) Register(funcList FunctionList) {
 	set.Add(leDate)
 	set.Add(leInt)
 	set.Add(leFloat)
+	for _, fun := range temporalCompareFuncs(ET_LessEqual.String()) {
+		set.Add(fun)
+	}
 	funcList.Add(ET_LessEqual.String(), set)
 }
 
@@ -1493,10 +1538,10 @@ func (CaseFunc) Register(funcList FunctionList) {
 	funcList.Add(ET_Case.String(), set)
 }
 
-func BindDecimalCaseWhen(fun *FunctionV2, args []*Expr) *FunctionData {
+func BindDecimalCaseWhen(fun *FunctionV2, args []*Expr) (*FunctionData, error) {
 	//type of else
 	fun._retType = args[0].DataTyp
-	return nil
+	return nil, nil
 }
 
 type ExtractFunc struct {
@@ -1516,6 +1561,27 @@ func (ExtractFunc) Register(funcList FunctionList) {
 	}
 
 	set.Add(extract)
+	set.Add(&FunctionV2{
+		_name:    ET_Extract.String(),
+		_args:    []common.LType{common.VarcharType(), common.TimestampType()},
+		_retType: common.BigintType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  BinaryFunction[common.String, int64, int64](timestampPartOp),
+	})
+	set.Add(&FunctionV2{
+		_name:    ET_Extract.String(),
+		_args:    []common.LType{common.VarcharType(), common.TimeType()},
+		_retType: common.BigintType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  BinaryFunction[common.String, int64, int64](timePartOp),
+	})
+	set.Add(&FunctionV2{
+		_name:    ET_Extract.String(),
+		_args:    []common.LType{common.VarcharType(), common.IntervalType()},
+		_retType: common.BigintType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  BinaryFunction[common.String, common.Interval, int64](intervalPartOp),
+	})
 
 	funcList.Add(ET_Extract.String(), set)
 }
@@ -1554,3 +1620,21 @@ func (SubstringFunc) Register(funcList FunctionList) {
 
 	funcList.Add(ET_Substring.String(), set)
 }
+
+type HllEstimateFunc struct {
+}
+
+func (HllEstimateFunc) Register(funcList FunctionList) {
+	set := NewFunctionSet("hll_estimate", ScalarFuncType)
+
+	estimate := &FunctionV2{
+		_name:    "hll_estimate",
+		_args:    []common.LType{common.VarcharType()},
+		_retType: common.BigintType(),
+		_funcTyp: ScalarFuncType,
+		_scalar:  UnaryFunction[common.String, int64](hllEstimate),
+	}
+	set.Add(estimate)
+
+	funcList.Add("hll_estimate", set)
+}
@@ -0,0 +1,448 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// makeString copies the bytes into the new string
+func makeString(data []byte, result *common.String) {
+	if len(data) == 0 {
+		*result = common.String{}
+		return
+	}
+	result.Data = util.CMalloc(len(data))
+	result.Len = len(data)
+	copy(util.PointerToSlice[byte](result.Data, result.Len), data)
+}
+
+// dictFunction evaluates the function on the dictionary vector.
+//
+// If the first argument is a dictionary vector and the others
+// are constant, the function is evaluated only once for each
+// distinct entry of the dictionary that is referenced.
+// Then the results are scattered into the result vector.
+func dictFunction[R any](fun ScalarFunc) ScalarFunc {
+	return func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+		vec := input.Data[0]
+		if !vec.PhyFormat().IsDict() {
+			fun(input, state, result)
+			return
+		}
+		for _, other := range input.Data[1:] {
+			if !other.PhyFormat().IsConst() {
+				fun(input, state, result)
+				return
+			}
+		}
+
+		count := input.Card()
+		sel := chunk.GetSelVectorInPhyFormatDict(vec)
+		//position of the entry in the dictionary -> position in the entries
+		entryPos := make(map[int]int)
+		entrySel := chunk.NewSelectVector(count)
+		resSel := make([]int, count)
+		entryCount := 0
+		for i := 0; i < count; i++ {
+			idx := sel.GetIndex(i)
+			pos, has := entryPos[idx]
+			if !has {
+				pos = entryCount
+				entryPos[idx] = pos
+				entrySel.SetIndex(pos, idx)
+				entryCount++
+			}
+			resSel[i] = pos
+		}
+		if entryCount == count {
+			//no duplicate entry
+			fun(input, state, result)
+			return
+		}
+
+		entries := &chunk.Chunk{
+			Data: make([]*chunk.Vector, len(input.Data)),
+		}
+		entries.SetCap(util.DefaultVectorSize)
+		entries.SetCard(entryCount)
+		entries.Data[0] = chunk.NewVector(vec.Typ(), false, 0)
+		entries.Data[0].Slice(chunk.GetChildInPhyFormatDict(vec), entrySel, entryCount)
+		copy(entries.Data[1:], input.Data[1:])
+
+		entryRes := chunk.NewFlatVector(result.Typ(), util.DefaultVectorSize)
+		fun(entries, state, entryRes)
+
+		var edata chunk.UnifiedFormat
+		entryRes.ToUnifiedFormat(entryCount, &edata)
+		entrySlice := chunk.GetSliceInPhyFormatUnifiedFormat[R](&edata)
+		result.SetPhyFormat(chunk.PF_FLAT)
+		resSlice := chunk.GetSliceInPhyFormatFlat[R](result)
+		resMask := chunk.GetMaskInPhyFormatFlat(result)
+		for i := 0; i < count; i++ {
+			eidx := edata.Sel.GetIndex(resSel[i])
+			if edata.Mask.RowIsValid(uint64(eidx)) {
+				resMask.SetValid(uint64(i))
+				resSlice[i] = entrySlice[eidx]
+			} else {
+				resMask.SetInvalid(uint64(i))
+			}
+		}
+	}
+}
+
+// concatFunction concatenates all the arguments.
+// The NULL argument is ignored.
+func concatFunction(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+	count := input.Card()
+	allConst := true
+	for _, vec := range input.Data {
+		if !vec.PhyFormat().IsConst() {
+			allConst = false
+			break
+		}
+	}
+	if allConst {
+		count = 1
+		result.SetPhyFormat(chunk.PF_CONST)
+	} else {
+		result.SetPhyFormat(chunk.PF_FLAT)
+	}
+
+	datas := make([]chunk.UnifiedFormat, len(input.Data))
+	slices := make([][]common.String, len(input.Data))
+	for i, vec := range input.Data {
+		vec.ToUnifiedFormat(count, &datas[i])
+		slices[i] = chunk.GetSliceInPhyFormatUnifiedFormat[common.String](&datas[i])
+	}
+	resSlice := chunk.GetSliceInPhyFormatFlat[common.String](result)
+	buf := bytes.Buffer{}
+	for i := 0; i < count; i++ {
+		buf.Reset()
+		for j := range datas {
+			idx := datas[j].Sel.GetIndex(i)
+			if datas[j].Mask.RowIsValid(uint64(idx)) {
+				buf.Write(slices[j][idx].DataSlice())
+			}
+		}
+		makeString(buf.Bytes(), &resSlice[i])
+	}
+}
+
+// concatOp is the operator ||. NULL if any argument is NULL.
+func concatOp(left, right *common.String, result *common.String) {
+	data := make([]byte, 0, left.Length()+right.Length())
+	data = append(data, left.DataSlice()...)
+	data = append(data, right.DataSlice()...)
+	makeString(data, result)
+}
+
+func lengthOp(input *common.String, result *int64) {
+	*result = int64(utf8.RuneCount(input.DataSlice()))
+}
+
+func upperOp(input *common.String, result *common.String) {
+	makeString(bytes.ToUpper(input.DataSlice()), result)
+}
+
+func lowerOp(input *common.String, result *common.String) {
+	makeString(bytes.ToLower(input.DataSlice()), result)
+}
+
+type TrimKind int
+
+const (
+	TRIM_BOTH TrimKind = iota
+	TRIM_LEFT
+	TRIM_RIGHT
+)
+
+func trimString(s string, chars string, kind TrimKind) string {
+	switch kind {
+	case TRIM_LEFT:
+		return strings.TrimLeft(s, chars)
+	case TRIM_RIGHT:
+		return strings.TrimRight(s, chars)
+	default:
+		return strings.Trim(s, chars)
+	}
+}
+
+func trimOp(kind TrimKind) UnaryOp[common.String, common.String] {
+	return func(input *common.String, result *common.String) {
+		makeString([]byte(trimString(input.String(), " ", kind)), result)
+	}
+}
+
+func trimCharsOp(kind TrimKind) BinaryOp[common.String, common.String, common.String] {
+	return func(input *common.String, chars *common.String, result *common.String) {
+		makeString([]byte(trimString(input.String(), chars.String(), kind)), result)
+	}
+}
+
+func replaceOp(input *common.String, from *common.String, to *common.String, result *common.String) {
+	if from.Length() == 0 {
+		makeString(input.DataSlice(), result)
+		return
+	}
+	makeString(bytes.ReplaceAll(input.DataSlice(), from.DataSlice(), to.DataSlice()), result)
+}
+
+// positionOp returns the position of the first character of
+// the substring in the string. 1-based. 0 if there is no substring.
+func positionOp(input *common.String, sub *common.String, result *int64) {
+	data := input.DataSlice()
+	idx := bytes.Index(data, sub.DataSlice())
+	if idx < 0 {
+		*result = 0
+		return
+	}
+	*result = int64(utf8.RuneCount(data[:idx])) + 1
+}
+
+// leftOp returns the first n characters of the string.
+// If n is negative, it returns all but the last |n| characters.
+func leftOp(input *common.String, n *int32, result *common.String) {
+	runes := []rune(input.String())
+	cnt := int(*n)
+	if cnt < 0 {
+		cnt = max(len(runes)+cnt, 0)
+	}
+	cnt = min(cnt, len(runes))
+	makeString([]byte(string(runes[:cnt])), result)
+}
+
+// rightOp returns the last n characters of the string.
+// If n is negative, it returns all but the first |n| characters.
+func rightOp(input *common.String, n *int32, result *common.String) {
+	runes := []rune(input.String())
+	cnt := int(*n)
+	if cnt < 0 {
+		cnt = max(len(runes)+cnt, 0)
+	}
+	cnt = min(cnt, len(runes))
+	makeString([]byte(string(runes[len(runes)-cnt:])), result)
+}
+
+// padString fills the string up to the length with the fill.
+// The string is truncated if it is longer than the length.
+func padString(input *common.String, length int32, fill *common.String, left bool, result *common.String) {
+	runes := []rune(input.String())
+	cnt := max(int(length), 0)
+	if len(runes) >= cnt {
+		makeString([]byte(string(runes[:cnt])), result)
+		return
+	}
+	fillRunes := []rune(fill.String())
+	if len(fillRunes) == 0 {
+		makeString([]byte(string(runes)), result)
+		return
+	}
+	pad := make([]rune, 0, cnt-len(runes))
+	for i := 0; len(pad) < cnt-len(runes); i++ {
+		pad = append(pad, fillRunes[i%len(fillRunes)])
+	}
+	if left {
+		makeString([]byte(string(pad)+string(runes)), result)
+	} else {
+		makeString([]byte(string(runes)+string(pad)), result)
+	}
+}
+
+func padOp(left bool) TernaryOp[common.String, int32, common.String, common.String] {
+	return func(input *common.String, length *int32, fill *common.String, result *common.String) {
+		padString(input, *length, fill, left, result)
+	}
+}
+
+func padSpaceOp(left bool) BinaryOp[common.String, int32, common.String] {
+	space := common.String{}
+	makeString([]byte(" "), &space)
+	return func(input *common.String, length *int32, result *common.String) {
+		padString(input, *length, &space, left, result)
+	}
+}
+
+// splitPartOp splits the string by the delimiter and returns the
+// n-th field. 1-based. If n is negative, it counts from the end.
+func splitPartOp(input *common.String, delim *common.String, n *int32, result *common.String) {
+	if *n == 0 {
+		panic(fmt.Errorf("field position must not be zero"))
+	}
+	var parts [][]byte
+	if delim.Length() == 0 {
+		parts = [][]byte{input.DataSlice()}
+	} else {
+		parts = bytes.Split(input.DataSlice(), delim.DataSlice())
+	}
+	idx := int(*n) - 1
+	if *n < 0 {
+		idx = len(parts) + int(*n)
+	}
+	if idx < 0 || idx >= len(parts) {
+		*result = common.String{}
+		return
+	}
+	makeString(parts[idx], result)
+}
+
+func reverseOp(input *common.String, result *common.String) {
+	runes := []rune(input.String())
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	makeString([]byte(string(runes)), result)
+}
+
+func startsWithOp(input *common.String, prefix *common.String, result *bool) {
+	*result = bytes.HasPrefix(input.DataSlice(), prefix.DataSlice())
+}
+
+func repeatOp(input *common.String, n *int32, result *common.String) {
+	if *n <= 0 {
+		*result = common.String{}
+		return
+	}
+	makeString(bytes.Repeat(input.DataSlice(), int(*n)), result)
+}
+
+func md5Op(input *common.String, result *common.String) {
+	sum := md5.Sum(input.DataSlice())
+	makeString([]byte(hex.EncodeToString(sum[:])), result)
+}
+
+// stringFunc creates the scalar function on strings
+// with the fast path for the dictionary vector.
+func stringFunc[R any](args []common.LType, retTyp common.LType, fun ScalarFunc) *FunctionV2 {
+	return &FunctionV2{
+		_args:    args,
+		_retType: retTyp,
+		_funcTyp: ScalarFuncType,
+		_scalar:  dictFunction[R](fun),
+	}
+}
+
+// registerScalarFunc registers the overloads under all the names
+func registerScalarFunc(funcList FunctionList, names []string, funs ...*FunctionV2) {
+	for _, name := range names {
+		set := NewFunctionSet(name, ScalarFuncType)
+		for _, fun := range funs {
+			fun = fun.Copy()
+			fun._name = name
+			set.Add(fun)
+		}
+		funcList.Add(name, set)
+	}
+}
+
+type StringFunc struct {
+}
+
+func (StringFunc) Register(funcList FunctionList) {
+	varchar := common.VarcharType()
+	integer := common.IntegerType()
+
+	registerScalarFunc(funcList, []string{"length", "char_length", "character_length"},
+		stringFunc[int64]([]common.LType{varchar}, common.BigintType(),
+			UnaryFunction[common.String, int64](lengthOp)))
+
+	registerScalarFunc(funcList, []string{"upper", "ucase"},
+		stringFunc[common.String]([]common.LType{varchar}, varchar,
+			UnaryFunction[common.String, common.String](upperOp)))
+
+	registerScalarFunc(funcList, []string{"lower", "lcase"},
+		stringFunc[common.String]([]common.LType{varchar}, varchar,
+			UnaryFunction[common.String, common.String](lowerOp)))
+
+	trims := map[TrimKind][]string{
+		TRIM_BOTH:  {"trim", "btrim"},
+		TRIM_LEFT:  {"ltrim"},
+		TRIM_RIGHT: {"rtrim"},
+	}
+	for kind, names := range trims {
+		registerScalarFunc(funcList, names,
+			stringFunc[common.String]([]common.LType{varchar}, varchar,
+				UnaryFunction[common.String, common.String](trimOp(kind))),
+			stringFunc[common.String]([]common.LType{varchar, varchar}, varchar,
+				BinaryFunction[common.String, common.String, common.String](trimCharsOp(kind))))
+	}
+
+	concat := &FunctionV2{
+		_args:    []common.LType{varchar},
+		_varargs: varchar,
+		_retType: varchar,
+		_funcTyp: ScalarFuncType,
+		_scalar:  concatFunction,
+	}
+	registerScalarFunc(funcList, []string{"concat"}, concat)
+
+	registerScalarFunc(funcList, []string{"||"},
+		stringFunc[common.String]([]common.LType{varchar, varchar}, varchar,
+			BinaryFunction[common.String, common.String, common.String](concatOp)))
+
+	registerScalarFunc(funcList, []string{"replace"},
+		stringFunc[common.String]([]common.LType{varchar, varchar, varchar}, varchar,
+			TernaryFunction[common.String, common.String, common.String, common.String](replaceOp)))
+
+	//position(sub in s) is position(s, sub)
+	registerScalarFunc(funcList, []string{"position", "strpos", "instr"},
+		stringFunc[int64]([]common.LType{varchar, varchar}, common.BigintType(),
+			BinaryFunction[common.String, common.String, int64](positionOp)))
+
+	registerScalarFunc(funcList, []string{"left"},
+		stringFunc[common.String]([]common.LType{varchar, integer}, varchar,
+			BinaryFunction[common.String, int32, common.String](leftOp)))
+
+	registerScalarFunc(funcList, []string{"right"},
+		stringFunc[common.String]([]common.LType{varchar, integer}, varchar,
+			BinaryFunction[common.String, int32, common.String](rightOp)))
+
+	for name, left := range map[string]bool{"lpad": true, "rpad": false} {
+		registerScalarFunc(funcList, []string{name},
+			stringFunc[common.String]([]common.LType{varchar, integer}, varchar,
+				BinaryFunction[common.String, int32, common.String](padSpaceOp(left))),
+			stringFunc[common.String]([]common.LType{varchar, integer, varchar}, varchar,
+				TernaryFunction[common.String, int32, common.String, common.String](padOp(left))))
+	}
+
+	registerScalarFunc(funcList, []string{"split_part"},
+		stringFunc[common.String]([]common.LType{varchar, varchar, integer}, varchar,
+			TernaryFunction[common.String, common.String, int32, common.String](splitPartOp)))
+
+	registerScalarFunc(funcList, []string{"reverse"},
+		stringFunc[common.String]([]common.LType{varchar}, varchar,
+			UnaryFunction[common.String, common.String](reverseOp)))
+
+	registerScalarFunc(funcList, []string{"starts_with"},
+		stringFunc[bool]([]common.LType{varchar, varchar}, common.BooleanType(),
+			BinaryFunction[common.String, common.String, bool](startsWithOp)))
+
+	registerScalarFunc(funcList, []string{"repeat"},
+		stringFunc[common.String]([]common.LType{varchar, integer}, varchar,
+			BinaryFunction[common.String, int32, common.String](repeatOp)))
+
+	registerScalarFunc(funcList, []string{"md5"},
+		stringFunc[common.String]([]common.LType{varchar}, varchar,
+			UnaryFunction[common.String, common.String](md5Op)))
+}
@@ -0,0 +1,80 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_stringOps(t *testing.T) {
+	str := func(s string) *common.String {
+		ret := &common.String{}
+		makeString([]byte(s), ret)
+		return ret
+	}
+	var res common.String
+	padString(str("hi"), 5, str("xy"), true, &res)
+	assert.Equal(t, "xyxhi", res.String())
+	padString(str("hello"), 3, str("xy"), false, &res)
+	assert.Equal(t, "hel", res.String())
+
+	n := int32(-1)
+	splitPartOp(str("a,b,c"), str(","), &n, &res)
+	assert.Equal(t, "c", res.String())
+	n = 4
+	splitPartOp(str("a,b,c"), str(","), &n, &res)
+	assert.Equal(t, "", res.String())
+
+	n = -2
+	leftOp(str("wörld"), &n, &res)
+	assert.Equal(t, "wör", res.String())
+	rightOp(str("wörld"), &n, &res)
+	assert.Equal(t, "rld", res.String())
+
+	var pos int64
+	positionOp(str("wörld"), str("l"), &pos)
+	assert.Equal(t, int64(4), pos)
+}
+
+func Test_dictFunction(t *testing.T) {
+	calls := 0
+	upper := UnaryFunction[common.String, common.String](upperOp)
+	fun := dictFunction[common.String](func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+		calls += input.Card()
+		upper(input, state, result)
+	})
+
+	//dictionary with 2 entries referenced by 4 rows
+	dict := chunk.NewVarcharFlatVector([]string{"a", "b", "c"}, 3)
+	vec := chunk.NewVector(common.VarcharType(), false, 0)
+	vec.Slice(dict, chunk.NewSelectVector3([]int{2, 0, 2, 0}), 4)
+
+	input := &chunk.Chunk{Data: []*chunk.Vector{vec}}
+	input.SetCap(util.DefaultVectorSize)
+	input.SetCard(4)
+	result := chunk.NewFlatVector(common.VarcharType(), util.DefaultVectorSize)
+	fun(input, nil, result)
+
+	assert.Equal(t, 2, calls)
+	for i, want := range []string{"C", "A", "C", "A"} {
+		assert.Equal(t, want, result.GetValue(i).Str)
+	}
+}
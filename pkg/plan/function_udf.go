@@ -0,0 +1,291 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+// funcLock guards the scalarFuncs, the aggrFuncs, the aggNames
+// and the udfs against the registration at runtime.
+var funcLock sync.RWMutex
+
+// udfs records the functions registered at runtime by the name.
+var udfs = make(map[string][]*FunctionV2)
+
+// ScalarFunctionDef describes the user-defined scalar function.
+type ScalarFunctionDef struct {
+	Name string
+	Args []common.LType
+	// Varargs is the type of the extra arguments after the Args.
+	// The zero value denotes no extra arguments.
+	Varargs common.LType
+	Return  common.LType
+	// DefaultNullHandling yields NULL if any argument is NULL.
+	// SpecialHandling passes the NULLs to the Func.
+	NullHandling FuncNullHandling
+	SideEffects  FuncSideEffects
+	// Func evaluates the chunk of the arguments into the result.
+	// RowFunction adapts the function on the single row.
+	Func ScalarFunc
+}
+
+// AggregateFunctionDef describes the user-defined aggregate function.
+// The state is any go value.
+type AggregateFunctionDef struct {
+	Name   string
+	Args   []common.LType
+	Return common.LType
+	// DefaultNullHandling skips the rows that have NULL arguments.
+	// SpecialHandling passes them to the Update.
+	NullHandling FuncNullHandling
+	// Init returns the empty state.
+	Init func() any
+	// Update accumulates the row into the state and returns the new state.
+	Update func(state any, args []*chunk.Value) any
+	// Combine merges the source into the target and returns the new target.
+	Combine func(source, target any) any
+	// Finalize returns the result of the state. nil denotes NULL.
+	Finalize func(state any) *chunk.Value
+}
+
+// RowFunction adapts the function on the row into the ScalarFunc.
+// nil result denotes NULL.
+func RowFunction(fun func(args []*chunk.Value) *chunk.Value) ScalarFunc {
+	return nestedFunction(fun)
+}
+
+// RegisterScalarFunction adds the scalar function. It can be called at runtime.
+// The function is an overload if the name exists already.
+func RegisterScalarFunction(def ScalarFunctionDef) error {
+	name, err := checkFunctionDef(def.Name, def.Args, def.Return)
+	if err != nil {
+		return err
+	}
+	if def.Func == nil {
+		return fmt.Errorf("function %s has no implementation", name)
+	}
+	fun := &FunctionV2{
+		_name:         name,
+		_args:         def.Args,
+		_varargs:      def.Varargs,
+		_retType:      def.Return,
+		_funcTyp:      ScalarFuncType,
+		_sideEffects:  def.SideEffects,
+		_nullHandling: def.NullHandling,
+		_scalar:       def.Func,
+	}
+	if def.NullHandling == DefaultNullHandling {
+		fun._scalar = propagateNull(def.Func)
+	}
+
+	funcLock.Lock()
+	defer funcLock.Unlock()
+	if _, ok := aggrFuncs[name]; ok {
+		return fmt.Errorf("function %s is an aggregate function", name)
+	}
+	err = addOverload(scalarFuncs, name, ScalarFuncType, fun)
+	if err != nil {
+		return err
+	}
+	udfs[name] = append(udfs[name], fun)
+	return nil
+}
+
+// RegisterAggregateFunction adds the aggregate function. It can be called at runtime.
+// The function is an overload if the name exists already.
+func RegisterAggregateFunction(def AggregateFunctionDef) error {
+	name, err := checkFunctionDef(def.Name, def.Args, def.Return)
+	if err != nil {
+		return err
+	}
+	if def.Init == nil || def.Update == nil || def.Combine == nil || def.Finalize == nil {
+		return fmt.Errorf("function %s requires Init, Update, Combine and Finalize", name)
+	}
+	fun := HolisticAggregate[udafState](def.Args, def.Return, udafOp{def: &def})
+	fun._name = name
+	fun._nullHandling = def.NullHandling
+
+	funcLock.Lock()
+	defer funcLock.Unlock()
+	if _, ok := scalarFuncs[name]; ok {
+		return fmt.Errorf("function %s is a scalar function", name)
+	}
+	err = addOverload(aggrFuncs, name, AggregateFuncType, fun)
+	if err != nil {
+		return err
+	}
+	aggNames[name] = 1
+	udfs[name] = append(udfs[name], fun)
+	return nil
+}
+
+// UnregisterFunction removes the functions registered at runtime by the name.
+// The builtin overloads of the name are kept.
+func UnregisterFunction(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	funcLock.Lock()
+	defer funcLock.Unlock()
+	funs, ok := udfs[name]
+	if !ok {
+		return fmt.Errorf("no user-defined function %s", name)
+	}
+	delete(udfs, name)
+	flist, ftyp := scalarFuncs, ScalarFuncType
+	if funs[0]._funcTyp == AggregateFuncType {
+		flist, ftyp = aggrFuncs, AggregateFuncType
+	}
+	//the set is copied. the binder may be reading the old one.
+	set := NewFunctionSet(name, ftyp)
+	for _, exist := range flist[name]._functions {
+		if !slices.Contains(funs, exist) {
+			set.Add(exist)
+		}
+	}
+	if len(set._functions) != 0 {
+		flist[name] = set
+		return nil
+	}
+	delete(flist, name)
+	if ftyp == AggregateFuncType {
+		delete(aggNames, name)
+	}
+	return nil
+}
+
+func checkFunctionDef(name string, args []common.LType, ret common.LType) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("function name is empty")
+	}
+	for i, arg := range args {
+		if arg.Id == common.LTID_INVALID {
+			return "", fmt.Errorf("function %s has invalid type of argument %d", name, i)
+		}
+	}
+	if ret.Id == common.LTID_INVALID || ret.Id == common.LTID_ANY {
+		return "", fmt.Errorf("function %s has invalid return type %s", name, ret)
+	}
+	return name, nil
+}
+
+// addOverload adds the function into the set of the name.
+// The set is copied. The binder may be reading the old one.
+func addOverload(flist FunctionList, name string, ftyp FuncType, fun *FunctionV2) error {
+	set := NewFunctionSet(name, ftyp)
+	if old, ok := flist[name]; ok {
+		for _, exist := range old._functions {
+			if sameSignature(exist, fun) {
+				return fmt.Errorf("function %s%s already registered", name, signatureString(fun))
+			}
+		}
+		set._functions = append(set._functions, old._functions...)
+	}
+	set.Add(fun)
+	flist[name] = set
+	return nil
+}
+
+func sameSignature(a, b *FunctionV2) bool {
+	if len(a._args) != len(b._args) || !a._varargs.Equal(b._varargs) {
+		return false
+	}
+	for i := range a._args {
+		if !a._args[i].Equal(b._args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func signatureString(fun *FunctionV2) string {
+	parts := make([]string, 0, len(fun._args)+1)
+	for _, arg := range fun._args {
+		parts = append(parts, arg.String())
+	}
+	if fun.hasVarargs() {
+		parts = append(parts, fun._varargs.String()+"...")
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// propagateNull sets the result NULL on the rows that have NULL arguments.
+func propagateNull(fun ScalarFunc) ScalarFunc {
+	return func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
+		fun(input, state, result)
+		count := input.Card()
+		formats := input.ToUnifiedFormat()
+		flattened := false
+		for i := 0; i < count; i++ {
+			null := false
+			for _, format := range formats {
+				if !format.Mask.RowIsValid(uint64(format.Sel.GetIndex(i))) {
+					null = true
+					break
+				}
+			}
+			if !null {
+				continue
+			}
+			if !flattened {
+				result.Flatten(count)
+				flattened = true
+			}
+			chunk.SetNullInPhyFormatFlat(result, uint64(i), true)
+		}
+	}
+}
+
+type udafState struct {
+	val any
+}
+
+// udafOp runs the user-defined aggregate on the HolisticAggregate.
+type udafOp struct {
+	def *AggregateFunctionDef
+}
+
+func (op udafOp) Init() *udafState {
+	return &udafState{val: op.def.Init()}
+}
+
+func (op udafOp) Update(state *udafState, row []*chunk.Value) {
+	if op.def.NullHandling == DefaultNullHandling {
+		for _, val := range row {
+			if val.IsNull {
+				return
+			}
+		}
+	}
+	state.val = op.def.Update(state.val, row)
+}
+
+func (op udafOp) Combine(source, target *udafState) {
+	target.val = op.def.Combine(source.val, target.val)
+}
+
+func (op udafOp) Finalize(state *udafState, retTyp common.LType) *chunk.Value {
+	return op.def.Finalize(state.val)
+}
+
+func (op udafOp) IgnoreNull() bool {
+	return false
+}
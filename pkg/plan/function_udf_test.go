@@ -0,0 +1,143 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+func Test_registerFunction(t *testing.T) {
+	bigint := common.BigintType()
+	evalConst := func(name string, args ...*Expr) *chunk.Value {
+		expr := gFuncBinder.BindScalarFunc(name, args, ET_Invalid, false)
+		data := &chunk.Chunk{}
+		data.Init([]common.LType{expr.DataTyp}, storage.STANDARD_VECTOR_SIZE)
+		tmp := &chunk.Chunk{}
+		tmp.SetCard(1)
+		require.NoError(t, NewExprExec(expr).executeExprs([]*chunk.Chunk{tmp, nil, nil}, data))
+		return data.Data[0].GetValue(0)
+	}
+	iconst := func(v int64) *Expr {
+		return &Expr{Typ: ET_IConst, DataTyp: bigint, Ivalue: v}
+	}
+	null := &Expr{Typ: ET_NConst, DataTyp: common.Null()}
+	//the functions are global
+	t.Cleanup(func() {
+		_ = UnregisterFunction("udf_add")
+		_ = UnregisterFunction("udf_product")
+	})
+
+	err := RegisterScalarFunction(ScalarFunctionDef{
+		Name:   "Udf_Add",
+		Args:   []common.LType{bigint, bigint},
+		Return: bigint,
+		Func: RowFunction(func(args []*chunk.Value) *chunk.Value {
+			return &chunk.Value{I64: args[0].I64 + args[1].I64}
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), evalConst("udf_add", iconst(2), iconst(3)).I64)
+	assert.True(t, evalConst("udf_add", iconst(2), null).IsNull)
+
+	//the overload
+	err = RegisterScalarFunction(ScalarFunctionDef{
+		Name:    "udf_add",
+		Args:    []common.LType{bigint},
+		Varargs: bigint,
+		Return:  common.VarcharType(),
+		Func: RowFunction(func(args []*chunk.Value) *chunk.Value {
+			return &chunk.Value{Str: "varargs"}
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "varargs", evalConst("udf_add", iconst(1), iconst(2), iconst(3)).Str)
+
+	//the conflicts
+	err = RegisterScalarFunction(ScalarFunctionDef{
+		Name:   "udf_add",
+		Args:   []common.LType{bigint, bigint},
+		Return: bigint,
+		Func:   RowFunction(func(args []*chunk.Value) *chunk.Value { return nil }),
+	})
+	assert.ErrorContains(t, err, "already registered")
+	err = RegisterScalarFunction(ScalarFunctionDef{
+		Name:   "sum",
+		Args:   []common.LType{common.VarcharType()},
+		Return: bigint,
+		Func:   RowFunction(func(args []*chunk.Value) *chunk.Value { return nil }),
+	})
+	assert.ErrorContains(t, err, "aggregate function")
+	err = RegisterScalarFunction(ScalarFunctionDef{Name: "udf_nil", Return: bigint})
+	assert.Error(t, err)
+	err = RegisterScalarFunction(ScalarFunctionDef{
+		Name: "udf_any",
+		Args: []common.LType{bigint},
+		Func: RowFunction(func(args []*chunk.Value) *chunk.Value { return nil }),
+	})
+	assert.Error(t, err)
+
+	def := AggregateFunctionDef{
+		Name:   "udf_product",
+		Args:   []common.LType{bigint},
+		Return: bigint,
+		Init:   func() any { return int64(1) },
+		Update: func(state any, args []*chunk.Value) any {
+			return state.(int64) * args[0].I64
+		},
+		Combine: func(source, target any) any {
+			return source.(int64) * target.(int64)
+		},
+		Finalize: func(state any) *chunk.Value {
+			return &chunk.Value{I64: state.(int64)}
+		},
+	}
+	require.NoError(t, RegisterAggregateFunction(def))
+	assert.True(t, IsAgg("udf_product"))
+	expr, err := gFuncBinder.BindAggrFunc("udf_product", []*Expr{iconst(1)}, ET_Invalid, false)
+	require.NoError(t, err)
+	assert.Equal(t, bigint.Id, expr.DataTyp.Id)
+	assert.ErrorContains(t, RegisterAggregateFunction(def), "already registered")
+	def.Name = "udf_add"
+	assert.ErrorContains(t, RegisterAggregateFunction(def), "scalar function")
+
+	assert.Equal(t, []string{"120"}, runSelectSQL(t, "select udf_product(i) from generate_series(1,5) g(i)"))
+	assert.Equal(t, []string{"0|8", "1|15"},
+		runSelectSQL(t, "select i % 2, udf_product(i) from generate_series(1,5) g(i) group by i % 2"))
+	assert.Equal(t, []string{"24"},
+		runSelectSQL(t, "select udf_product(i) filter (where i < 5) from generate_series(1,5) g(i)"))
+}
+
+func Test_unregisterFunction(t *testing.T) {
+	bigint := common.BigintType()
+	require.NoError(t, RegisterScalarFunction(ScalarFunctionDef{
+		Name:   "abs",
+		Args:   []common.LType{bigint, bigint},
+		Return: bigint,
+		Func:   RowFunction(func(args []*chunk.Value) *chunk.Value { return nil }),
+	}))
+	builtins := len(scalarFuncs["abs"]._functions)
+	require.NoError(t, UnregisterFunction("ABS"))
+	//the builtin overloads are kept
+	assert.Len(t, scalarFuncs["abs"]._functions, builtins-1)
+	assert.Error(t, UnregisterFunction("abs"))
+	assert.Error(t, UnregisterFunction("udf_none"))
+}
@@ -0,0 +1,250 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+// buildSeries binds generate_series and range.
+// generate_series(start, stop[, step]) includes the stop. range excludes it.
+// the integers are BIGINT. the dates and timestamps are TIMESTAMP with the interval step.
+func (b *Builder) buildSeries(
+	name string,
+	call *pg_query.FuncCall,
+	alias *pg_query.Alias,
+	ctx *BindContext,
+	depth int) (*Expr, error) {
+	if len(call.GetArgs()) < 1 || len(call.GetArgs()) > 3 {
+		return nil, fmt.Errorf("%s requires 1 to 3 arguments", name)
+	}
+	args := make([]*Expr, 0)
+	temporal := false
+	for _, arg := range call.GetArgs() {
+		expr, err := b.bindExpr(ctx, IWC_VALUES, arg, depth)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.DataTyp.Id {
+		case common.LTID_DATE, common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+			common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+			temporal = true
+		}
+		args = append(args, expr)
+	}
+
+	typ := common.BigintType()
+	argTyps := []common.LType{typ, typ, typ}
+	if temporal {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("%s over timestamps requires the interval step", name)
+		}
+		typ = common.TimestampType()
+		argTyps = []common.LType{typ, typ, common.IntervalType()}
+	} else {
+		if len(args) == 1 {
+			//start from 0
+			args = append([]*Expr{{Typ: ET_IConst, DataTyp: typ, Ivalue: 0}}, args...)
+		}
+		if len(args) == 2 {
+			args = append(args, &Expr{Typ: ET_IConst, DataTyp: typ, Ivalue: 1})
+		}
+	}
+	for i, arg := range args {
+		var err error
+		args[i], err = AddCastToType(arg, argTyps[i], false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := []string{name}
+	typs := []common.LType{typ}
+	bind, err := b.addTableFuncBinding(name, alias, names, typs, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{
+		Typ:         ET_TableFunc,
+		Index:       bind.index,
+		Table:       name,
+		Alias:       bind.alias,
+		BelongCtx:   ctx,
+		Types:       typs,
+		Names:       names,
+		Values:      [][]*Expr{args},
+		ColName2Idx: map[string]int{name: 0},
+	}, err
+}
+
+// seriesInfo is the evaluated arguments of the series.
+type seriesInfo struct {
+	typ       common.LType
+	start     int64
+	stop      int64
+	step      int64
+	interval  common.Interval
+	inclusive bool
+	//the step is negative
+	desc bool
+	//some argument is NULL
+	empty bool
+}
+
+func newSeriesInfo(args []*Expr, typ common.LType, inclusive bool) (*seriesInfo, error) {
+	argTyps := make([]common.LType, len(args))
+	for i, arg := range args {
+		argTyps[i] = arg.DataTyp
+	}
+	data := &chunk.Chunk{}
+	data.Init(argTyps, storage.STANDARD_VECTOR_SIZE)
+	tmp := &chunk.Chunk{}
+	tmp.SetCard(1)
+	err := NewExprExec(args...).executeExprs([]*chunk.Chunk{tmp, nil, nil}, data)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &seriesInfo{
+		typ:       typ,
+		inclusive: inclusive,
+	}
+	vals := make([]*chunk.Value, len(args))
+	for i := range args {
+		vals[i] = data.Data[i].GetValue(0)
+		if vals[i].IsNull {
+			info.empty = true
+			return info, nil
+		}
+	}
+	info.start = vals[0].I64
+	info.stop = vals[1].I64
+	if typ.Id == common.LTID_BIGINT {
+		info.step = vals[2].I64
+		if info.step == 0 {
+			return nil, fmt.Errorf("the step of the series can not be 0")
+		}
+		info.desc = info.step < 0
+		return info, nil
+	}
+
+	info.interval = common.Interval{
+		Months: int32(vals[2].I64),
+		Days:   int32(vals[2].I64_1),
+		Micros: vals[2].I64_2,
+	}
+	iv := info.interval
+	switch {
+	case iv.Months == 0 && iv.Days == 0 && iv.Micros == 0:
+		return nil, fmt.Errorf("the interval of the series can not be 0")
+	case iv.Months >= 0 && iv.Days >= 0 && iv.Micros >= 0:
+	case iv.Months <= 0 && iv.Days <= 0 && iv.Micros <= 0:
+		info.desc = true
+	default:
+		return nil, fmt.Errorf("the interval %s of the series has mixed signs", iv.String())
+	}
+	return info, nil
+}
+
+// contains returns true if the value is not beyond the stop
+func (info *seriesInfo) contains(val int64) bool {
+	switch {
+	case info.desc && info.inclusive:
+		return val >= info.stop
+	case info.desc:
+		return val > info.stop
+	case info.inclusive:
+		return val <= info.stop
+	default:
+		return val < info.stop
+	}
+}
+
+// next returns the value after val at the idx. false if it overflows.
+// the timestamp is start + idx * interval. it does not drift at the end of the month.
+func (info *seriesInfo) next(val int64, idx int64) (int64, bool) {
+	if info.typ.Id != common.LTID_BIGINT {
+		iv := common.Interval{
+			Months: info.interval.Months * int32(idx),
+			Days:   info.interval.Days * int32(idx),
+			Micros: info.interval.Micros * idx,
+		}
+		return common.TimestampAddInterval(info.start, &iv), true
+	}
+	if info.step > 0 && val > math.MaxInt64-info.step ||
+		info.step < 0 && val < math.MinInt64-info.step {
+		return 0, false
+	}
+	return val + info.step, true
+}
+
+// estimatedCount returns the approximate count of the values
+func (info *seriesInfo) estimatedCount() float64 {
+	if info.empty {
+		return 0
+	}
+	step := float64(info.step)
+	if info.typ.Id != common.LTID_BIGINT {
+		step = float64(info.interval.Months)*30*float64(common.MicrosPerDay) +
+			float64(info.interval.Days)*float64(common.MicrosPerDay) +
+			float64(info.interval.Micros)
+	}
+	cnt := (float64(info.stop)-float64(info.start))/step + 1
+	return max(cnt, 0)
+}
+
+// seriesState is the cursor of the series in the scan
+type seriesState struct {
+	cur int64
+	//index of the cur in the series
+	idx  int64
+	done bool
+}
+
+// readSeries generates the next values of the series
+func (run *Runner) readSeries(output *chunk.Chunk, maxCnt int) error {
+	info := run.op.series
+	if run.series == nil {
+		run.series = &seriesState{
+			cur:  info.start,
+			done: info.empty,
+		}
+	}
+	state := run.series
+	vec := output.Data[0]
+	cnt := 0
+	for ; cnt < maxCnt && !state.done; cnt++ {
+		if !info.contains(state.cur) {
+			state.done = true
+			break
+		}
+		vec.SetValue(cnt, &chunk.Value{Typ: info.typ, I64: state.cur})
+		state.idx++
+		next, ok := info.next(state.cur, state.idx)
+		if !ok {
+			state.done = true
+		}
+		state.cur = next
+	}
+	output.SetCard(cnt)
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func seriesValues(info *seriesInfo) []int64 {
+	ret := make([]int64, 0)
+	cur := info.start
+	for idx := int64(1); info.contains(cur); idx++ {
+		ret = append(ret, cur)
+		next, ok := info.next(cur, idx)
+		if !ok {
+			break
+		}
+		cur = next
+	}
+	return ret
+}
+
+func Test_series(t *testing.T) {
+	bigint := common.BigintType()
+	iconst := func(v int64) *Expr {
+		return &Expr{Typ: ET_IConst, DataTyp: bigint, Ivalue: v}
+	}
+
+	info, err := newSeriesInfo([]*Expr{iconst(1), iconst(10), iconst(3)}, bigint, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 4, 7, 10}, seriesValues(info))
+	assert.Equal(t, float64(4), info.estimatedCount())
+
+	//range excludes the stop
+	info, err = newSeriesInfo([]*Expr{iconst(10), iconst(1), iconst(-3)}, bigint, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 7, 4}, seriesValues(info))
+
+	_, err = newSeriesInfo([]*Expr{iconst(1), iconst(10), iconst(0)}, bigint, true)
+	assert.Error(t, err)
+
+	//stop at the overflow
+	info = &seriesInfo{typ: bigint, start: math.MaxInt64 - 3, stop: math.MaxInt64, step: 2, inclusive: true}
+	assert.Equal(t, []int64{math.MaxInt64 - 3, math.MaxInt64 - 1}, seriesValues(info))
+
+	//the months do not drift
+	start, err := common.ParseTimestamp("2024-01-31 00:00:00")
+	require.NoError(t, err)
+	stop, err := common.ParseTimestamp("2024-05-31 00:00:00")
+	require.NoError(t, err)
+	info = &seriesInfo{
+		typ:       common.TimestampType(),
+		start:     start,
+		stop:      stop,
+		interval:  common.Interval{Months: 2},
+		inclusive: true,
+	}
+	values := seriesValues(info)
+	require.Len(t, values, 3)
+	assert.Equal(t, stop, values[2])
+}
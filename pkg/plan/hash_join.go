@@ -30,6 +30,8 @@ const (
 	HJS_BUILD
 	HJS_PROBE
 	HJS_SCAN_HT
+	//join the spilled partitions
+	HJS_PARTITION
 	HJS_DONE
 )
 
@@ -61,11 +63,27 @@ type HashJoin struct {
 	_leftIndice  []int
 	_rightIndice []int
 	_markIndex   int
+
+	_op *PhysicalOperator
+	//the budget of the hash table. the build side over it
+	//and the probe side are spilled into the partitions
+	_memoryLimit int
+	_radixLevel  int
+	_buildParts  []*spillWriter
+	_probeParts  []*spillWriter
+	//the partition joined now
+	_partIdx    int
+	_partJoin   *HashJoin
+	_partProbe  *spillWriter
+	_partReader *spillReader
+	_partInput  *chunk.Chunk
+	_ctxCheck   ctxCheck
 }
 
 func NewHashJoin(op *PhysicalOperator, conds []*Expr) *HashJoin {
 	hj := new(HashJoin)
 	hj._hjs = HJS_INIT
+	hj._op = op
 	hj._conds = copyExprs(conds...)
 	for _, cond := range conds {
 		hj._keyTypes = append(hj._keyTypes, cond.Children[0].DataTyp)
@@ -126,14 +144,344 @@ func (hj *HashJoin) Build(input *chunk.Chunk) error {
 
 	//build th
 	if len(hj._buildTypes) != 0 {
-		hj._ht.Build(hj._joinKeys, input)
+		return hj.build(hj._joinKeys, input)
 	} else {
 		hj._buildChunk.SetCard(input.Card())
-		hj._ht.Build(hj._joinKeys, hj._buildChunk)
+		return hj.build(hj._joinKeys, hj._buildChunk)
+	}
+}
+
+func (hj *HashJoin) build(keys, payload *chunk.Chunk) error {
+	if hj.spilled() {
+		return hj.spillBuild(keys, payload)
+	}
+	hj._ht.Build(keys, payload)
+	if hj.exceedMemoryLimit() {
+		return hj.spill()
+	}
+	return nil
+}
+
+func (hj *HashJoin) Finalize() error {
+	if !hj.spilled() {
+		return hj._ht.Finalize(hj._ctxCheck)
+	}
+	for _, part := range hj._buildParts {
+		if part != nil {
+			err := part.Close()
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
+// Execute probes the hash table with the input. The results are in the next.
+// It returns haveMoreOutput if the input has more results.
+// The input is spilled into the partitions if the build side is spilled.
+func (hj *HashJoin) Execute(input, next *chunk.Chunk) (OperatorResult, error) {
+	if hj._scan == nil {
+		if !hj.spilled() && hj._ht.count() == 0 &&
+			(hj._op.JoinTyp == LOT_JoinTypeInner || hj._op.JoinTyp == LOT_JoinTypeSEMI) {
+			//no matches on the empty build side
+			return NeedMoreInput, nil
+		}
+		hj._joinKeys.Reset()
+		err := hj._probExec.executeExprs([]*chunk.Chunk{input, nil, nil}, hj._joinKeys)
+		if err != nil {
+			return InvalidOpResult, err
+		}
+		if hj.spilled() {
+			return NeedMoreInput, hj.spillProbe(input)
+		}
+		hj._scan = hj._ht.Probe(hj._joinKeys)
+		hj._scan._leftChunk = input
+	}
+	hj._scan.Next(hj._joinKeys, hj._scan._leftChunk, next)
+	if next.Card() > 0 {
+		return haveMoreOutput, nil
+	}
+	hj._scan = nil
+	return NeedMoreInput, nil
+}
+
+/*
+Grace hash join.
+
+The build side is partitioned by the radix of the hash when
+the hash table exceeds the memory limit. The rows in the hash table
+are moved into the partitions also. Then the probe side is
+partitioned in the same way.
+
+After the last input of the probe side, the partitions are joined
+one by one. The partition that exceeds the memory limit again
+is partitioned by the next radix of the hash recursively.
+*/
+
+// spilled returns true if the build side is in the partitions
+func (hj *HashJoin) spilled() bool {
+	return hj._buildParts != nil
+}
+
+func (hj *HashJoin) exceedMemoryLimit() bool {
+	return hj._memoryLimit > 0 &&
+		hj._radixLevel < maxRadixLevel &&
+		hj._ht._dataCollection.SizeInBytes() > hj._memoryLimit
+}
+
+// spill moves the rows of the hash table into the partitions
+func (hj *HashJoin) spill() error {
+	hj._buildParts = make([]*spillWriter, radixPartitions)
+	hj._probeParts = make([]*spillWriter, radixPartitions)
+	err := hj._ht.scanRows(hj._ctxCheck, hj.spillBuild)
+	if err != nil {
+		return err
+	}
+	hj._ht.Close()
+	return nil
+}
+
+// scanBuild calls the fn on the keys and the payload of the build side
+// in the hash table or in the partitions. The build side is not finalized.
+func (hj *HashJoin) scanBuild(fn func(keys, payload *chunk.Chunk) error) error {
+	if !hj.spilled() {
+		return hj._ht.scanRows(hj._ctxCheck, fn)
+	}
+	for _, part := range hj._buildParts {
+		if part == nil {
+			continue
+		}
+		err := part.Close()
+		if err != nil {
+			return err
+		}
+		err = readBuildPart(part, hj._ctxCheck, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBuildPart calls the fn on the keys and the payload in the partition
+func readBuildPart(part *spillWriter, check ctxCheck, fn func(keys, payload *chunk.Chunk) error) error {
+	reader := newSpillReader(part)
+	defer reader.Close()
+	for {
+		err := check.err()
+		if err != nil {
+			return err
+		}
+		keys := &chunk.Chunk{}
+		err = reader.Next(keys)
+		if err != nil {
+			return err
+		}
+		if keys.Card() == 0 {
+			return nil
+		}
+		payload := &chunk.Chunk{}
+		err = reader.Next(payload)
+		if err != nil {
+			return err
+		}
+		err = fn(keys, payload)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (hj *HashJoin) spillBuild(keys, payload *chunk.Chunk) error {
+	var keyData []*chunk.UnifiedFormat
+	var curSel *chunk.SelectVector
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	count := hj._ht.prepareKeys(keys, &keyData, &curSel, sel, true)
+	if count < keys.Card() {
+		hj._ht._hasNull = true
+	}
+	if count == 0 {
+		return nil
+	}
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	hj._ht.hash(keys, curSel, count, hashes)
+	return hj.partition(hj._buildParts, hashes, curSel, count, keys, payload)
+}
+
+// spillProbe writes the input into the partitions by the hash of the join keys.
+// The rows with the NULL keys are in the partitions also.
+func (hj *HashJoin) spillProbe(input *chunk.Chunk) error {
+	if input.Card() == 0 {
+		return nil
+	}
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	hj._ht.hash(hj._joinKeys, nil, input.Card(), hashes)
+	return hj.partition(
+		hj._probeParts,
+		hashes,
+		chunk.IncrSelectVectorInPhyFormatFlat(),
+		input.Card(),
+		input,
+	)
+}
+
+// partition appends the rows in the sel into the partitions by the hashes
+func (hj *HashJoin) partition(
+	parts []*spillWriter,
+	hashes *chunk.Vector,
+	sel *chunk.SelectVector,
+	count int,
+	data ...*chunk.Chunk,
+) error {
+	var hdata chunk.UnifiedFormat
+	hashes.ToUnifiedFormat(data[0].Card(), &hdata)
+	hashSlice := chunk.GetSliceInPhyFormatUnifiedFormat[uint64](&hdata)
+	sels := make([]*chunk.SelectVector, radixPartitions)
+	counts := make([]int, radixPartitions)
+	for i := 0; i < count; i++ {
+		idx := sel.GetIndex(i)
+		part := radixPartition(hashSlice[hdata.Sel.GetIndex(idx)], hj._radixLevel)
+		if sels[part] == nil {
+			sels[part] = chunk.NewSelectVector(util.DefaultVectorSize)
+		}
+		sels[part].SetIndex(counts[part], idx)
+		counts[part]++
+	}
+	for part, psel := range sels {
+		if psel == nil {
+			continue
+		}
+		if parts[part] == nil {
+			parts[part] = newSpillWriter(storage.GBufferMgr)
+		}
+		for _, src := range data {
+			err := parts[part].Append(sliceChunk(src, psel, counts[part]))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExecutePartitions joins the partitions after the last input.
+// The results are in the next. It returns haveMoreOutput if
+// there are more results.
+func (hj *HashJoin) ExecutePartitions(next *chunk.Chunk) (OperatorResult, error) {
+	for hj.spilled() {
+		if hj._partJoin == nil {
+			if hj._partIdx >= radixPartitions {
+				return Done, nil
+			}
+			err := hj.buildPartition(hj._partIdx)
+			hj._partIdx++
+			if err != nil {
+				return InvalidOpResult, err
+			}
+			continue
+		}
+		if hj._partInput != nil {
+			res, err := hj._partJoin.Execute(hj._partInput, next)
+			if err != nil {
+				return InvalidOpResult, err
+			}
+			if res == haveMoreOutput {
+				return haveMoreOutput, nil
+			}
+			hj._partInput = nil
+		}
+		input := &chunk.Chunk{}
+		err := hj._partReader.Next(input)
+		if err != nil {
+			return InvalidOpResult, err
+		}
+		if input.Card() != 0 {
+			hj._partInput = input
+			continue
+		}
+		//the partition may be spilled also
+		res, err := hj._partJoin.ExecutePartitions(next)
+		if err != nil {
+			return InvalidOpResult, err
+		}
+		if res == haveMoreOutput {
+			return haveMoreOutput, nil
+		}
+		hj.closePartition()
+	}
+	return Done, nil
+}
+
+// buildPartition builds the hash table of the partition.
+// The partition without the probe side is skipped.
+func (hj *HashJoin) buildPartition(idx int) error {
+	build := hj._buildParts[idx]
+	probe := hj._probeParts[idx]
+	hj._buildParts[idx] = nil
+	hj._probeParts[idx] = nil
+	if build != nil {
+		defer build.Destroy()
+	}
+	if probe == nil {
+		return nil
+	}
+	hj._partProbe = probe
+	err := probe.Close()
+	if err != nil {
+		return err
+	}
+	part := NewHashJoin(hj._op, hj._conds)
+	part._memoryLimit = hj._memoryLimit
+	part._radixLevel = hj._radixLevel + 1
+	part._ctxCheck = hj._ctxCheck
+	part._ht._hasNull = hj._ht._hasNull
+	hj._partJoin = part
+	if build != nil {
+		err = readBuildPart(build, hj._ctxCheck, part.build)
+		if err != nil {
+			return err
+		}
+	}
+	err = part.Finalize()
+	if err != nil {
+		return err
+	}
+	hj._partReader = newSpillReader(probe)
+	return nil
+}
+
+func (hj *HashJoin) closePartition() {
+	if hj._partJoin != nil {
+		hj._partJoin.Close()
+		hj._partJoin._ht.Close()
+		hj._partJoin = nil
+	}
+	if hj._partReader != nil {
+		hj._partReader.Close()
+		hj._partReader = nil
+	}
+	if hj._partProbe != nil {
+		hj._partProbe.Destroy()
+		hj._partProbe = nil
+	}
+	hj._partInput = nil
+}
+
+// Close removes the partitions
+func (hj *HashJoin) Close() {
+	hj.closePartition()
+	for _, parts := range [][]*spillWriter{hj._buildParts, hj._probeParts} {
+		for _, part := range parts {
+			if part != nil {
+				part.Destroy()
+			}
+		}
+	}
+	hj._buildParts = nil
+	hj._probeParts = nil
+}
+
 type Scan struct {
 	_keyData    []*chunk.UnifiedFormat
 	_pointers   *chunk.Vector
@@ -249,7 +597,6 @@ func (scan *Scan) NextMarkJoin(keys, left, result *chunk.Chunk) {
 	//assertFunc(result.columnCount() ==
 	//	left.columnCount()+1)
 	util.AssertFunc(util.Back(result.Data).Typ().Id == common.LTID_BOOLEAN)
-	util.AssertFunc(scan._ht.count() > 0)
 	scan.ScanKeyMatches(keys)
 	scan.constructMarkJoinResult(keys, left, result)
 	scan._finished = true
@@ -319,7 +666,7 @@ func (scan *Scan) NextInnerJoin(keys, left, result *chunk.Chunk) {
 		//right part result
 		for i := 0; i < len(scan._ht._buildTypes); i++ {
 			vec := result.Data[left.ColumnCount()+i]
-			util.AssertFunc(vec.Typ() == scan._ht._buildTypes[i])
+			util.AssertFunc(vec.Typ().Equal(scan._ht._buildTypes[i]))
 			scan.gatherResult2(
 				vec,
 				resVec,
@@ -622,8 +969,15 @@ func (jht *JoinHashTable) InitPointerTable() {
 	jht._bitmask = pCap - 1
 }
 
-func (jht *JoinHashTable) Finalize() {
+// Finalize inserts the rows into the pointer table.
+// The check is done on every chunk.
+func (jht *JoinHashTable) Finalize(check ctxCheck) error {
 	jht.InitPointerTable()
+	if jht.count() == 0 {
+		//the empty build side or the partition without the build side
+		jht._finalized = true
+		return nil
+	}
 	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
 	hashSlice := chunk.GetSliceInPhyFormatFlat[uint64](hashes)
 	iter := NewTupleDataChunkIterator2(
@@ -632,6 +986,10 @@ func (jht *JoinHashTable) Finalize() {
 		false,
 	)
 	for {
+		err := check.err()
+		if err != nil {
+			return err
+		}
 		//reset hashes
 		for j := 0; j < util.DefaultVectorSize; j++ {
 			hashSlice[j] = uint64(0)
@@ -650,6 +1008,7 @@ func (jht *JoinHashTable) Finalize() {
 		}
 	}
 	jht._finalized = true
+	return nil
 }
 
 //func (jht *JoinHashTable) printHashMap() {
@@ -753,7 +1112,7 @@ func (jht *JoinHashTable) Probe(keys *chunk.Chunk) *Scan {
 }
 
 func (jht *JoinHashTable) initScan(keys *chunk.Chunk, curSel **chunk.SelectVector) *Scan {
-	util.AssertFunc(jht.count() > 0)
+	//the hash table of the partition can be empty
 	util.AssertFunc(jht._finalized)
 	newScan := NewScan(jht)
 	if jht._joinType != LOT_JoinTypeInner {
@@ -769,10 +1128,54 @@ func (jht *JoinHashTable) initScan(keys *chunk.Chunk, curSel **chunk.SelectVecto
 	return newScan
 }
 
+// scanRows calls the fn on the keys and the payload of the rows
+func (jht *JoinHashTable) scanRows(check ctxCheck, fn func(keys, payload *chunk.Chunk) error) error {
+	//without the hash
+	scanTypes := jht._layout.types()[:jht._layout.columnCount()-1]
+	state := NewTupleDataScanState(len(scanTypes), PIN_PRRP_KEEP_PINNED)
+	for i := range scanTypes {
+		state._colIds = append(state._colIds, i)
+	}
+	jht._dataCollection.InitScan(state)
+	for {
+		err := check.err()
+		if err != nil {
+			return err
+		}
+		rows := &chunk.Chunk{}
+		rows.Init(scanTypes, util.DefaultVectorSize)
+		if !jht._dataCollection.Scan(state, rows) {
+			return nil
+		}
+		keys := &chunk.Chunk{}
+		keys.Init(jht._keyTypes, util.DefaultVectorSize)
+		for i := range keys.Data {
+			keys.Data[i].Reference(rows.Data[i])
+		}
+		keys.SetCard(rows.Card())
+		payload := &chunk.Chunk{}
+		payload.Init(jht._buildTypes, util.DefaultVectorSize)
+		for i := range payload.Data {
+			payload.Data[i].Reference(rows.Data[len(jht._keyTypes)+i])
+		}
+		payload.SetCard(rows.Card())
+		err = fn(keys, payload)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (jht *JoinHashTable) count() int {
 	return jht._dataCollection.Count()
 }
 
+// Close releases the memory of the hash table
+func (jht *JoinHashTable) Close() {
+	jht._dataCollection.Close()
+	jht._hashMap = nil
+}
+
 type JoinScan struct {
 }
 
@@ -848,7 +1251,7 @@ func NewTupleDataLayout(types []common.LType, aggrObjs []*AggrObject, childrenOu
 	//all columns + children output columns are constant size
 	for _, lType := range append(layout._types, layout._childrenOutputTypes...) {
 		layout._allConst = layout._allConst &&
-			lType.GetInternalType().IsConstant()
+			rowPhyType(lType).IsConstant()
 	}
 
 	if needHeapOffset && !layout._allConst {
@@ -860,9 +1263,9 @@ func NewTupleDataLayout(types []common.LType, aggrObjs []*AggrObject, childrenOu
 	//data columns + children output columns
 	for _, lType := range append(layout._types, layout._childrenOutputTypes...) {
 		layout._offsets = append(layout._offsets, layout._rowWidth)
-		if lType.GetInternalType().IsConstant() ||
-			lType.GetInternalType().IsVarchar() {
-			layout._rowWidth += lType.GetInternalType().Size()
+		pTyp := rowPhyType(lType)
+		if pTyp.IsConstant() || pTyp.IsVarchar() {
+			layout._rowWidth += pTyp.Size()
 		} else {
 			//for variable length types, pointer to the actual data
 			layout._rowWidth += common.Int64Size
@@ -970,6 +1373,17 @@ func (tuple *TupleDataCollection) Count() int {
 	return int(tuple._count)
 }
 
+func (tuple *TupleDataCollection) SizeInBytes() int {
+	return tuple._alloc.SizeInBytes()
+}
+
+// Close releases the memory of the tuples
+func (tuple *TupleDataCollection) Close() {
+	tuple._alloc.Close()
+	tuple._segments = nil
+	tuple._count = 0
+}
+
 func (tuple *TupleDataCollection) Append(
 	pinState *TupleDataPinState,
 	chunkState *TupleDataChunkState,
@@ -1085,16 +1499,73 @@ func (tuple *TupleDataCollection) scatterVector(
 }
 
 // convert chunk into state.data unified format
-func toUnifiedFormat(state *TupleDataChunkState, chunk *chunk.Chunk) {
+func toUnifiedFormat(state *TupleDataChunkState, data *chunk.Chunk) {
 	for i, colId := range state._columnIds {
-		chunk.Data[i].ToUnifiedFormat(chunk.Card(), &state._data[colId])
+		rowVector(data.Data[i], data.Card()).ToUnifiedFormat(data.Card(), &state._data[colId])
 	}
 }
 
-func toUnifiedFormatForChildrenOutput(state *TupleDataChunkState, chunk *chunk.Chunk) {
+func toUnifiedFormatForChildrenOutput(state *TupleDataChunkState, data *chunk.Chunk) {
 	for i, colIdx := range state._childrenOutputIds {
-		chunk.Data[i].ToUnifiedFormat(chunk.Card(), &state._data[colIdx])
+		rowVector(data.Data[i], data.Card()).ToUnifiedFormat(data.Card(), &state._data[colIdx])
+	}
+}
+
+// rowPhyType returns the physical type of the column in the row.
+// the nested values are stored as the encoded VARCHAR.
+func rowPhyType(typ common.LType) common.PhyType {
+	if typ.IsNested() {
+		return common.VARCHAR
+	}
+	return typ.GetInternalType()
+}
+
+// rowVector returns the vector that is scattered into the row.
+func rowVector(vec *chunk.Vector, cnt int) *chunk.Vector {
+	if vec.Typ().IsNested() {
+		return chunk.EncodeVector(vec, cnt)
+	}
+	return vec
+}
+
+// rowTypes returns the types of the columns in the row.
+func rowTypes(types []common.LType) []common.LType {
+	ret := make([]common.LType, len(types))
+	for i, typ := range types {
+		ret[i] = typ
+		if typ.IsNested() {
+			ret[i] = common.VarcharType()
+		}
+	}
+	return ret
+}
+
+func hasNestedType(types []common.LType) bool {
+	for _, typ := range types {
+		if typ.IsNested() {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeNestedChunk replaces the nested vectors with the encoded ones.
+func encodeNestedChunk(data *chunk.Chunk) {
+	for i, vec := range data.Data {
+		data.Data[i] = rowVector(vec, data.Card())
+	}
+}
+
+// decodeNestedChunk decodes the encoded chunk into the result.
+func decodeNestedChunk(encoded, result *chunk.Chunk) {
+	for i, vec := range encoded.Data {
+		if result.Data[i].Typ().IsNested() {
+			chunk.DecodeVector(vec, result.Data[i], encoded.Card())
+		} else {
+			result.Data[i].Reference(vec)
+		}
 	}
+	result.SetCard(encoded.Card())
 }
 
 // result refers to chunk state.data unified format
@@ -1138,7 +1609,7 @@ func (tuple *TupleDataCollection) evaluateHeapSizes(
 	appendSel *chunk.SelectVector,
 	cnt int) {
 	//only care varchar type
-	pTyp := src.Typ().GetInternalType()
+	pTyp := rowPhyType(src.Typ())
 	switch pTyp {
 	case common.VARCHAR:
 	default:
@@ -1214,7 +1685,7 @@ func TupleDataTemplatedScatterSwitch(
 	rowLocations *chunk.Vector,
 	heapLocations *chunk.Vector,
 	colIdx int) {
-	pTyp := src.Typ().GetInternalType()
+	pTyp := rowPhyType(src.Typ())
 	switch pTyp {
 	case common.INT32:
 		TupleDataTemplatedScatter[int32](
@@ -1293,6 +1764,17 @@ func TupleDataTemplatedScatterSwitch(
 			colIdx,
 			chunk.DateScatterOp{},
 		)
+	case common.INTERVAL:
+		TupleDataTemplatedScatter[common.Interval](
+			srcFormat,
+			appendSel,
+			cnt,
+			layout,
+			rowLocations,
+			heapLocations,
+			colIdx,
+			chunk.IntervalScatterOp{},
+		)
 	case common.DOUBLE:
 		TupleDataTemplatedScatter[float64](
 			srcFormat,
@@ -1371,6 +1853,16 @@ func TupleDataTemplatedGatherSwitch(
 	target *chunk.Vector,
 	targetSel *chunk.SelectVector,
 ) {
+	if target.Typ().IsNested() {
+		encoded := chunk.NewFlatVector(common.VarcharType(), util.DefaultVectorSize)
+		TupleDataTemplatedGatherSwitch(layout, rowLocs, colIdx, scanSel, scanCnt, encoded, targetSel)
+		target.SetPhyFormat(chunk.PF_FLAT)
+		for i := 0; i < scanCnt; i++ {
+			idx := targetSel.GetIndex(i)
+			target.SetValue(idx, chunk.DecodeString(target.Typ(), encoded.GetValue(idx)))
+		}
+		return
+	}
 	pTyp := target.Typ().GetInternalType()
 	switch pTyp {
 	case common.INT32:
@@ -1423,6 +1915,16 @@ func TupleDataTemplatedGatherSwitch(
 			target,
 			targetSel,
 		)
+	case common.INTERVAL:
+		TupleDataTemplatedGather[common.Interval](
+			layout,
+			rowLocs,
+			colIdx,
+			scanSel,
+			scanCnt,
+			target,
+			targetSel,
+		)
 	case common.INT128:
 		TupleDataTemplatedGather[common.Hugeint](
 			layout,
@@ -0,0 +1,152 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_graceHashJoin(t *testing.T) {
+	integer := common.IntegerType()
+	types := []common.LType{integer, integer}
+	//[key, payload]. -1 is NULL
+	newChunks := func(rows [][2]int64) []*chunk.Chunk {
+		ret := make([]*chunk.Chunk, 0)
+		for start := 0; start < len(rows); start += util.DefaultVectorSize {
+			end := min(start+util.DefaultVectorSize, len(rows))
+			data := &chunk.Chunk{}
+			data.Init(types, util.DefaultVectorSize)
+			for i, row := range rows[start:end] {
+				for j, v := range row {
+					data.Data[j].SetValue(i, &chunk.Value{Typ: integer, I64: v, IsNull: v < 0})
+				}
+			}
+			data.SetCard(end - start)
+			ret = append(ret, data)
+		}
+		return ret
+	}
+	col := func(src SourceType, idx int) *Expr {
+		return &Expr{Typ: ET_Column, DataTyp: integer, ColRef: ColumnBind{uint64(src), uint64(idx)}}
+	}
+	newJoin := func(joinTyp LOT_JoinType, memoryLimit int) *HashJoin {
+		op := &PhysicalOperator{
+			Typ:     POT_Join,
+			JoinTyp: joinTyp,
+			OnConds: []*Expr{{
+				Typ:      ET_Func,
+				SubTyp:   ET_Equal,
+				DataTyp:  common.BooleanType(),
+				FunImpl:  &FunctionV2{},
+				Children: []*Expr{col(LeftChild, 0), col(RightChild, 0)},
+			}},
+			Children: []*PhysicalOperator{
+				{Outputs: []*Expr{col(ThisNode, 0), col(ThisNode, 1)}},
+				{Outputs: []*Expr{col(ThisNode, 0), col(ThisNode, 1)}},
+			},
+		}
+		hj := NewHashJoin(op, op.OnConds)
+		hj._memoryLimit = memoryLimit
+		return hj
+	}
+	rowsOf := func(data *chunk.Chunk, rows []string) []string {
+		for i := 0; i < data.Card(); i++ {
+			vals := make([]string, 0)
+			for _, vec := range data.Data {
+				vals = append(vals, vec.GetValue(i).String())
+			}
+			rows = append(rows, strings.Join(vals, "|"))
+		}
+		return rows
+	}
+	runJoin := func(hj *HashJoin, build, probe [][2]int64) []string {
+		defer hj.Close()
+		for _, data := range newChunks(build) {
+			require.NoError(t, hj.Build(data))
+		}
+		require.NoError(t, hj.Finalize())
+		rows := make([]string, 0)
+		for _, data := range newChunks(probe) {
+			for {
+				next := &chunk.Chunk{}
+				next.Init(hj._scanNextTyps, util.DefaultVectorSize)
+				res, err := hj.Execute(data, next)
+				require.NoError(t, err)
+				rows = rowsOf(next, rows)
+				if res != haveMoreOutput {
+					break
+				}
+			}
+		}
+		for {
+			next := &chunk.Chunk{}
+			next.Init(hj._scanNextTyps, util.DefaultVectorSize)
+			res, err := hj.ExecutePartitions(next)
+			require.NoError(t, err)
+			rows = rowsOf(next, rows)
+			if res != haveMoreOutput {
+				break
+			}
+		}
+		sort.Strings(rows)
+		return rows
+	}
+
+	//the build keys are sparse. many partitions have no build rows
+	build := make([][2]int64, 0)
+	for i := int64(0); i < 3000; i++ {
+		build = append(build, [2]int64{(i % 50) * 97, i})
+	}
+	build = append(build, [2]int64{-1, 1})
+	probe := make([][2]int64, 0)
+	for i := int64(0); i < 10000; i++ {
+		key := i % 7000
+		if i%100 == 0 {
+			key = -1
+		}
+		probe = append(probe, [2]int64{key, i})
+	}
+
+	joinTypes := []LOT_JoinType{LOT_JoinTypeInner, LOT_JoinTypeLeft, LOT_JoinTypeANTI, LOT_JoinTypeSEMI}
+	for _, joinTyp := range joinTypes {
+		for _, buildRows := range [][][2]int64{build, build[:0]} {
+			t.Run(fmt.Sprintf("%v build %d", joinTyp, len(buildRows)), func(t *testing.T) {
+				expect := runJoin(newJoin(joinTyp, 0), buildRows, probe)
+
+				//the hash table exceeds the limit at once and
+				//the partitions are partitioned again recursively
+				hj := newJoin(joinTyp, 1)
+				for _, data := range newChunks(buildRows) {
+					require.NoError(t, hj.Build(data))
+				}
+				assert.Equal(t, len(buildRows) != 0, hj.spilled())
+				hj.Close()
+
+				assert.Equal(t, expect, runJoin(newJoin(joinTyp, 1), buildRows, probe))
+				assert.Equal(t, expect, runJoin(newJoin(joinTyp, 64<<10), buildRows, probe))
+			})
+		}
+	}
+}
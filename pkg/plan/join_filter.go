@@ -0,0 +1,425 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+/*
+Runtime join filters.
+
+The inner and the semi hash join drop the rows of the probe side
+whose keys are not on the build side. The join collects the min/max
+and the bloom filter of the keys on the build side. After the build,
+they are handed to the table scan on the probe side:
+
+	min/max: the row groups and the vectors are skipped by the zone maps.
+	bloom filter: the rows are filtered before they reach the join.
+
+The filter is planned on the equal key that is the column of the table
+scan on the probe side. The column is traced through the filter,
+the project and the left side of the join.
+
+The build of the join finishes before the scan on the probe side starts
+in both the pulled and the pushed execution.
+*/
+
+const (
+	bloomBitsPerKey = 16
+	bloomHashes     = 3
+	//the bloom filter is not built on the more keys
+	maxBloomKeys = 1 << 22
+)
+
+// joinFilter is the runtime filter on one equal key of the hash join
+type joinFilter struct {
+	//the key in the on conditions
+	keyIdx int
+	//the table scan on the probe side and the column in the scan chunk
+	scan   *PhysicalOperator
+	colIdx int
+
+	//the states below are set by the build
+	ready    bool
+	min, max *chunk.Value
+	//the hashes of the keys. nil if there are too many keys
+	hashes  []uint64
+	noBloom bool
+	bloom   *bloomFilter
+}
+
+func (filter *joinFilter) String() string {
+	return fmt.Sprintf("key %d -> scan %d col %d", filter.keyIdx, filter.scan.Id, filter.colIdx)
+}
+
+func (filter *joinFilter) reset() {
+	filter.ready = false
+	filter.min = nil
+	filter.max = nil
+	filter.hashes = nil
+	filter.noBloom = false
+	filter.bloom = nil
+}
+
+// planJoinFilters plans the runtime filters of the hash join
+// on the table scans of the probe side.
+func planJoinFilters(join *PhysicalOperator) []*joinFilter {
+	if join.JoinTyp != LOT_JoinTypeInner && join.JoinTyp != LOT_JoinTypeSEMI {
+		return nil
+	}
+	var filters []*joinFilter
+	for i, cond := range join.OnConds {
+		if cond.Typ != ET_Func || cond.SubTyp != ET_Equal {
+			continue
+		}
+		key := cond.Children[0]
+		if key.Typ != ET_Column ||
+			SourceType(key.ColRef.table()) != LeftChild ||
+			!key.DataTyp.Equal(cond.Children[1].DataTyp) {
+			continue
+		}
+		scan, colIdx := traceScanColumn(join.Children[0], int(key.ColRef.column()))
+		if scan == nil {
+			continue
+		}
+		filter := &joinFilter{
+			keyIdx: i,
+			scan:   scan,
+			colIdx: colIdx,
+		}
+		scan.runtimeFilters = append(scan.runtimeFilters, filter)
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// traceScanColumn finds the table scan and the column in the scan chunk
+// that the output of the op at the pos comes from.
+func traceScanColumn(op *PhysicalOperator, pos int) (*PhysicalOperator, int) {
+	out := op.Outputs[pos]
+	if out.Typ != ET_Column {
+		return nil, 0
+	}
+	src := SourceType(out.ColRef.table())
+	col := int(out.ColRef.column())
+	switch op.Typ {
+	case POT_Scan:
+		if op.ScanTyp != ScanTypeTable {
+			return nil, 0
+		}
+		return op, col
+	case POT_Filter, POT_Join, POT_RangeJoin:
+		if src != LeftChild {
+			return nil, 0
+		}
+		return traceScanColumn(op.Children[0], col)
+	case POT_Project:
+		if src == ThisNode {
+			proj := op.Projects[col]
+			if proj.Typ != ET_Column || SourceType(proj.ColRef.table()) != LeftChild {
+				return nil, 0
+			}
+			col = int(proj.ColRef.column())
+		} else if src != LeftChild {
+			return nil, 0
+		}
+		return traceScanColumn(op.Children[0], col)
+	default:
+		return nil, 0
+	}
+}
+
+// update collects the keys of the build side
+func (filter *joinFilter) update(keys *chunk.Vector, count int) {
+	if count == 0 {
+		return
+	}
+	var kdata chunk.UnifiedFormat
+	keys.ToUnifiedFormat(count, &kdata)
+	filter.updateMinMax(keys.Typ(), &kdata, count)
+	if filter.noBloom {
+		return
+	}
+	if len(filter.hashes)+count > maxBloomKeys {
+		filter.noBloom = true
+		filter.hashes = nil
+		return
+	}
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	chunk.HashTypeSwitch(keys, hashes, nil, count, false)
+	var hdata chunk.UnifiedFormat
+	hashes.ToUnifiedFormat(count, &hdata)
+	hashSlice := chunk.GetSliceInPhyFormatUnifiedFormat[uint64](&hdata)
+	for i := 0; i < count; i++ {
+		if !kdata.Mask.RowIsValid(uint64(kdata.Sel.GetIndex(i))) {
+			continue
+		}
+		filter.hashes = append(filter.hashes, hashSlice[hdata.Sel.GetIndex(i)])
+	}
+}
+
+func (filter *joinFilter) updateMinMax(typ common.LType, kdata *chunk.UnifiedFormat, count int) {
+	var lo, hi *chunk.Value
+	switch typ.GetInternalType() {
+	case common.INT32:
+		lo, hi = minMaxOf(kdata, count,
+			func(a, b int32) bool { return a < b },
+			func(v int32) *chunk.Value {
+				return &chunk.Value{Typ: typ, I64: int64(v)}
+			})
+	case common.INT64:
+		lo, hi = minMaxOf(kdata, count,
+			func(a, b int64) bool { return a < b },
+			func(v int64) *chunk.Value {
+				return &chunk.Value{Typ: typ, I64: v}
+			})
+	case common.UINT64:
+		lo, hi = minMaxOf(kdata, count,
+			func(a, b uint64) bool { return a < b },
+			func(v uint64) *chunk.Value {
+				return &chunk.Value{Typ: typ, U64: v}
+			})
+	case common.DATE:
+		lo, hi = minMaxOf(kdata, count,
+			func(a, b common.Date) bool { return a.Less(&b) },
+			func(v common.Date) *chunk.Value {
+				return &chunk.Value{Typ: typ, I64: int64(v.Year), I64_1: int64(v.Month), I64_2: int64(v.Day)}
+			})
+	default:
+		return
+	}
+	if lo == nil {
+		return
+	}
+	if filter.min == nil || compareKeyValue(lo, filter.min) < 0 {
+		filter.min = lo
+	}
+	if filter.max == nil || compareKeyValue(hi, filter.max) > 0 {
+		filter.max = hi
+	}
+}
+
+// minMaxOf returns the min/max of the valid keys. nil if there is no valid key.
+func minMaxOf[T any](
+	kdata *chunk.UnifiedFormat,
+	count int,
+	less func(a, b T) bool,
+	toValue func(T) *chunk.Value) (*chunk.Value, *chunk.Value) {
+	keySlice := chunk.GetSliceInPhyFormatUnifiedFormat[T](kdata)
+	var lo, hi T
+	has := false
+	for i := 0; i < count; i++ {
+		idx := kdata.Sel.GetIndex(i)
+		if !kdata.Mask.RowIsValid(uint64(idx)) {
+			continue
+		}
+		val := keySlice[idx]
+		if !has {
+			lo, hi, has = val, val, true
+			continue
+		}
+		if less(val, lo) {
+			lo = val
+		}
+		if less(hi, val) {
+			hi = val
+		}
+	}
+	if !has {
+		return nil, nil
+	}
+	return toValue(lo), toValue(hi)
+}
+
+// compareKeyValue compares the min/max values of the keys
+func compareKeyValue(a, b *chunk.Value) int {
+	switch a.Typ.GetInternalType() {
+	case common.UINT64:
+		return cmpOrdered(a.U64, b.U64)
+	case common.DATE:
+		for _, pair := range [][2]int64{{a.I64, b.I64}, {a.I64_1, b.I64_1}, {a.I64_2, b.I64_2}} {
+			if ret := cmpOrdered(pair[0], pair[1]); ret != 0 {
+				return ret
+			}
+		}
+		return 0
+	default:
+		return cmpOrdered(a.I64, b.I64)
+	}
+}
+
+func cmpOrdered[T int64 | uint64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// finish builds the bloom filter after the build
+func (filter *joinFilter) finish() {
+	if !filter.noBloom {
+		filter.bloom = newBloomFilter(filter.hashes)
+	}
+	filter.hashes = nil
+	filter.ready = true
+}
+
+// tableFilters returns the min/max of the keys as the filters of the zone maps
+func (filter *joinFilter) tableFilters() []*storage.ConstantFilter {
+	if filter.min == nil {
+		return nil
+	}
+	return []*storage.ConstantFilter{
+		{Cmp: storage.CompareGreaterEqual, Constant: filter.min},
+		{Cmp: storage.CompareLessEqual, Constant: filter.max},
+	}
+}
+
+// selectRows selects the rows of the scan chunk that may have the keys.
+// It returns the count of the selected rows. All rows are selected
+// without the bloom filter and the sel is untouched.
+func (filter *joinFilter) selectRows(data *chunk.Chunk, sel *chunk.SelectVector) int {
+	count := data.Card()
+	if filter.bloom == nil {
+		return count
+	}
+	hashes := chunk.NewFlatVector(common.HashType(), util.DefaultVectorSize)
+	chunk.HashTypeSwitch(data.Data[filter.colIdx], hashes, nil, count, false)
+	var hdata chunk.UnifiedFormat
+	hashes.ToUnifiedFormat(count, &hdata)
+	hashSlice := chunk.GetSliceInPhyFormatUnifiedFormat[uint64](&hdata)
+	selCount := 0
+	for i := 0; i < count; i++ {
+		if filter.bloom.mayContain(hashSlice[hdata.Sel.GetIndex(i)]) {
+			sel.SetIndex(selCount, i)
+			selCount++
+		}
+	}
+	return selCount
+}
+
+// bloomFilter is the bloom filter on the hashes of the keys
+type bloomFilter struct {
+	bits []uint64
+	mask uint64
+}
+
+func newBloomFilter(hashes []uint64) *bloomFilter {
+	n := max(len(hashes)*bloomBitsPerKey, 512)
+	//power of two
+	size := 1 << bits.Len(uint(n-1))
+	bf := &bloomFilter{
+		bits: make([]uint64, size/64),
+		mask: uint64(size - 1),
+	}
+	for _, h := range hashes {
+		bf.insert(h)
+	}
+	return bf
+}
+
+func (bf *bloomFilter) insert(h uint64) {
+	delta := bits.RotateLeft64(h, 32) | 1
+	for i := 0; i < bloomHashes; i++ {
+		pos := h & bf.mask
+		bf.bits[pos>>6] |= 1 << (pos & 63)
+		h += delta
+	}
+}
+
+func (bf *bloomFilter) mayContain(h uint64) bool {
+	delta := bits.RotateLeft64(h, 32) | 1
+	for i := 0; i < bloomHashes; i++ {
+		pos := h & bf.mask
+		if bf.bits[pos>>6]&(1<<(pos&63)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+// updateJoinFilters collects the keys of the build side of the hash join
+func (run *Runner) updateJoinFilters(keys *chunk.Chunk) {
+	for _, filter := range run.op.joinFilters {
+		filter.update(keys.Data[filter.keyIdx], keys.Card())
+	}
+}
+
+// finishJoinFilters hands the runtime filters to the scans on the probe side
+func (run *Runner) finishJoinFilters() {
+	for _, filter := range run.op.joinFilters {
+		filter.finish()
+	}
+}
+
+// scanFilters returns the filters of the table scan with the min/max
+// of the ready runtime filters. The ready runtime filters are kept
+// for the rows.
+func (run *Runner) scanFilters() *storage.TableFilterSet {
+	run.joinFilters = nil
+	for _, filter := range run.op.runtimeFilters {
+		if filter.ready {
+			run.joinFilters = append(run.joinFilters, filter)
+		}
+	}
+	if len(run.joinFilters) == 0 {
+		return run.tableFilters
+	}
+	set := storage.NewTableFilterSet()
+	if run.tableFilters != nil {
+		for colIdx, filters := range run.tableFilters.Filters {
+			for _, filter := range filters {
+				set.PushFilter(colIdx, filter)
+			}
+		}
+	}
+	for _, filter := range run.joinFilters {
+		for _, tabFilter := range filter.tableFilters() {
+			set.PushFilter(storage.IdxType(filter.colIdx), tabFilter)
+		}
+	}
+	if set.Empty() {
+		return nil
+	}
+	return set
+}
+
+// runJoinFilters filters the rows of the scan chunk by the runtime filters
+func (run *Runner) runJoinFilters(data *chunk.Chunk) {
+	if len(run.joinFilters) == 0 {
+		return
+	}
+	for _, filter := range run.joinFilters {
+		sel := chunk.NewSelectVector(util.DefaultVectorSize)
+		count := filter.selectRows(data, sel)
+		if count == data.Card() {
+			continue
+		}
+		data.SliceItself(sel, count)
+		if count == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_joinFilter(t *testing.T) {
+	integer := common.IntegerType()
+	newChunk := func(vals ...int64) *chunk.Chunk {
+		data := &chunk.Chunk{}
+		data.Init([]common.LType{integer}, util.DefaultVectorSize)
+		for i, v := range vals {
+			if v < 0 {
+				data.Data[0].SetValue(i, &chunk.Value{Typ: integer, IsNull: true})
+			} else {
+				data.Data[0].SetValue(i, &chunk.Value{Typ: integer, I64: v})
+			}
+		}
+		data.SetCard(len(vals))
+		return data
+	}
+
+	filter := &joinFilter{}
+	//the build side. -1 is NULL
+	keys := newChunk(30, 10, -1, 20)
+	filter.update(keys.Data[0], keys.Card())
+	keys = newChunk(40, -1)
+	filter.update(keys.Data[0], keys.Card())
+	filter.finish()
+	require.True(t, filter.ready)
+	assert.Equal(t, int64(10), filter.min.I64)
+	assert.Equal(t, int64(40), filter.max.I64)
+
+	tabFilters := filter.tableFilters()
+	require.Len(t, tabFilters, 2)
+	assert.Equal(t, storage.CompareGreaterEqual, tabFilters[0].Cmp)
+	assert.Equal(t, storage.CompareLessEqual, tabFilters[1].Cmp)
+
+	//the probe side. no false negative
+	probe := newChunk(10, 20, 30, 40)
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	assert.Equal(t, 4, filter.selectRows(probe, sel))
+
+	vals := make([]int64, 0)
+	for i := int64(1000); i < 2000; i++ {
+		vals = append(vals, i)
+	}
+	probe = newChunk(vals...)
+	assert.Less(t, filter.selectRows(probe, sel), 50)
+
+	//empty build side
+	filter = &joinFilter{}
+	filter.finish()
+	assert.Nil(t, filter.tableFilters())
+	assert.Equal(t, 0, filter.selectRows(newChunk(1, 2, 3), sel))
+}
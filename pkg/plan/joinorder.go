@@ -516,7 +516,7 @@ func (joinOrder *JoinOrderOptimizer) Optimize(root *LogicalOperator) (*LogicalOp
 					joinOrder.createEdge(filter.Children[0], child, info)
 				}
 			case ET_SubFunc:
-			case ET_And, ET_Or, ET_Equal, ET_NotEqual, ET_Like, ET_GreaterEqual, ET_Less, ET_Greater:
+			case ET_And, ET_Or, ET_Equal, ET_NotEqual, ET_Like, ET_GreaterEqual, ET_Less, ET_LessEqual, ET_Greater:
 				joinOrder.createEdge(filter.Children[0], filter.Children[1], info)
 			default:
 				panic(fmt.Sprintf("usp %v", filter.SubTyp))
@@ -1282,7 +1282,7 @@ func getTableRefers(root *LogicalOperator, set UnorderedSet) {
 		for _, child := range root.Children {
 			getTableRefers(child, set)
 		}
-	case LOT_Project:
+	case LOT_Project, LOT_Unnest:
 		set.insert(root.Index)
 		collectTableRefersOfExprs(root.Projects, set)
 		getTableRefers(root.Children[0], set)
@@ -0,0 +1,292 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/daviszhen/plan/pkg/parser"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+// maxMacroDepth limits the nested expansion of the macros
+const maxMacroDepth = 64
+
+// buildCreateMacro binds the CREATE MACRO.
+// The parser rewrites it into the CREATE FUNCTION in the language macro or table_macro.
+func (b *Builder) buildCreateMacro(
+	txn *storage.Txn,
+	stmt *pg_query.CreateFunctionStmt,
+	ctx *BindContext,
+	depth int) (*LogicalOperator, error) {
+	lang := ""
+	body := ""
+	for _, opt := range stmt.GetOptions() {
+		def := opt.GetDefElem()
+		switch def.GetDefname() {
+		case "language":
+			lang = def.GetArg().GetString_().GetSval()
+		case "as":
+			items := def.GetArg().GetList().GetItems()
+			if len(items) == 1 {
+				body = items[0].GetString_().GetSval()
+			}
+		}
+	}
+	if lang != "macro" && lang != "table_macro" {
+		return nil, fmt.Errorf("usp function language %s", lang)
+	}
+	if stmt.GetReplace() {
+		return nil, fmt.Errorf("usp CREATE OR REPLACE MACRO")
+	}
+	schema, name := splitMacroName(stmt.GetFuncname())
+	if schema == "pg_catalog" {
+		return nil, fmt.Errorf("can not create macro %s in schema %s", name, schema)
+	}
+
+	params := make([]string, 0)
+	for _, node := range stmt.GetParameters() {
+		param := node.GetFunctionParameter()
+		pname := param.GetName()
+		if pname == "" {
+			names := param.GetArgType().GetNames()
+			if len(names) == 1 {
+				pname = names[0].GetString_().GetSval()
+			}
+		}
+		if pname == "" || param.GetDefexpr() != nil {
+			return nil, fmt.Errorf("invalid parameter of the macro %s", name)
+		}
+		if slices.Contains(params, pname) {
+			return nil, fmt.Errorf("duplicate parameter %s of the macro %s", pname, name)
+		}
+		params = append(params, pname)
+	}
+
+	tableMacro := lang == "table_macro"
+	ast, err := parseMacroBody(name, body, tableMacro)
+	if err != nil {
+		return nil, err
+	}
+	if tableMacro {
+		if _, has := tableFuncFormats[name]; has || name == "generate_series" || name == "range" {
+			return nil, fmt.Errorf("table function %s already exists", name)
+		}
+	} else {
+		funcLock.RLock()
+		_, isScalar := scalarFuncs[name]
+		_, isAggr := aggrFuncs[name]
+		funcLock.RUnlock()
+		if isScalar || isAggr {
+			return nil, fmt.Errorf("function %s already exists", name)
+		}
+	}
+
+	return &LogicalOperator{
+		Typ:      LOT_CreateMacro,
+		Database: schema,
+		Table:    name,
+		Macro: storage.NewMacroInfo(
+			schema,
+			name,
+			params,
+			body,
+			tableMacro,
+			macroDepends(txn, ast),
+		),
+	}, nil
+}
+
+func splitMacroName(funcNames []*pg_query.Node) (string, string) {
+	schema := "public"
+	if len(funcNames) > 1 {
+		schema = funcNames[len(funcNames)-2].GetString_().GetSval()
+	}
+	return schema, funcNames[len(funcNames)-1].GetString_().GetSval()
+}
+
+// parseMacroBody returns the expression of the scalar macro
+// or the select statement of the table macro.
+func parseMacroBody(name, body string, tableMacro bool) (*pg_query.Node, error) {
+	if tableMacro {
+		stmts, err := parser.Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		if len(stmts) != 1 || stmts[0].GetStmt().GetSelectStmt() == nil {
+			return nil, fmt.Errorf("the body of the table macro %s must be a query", name)
+		}
+		return stmts[0].GetStmt(), nil
+	}
+	stmts, err := parser.Parse("SELECT " + body)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("the body of the macro %s must be an expression", name)
+	}
+	sel := stmts[0].GetStmt().GetSelectStmt()
+	if len(sel.GetTargetList()) != 1 || len(sel.GetFromClause()) != 0 ||
+		sel.GetWhereClause() != nil || len(sel.GetGroupClause()) != 0 {
+		return nil, fmt.Errorf("the body of the macro %s must be an expression", name)
+	}
+	return sel.GetTargetList()[0].GetResTarget().GetVal(), nil
+}
+
+// macroDepends collects the existing tables and macros used in the body
+func macroDepends(txn *storage.Txn, ast *pg_query.Node) []*storage.CatalogEntry {
+	ret := make([]*storage.CatalogEntry, 0)
+	add := func(ent *storage.CatalogEntry) {
+		if ent != nil && !slices.Contains(ret, ent) {
+			ret = append(ret, ent)
+		}
+	}
+	walkNodes(ast, func(node *pg_query.Node) bool {
+		switch impl := node.GetNode().(type) {
+		case *pg_query.Node_RangeVar:
+			schema := impl.RangeVar.GetSchemaname()
+			if schema == "" {
+				schema = "public"
+			}
+			add(storage.GCatalog.GetEntry(txn, storage.CatalogTypeTable, schema, impl.RangeVar.GetRelname()))
+		case *pg_query.Node_FuncCall:
+			schema, name := splitMacroName(impl.FuncCall.GetFuncname())
+			add(storage.GCatalog.GetEntry(txn, storage.CatalogTypeMacro, schema, name))
+		}
+		return true
+	})
+	return ret
+}
+
+// walkNodes visits the nodes of the ast in the preorder.
+// The children of the node are skipped if the fun returns false.
+func walkNodes(ast proto.Message, fun func(node *pg_query.Node) bool) {
+	var visit func(msg protoreflect.Message)
+	visit = func(msg protoreflect.Message) {
+		if node, ok := msg.Interface().(*pg_query.Node); ok && !fun(node) {
+			return
+		}
+		msg.Range(func(fd protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+			switch {
+			case fd.Message() == nil || fd.IsMap():
+			case fd.IsList():
+				list := val.List()
+				for i := 0; i < list.Len(); i++ {
+					visit(list.Get(i).Message())
+				}
+			default:
+				visit(val.Message())
+			}
+			return true
+		})
+	}
+	visit(ast.ProtoReflect())
+}
+
+// substituteParams replaces the parameters in the ast by the arguments
+func substituteParams(ast proto.Message, params []string, args []*pg_query.Node) {
+	walkNodes(ast, func(node *pg_query.Node) bool {
+		fields := node.GetColumnRef().GetFields()
+		if len(fields) != 1 {
+			return true
+		}
+		idx := slices.Index(params, fields[0].GetString_().GetSval())
+		if idx < 0 {
+			return true
+		}
+		node.Node = proto.Clone(args[idx]).(*pg_query.Node).Node
+		return false
+	})
+}
+
+// lookupMacro returns the macro entry of the function. nil if it is not a macro.
+func (b *Builder) lookupMacro(funcNames []*pg_query.Node, tableMacro bool) *storage.CatalogEntry {
+	if b.txn == nil || len(funcNames) == 0 {
+		return nil
+	}
+	schema, name := splitMacroName(funcNames)
+	if schema == "pg_catalog" {
+		return nil
+	}
+	ent := storage.GCatalog.GetEntry(b.txn, storage.CatalogTypeMacro, schema, name)
+	if ent == nil || ent.IsTableMacro() != tableMacro {
+		return nil
+	}
+	return ent
+}
+
+// expandMacro returns the body of the macro with the arguments
+func (b *Builder) expandMacro(macro *storage.CatalogEntry, args []*pg_query.Node) (*pg_query.Node, error) {
+	params := macro.GetMacroParams()
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("macro %s requires %d arguments, but got %d", macro.GetName(), len(params), len(args))
+	}
+	for _, arg := range args {
+		if arg.GetNamedArgExpr() != nil {
+			return nil, fmt.Errorf("usp named argument of the macro %s", macro.GetName())
+		}
+	}
+	if b.macroDepth >= maxMacroDepth {
+		return nil, fmt.Errorf("max expansion depth of the macro %s", macro.GetName())
+	}
+	ast, err := parseMacroBody(macro.GetName(), macro.GetMacroBody(), macro.IsTableMacro())
+	if err != nil {
+		return nil, err
+	}
+	substituteParams(ast, params, args)
+	return ast, nil
+}
+
+// bindMacro binds the scalar macro by binding its body
+func (b *Builder) bindMacro(ctx *BindContext, iwc InWhichClause, macro *storage.CatalogEntry, call *pg_query.FuncCall, depth int) (*Expr, error) {
+	ast, err := b.expandMacro(macro, call.GetArgs())
+	if err != nil {
+		return nil, err
+	}
+	b.macroDepth++
+	defer func() {
+		b.macroDepth--
+	}()
+	return b.bindExpr(ctx, iwc, ast, depth)
+}
+
+// buildTableMacro builds the table macro as the subquery
+func (b *Builder) buildTableMacro(macro *storage.CatalogEntry, rangeFunc *pg_query.RangeFunction, call *pg_query.FuncCall, ctx *BindContext, depth int) (*Expr, error) {
+	ast, err := b.expandMacro(macro, call.GetArgs())
+	if err != nil {
+		return nil, err
+	}
+	alias := rangeFunc.GetAlias()
+	if alias == nil {
+		alias = &pg_query.Alias{Aliasname: macro.GetName()}
+	}
+	b.macroDepth++
+	defer func() {
+		b.macroDepth--
+	}()
+	return b.buildTable(&pg_query.Node{
+		Node: &pg_query.Node_RangeSubselect{
+			RangeSubselect: &pg_query.RangeSubselect{
+				Subquery: ast,
+				Alias:    alias,
+			},
+		},
+	}, ctx, depth)
+}
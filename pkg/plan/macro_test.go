@@ -0,0 +1,63 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/parser"
+)
+
+func Test_substituteParams(t *testing.T) {
+	deparse := func(stmt *pg_query.Node) string {
+		sql, err := pg_query.Deparse(&pg_query.ParseResult{
+			Stmts: []*pg_query.RawStmt{{Stmt: stmt}},
+		})
+		require.NoError(t, err)
+		return sql
+	}
+	stmts, err := parser.Parse("SELECT x + 1, 'b'")
+	require.NoError(t, err)
+	targets := stmts[0].Stmt.GetSelectStmt().GetTargetList()
+	args := []*pg_query.Node{
+		targets[0].GetResTarget().GetVal(),
+		targets[1].GetResTarget().GetVal(),
+	}
+
+	//the argument is not substituted again
+	ast, err := parseMacroBody("f", "a * (b || a) + t.a", false)
+	require.NoError(t, err)
+	substituteParams(ast, []string{"a", "x"}, args)
+	stmts, err = parser.Parse("SELECT 1")
+	require.NoError(t, err)
+	stmts[0].Stmt.GetSelectStmt().GetTargetList()[0].GetResTarget().Val = ast
+	assert.Equal(t, "SELECT ((x + 1) * (b || (x + 1))) + t.a", deparse(stmts[0].Stmt))
+
+	ast, err = parseMacroBody("t", "select a from t1 where b = a and exists (select 1 from t2 where c = b)", true)
+	require.NoError(t, err)
+	substituteParams(ast, []string{"a", "b"}, args)
+	assert.Equal(t,
+		"SELECT x + 1 FROM t1 WHERE 'b' = (x + 1) AND EXISTS (SELECT 1 FROM t2 WHERE c = 'b')",
+		deparse(ast))
+
+	_, err = parseMacroBody("f", "1, 2", false)
+	assert.Error(t, err)
+	_, err = parseMacroBody("t", "insert into t values (1)", true)
+	assert.Error(t, err)
+}
@@ -172,6 +172,20 @@ func TemplatedMatchOp(
 				noMatchSel,
 				equalDateOp{},
 			)
+		case common.INTERVAL:
+			TemplatedMatchType[common.Interval](
+				col,
+				rows,
+				layout._rowWidth,
+				sel,
+				cnt,
+				colOffset,
+				colNo,
+				noMatch,
+				noMatchCnt,
+				noMatchSel,
+				equalIntervalOp{},
+			)
 		case common.DECIMAL:
 			TemplatedMatchType[common.Decimal](
 				col,
@@ -0,0 +1,199 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+)
+
+// operatorDef is the execution of the physical operator.
+//
+// The Runner pulls the chunks by the init, the exec and the close.
+//
+// The pipeline pushes the chunks by the roles of the operator.
+// The operator without any role runs as the pulled source
+// on its whole subtree.
+type operatorDef struct {
+	init  func(run *Runner) error
+	exec  func(run *Runner, output *chunk.Chunk, state *OperatorState) (OperatorResult, error)
+	close func(run *Runner) error
+
+	//the Source role. it produces the chunks at the start of the pipeline
+	getData func(run *Runner, output *chunk.Chunk) (SourceResult, error)
+	//the Operator role. it transforms the chunks in the pipeline
+	execute func(run *Runner, input, output *chunk.Chunk) (OperatorResult, error)
+	//it produces the rest of the output after the last chunk. it is optional
+	flush func(run *Runner, output *chunk.Chunk) (OperatorResult, error)
+	//the Sink role. it consumes the chunks at the end of the pipeline
+	sink     func(run *Runner, input *chunk.Chunk) (SinkResult, error)
+	finalize func(run *Runner) error
+	//it merges the thread-local sink state of the worker into the operator
+	//before the finalize. it is optional. the workers of the parallel
+	//pipeline sink into their own local states without the lock.
+	combine func(run *Runner, local *Runner) error
+	//the operator takes the roles only if it is true. nil denotes always.
+	pushable func(op *PhysicalOperator) bool
+}
+
+var operatorDefs = make(map[POT]*operatorDef)
+
+// registerOperator adds the execution of the physical operator.
+// The init, the exec and the close are required.
+func registerOperator(typ POT, def *operatorDef) {
+	if def.init == nil || def.exec == nil || def.close == nil {
+		panic(fmt.Sprintf("operator %s requires init, exec and close", typ))
+	}
+	if (def.sink == nil) != (def.finalize == nil) {
+		panic(fmt.Sprintf("operator %s requires both sink and finalize", typ))
+	}
+	if def.combine != nil && def.sink == nil {
+		panic(fmt.Sprintf("operator %s requires sink for combine", typ))
+	}
+	operatorDefs[typ] = def
+}
+
+func (run *Runner) def() *operatorDef {
+	return getOperatorDef(run.op)
+}
+
+func getOperatorDef(op *PhysicalOperator) *operatorDef {
+	def, has := operatorDefs[op.Typ]
+	if !has {
+		panic("usp")
+	}
+	return def
+}
+
+// isPushable returns true if the operator has any role in the pipeline
+func (def *operatorDef) isPushable(op *PhysicalOperator) bool {
+	if def.getData == nil && def.execute == nil && def.sink == nil {
+		return false
+	}
+	if (def.execute != nil || def.sink != nil) && len(op.Children) == 0 {
+		return false
+	}
+	return def.pushable == nil || def.pushable(op)
+}
+
+func init() {
+	registerOperator(POT_Scan, &operatorDef{
+		init:    (*Runner).scanInit,
+		exec:    (*Runner).scanExec,
+		close:   (*Runner).scanClose,
+		getData: (*Runner).scanGetData,
+	})
+	registerOperator(POT_Project, &operatorDef{
+		init:    (*Runner).projInit,
+		exec:    (*Runner).projExec,
+		close:   (*Runner).projClose,
+		execute: (*Runner).projExecute,
+	})
+	registerOperator(POT_Filter, &operatorDef{
+		init:    (*Runner).filterInit,
+		exec:    (*Runner).filterExec,
+		close:   (*Runner).filterClose,
+		execute: (*Runner).filterExecute,
+	})
+	//the hash join probes in the pipeline of the left child.
+	//it is the sink of the pipeline of the right child.
+	registerOperator(POT_Join, &operatorDef{
+		init:     (*Runner).joinInit,
+		exec:     (*Runner).joinExec,
+		close:    (*Runner).joinClose,
+		execute:  (*Runner).joinExecute,
+		flush:    (*Runner).joinFlush,
+		sink:     (*Runner).joinSink,
+		finalize: (*Runner).joinFinalize,
+		combine:  (*Runner).joinCombine,
+		pushable: func(op *PhysicalOperator) bool {
+			//the cross product is pulled
+			return len(op.OnConds) != 0
+		},
+	})
+	//the range join materializes the left child in the pipeline and
+	//joins after the last chunk.
+	//it is the sink of the pipeline of the right child.
+	registerOperator(POT_RangeJoin, &operatorDef{
+		init:     (*Runner).rangeJoinInit,
+		exec:     (*Runner).rangeJoinExec,
+		close:    (*Runner).rangeJoinClose,
+		execute:  (*Runner).rangeJoinExecute,
+		flush:    (*Runner).rangeJoinFlush,
+		sink:     (*Runner).rangeJoinSink,
+		finalize: (*Runner).rangeJoinFinalize,
+	})
+	registerOperator(POT_Agg, &operatorDef{
+		init:     (*Runner).aggrInit,
+		exec:     (*Runner).aggrExec,
+		close:    (*Runner).aggrClose,
+		getData:  (*Runner).aggrGetData,
+		sink:     (*Runner).aggrSink,
+		finalize: (*Runner).aggrFinalize,
+		combine:  (*Runner).aggrCombine,
+	})
+	registerOperator(POT_Order, &operatorDef{
+		init:     (*Runner).orderInit,
+		exec:     (*Runner).orderExec,
+		close:    (*Runner).orderClose,
+		getData:  (*Runner).orderGetData,
+		sink:     (*Runner).orderSink,
+		finalize: (*Runner).orderFinalize,
+	})
+	registerOperator(POT_Limit, &operatorDef{
+		init:     (*Runner).limitInit,
+		exec:     (*Runner).limitExec,
+		close:    (*Runner).limitClose,
+		getData:  (*Runner).limitGetData,
+		sink:     (*Runner).limitSink,
+		finalize: (*Runner).limitFinalize,
+	})
+	registerOperator(POT_Unnest, &operatorDef{
+		init:  (*Runner).unnestInit,
+		exec:  (*Runner).unnestExec,
+		close: (*Runner).unnestClose,
+	})
+	registerOperator(POT_Stub, &operatorDef{
+		init:  (*Runner).stubInit,
+		exec:  (*Runner).stubExec,
+		close: (*Runner).stubClose,
+	})
+	registerOperator(POT_CreateSchema, &operatorDef{
+		init:  (*Runner).createSchemaInit,
+		exec:  (*Runner).createSchemaExec,
+		close: (*Runner).createSchemaClose,
+	})
+	registerOperator(POT_CreateTable, &operatorDef{
+		init:  (*Runner).createTableInit,
+		exec:  (*Runner).createTableExec,
+		close: (*Runner).createTableClose,
+	})
+	registerOperator(POT_CreateMacro, &operatorDef{
+		init:  (*Runner).createMacroInit,
+		exec:  (*Runner).createMacroExec,
+		close: (*Runner).createMacroClose,
+	})
+	registerOperator(POT_Set, &operatorDef{
+		init:  (*Runner).setInit,
+		exec:  (*Runner).setExec,
+		close: (*Runner).setClose,
+	})
+	registerOperator(POT_Insert, &operatorDef{
+		init:  (*Runner).insertInit,
+		exec:  (*Runner).insertExec,
+		close: (*Runner).insertClose,
+	})
+}
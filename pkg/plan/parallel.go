@@ -0,0 +1,243 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+	"sync"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+/*
+Morsel-driven parallelism.
+
+The pipeline on the table scan runs in the workers.
+Each worker has its own runners of the source and the operators
+as the thread-local state. The pipeline state is the global state
+shared by the workers: the morsels of the table and the hash tables
+of the joins. The morsel is a row group of the table.
+
+The hash tables are built by the pipelines before. The hash aggregate
+and the build of the hash join have the thread-local sink states.
+Each worker sinks into its own local state without the lock. The local
+states are combined into the sink after the workers are done, then
+the sink is finalized. The workers push the results into the other
+sinks under the lock.
+*/
+
+// pipelineState is the global state of the pipeline
+type pipelineState struct {
+	scan *storage.ParallelTableScanState
+	//the hash tables of the joins in the pipeline
+	hts map[*PhysicalOperator]*JoinHashTable
+}
+
+// sharedHT returns the hash table of the join that the workers probe
+func (pstate *pipelineState) sharedHT(op *PhysicalOperator) (*JoinHashTable, bool) {
+	if pstate == nil {
+		return nil, false
+	}
+	ht, has := pstate.hts[op]
+	return ht, has
+}
+
+func (run *Runner) threads() int {
+	if run.cfg == nil || run.cfg.Exec.Threads < 1 {
+		return 1
+	}
+	return run.cfg.Exec.Threads
+}
+
+// parallel returns true if the pipeline runs in the workers
+func (exec *PipelineExecutor) parallel(pipe *Pipeline) bool {
+	if exec.root.threads() < 2 {
+		return false
+	}
+	if _, ok := pipe.source.(runnerSource); !ok {
+		return false
+	}
+	for i, op := range pipe.ops {
+		//the partitions of the join are not shared
+		if op.Typ == POT_Join && pipe.operators[i].(runnerOperator).run.hjoin.spilled() {
+			return false
+		}
+		//the range join materializes the input in itself
+		if op.Typ == POT_RangeJoin {
+			return false
+		}
+	}
+	op := pipe.sourceOp
+	return op.Typ == POT_Scan && op.ScanTyp == ScanTypeTable &&
+		(op.Sample == nil || op.Sample.Method != SampleReservoir)
+}
+
+// executeParallel runs the pipeline in the workers until it is finished
+func (exec *PipelineExecutor) executeParallel(ctx context.Context, pipe *Pipeline) error {
+	pstate := &pipelineState{
+		scan: &storage.ParallelTableScanState{},
+		hts:  make(map[*PhysicalOperator]*JoinHashTable),
+	}
+	for i, op := range pipe.ops {
+		if op.Typ == POT_Join {
+			pstate.hts[op] = pipe.operators[i].(runnerOperator).run.hjoin._ht
+		}
+	}
+	tabEnt := pipe.source.(runnerSource).run.tabEnt
+	tabEnt.GetStorage().InitParallelScan(exec.root.Txn, pstate.scan)
+
+	type worker struct {
+		source    Source
+		operators []Operator
+		//the local sink state. nil if the sink has no local state.
+		local *Runner
+	}
+	sinkRun, hasLocal := exec.localSink(pipe)
+	workers := make([]worker, 0)
+	for i := 0; i < exec.root.threads(); i++ {
+		run, err := exec.newRunner(pipe.sourceOp, pstate)
+		if err != nil {
+			return err
+		}
+		w := worker{source: runnerSource{run: run}}
+		if hasLocal {
+			w.local, err = exec.newRunner(sinkRun.op, pstate)
+			if err != nil {
+				return err
+			}
+		}
+		for _, op := range pipe.ops {
+			run, err = exec.newRunner(op, pstate)
+			if err != nil {
+				return err
+			}
+			w.operators = append(w.operators, runnerOperator{run: run})
+		}
+		workers = append(workers, w)
+	}
+
+	var (
+		lock     sync.Mutex
+		sinkDone bool
+		firstErr error
+	)
+	setErr := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		sinkDone = true
+	}
+	stopped := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return sinkDone
+	}
+	sink := func(input *chunk.Chunk) (SinkResult, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if sinkDone {
+			return SinkResDone, nil
+		}
+		res, err := pipe.sink.Sink(input)
+		if res == SinkResDone {
+			sinkDone = true
+		}
+		return res, err
+	}
+	run := func(w worker) (err error) {
+		defer func() {
+			if rErr := recover(); rErr != nil {
+				err = recoverError(rErr)
+			}
+		}()
+		workerSink := sink
+		if w.local != nil {
+			workerSink = runnerSink{run: w.local}.Sink
+		}
+		for !stopped() {
+			err = contextError(ctx)
+			if err != nil {
+				return err
+			}
+			output := &chunk.Chunk{}
+			output.SetCap(util.DefaultVectorSize)
+			res, err := w.source.GetData(output)
+			if err != nil {
+				return err
+			}
+			sres, err := pipe.push(w.operators, workerSink, output, 0)
+			if err != nil {
+				return err
+			}
+			if res == SrcResDone || sres == SinkResDone {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+			err := run(w)
+			if err != nil {
+				setErr(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if hasLocal {
+		for _, w := range workers {
+			err := sinkRun.def().combine(sinkRun, w.local)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return pipe.finish()
+}
+
+// localSink returns the runner of the sink if the sink
+// has the thread-local states in the workers.
+func (exec *PipelineExecutor) localSink(pipe *Pipeline) (*Runner, bool) {
+	sink, ok := pipe.sink.(runnerSink)
+	if !ok || sink.run.def().combine == nil {
+		return nil, false
+	}
+	return sink.run, true
+}
+
+// readMorsels scans the morsels assigned to the worker
+func (run *Runner) readMorsels(output *chunk.Chunk) {
+	table := run.tabEnt.GetStorage()
+	for {
+		table.Scan(run.Txn, output, run.state.tableScanState)
+		if output.Card() > 0 {
+			return
+		}
+		if !table.NextParallelScan(run.pipe.scan, run.state.tableScanState) {
+			return
+		}
+	}
+}
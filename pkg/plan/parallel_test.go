@@ -0,0 +1,199 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/parser"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_buildSet(t *testing.T) {
+	build := func(sql string) (*LogicalOperator, error) {
+		stmts, err := parser.Parse(sql)
+		require.NoError(t, err)
+		return NewBuilder(nil).buildDDL(nil, stmts[0], nil, 0)
+	}
+	lp, err := build("SET threads = 4")
+	require.NoError(t, err)
+	assert.Equal(t, LOT_Set, lp.Typ)
+	assert.Equal(t, "threads = 4", lp.Setting.String())
+
+	cfg := &util.Config{}
+	run := &Runner{cfg: cfg, op: &PhysicalOperator{Typ: POT_Set, Setting: lp.Setting}}
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.Exec.Threads)
+	assert.Equal(t, 4, run.threads())
+
+	_, err = build("SET threads = 0")
+	assert.ErrorContains(t, err, "positive integer")
+	_, err = build("SET threads = 'many'")
+	assert.ErrorContains(t, err, "positive integer")
+	_, err = build("SET foo = 1")
+	assert.ErrorContains(t, err, "unrecognized configuration parameter")
+	_, err = build("RESET threads")
+	assert.Error(t, err)
+
+	lp, err = build("SET aggregate_memory_limit = '64MB'")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(64<<20), cfg.Exec.AggrMemoryLimit)
+	assert.Equal(t, 64<<20, run.aggrMemoryLimit())
+	_, err = build("SET aggregate_memory_limit = '64XB'")
+	assert.ErrorContains(t, err, "invalid memory size")
+
+	lp, err = build("SET sort_memory_limit = '1GB'")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1<<30, run.sortMemoryLimit())
+
+	lp, err = build("SET join_memory_limit = '256MB'")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 256<<20, run.joinMemoryLimit())
+
+	lp, err = build("SET memory_limit = '4GB'")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	defer storage.GBufferMgr.SetMemoryLimit(0)
+	assert.Equal(t, int64(4<<30), storage.GBufferMgr.MemoryLimit())
+
+	lp, err = build("SET statement_timeout = '2s'")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000), run.cfg.Exec.StatementTimeout)
+
+	lp, err = build("SET statement_timeout = 500")
+	require.NoError(t, err)
+	run.op.Setting = lp.Setting
+	_, err = run.setExec(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), run.cfg.Exec.StatementTimeout)
+
+	_, err = build("SET statement_timeout = 'soon'")
+	assert.Error(t, err)
+}
+
+// runPipelineSQL runs the sql by the pipelines.
+// It returns the sorted rows and the local sink states of the typ in the workers.
+func runPipelineSQL(t *testing.T, txn *storage.Txn, cfg *util.Config, sql string, typ POT) ([]string, int) {
+	run, err := InitRunner(cfg, txn, sql)
+	require.NoError(t, err)
+	defer run.Close()
+	rows := make([]string, 0)
+	err = run.executor.Execute(context.Background(), func(output *chunk.Chunk) error {
+		for i := 0; i < output.Card(); i++ {
+			vals := make([]string, 0)
+			for _, vec := range output.Data {
+				vals = append(vals, vec.GetValue(i).String())
+			}
+			rows = append(rows, strings.Join(vals, "|"))
+		}
+		return nil
+	})
+	require.NoError(t, err, sql)
+	cnt := 0
+	for _, r := range run.executor.runners {
+		//the probe of the join in the worker is not the sink
+		if r.op.Typ == typ && r.pipe != nil &&
+			(r.hjoin == nil || r.hjoin._hjs != HJS_PROBE) {
+			cnt++
+		}
+	}
+	sort.Strings(rows)
+	return rows, cnt
+}
+
+// useTempDatabase replaces the catalog and the storage with
+// the new database in the temporary directory during the test
+func useTempDatabase(t *testing.T) {
+	oldCatalog, oldStorage := storage.GCatalog, storage.GStorageMgr
+	t.Cleanup(func() {
+		storage.GCatalog, storage.GStorageMgr = oldCatalog, oldStorage
+	})
+	storage.GCatalog = storage.NewCatalog()
+	require.NoError(t, storage.GCatalog.Init())
+	storage.GStorageMgr = storage.NewStorageMgr(filepath.Join(t.TempDir(), "parallel"), false)
+	require.NoError(t, storage.GStorageMgr.LoadDatabase())
+}
+
+func Test_parallelLocalSink(t *testing.T) {
+	//the join order needs the rows of the committed tables
+	useTempDatabase(t)
+	cfg := &util.Config{}
+	//the rows are in 3 row groups
+	for _, sql := range []string{
+		"create table parallel_t (a int, b bigint)",
+		"insert into parallel_t select i % 1000, i from generate_series(1, 300000) g(i)",
+		"create table parallel_u (c int, d bigint)",
+		"insert into parallel_u select i % 1000, i from generate_series(1, 300000) g(i)",
+	} {
+		txn, err := storage.GTxnMgr.NewTxn("parallel")
+		require.NoError(t, err)
+		_, _ = runPipelineSQL(t, txn, cfg, sql, POT_Insert)
+		require.NoError(t, storage.GTxnMgr.Commit(txn))
+	}
+	txn, err := storage.GTxnMgr.NewTxn("parallel")
+	require.NoError(t, err)
+	defer storage.GTxnMgr.Rollback(txn)
+
+	tests := []struct {
+		sql string
+		typ POT
+	}{
+		{"select a % 100, count(*), sum(a), min(b), max(b) from parallel_t group by a % 100", POT_Agg},
+		{"select count(*), sum(a), min(b), max(b) from parallel_t", POT_Agg},
+		{"select a % 10, count(distinct a) from parallel_t group by a % 10", POT_Agg},
+		{"select a, count(*) from parallel_t where b > 1000 group by a", POT_Agg},
+		{"select count(*), sum(parallel_t.a) from parallel_t, parallel_u where parallel_t.b = parallel_u.d", POT_Join},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int64{0, 1 << 20} {
+			cfg.Exec.AggrMemoryLimit = limit
+			cfg.Exec.JoinMemoryLimit = limit
+			cfg.Exec.Threads = 1
+			want, _ := runPipelineSQL(t, txn, cfg, tt.sql, tt.typ)
+			cfg.Exec.Threads = 4
+			got, locals := runPipelineSQL(t, txn, cfg, tt.sql, tt.typ)
+			assert.Equal(t, want, got, tt.sql)
+			assert.Equal(t, 4, locals, tt.sql)
+		}
+	}
+	rows, _ := runPipelineSQL(t, txn, cfg, tests[1].sql, POT_Agg)
+	assert.Equal(t, []string{"300000|149850000|1|300000"}, rows)
+	rows, _ = runPipelineSQL(t, txn, cfg, tests[len(tests)-1].sql, POT_Join)
+	assert.Equal(t, []string{"300000|149850000"}, rows)
+}
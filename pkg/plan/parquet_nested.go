@@ -0,0 +1,379 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	pqReader "github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+// pqNode is the node of the parquet schema tree.
+type pqNode struct {
+	path     string
+	repeated bool
+	//max definition level of the node
+	defLevel int32
+	//max repetition level of the node
+	repLevel int32
+	children []*pqNode
+	//index of the leaf in the column
+	leafIdx int
+}
+
+// pqColumn holds the leaves of a top-level column.
+type pqColumn struct {
+	root   *pqNode
+	leaves []*pqNode
+	values [][]any
+	rls    [][]int32
+	dls    [][]int32
+	//cursor of every leaf
+	pos []int
+}
+
+// buildPqColumns splits the parquet schema into the top-level columns.
+func buildPqColumns(sh *schema.SchemaHandler) []*pqColumn {
+	elems := sh.SchemaElements
+	idx := 1
+	var build func(parent *pqNode, col *pqColumn) *pqNode
+	build = func(parent *pqNode, col *pqColumn) *pqNode {
+		elem := elems[idx]
+		node := &pqNode{
+			path:     sh.IndexMap[int32(idx)],
+			defLevel: parent.defLevel,
+			repLevel: parent.repLevel,
+			leafIdx:  -1,
+		}
+		idx++
+		switch elem.GetRepetitionType() {
+		case parquet.FieldRepetitionType_OPTIONAL:
+			node.defLevel++
+		case parquet.FieldRepetitionType_REPEATED:
+			node.defLevel++
+			node.repLevel++
+			node.repeated = true
+		}
+		if elem.GetNumChildren() == 0 {
+			node.leafIdx = len(col.leaves)
+			col.leaves = append(col.leaves, node)
+			return node
+		}
+		for i := int32(0); i < elem.GetNumChildren(); i++ {
+			node.children = append(node.children, build(node, col))
+		}
+		return node
+	}
+	cols := make([]*pqColumn, 0)
+	root := &pqNode{}
+	for idx < len(elems) {
+		col := &pqColumn{}
+		col.root = build(root, col)
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// read reads the leaves of the column.
+func (col *pqColumn) read(reader *pqReader.ParquetReader, maxCnt int) error {
+	col.values = make([][]any, len(col.leaves))
+	col.rls = make([][]int32, len(col.leaves))
+	col.dls = make([][]int32, len(col.leaves))
+	col.pos = make([]int, len(col.leaves))
+	for i, leaf := range col.leaves {
+		var err error
+		col.values[i], col.rls[i], col.dls[i], err = reader.ReadColumnByPath(leaf.path, int64(maxCnt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (col *pqColumn) done() bool {
+	return col.pos[0] >= len(col.dls[0])
+}
+
+func firstLeaf(node *pqNode) *pqNode {
+	for node.leafIdx < 0 {
+		node = node.children[0]
+	}
+	return node
+}
+
+// skip consumes one entry of every leaf under the node
+func (col *pqColumn) skip(node *pqNode) {
+	if node.leafIdx >= 0 {
+		col.pos[node.leafIdx]++
+		return
+	}
+	for _, child := range node.children {
+		col.skip(child)
+	}
+}
+
+// assemble rebuilds the value of the node from the leaves
+func (col *pqColumn) assemble(node *pqNode, typ common.LType) (*chunk.Value, error) {
+	leaf := firstLeaf(node)
+	pos := col.pos[leaf.leafIdx]
+	if pos >= len(col.dls[leaf.leafIdx]) {
+		return nil, fmt.Errorf("parquet column %s is truncated", node.path)
+	}
+	dl := col.dls[leaf.leafIdx][pos]
+	if !node.repeated && dl < node.defLevel {
+		col.skip(node)
+		return &chunk.Value{Typ: typ, IsNull: true}, nil
+	}
+	switch typ.Id {
+	case common.LTID_LIST, common.LTID_MAP:
+		if len(node.children) != 1 || !node.children[0].repeated {
+			return nil, fmt.Errorf("parquet column %s is not a list", node.path)
+		}
+		rep := node.children[0]
+		elem := rep
+		if typ.Id == common.LTID_LIST && len(rep.children) == 1 {
+			elem = rep.children[0]
+		}
+		if dl < rep.defLevel {
+			//empty list
+			col.skip(node)
+			ret := chunk.NewListValue(typ.ListChild(), nil)
+			ret.Typ = typ
+			return ret, nil
+		}
+		elems := make([]*chunk.Value, 0)
+		for {
+			val, err := col.assemble(elem, typ.ListChild())
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, val)
+			next := col.pos[leaf.leafIdx]
+			if next >= len(col.rls[leaf.leafIdx]) || col.rls[leaf.leafIdx][next] != rep.repLevel {
+				break
+			}
+		}
+		ret := chunk.NewListValue(typ.ListChild(), elems)
+		ret.Typ = typ
+		return ret, nil
+	case common.LTID_STRUCT:
+		if len(node.children) != len(typ.Children) {
+			return nil, fmt.Errorf("parquet column %s has %d fields. expect %d",
+				node.path, len(node.children), len(typ.Children))
+		}
+		fields := make([]*chunk.Value, len(node.children))
+		for i, child := range node.children {
+			val, err := col.assemble(child, typ.Children[i])
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = val
+		}
+		return chunk.NewStructValue(typ, fields), nil
+	default:
+		if node.leafIdx < 0 {
+			return nil, fmt.Errorf("parquet column %s is not a primitive", node.path)
+		}
+		col.pos[leaf.leafIdx]++
+		if dl < node.defLevel {
+			return &chunk.Value{Typ: typ, IsNull: true}, nil
+		}
+		return parquetColToValue(col.values[leaf.leafIdx][pos], typ)
+	}
+}
+
+// readParquetNested reads the parquet file with the nested columns.
+// the columns of the file are the top-level fields.
+func (run *Runner) readParquetNested(output *chunk.Chunk, maxCnt int) error {
+	if run.pqColumns == nil {
+		run.pqColumns = buildPqColumns(run.pqReader.SchemaHandler)
+	}
+	rowCont := -1
+	for j, idx := range run.colIndice {
+		if idx >= len(run.pqColumns) {
+			return fmt.Errorf("no column %d in parquet file", idx)
+		}
+		col := run.pqColumns[idx]
+		err := col.read(run.pqReader, maxCnt)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		vec := output.Data[j]
+		cnt := 0
+		for !col.done() {
+			val, err := col.assemble(col.root, vec.Typ())
+			if err != nil {
+				return err
+			}
+			vec.SetValue(cnt, val)
+			cnt++
+		}
+		if rowCont < 0 {
+			rowCont = cnt
+		} else if cnt != rowCont {
+			return fmt.Errorf("column %d has different count of values %d with previous columns %d", idx, cnt, rowCont)
+		}
+	}
+	output.SetCard(max(rowCont, 0))
+	return nil
+}
+
+// pqHasGroup returns true if any column of the file is nested.
+// the leaves are not the top-level columns then.
+func pqHasGroup(sh *schema.SchemaHandler) bool {
+	for _, elem := range sh.SchemaElements[1:] {
+		if elem.GetNumChildren() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pqSchema converts the top-level columns of the parquet file into the types.
+func pqSchema(sh *schema.SchemaHandler) ([]string, []common.LType, error) {
+	elems := sh.SchemaElements
+	idx := 1
+	convertedType := func(elem *parquet.SchemaElement) parquet.ConvertedType {
+		if !elem.IsSetConvertedType() {
+			return -1
+		}
+		return elem.GetConvertedType()
+	}
+	//repeated is true for the element of the list
+	var convert func(repeated bool) (common.LType, error)
+	convert = func(repeated bool) (common.LType, error) {
+		elem := elems[idx]
+		idx++
+		if !repeated && elem.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+			return common.LType{}, fmt.Errorf("usp repeated parquet column %s", elem.GetName())
+		}
+		if elem.GetNumChildren() == 0 {
+			return pqPrimitiveType(elem, convertedType(elem))
+		}
+		switch convertedType(elem) {
+		case parquet.ConvertedType_LIST:
+			if elems[idx].GetNumChildren() == 1 {
+				//the repeated group wraps the element
+				idx++
+			}
+			child, err := convert(true)
+			if err != nil {
+				return common.LType{}, err
+			}
+			return common.ListType(child), nil
+		case parquet.ConvertedType_MAP, parquet.ConvertedType_MAP_KEY_VALUE:
+			if elems[idx].GetNumChildren() != 2 {
+				return common.LType{}, fmt.Errorf("parquet column %s is not a map", elem.GetName())
+			}
+			idx++
+			key, err := convert(false)
+			if err != nil {
+				return common.LType{}, err
+			}
+			value, err := convert(false)
+			if err != nil {
+				return common.LType{}, err
+			}
+			return common.MapType(key, value), nil
+		default:
+			names := make([]string, 0)
+			typs := make([]common.LType, 0)
+			for i := int32(0); i < elem.GetNumChildren(); i++ {
+				names = append(names, elems[idx].GetName())
+				typ, err := convert(false)
+				if err != nil {
+					return common.LType{}, err
+				}
+				typs = append(typs, typ)
+			}
+			return common.StructType(names, typs), nil
+		}
+	}
+	names := make([]string, 0)
+	typs := make([]common.LType, 0)
+	for idx < len(elems) {
+		names = append(names, elems[idx].GetName())
+		typ, err := convert(false)
+		if err != nil {
+			return nil, nil, err
+		}
+		typs = append(typs, typ)
+	}
+	return names, typs, nil
+}
+
+func pqPrimitiveType(elem *parquet.SchemaElement, conv parquet.ConvertedType) (common.LType, error) {
+	decimal := conv == parquet.ConvertedType_DECIMAL ||
+		elem.IsSetLogicalType() && elem.GetLogicalType().IsSetDECIMAL()
+	switch elem.GetType() {
+	case parquet.Type_BOOLEAN:
+		return common.BooleanType(), nil
+	case parquet.Type_INT32:
+		switch {
+		case decimal:
+			return common.DecimalType(int(elem.GetPrecision()), int(elem.GetScale())), nil
+		case conv == parquet.ConvertedType_DATE:
+			return common.DateType(), nil
+		case conv == parquet.ConvertedType_TIME_MILLIS:
+			return common.TimeType(), nil
+		}
+		return common.IntegerType(), nil
+	case parquet.Type_INT64:
+		switch {
+		case decimal:
+			return common.DecimalType(int(elem.GetPrecision()), int(elem.GetScale())), nil
+		case conv == parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return common.MakeLType(common.LTID_TIMESTAMP_MS), nil
+		case conv == parquet.ConvertedType_TIMESTAMP_MICROS:
+			return common.TimestampType(), nil
+		case conv == parquet.ConvertedType_TIME_MICROS:
+			return common.TimeType(), nil
+		case elem.IsSetLogicalType() && elem.GetLogicalType().IsSetTIMESTAMP():
+			unit := elem.GetLogicalType().GetTIMESTAMP().GetUnit()
+			switch {
+			case unit.IsSetMILLIS():
+				return common.MakeLType(common.LTID_TIMESTAMP_MS), nil
+			case unit.IsSetNANOS():
+				return common.MakeLType(common.LTID_TIMESTAMP_NS), nil
+			}
+			return common.TimestampType(), nil
+		}
+		return common.BigintType(), nil
+	case parquet.Type_FLOAT:
+		return common.FloatType(), nil
+	case parquet.Type_DOUBLE:
+		return common.DoubleType(), nil
+	case parquet.Type_BYTE_ARRAY:
+		if conv == parquet.ConvertedType_JSON {
+			return common.JSONType(), nil
+		}
+		return common.VarcharType(), nil
+	case parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		if conv == parquet.ConvertedType_INTERVAL {
+			return common.IntervalType(), nil
+		}
+	}
+	return common.LType{}, fmt.Errorf("usp parquet type %v of column %s", elem.GetType(), elem.GetName())
+}
@@ -0,0 +1,408 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+/*
+Push-based execution.
+
+The plan is split into the pipelines at the pipeline breakers.
+The pipeline is the source, the operators on it and the sink.
+The driver pulls the chunk from the source and pushes it through
+the operators into the sink.
+
+	aggregate, order, limit: the sink of the child pipeline and
+	                         the source of the parent pipeline.
+	hash join: the sink of the pipeline of the right child and
+	           the operator in the pipeline of the left child.
+	filter, project: the operator.
+	scan: the source.
+
+The other operators run as the pulled source on the whole subtree.
+
+The pipeline runs after the pipelines it depends on. The executor runs
+the pipelines step by step. It can be paused between the steps
+and be cancelled by the context. The operators check the context
+on every chunk.
+*/
+
+// Source produces the chunks at the start of the pipeline.
+type Source interface {
+	GetData(output *chunk.Chunk) (SourceResult, error)
+}
+
+// Operator transforms the chunk in the pipeline.
+// It returns haveMoreOutput if the input has more output.
+// The driver calls it again with the same input.
+// The Flush is called after the last chunk until it returns
+// no haveMoreOutput.
+type Operator interface {
+	Execute(input, output *chunk.Chunk) (OperatorResult, error)
+	Flush(output *chunk.Chunk) (OperatorResult, error)
+}
+
+// Sink consumes the chunks at the end of the pipeline.
+// The Finalize is called after the last chunk.
+type Sink interface {
+	Sink(input *chunk.Chunk) (SinkResult, error)
+	Finalize() error
+}
+
+// runnerSource is the Source role of the operator
+type runnerSource struct {
+	run *Runner
+}
+
+func (src runnerSource) GetData(output *chunk.Chunk) (SourceResult, error) {
+	err := src.run.checkContext()
+	if err != nil {
+		return SrcResDone, err
+	}
+	output.Init(src.run.outputTypes, util.DefaultVectorSize)
+	return src.run.def().getData(src.run, output)
+}
+
+// pullSource pulls the chunks from the subtree
+type pullSource struct {
+	run *Runner
+}
+
+func (src pullSource) GetData(output *chunk.Chunk) (SourceResult, error) {
+	res, err := src.run.Execute(nil, output, src.run.state)
+	if err != nil {
+		return SrcResDone, err
+	}
+	if res == Done || res == InvalidOpResult {
+		return SrcResDone, nil
+	}
+	return SrcResHaveMoreOutput, nil
+}
+
+// runnerOperator is the Operator role of the operator
+type runnerOperator struct {
+	run *Runner
+}
+
+func (op runnerOperator) Execute(input, output *chunk.Chunk) (OperatorResult, error) {
+	err := op.run.checkContext()
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	output.Init(op.run.outputTypes, util.DefaultVectorSize)
+	return op.run.def().execute(op.run, input, output)
+}
+
+func (op runnerOperator) Flush(output *chunk.Chunk) (OperatorResult, error) {
+	def := op.run.def()
+	if def.flush == nil {
+		return Done, nil
+	}
+	output.Init(op.run.outputTypes, util.DefaultVectorSize)
+	return def.flush(op.run, output)
+}
+
+// runnerSink is the Sink role of the operator
+type runnerSink struct {
+	run *Runner
+}
+
+func (sink runnerSink) Sink(input *chunk.Chunk) (SinkResult, error) {
+	return sink.run.def().sink(sink.run, input)
+}
+
+func (sink runnerSink) Finalize() error {
+	return sink.run.def().finalize(sink.run)
+}
+
+// resultSink hands the results of the query to the executor
+type resultSink struct {
+	exec *PipelineExecutor
+}
+
+func (sink resultSink) Sink(input *chunk.Chunk) (SinkResult, error) {
+	err := sink.exec.result(input)
+	if err != nil {
+		return SinkResDone, err
+	}
+	return SinkResNeedMoreInput, nil
+}
+
+func (sink resultSink) Finalize() error {
+	return nil
+}
+
+type Pipeline struct {
+	exec *PipelineExecutor
+	//the operator of the source
+	sourceOp *PhysicalOperator
+	source   Source
+	//the operators from the source to the sink
+	ops       []*PhysicalOperator
+	operators []Operator
+	sink      Sink
+	//the pipelines run before this
+	deps     []*Pipeline
+	finished bool
+}
+
+// push runs the input through the operators from the idx into the sink.
+func (pipe *Pipeline) push(operators []Operator, sink func(*chunk.Chunk) (SinkResult, error), input *chunk.Chunk, idx int) (SinkResult, error) {
+	if input.Card() == 0 {
+		return SinkResNeedMoreInput, nil
+	}
+	if idx == len(operators) {
+		return sink(input)
+	}
+	for {
+		output := &chunk.Chunk{}
+		res, err := operators[idx].Execute(input, output)
+		if err != nil {
+			return SinkResDone, err
+		}
+		sres, err := pipe.push(operators, sink, output, idx+1)
+		if err != nil || sres == SinkResDone {
+			return SinkResDone, err
+		}
+		if res != haveMoreOutput {
+			return SinkResNeedMoreInput, nil
+		}
+	}
+}
+
+// step pushes one chunk of the source. It returns true if the pipeline is finished.
+func (pipe *Pipeline) step() (bool, error) {
+	output := &chunk.Chunk{}
+	output.SetCap(util.DefaultVectorSize)
+	res, err := pipe.source.GetData(output)
+	if err != nil {
+		return false, err
+	}
+	sres, err := pipe.push(pipe.operators, pipe.sink.Sink, output, 0)
+	if err != nil {
+		return false, err
+	}
+	if res == SrcResDone && sres != SinkResDone {
+		_, err = pipe.flush(pipe.operators, pipe.sink.Sink)
+		if err != nil {
+			return false, err
+		}
+	}
+	if res == SrcResDone || sres == SinkResDone {
+		return true, pipe.finish()
+	}
+	return false, nil
+}
+
+// flush pushes the rest of the output of the operators into the sink.
+func (pipe *Pipeline) flush(operators []Operator, sink func(*chunk.Chunk) (SinkResult, error)) (SinkResult, error) {
+	for idx, op := range operators {
+		for {
+			output := &chunk.Chunk{}
+			res, err := op.Flush(output)
+			if err != nil {
+				return SinkResDone, err
+			}
+			if res != haveMoreOutput {
+				break
+			}
+			sres, err := pipe.push(operators, sink, output, idx+1)
+			if err != nil || sres == SinkResDone {
+				return SinkResDone, err
+			}
+		}
+	}
+	return SinkResNeedMoreInput, nil
+}
+
+func (pipe *Pipeline) finish() error {
+	pipe.finished = true
+	return pipe.sink.Finalize()
+}
+
+// PipelineExecutor runs the pipelines of the query
+type PipelineExecutor struct {
+	root *Runner
+	//in the order of the execution
+	pipelines []*Pipeline
+	current   int
+	//the runners of the operators
+	runners []*Runner
+	result  func(*chunk.Chunk) error
+	//the context of the running query
+	ctx context.Context
+}
+
+// NewPipelineExecutor splits the plan of the runner into the pipelines
+func NewPipelineExecutor(root *Runner) (*PipelineExecutor, error) {
+	exec := &PipelineExecutor{root: root}
+	pipe := &Pipeline{exec: exec}
+	pipe.sink = resultSink{exec: exec}
+	err := exec.build(root.op, pipe)
+	if err != nil {
+		return nil, err
+	}
+	exec.schedule(pipe)
+	return exec, nil
+}
+
+// newRunner inits the operator without its children.
+// The pstate is not nil if the runner is in the worker.
+func (exec *PipelineExecutor) newRunner(op *PhysicalOperator, pstate *pipelineState) (*Runner, error) {
+	run := &Runner{
+		op:    op,
+		Txn:   exec.root.Txn,
+		state: &OperatorState{},
+		cfg:   exec.root.cfg,
+		pipe:  pstate,
+		ctx:   exec.ctx,
+	}
+	run.initOutput()
+	err := run.def().init(run)
+	if err != nil {
+		return nil, err
+	}
+	exec.runners = append(exec.runners, run)
+	return run, nil
+}
+
+// build adds the op into the pipe. The pipe has the sink already.
+func (exec *PipelineExecutor) build(op *PhysicalOperator, pipe *Pipeline) error {
+	def := getOperatorDef(op)
+	if !def.isPushable(op) {
+		run := &Runner{
+			op:    op,
+			Txn:   exec.root.Txn,
+			state: &OperatorState{},
+			cfg:   exec.root.cfg,
+		}
+		err := run.Init()
+		if err != nil {
+			return err
+		}
+		exec.runners = append(exec.runners, run)
+		pipe.sourceOp = op
+		pipe.source = pullSource{run: run}
+		return nil
+	}
+	run, err := exec.newRunner(op, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case def.getData != nil:
+		//the source of the pipe
+		pipe.sourceOp = op
+		pipe.source = runnerSource{run: run}
+		if def.sink != nil {
+			child := &Pipeline{exec: exec, sink: runnerSink{run: run}}
+			pipe.deps = append(pipe.deps, child)
+			return exec.build(op.Children[0], child)
+		}
+		return nil
+	case def.execute != nil:
+		pipe.ops = append([]*PhysicalOperator{op}, pipe.ops...)
+		pipe.operators = append([]Operator{runnerOperator{run: run}}, pipe.operators...)
+		if def.sink != nil {
+			//the right child is built before the probe
+			build := &Pipeline{exec: exec, sink: runnerSink{run: run}}
+			pipe.deps = append(pipe.deps, build)
+			err = exec.build(op.Children[1], build)
+			if err != nil {
+				return err
+			}
+		}
+		return exec.build(op.Children[0], pipe)
+	default:
+		panic("usp")
+	}
+}
+
+// schedule puts the pipeline after its dependencies
+func (exec *PipelineExecutor) schedule(pipe *Pipeline) {
+	for _, dep := range pipe.deps {
+		exec.schedule(dep)
+	}
+	exec.pipelines = append(exec.pipelines, pipe)
+}
+
+// ExecuteTask runs one step of the current pipeline.
+// It returns true if all pipelines are finished.
+// The executor is paused if it is not called.
+func (exec *PipelineExecutor) ExecuteTask(ctx context.Context) (bool, error) {
+	if exec.current >= len(exec.pipelines) {
+		return true, nil
+	}
+	err := contextError(ctx)
+	if err != nil {
+		return false, err
+	}
+	exec.setContext(ctx)
+	pipe := exec.pipelines[exec.current]
+	var finished bool
+	if exec.parallel(pipe) {
+		finished, err = true, exec.executeParallel(ctx, pipe)
+	} else {
+		finished, err = pipe.step()
+	}
+	if err != nil {
+		return false, err
+	}
+	if finished {
+		exec.current++
+	}
+	return exec.current >= len(exec.pipelines), nil
+}
+
+// Execute runs all pipelines. The result receives the results of the query.
+func (exec *PipelineExecutor) Execute(ctx context.Context, result func(*chunk.Chunk) error) error {
+	exec.result = result
+	for {
+		done, err := exec.ExecuteTask(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// setContext passes the context into the operators
+func (exec *PipelineExecutor) setContext(ctx context.Context) {
+	if exec.ctx == ctx {
+		return
+	}
+	exec.ctx = ctx
+	for _, run := range exec.runners {
+		run.setContext(ctx)
+	}
+}
+
+func (exec *PipelineExecutor) Close() error {
+	for _, run := range exec.runners {
+		err := run.Close()
+		if err != nil {
+			return err
+		}
+	}
+	exec.runners = nil
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_PipelineExecutor(t *testing.T) {
+	saved := operatorDefs
+	defer func() {
+		operatorDefs = saved
+	}()
+	operatorDefs = make(map[POT]*operatorDef)
+
+	events := make([]string, 0)
+	name := func(run *Runner) string {
+		if run.op.Typ == POT_Scan {
+			return "scan " + run.op.Table
+		}
+		return run.op.Typ.String()
+	}
+	noop := func(run *Runner) error { return nil }
+	noExec := func(run *Runner, output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+		return Done, nil
+	}
+	//the source emits one row
+	emitted := make(map[*Runner]bool)
+	getData := func(run *Runner, output *chunk.Chunk) (SourceResult, error) {
+		if emitted[run] {
+			return SrcResDone, nil
+		}
+		emitted[run] = true
+		events = append(events, name(run))
+		output.SetCard(1)
+		return SrcResHaveMoreOutput, nil
+	}
+	execute := func(run *Runner, input, output *chunk.Chunk) (OperatorResult, error) {
+		events = append(events, name(run))
+		output.SetCard(input.Card())
+		return NeedMoreInput, nil
+	}
+	//the join emits one row after the last input
+	flushed := false
+	flush := func(run *Runner, output *chunk.Chunk) (OperatorResult, error) {
+		if flushed {
+			return Done, nil
+		}
+		flushed = true
+		events = append(events, name(run)+" flush")
+		output.SetCard(1)
+		return haveMoreOutput, nil
+	}
+	sink := func(run *Runner, input *chunk.Chunk) (SinkResult, error) {
+		events = append(events, name(run)+" sink")
+		return SinkResNeedMoreInput, nil
+	}
+	finalize := func(run *Runner) error {
+		events = append(events, name(run)+" finalize")
+		return nil
+	}
+	registerOperator(POT_Scan, &operatorDef{init: noop, exec: noExec, close: noop, getData: getData})
+	registerOperator(POT_Filter, &operatorDef{init: noop, exec: noExec, close: noop, execute: execute})
+	registerOperator(POT_Join, &operatorDef{init: noop, exec: noExec, close: noop,
+		execute: execute, flush: flush, sink: sink, finalize: finalize})
+	registerOperator(POT_Agg, &operatorDef{init: noop, exec: noExec, close: noop,
+		getData: getData, sink: sink, finalize: finalize})
+
+	left := &PhysicalOperator{Typ: POT_Scan, Table: "l"}
+	right := &PhysicalOperator{Typ: POT_Scan, Table: "r"}
+	join := &PhysicalOperator{Typ: POT_Join, Children: []*PhysicalOperator{left, right}}
+	filter := &PhysicalOperator{Typ: POT_Filter, Children: []*PhysicalOperator{join}}
+	agg := &PhysicalOperator{Typ: POT_Agg, Children: []*PhysicalOperator{filter}}
+
+	run := &Runner{op: agg, cfg: &util.Config{}}
+	exec, err := NewPipelineExecutor(run)
+	require.NoError(t, err)
+	defer exec.Close()
+
+	//the build side, the probe side into the aggregate, the aggregate
+	require.Equal(t, 3, len(exec.pipelines))
+	assert.Equal(t, right, exec.pipelines[0].sourceOp)
+	assert.Equal(t, left, exec.pipelines[1].sourceOp)
+	assert.Equal(t, []*PhysicalOperator{join, filter}, exec.pipelines[1].ops)
+	assert.Equal(t, agg, exec.pipelines[2].sourceOp)
+
+	//one step at a time
+	results := 0
+	exec.result = func(c *chunk.Chunk) error {
+		results += c.Card()
+		return nil
+	}
+	done, err := exec.ExecuteTask(context.Background())
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, []string{"scan r", "join sink"}, events)
+
+	err = exec.Execute(context.Background(), exec.result)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"scan r", "join sink", "join finalize",
+		"scan l", "join", "filter", "agg sink",
+		"join flush", "filter", "agg sink", "agg finalize",
+		"agg",
+	}, events)
+	assert.Equal(t, 1, results)
+
+	//the cancelled query stops before the next step
+	exec, err = NewPipelineExecutor(run)
+	require.NoError(t, err)
+	defer exec.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = exec.Execute(ctx, exec.result)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, ErrQueryCanceled)
+
+	//the operator stops in the pipeline
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	registerOperator(POT_Join, &operatorDef{init: noop, exec: noExec, close: noop,
+		execute: func(run *Runner, input, output *chunk.Chunk) (OperatorResult, error) {
+			cancel()
+			return execute(run, input, output)
+		},
+		flush: flush, sink: sink, finalize: finalize})
+	events = events[:0]
+	exec, err = NewPipelineExecutor(run)
+	require.NoError(t, err)
+	defer exec.Close()
+	err = exec.Execute(ctx, exec.result)
+	assert.ErrorIs(t, err, ErrQueryCanceled)
+	assert.Equal(t, []string{
+		"scan r", "join sink", "join finalize",
+		"scan l", "join",
+	}, events)
+
+	//the statement timed out
+	ctx, cancel = context.WithTimeoutCause(context.Background(), 0, ErrStatementTimeout)
+	defer cancel()
+	exec, err = NewPipelineExecutor(run)
+	require.NoError(t, err)
+	defer exec.Close()
+	err = exec.Execute(ctx, exec.result)
+	assert.ErrorIs(t, err, ErrStatementTimeout)
+}
+
+func Test_cancelFinalize(t *testing.T) {
+	tests := []struct {
+		sql string
+		typ POT
+	}{
+		{"select i from generate_series(1, 100000) as t(i) order by i desc", POT_Order},
+		{"select i % 10, count(*) from generate_series(1, 100000) as t(i) group by i % 10", POT_Agg},
+	}
+	for _, tt := range tests {
+		for _, cause := range []error{nil, ErrStatementTimeout} {
+			run := &Runner{
+				op:    genSelectPhyPlan(t, tt.sql),
+				state: &OperatorState{},
+				cfg:   &util.Config{},
+			}
+			exec, err := NewPipelineExecutor(run)
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancelCause(context.Background())
+			//the query is canceled at the first check in the finalize.
+			//the checks between the chunks are passed already
+			calls := 0
+			for _, r := range exec.runners {
+				if r.op.Typ != tt.typ {
+					continue
+				}
+				check := ctxCheck(func() error {
+					calls++
+					cancel(cause)
+					return r.checkContext()
+				})
+				if tt.typ == POT_Order {
+					r.localSort._ctxCheck = check
+				} else {
+					r.hAggr.SetCtxCheck(check)
+				}
+			}
+			err = exec.Execute(ctx, func(output *chunk.Chunk) error {
+				return nil
+			})
+			if cause == nil {
+				assert.ErrorIs(t, err, ErrQueryCanceled, tt.sql)
+			} else {
+				assert.ErrorIs(t, err, ErrStatementTimeout, tt.sql)
+			}
+			assert.Equal(t, 1, calls, tt.sql)
+			exec.Close()
+			cancel(nil)
+		}
+	}
+}
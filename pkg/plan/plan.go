@@ -121,6 +121,9 @@ const (
 	LOT_CreateSchema LOT = 7
 	LOT_CreateTable  LOT = 8
 	LOT_Insert       LOT = 9
+	LOT_Unnest       LOT = 10
+	LOT_CreateMacro  LOT = 11
+	LOT_Set          LOT = 12
 )
 
 func (lt LOT) String() string {
@@ -145,6 +148,12 @@ func (lt LOT) String() string {
 		return "CreateTable"
 	case LOT_Insert:
 		return "Insert"
+	case LOT_Unnest:
+		return "Unnest"
+	case LOT_CreateMacro:
+		return "CreateMacro"
+	case LOT_Set:
+		return "Set"
 	default:
 		panic(fmt.Sprintf("usp %d", lt))
 	}
@@ -191,6 +200,8 @@ const (
 	ScanTypeTable      ScanType = 0
 	ScanTypeValuesList ScanType = 1
 	ScanTypeCopyFrom   ScanType = 2
+	ScanTypeTableFunc  ScanType = 3
+	ScanTypeSeries     ScanType = 4
 )
 
 func (st ScanType) String() string {
@@ -201,6 +212,10 @@ func (st ScanType) String() string {
 		return "scan values list"
 	case ScanTypeCopyFrom:
 		return "scan copy from"
+	case ScanTypeTableFunc:
+		return "scan table function"
+	case ScanTypeSeries:
+		return "scan series"
 	default:
 		panic("usp")
 	}
@@ -216,8 +231,17 @@ type ScanInfo struct {
 	Names         []string
 	ColumnIds     []int
 	FilePath      string
-	Opts          []*ScanOption
-	Format        string //for CopyFrom
+	//files matched by the glob pattern of the table function
+	FilePaths []string
+	Opts      []*ScanOption
+	Format    string //for CopyFrom and table function
+}
+
+func (info *ScanInfo) files() []string {
+	if len(info.FilePaths) != 0 {
+		return info.FilePaths
+	}
+	return []string{info.FilePath}
 }
 
 type LogicalOperator struct {
@@ -236,6 +260,7 @@ type LogicalOperator struct {
 	OnConds          []*Expr //for innor join
 	Aggs             []*Expr
 	GroupBys         []*Expr
+	GroupingSets     []GroupingSet //for GROUPING SETS, ROLLUP, CUBE
 	OrderBys         []*Expr
 	Limit            *Expr
 	Offset           *Expr
@@ -247,6 +272,8 @@ type LogicalOperator struct {
 	IfNotExists      bool
 	ColDefs          []*storage.ColumnDefinition //for create table
 	Constraints      []*storage.Constraint       //for create table
+	Macro            *storage.MacroInfo          //for create macro
+	Setting          *Setting                    //for set
 	TableEnt         *storage.CatalogEntry       //for insert
 	TableIndex       int                         //for insert
 	ExpectedTypes    []common.LType              //for insert
@@ -259,14 +286,19 @@ type LogicalOperator struct {
 	//column seq no in table -> column seq no in Insert
 	ColumnIndexMap []int //for insert
 	ScanInfo       *ScanInfo
+	Sample         *SampleInfo        //for TABLESAMPLE
 	Counts         ColumnBindCountMap `json:"-"`
 	ColRefToPos    ColumnBindPosMap   `json:"-"`
 }
 
 func (lo *LogicalOperator) EstimatedCard(txn *storage.Txn) uint64 {
 	if lo.Typ == LOT_Scan {
+		if lo.TableEnt == nil {
+			//values list or table function
+			return uint64(lo.Stats.RowCount)
+		}
 		{
-			return lo.TableEnt.GetStats2(0).Count()
+			return lo.Sample.estimatedCard(lo.TableEnt.GetStats2(0).Count())
 		}
 		{
 			//catalogTable, err := tpchCatalog().Table(lo.Database, lo.Table)
@@ -347,6 +379,9 @@ func (lo *LogicalOperator) Print(tree treeprint.Tree) {
 			//}
 			//tree.AddMetaNode("columns", printColumns(catalogTable.Columns))
 		}
+		if lo.Sample != nil {
+			tree.AddMetaNode("sample", lo.Sample.String())
+		}
 		node := tree.AddBranch("filters")
 		listExprsToTree(node, lo.Filters)
 		//printStats := func(columns []string) string {
@@ -384,6 +419,9 @@ func (lo *LogicalOperator) Print(tree treeprint.Tree) {
 			node := tree.AddBranch(fmt.Sprintf("groupExprs, index %d", lo.Index))
 			listExprsToTree(node, lo.GroupBys)
 		}
+		if len(lo.GroupingSets) > 0 {
+			tree.AddMetaNode("groupingSets", groupingSetsString(lo.GroupingSets))
+		}
 		if len(lo.Aggs) > 0 {
 			node := tree.AddBranch(fmt.Sprintf("aggExprs, index %d", lo.Index2))
 			listExprsToTree(node, lo.Aggs)
@@ -401,8 +439,18 @@ func (lo *LogicalOperator) Print(tree treeprint.Tree) {
 	case LOT_Limit:
 		tree = tree.AddBranch(fmt.Sprintf("Limit: %v", lo.Limit.String()))
 		printOutputs(tree, lo)
+	case LOT_Unnest:
+		tree = tree.AddBranch("Unnest:")
+		printOutputs(tree, lo)
+		tree.AddMetaNode("index", fmt.Sprintf("%d", lo.Index))
+		node := tree.AddMetaBranch("exprs", "")
+		listExprsToTree(node, lo.Projects)
 	case LOT_CreateSchema:
 		tree = tree.AddBranch(fmt.Sprintf("CreateSchema: %v %v", lo.Database, lo.IfNotExists))
+	case LOT_CreateMacro:
+		tree = tree.AddBranch(fmt.Sprintf("CreateMacro: %v %v", lo.Database, lo.Table))
+	case LOT_Set:
+		tree = tree.AddBranch(fmt.Sprintf("Set: %v", lo.Setting))
 	case LOT_CreateTable:
 		tree = tree.AddBranch(fmt.Sprintf("CreateTable: %v %v %v",
 			lo.Database, lo.Table, lo.IfNotExists))
@@ -504,6 +552,7 @@ const (
 	ET_Column     ET = iota //column
 	ET_TABLE                //table
 	ET_ValuesList           //for insert
+	ET_TableFunc            //table function in from
 	ET_Join                 //join
 	ET_CTE
 
@@ -557,6 +606,7 @@ const (
 	ET_Cast
 	ET_Extract
 	ET_Substring
+	ET_Grouping
 )
 
 func (et ET_SubTyp) String() string {
@@ -613,6 +663,8 @@ func (et ET_SubTyp) String() string {
 		return "extract"
 	case ET_Substring:
 		return "substring"
+	case ET_Grouping:
+		return "grouping"
 	default:
 		panic(fmt.Sprintf("usp %v", int(et)))
 	}
@@ -690,6 +742,8 @@ type Expr struct {
 	SubTyp  ET_SubTyp
 	DataTyp common.LType
 	AggrTyp AggrType
+	// aggregate with FILTER clause. it is the last child.
+	HasFilter bool
 
 	Children []*Expr
 
@@ -723,6 +777,8 @@ type Expr struct {
 	Values      [][]*Expr
 	ColName2Idx map[string]int
 	TabEnt      *storage.CatalogEntry
+	ScanInfo    *ScanInfo   //for table function
+	Sample      *SampleInfo //for TABLESAMPLE
 }
 
 func (e *Expr) equal(o *Expr) bool {
@@ -735,12 +791,15 @@ func (e *Expr) equal(o *Expr) bool {
 		if e.SubTyp != o.SubTyp {
 			return false
 		}
-		if e.DataTyp != o.DataTyp {
+		if !e.DataTyp.Equal(o.DataTyp) {
 			return false
 		}
 		if e.AggrTyp != o.AggrTyp {
 			return false
 		}
+		if e.HasFilter != o.HasFilter {
+			return false
+		}
 		if e.Index != o.Index {
 			return false
 		}
@@ -821,6 +880,7 @@ func (e *Expr) copy() *Expr {
 		SubTyp:      e.SubTyp,
 		DataTyp:     e.DataTyp,
 		AggrTyp:     e.AggrTyp,
+		HasFilter:   e.HasFilter,
 		Index:       e.Index,
 		Database:    e.Database,
 		Table:       e.Table,
@@ -971,7 +1031,7 @@ func (e *Expr) Format(ctx *FormatCtx) {
 		ctx.Writef("(%v,%s)", e.Fvalue, e.DataTyp)
 	case ET_DecConst:
 		ctx.Writef("(%v,%s)", e.Svalue, e.DataTyp)
-	case ET_TABLE:
+	case ET_TABLE, ET_TableFunc:
 		ctx.Writef("%s.%s", e.Database, e.Table)
 	case ET_Join:
 		e.Children[0].Format(ctx)
@@ -1039,15 +1099,33 @@ func (e *Expr) Format(ctx *FormatCtx) {
 
 		case ET_SubFunc:
 			ctx.Writef("%s(", e.Svalue)
-			for idx, child := range e.Children {
+			args := e.Children
+			if e.HasFilter {
+				args = args[:len(args)-1]
+			}
+			for idx, child := range args {
 				if idx > 0 {
 					ctx.Write(", ")
 				}
 				child.Format(ctx)
 			}
 			ctx.Write(")")
+			if e.HasFilter {
+				ctx.Write(" filter (where ")
+				e.Children[len(e.Children)-1].Format(ctx)
+				ctx.Write(")")
+			}
 			ctx.Write("->")
 			ctx.Writef("%s", e.DataTyp)
+		case ET_Grouping:
+			ctx.Write("grouping(")
+			for idx, child := range e.Children {
+				if idx > 0 {
+					ctx.Write(", ")
+				}
+				child.Format(ctx)
+			}
+			ctx.Write(")")
 		default:
 			//binary operator
 			e.Children[0].Format(ctx)
@@ -1108,7 +1186,7 @@ func (e *Expr) Print(tree treeprint.Tree, meta string) {
 		tree.AddMetaNode(head, fmt.Sprintf("(%v)", e.Fvalue))
 	case ET_DecConst:
 		tree.AddMetaNode(head, fmt.Sprintf("(%s %d %d)", e.Svalue, e.DataTyp.Width, e.DataTyp.Scale))
-	case ET_TABLE:
+	case ET_TABLE, ET_TableFunc:
 		tree.AddNode(fmt.Sprintf("%s.%s", e.Database, e.Table))
 	case ET_Join:
 		typStr := ""
@@ -1154,10 +1232,12 @@ func (e *Expr) Print(tree treeprint.Tree, meta string) {
 		case ET_Exists:
 			branch = tree.AddMetaBranch(head, e.SubTyp)
 			e.Children[0].Print(branch, "")
-		case ET_SubFunc:
+		case ET_SubFunc, ET_Grouping:
 			dist := ""
 			if e.AggrTyp == DISTINCT {
 				dist = "(distinct)"
+			} else if e.HasFilter {
+				dist = "(filter)"
 			}
 			branch = tree.AddMetaBranch(head, fmt.Sprintf("%s %s", e.Svalue, dist))
 			for _, child := range e.Children {
@@ -1202,6 +1282,10 @@ const (
 	POT_CreateSchema POT = 9
 	POT_CreateTable  POT = 10
 	POT_Insert       POT = 11
+	POT_Unnest       POT = 12
+	POT_CreateMacro  POT = 13
+	POT_Set          POT = 14
+	POT_RangeJoin    POT = 15
 )
 
 var potToStr = map[POT]string{
@@ -1217,6 +1301,10 @@ var potToStr = map[POT]string{
 	POT_CreateSchema: "createSchema",
 	POT_CreateTable:  "createTable",
 	POT_Insert:       "insert",
+	POT_Unnest:       "unnest",
+	POT_CreateMacro:  "createMacro",
+	POT_Set:          "set",
+	POT_RangeJoin:    "rangeJoin",
 }
 
 func (t POT) String() string {
@@ -1244,6 +1332,7 @@ type PhysicalOperator struct {
 	Filters       []*Expr
 	Aggs          []*Expr
 	GroupBys      []*Expr
+	GroupingSets  []GroupingSet
 	OnConds       []*Expr
 	OrderBys      []*Expr
 	Limit         *Expr
@@ -1253,6 +1342,8 @@ type PhysicalOperator struct {
 	IfNotExists   bool
 	ColDefs       []*storage.ColumnDefinition //for create table
 	Constraints   []*storage.Constraint       //for create table
+	Macro         *storage.MacroInfo          //for create macro
+	Setting       *Setting                    //for set
 	TableEnt      *storage.CatalogEntry
 	ScanTyp       ScanType
 	Types         []common.LType        //for insert ... values
@@ -1262,6 +1353,10 @@ type PhysicalOperator struct {
 	//column seq no in table -> column seq no in Insert
 	ColumnIndexMap []int //for insert
 	ScanInfo       *ScanInfo
+	series         *seriesInfo   //for generate_series
+	Sample         *SampleInfo   //for TABLESAMPLE
+	joinFilters    []*joinFilter //the runtime filters of the hash join
+	runtimeFilters []*joinFilter //the runtime filters on the table scan
 	Children       []*PhysicalOperator
 	ExecStats      ExecStats
 }
@@ -1308,7 +1403,7 @@ func (po *PhysicalOperator) Print(tree treeprint.Tree) {
 			tableInfo = fmt.Sprintf("%v.%v", po.Database, po.Table)
 		}
 		tree.AddMetaNode("table", tableInfo)
-		if po.ScanTyp == ScanTypeTable {
+		if po.ScanTyp == ScanTypeTable || po.ScanTyp == ScanTypeTableFunc {
 			printColumns := func(cols []string) string {
 				t := strings.Builder{}
 				t.WriteByte('\n')
@@ -1330,6 +1425,9 @@ func (po *PhysicalOperator) Print(tree treeprint.Tree) {
 			}
 		}
 
+		if po.Sample != nil {
+			tree.AddMetaNode("sample", po.Sample.String())
+		}
 		node := tree.AddBranch("filters")
 		listExprsToTree(node, po.Filters)
 		//printStats := func(columns []string) string {
@@ -1357,9 +1455,25 @@ func (po *PhysicalOperator) Print(tree treeprint.Tree) {
 			node := tree.AddMetaBranch("On", "")
 			listExprsToTree(node, po.OnConds)
 		}
+		if len(po.joinFilters) > 0 {
+			node := tree.AddMetaBranch("runtime filters", "")
+			for _, filter := range po.joinFilters {
+				node.AddNode(filter.String())
+			}
+		}
 		//if po.Stats != nil {
 		//	tree.AddMetaNode("Stats", po.Stats.String())
 		//}
+	case POT_RangeJoin:
+		if len(po.OnConds) == 1 {
+			tree = tree.AddBranch(fmt.Sprintf("PiecewiseMergeJoin (%v):", po.JoinTyp))
+		} else {
+			tree = tree.AddBranch(fmt.Sprintf("IEJoin (%v):", po.JoinTyp))
+		}
+		printPhyOutputs(tree, po)
+		tree.AddMetaNode("index", fmt.Sprintf("%d", po.Index))
+		node := tree.AddMetaBranch("On", "")
+		listExprsToTree(node, po.OnConds)
 	case POT_Agg:
 		tree = tree.AddBranch("Aggregate:")
 		printPhyOutputs(tree, po)
@@ -1367,6 +1481,9 @@ func (po *PhysicalOperator) Print(tree treeprint.Tree) {
 			node := tree.AddBranch(fmt.Sprintf("groupExprs, index %d", po.Index))
 			listExprsToTree(node, po.GroupBys)
 		}
+		if len(po.GroupingSets) > 0 {
+			tree.AddMetaNode("groupingSets", groupingSetsString(po.GroupingSets))
+		}
 		if len(po.Aggs) > 0 {
 			node := tree.AddBranch(fmt.Sprintf("aggExprs, index %d", po.Index2))
 			listExprsToTree(node, po.Aggs)
@@ -1384,11 +1501,21 @@ func (po *PhysicalOperator) Print(tree treeprint.Tree) {
 	case POT_Limit:
 		tree = tree.AddBranch(fmt.Sprintf("Limit: %v", po.Limit.String()))
 		printPhyOutputs(tree, po)
+	case POT_Unnest:
+		tree = tree.AddBranch("Unnest:")
+		printPhyOutputs(tree, po)
+		tree.AddMetaNode("index", fmt.Sprintf("%d", po.Index))
+		node := tree.AddMetaBranch("exprs", "")
+		listExprsToTree(node, po.Projects)
 	case POT_Stub:
 		tree = tree.AddBranch(fmt.Sprintf("Stub: %v %v", po.Table, po.ChunkCount))
 		printPhyOutputs(tree, po)
 	case POT_CreateSchema:
 		tree = tree.AddBranch(fmt.Sprintf("CreateSchema: %v %v", po.Database, po.IfNotExists))
+	case POT_CreateMacro:
+		tree = tree.AddBranch(fmt.Sprintf("CreateMacro: %v %v", po.Database, po.Table))
+	case POT_Set:
+		tree = tree.AddBranch(fmt.Sprintf("Set: %v", po.Setting))
 	case POT_CreateTable:
 		tree = tree.AddBranch(fmt.Sprintf("CreateTable: %v %v %v", po.Database, po.Table, po.IfNotExists))
 		node := tree.AddMetaBranch("colDefs", "")
@@ -1638,12 +1765,13 @@ func deceaseDepth(expr *Expr) (*Expr, bool) {
 				args = append(args, newChild)
 			}
 			return &Expr{
-				Typ:      expr.Typ,
-				SubTyp:   expr.SubTyp,
-				Svalue:   expr.Svalue,
-				DataTyp:  expr.DataTyp,
-				Children: args,
-				FunImpl:  expr.FunImpl,
+				Typ:       expr.Typ,
+				SubTyp:    expr.SubTyp,
+				Svalue:    expr.Svalue,
+				DataTyp:   expr.DataTyp,
+				HasFilter: expr.HasFilter,
+				Children:  args,
+				FunImpl:   expr.FunImpl,
 			}, hasCorCol
 		default:
 			panic(fmt.Sprintf("usp %v", expr.SubTyp))
@@ -0,0 +1,683 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"math/bits"
+	"slices"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+/*
+Range joins
+
+The inner join without the equality keys is the cross product with
+the filter on it. The join on the inequalities between the sides
+runs as the range join instead.
+
+	piecewise merge join: l.x op1 r.x
+	IEJoin: l.x op1 r.x AND l.y op2 r.y
+
+Both sides are materialized without the rows having NULL keys.
+The rows of both sides are sorted together on one key by the LocalSort.
+The side of the row is the second sort key that breaks the ties.
+
+The matches of the left row on l.x op1 r.x are the right rows
+after it in the order L1:
+
+	<, <=: ascending x
+	>, >=: descending x
+	ties: the right rows first on the strict one. the left rows first otherwise.
+
+The piecewise merge join outputs the right rows after the left row in L1.
+
+The IEJoin visits the rows in the order L2. The matches of the left row
+on l.y op2 r.y are the right rows visited before it:
+
+	<, <=: descending y
+	>, >=: ascending y
+	ties: the left rows first on the strict one. the right rows first otherwise.
+
+The visited right rows are marked in the bitmap on their positions in L1.
+The matches of the left row are the marked bits after its position in L1.
+*/
+
+type RangeJoinStage int
+
+const (
+	RJS_INIT RangeJoinStage = iota
+	RJS_BUILD
+	RJS_PROBE
+	RJS_SCAN
+)
+
+// rangeJoinSide is the materialized rows of one side
+type rangeJoinSide struct {
+	keyExec *ExprExec
+	rows    *ColumnDataCollection
+	keys    *ColumnDataCollection
+}
+
+type RangeJoin struct {
+	//l op r on the keys
+	_cmps     []storage.CompareType
+	_keyTypes []common.LType
+	_left     *rangeJoinSide
+	_right    *rangeJoinSide
+	_rjs      RangeJoinStage
+
+	//L1. entry: the left row i is i. the right row j is left count + j
+	_l1 []int
+	//piecewise merge join.
+	//the right rows in L1 and the count of them before the left rows
+	_rights      []int
+	_lefts       []int
+	_rightsCount []int
+	_leftIdx     int
+	_rightIdx    int
+
+	//IEJoin
+	_l2 []int
+	//entry -> position in L1
+	_pos1 []int
+	//the visited right rows on the positions in L1
+	_bits    []uint64
+	_l2Idx   int
+	_curLeft int
+	_nextBit int
+}
+
+// isRangeCond returns true if the cond can be the condition of the range join
+func isRangeCond(cond *Expr) bool {
+	if cond.Typ != ET_Func {
+		return false
+	}
+	switch cond.SubTyp {
+	case ET_Less, ET_LessEqual, ET_Greater, ET_GreaterEqual:
+	default:
+		return false
+	}
+	ltyp := cond.Children[0].DataTyp
+	if ltyp.Id != cond.Children[1].DataTyp.Id {
+		return false
+	}
+	//the types the sort and the ColumnDataCollection support
+	switch ltyp.GetInternalType() {
+	case common.BOOL, common.INT8, common.INT16, common.INT32, common.INT64,
+		common.FLOAT, common.DOUBLE, common.DECIMAL, common.INT128,
+		common.DATE, common.INTERVAL, common.VARCHAR:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRangeJoin returns true if the conds are one or two inequalities only
+func isRangeJoin(conds []*Expr) bool {
+	if len(conds) == 0 || len(conds) > 2 {
+		return false
+	}
+	for _, cond := range conds {
+		if !isRangeCond(cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// pushRangeConds moves the inequalities between the sides of the cross product
+// under the filter into the join. At most two of them are moved.
+func pushRangeConds(filter *LogicalOperator) {
+	join := filter.Children[0]
+	if join.Typ != LOT_JOIN || len(join.OnConds) != 0 ||
+		join.JoinTyp != LOT_JoinTypeCross && join.JoinTyp != LOT_JoinTypeInner {
+		return
+	}
+	rest := make([]*Expr, 0)
+	onConds := make([]*Expr, 0)
+	for _, cond := range filter.Filters {
+		var onCond *Expr
+		if isRangeCond(cond) {
+			onCond = rangeJoinCond(cond, join)
+		}
+		if onCond != nil {
+			if slices.ContainsFunc(onConds, onCond.equal) {
+				//the duplicate one
+				continue
+			}
+			if len(onConds) < 2 {
+				onConds = append(onConds, onCond)
+				continue
+			}
+		}
+		rest = append(rest, cond)
+	}
+	if len(onConds) != 0 {
+		join.OnConds = onConds
+		join.JoinTyp = LOT_JoinTypeInner
+		filter.Filters = rest
+	}
+}
+
+// rangeJoinCond rewrites the cond on the outputs of the join into
+// the one on the children with the left child on the left.
+// It returns nil if the cond is not between the children.
+func rangeJoinCond(cond *Expr, join *LogicalOperator) *Expr {
+	left, lsrc := joinSideExpr(cond.Children[0], join)
+	right, rsrc := joinSideExpr(cond.Children[1], join)
+	ret := &Expr{
+		Typ:        cond.Typ,
+		SubTyp:     cond.SubTyp,
+		Svalue:     cond.Svalue,
+		DataTyp:    cond.DataTyp,
+		IsOperator: cond.IsOperator,
+		BindInfo:   cond.BindInfo,
+		FunImpl:    cond.FunImpl,
+	}
+	switch {
+	case lsrc == LeftChild && rsrc == RightChild:
+		ret.Children = []*Expr{left, right}
+	case lsrc == RightChild && rsrc == LeftChild:
+		//r op l => l flip(op) r on the same argument types.
+		//the comparison is evaluated by the select on the sub type.
+		ret.SubTyp = flipComparison(cond.SubTyp)
+		ret.Svalue = ret.SubTyp.String()
+		fun := *cond.FunImpl
+		fun._name = ret.SubTyp.String()
+		fun._scalar = nil
+		ret.FunImpl = &fun
+		ret.Children = []*Expr{right, left}
+	default:
+		return nil
+	}
+	return ret
+}
+
+// flipComparison returns the comparison with the swapped operands
+func flipComparison(subTyp ET_SubTyp) ET_SubTyp {
+	switch subTyp {
+	case ET_Less:
+		return ET_Greater
+	case ET_LessEqual:
+		return ET_GreaterEqual
+	case ET_Greater:
+		return ET_Less
+	case ET_GreaterEqual:
+		return ET_LessEqual
+	default:
+		panic("usp " + subTyp.String())
+	}
+}
+
+// joinSideExpr replaces the columns on the outputs of the join in the expr
+// with the columns on the children. It returns ThisNode as the source
+// if the expr is not on exactly one child.
+func joinSideExpr(e *Expr, join *LogicalOperator) (*Expr, SourceType) {
+	ret := e.copy()
+	srcs := make(map[SourceType]bool)
+	var replace func(e *Expr) bool
+	replace = func(e *Expr) bool {
+		switch e.Typ {
+		case ET_Column:
+			if e.Depth != 0 || SourceType(e.ColRef.table()) != LeftChild {
+				return false
+			}
+			out := join.Outputs[e.ColRef.column()]
+			if out.Typ != ET_Column {
+				return false
+			}
+			e.ColRef = out.ColRef
+			srcs[SourceType(out.ColRef.table())] = true
+		case ET_Subquery:
+			return false
+		}
+		for _, child := range e.Children {
+			if !replace(child) {
+				return false
+			}
+		}
+		return true
+	}
+	if !replace(ret) || len(srcs) != 1 {
+		return nil, ThisNode
+	}
+	for src := range srcs {
+		return ret, src
+	}
+	return nil, ThisNode
+}
+
+func NewRangeJoin(leftTypes, rightTypes []common.LType, conds []*Expr) *RangeJoin {
+	util.AssertFunc(len(conds) == 1 || len(conds) == 2)
+	ret := &RangeJoin{
+		_curLeft: -1,
+	}
+	leftKeys := make([]*Expr, 0)
+	rightKeys := make([]*Expr, 0)
+	for _, cond := range conds {
+		ret._cmps = append(ret._cmps, compareTypeOf(cond.SubTyp))
+		ret._keyTypes = append(ret._keyTypes, cond.Children[0].DataTyp)
+		leftKeys = append(leftKeys, cond.Children[0])
+		rightKeys = append(rightKeys, cond.Children[1])
+	}
+	ret._left = &rangeJoinSide{
+		keyExec: NewExprExec(leftKeys...),
+		rows:    NewColumnDataCollection(leftTypes),
+		keys:    NewColumnDataCollection(ret._keyTypes),
+	}
+	ret._right = &rangeJoinSide{
+		keyExec: NewExprExec(rightKeys...),
+		rows:    NewColumnDataCollection(rightTypes),
+		keys:    NewColumnDataCollection(ret._keyTypes),
+	}
+	return ret
+}
+
+// Build materializes the chunk of the right child
+func (rj *RangeJoin) Build(input *chunk.Chunk) error {
+	rj._rjs = RJS_BUILD
+	return rj._right.append([]*chunk.Chunk{nil, input, nil}, input, rj._keyTypes)
+}
+
+// Probe materializes the chunk of the left child
+func (rj *RangeJoin) Probe(input *chunk.Chunk) error {
+	rj._rjs = RJS_PROBE
+	if rj._right.rows._count == 0 {
+		//no matches
+		return nil
+	}
+	return rj._left.append([]*chunk.Chunk{input, nil, nil}, input, rj._keyTypes)
+}
+
+func (side *rangeJoinSide) append(data []*chunk.Chunk, input *chunk.Chunk, keyTypes []common.LType) error {
+	if input.Card() == 0 {
+		return nil
+	}
+	keys := &chunk.Chunk{}
+	keys.Init(keyTypes, util.DefaultVectorSize)
+	err := side.keyExec.executeExprs(data, keys)
+	if err != nil {
+		return err
+	}
+
+	//the rows having NULL keys are never matched
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	count := 0
+	kdata := keys.ToUnifiedFormat()
+	for i := 0; i < input.Card(); i++ {
+		valid := true
+		for _, kd := range kdata {
+			if !kd.Mask.RowIsValid(uint64(kd.Sel.GetIndex(i))) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			sel.SetIndex(count, i)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	if count < input.Card() {
+		rows := &chunk.Chunk{}
+		rows.Init(side.rows._types, util.DefaultVectorSize)
+		rows.Slice(input, sel, count, 0)
+		input = rows
+		keys.SliceItself(sel, count)
+	}
+	side.rows.Append(input)
+	side.keys.Append(keys)
+	return nil
+}
+
+// Finalize sorts the rows after the last chunk of the left child
+func (rj *RangeJoin) Finalize() {
+	rj._rjs = RJS_SCAN
+	leftCount := rj._left.rows._count
+	cmp := rj._cmps[0]
+	rj._l1 = rj.sortEntries(0,
+		cmp == storage.CompareGreater || cmp == storage.CompareGreaterEqual,
+		cmp == storage.CompareLess || cmp == storage.CompareGreater,
+	)
+	if len(rj._cmps) == 1 {
+		for _, entry := range rj._l1 {
+			if entry < leftCount {
+				rj._lefts = append(rj._lefts, entry)
+				rj._rightsCount = append(rj._rightsCount, len(rj._rights))
+			} else {
+				rj._rights = append(rj._rights, entry-leftCount)
+			}
+		}
+		if len(rj._lefts) != 0 {
+			rj._rightIdx = rj._rightsCount[0]
+		}
+		return
+	}
+
+	cmp = rj._cmps[1]
+	rj._l2 = rj.sortEntries(1,
+		cmp == storage.CompareLess || cmp == storage.CompareLessEqual,
+		cmp == storage.CompareGreaterEqual || cmp == storage.CompareLessEqual,
+	)
+	rj._pos1 = make([]int, len(rj._l1))
+	for i, entry := range rj._l1 {
+		rj._pos1[entry] = i
+	}
+	rj._bits = make([]uint64, (len(rj._l1)+63)/64)
+}
+
+// sortEntries sorts the rows of both sides on the key.
+// The right rows go first on the ties if rightFirst is true.
+func (rj *RangeJoin) sortEntries(keyIdx int, desc bool, rightFirst bool) []int {
+	count := rj._left.rows._count + rj._right.rows._count
+	if count == 0 {
+		return nil
+	}
+	integer := common.IntegerType()
+	bigint := common.BigintType()
+	orders := []*Expr{
+		{Typ: ET_Orderby, Desc: desc, Children: []*Expr{{DataTyp: rj._keyTypes[keyIdx]}}},
+		{Typ: ET_Orderby, Desc: rightFirst, Children: []*Expr{{DataTyp: integer}}},
+	}
+	localSort := NewLocalSort(
+		NewSortLayout(orders),
+		NewRowLayout([]common.LType{bigint}, nil),
+	)
+	defer localSort.Close()
+
+	entry := 0
+	for side, keys := range []*ColumnDataCollection{rj._left.keys, rj._right.keys} {
+		for _, keyChunk := range keys._chunks {
+			sortKey := &chunk.Chunk{}
+			sortKey.Init([]common.LType{rj._keyTypes[keyIdx], integer}, util.DefaultVectorSize)
+			sortKey.Data[0].Reference(keyChunk.Data[keyIdx])
+			sides := chunk.GetSliceInPhyFormatFlat[int32](sortKey.Data[1])
+			payload := &chunk.Chunk{}
+			payload.Init([]common.LType{bigint}, util.DefaultVectorSize)
+			entries := chunk.GetSliceInPhyFormatFlat[int64](payload.Data[0])
+			for i := 0; i < keyChunk.Card(); i++ {
+				sides[i] = int32(side)
+				entries[i] = int64(entry)
+				entry++
+			}
+			sortKey.SetCard(keyChunk.Card())
+			payload.SetCard(keyChunk.Card())
+			localSort.SinkChunk(sortKey, payload)
+		}
+	}
+	localSort.Sort(true)
+
+	ret := make([]int, 0, count)
+	scanner := NewPayloadScanner(localSort._sortedBlocks[0]._payloadData, localSort, true)
+	for {
+		output := &chunk.Chunk{}
+		output.Init([]common.LType{bigint}, util.DefaultVectorSize)
+		scanner.Scan(output)
+		if output.Card() == 0 {
+			break
+		}
+		entries := chunk.GetSliceInPhyFormatFlat[int64](output.Data[0])
+		for i := 0; i < output.Card(); i++ {
+			ret = append(ret, int(entries[i]))
+		}
+	}
+	util.AssertFunc(len(ret) == count)
+	return ret
+}
+
+// Scan outputs the matched rows of both sides.
+// It returns false if there is no more matches.
+func (rj *RangeJoin) Scan(left, right *chunk.Chunk) bool {
+	leftRows := make([]int, 0, util.DefaultVectorSize)
+	rightRows := make([]int, 0, util.DefaultVectorSize)
+	if len(rj._cmps) == 1 {
+		leftRows, rightRows = rj.piecewiseMergeNext(leftRows, rightRows)
+	} else {
+		leftRows, rightRows = rj.ieJoinNext(leftRows, rightRows)
+	}
+	if len(leftRows) == 0 {
+		return false
+	}
+	gatherRows(rj._left.rows, leftRows, left)
+	gatherRows(rj._right.rows, rightRows, right)
+	return true
+}
+
+// piecewiseMergeNext outputs the right rows after the left rows in L1
+func (rj *RangeJoin) piecewiseMergeNext(leftRows, rightRows []int) ([]int, []int) {
+	for len(leftRows) < util.DefaultVectorSize && rj._leftIdx < len(rj._lefts) {
+		if rj._rightIdx == len(rj._rights) {
+			rj._leftIdx++
+			if rj._leftIdx < len(rj._lefts) {
+				rj._rightIdx = rj._rightsCount[rj._leftIdx]
+			}
+			continue
+		}
+		n := min(util.DefaultVectorSize-len(leftRows), len(rj._rights)-rj._rightIdx)
+		for i := 0; i < n; i++ {
+			leftRows = append(leftRows, rj._lefts[rj._leftIdx])
+		}
+		rightRows = append(rightRows, rj._rights[rj._rightIdx:rj._rightIdx+n]...)
+		rj._rightIdx += n
+	}
+	return leftRows, rightRows
+}
+
+// ieJoinNext visits the rows in L2 and outputs the marked right rows
+// after the left rows in L1
+func (rj *RangeJoin) ieJoinNext(leftRows, rightRows []int) ([]int, []int) {
+	leftCount := rj._left.rows._count
+	for len(leftRows) < util.DefaultVectorSize {
+		if rj._curLeft < 0 {
+			if rj._l2Idx == len(rj._l2) {
+				break
+			}
+			entry := rj._l2[rj._l2Idx]
+			rj._l2Idx++
+			pos := rj._pos1[entry]
+			if entry >= leftCount {
+				rj._bits[pos/64] |= 1 << (pos % 64)
+				continue
+			}
+			rj._curLeft = entry
+			rj._nextBit = pos + 1
+		}
+		pos := nextSetBit(rj._bits, rj._nextBit)
+		if pos < 0 {
+			rj._curLeft = -1
+			continue
+		}
+		leftRows = append(leftRows, rj._curLeft)
+		rightRows = append(rightRows, rj._l1[pos]-leftCount)
+		rj._nextBit = pos + 1
+	}
+	return leftRows, rightRows
+}
+
+// nextSetBit returns the position of the first set bit from the start.
+// It returns -1 if there is no one.
+func nextSetBit(words []uint64, start int) int {
+	idx := start / 64
+	if idx >= len(words) {
+		return -1
+	}
+	word := words[idx] >> (start % 64)
+	if word != 0 {
+		return start + bits.TrailingZeros64(word)
+	}
+	for idx++; idx < len(words); idx++ {
+		if words[idx] != 0 {
+			return idx*64 + bits.TrailingZeros64(words[idx])
+		}
+	}
+	return -1
+}
+
+// gatherRows copies the rows of the collection into the output in order.
+// The consecutive rows in the same chunk are copied together.
+func gatherRows(cdc *ColumnDataCollection, rows []int, output *chunk.Chunk) {
+	sel := chunk.NewSelectVector(util.DefaultVectorSize)
+	for start := 0; start < len(rows); {
+		chunkIdx := rows[start] / util.DefaultVectorSize
+		end := start
+		for end < len(rows) && rows[end]/util.DefaultVectorSize == chunkIdx {
+			sel.SetIndex(end-start, rows[end]%util.DefaultVectorSize)
+			end++
+		}
+		src := cdc._chunks[chunkIdx]
+		for i := range output.Data {
+			chunk.Copy(src.Data[i], output.Data[i], sel, end-start, 0, start)
+		}
+		start = end
+	}
+	output.SetCard(len(rows))
+}
+
+func (rj *RangeJoin) Close() {
+	rj._left = nil
+	rj._right = nil
+	rj._l1 = nil
+	rj._l2 = nil
+	rj._pos1 = nil
+	rj._bits = nil
+}
+
+func (run *Runner) rangeJoinInit() error {
+	run.state = &OperatorState{
+		outputExec: NewExprExec(run.op.Outputs...),
+	}
+	childTypes := func(child *PhysicalOperator) []common.LType {
+		types := make([]common.LType, len(child.Outputs))
+		for i, e := range child.Outputs {
+			types[i] = e.DataTyp
+		}
+		return types
+	}
+	run.rjoin = NewRangeJoin(
+		childTypes(run.op.Children[0]),
+		childTypes(run.op.Children[1]),
+		run.op.OnConds)
+	return nil
+}
+
+func (run *Runner) rangeJoinExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+	//1. materialize the right child
+	if run.rjoin._rjs == RJS_INIT {
+		for {
+			rightChunk := &chunk.Chunk{}
+			res, err := run.execChild(run.children[1], rightChunk, state)
+			if err != nil {
+				return InvalidOpResult, err
+			}
+			if res == InvalidOpResult {
+				return InvalidOpResult, nil
+			}
+			if res == Done {
+				break
+			}
+			_, err = run.rangeJoinSink(rightChunk)
+			if err != nil {
+				return InvalidOpResult, err
+			}
+		}
+		err := run.rangeJoinFinalize()
+		if err != nil {
+			return InvalidOpResult, err
+		}
+	}
+	//2. materialize the left child
+	if run.rjoin._rjs == RJS_PROBE {
+		for {
+			leftChunk := &chunk.Chunk{}
+			res, err := run.execChild(run.children[0], leftChunk, state)
+			if err != nil {
+				return InvalidOpResult, err
+			}
+			if res == InvalidOpResult {
+				return InvalidOpResult, nil
+			}
+			if res == Done {
+				break
+			}
+			_, err = run.rangeJoinExecute(leftChunk, output)
+			if err != nil {
+				return InvalidOpResult, err
+			}
+		}
+	}
+	//3. join
+	return run.rangeJoinFlush(output)
+}
+
+// rangeJoinExecute materializes the input. The results are produced
+// by the flush after the last input.
+func (run *Runner) rangeJoinExecute(input, output *chunk.Chunk) (OperatorResult, error) {
+	err := run.rjoin.Probe(input)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	return NeedMoreInput, nil
+}
+
+// rangeJoinFlush joins the materialized rows.
+// It returns haveMoreOutput if there are more results.
+func (run *Runner) rangeJoinFlush(output *chunk.Chunk) (OperatorResult, error) {
+	if run.rjoin._rjs != RJS_SCAN {
+		run.rjoin.Finalize()
+	}
+	leftChunk := &chunk.Chunk{}
+	leftChunk.Init(run.rjoin._left.rows._types, util.DefaultVectorSize)
+	rightChunk := &chunk.Chunk{}
+	rightChunk.Init(run.rjoin._right.rows._types, util.DefaultVectorSize)
+	if !run.rjoin.Scan(leftChunk, rightChunk) {
+		return Done, nil
+	}
+	err := run.state.outputExec.executeExprs(
+		[]*chunk.Chunk{leftChunk, rightChunk, nil},
+		output,
+	)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	return haveMoreOutput, nil
+}
+
+// rangeJoinSink materializes the chunk of the right child
+func (run *Runner) rangeJoinSink(input *chunk.Chunk) (SinkResult, error) {
+	err := run.rjoin.Build(input)
+	if err != nil {
+		return SinkResDone, err
+	}
+	return SinkResNeedMoreInput, nil
+}
+
+func (run *Runner) rangeJoinFinalize() error {
+	run.rjoin._rjs = RJS_PROBE
+	return nil
+}
+
+func (run *Runner) rangeJoinClose() error {
+	if run.rjoin != nil {
+		run.rjoin.Close()
+	}
+	run.rjoin = nil
+	return nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_rangeJoin(t *testing.T) {
+	integer := common.IntegerType()
+	//[id, key0, key1]. -1 is NULL
+	newRows := func(n int) [][3]int64 {
+		rows := make([][3]int64, n)
+		for i := range rows {
+			rows[i] = [3]int64{int64(i), rand.Int63n(20), rand.Int63n(20)}
+			if rand.Intn(50) == 0 {
+				rows[i][1+rand.Intn(2)] = -1
+			}
+		}
+		return rows
+	}
+	//the keys of the double keep the order of the integers
+	newChunks := func(types []common.LType, rows [][3]int64) []*chunk.Chunk {
+		ret := make([]*chunk.Chunk, 0)
+		for start := 0; start < len(rows); start += util.DefaultVectorSize {
+			end := min(start+util.DefaultVectorSize, len(rows))
+			data := &chunk.Chunk{}
+			data.Init(types, util.DefaultVectorSize)
+			for i, row := range rows[start:end] {
+				for j, v := range row {
+					val := &chunk.Value{Typ: types[j], I64: v}
+					if types[j].Id == common.LTID_DOUBLE {
+						val.F64 = float64(v-10) / 4
+					}
+					if v < 0 {
+						val.IsNull = true
+					}
+					data.Data[j].SetValue(i, val)
+				}
+			}
+			data.SetCard(end - start)
+			ret = append(ret, data)
+		}
+		return ret
+	}
+	compare := map[ET_SubTyp]func(a, b int64) bool{
+		ET_Less:         func(a, b int64) bool { return a < b },
+		ET_LessEqual:    func(a, b int64) bool { return a <= b },
+		ET_Greater:      func(a, b int64) bool { return a > b },
+		ET_GreaterEqual: func(a, b int64) bool { return a >= b },
+	}
+
+	left := newRows(3000)
+	right := newRows(300)
+	ops := []ET_SubTyp{ET_Less, ET_LessEqual, ET_Greater, ET_GreaterEqual}
+	for _, keyTyp := range []common.LType{integer, common.BigintType(), common.DoubleType()} {
+		types := []common.LType{integer, keyTyp, keyTyp}
+		col := func(src SourceType, idx int) *Expr {
+			return &Expr{Typ: ET_Column, DataTyp: keyTyp, ColRef: ColumnBind{uint64(src), uint64(idx)}}
+		}
+		for _, op1 := range ops {
+			ops2 := append([]ET_SubTyp{0}, ops...)
+			if keyTyp.Id != common.LTID_INTEGER {
+				//the other types on the piecewise merge join and the IEJoin
+				ops2 = []ET_SubTyp{0, op1}
+			}
+			for _, op2 := range ops2 {
+				conds := []*Expr{
+					{Typ: ET_Func, SubTyp: op1, Children: []*Expr{col(LeftChild, 1), col(RightChild, 1)}},
+				}
+				if op2 != 0 {
+					conds = append(conds, &Expr{Typ: ET_Func, SubTyp: op2, Children: []*Expr{col(LeftChild, 2), col(RightChild, 2)}})
+				}
+				t.Run(fmt.Sprintf("%v %v %v", keyTyp, op1, op2), func(t *testing.T) {
+					expect := make(map[[2]int64]int)
+					for _, l := range left {
+						for _, r := range right {
+							if l[1] < 0 || r[1] < 0 || !compare[op1](l[1], r[1]) {
+								continue
+							}
+							if op2 != 0 && (l[2] < 0 || r[2] < 0 || !compare[op2](l[2], r[2])) {
+								continue
+							}
+							expect[[2]int64{l[0], r[0]}]++
+						}
+					}
+
+					rj := NewRangeJoin(types, types, conds)
+					for _, data := range newChunks(types, right) {
+						require.NoError(t, rj.Build(data))
+					}
+					for _, data := range newChunks(types, left) {
+						require.NoError(t, rj.Probe(data))
+					}
+					rj.Finalize()
+					result := make(map[[2]int64]int)
+					for {
+						leftChunk := &chunk.Chunk{}
+						leftChunk.Init(types, util.DefaultVectorSize)
+						rightChunk := &chunk.Chunk{}
+						rightChunk.Init(types, util.DefaultVectorSize)
+						if !rj.Scan(leftChunk, rightChunk) {
+							break
+						}
+						require.Equal(t, leftChunk.Card(), rightChunk.Card())
+						for i := 0; i < leftChunk.Card(); i++ {
+							result[[2]int64{
+								leftChunk.Data[0].GetValue(i).I64,
+								rightChunk.Data[0].GetValue(i).I64,
+							}]++
+						}
+					}
+					assert.Equal(t, expect, result)
+				})
+			}
+		}
+	}
+}
+
+func hasRangeJoin(root *PhysicalOperator) bool {
+	if root.Typ == POT_RangeJoin {
+		return true
+	}
+	return slices.ContainsFunc(root.Children, hasRangeJoin)
+}
+
+func Test_rangeJoinSQL(t *testing.T) {
+	from := " from generate_series(1,100) a(x), generate_series(1,100) b(y) where "
+	tests := []struct {
+		sql    string
+		expect []string
+	}{
+		//bigint
+		{"select count(*)" + from + "a.x < b.y", []string{"4950"}},
+		//the left child on the right side
+		{"select count(*)" + from + "b.y < a.x + 5", []string{"5440"}},
+		{"select count(*)" + from + "b.y >= a.x and a.x > b.y - 2", []string{"199"}},
+		//double
+		{"select count(*)" + from + "cast(a.x as double) < b.y", []string{"4950"}},
+		{"select count(*)" + from + "a.x > b.y and a.x < b.y + 3", []string{"197"}},
+		//empty side
+		{"select a.x, b.y from generate_series(1,3) a(x), generate_series(1,0) b(y) where a.x < b.y", []string{}},
+		{"select a.x, b.y from generate_series(1,0) a(x), generate_series(1,3) b(y) where b.y < a.x", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.True(t, hasRangeJoin(genSelectPhyPlan(t, tt.sql)))
+			assert.Equal(t, tt.expect, runSelectSQL(t, tt.sql))
+		})
+	}
+}
@@ -15,8 +15,12 @@
 package plan
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -187,7 +191,47 @@ func RunDDL(cfg *util.Config) error {
 	return nil
 }
 
-func InitRunner(cfg *util.Config, txn *storage.Txn, query string) (*Runner, error) {
+// cancelError is the error of the stopped statement.
+// It wraps the error of the context.
+type cancelError struct {
+	msg string
+	err error
+}
+
+func (e *cancelError) Error() string {
+	return e.msg
+}
+
+func (e *cancelError) Unwrap() error {
+	return e.err
+}
+
+var (
+	ErrQueryCanceled error = &cancelError{
+		msg: "canceling statement due to user request",
+		err: context.Canceled,
+	}
+	ErrStatementTimeout error = &cancelError{
+		msg: "canceling statement due to statement timeout",
+		err: context.DeadlineExceeded,
+	}
+)
+
+// recoverError converts the panic into the error of the statement
+func recoverError(v any) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return util.ConvertPanicError(v)
+}
+
+func InitRunner(cfg *util.Config, txn *storage.Txn, query string) (run *Runner, err error) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			run = nil
+			err = recoverError(rErr)
+		}
+	}()
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
@@ -213,13 +257,13 @@ func InitRunner(cfg *util.Config, txn *storage.Txn, query string) (*Runner, erro
 	}
 
 	//gen runner
-	run := &Runner{
+	run = &Runner{
 		op:    root,
 		state: &OperatorState{},
 		cfg:   cfg,
 		Txn:   txn,
 	}
-	err = run.Init()
+	run.executor, err = NewPipelineExecutor(run)
 	if err != nil {
 		return nil, err
 	}
@@ -506,6 +550,8 @@ type OperatorState struct {
 	ungroupAggr           bool
 	ungroupAggrDone       bool
 	haScanState           *HashAggrScanState
+	aggrPos               []int          //position of the aggr in the output of hash aggr
+	aggrTypes             []common.LType //types of the aggrs and grouping funcs
 	groupbyWithParamsExec *ExprExec
 	groupbyExec           *ExprExec
 
@@ -579,6 +625,9 @@ type Runner struct {
 	//for limit
 	limit *Limit
 
+	//for unnest
+	unnest *Unnest
+
 	//for order
 	localSort *LocalSort
 
@@ -589,15 +638,26 @@ type Runner struct {
 	cross *CrossProduct
 	//for hash join
 	hjoin *HashJoin
+	//for range join
+	rjoin *RangeJoin
 
 	//for scan
 	pqFile        source.ParquetFile
 	pqReader      *pqReader.ParquetReader
+	pqColumns     []*pqColumn
 	dataFile      *os.File
 	reader        *csv.Reader
+	jsonDecoder   *json.Decoder
 	colIndice     []int
 	readedColTyps []common.LType
 	tablePath     string
+	//files of the copy from or the table function
+	scanFiles []string
+	fileIdx   int
+	//cursor of generate_series
+	series *seriesState
+	//for the reservoir sample
+	reservoir *reservoirState
 	//for test cross product
 	maxRows int
 
@@ -611,6 +671,17 @@ type Runner struct {
 
 	//for table scan
 	tabEnt *storage.CatalogEntry
+	//the constant comparisons pushed into the table scan
+	tableFilters *storage.TableFilterSet
+	//the ready runtime filters of the hash joins on the table scan
+	joinFilters []*joinFilter
+
+	//for the push execution
+	executor *PipelineExecutor
+	//the global state of the parallel pipeline. the runner is in the worker if it is not nil
+	pipe *pipelineState
+	//the context of the query. the operators stop if it is done
+	ctx context.Context
 }
 
 func (run *Runner) Columns() wire.Columns {
@@ -628,31 +699,26 @@ func (run *Runner) Columns() wire.Columns {
 
 func (run *Runner) Run(
 	ctx context.Context,
-	writer wire.DataWriter) error {
+	writer wire.DataWriter) (err error) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			err = recoverError(rErr)
+		}
+	}()
 	if run.cfg.Debug.PrintPlan {
 		fmt.Println(run.op.String())
 	}
-
-	for {
-		output := &chunk.Chunk{}
-		output.SetCap(util.DefaultVectorSize)
-		result, err := run.Execute(nil, output, run.state)
-		if err != nil {
-			return err
-		}
-		if result == Done {
-			break
-		}
-		if output.Card() > 0 {
-			util.AssertFunc(output.Card() != 0)
-			err = output.SaveToWriter(writer)
-			if err != nil {
-				return err
-			}
-		}
+	if run.cfg.Exec.StatementTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx,
+			time.Duration(run.cfg.Exec.StatementTimeout)*time.Millisecond,
+			ErrStatementTimeout)
+		defer cancel()
 	}
 
-	return nil
+	return run.executor.Execute(ctx, func(output *chunk.Chunk) error {
+		return output.SaveToWriter(writer)
+	})
 }
 
 func (run *Runner) initChildren() error {
@@ -663,6 +729,7 @@ func (run *Runner) initChildren() error {
 			Txn:   run.Txn,
 			state: &OperatorState{},
 			cfg:   run.cfg,
+			ctx:   run.ctx,
 		}
 		err := childRun.Init()
 		if err != nil {
@@ -686,67 +753,62 @@ func (run *Runner) Init() error {
 	if err != nil {
 		return err
 	}
-	switch run.op.Typ {
-	case POT_Scan:
-		return run.scanInit()
-	case POT_Project:
-		return run.projInit()
-	case POT_Join:
-		return run.joinInit()
-	case POT_Agg:
-		return run.aggrInit()
-	case POT_Filter:
-		return run.filterInit()
-	case POT_Order:
-		return run.orderInit()
-	case POT_Limit:
-		return run.limitInit()
-	case POT_Stub:
-		return run.stubInit()
-	case POT_CreateSchema:
-		return run.createSchemaInit()
-	case POT_CreateTable:
-		return run.createTableInit()
-	case POT_Insert:
-		return run.insertInit()
-	default:
-		panic("usp")
-	}
+	return run.def().init(run)
 }
 
 func (run *Runner) Execute(input, output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+	err := run.checkContext()
+	if err != nil {
+		return InvalidOpResult, err
+	}
 	output.Init(run.outputTypes, util.DefaultVectorSize)
 	defer func(start time.Time) {
 		run.op.ExecStats._totalTime += time.Since(start)
 	}(time.Now())
-	switch run.op.Typ {
-	case POT_Scan:
-		return run.scanExec(output, state)
-	case POT_Project:
-		return run.projExec(output, state)
-	case POT_Join:
-		return run.joinExec(output, state)
-	case POT_Agg:
-		return run.aggrExec(output, state)
-	case POT_Filter:
-		return run.filterExec(output, state)
-	case POT_Order:
-		return run.orderExec(output, state)
-	case POT_Limit:
-		return run.limitExec(output, state)
-	case POT_Stub:
-		return run.stubExec(output, state)
-	case POT_CreateSchema:
-		return run.createSchemaExec(output, state)
-	case POT_CreateTable:
-		return run.createTableExec(output, state)
-	case POT_Insert:
-		return run.insertExec(output, state)
-	default:
-		panic("usp")
+	return run.def().exec(run, output, state)
+}
+
+// setContext sets the context of the query on the runner and its children
+func (run *Runner) setContext(ctx context.Context) {
+	run.ctx = ctx
+	for _, child := range run.children {
+		child.setContext(ctx)
 	}
 }
 
+// checkContext returns the error if the query is canceled or timed out
+func (run *Runner) checkContext() error {
+	if run.ctx == nil {
+		return nil
+	}
+	return contextError(run.ctx)
+}
+
+// ctxCheck checks the cancellation of the query in the long loops
+// of the sort, the aggregate and the hash join. It is the checkContext
+// of the runner. nil is no check.
+type ctxCheck func() error
+
+func (check ctxCheck) err() error {
+	if check == nil {
+		return nil
+	}
+	return check()
+}
+
+// contextError returns the cause of the done context.
+// The cancel without the cause is from the user.
+func contextError(ctx context.Context) error {
+	err := context.Cause(ctx)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrQueryCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrStatementTimeout
+	}
+	return err
+}
+
 func (run *Runner) execChild(child *Runner, output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
 	defer func(start time.Time) {
 		run.op.ExecStats._totalChildTime += time.Since(start)
@@ -769,38 +831,16 @@ func (run *Runner) execChild(child *Runner, output *chunk.Chunk, state *Operator
 }
 
 func (run *Runner) Close() error {
+	if run.executor != nil {
+		return run.executor.Close()
+	}
 	for _, child := range run.children {
 		err := child.Close()
 		if err != nil {
 			return err
 		}
 	}
-	switch run.op.Typ {
-	case POT_Scan:
-		return run.scanClose()
-	case POT_Project:
-		return run.projClose()
-	case POT_Join:
-		return run.joinClose()
-	case POT_Agg:
-		return run.aggrClose()
-	case POT_Filter:
-		return run.filterClose()
-	case POT_Order:
-		return run.orderClose()
-	case POT_Limit:
-		return run.limitClose()
-	case POT_Stub:
-		return run.stubClose()
-	case POT_CreateSchema:
-		return run.createSchemaClose()
-	case POT_CreateTable:
-		return run.createTableClose()
-	case POT_Insert:
-		return run.insertClose()
-	default:
-		panic("usp")
-	}
+	return run.def().close(run)
 }
 
 func (run *Runner) insertInit() error {
@@ -955,6 +995,26 @@ func (run *Runner) createSchemaClose() error {
 	return nil
 }
 
+func (run *Runner) createMacroInit() error {
+	return nil
+}
+
+func (run *Runner) createMacroExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+	macroEnt := storage.GCatalog.GetEntry(run.Txn, storage.CatalogTypeMacro, run.op.Database, run.op.Table)
+	if macroEnt != nil {
+		return InvalidOpResult, fmt.Errorf("macro %s already exists", run.op.Table)
+	}
+	_, err := storage.GCatalog.CreateMacro(run.Txn, run.op.Macro)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	return Done, nil
+}
+
+func (run *Runner) createMacroClose() error {
+	return nil
+}
+
 func (run *Runner) stubInit() error {
 	deserial, err := util.NewFileDeserialize(run.op.Table)
 	if err != nil {
@@ -1003,7 +1063,6 @@ func (run *Runner) limitExec(output *chunk.Chunk, state *OperatorState) (Operato
 	var err error
 	var res OperatorResult
 	if run.limit._state == LIMIT_INIT {
-		cnt := 0
 		for {
 			childChunk := &chunk.Chunk{}
 			res, err = run.execChild(run.children[0], childChunk, state)
@@ -1022,42 +1081,60 @@ func (run *Runner) limitExec(output *chunk.Chunk, state *OperatorState) (Operato
 
 			//childChunk.print()
 
-			ret := run.limit.Sink(childChunk)
+			ret, err := run.limitSink(childChunk)
+			if err != nil {
+				return InvalidOpResult, err
+			}
 			if ret == SinkResDone {
 				break
 			}
 		}
-		fmt.Println("limit total children count", cnt)
-		run.limit._state = LIMIT_SCAN
+		err = run.limitFinalize()
+		if err != nil {
+			return InvalidOpResult, err
+		}
 	}
 
-	if run.limit._state == LIMIT_SCAN {
-		//get data from collection
-		for {
-			read := &chunk.Chunk{}
-			read.Init(run.limit._childTypes, util.DefaultVectorSize)
-			getRet := run.limit.GetData(read)
-			if getRet == SrcResDone {
-				break
-			}
+	getRet, err := run.limitGetData(output)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if getRet == SrcResDone {
+		return Done, nil
+	}
+	return haveMoreOutput, nil
+}
 
-			//evaluate output
-			err = run.state.outputExec.executeExprs([]*chunk.Chunk{read, nil, nil}, output)
-			if err != nil {
-				return InvalidOpResult, err
-			}
+func (run *Runner) limitSink(input *chunk.Chunk) (SinkResult, error) {
+	return run.limit.Sink(input), nil
+}
 
-			if output.Card() > 0 {
-				return haveMoreOutput, nil
-			}
+func (run *Runner) limitFinalize() error {
+	run.limit._state = LIMIT_SCAN
+	return nil
+}
+
+func (run *Runner) limitGetData(output *chunk.Chunk) (SourceResult, error) {
+	//get data from collection
+	for {
+		read := &chunk.Chunk{}
+		read.Init(run.limit._childTypes, util.DefaultVectorSize)
+		getRet := run.limit.GetData(read)
+		if getRet == SrcResDone {
+			break
 		}
 
-	}
+		//evaluate output
+		err := run.state.outputExec.executeExprs([]*chunk.Chunk{read, nil, nil}, output)
+		if err != nil {
+			return SrcResDone, err
+		}
 
-	if output.Card() == 0 {
-		return Done, nil
+		if output.Card() > 0 {
+			return SrcResHaveMoreOutput, nil
+		}
 	}
-	return haveMoreOutput, nil
+	return SrcResDone, nil
 }
 
 func (run *Runner) limitClose() error {
@@ -1083,8 +1160,10 @@ func (run *Runner) orderInit() error {
 
 	run.localSort = NewLocalSort(
 		NewSortLayout(run.op.OrderBys),
-		NewRowLayout(payLoadTypes, nil),
+		NewRowLayout(rowTypes(payLoadTypes), nil),
 	)
+	run.localSort.SetMemoryLimit(run.sortMemoryLimit())
+	run.localSort._ctxCheck = run.checkContext
 
 	run.state = &OperatorState{
 		keyTypes:     keyTypes,
@@ -1100,7 +1179,6 @@ func (run *Runner) orderExec(output *chunk.Chunk, state *OperatorState) (Operato
 	var err error
 	var res OperatorResult
 	if run.localSort._sortState == SS_INIT {
-		cnt := 0
 		for {
 			childChunk := &chunk.Chunk{}
 			res, err = run.execChild(run.children[0], childChunk, state)
@@ -1119,75 +1197,169 @@ func (run *Runner) orderExec(output *chunk.Chunk, state *OperatorState) (Operato
 
 			//childChunk.print()
 
-			//evaluate order by expr
-			key := &chunk.Chunk{}
-			key.Init(run.state.keyTypes, util.DefaultVectorSize)
-			err = run.state.orderKeyExec.executeExprs(
-				[]*chunk.Chunk{childChunk, nil, nil},
-				key,
-			)
+			_, err = run.orderSink(childChunk)
 			if err != nil {
 				return 0, err
 			}
+		}
+		err = run.orderFinalize()
+		if err != nil {
+			return InvalidOpResult, err
+		}
+	}
+
+	getRet, err := run.orderGetData(output)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if getRet == SrcResDone {
+		return Done, nil
+	}
+	return haveMoreOutput, nil
+}
+
+func (run *Runner) orderSink(input *chunk.Chunk) (SinkResult, error) {
+	//evaluate order by expr
+	key := &chunk.Chunk{}
+	key.Init(run.state.keyTypes, util.DefaultVectorSize)
+	err := run.state.orderKeyExec.executeExprs(
+		[]*chunk.Chunk{input, nil, nil},
+		key,
+	)
+	if err != nil {
+		return SinkResDone, err
+	}
 
-			//key.print()
+	//key.print()
 
-			//evaluate payload expr
-			payload := &chunk.Chunk{}
-			payload.Init(run.state.payloadTypes, util.DefaultVectorSize)
+	//evaluate payload expr
+	payload := &chunk.Chunk{}
+	payload.Init(run.state.payloadTypes, util.DefaultVectorSize)
 
-			err = run.state.outputExec.executeExprs(
-				[]*chunk.Chunk{childChunk, nil, nil},
-				payload,
-			)
-			if err != nil {
-				return 0, err
-			}
+	err = run.state.outputExec.executeExprs(
+		[]*chunk.Chunk{input, nil, nil},
+		payload,
+	)
+	if err != nil {
+		return SinkResDone, err
+	}
 
-			util.AssertFunc(key.Card() != 0 && payload.Card() != 0)
-			cnt += key.Card()
-			util.AssertFunc(key.Card() == payload.Card())
+	encodeNestedChunk(payload)
 
-			run.localSort.SinkChunk(key, payload)
+	util.AssertFunc(key.Card() != 0 && payload.Card() != 0)
+	util.AssertFunc(key.Card() == payload.Card())
+
+	run.localSort.SinkChunk(key, payload)
+	if run.localSort.exceedMemoryLimit() {
+		err = run.localSort.Spill()
+		if err != nil {
+			return SinkResDone, err
 		}
-		fmt.Println("total count", cnt)
-		run.localSort._sortState = SS_SORT
 	}
+	return SinkResNeedMoreInput, nil
+}
 
-	if run.localSort._sortState == SS_SORT {
-		//get all chunks from child
-		run.localSort.Sort(true)
-		run.localSort._sortState = SS_SCAN
+func (run *Runner) orderFinalize() error {
+	//get all chunks from child
+	run.localSort._sortState = SS_SORT
+	if len(run.localSort._runs) == 0 {
+		err := run.localSort.Sort(true)
+		if err != nil {
+			return err
+		}
+	} else {
+		//the rest is the last run
+		err := run.localSort.Spill()
+		if err != nil {
+			return err
+		}
+		run.localSort._merger, err = newSortMerger(run.localSort)
+		if err != nil {
+			return err
+		}
 	}
+	run.localSort._sortState = SS_SCAN
+	return nil
+}
 
-	if run.localSort._sortState == SS_SCAN {
-		if run.localSort._scanner != nil &&
-			run.localSort._scanner.Remaining() == 0 {
-			run.localSort._scanner = nil
-		}
+func (run *Runner) orderGetData(output *chunk.Chunk) (SourceResult, error) {
+	if run.localSort._merger != nil {
+		return run.orderMergeData(output)
+	}
+	if run.localSort._scanner != nil &&
+		run.localSort._scanner.Remaining() == 0 {
+		return SrcResDone, nil
+	}
 
-		if run.localSort._scanner == nil {
-			run.localSort._scanner = NewPayloadScanner(
-				run.localSort._sortedBlocks[0]._payloadData,
-				run.localSort,
-				true,
-			)
-		}
+	if run.localSort._scanner == nil {
+		run.localSort._scanner = NewPayloadScanner(
+			run.localSort._sortedBlocks[0]._payloadData,
+			run.localSort,
+			true,
+		)
+	}
 
+	if hasNestedType(run.state.payloadTypes) {
+		encoded := &chunk.Chunk{}
+		encoded.Init(rowTypes(run.state.payloadTypes), util.DefaultVectorSize)
+		run.localSort._scanner.Scan(encoded)
+		decodeNestedChunk(encoded, output)
+	} else {
 		run.localSort._scanner.Scan(output)
 	}
 
 	if output.Card() == 0 {
-		return Done, nil
+		return SrcResDone, nil
 	}
-	return haveMoreOutput, nil
+	return SrcResHaveMoreOutput, nil
+}
+
+// orderMergeData gets the results from the merger of the sorted runs
+func (run *Runner) orderMergeData(output *chunk.Chunk) (SourceResult, error) {
+	var err error
+	if hasNestedType(run.state.payloadTypes) {
+		encoded := &chunk.Chunk{}
+		encoded.Init(rowTypes(run.state.payloadTypes), util.DefaultVectorSize)
+		err = run.localSort._merger.Scan(encoded)
+		decodeNestedChunk(encoded, output)
+	} else {
+		err = run.localSort._merger.Scan(output)
+	}
+	if err != nil {
+		return SrcResDone, err
+	}
+	if output.Card() == 0 {
+		return SrcResDone, nil
+	}
+	return SrcResHaveMoreOutput, nil
 }
 
 func (run *Runner) orderClose() error {
+	if run.localSort != nil {
+		run.localSort.Close()
+	}
 	run.localSort = nil
 	return nil
 }
 
+const defaultJoinMemoryLimit = 1 << 30
+
+func (run *Runner) joinMemoryLimit() int {
+	if run.cfg == nil || run.cfg.Exec.JoinMemoryLimit < 1 {
+		return defaultJoinMemoryLimit
+	}
+	return int(run.cfg.Exec.JoinMemoryLimit)
+}
+
+const defaultSortMemoryLimit = 1 << 30
+
+func (run *Runner) sortMemoryLimit() int {
+	if run.cfg == nil || run.cfg.Exec.SortMemoryLimit < 1 {
+		return defaultSortMemoryLimit
+	}
+	return int(run.cfg.Exec.SortMemoryLimit)
+}
+
 func (run *Runner) filterInit() error {
 	var err error
 	var filterExec *ExprExec
@@ -1288,7 +1460,7 @@ func (run *Runner) filterExec(output *chunk.Chunk, state *OperatorState) (Operat
 		}
 	}
 
-	err = run.runFilterExec(childChunk, output, false)
+	_, err = run.filterExecute(childChunk, output)
 	if err != nil {
 		return 0, err
 	}
@@ -1296,10 +1468,28 @@ func (run *Runner) filterExec(output *chunk.Chunk, state *OperatorState) (Operat
 	return haveMoreOutput, nil
 }
 
+func (run *Runner) filterExecute(input, output *chunk.Chunk) (OperatorResult, error) {
+	err := run.runFilterExec(input, output, false)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	return NeedMoreInput, nil
+}
+
 func (run *Runner) filterClose() error {
 	return nil
 }
 
+// the memory budget of the hash aggregate if it is not set
+const defaultAggrMemoryLimit = 1 << 30
+
+func (run *Runner) aggrMemoryLimit() int {
+	if run.cfg == nil || run.cfg.Exec.AggrMemoryLimit < 1 {
+		return defaultAggrMemoryLimit
+	}
+	return int(run.cfg.Exec.AggrMemoryLimit)
+}
+
 func (run *Runner) aggrInit() error {
 	run.state = &OperatorState{}
 	//if len(run.op.GroupBys) == 0 /*&& groupingSet*/ {
@@ -1323,6 +1513,7 @@ func (run *Runner) aggrInit() error {
 			run.op.GroupBys = append(run.op.GroupBys, constExpr)
 
 			run.state.constGroupby = true
+			run.op.GroupingSets = nil
 		}
 
 		//children input types
@@ -1339,14 +1530,22 @@ func (run *Runner) aggrInit() error {
 			})
 		}
 
+		aggs, groupingFuncs := run.splitGroupingFuncs()
 		run.hAggr = NewHashAggr(
 			run.outputTypes,
-			run.op.Aggs,
+			aggs,
 			run.op.GroupBys,
-			nil,
-			nil,
+			run.op.GroupingSets,
+			groupingFuncs,
 			refChildrenOutput,
 		)
+		limit := run.aggrMemoryLimit()
+		if run.pipe != nil {
+			//the local hash tables of the workers share the budget
+			limit /= run.threads()
+		}
+		run.hAggr.SetMemoryLimit(limit)
+		run.hAggr.SetCtxCheck(run.checkContext)
 		if run.op.Children[0].Typ == POT_Filter {
 			run.hAggr._printHash = true
 		}
@@ -1377,6 +1576,53 @@ func (run *Runner) aggrInit() error {
 	return nil
 }
 
+// splitGroupingFuncs splits the GROUPING functions from the aggregates.
+// The hash aggr outputs the grouping functions after the aggregates.
+func (run *Runner) splitGroupingFuncs() ([]*Expr, [][]int) {
+	aggs := make([]*Expr, 0)
+	groupingFuncs := make([][]int, 0)
+	for _, aggr := range run.op.Aggs {
+		if aggr.SubTyp != ET_Grouping {
+			aggs = append(aggs, aggr)
+		}
+	}
+	aggrIdx := 0
+	for _, aggr := range run.op.Aggs {
+		run.state.aggrTypes = append(run.state.aggrTypes, aggr.DataTyp)
+		if aggr.SubTyp != ET_Grouping {
+			run.state.aggrPos = append(run.state.aggrPos, aggrIdx)
+			aggrIdx++
+			continue
+		}
+		run.state.aggrPos = append(run.state.aggrPos, len(aggs)+len(groupingFuncs))
+		group := make([]int, 0, len(aggr.Children))
+		for _, child := range aggr.Children {
+			group = append(group, int(child.Ivalue))
+		}
+		groupingFuncs = append(groupingFuncs, group)
+	}
+	return aggs, groupingFuncs
+}
+
+// reorderAggrs puts the aggregates and the grouping functions
+// in the order of the Aggs of the operator.
+func (run *Runner) reorderAggrs(data *chunk.Chunk) *chunk.Chunk {
+	groupCnt := run.hAggr._groupedAggrData.GroupCount()
+	typs := make([]common.LType, 0)
+	typs = append(typs, run.hAggr._groupedAggrData._groupTypes...)
+	typs = append(typs, run.state.aggrTypes...)
+	ret := &chunk.Chunk{}
+	ret.Init(typs, util.DefaultVectorSize)
+	for i := 0; i < groupCnt; i++ {
+		ret.Data[i].Reference(data.Data[i])
+	}
+	for i, pos := range run.state.aggrPos {
+		ret.Data[groupCnt+i].Reference(data.Data[groupCnt+pos])
+	}
+	ret.SetCard(data.Card())
+	return ret
+}
+
 func (run *Runner) aggrExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
 	var err error
 	var res OperatorResult
@@ -1406,164 +1652,208 @@ func (run *Runner) aggrExec(output *chunk.Chunk, state *OperatorState) (Operator
 
 			cnt += childChunk.Card()
 
-			typs := make([]common.LType, 0)
-			typs = append(typs, run.hAggr._groupedAggrData._groupTypes...)
-			typs = append(typs, run.hAggr._groupedAggrData._payloadTypes...)
-			typs = append(typs, run.hAggr._groupedAggrData._childrenOutputTypes...)
-			groupChunk := &chunk.Chunk{}
-			groupChunk.Init(typs, util.DefaultVectorSize)
-			err = run.state.groupbyWithParamsExec.executeExprs([]*chunk.Chunk{childChunk, nil, nil}, groupChunk)
+			_, err = run.aggrSink(childChunk)
 			if err != nil {
 				return InvalidOpResult, err
 			}
-
-			//groupChunk.print()
-			run.hAggr.Sink(groupChunk)
-
 		}
-		run.hAggr.Finalize()
-		run.hAggr._has = HAS_SCAN
 		fmt.Println("get build child cnt", cnt)
-		fmt.Println("tuple collection size", run.hAggr._groupings[0]._tableData._finalizedHT._dataCollection._count)
+		err = run.aggrFinalize()
+		if err != nil {
+			return InvalidOpResult, err
+		}
 	}
-	if run.hAggr._has == HAS_SCAN {
-		if run.state.haScanState == nil {
-			run.state.haScanState = NewHashAggrScanState()
-			err = run.initChildren()
-			if err != nil {
-				return InvalidOpResult, err
-			}
+	if run.state.haScanState == nil {
+		err = run.initChildren()
+		if err != nil {
+			return InvalidOpResult, err
 		}
+	}
+	getRet, err := run.aggrGetData(output)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if getRet == SrcResDone {
+		return Done, nil
+	}
+	return haveMoreOutput, nil
+}
 
-		for {
-
-			if run.state.ungroupAggr {
-				if run.state.ungroupAggrDone {
-					return Done, nil
-				}
-				run.state.ungroupAggrDone = true
-			}
-
-			groupAddAggrTypes := make([]common.LType, 0)
-			groupAddAggrTypes = append(groupAddAggrTypes, run.hAggr._groupedAggrData._groupTypes...)
-			groupAddAggrTypes = append(groupAddAggrTypes, run.hAggr._groupedAggrData._aggrReturnTypes...)
-			groupAndAggrChunk := &chunk.Chunk{}
-			groupAndAggrChunk.Init(groupAddAggrTypes, util.DefaultVectorSize)
-			util.AssertFunc(len(run.hAggr._groupedAggrData._groupingFuncs) == 0)
-			childChunk := &chunk.Chunk{}
-			childChunk.Init(run.hAggr._groupedAggrData._childrenOutputTypes, util.DefaultVectorSize)
-			res = run.hAggr.GetData(run.state.haScanState, groupAndAggrChunk, childChunk)
-			if res == InvalidOpResult {
-				return InvalidOpResult, nil
-			}
-			if res == Done {
-				break
-			}
+func (run *Runner) aggrSink(input *chunk.Chunk) (SinkResult, error) {
+	typs := make([]common.LType, 0)
+	typs = append(typs, run.hAggr._groupedAggrData._groupTypes...)
+	typs = append(typs, run.hAggr._groupedAggrData._payloadTypes...)
+	typs = append(typs, run.hAggr._groupedAggrData._childrenOutputTypes...)
+	groupChunk := &chunk.Chunk{}
+	groupChunk.Init(typs, util.DefaultVectorSize)
+	err := run.state.groupbyWithParamsExec.executeExprs([]*chunk.Chunk{input, nil, nil}, groupChunk)
+	if err != nil {
+		return SinkResDone, err
+	}
 
-			x := childChunk.Card()
+	//groupChunk.print()
+	run.hAggr.Sink(groupChunk)
+	return SinkResNeedMoreInput, nil
+}
 
-			//3.get group by + aggr states for the group
+// aggrCombine merges the local hash tables of the worker
+func (run *Runner) aggrCombine(local *Runner) error {
+	return run.hAggr.Combine(local.hAggr)
+}
 
-			//4.eval the filter on (child chunk + aggr states)
+func (run *Runner) aggrFinalize() error {
+	err := run.hAggr.Finalize()
+	if err != nil {
+		return err
+	}
+	run.hAggr._has = HAS_SCAN
+	return nil
+}
 
-			//childChunk.print()
+func (run *Runner) aggrGetData(output *chunk.Chunk) (SourceResult, error) {
+	var err error
+	var res OperatorResult
+	state := run.state
+	if run.state.haScanState == nil {
+		run.state.haScanState = NewHashAggrScanState()
+	}
 
-			//groupAndAggrChunk.print()
+	for {
 
-			//aggrStatesChunk.print()
-			filterInputTypes := make([]common.LType, 0)
-			filterInputTypes = append(filterInputTypes, run.hAggr._groupedAggrData._aggrReturnTypes...)
-			filterInputChunk := &chunk.Chunk{}
-			filterInputChunk.Init(filterInputTypes, util.DefaultVectorSize)
-			for i := 0; i < len(run.hAggr._groupedAggrData._aggregates); i++ {
-				filterInputChunk.Data[i].Reference(groupAndAggrChunk.Data[run.hAggr._groupedAggrData.GroupCount()+i])
-			}
-			filterInputChunk.SetCard(groupAndAggrChunk.Card())
-			var count int
-			count, err = state.filterExec.executeSelect([]*chunk.Chunk{childChunk, nil, filterInputChunk}, state.filterSel)
-			if err != nil {
-				return InvalidOpResult, err
+		if run.state.ungroupAggr {
+			if run.state.ungroupAggrDone {
+				return SrcResDone, nil
 			}
+			run.state.ungroupAggrDone = true
+		}
 
-			if count == 0 {
-				run.state.haScanState._filteredCnt1 += childChunk.Card() - count
-				continue
-			}
+		groupAddAggrTypes := make([]common.LType, 0)
+		groupAddAggrTypes = append(groupAddAggrTypes, run.hAggr._groupedAggrData._groupTypes...)
+		groupAddAggrTypes = append(groupAddAggrTypes, run.hAggr._groupedAggrData._aggrReturnTypes...)
+		for range run.hAggr._groupedAggrData._groupingFuncs {
+			groupAddAggrTypes = append(groupAddAggrTypes, common.BigintType())
+		}
+		groupAndAggrChunk := &chunk.Chunk{}
+		groupAndAggrChunk.Init(groupAddAggrTypes, util.DefaultVectorSize)
+		childChunk := &chunk.Chunk{}
+		childChunk.Init(run.hAggr._groupedAggrData._childrenOutputTypes, util.DefaultVectorSize)
+		res = run.hAggr.GetData(run.state.haScanState, groupAndAggrChunk, childChunk)
+		if res == InvalidOpResult {
+			return SrcResDone, nil
+		}
+		if res == Done {
+			break
+		}
+		if len(run.hAggr._groupedAggrData._groupingFuncs) != 0 {
+			groupAndAggrChunk = run.reorderAggrs(groupAndAggrChunk)
+			groupAddAggrTypes = make([]common.LType, 0)
+			groupAddAggrTypes = append(groupAddAggrTypes, run.hAggr._groupedAggrData._groupTypes...)
+			groupAddAggrTypes = append(groupAddAggrTypes, run.state.aggrTypes...)
+		}
 
-			var childChunk2 *chunk.Chunk
-			var aggrStatesChunk2 *chunk.Chunk
-			var filtered int
-			if count == childChunk.Card() {
-				childChunk2 = childChunk
-				aggrStatesChunk2 = groupAndAggrChunk
+		x := childChunk.Card()
 
-				util.AssertFunc(childChunk.Card() == childChunk2.Card())
-				util.AssertFunc(groupAndAggrChunk.Card() == aggrStatesChunk2.Card())
-				util.AssertFunc(childChunk2.Card() == aggrStatesChunk2.Card())
-			} else {
-				filtered = childChunk.Card() - count
-				run.state.haScanState._filteredCnt2 += filtered
+		//3.get group by + aggr states for the group
 
-				childChunkIndice := make([]int, 0)
-				for i := 0; i < childChunk.ColumnCount(); i++ {
-					childChunkIndice = append(childChunkIndice, i)
-				}
-				aggrStatesChunkIndice := make([]int, 0)
-				for i := 0; i < groupAndAggrChunk.ColumnCount(); i++ {
-					aggrStatesChunkIndice = append(aggrStatesChunkIndice, i)
-				}
-				childChunk2 = &chunk.Chunk{}
-				childChunk2.Init(run.children[0].outputTypes, util.DefaultVectorSize)
-				aggrStatesChunk2 = &chunk.Chunk{}
-				aggrStatesChunk2.Init(groupAddAggrTypes, util.DefaultVectorSize)
-
-				//slice
-				childChunk2.SliceIndice(childChunk, state.filterSel, count, 0, childChunkIndice)
-				aggrStatesChunk2.SliceIndice(groupAndAggrChunk, state.filterSel, count, 0, aggrStatesChunkIndice)
-
-				util.AssertFunc(count == childChunk2.Card())
-				util.AssertFunc(count == aggrStatesChunk2.Card())
-				util.AssertFunc(childChunk2.Card() == aggrStatesChunk2.Card())
-			}
-
-			var aggrStatesChunk3 *chunk.Chunk
-			if run.state.ungroupAggr {
-				//remove const groupby expr
-				aggrStatesTyps := make([]common.LType, 0)
-				aggrStatesTyps = append(aggrStatesTyps, run.hAggr._groupedAggrData._aggrReturnTypes...)
-				aggrStatesChunk3 = &chunk.Chunk{}
-				aggrStatesChunk3.Init(aggrStatesTyps, util.DefaultVectorSize)
-
-				for i := 0; i < len(run.hAggr._groupedAggrData._aggregates); i++ {
-					aggrStatesChunk3.Data[i].Reference(aggrStatesChunk2.Data[run.hAggr._groupedAggrData.GroupCount()+i])
-				}
-				aggrStatesChunk3.SetCard(aggrStatesChunk2.Card())
-			} else {
-				aggrStatesChunk3 = aggrStatesChunk2
-			}
+		//4.eval the filter on (child chunk + aggr states)
 
-			//5. eval the output
-			err = run.state.outputExec.executeExprs([]*chunk.Chunk{childChunk2, nil, aggrStatesChunk3}, output)
-			if err != nil {
-				return InvalidOpResult, err
+		//childChunk.print()
+
+		//groupAndAggrChunk.print()
+
+		//aggrStatesChunk.print()
+		filterInputTypes := make([]common.LType, 0)
+		filterInputTypes = append(filterInputTypes, run.state.aggrTypes...)
+		filterInputChunk := &chunk.Chunk{}
+		filterInputChunk.Init(filterInputTypes, util.DefaultVectorSize)
+		for i := 0; i < len(run.state.aggrTypes); i++ {
+			filterInputChunk.Data[i].Reference(groupAndAggrChunk.Data[run.hAggr._groupedAggrData.GroupCount()+i])
+		}
+		filterInputChunk.SetCard(groupAndAggrChunk.Card())
+		var count int
+		count, err = state.filterExec.executeSelect([]*chunk.Chunk{childChunk, nil, filterInputChunk}, state.filterSel)
+		if err != nil {
+			return SrcResDone, err
+		}
+
+		if count == 0 {
+			run.state.haScanState._filteredCnt1 += childChunk.Card() - count
+			continue
+		}
+
+		var childChunk2 *chunk.Chunk
+		var aggrStatesChunk2 *chunk.Chunk
+		var filtered int
+		if count == childChunk.Card() {
+			childChunk2 = childChunk
+			aggrStatesChunk2 = groupAndAggrChunk
+
+			util.AssertFunc(childChunk.Card() == childChunk2.Card())
+			util.AssertFunc(groupAndAggrChunk.Card() == aggrStatesChunk2.Card())
+			util.AssertFunc(childChunk2.Card() == aggrStatesChunk2.Card())
+		} else {
+			filtered = childChunk.Card() - count
+			run.state.haScanState._filteredCnt2 += filtered
+
+			childChunkIndice := make([]int, 0)
+			for i := 0; i < childChunk.ColumnCount(); i++ {
+				childChunkIndice = append(childChunkIndice, i)
 			}
-			if filtered == 0 {
-				util.AssertFunc(filtered == 0)
-				util.AssertFunc(output.Card() == childChunk2.Card())
-				util.AssertFunc(x >= childChunk2.Card())
+			aggrStatesChunkIndice := make([]int, 0)
+			for i := 0; i < groupAndAggrChunk.ColumnCount(); i++ {
+				aggrStatesChunkIndice = append(aggrStatesChunkIndice, i)
 			}
-			util.AssertFunc(output.Card()+filtered == childChunk.Card())
-			util.AssertFunc(x == childChunk.Card())
-			util.AssertFunc(output.Card() == childChunk2.Card())
+			childChunk2 = &chunk.Chunk{}
+			childChunk2.Init(run.hAggr._groupedAggrData._childrenOutputTypes, util.DefaultVectorSize)
+			aggrStatesChunk2 = &chunk.Chunk{}
+			aggrStatesChunk2.Init(groupAddAggrTypes, util.DefaultVectorSize)
 
-			run.state.haScanState._outputCnt += output.Card()
-			run.state.haScanState._childCnt2 += childChunk.Card()
-			run.state.haScanState._childCnt3 += x
-			if output.Card() > 0 {
+			//slice
+			childChunk2.SliceIndice(childChunk, state.filterSel, count, 0, childChunkIndice)
+			aggrStatesChunk2.SliceIndice(groupAndAggrChunk, state.filterSel, count, 0, aggrStatesChunkIndice)
 
-				//output.print()
-				return haveMoreOutput, nil
+			util.AssertFunc(count == childChunk2.Card())
+			util.AssertFunc(count == aggrStatesChunk2.Card())
+			util.AssertFunc(childChunk2.Card() == aggrStatesChunk2.Card())
+		}
+
+		var aggrStatesChunk3 *chunk.Chunk
+		if run.state.ungroupAggr {
+			//remove const groupby expr
+			aggrStatesTyps := make([]common.LType, 0)
+			aggrStatesTyps = append(aggrStatesTyps, run.state.aggrTypes...)
+			aggrStatesChunk3 = &chunk.Chunk{}
+			aggrStatesChunk3.Init(aggrStatesTyps, util.DefaultVectorSize)
+
+			for i := 0; i < len(run.state.aggrTypes); i++ {
+				aggrStatesChunk3.Data[i].Reference(aggrStatesChunk2.Data[run.hAggr._groupedAggrData.GroupCount()+i])
 			}
+			aggrStatesChunk3.SetCard(aggrStatesChunk2.Card())
+		} else {
+			aggrStatesChunk3 = aggrStatesChunk2
+		}
+
+		//5. eval the output
+		err = run.state.outputExec.executeExprs([]*chunk.Chunk{childChunk2, nil, aggrStatesChunk3}, output)
+		if err != nil {
+			return SrcResDone, err
+		}
+		if filtered == 0 {
+			util.AssertFunc(filtered == 0)
+			util.AssertFunc(output.Card() == childChunk2.Card())
+			util.AssertFunc(x >= childChunk2.Card())
+		}
+		util.AssertFunc(output.Card()+filtered == childChunk.Card())
+		util.AssertFunc(x == childChunk.Card())
+		util.AssertFunc(output.Card() == childChunk2.Card())
+
+		run.state.haScanState._outputCnt += output.Card()
+		run.state.haScanState._childCnt2 += childChunk.Card()
+		run.state.haScanState._childCnt3 += x
+		if output.Card() > 0 {
+
+			//output.print()
+			return SrcResHaveMoreOutput, nil
 		}
 	}
 	fmt.Println("scan cnt",
@@ -1580,10 +1870,13 @@ func (run *Runner) aggrExec(output *chunk.Chunk, state *OperatorState) (Operator
 		"filteredCnt2",
 		run.state.haScanState._filteredCnt2,
 	)
-	return Done, nil
+	return SrcResDone, nil
 }
 
 func (run *Runner) aggrClose() error {
+	if run.hAggr != nil {
+		run.hAggr.Close()
+	}
 	run.hAggr = nil
 	return nil
 }
@@ -1594,6 +1887,21 @@ func (run *Runner) joinInit() error {
 	}
 	if len(run.op.OnConds) != 0 {
 		run.hjoin = NewHashJoin(run.op, run.op.OnConds)
+		run.hjoin._memoryLimit = run.joinMemoryLimit()
+		run.hjoin._ctxCheck = run.checkContext
+		if ht, has := run.pipe.sharedHT(run.op); has {
+			//probe the shared hash table
+			run.hjoin._ht = ht
+			run.hjoin._hjs = HJS_PROBE
+		} else if run.pipe != nil {
+			//the local build side of the worker.
+			//the workers share the budget.
+			run.hjoin._memoryLimit /= run.threads()
+		} else {
+			for _, filter := range run.op.joinFilters {
+				filter.reset()
+			}
+		}
 	} else {
 		types := make([]common.LType, len(run.op.Children[1].Outputs))
 		for i, e := range run.op.Children[1].Outputs {
@@ -1634,6 +1942,9 @@ func (run *Runner) hashJoinExec(output *chunk.Chunk, state *OperatorState) (Oper
 	if res == InvalidOpResult {
 		return InvalidOpResult, nil
 	}
+	if run.hjoin._hjs == HJS_PARTITION {
+		return run.joinFlush(output)
+	}
 	//2. probe stage
 	//probe
 	if run.hjoin._hjs == HJS_BUILD || run.hjoin._hjs == HJS_PROBE {
@@ -1643,17 +1954,13 @@ func (run *Runner) hashJoinExec(output *chunk.Chunk, state *OperatorState) (Oper
 
 		//continue unfinished can
 		if run.hjoin._scan != nil {
-			nextChunk := chunk.Chunk{}
-			nextChunk.Init(run.hjoin._scanNextTyps, util.DefaultVectorSize)
-			run.hjoin._scan.Next(run.hjoin._joinKeys, run.hjoin._scan._leftChunk, &nextChunk)
-			if nextChunk.Card() > 0 {
-				err = run.evalJoinOutput(&nextChunk, output)
-				if err != nil {
-					return 0, err
-				}
+			res, err = run.joinExecute(nil, output)
+			if err != nil {
+				return 0, err
+			}
+			if res == haveMoreOutput {
 				return haveMoreOutput, nil
 			}
-			run.hjoin._scan = nil
 		}
 
 		//probe
@@ -1664,6 +1971,10 @@ func (run *Runner) hashJoinExec(output *chunk.Chunk, state *OperatorState) (Oper
 		}
 		switch res {
 		case Done:
+			if run.hjoin.spilled() {
+				run.hjoin._hjs = HJS_PARTITION
+				return run.joinFlush(output)
+			}
 			return Done, nil
 		case InvalidOpResult:
 			return InvalidOpResult, nil
@@ -1672,30 +1983,53 @@ func (run *Runner) hashJoinExec(output *chunk.Chunk, state *OperatorState) (Oper
 		//fmt.Println("left chunk", leftChunk.card())
 		//leftChunk.print()
 
-		run.hjoin._joinKeys.Reset()
-		err = run.hjoin._probExec.executeExprs([]*chunk.Chunk{leftChunk, nil, nil}, run.hjoin._joinKeys)
+		_, err = run.joinExecute(leftChunk, output)
 		if err != nil {
 			return 0, err
 		}
-		run.hjoin._scan = run.hjoin._ht.Probe(run.hjoin._joinKeys)
-		run.hjoin._scan._leftChunk = leftChunk
-		nextChunk := chunk.Chunk{}
-		nextChunk.Init(run.hjoin._scanNextTyps, util.DefaultVectorSize)
-		run.hjoin._scan.Next(run.hjoin._joinKeys, run.hjoin._scan._leftChunk, &nextChunk)
-		if nextChunk.Card() > 0 {
-			err = run.evalJoinOutput(&nextChunk, output)
-			if err != nil {
-				return 0, err
-			}
-			return haveMoreOutput, nil
-		} else {
-			run.hjoin._scan = nil
-		}
 		return haveMoreOutput, nil
 	}
 	return 0, nil
 }
 
+// joinExecute probes the hash table with the input.
+// It returns haveMoreOutput if the input has more results.
+// The driver calls it again for them.
+func (run *Runner) joinExecute(input, output *chunk.Chunk) (OperatorResult, error) {
+	nextChunk := chunk.Chunk{}
+	nextChunk.Init(run.hjoin._scanNextTyps, util.DefaultVectorSize)
+	res, err := run.hjoin.Execute(input, &nextChunk)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if res == haveMoreOutput {
+		err = run.evalJoinOutput(&nextChunk, output)
+		if err != nil {
+			return InvalidOpResult, err
+		}
+	}
+	return res, nil
+}
+
+// joinFlush joins the spilled partitions after the last input of the left child.
+// It returns haveMoreOutput if there are more results.
+func (run *Runner) joinFlush(output *chunk.Chunk) (OperatorResult, error) {
+	nextChunk := chunk.Chunk{}
+	nextChunk.Init(run.hjoin._scanNextTyps, util.DefaultVectorSize)
+	res, err := run.hjoin.ExecutePartitions(&nextChunk)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if res != haveMoreOutput {
+		return Done, nil
+	}
+	err = run.evalJoinOutput(&nextChunk, output)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	return haveMoreOutput, nil
+}
+
 func (run *Runner) crossProductExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
 	//1. Build Hash Table on the right child
 	res, err := run.crossBuild(state)
@@ -1822,8 +2156,6 @@ func (run *Runner) joinBuildHashTable(state *OperatorState) (OperatorResult, err
 	var err error
 	var res OperatorResult
 	if run.hjoin._hjs == HJS_INIT {
-		run.hjoin._hjs = HJS_BUILD
-		cnt := 0
 		for {
 			rightChunk := &chunk.Chunk{}
 			res, err = run.execChild(run.children[1], rightChunk, state)
@@ -1834,27 +2166,71 @@ func (run *Runner) joinBuildHashTable(state *OperatorState) (OperatorResult, err
 				return InvalidOpResult, nil
 			}
 			if res == Done {
-				run.hjoin._ht.Finalize()
 				break
 			}
 
 			//fmt.Println("right child chunk")
 			//rightChunk.print()
 
-			cnt++
-			err = run.hjoin.Build(rightChunk)
+			_, err = run.joinSink(rightChunk)
 			if err != nil {
 				return 0, err
 			}
 		}
-		fmt.Println("right hash table count", run.hjoin._ht.count())
-		run.hjoin._hjs = HJS_PROBE
+		err = run.joinFinalize()
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	return Done, nil
 }
 
+// joinSink builds the hash table with the chunk of the right child
+func (run *Runner) joinSink(input *chunk.Chunk) (SinkResult, error) {
+	run.hjoin._hjs = HJS_BUILD
+	err := run.hjoin.Build(input)
+	if err != nil {
+		return SinkResDone, err
+	}
+	if run.pipe == nil {
+		//the filters of the local build side are updated in the combine
+		run.updateJoinFilters(run.hjoin._joinKeys)
+	}
+	return SinkResNeedMoreInput, nil
+}
+
+// joinCombine builds the hash table with the local build side of the worker
+func (run *Runner) joinCombine(local *Runner) error {
+	run.hjoin._hjs = HJS_BUILD
+	if local.hjoin._ht._hasNull {
+		run.hjoin._ht._hasNull = true
+	}
+	defer local.hjoin._ht.Close()
+	return local.hjoin.scanBuild(func(keys, payload *chunk.Chunk) error {
+		err := run.hjoin.build(keys, payload)
+		if err != nil {
+			return err
+		}
+		run.updateJoinFilters(keys)
+		return nil
+	})
+}
+
+func (run *Runner) joinFinalize() error {
+	err := run.hjoin.Finalize()
+	if err != nil {
+		return err
+	}
+	run.finishJoinFilters()
+	run.hjoin._hjs = HJS_PROBE
+	return nil
+}
+
 func (run *Runner) joinClose() error {
+	if run.hjoin != nil {
+		run.hjoin.Close()
+	}
 	run.hjoin = nil
 	run.cross = nil
 	return nil
@@ -1887,26 +2263,85 @@ func (run *Runner) projExec(output *chunk.Chunk, state *OperatorState) (Operator
 		}
 	}
 
+	_, err = run.projExecute(childChunk, output)
+	if err != nil {
+		return 0, err
+	}
+
+	return res, nil
+}
+
+func (run *Runner) projExecute(input, output *chunk.Chunk) (OperatorResult, error) {
 	//project list
 	projChunk := &chunk.Chunk{}
 	projChunk.Init(run.state.projTypes, util.DefaultVectorSize)
-	err = run.state.projExec.executeExprs([]*chunk.Chunk{childChunk, nil, nil}, projChunk)
+	err := run.state.projExec.executeExprs([]*chunk.Chunk{input, nil, nil}, projChunk)
 	if err != nil {
-		return 0, err
+		return InvalidOpResult, err
 	}
 
-	err = run.state.outputExec.executeExprs([]*chunk.Chunk{childChunk, nil, projChunk}, output)
+	err = run.state.outputExec.executeExprs([]*chunk.Chunk{input, nil, projChunk}, output)
 	if err != nil {
-		return 0, err
+		return InvalidOpResult, err
 	}
-
-	return res, nil
+	return NeedMoreInput, nil
 }
 func (run *Runner) projClose() error {
 
 	return nil
 }
 
+func (run *Runner) unnestInit() error {
+	run.unnest = NewUnnest(run.children[0].outputTypes, run.op.Projects)
+	run.state = &OperatorState{
+		outputExec: NewExprExec(run.op.Outputs...),
+	}
+	return nil
+}
+
+func (run *Runner) unnestExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+	un := run.unnest
+	for {
+		if un._input == nil {
+			childChunk := &chunk.Chunk{}
+			res, err := run.execChild(run.children[0], childChunk, state)
+			if err != nil {
+				return 0, err
+			}
+			if res == InvalidOpResult || res == Done {
+				return res, nil
+			}
+			err = un.SetInput(childChunk)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		sel := chunk.NewSelectVector(util.DefaultVectorSize)
+		elems := &chunk.Chunk{}
+		cnt := un.Expand(sel, elems)
+		if cnt == 0 {
+			//next child chunk
+			un._input = nil
+			continue
+		}
+		childChunk := &chunk.Chunk{}
+		childChunk.Init(un._childTypes, util.DefaultVectorSize)
+		childChunk.Slice(un._input, sel, cnt, 0)
+
+		err := run.state.outputExec.executeExprs([]*chunk.Chunk{childChunk, nil, elems}, output)
+		if err != nil {
+			return 0, err
+		}
+		return haveMoreOutput, nil
+	}
+}
+
+func (run *Runner) unnestClose() error {
+	run.unnest = nil
+	return nil
+}
+
 func (run *Runner) scanInit() error {
 	var err error
 	switch run.op.ScanTyp {
@@ -1929,6 +2364,7 @@ func (run *Runner) scanInit() error {
 					return fmt.Errorf("no such column %s in %s.%s", col, run.op.Database, run.op.Table)
 				}
 			}
+			run.tableFilters = pushdownTableFilters(run.op.Filters)
 		}
 		{
 			//read schema
@@ -1984,38 +2420,36 @@ func (run *Runner) scanInit() error {
 			}
 		}
 		run.readedColTyps = run.op.Types
-	case ScanTypeCopyFrom:
+	case ScanTypeSeries:
+		run.colIndice = []int{0}
+		run.readedColTyps = run.op.Types
+		run.series = nil
+	case ScanTypeCopyFrom, ScanTypeTableFunc:
 		run.colIndice = run.op.ScanInfo.ColumnIds
 		run.readedColTyps = run.op.ScanInfo.ReturnedTypes
-		//open data file
-		switch run.op.ScanInfo.Format {
-		case "parquet":
-			run.pqFile, err = pqLocal.NewLocalFileReader(run.op.ScanInfo.FilePath)
-			if err != nil {
-				return err
-			}
-
-			run.pqReader, err = pqReader.NewParquetColumnReader(run.pqFile, 1)
-			if err != nil {
-				return err
-			}
-		case "csv":
-			run.tablePath = run.op.ScanInfo.FilePath
-			run.dataFile, err = os.OpenFile(run.tablePath, os.O_RDONLY, 0755)
-			if err != nil {
-				return err
+		if run.op.ScanTyp == ScanTypeTableFunc {
+			//only the needed columns are decoded
+			run.colIndice = make([]int, 0)
+			run.readedColTyps = make([]common.LType, 0)
+			for _, col := range run.op.Columns {
+				if idx, has := run.op.ColName2Idx[col]; has {
+					run.colIndice = append(run.colIndice, idx)
+					run.readedColTyps = append(run.readedColTyps, run.op.ScanInfo.ReturnedTypes[idx])
+				} else {
+					return fmt.Errorf("no such column %s in %s", col, run.op.Table)
+				}
 			}
-
-			comma := ','
-			if commaOpt := getFormatFun("delimiter", run.op.ScanInfo.Opts); commaOpt != nil {
-				comma = int32(commaOpt.Opt[0])
+			if len(run.colIndice) == 0 {
+				//the first column is read for counting the rows
+				run.colIndice = append(run.colIndice, 0)
+				run.readedColTyps = append(run.readedColTyps, run.op.ScanInfo.ReturnedTypes[0])
 			}
-
-			//init csv reader
-			run.reader = csv.NewReader(run.dataFile)
-			run.reader.Comma = comma
-		default:
-			panic("usp format")
+		}
+		run.scanFiles = run.op.ScanInfo.files()
+		run.fileIdx = 0
+		err = run.openScanFile(run.scanFiles[0])
+		if err != nil {
+			return err
 		}
 	default:
 		panic("usp")
@@ -2049,6 +2483,17 @@ func (run *Runner) scanExec(output *chunk.Chunk, state *OperatorState) (Operator
 	return haveMoreOutput, nil
 }
 
+func (run *Runner) scanGetData(output *chunk.Chunk) (SourceResult, error) {
+	res, err := run.scanExec(output, run.state)
+	if err != nil {
+		return SrcResDone, err
+	}
+	if res == Done {
+		return SrcResDone, nil
+	}
+	return SrcResHaveMoreOutput, nil
+}
+
 func (run *Runner) scanRows(output *chunk.Chunk, state *OperatorState, maxCnt int) (bool, error) {
 	if maxCnt == 0 {
 		return false, nil
@@ -2068,16 +2513,30 @@ func (run *Runner) scanRows(output *chunk.Chunk, state *OperatorState, maxCnt in
 		{
 			if run.state.tableScanState == nil {
 				run.state.tableScanState = storage.NewTableScanState()
+				run.state.tableScanState.SetFilters(run.scanFilters())
 				colIds := make([]storage.IdxType, 0)
 				for _, colId := range run.colIndice {
 					colIds = append(colIds, storage.IdxType(colId))
 				}
-				run.tabEnt.GetStorage().InitScan(
-					run.Txn,
-					run.state.tableScanState,
-					colIds)
+				if run.pipe != nil {
+					run.tabEnt.GetStorage().InitLocalScan(
+						run.state.tableScanState,
+						colIds)
+				} else {
+					run.tabEnt.GetStorage().InitScan(
+						run.Txn,
+						run.state.tableScanState,
+						colIds)
+				}
+				run.state.tableScanState.SetSample(run.op.Sample.scanSample())
+			}
+			if run.op.Sample != nil && run.op.Sample.Method == SampleReservoir {
+				run.readReservoir(readed, maxCnt)
+			} else if run.pipe != nil {
+				run.readMorsels(readed)
+			} else {
+				run.tabEnt.GetStorage().Scan(run.Txn, readed, run.state.tableScanState)
 			}
-			run.tabEnt.GetStorage().Scan(run.Txn, readed, run.state.tableScanState)
 		}
 		{
 			//read table
@@ -2101,21 +2560,30 @@ func (run *Runner) scanRows(output *chunk.Chunk, state *OperatorState, maxCnt in
 		if err != nil {
 			return false, err
 		}
-	case ScanTypeCopyFrom:
-		//read table
-		switch run.op.ScanInfo.Format {
-		case "parquet":
-			err = run.readParquetTable(readed, state, maxCnt)
+	case ScanTypeSeries:
+		err = run.readSeries(readed, maxCnt)
+		if err != nil {
+			return false, err
+		}
+	case ScanTypeCopyFrom, ScanTypeTableFunc:
+		for {
+			err = run.readScanFile(readed, state, maxCnt)
+			if err != nil {
+				return false, fmt.Errorf("read %s: %w", run.scanFiles[run.fileIdx], err)
+			}
+			if readed.Card() != 0 || run.fileIdx+1 >= len(run.scanFiles) {
+				break
+			}
+			//next file
+			err = run.closeScanFile()
 			if err != nil {
 				return false, err
 			}
-		case "csv":
-			err = run.readCsvTable(readed, state, maxCnt)
+			run.fileIdx++
+			err = run.openScanFile(run.scanFiles[run.fileIdx])
 			if err != nil {
 				return false, err
 			}
-		default:
-			panic("usp format")
 		}
 	default:
 		panic("usp")
@@ -2129,6 +2597,7 @@ func (run *Runner) scanRows(output *chunk.Chunk, state *OperatorState, maxCnt in
 		run.maxRows += readed.Card()
 	}
 
+	run.runJoinFilters(readed)
 	err = run.runFilterExec(readed, output, true)
 	if err != nil {
 		return false, err
@@ -2140,7 +2609,7 @@ func (run *Runner) scanClose() error {
 	switch run.op.ScanTyp {
 	case ScanTypeTable:
 		{
-
+			run.reservoir = nil
 		}
 		{
 			//switch run.cfg.Tpch1g.Data.Format {
@@ -2157,23 +2626,100 @@ func (run *Runner) scanClose() error {
 
 	case ScanTypeValuesList:
 		return nil
-	case ScanTypeCopyFrom:
-		switch run.op.ScanInfo.Format {
-		case "csv":
-			run.reader = nil
-			return run.dataFile.Close()
-		case "parquet":
-			run.pqReader.ReadStop()
-			return run.pqFile.Close()
-		default:
-			panic("usp format")
-		}
+	case ScanTypeSeries:
+		run.series = nil
+		return nil
+	case ScanTypeCopyFrom, ScanTypeTableFunc:
+		return run.closeScanFile()
 	default:
 		panic("usp")
 	}
 	return nil
 }
+
+func (run *Runner) openScanFile(path string) error {
+	var err error
+	switch run.op.ScanInfo.Format {
+	case "parquet":
+		run.pqFile, err = pqLocal.NewLocalFileReader(path)
+		if err != nil {
+			return err
+		}
+
+		run.pqReader, err = pqReader.NewParquetColumnReader(run.pqFile, 1)
+		if err != nil {
+			return err
+		}
+	case "csv":
+		run.tablePath = path
+		run.dataFile, err = os.OpenFile(run.tablePath, os.O_RDONLY, 0755)
+		if err != nil {
+			return err
+		}
+
+		comma := ','
+		if commaOpt := getFormatFun("delimiter", run.op.ScanInfo.Opts); commaOpt != nil {
+			comma = int32(commaOpt.Opt[0])
+		}
+
+		//init csv reader
+		run.reader = csv.NewReader(run.dataFile)
+		run.reader.Comma = comma
+		if headerOpt := getFormatFun("header", run.op.ScanInfo.Opts); headerOpt != nil && headerOpt.Opt == "true" {
+			//skip the header of every file
+			_, err = run.reader.Read()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+		}
+	case "json", "ndjson":
+		run.tablePath = path
+		run.dataFile, err = os.OpenFile(run.tablePath, os.O_RDONLY, 0755)
+		if err != nil {
+			return err
+		}
+		//newline delimited objects or the array of objects
+		run.jsonDecoder = json.NewDecoder(bufio.NewReader(run.dataFile))
+	default:
+		panic("usp format")
+	}
+	return nil
+}
+
+func (run *Runner) readScanFile(output *chunk.Chunk, state *OperatorState, maxCnt int) error {
+	switch run.op.ScanInfo.Format {
+	case "parquet":
+		return run.readParquetTable(output, state, maxCnt)
+	case "csv":
+		return run.readCsvTable(output, state, maxCnt)
+	case "json", "ndjson":
+		return run.readJsonTable(output, maxCnt)
+	default:
+		panic("usp format")
+	}
+}
+
+func (run *Runner) closeScanFile() error {
+	switch run.op.ScanInfo.Format {
+	case "csv":
+		run.reader = nil
+		return run.dataFile.Close()
+	case "json", "ndjson":
+		run.jsonDecoder = nil
+		return run.dataFile.Close()
+	case "parquet":
+		run.pqReader.ReadStop()
+		run.pqColumns = nil
+		return run.pqFile.Close()
+	default:
+		panic("usp format")
+	}
+}
+
 func (run *Runner) readParquetTable(output *chunk.Chunk, state *OperatorState, maxCnt int) error {
+	if hasNestedType(run.readedColTyps) || pqHasGroup(run.pqReader.SchemaHandler) {
+		return run.readParquetNested(output, maxCnt)
+	}
 	rowCont := -1
 	var err error
 	var values []interface{}
@@ -2254,6 +2800,87 @@ func (run *Runner) readCsvTable(output *chunk.Chunk, state *OperatorState, maxCn
 	return nil
 }
 
+// readJsonTable reads the objects and maps the keys onto the columns.
+// The missing key is NULL.
+func (run *Runner) readJsonTable(output *chunk.Chunk, maxCnt int) error {
+	dec := run.jsonDecoder
+	rowCont := 0
+	texts := make([][]*chunk.Value, len(run.colIndice))
+	for rowCont < maxCnt {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if tok == json.Delim('[') || tok == json.Delim(']') {
+			//the array of objects
+			continue
+		}
+		if tok != json.Delim('{') {
+			return fmt.Errorf("expect json object. got %v", tok)
+		}
+		obj := make(map[string]json.RawMessage)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			var value json.RawMessage
+			if err = dec.Decode(&value); err != nil {
+				return err
+			}
+			obj[strings.ToLower(key.(string))] = value
+		}
+		//end of the object
+		if _, err = dec.Token(); err != nil {
+			return err
+		}
+		for j, idx := range run.colIndice {
+			name := strings.ToLower(run.op.ScanInfo.Names[idx])
+			texts[j] = append(texts[j], jsonFieldText(obj[name], output.Data[j].Typ()))
+		}
+		rowCont++
+	}
+	for j := range run.colIndice {
+		vec := output.Data[j]
+		src := vec
+		if vec.Typ().Id != common.LTID_VARCHAR {
+			src = chunk.NewFlatVector(common.VarcharType(), util.DefaultVectorSize)
+		}
+		for i, text := range texts[j] {
+			src.SetValue(i, text)
+		}
+		if src != vec {
+			castInfo, err := castFuncs.GetCastFunc(src.Typ(), vec.Typ())
+			if err != nil {
+				return err
+			}
+			castInfo._fun(src, vec, rowCont, &CastParams{_strict: true})
+		}
+	}
+	output.SetCard(rowCont)
+	return nil
+}
+
+// jsonFieldText returns the text of the field that is cast to the column.
+// The string is unquoted except for the JSON column.
+func jsonFieldText(raw json.RawMessage, typ common.LType) *chunk.Value {
+	val := &chunk.Value{Typ: common.VarcharType()}
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		val.IsNull = true
+	case typ.Id == common.LTID_JSON:
+		val.Str = compactJSON(raw)
+	default:
+		ret := jsonExtractString(raw)
+		val.Str = ret.Str
+	}
+	return val
+}
+
 func (run *Runner) readValues(output *chunk.Chunk, state *OperatorState, maxCnt int) error {
 	if run.op.collection.Count() == 0 {
 		output.SetCap(0)
@@ -2277,6 +2904,10 @@ func fieldToValue(field string, lTyp common.LType) (*chunk.Value, error) {
 	val := &chunk.Value{
 		Typ: lTyp,
 	}
+	if len(field) == 0 && lTyp.Id != common.LTID_VARCHAR {
+		val.IsNull = true
+		return val, nil
+	}
 	switch lTyp.Id {
 	case common.LTID_DATE:
 		d, err := time.Parse(time.DateOnly, field)
@@ -2286,13 +2917,43 @@ func fieldToValue(field string, lTyp common.LType) (*chunk.Value, error) {
 		val.I64 = int64(d.Year())
 		val.I64_1 = int64(d.Month())
 		val.I64_2 = int64(d.Day())
-	case common.LTID_INTEGER:
+	case common.LTID_INTEGER, common.LTID_BIGINT:
 		val.I64, err = strconv.ParseInt(field, 10, 64)
 		if err != nil {
 			return nil, err
 		}
+	case common.LTID_FLOAT, common.LTID_DOUBLE:
+		val.F64, err = strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+	case common.LTID_BOOLEAN:
+		val.Bool, err = strconv.ParseBool(strings.ToLower(field))
+		if err != nil {
+			return nil, err
+		}
 	case common.LTID_VARCHAR:
 		val.Str = field
+	case common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		ts, err := common.ParseTimestamp(field)
+		if err != nil {
+			return nil, err
+		}
+		val.I64 = common.MicrosToTimestamp(lTyp.Id, ts)
+	case common.LTID_TIME:
+		val.I64, err = common.ParseTime(field)
+		if err != nil {
+			return nil, err
+		}
+	case common.LTID_INTERVAL:
+		interval, err := common.ParseInterval(field)
+		if err != nil {
+			return nil, err
+		}
+		val.I64 = int64(interval.Months)
+		val.I64_1 = int64(interval.Days)
+		val.I64_2 = interval.Micros
 	default:
 		panic("usp")
 	}
@@ -2303,7 +2964,30 @@ func parquetColToValue(field any, lTyp common.LType) (*chunk.Value, error) {
 	val := &chunk.Value{
 		Typ: lTyp,
 	}
+	if field == nil {
+		//the optional column
+		val.IsNull = true
+		return val, nil
+	}
 	switch lTyp.Id {
+	case common.LTID_BOOLEAN:
+		fVal, ok := field.(bool)
+		if !ok {
+			panic("usp")
+		}
+		val.Bool = fVal
+	case common.LTID_FLOAT:
+		fVal, ok := field.(float32)
+		if !ok {
+			panic("usp")
+		}
+		val.F64 = float64(fVal)
+	case common.LTID_DOUBLE:
+		fVal, ok := field.(float64)
+		if !ok {
+			panic("usp")
+		}
+		val.F64 = fVal
 	case common.LTID_DATE:
 		if _, ok := field.(int32); !ok {
 			panic("usp")
@@ -2331,7 +3015,7 @@ func parquetColToValue(field any, lTyp common.LType) (*chunk.Value, error) {
 		default:
 			panic("usp")
 		}
-	case common.LTID_VARCHAR:
+	case common.LTID_VARCHAR, common.LTID_JSON:
 		if _, ok := field.(string); !ok {
 			panic("usp")
 		}
@@ -2352,7 +3036,34 @@ func parquetColToValue(field any, lTyp common.LType) (*chunk.Value, error) {
 		default:
 			panic("usp")
 		}
-
+	case common.LTID_TIMESTAMP, common.LTID_TIMESTAMP_TZ,
+		common.LTID_TIMESTAMP_SEC, common.LTID_TIMESTAMP_MS, common.LTID_TIMESTAMP_NS:
+		//the int64 is in the unit of the column
+		fVal, ok := field.(int64)
+		if !ok {
+			panic("usp")
+		}
+		val.I64 = fVal
+	case common.LTID_TIME:
+		switch fVal := field.(type) {
+		case int32:
+			//TIME_MILLIS
+			val.I64 = int64(fVal) * 1000
+		case int64:
+			val.I64 = fVal
+		default:
+			panic("usp")
+		}
+	case common.LTID_INTERVAL:
+		//months, days and millis in little endian
+		fVal, ok := field.(string)
+		if !ok || len(fVal) != 12 {
+			panic("usp")
+		}
+		data := []byte(fVal)
+		val.I64 = int64(int32(binary.LittleEndian.Uint32(data)))
+		val.I64_1 = int64(int32(binary.LittleEndian.Uint32(data[4:])))
+		val.I64_2 = int64(binary.LittleEndian.Uint32(data[8:])) * 1000
 	default:
 		panic("usp")
 	}
@@ -0,0 +1,236 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+type SampleMethod int
+
+const (
+	// SampleSystem samples the whole vectors of the table
+	SampleSystem SampleMethod = iota
+	// SampleBernoulli samples the single rows
+	SampleBernoulli
+	// SampleReservoir samples the fixed count of rows
+	SampleReservoir
+)
+
+var sampleMethodToStr = map[SampleMethod]string{
+	SampleSystem:    "system",
+	SampleBernoulli: "bernoulli",
+	SampleReservoir: "reservoir",
+}
+
+// SampleInfo is the TABLESAMPLE on the table scan.
+// The same seed yields the same sample.
+type SampleInfo struct {
+	Method SampleMethod
+	// Percentage is for the system and the bernoulli
+	Percentage float64
+	// Rows is for the reservoir
+	Rows int64
+	Seed int64
+}
+
+func (info *SampleInfo) String() string {
+	if info.Method == SampleReservoir {
+		return fmt.Sprintf("%s(%d rows) repeatable(%d)", sampleMethodToStr[info.Method], info.Rows, info.Seed)
+	}
+	return fmt.Sprintf("%s(%v%%) repeatable(%d)", sampleMethodToStr[info.Method], info.Percentage, info.Seed)
+}
+
+// estimatedCard scales the count of the rows in the table
+func (info *SampleInfo) estimatedCard(card uint64) uint64 {
+	if info == nil {
+		return card
+	}
+	if info.Method == SampleReservoir {
+		return min(card, uint64(info.Rows))
+	}
+	return uint64(math.Ceil(float64(card) * info.Percentage / 100))
+}
+
+// scanSample returns the sample done by the storage. nil for the reservoir.
+func (info *SampleInfo) scanSample() *storage.ScanSample {
+	if info == nil || info.Method == SampleReservoir {
+		return nil
+	}
+	return &storage.ScanSample{
+		System:     info.Method == SampleSystem,
+		Percentage: info.Percentage,
+		Seed:       info.Seed,
+	}
+}
+
+// buildTableSample binds the TABLESAMPLE on the table.
+// The parser rewrites the USING SAMPLE into it.
+//
+//	t TABLESAMPLE bernoulli(10) REPEATABLE(42)
+//	t TABLESAMPLE system(10)
+//	t TABLESAMPLE reservoir(100)
+func (b *Builder) buildTableSample(sample *pg_query.RangeTableSample, ctx *BindContext, depth int) (*Expr, error) {
+	if len(sample.GetMethod()) != 1 {
+		return nil, fmt.Errorf("usp sample method")
+	}
+	name := strings.ToLower(sample.GetMethod()[0].GetString_().GetSval())
+	info := &SampleInfo{}
+	switch name {
+	case "system":
+		info.Method = SampleSystem
+	case "bernoulli":
+		info.Method = SampleBernoulli
+	case "reservoir":
+		info.Method = SampleReservoir
+	default:
+		return nil, fmt.Errorf("usp sample method %s", name)
+	}
+	if len(sample.GetArgs()) != 1 {
+		return nil, fmt.Errorf("sample method %s requires 1 argument", name)
+	}
+	arg, err := sampleConst(sample.GetArgs()[0])
+	if err != nil {
+		return nil, err
+	}
+	if info.Method == SampleReservoir {
+		if arg < 0 || arg != math.Trunc(arg) {
+			return nil, fmt.Errorf("the count of the sample rows must be a non-negative integer")
+		}
+		info.Rows = int64(arg)
+	} else {
+		if arg < 0 || arg > 100 {
+			return nil, fmt.Errorf("the sample percentage must be between 0 and 100")
+		}
+		info.Percentage = arg
+	}
+	if sample.GetRepeatable() != nil {
+		seed, err := sampleConst(sample.GetRepeatable())
+		if err != nil {
+			return nil, err
+		}
+		if seed != math.Trunc(seed) {
+			return nil, fmt.Errorf("the seed of the sample must be an integer")
+		}
+		info.Seed = int64(seed)
+	} else {
+		info.Seed = rand.Int63()
+	}
+
+	table, err := b.buildTable(sample.GetRelation(), ctx, depth)
+	if err != nil {
+		return nil, err
+	}
+	if table.Typ != ET_TABLE {
+		return nil, fmt.Errorf("TABLESAMPLE is only supported on the table")
+	}
+	table.Sample = info
+	return table, nil
+}
+
+// sampleConst returns the numeric constant in the TABLESAMPLE
+func sampleConst(node *pg_query.Node) (float64, error) {
+	neg := false
+	if aexpr := node.GetAExpr(); aexpr != nil && aexpr.GetLexpr() == nil &&
+		len(aexpr.GetName()) == 1 && aexpr.GetName()[0].GetString_().GetSval() == "-" {
+		neg = true
+		node = aexpr.GetRexpr()
+	}
+	ret := 0.0
+	switch {
+	case node.GetAConst().GetIval() != nil:
+		ret = float64(node.GetAConst().GetIval().GetIval())
+	case node.GetAConst().GetFval() != nil:
+		val, err := strconv.ParseFloat(node.GetAConst().GetFval().GetFval(), 64)
+		if err != nil {
+			return 0, err
+		}
+		ret = val
+	default:
+		return 0, fmt.Errorf("the argument of the sample must be a number")
+	}
+	if neg {
+		ret = -ret
+	}
+	return ret, nil
+}
+
+// reservoirState is the reservoir sample of the scan
+type reservoirState struct {
+	rows [][]*chunk.Value
+	//the next row to be read
+	pos int
+}
+
+// readReservoir reads the whole table into the reservoir at first.
+// Then it returns the rows in the reservoir.
+func (run *Runner) readReservoir(output *chunk.Chunk, maxCnt int) {
+	if run.reservoir == nil {
+		info := run.op.Sample
+		rnd := rand.New(rand.NewSource(info.Seed))
+		state := &reservoirState{
+			rows: make([][]*chunk.Value, 0),
+		}
+		seen := int64(0)
+		for {
+			data := &chunk.Chunk{}
+			data.Init(run.readedColTyps, storage.STANDARD_VECTOR_SIZE)
+			run.tabEnt.GetStorage().Scan(run.Txn, data, run.state.tableScanState)
+			if data.Card() == 0 {
+				break
+			}
+			for i := 0; i < data.Card(); i++ {
+				//algorithm R
+				pos := seen
+				if seen >= info.Rows {
+					pos = rnd.Int63n(seen + 1)
+				}
+				seen++
+				if pos >= info.Rows {
+					continue
+				}
+				row := make([]*chunk.Value, data.ColumnCount())
+				for j := range row {
+					row[j] = data.Data[j].GetValue(i)
+				}
+				if pos == int64(len(state.rows)) {
+					state.rows = append(state.rows, row)
+				} else {
+					state.rows[pos] = row
+				}
+			}
+		}
+		run.reservoir = state
+	}
+	state := run.reservoir
+	cnt := min(maxCnt, len(state.rows)-state.pos)
+	for i := 0; i < cnt; i++ {
+		row := state.rows[state.pos+i]
+		for j, val := range row {
+			output.Data[j].SetValue(i, val)
+		}
+	}
+	state.pos += cnt
+	output.SetCard(cnt)
+}
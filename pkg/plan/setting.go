@@ -0,0 +1,199 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// Setting is the SET name = value on the session
+type Setting struct {
+	Name  string
+	Value string
+}
+
+func (set *Setting) String() string {
+	return fmt.Sprintf("%s = %s", set.Name, set.Value)
+}
+
+// settings applies the value on the config of the session
+var settings = map[string]func(cfg *util.Config, value string) error{
+	"threads": func(cfg *util.Config, value string) error {
+		threads, err := strconv.Atoi(value)
+		if err != nil || threads < 1 {
+			return fmt.Errorf("threads must be a positive integer, but got %s", value)
+		}
+		cfg.Exec.Threads = threads
+		return nil
+	},
+	"aggregate_memory_limit": func(cfg *util.Config, value string) error {
+		limit, err := parseMemory(value)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.AggrMemoryLimit = limit
+		return nil
+	},
+	"sort_memory_limit": func(cfg *util.Config, value string) error {
+		limit, err := parseMemory(value)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.SortMemoryLimit = limit
+		return nil
+	},
+	"join_memory_limit": func(cfg *util.Config, value string) error {
+		limit, err := parseMemory(value)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.JoinMemoryLimit = limit
+		return nil
+	},
+	"memory_limit": func(cfg *util.Config, value string) error {
+		limit, err := parseMemory(value)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.MemoryLimit = limit
+		return nil
+	},
+	"statement_timeout": func(cfg *util.Config, value string) error {
+		timeout, err := parseTimeout(value)
+		if err != nil {
+			return err
+		}
+		cfg.Exec.StatementTimeout = timeout
+		return nil
+	},
+}
+
+var memoryUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+}
+
+// parseMemory parses the size of the memory like 1024, 64MB or 1GB
+func parseMemory(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	pos := strings.IndexFunc(value, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if pos < 0 {
+		pos = len(value)
+	}
+	num, err := strconv.ParseInt(value[:pos], 10, 64)
+	unit, has := memoryUnits[strings.TrimSpace(value[pos:])]
+	if err != nil || !has || num < 1 {
+		return 0, fmt.Errorf("invalid memory size %s", value)
+	}
+	return num * unit, nil
+}
+
+// parseTimeout parses the timeout like 5000, 5s or 1m into milliseconds.
+// The number without the unit is in milliseconds.
+func parseTimeout(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("invalid timeout %s", value)
+		}
+		return ms, nil
+	}
+	dur, err := time.ParseDuration(value)
+	if err != nil || dur < 0 {
+		return 0, fmt.Errorf("invalid timeout %s", value)
+	}
+	return dur.Milliseconds(), nil
+}
+
+// buildSet binds the SET statement.
+//
+//	SET threads = 4
+//	SET aggregate_memory_limit = '64MB'
+//	SET sort_memory_limit = '1GB'
+//	SET join_memory_limit = '256MB'
+//	SET memory_limit = '4GB'
+//	SET statement_timeout = '30s'
+func (b *Builder) buildSet(stmt *pg_query.VariableSetStmt) (*LogicalOperator, error) {
+	if stmt.GetKind() != pg_query.VariableSetKind_VAR_SET_VALUE {
+		return nil, fmt.Errorf("usp %s", stmt.GetKind())
+	}
+	name := strings.ToLower(stmt.GetName())
+	apply, has := settings[name]
+	if !has {
+		return nil, fmt.Errorf("unrecognized configuration parameter %s", name)
+	}
+	if len(stmt.GetArgs()) != 1 {
+		return nil, fmt.Errorf("SET %s takes only one argument", name)
+	}
+	val := stmt.GetArgs()[0].GetAConst()
+	value := ""
+	switch {
+	case val.GetIval() != nil:
+		value = strconv.FormatInt(int64(val.GetIval().GetIval()), 10)
+	case val.GetFval() != nil:
+		value = val.GetFval().GetFval()
+	case val.GetSval() != nil:
+		value = val.GetSval().GetSval()
+	default:
+		return nil, fmt.Errorf("invalid value of %s", name)
+	}
+	//check the value
+	err := apply(&util.Config{}, value)
+	if err != nil {
+		return nil, err
+	}
+	return &LogicalOperator{
+		Typ:     LOT_Set,
+		Setting: &Setting{Name: name, Value: value},
+	}, nil
+}
+
+func (run *Runner) setInit() error {
+	return nil
+}
+
+func (run *Runner) setExec(output *chunk.Chunk, state *OperatorState) (OperatorResult, error) {
+	err := settings[run.op.Setting.Name](run.cfg, run.op.Setting.Value)
+	if err != nil {
+		return InvalidOpResult, err
+	}
+	if run.op.Setting.Name == "memory_limit" {
+		//the buffer manager is shared by the sessions
+		err = storage.GBufferMgr.SetMemoryLimit(run.cfg.Exec.MemoryLimit)
+		if err != nil {
+			return InvalidOpResult, err
+		}
+	}
+	return Done, nil
+}
+
+func (run *Runner) setClose() error {
+	return nil
+}
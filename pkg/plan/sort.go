@@ -15,11 +15,13 @@
 package plan
 
 import (
+	"math"
 	"sort"
 	"unsafe"
 
 	"github.com/daviszhen/plan/pkg/chunk"
 	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
 	"github.com/daviszhen/plan/pkg/util"
 )
 
@@ -220,14 +222,29 @@ type RowDataBlock struct {
 	_count     int
 	//write offset for var len entry
 	_byteOffset int
+	//the memory reserved on the buffer manager
+	_size int
 }
 
 func (block *RowDataBlock) Close() {
 	util.CFree(block._ptr)
 	block._ptr = unsafe.Pointer(nil)
 	block._count = 0
+	storage.GBufferMgr.Release(int64(block._size))
+	block._size = 0
 }
 
+// reserve accounts the new size of the block on the buffer manager
+func (block *RowDataBlock) reserve(size int) {
+	err := storage.GBufferMgr.Reserve(int64(size - block._size))
+	if err != nil {
+		panic(err)
+	}
+	block._size = size
+}
+
+// Copy shares the memory with the block. The memory is released
+// by the block.
 func (block *RowDataBlock) Copy() *RowDataBlock {
 	ret := &RowDataBlock{_entrySize: block._entrySize}
 	ret._ptr = block._ptr
@@ -243,6 +260,7 @@ func NewRowDataBlock(capacity int, entrySize int) *RowDataBlock {
 		_entrySize: entrySize,
 	}
 	sz := max(BLOCK_SIZE, capacity*entrySize)
+	ret.reserve(sz)
 	ret._ptr = util.CMalloc(sz)
 	return ret
 }
@@ -269,6 +287,16 @@ func (d SortedData) Count() int {
 	return cnt
 }
 
+func (d *SortedData) Close() {
+	for _, blocks := range [][]*RowDataBlock{d._dataBlocks, d._heapBlocks} {
+		for _, block := range blocks {
+			block.Close()
+		}
+	}
+	d._dataBlocks = nil
+	d._heapBlocks = nil
+}
+
 func NewSortedData(typ SortedDataType, layout *RowLayout) *SortedData {
 	ret := &SortedData{
 		_type:   typ,
@@ -297,6 +325,15 @@ func NewSortedBlock(sortLayout *SortLayout, payloadLayout *RowLayout) *SortedBlo
 	return ret
 }
 
+func (sb *SortedBlock) Close() {
+	for _, block := range sb._radixSortingData {
+		block.Close()
+	}
+	sb._radixSortingData = nil
+	sb._blobSortingData.Close()
+	sb._payloadData.Close()
+}
+
 type BlockAppendEntry struct {
 	_basePtr unsafe.Pointer
 	_count   int
@@ -382,6 +419,7 @@ func (cdc *RowDataCollection) AppendToBlock(
 					appendCnt == 0 &&
 					entrySizes[i] > block._capacity {
 					block._capacity = entrySizes[i]
+					block.reserve(max(BLOCK_SIZE, block._capacity))
 					block._ptr = util.CRealloc(block._ptr, block._capacity)
 					dataPtr = block._ptr
 					appendCnt++
@@ -410,9 +448,21 @@ func (cdc *RowDataCollection) CreateBlock() *RowDataBlock {
 	return nb
 }
 
+func (cdc *RowDataCollection) SizeInBytes() int {
+	size := 0
+	for _, block := range cdc._blocks {
+		if block != nil {
+			size += max(BLOCK_SIZE, block._capacity*block._entrySize)
+		}
+	}
+	return size
+}
+
 func (cdc *RowDataCollection) Close() {
 	for _, block := range cdc._blocks {
-		block.Close()
+		if block != nil {
+			block.Close()
+		}
 	}
 	cdc._blocks = nil
 	cdc._count = 0
@@ -439,6 +489,14 @@ type LocalSort struct {
 	_addresses        *chunk.Vector
 	_sel              *chunk.SelectVector
 	_scanner          *PayloadScanner
+	//the heap blocks before the reorder. the sorted rows point to
+	//the strings in them
+	_heapBlocks []*RowDataBlock
+	//the data over it is sorted and spilled as the run
+	_memoryLimit int
+	_runs        []*sortedRun
+	_merger      *sortMerger
+	_ctxCheck    ctxCheck
 }
 
 func NewLocalSort(slayout *SortLayout, playout *RowLayout) *LocalSort {
@@ -541,10 +599,10 @@ func (ls *LocalSort) SinkChunk(sort, payload *chunk.Chunk) {
 	)
 }
 
-func (ls *LocalSort) Sort(reorderHeap bool) {
+func (ls *LocalSort) Sort(reorderHeap bool) error {
 	util.AssertFunc(ls._radixSortingData._count == ls._payloadData._count && reorderHeap)
 	if ls._radixSortingData._count == 0 {
-		return
+		return nil
 	}
 
 	lastBk := NewSortedBlock(ls._sortLayout, ls._payloadLayout)
@@ -563,12 +621,20 @@ func (ls *LocalSort) Sort(reorderHeap bool) {
 	payloadBlock := ls.ConcatenateBlocks(ls._payloadData)
 	lastBk._payloadData._dataBlocks = append(lastBk._payloadData._dataBlocks, payloadBlock)
 	//sort in memory
-	ls.SortInMemory()
+	err := ls.SortInMemory()
+	if err != nil {
+		return err
+	}
+	err = ls._ctxCheck.err()
+	if err != nil {
+		return err
+	}
 	//reorder
 	ls.ReOrder(reorderHeap)
+	return nil
 }
 
-func (ls *LocalSort) SortInMemory() {
+func (ls *LocalSort) SortInMemory() error {
 	lastSBk := util.Back(ls._sortedBlocks)
 	lastBlock := util.Back(lastSBk._radixSortingData)
 	count := lastBlock._count
@@ -588,6 +654,11 @@ func (ls *LocalSort) SortInMemory() {
 	var ties []bool
 	containsString := false
 	for i := 0; i < ls._sortLayout._columnCount; i++ {
+		//the query may be canceled between the passes
+		err := ls._ctxCheck.err()
+		if err != nil {
+			return err
+		}
 		sortingSize += ls._sortLayout._columnSizes[i]
 		containsString = containsString ||
 			ls._sortLayout._logicalTypes[i].GetInternalType().IsVarchar()
@@ -662,7 +733,7 @@ func (ls *LocalSort) SortInMemory() {
 		sortingSize = 0
 
 	}
-
+	return nil
 }
 
 func (ls *LocalSort) ReOrder(reorderHeap bool) {
@@ -719,6 +790,8 @@ func (ls *LocalSort) ReOrder2(
 
 	}
 
+	//the heap is not in the unordered block
+	unorderedDBlock.Close()
 	sd._dataBlocks = nil
 	sd._dataBlocks = append(
 		sd._dataBlocks,
@@ -750,6 +823,7 @@ func (ls *LocalSort) ReOrder2(
 		}
 
 		sd._heapBlocks = append(sd._heapBlocks, orderedHeapBlock)
+		ls._heapBlocks = append(ls._heapBlocks, heap._blocks...)
 		heap._blocks = nil
 		heap._count = 0
 	}
@@ -1026,6 +1100,41 @@ func RadixScatter(
 	v.ToUnifiedFormat(vcount, &vdata)
 	switch v.Typ().GetInternalType() {
 	case common.BOOL:
+		TemplatedRadixScatter[bool](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			boolEncoder{},
+		)
+	case common.INT8:
+		TemplatedRadixScatter[int8](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			int8Encoder{},
+		)
+	case common.INT16:
+		TemplatedRadixScatter[int16](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			int16Encoder{},
+		)
 	case common.INT32:
 		TemplatedRadixScatter[int32](
 			&vdata,
@@ -1062,6 +1171,54 @@ func RadixScatter(
 			offset,
 			decimalEncoder{},
 		)
+	case common.INT64:
+		TemplatedRadixScatter[int64](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			int64Encoder{},
+		)
+	case common.FLOAT:
+		TemplatedRadixScatter[float32](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			float32Encoder{},
+		)
+	case common.DOUBLE:
+		TemplatedRadixScatter[float64](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			float64Encoder{},
+		)
+	case common.INTERVAL:
+		TemplatedRadixScatter[common.Interval](
+			&vdata,
+			sel,
+			serCount,
+			keyLocs,
+			desc,
+			hasNull,
+			nullsFirst,
+			offset,
+			intervalEncoder{},
+		)
 	case common.DATE:
 		TemplatedRadixScatter[common.Date](
 			&vdata,
@@ -1248,6 +1405,17 @@ func Scatter(
 				layout,
 				chunk.DateScatterOp{},
 			)
+		case common.INTERVAL:
+			TemplatedScatter[common.Interval](
+				col,
+				rows,
+				sel,
+				count,
+				colOffset,
+				colNo,
+				layout,
+				chunk.IntervalScatterOp{},
+			)
 		case common.DECIMAL:
 			TemplatedScatter[common.Decimal](
 				col,
@@ -2550,16 +2718,6 @@ func (scan *RowDataCollectionScanner) Scan(output *chunk.Chunk) {
 
 	output.SetCard(count)
 	scan._totalScanned += scanned
-	if scan._flush {
-		for i := 0; i < scan._readState._blockIdx; i++ {
-			if scan._rows._blocks != nil {
-				scan._rows._blocks[i]._ptr = nil
-			}
-			if scan._heap._blocks != nil {
-				scan._heap._blocks[i]._ptr = nil
-			}
-		}
-	}
 }
 
 func (scan *RowDataCollectionScanner) Count() int {
@@ -2655,6 +2813,15 @@ func NewPayloadScanner(
 	return ret
 }
 
+// Close releases the blocks taken by the flushing scanner.
+// The output may point to the strings in them.
+func (scan *PayloadScanner) Close() {
+	if scan._scanner._flush {
+		scan._rows.Close()
+		scan._heap.Close()
+	}
+}
+
 func (scan *PayloadScanner) Scan(output *chunk.Chunk) {
 	scan._scanner.Scan(output)
 }
@@ -2737,6 +2904,17 @@ func Gather(
 			colNo,
 			buildSize,
 		)
+	case common.INTERVAL:
+		TemplatedGatherLoop[common.Interval](
+			rows,
+			rowSel,
+			col,
+			colSel,
+			count,
+			layout,
+			colNo,
+			buildSize,
+		)
 	case common.VARCHAR:
 		GatherVarchar(
 			rows,
@@ -2943,6 +3121,41 @@ func FlipSign(b uint8) uint8 {
 	return b ^ 128
 }
 
+type boolEncoder struct{}
+
+func (boolEncoder) EncodeData(ptr unsafe.Pointer, value *bool) {
+	b := uint8(0)
+	if *value {
+		b = 1
+	}
+	util.Store[uint8](b, ptr)
+}
+
+func (boolEncoder) TypeSize() int {
+	return common.BoolSize
+}
+
+type int8Encoder struct{}
+
+func (int8Encoder) EncodeData(ptr unsafe.Pointer, value *int8) {
+	util.Store[uint8](FlipSign(uint8(*value)), ptr)
+}
+
+func (int8Encoder) TypeSize() int {
+	return common.Int8Size
+}
+
+type int16Encoder struct{}
+
+func (int16Encoder) EncodeData(ptr unsafe.Pointer, value *int16) {
+	util.Store[uint16](BSWAP16(uint16(*value)), ptr)
+	util.Store[uint8](FlipSign(util.Load[uint8](ptr)), ptr)
+}
+
+func (int16Encoder) TypeSize() int {
+	return common.Int16Size
+}
+
 type int32Encoder struct {
 }
 
@@ -2968,6 +3181,71 @@ func (i intEncoder) TypeSize() int {
 	return int(unsafe.Sizeof(int(0)))
 }
 
+type int64Encoder struct{}
+
+func (int64Encoder) EncodeData(ptr unsafe.Pointer, value *int64) {
+	encodeInt64(ptr, *value)
+}
+
+func (int64Encoder) TypeSize() int {
+	return common.Int64Size
+}
+
+// float32Encoder encodes the float in the order:
+// -inf < negatives < 0 < positives < inf < NaN
+type float32Encoder struct{}
+
+func (float32Encoder) EncodeData(ptr unsafe.Pointer, value *float32) {
+	x := *value
+	var buff uint32
+	switch {
+	case x == 0:
+		//-0 equals 0
+		buff = 1 << 31
+	case x != x:
+		buff = math.MaxUint32
+	default:
+		buff = math.Float32bits(x)
+		if buff < 1<<31 {
+			buff += 1 << 31
+		} else {
+			buff = ^buff
+		}
+	}
+	util.Store[uint32](BSWAP32(buff), ptr)
+}
+
+func (float32Encoder) TypeSize() int {
+	return common.Float32Size
+}
+
+// float64Encoder encodes the double in the order as the float32Encoder
+type float64Encoder struct{}
+
+func (float64Encoder) EncodeData(ptr unsafe.Pointer, value *float64) {
+	x := *value
+	var buff uint64
+	switch {
+	case x == 0:
+		//-0 equals 0
+		buff = 1 << 63
+	case x != x:
+		buff = math.MaxUint64
+	default:
+		buff = math.Float64bits(x)
+		if buff < 1<<63 {
+			buff += 1 << 63
+		} else {
+			buff = ^buff
+		}
+	}
+	util.Store[uint64](BSWAP64(buff), ptr)
+}
+
+func (float64Encoder) TypeSize() int {
+	return common.Int64Size
+}
+
 type decimalEncoder struct {
 }
 
@@ -2993,6 +3271,19 @@ func (dateEncoder) TypeSize() int {
 	return common.DateSize
 }
 
+// intervalEncoder encodes the normalized interval
+type intervalEncoder struct{}
+
+func (intervalEncoder) EncodeData(ptr unsafe.Pointer, iv *common.Interval) {
+	days, micros := iv.Normalize()
+	encodeInt64(ptr, days)
+	encodeInt64(util.PointerAdd(ptr, common.Int64Size), micros)
+}
+
+func (intervalEncoder) TypeSize() int {
+	return common.IntervalSize
+}
+
 type hugeEncoder struct{}
 
 func (hugeEncoder) EncodeData(ptr unsafe.Pointer, d *common.Hugeint) {
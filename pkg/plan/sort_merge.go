@@ -0,0 +1,416 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"container/heap"
+	"unsafe"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+/*
+External merge sort.
+
+The LocalSort sorts the data in memory when it exceeds the memory limit.
+The sorted data is spilled as the run:
+
+	keys: the radix encoded sorting keys in order.
+	rows: the chunks of the payload and the blob sorting data
+	      scanned by the PayloadScanner.
+
+The runs are merged by the k-way merge at last. The keys are compared
+column by column. The tie of the prefix of the varchar is broken by
+the blob sorting data.
+*/
+
+// sortedRun is the sorted data in the temporary blocks
+type sortedRun struct {
+	keys  *spillWriter
+	rows  *spillWriter
+	count int
+}
+
+func (run *sortedRun) Destroy() {
+	run.keys.Destroy()
+	run.rows.Destroy()
+}
+
+func (ls *LocalSort) SetMemoryLimit(limit int) {
+	ls._memoryLimit = limit
+}
+
+// SizeInBytes returns the memory of the unsorted data
+func (ls *LocalSort) SizeInBytes() int {
+	size := ls._radixSortingData.SizeInBytes() +
+		ls._payloadData.SizeInBytes() +
+		ls._payloadHeap.SizeInBytes()
+	if !ls._sortLayout._allConstant {
+		size += ls._blobSortingData.SizeInBytes() +
+			ls._blobSortingHeap.SizeInBytes()
+	}
+	return size
+}
+
+func (ls *LocalSort) exceedMemoryLimit() bool {
+	return ls._memoryLimit > 0 && ls.SizeInBytes() > ls._memoryLimit
+}
+
+// Spill sorts the data in memory and writes it as the run
+func (ls *LocalSort) Spill() error {
+	if ls._radixSortingData._count == 0 {
+		return nil
+	}
+	err := ls.Sort(true)
+	if err != nil {
+		return err
+	}
+	sb := util.Back(ls._sortedBlocks)
+	ls._sortedBlocks = ls._sortedBlocks[:len(ls._sortedBlocks)-1]
+	//the blocks are moved into the sorted block
+	for _, rows := range []*RowDataCollection{
+		ls._radixSortingData,
+		ls._blobSortingData,
+		ls._payloadData,
+		ls._payloadHeap,
+		ls._blobSortingHeap,
+	} {
+		if rows != nil {
+			rows._blocks = nil
+			rows._count = 0
+		}
+	}
+	defer func() {
+		//the strings of the sorted rows are in the heap before the reorder
+		sb.Close()
+		for _, block := range ls._heapBlocks {
+			block.Close()
+		}
+		ls._heapBlocks = nil
+	}()
+
+	run := &sortedRun{
+		keys: newSpillWriter(storage.GBufferMgr),
+		rows: newSpillWriter(storage.GBufferMgr),
+	}
+	ls._runs = append(ls._runs, run)
+	payloadScan := NewPayloadScanner(sb._payloadData, ls, false)
+	var blobScan *PayloadScanner
+	if !ls._sortLayout._allConstant {
+		blobScan = NewPayloadScanner(sb._blobSortingData, ls, false)
+	}
+	keyPtr := sb._radixSortingData[0]._ptr
+	for payloadScan.Remaining() > 0 {
+		err = ls._ctxCheck.err()
+		if err != nil {
+			return err
+		}
+		payload := &chunk.Chunk{}
+		payload.Init(ls._payloadLayout.GetTypes(), util.DefaultVectorSize)
+		payloadScan.Scan(payload)
+		err = run.rows.Append(payload)
+		if err != nil {
+			return err
+		}
+		if blobScan != nil {
+			blob := &chunk.Chunk{}
+			blob.Init(ls._sortLayout._blobLayout.GetTypes(), util.DefaultVectorSize)
+			blobScan.Scan(blob)
+			err = run.rows.Append(blob)
+			if err != nil {
+				return err
+			}
+		}
+		size := payload.Card() * ls._sortLayout._entrySize
+		err = run.keys.WriteData(util.PointerToSlice[byte](keyPtr, size), size)
+		if err != nil {
+			return err
+		}
+		keyPtr = util.PointerAdd(keyPtr, size)
+		run.count += payload.Card()
+	}
+	err = run.keys.Close()
+	if err != nil {
+		return err
+	}
+	return run.rows.Close()
+}
+
+// Close releases the memory and removes the spilled runs
+func (ls *LocalSort) Close() {
+	if ls._merger != nil {
+		ls._merger.Close()
+		ls._merger = nil
+	}
+	for _, run := range ls._runs {
+		run.Destroy()
+	}
+	ls._runs = nil
+	if ls._scanner != nil {
+		ls._scanner.Close()
+		ls._scanner = nil
+	}
+	for _, sb := range ls._sortedBlocks {
+		sb.Close()
+	}
+	ls._sortedBlocks = nil
+	for _, block := range ls._heapBlocks {
+		block.Close()
+	}
+	ls._heapBlocks = nil
+	for _, rows := range []*RowDataCollection{
+		ls._radixSortingData,
+		ls._blobSortingData,
+		ls._blobSortingHeap,
+		ls._payloadData,
+		ls._payloadHeap,
+	} {
+		if rows != nil {
+			rows.Close()
+		}
+	}
+}
+
+// sortRunCursor reads the rows of the run chunk by chunk
+type sortRunCursor struct {
+	no      int
+	keys    *spillReader
+	rows    *spillReader
+	payload *chunk.Chunk
+	blob    *chunk.Chunk
+	key     []byte
+	idx     int
+}
+
+// next reads the next chunk of the run. The payload is empty at the end.
+func (cur *sortRunCursor) next(layout *SortLayout) error {
+	cur.idx = 0
+	cur.payload = &chunk.Chunk{}
+	err := cur.rows.Next(cur.payload)
+	if err != nil || cur.payload.Card() == 0 {
+		return err
+	}
+	if !layout._allConstant {
+		cur.blob = &chunk.Chunk{}
+		err = cur.rows.Next(cur.blob)
+		if err != nil {
+			return err
+		}
+	}
+	size := cur.payload.Card() * layout._entrySize
+	if cap(cur.key) < size {
+		cur.key = make([]byte, size)
+	}
+	cur.key = cur.key[:size]
+	return cur.keys.ReadData(cur.key, size)
+}
+
+func (cur *sortRunCursor) rowKey(layout *SortLayout) []byte {
+	return cur.key[cur.idx*layout._entrySize:]
+}
+
+func (cur *sortRunCursor) Close() {
+	cur.keys.Close()
+	cur.rows.Close()
+}
+
+// sortMerger merges the sorted runs by the heap of the cursors
+type sortMerger struct {
+	layout  *SortLayout
+	cursors []*sortRunCursor
+	heap    sortRunHeap
+}
+
+func newSortMerger(ls *LocalSort) (*sortMerger, error) {
+	m := &sortMerger{layout: ls._sortLayout}
+	m.heap.merger = m
+	for i, run := range ls._runs {
+		err := ls._ctxCheck.err()
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		cur := &sortRunCursor{
+			no:   i,
+			keys: newSpillReader(run.keys),
+			rows: newSpillReader(run.rows),
+		}
+		m.cursors = append(m.cursors, cur)
+		err = cur.next(m.layout)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		if cur.payload.Card() != 0 {
+			m.heap.cursors = append(m.heap.cursors, cur)
+		}
+	}
+	heap.Init(&m.heap)
+	return m, nil
+}
+
+// compare compares the current rows of the cursors
+func (m *sortMerger) compare(l, r *sortRunCursor) int {
+	lay := m.layout
+	lkey := l.rowKey(lay)
+	rkey := r.rowKey(lay)
+	offset := 0
+	for i := 0; i < lay._columnCount; i++ {
+		size := lay._columnSizes[i]
+		ret := bytes.Compare(lkey[offset:offset+size], rkey[offset:offset+size])
+		if ret != 0 {
+			return ret
+		}
+		if !lay._constantSize[i] {
+			//the prefixes are equal
+			ret = m.compareBlob(l, r, i)
+			if ret != 0 {
+				return ret
+			}
+		}
+		offset += size
+	}
+	return 0
+}
+
+func (m *sortMerger) compareBlob(l, r *sortRunCursor, sortCol int) int {
+	col := m.layout._sortingToBlobCol[sortCol]
+	lvec := l.blob.Data[col]
+	rvec := r.blob.Data[col]
+	if !chunk.GetMaskInPhyFormatFlat(lvec).RowIsValid(uint64(l.idx)) ||
+		!chunk.GetMaskInPhyFormatFlat(rvec).RowIsValid(uint64(r.idx)) {
+		//both are null
+		return 0
+	}
+	lstr := chunk.GetSliceInPhyFormatFlat[common.String](lvec)[l.idx]
+	rstr := chunk.GetSliceInPhyFormatFlat[common.String](rvec)[r.idx]
+	ret := CompareVal(
+		unsafe.Pointer(&lstr),
+		unsafe.Pointer(&rstr),
+		m.layout._logicalTypes[sortCol],
+	)
+	if m.layout._orderTypes[sortCol] == OT_DESC {
+		return -ret
+	}
+	return ret
+}
+
+// Scan merges the rows into the output. The output is empty at the end.
+func (m *sortMerger) Scan(output *chunk.Chunk) error {
+	count := 0
+	for count < util.DefaultVectorSize && m.heap.Len() > 0 {
+		cur := m.heap.cursors[0]
+		//the rows of the cursor are copied together
+		//until the row of the other cursor is less
+		other := m.heap.second()
+		start := cur.idx
+		cur.idx++
+		for cur.idx < cur.payload.Card() &&
+			count+cur.idx-start < util.DefaultVectorSize &&
+			(other == nil || !m.heap.less(other, cur)) {
+			cur.idx++
+		}
+		for i, vec := range cur.payload.Data {
+			chunk.Copy(
+				vec,
+				output.Data[i],
+				chunk.IncrSelectVectorInPhyFormatFlat(),
+				cur.idx,
+				start,
+				count,
+			)
+		}
+		count += cur.idx - start
+		if cur.idx < cur.payload.Card() {
+			heap.Fix(&m.heap, 0)
+			continue
+		}
+		err := cur.next(m.layout)
+		if err != nil {
+			return err
+		}
+		if cur.payload.Card() == 0 {
+			heap.Pop(&m.heap)
+		} else {
+			heap.Fix(&m.heap, 0)
+		}
+	}
+	output.SetCard(count)
+	return nil
+}
+
+func (m *sortMerger) Close() {
+	for _, cur := range m.cursors {
+		cur.Close()
+	}
+	m.cursors = nil
+	m.heap.cursors = nil
+}
+
+// sortRunHeap is the min heap of the cursors.
+// The row of the earlier run is less if the rows are equal.
+type sortRunHeap struct {
+	merger  *sortMerger
+	cursors []*sortRunCursor
+}
+
+func (h *sortRunHeap) less(l, r *sortRunCursor) bool {
+	ret := h.merger.compare(l, r)
+	if ret == 0 {
+		return l.no < r.no
+	}
+	return ret < 0
+}
+
+// second returns the least cursor except the top
+func (h *sortRunHeap) second() *sortRunCursor {
+	switch len(h.cursors) {
+	case 1:
+		return nil
+	case 2:
+		return h.cursors[1]
+	default:
+		if h.less(h.cursors[2], h.cursors[1]) {
+			return h.cursors[2]
+		}
+		return h.cursors[1]
+	}
+}
+
+func (h *sortRunHeap) Len() int {
+	return len(h.cursors)
+}
+
+func (h *sortRunHeap) Less(i, j int) bool {
+	return h.less(h.cursors[i], h.cursors[j])
+}
+
+func (h *sortRunHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *sortRunHeap) Push(x any) {
+	h.cursors = append(h.cursors, x.(*sortRunCursor))
+}
+
+func (h *sortRunHeap) Pop() any {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
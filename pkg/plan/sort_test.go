@@ -17,6 +17,7 @@ package plan
 import (
 	"fmt"
 	"math"
+	"math/rand"
 	"sort"
 	"testing"
 	"unsafe"
@@ -24,6 +25,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
 	"github.com/daviszhen/plan/pkg/util"
 )
 
@@ -460,3 +463,87 @@ func Test_localsort(t *testing.T) {
 	ops[0].Children[0] = stubOp
 	runOps(t, conf, nil, ops)
 }
+
+func Test_externalSort(t *testing.T) {
+	//order by k, s desc
+	orders := []*Expr{
+		{Children: []*Expr{{DataTyp: common.BigintType()}}},
+		{Desc: true, Children: []*Expr{{DataTyp: common.VarcharType()}}},
+	}
+	keyTypes := []common.LType{common.BigintType(), common.VarcharType()}
+	payloadTypes := []common.LType{common.BigintType(), common.VarcharType(), common.BigintType()}
+	ls := NewLocalSort(NewSortLayout(orders), NewRowLayout(payloadTypes, nil))
+	ls.SetMemoryLimit(1)
+	defer ls.Close()
+
+	type row struct {
+		k  int64
+		s  string
+		id int64
+	}
+	rows := make([]row, 0)
+	rnd := rand.New(rand.NewSource(1))
+	for c := 0; c < 8; c++ {
+		key := &chunk.Chunk{}
+		key.Init(keyTypes, util.DefaultVectorSize)
+		payload := &chunk.Chunk{}
+		payload.Init(payloadTypes, util.DefaultVectorSize)
+		for i := 0; i < util.DefaultVectorSize; i++ {
+			//the strings are longer than the prefix
+			r := row{
+				k:  int64(rnd.Intn(10)),
+				s:  fmt.Sprintf("long-string-prefix-%d", rnd.Intn(100)),
+				id: int64(len(rows)),
+			}
+			rows = append(rows, r)
+			k := &chunk.Value{Typ: common.BigintType(), I64: r.k}
+			s := &chunk.Value{Typ: common.VarcharType(), Str: r.s}
+			key.Data[0].SetValue(i, k)
+			key.Data[1].SetValue(i, s)
+			payload.Data[0].SetValue(i, k)
+			payload.Data[1].SetValue(i, s)
+			payload.Data[2].SetValue(i, &chunk.Value{Typ: common.BigintType(), I64: r.id})
+		}
+		key.SetCard(util.DefaultVectorSize)
+		payload.SetCard(util.DefaultVectorSize)
+		ls.SinkChunk(key, payload)
+		require.True(t, ls.exceedMemoryLimit())
+		require.NoError(t, ls.Spill())
+	}
+	require.Len(t, ls._runs, 8)
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].k != rows[j].k {
+			return rows[i].k < rows[j].k
+		}
+		return rows[i].s > rows[j].s
+	})
+
+	merger, err := newSortMerger(ls)
+	require.NoError(t, err)
+	ls._merger = merger
+	got := make([]row, 0)
+	for {
+		output := &chunk.Chunk{}
+		output.Init(payloadTypes, util.DefaultVectorSize)
+		require.NoError(t, merger.Scan(output))
+		if output.Card() == 0 {
+			break
+		}
+		for i := 0; i < output.Card(); i++ {
+			got = append(got, row{
+				k:  output.Data[0].GetValue(i).I64,
+				s:  output.Data[1].GetValue(i).Str,
+				id: output.Data[2].GetValue(i).I64,
+			})
+		}
+	}
+	//the order of the equal rows is not kept
+	require.Equal(t, len(rows), len(got))
+	ids := make(map[int64]bool)
+	for i := range rows {
+		assert.Equal(t, rows[i].k, got[i].k)
+		assert.Equal(t, rows[i].s, got[i].s)
+		ids[got[i].id] = true
+	}
+	assert.Len(t, ids, len(rows))
+}
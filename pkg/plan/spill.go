@@ -0,0 +1,147 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"io"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/storage"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+var _ util.Serialize = new(spillWriter)
+
+// spillWriter writes the chunks into the temporary blocks of the buffer manager.
+// The full block is offloaded into the temporary directory.
+type spillWriter struct {
+	bufMgr *storage.BufferManager
+	blocks []*storage.BlockHandle
+	//the bytes in the blocks
+	sizes []int
+	//the last block that is not full
+	current *storage.BufferHandle
+	//the rows in the chunks
+	count int
+}
+
+func newSpillWriter(bufMgr *storage.BufferManager) *spillWriter {
+	return &spillWriter{bufMgr: bufMgr}
+}
+
+func (w *spillWriter) Append(data *chunk.Chunk) error {
+	w.count += data.Card()
+	return data.Serialize(w)
+}
+
+func (w *spillWriter) WriteData(buffer []byte, l int) error {
+	blockSize := int(storage.BLOCK_SIZE)
+	for l > 0 {
+		last := len(w.sizes) - 1
+		if w.current == nil || w.sizes[last] == blockSize {
+			err := w.offload()
+			if err != nil {
+				return err
+			}
+			var block *storage.BlockHandle
+			w.current = w.bufMgr.Allocate(storage.BLOCK_SIZE, false, &block)
+			w.blocks = append(w.blocks, block)
+			w.sizes = append(w.sizes, 0)
+			last++
+		}
+		dst := util.PointerToSlice[byte](w.current.Ptr(), blockSize)
+		n := copy(dst[w.sizes[last]:], buffer[:l])
+		w.sizes[last] += n
+		buffer = buffer[n:]
+		l -= n
+	}
+	return nil
+}
+
+func (w *spillWriter) offload() error {
+	if w.current == nil {
+		return nil
+	}
+	err := w.bufMgr.Offload(w.current)
+	w.current = nil
+	return err
+}
+
+// Close offloads the last block
+func (w *spillWriter) Close() error {
+	return w.offload()
+}
+
+// Destroy removes the blocks that are not read
+func (w *spillWriter) Destroy() {
+	for _, block := range w.blocks {
+		w.bufMgr.Destroy(block)
+	}
+	w.blocks = nil
+	w.sizes = nil
+	w.current = nil
+}
+
+var _ util.Deserialize = new(spillReader)
+
+// spillReader reads the chunks from the blocks of the spillWriter.
+// The block is destroyed after it is read.
+type spillReader struct {
+	w       *spillWriter
+	idx     int
+	offset  int
+	current *storage.BufferHandle
+}
+
+func newSpillReader(w *spillWriter) *spillReader {
+	return &spillReader{w: w}
+}
+
+// Next reads the next chunk. The chunk is empty at the end.
+func (r *spillReader) Next(data *chunk.Chunk) error {
+	return data.Deserialize(r)
+}
+
+func (r *spillReader) ReadData(buffer []byte, l int) error {
+	read := 0
+	for read < l {
+		if r.current == nil {
+			if r.idx >= len(r.w.blocks) {
+				return io.EOF
+			}
+			r.current = r.w.bufMgr.Pin(r.w.blocks[r.idx])
+			r.offset = 0
+		}
+		size := r.w.sizes[r.idx]
+		src := util.PointerToSlice[byte](r.current.Ptr(), size)
+		n := copy(buffer[read:l], src[r.offset:])
+		r.offset += n
+		read += n
+		if r.offset == size {
+			r.current.Close()
+			r.current = nil
+			r.idx++
+		}
+	}
+	return nil
+}
+
+func (r *spillReader) Close() error {
+	if r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+// pushdownTableFilters extracts the comparisons between the column and
+// the constant from the filters of the table scan:
+//
+//	col =, <, <=, >, >= const
+//	const =, <, <=, >, >= col
+//	col between const and const
+//
+// The storage skips the row groups and the vectors by them.
+// The filters are still evaluated on the scanned rows.
+// It returns nil if nothing is pushed.
+func pushdownTableFilters(filters []*Expr) *storage.TableFilterSet {
+	var set *storage.TableFilterSet
+	push := func(col, cst *Expr, cmp storage.CompareType) {
+		if !col.DataTyp.Equal(cst.DataTyp) {
+			return
+		}
+		val, err := evalConstExpr(cst)
+		if err != nil {
+			//the error is reported by the filter on the scanned rows
+			return
+		}
+		if set == nil {
+			set = storage.NewTableFilterSet()
+		}
+		set.PushFilter(storage.IdxType(col.ColRef[1]), &storage.ConstantFilter{
+			Cmp:      cmp,
+			Constant: val,
+		})
+	}
+	for _, filter := range filters {
+		for _, expr := range splitExprByAnd(filter) {
+			if expr.Typ != ET_Func {
+				continue
+			}
+			switch expr.SubTyp {
+			case ET_Equal, ET_Less, ET_LessEqual, ET_Greater, ET_GreaterEqual:
+				left, right := expr.Children[0], expr.Children[1]
+				cmp := compareTypeOf(expr.SubTyp)
+				if isScanColumn(left) && isConstExpr(right) {
+					push(left, right, cmp)
+				} else if isConstExpr(left) && isScanColumn(right) {
+					push(right, left, cmp.Flip())
+				}
+			case ET_Between:
+				col, lower, upper := expr.Children[0], expr.Children[1], expr.Children[2]
+				if isScanColumn(col) && isConstExpr(lower) && isConstExpr(upper) {
+					push(col, lower, storage.CompareGreaterEqual)
+					push(col, upper, storage.CompareLessEqual)
+				}
+			}
+		}
+	}
+	return set
+}
+
+func compareTypeOf(subTyp ET_SubTyp) storage.CompareType {
+	switch subTyp {
+	case ET_Equal:
+		return storage.CompareEqual
+	case ET_Less:
+		return storage.CompareLess
+	case ET_LessEqual:
+		return storage.CompareLessEqual
+	case ET_Greater:
+		return storage.CompareGreater
+	case ET_GreaterEqual:
+		return storage.CompareGreaterEqual
+	default:
+		panic("usp " + subTyp.String())
+	}
+}
+
+func isScanColumn(e *Expr) bool {
+	return e.Typ == ET_Column && e.Depth == 0
+}
+
+// isConstExpr returns true if the expr only has the constants and
+// the scalar functions without side effects on them.
+func isConstExpr(e *Expr) bool {
+	switch e.Typ {
+	case ET_IConst, ET_DecConst, ET_SConst, ET_FConst, ET_DateConst,
+		ET_IntervalConst, ET_BConst, ET_NConst:
+		return true
+	case ET_Func:
+		if e.FunImpl == nil ||
+			e.FunImpl._funcTyp != ScalarFuncType ||
+			e.FunImpl._sideEffects == HasSideEffects {
+			return false
+		}
+		for _, child := range e.Children {
+			if !isConstExpr(child) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// evalConstExpr evaluates the constant expr
+func evalConstExpr(e *Expr) (*chunk.Value, error) {
+	data := &chunk.Chunk{}
+	data.Init([]common.LType{e.DataTyp}, storage.STANDARD_VECTOR_SIZE)
+	tmp := &chunk.Chunk{}
+	tmp.SetCard(1)
+	err := NewExprExec(e).executeExprs([]*chunk.Chunk{tmp, nil, nil}, data)
+	if err != nil {
+		return nil, err
+	}
+	return data.Data[0].GetValue(0), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/storage"
+)
+
+func Test_pushdownTableFilters(t *testing.T) {
+	integer := common.IntegerType()
+	col := func(idx uint64) *Expr {
+		return &Expr{Typ: ET_Column, DataTyp: integer, ColRef: ColumnBind{4, idx}}
+	}
+	iconst := func(v int64) *Expr {
+		return &Expr{Typ: ET_IConst, DataTyp: integer, Ivalue: v}
+	}
+	cmp := func(subTyp ET_SubTyp, children ...*Expr) *Expr {
+		return &Expr{Typ: ET_Func, SubTyp: subTyp, DataTyp: common.BooleanType(), Children: children, FunImpl: &FunctionV2{}}
+	}
+
+	set := pushdownTableFilters([]*Expr{
+		cmp(ET_Less, col(0), iconst(10)),
+		//const on the left
+		cmp(ET_GreaterEqual, iconst(3), col(1)),
+		cmp(ET_Between, col(2), iconst(1), iconst(5)),
+		//not pushed
+		cmp(ET_Equal, col(0), col(1)),
+		cmp(ET_NotEqual, col(0), iconst(1)),
+		cmp(ET_Or, cmp(ET_Less, col(0), iconst(1)), cmp(ET_Greater, col(0), iconst(5))),
+	})
+	require.NotNil(t, set)
+	require.Len(t, set.Filters, 3)
+
+	check := func(filter *storage.ConstantFilter, cmp storage.CompareType, val int64) {
+		assert.Equal(t, cmp, filter.Cmp)
+		assert.Equal(t, val, filter.Constant.I64)
+	}
+	require.Len(t, set.Filters[0], 1)
+	check(set.Filters[0][0], storage.CompareLess, 10)
+	require.Len(t, set.Filters[1], 1)
+	check(set.Filters[1][0], storage.CompareLessEqual, 3)
+	require.Len(t, set.Filters[2], 2)
+	check(set.Filters[2][0], storage.CompareGreaterEqual, 1)
+	check(set.Filters[2][1], storage.CompareLessEqual, 5)
+
+	assert.Nil(t, pushdownTableFilters([]*Expr{cmp(ET_Equal, col(0), col(1))}))
+}
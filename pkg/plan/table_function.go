@@ -0,0 +1,589 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	pqLocal "github.com/xitongsys/parquet-go-source/local"
+	pqReader "github.com/xitongsys/parquet-go/reader"
+
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// rows read for inferring the schema of the csv or json file
+const sniffRows = 1024
+
+// tableFuncFormats maps the table function into the file format
+var tableFuncFormats = map[string]string{
+	"read_csv":      "csv",
+	"read_csv_auto": "csv",
+	"read_parquet":  "parquet",
+	"parquet_scan":  "parquet",
+	"read_json":     "json",
+	"read_ndjson":   "json",
+}
+
+// fileSchema is inferred from the head of the file
+type fileSchema struct {
+	names     []string
+	typs      []common.LType
+	hasHeader bool
+	//rows in the file or read for the inference
+	rowCnt int64
+}
+
+// buildTableFunc binds the table function that reads the files.
+// read_csv('dir/*.csv', delim='|', header=true)
+func (b *Builder) buildTableFunc(rangeFunc *pg_query.RangeFunction, ctx *BindContext, depth int) (*Expr, error) {
+	if len(rangeFunc.GetFunctions()) != 1 {
+		return nil, fmt.Errorf("usp multiple table functions")
+	}
+	items := rangeFunc.GetFunctions()[0].GetList().GetItems()
+	if len(items) == 0 || items[0].GetFuncCall() == nil {
+		return nil, fmt.Errorf("usp table function")
+	}
+	call := items[0].GetFuncCall()
+	funcNames := call.GetFuncname()
+	name := strings.ToLower(funcNames[len(funcNames)-1].GetString_().GetSval())
+	if macro := b.lookupMacro(funcNames, true); macro != nil {
+		return b.buildTableMacro(macro, rangeFunc, call, ctx, depth)
+	}
+	if name == "generate_series" || name == "range" {
+		return b.buildSeries(name, call, rangeFunc.GetAlias(), ctx, depth)
+	}
+	if name == "unnest" {
+		return b.buildUnnestTable(call, rangeFunc.GetAlias(), ctx, depth)
+	}
+	format, has := tableFuncFormats[name]
+	if !has {
+		return nil, fmt.Errorf("no table function %s", name)
+	}
+
+	patterns := make([]string, 0)
+	opts := make([]*ScanOption, 0)
+	for _, arg := range call.GetArgs() {
+		switch {
+		case arg.GetNamedArgExpr() != nil:
+			opt, err := tableFuncOption(name, arg.GetNamedArgExpr().GetName(), arg.GetNamedArgExpr().GetArg())
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, opt)
+		case arg.GetAExpr() != nil:
+			//delim='|'
+			aexpr := arg.GetAExpr()
+			fields := aexpr.GetLexpr().GetColumnRef().GetFields()
+			if len(aexpr.GetName()) != 1 || aexpr.GetName()[0].GetString_().GetSval() != "=" || len(fields) != 1 {
+				return nil, fmt.Errorf("invalid option of %s", name)
+			}
+			opt, err := tableFuncOption(name, fields[0].GetString_().GetSval(), aexpr.GetRexpr())
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, opt)
+		case arg.GetAArrayExpr() != nil:
+			for _, elem := range arg.GetAArrayExpr().GetElements() {
+				if elem.GetAConst().GetSval() == nil {
+					return nil, fmt.Errorf("the file of %s must be a string", name)
+				}
+				patterns = append(patterns, elem.GetAConst().GetSval().GetSval())
+			}
+		case arg.GetAConst().GetSval() != nil:
+			patterns = append(patterns, arg.GetAConst().GetSval().GetSval())
+		default:
+			return nil, fmt.Errorf("the file of %s must be a string", name)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no file in %s", name)
+	}
+
+	files, err := expandGlobs(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	//the schema comes from the first file
+	var schema *fileSchema
+	switch format {
+	case "csv":
+		comma := ','
+		if commaOpt := getFormatFun("delimiter", opts); commaOpt != nil {
+			comma = int32(commaOpt.Opt[0])
+		}
+		header := ""
+		if headerOpt := getFormatFun("header", opts); headerOpt != nil {
+			header = headerOpt.Opt
+		}
+		schema, err = sniffCsv(files[0], comma, header)
+		if err != nil {
+			return nil, err
+		}
+		if header == "" {
+			opts = append(opts, &ScanOption{Kind: "header", Opt: strconv.FormatBool(schema.hasHeader)})
+		}
+	case "parquet":
+		schema, err = readParquetSchema(files[0])
+	case "json":
+		schema, err = sniffJson(files[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	names, typs := schema.names, schema.typs
+
+	bind, err := b.addTableFuncBinding(name, rangeFunc.GetAlias(), names, typs, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	//the columns of the scan are the names in the file
+	name2Idx := make(map[string]int)
+	columnIds := make([]int, len(names))
+	for i, colName := range names {
+		name2Idx[colName] = i
+		columnIds[i] = i
+	}
+	return &Expr{
+		Typ:       ET_TableFunc,
+		Index:     bind.index,
+		Table:     name,
+		Alias:     bind.alias,
+		BelongCtx: ctx,
+		ScanInfo: &ScanInfo{
+			ReturnedTypes: typs,
+			Names:         names,
+			ColumnIds:     columnIds,
+			FilePath:      files[0],
+			FilePaths:     files,
+			Opts:          opts,
+			Format:        format,
+		},
+		ColName2Idx: name2Idx,
+		//the files are assumed to be as large as the first one
+		Ivalue: schema.rowCnt * int64(len(files)),
+	}, err
+}
+
+// addTableFuncBinding adds the binding of the table function.
+// the alias renames the columns.
+func (b *Builder) addTableFuncBinding(
+	name string,
+	alias *pg_query.Alias,
+	names []string,
+	typs []common.LType,
+	ctx *BindContext) (*Binding, error) {
+	bind := &Binding{
+		typ:     BT_TABLE,
+		alias:   name,
+		index:   uint64(b.GetTag()),
+		typs:    util.CopyTo(typs),
+		names:   util.CopyTo(names),
+		nameMap: make(map[string]int),
+	}
+	if alias != nil {
+		bind.alias = alias.GetAliasname()
+		colNames := alias.GetColnames()
+		if len(colNames) > len(names) {
+			return nil, fmt.Errorf("%s has %d columns available but %d columns specified",
+				bind.alias, len(names), len(colNames))
+		}
+		for i, colName := range colNames {
+			bind.names[i] = colName.GetString_().GetSval()
+		}
+	}
+	for idx, name := range bind.names {
+		bind.nameMap[name] = idx
+	}
+	err := ctx.AddBinding(bind.alias, bind)
+	if err != nil {
+		return nil, err
+	}
+	return bind, nil
+}
+
+// buildUnnestTable binds unnest(list) in the FROM clause.
+// it is rewritten into the subquery
+// (SELECT unnest(list) AS unnest FROM generate_series(1,1)) AS unnest.
+func (b *Builder) buildUnnestTable(call *pg_query.FuncCall, alias *pg_query.Alias, ctx *BindContext, depth int) (*Expr, error) {
+	if len(call.GetArgs()) != 1 {
+		return nil, fmt.Errorf("unnest requires one argument")
+	}
+	if alias == nil {
+		alias = &pg_query.Alias{Aliasname: "unnest"}
+	}
+	unnest := pg_query.MakeFuncCallNode(
+		[]*pg_query.Node{pg_query.MakeStrNode("unnest")},
+		call.GetArgs(),
+		call.GetLocation())
+	//one row for the unnest
+	oneRow := pg_query.MakeFuncCallNode(
+		[]*pg_query.Node{pg_query.MakeStrNode("generate_series")},
+		[]*pg_query.Node{pg_query.MakeAConstIntNode(1, -1), pg_query.MakeAConstIntNode(1, -1)},
+		-1)
+	subquery := &pg_query.SelectStmt{
+		TargetList: []*pg_query.Node{pg_query.MakeResTargetNodeWithNameAndVal("unnest", unnest, -1)},
+		FromClause: []*pg_query.Node{pg_query.MakeSimpleRangeFunctionNode(
+			[]*pg_query.Node{pg_query.MakeListNode([]*pg_query.Node{oneRow})})},
+		Op: pg_query.SetOperation_SETOP_NONE,
+	}
+	node := &pg_query.Node{
+		Node: &pg_query.Node_RangeSubselect{
+			RangeSubselect: &pg_query.RangeSubselect{
+				Alias:    alias,
+				Subquery: &pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: subquery}},
+			},
+		},
+	}
+	return b.buildTable(node, ctx, depth)
+}
+
+// tableFuncOption converts the named argument into the scan option.
+func tableFuncOption(funcName, optName string, arg *pg_query.Node) (*ScanOption, error) {
+	aconst := arg.GetAConst()
+	if aconst == nil {
+		return nil, fmt.Errorf("option %s of %s must be a constant", optName, funcName)
+	}
+	value := ""
+	switch {
+	case aconst.GetSval() != nil:
+		value = aconst.GetSval().GetSval()
+	case aconst.GetBoolval() != nil:
+		value = strconv.FormatBool(aconst.GetBoolval().GetBoolval())
+	case aconst.GetIval() != nil:
+		value = strconv.FormatInt(int64(aconst.GetIval().GetIval()), 10)
+	default:
+		return nil, fmt.Errorf("invalid value of option %s of %s", optName, funcName)
+	}
+
+	optName = strings.ToLower(optName)
+	if tableFuncFormats[funcName] == "csv" {
+		switch optName {
+		case "delim", "sep", "delimiter":
+			if len(value) != 1 {
+				return nil, fmt.Errorf("the delimiter must be one character. got '%s'", value)
+			}
+			return &ScanOption{Kind: "delimiter", Opt: value}, nil
+		case "header":
+			header, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("the header must be a boolean. got '%s'", value)
+			}
+			return &ScanOption{Kind: "header", Opt: strconv.FormatBool(header)}, nil
+		}
+	}
+	return nil, fmt.Errorf("usp option %s of %s", optName, funcName)
+}
+
+// expandGlobs returns the files matched by the patterns in order.
+func expandGlobs(patterns []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files found that match the pattern %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func readParquetSchema(path string) (*fileSchema, error) {
+	file, err := pqLocal.NewLocalFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := pqReader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.ReadStop()
+	names, typs, err := pqSchema(reader.SchemaHandler)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSchema{
+		names:  normalizeColumnNames(names),
+		typs:   typs,
+		rowCnt: reader.GetNumRows(),
+	}, nil
+}
+
+// normalizeColumnNames lowers the names and renames the empty and
+// duplicate ones.
+func normalizeColumnNames(names []string) []string {
+	ret := make([]string, len(names))
+	seen := make(map[string]bool)
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			name = fmt.Sprintf("column%d", i)
+		}
+		for seen[name] {
+			name = fmt.Sprintf("%s_%d", name, i)
+		}
+		seen[name] = true
+		ret[i] = name
+	}
+	return ret
+}
+
+// csvCandidates are the types tried in order for the column of the csv file
+var csvCandidates = []common.LType{
+	common.BooleanType(),
+	common.IntegerType(),
+	common.BigintType(),
+	common.DoubleType(),
+	common.DateType(),
+	common.TimestampType(),
+	common.VarcharType(),
+}
+
+// csvFits returns true if the field can be read as the type.
+func csvFits(typ common.LType, field string) bool {
+	var err error
+	switch typ.Id {
+	case common.LTID_BOOLEAN:
+		_, err = strconv.ParseBool(strings.ToLower(field))
+		return err == nil && len(field) > 1
+	case common.LTID_INTEGER:
+		_, err = strconv.ParseInt(field, 10, 32)
+	case common.LTID_BIGINT:
+		_, err = strconv.ParseInt(field, 10, 64)
+	case common.LTID_DOUBLE:
+		_, err = strconv.ParseFloat(field, 64)
+	case common.LTID_DATE:
+		_, err = time.Parse(time.DateOnly, field)
+	case common.LTID_TIMESTAMP:
+		_, err = common.ParseTimestamp(field)
+	}
+	return err == nil
+}
+
+// csvTypes infers the types of the columns. the empty field is NULL.
+func csvTypes(rows [][]string, colCnt int) []common.LType {
+	typs := make([]common.LType, colCnt)
+	for j := 0; j < colCnt; j++ {
+		//the first candidate that all fields fit.
+		//the column of empty fields is VARCHAR.
+		for _, cand := range csvCandidates {
+			fits := true
+			allEmpty := true
+			for _, row := range rows {
+				if j >= len(row) || row[j] == "" {
+					continue
+				}
+				allEmpty = false
+				if !csvFits(cand, row[j]) {
+					fits = false
+					break
+				}
+			}
+			if allEmpty {
+				cand = common.VarcharType()
+			}
+			if fits {
+				typs[j] = cand
+				break
+			}
+		}
+	}
+	return typs
+}
+
+// sniffCsv infers the column names and types from the head of the csv file.
+// header is "true", "false" or "" for detecting it. the header is detected if
+// the first row has a string in the column of other type.
+func sniffCsv(path string, comma rune, header string) (*fileSchema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.Comma = comma
+	rows := make([][]string, 0)
+	for len(rows) < sniffRows {
+		row, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty csv file %s", path)
+	}
+	colCnt := len(rows[0])
+
+	hasHeader := header == "true"
+	if header == "" && len(rows) > 1 {
+		typs := csvTypes(rows[1:], colCnt)
+		firstTyps := csvTypes(rows[:1], colCnt)
+		for j, field := range rows[0] {
+			if typs[j].Id != common.LTID_VARCHAR && field != "" && firstTyps[j].Id == common.LTID_VARCHAR {
+				hasHeader = true
+				break
+			}
+		}
+	}
+
+	names := make([]string, colCnt)
+	if hasHeader {
+		copy(names, rows[0])
+		rows = rows[1:]
+	}
+	return &fileSchema{
+		names:     normalizeColumnNames(names),
+		typs:      csvTypes(rows, colCnt),
+		hasHeader: hasHeader,
+		rowCnt:    int64(len(rows)),
+	}, nil
+}
+
+// jsonValueType returns the type of the json value. false for null.
+func jsonValueType(raw json.RawMessage) (common.LType, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return common.LType{}, false
+	}
+	switch raw[0] {
+	case 'n':
+		return common.LType{}, false
+	case '{', '[':
+		return common.JSONType(), true
+	case '"':
+		return common.VarcharType(), true
+	case 't', 'f':
+		return common.BooleanType(), true
+	default:
+		for _, typ := range []common.LType{common.IntegerType(), common.BigintType()} {
+			if csvFits(typ, string(raw)) {
+				return typ, true
+			}
+		}
+		return common.DoubleType(), true
+	}
+}
+
+// numericRank orders the numeric types of the json value for widening
+func numericRank(typ common.LType) int {
+	switch typ.Id {
+	case common.LTID_INTEGER:
+		return 1
+	case common.LTID_BIGINT:
+		return 2
+	case common.LTID_DOUBLE:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// sniffJson infers the columns from the keys of the objects at the head of the file.
+// the keys are in the order of the first appearance.
+func sniffJson(path string) (*fileSchema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(bufio.NewReader(file))
+	names := make([]string, 0)
+	typs := make([]common.LType, 0)
+	known := make([]bool, 0)
+	name2Idx := make(map[string]int)
+	rowCnt := 0
+	for rowCnt < sniffRows {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if tok == json.Delim('[') || tok == json.Delim(']') {
+			continue
+		}
+		if tok != json.Delim('{') {
+			return nil, fmt.Errorf("expect json object. got %v", tok)
+		}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value json.RawMessage
+			if err = dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			name := strings.ToLower(key.(string))
+			idx, has := name2Idx[name]
+			if !has {
+				idx = len(names)
+				name2Idx[name] = idx
+				names = append(names, name)
+				typs = append(typs, common.VarcharType())
+				known = append(known, false)
+			}
+			typ, ok := jsonValueType(value)
+			switch {
+			case !ok:
+			case !known[idx]:
+				typs[idx] = typ
+				known[idx] = true
+			case typs[idx].Id == typ.Id:
+			case numericRank(typs[idx]) > 0 && numericRank(typ) > 0:
+				if numericRank(typ) > numericRank(typs[idx]) {
+					typs[idx] = typ
+				}
+			default:
+				typs[idx] = common.VarcharType()
+			}
+		}
+		if _, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		rowCnt++
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no object in json file %s", path)
+	}
+	return &fileSchema{
+		names:  names,
+		typs:   typs,
+		rowCnt: int64(rowCnt),
+	}, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+func Test_sniffCsv(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	typStrs := func(typs []common.LType) []string {
+		ret := make([]string, len(typs))
+		for i, typ := range typs {
+			ret[i] = typ.String()
+		}
+		return ret
+	}
+
+	//the header is detected
+	path := write("a.csv", "Id,Name,Score,Day,Ok\n1,x,1.5,2024-01-02,true\n2,,,2024-02-03,false\n")
+	schema, err := sniffCsv(path, ',', "")
+	require.NoError(t, err)
+	assert.True(t, schema.hasHeader)
+	assert.Equal(t, []string{"id", "name", "score", "day", "ok"}, schema.names)
+	assert.Equal(t, []string{
+		common.IntegerType().String(),
+		common.VarcharType().String(),
+		common.DoubleType().String(),
+		common.DateType().String(),
+		common.BooleanType().String(),
+	}, typStrs(schema.typs))
+
+	//no header. the big integer and the mixed column
+	path = write("b.csv", "1|3000000000|2024-01-01\n2|4|x\n")
+	schema, err = sniffCsv(path, '|', "")
+	require.NoError(t, err)
+	assert.False(t, schema.hasHeader)
+	assert.Equal(t, []string{"column0", "column1", "column2"}, schema.names)
+	assert.Equal(t, []string{
+		common.IntegerType().String(),
+		common.BigintType().String(),
+		common.VarcharType().String(),
+	}, typStrs(schema.typs))
+
+	//the header of strings is given
+	path = write("c.csv", "a,a\nx,y\n")
+	schema, err = sniffCsv(path, ',', "true")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a_1"}, schema.names)
+	assert.Equal(t, int64(1), schema.rowCnt)
+
+	files, err := expandGlobs([]string{filepath.Join(dir, "[ab].csv"), filepath.Join(dir, "c.csv")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.csv"),
+		filepath.Join(dir, "b.csv"),
+		filepath.Join(dir, "c.csv"),
+	}, files)
+	_, err = expandGlobs([]string{filepath.Join(dir, "*.parquet")})
+	assert.Error(t, err)
+}
+
+func Test_sniffJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.json")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`[{"A": 1, "b": "x", "c": {"d": [1]}}, {"a": 2.5, "b": null, "e": true, "f": 1}, {"f": "y"}]`), 0644))
+	schema, err := sniffJson(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "e", "f"}, schema.names)
+	assert.Equal(t, []common.LTypeId{
+		common.LTID_DOUBLE,
+		common.LTID_VARCHAR,
+		common.LTID_JSON,
+		common.LTID_BOOLEAN,
+		common.LTID_VARCHAR,
+	}, []common.LTypeId{
+		schema.typs[0].Id,
+		schema.typs[1].Id,
+		schema.typs[2].Id,
+		schema.typs[3].Id,
+		schema.typs[4].Id,
+	})
+	assert.Equal(t, int64(3), schema.rowCnt)
+}
@@ -67,6 +67,30 @@ func NewTupleDataAllocator(bufferMgr *storage.BufferManager, layout *TupleDataLa
 	return ret
 }
 
+// SizeInBytes returns the memory of the row blocks and the heap blocks
+func (alloc *TupleDataAllocator) SizeInBytes() int {
+	size := uint64(0)
+	for _, block := range alloc._rowBlocks {
+		size += block._cap
+	}
+	for _, block := range alloc._heapBlocks {
+		size += block._cap
+	}
+	return int(size)
+}
+
+// Close releases the memory of the blocks
+func (alloc *TupleDataAllocator) Close() {
+	for _, block := range alloc._rowBlocks {
+		alloc._bufferMgr.Destroy(block._handle)
+	}
+	for _, block := range alloc._heapBlocks {
+		alloc._bufferMgr.Destroy(block._handle)
+	}
+	alloc._rowBlocks = nil
+	alloc._heapBlocks = nil
+}
+
 func (alloc *TupleDataAllocator) Build(
 	segment *TupleDataSegment,
 	pinState *TupleDataPinState,
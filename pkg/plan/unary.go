@@ -86,10 +86,16 @@ func VectorTryCastOperator[T, R any](
 	idx int,
 	data *UnaryData,
 	op CastOp[T, R]) {
-	ret := op(input, result, false)
+	ret := op(input, result, data._tryCastData._strict)
 	if !ret {
-		err := fmt.Sprintf("VectorTryCastOperator[%d]: operation failed", idx)
-		data._tryCastData._errorMsg = &err
+		err := fmt.Errorf("could not cast %s to %s",
+			castValueString(input),
+			castTypeName(data._tryCastData._result.Typ()))
+		if data._tryCastData._strict {
+			panic(err)
+		}
+		msg := err.Error()
+		data._tryCastData._errorMsg = &msg
 		data._tryCastData._allConverted = false
 		mask.SetInvalid(uint64(idx))
 	}
@@ -97,7 +103,7 @@ func VectorTryCastOperator[T, R any](
 
 func UnaryFunction[T any, R any](
 	op UnaryOp[T, R]) ScalarFunc {
-	wrapper := &UnaryOperatorWrapper[T, R]{}
+	wrapper := &UnaryOperatorWrapper[T, R]{op: op}
 	temp := func(input *chunk.Chunk, state *ExprState, result *chunk.Vector) {
 		unaryExecStandard[T, R](
 			input.Data[0],
@@ -0,0 +1,112 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+// Unnest expands the lists of the child rows into rows.
+// The row whose longest list has k elements yields k rows.
+// The shorter lists are padded with NULL.
+// The row whose lists are all NULL or empty yields no row.
+type Unnest struct {
+	_childTypes []common.LType
+	_listTypes  []common.LType
+	_elemTypes  []common.LType
+	_listExec   *ExprExec
+
+	//current child chunk
+	_input *chunk.Chunk
+	//row -> unnest expr -> elements. nil for NULL list
+	_lists [][][]*chunk.Value
+	_row   int
+	_pos   int
+}
+
+func NewUnnest(childTypes []common.LType, lists []*Expr) *Unnest {
+	un := &Unnest{
+		_childTypes: childTypes,
+		_listExec:   NewExprExec(lists...),
+	}
+	for _, list := range lists {
+		un._listTypes = append(un._listTypes, list.DataTyp)
+		un._elemTypes = append(un._elemTypes, list.DataTyp.ListChild())
+	}
+	return un
+}
+
+// SetInput evaluates the lists on the child chunk.
+func (un *Unnest) SetInput(input *chunk.Chunk) error {
+	un._input = input
+	un._row = 0
+	un._pos = 0
+	un._lists = un._lists[:0]
+	if input.Card() == 0 {
+		return nil
+	}
+	listChunk := &chunk.Chunk{}
+	listChunk.Init(un._listTypes, util.DefaultVectorSize)
+	err := un._listExec.executeExprs([]*chunk.Chunk{input, nil, nil}, listChunk)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < input.Card(); i++ {
+		row := make([][]*chunk.Value, len(un._listTypes))
+		for j, vec := range listChunk.Data {
+			val := vec.GetValue(i)
+			if !val.IsNull {
+				row[j] = val.Children
+			}
+		}
+		un._lists = append(un._lists, row)
+	}
+	return nil
+}
+
+// Expand fills the next rows of the elements and the rows
+// of the child they come from. It returns 0 if the input has been
+// consumed.
+func (un *Unnest) Expand(sel *chunk.SelectVector, elems *chunk.Chunk) int {
+	elems.Init(un._elemTypes, util.DefaultVectorSize)
+	cnt := 0
+	for un._row < len(un._lists) && cnt < util.DefaultVectorSize {
+		lists := un._lists[un._row]
+		length := 0
+		for _, list := range lists {
+			length = max(length, len(list))
+		}
+		for un._pos < length && cnt < util.DefaultVectorSize {
+			sel.SetIndex(cnt, un._row)
+			for j, list := range lists {
+				if un._pos < len(list) {
+					elems.Data[j].SetValue(cnt, list[un._pos])
+				} else {
+					elems.Data[j].SetValue(cnt, &chunk.Value{Typ: un._elemTypes[j], IsNull: true})
+				}
+			}
+			cnt++
+			un._pos++
+		}
+		if un._pos >= length {
+			un._row++
+			un._pos = 0
+		}
+	}
+	elems.SetCard(cnt)
+	return cnt
+}
@@ -54,9 +54,10 @@ func TemplatedTryCastLoop[T any, R any](
 	op UnaryOp2[T, R],
 ) bool {
 	input := &VectorTryCastData{
-		_result:   res,
-		_strict:   params._strict,
-		_errorMsg: &params._errorMsg,
+		_result:       res,
+		_strict:       params._strict,
+		_errorMsg:     &params._errorMsg,
+		_allConverted: true,
 	}
 	data := &UnaryData{
 		_tryCastData: input,
@@ -65,7 +66,7 @@ func TemplatedTryCastLoop[T any, R any](
 		src, res,
 		count,
 		data,
-		false,
+		!params._strict,
 		op)
 	return data._tryCastData._allConverted
 }
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"container/list"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -60,6 +61,10 @@ type BlockHandle struct {
 	_blockId    BlockID
 	_buffer     *FileBuffer
 	_canDestroy bool
+	//the temporary block is written into the temporary directory
+	_offloaded bool
+	//the position in the eviction queue of the buffer manager
+	_queueElem *list.Element
 }
 
 func NewBlockHandle(blockMgr BlockMgr, blockId BlockID) *BlockHandle {
@@ -89,6 +94,10 @@ func NewBlockHandle2(
 func (handle *BlockHandle) Close() {
 	handle._buffer.Close()
 	handle._buffer = nil
+	if handle._offloaded {
+		handle._blockMgr.BufferMgr().DeleteTemporaryFile(handle._blockId)
+		handle._offloaded = false
+	}
 	handle._blockMgr.UnregisterBlock(handle._blockId, handle._canDestroy)
 }
 
@@ -106,6 +115,12 @@ func (handle *BlockHandle) Load(inhandle *BlockHandle, reusableBuffer *FileBuffe
 			panic(err)
 		}
 		handle._buffer = block.FileBuffer
+	} else if handle._offloaded {
+		buffer, err := handle._blockMgr.BufferMgr().ReadTemporaryBuffer(handle._blockId)
+		if err != nil {
+			panic(err)
+		}
+		handle._buffer = buffer
 	} else {
 		if handle._canDestroy {
 			return &BufferHandle{}
@@ -142,7 +157,7 @@ func (handle *BlockHandle) UnloadAndTakeBlock() *FileBuffer {
 		return nil
 	}
 	util.AssertFunc(handle.CanUnload())
-	if handle._blockId >= MAX_BLOCK && !handle._canDestroy {
+	if handle._blockId >= MAX_BLOCK && !handle._canDestroy && !handle._offloaded {
 		panic("usp")
 	}
 	handle._state.Store(UNLOADED)
@@ -176,6 +191,8 @@ type DatabaseHeader struct {
 	_metaBlock  BlockID
 	_freeList   BlockID
 	_blockCount uint64
+	//the version of the checkpoint
+	_version uint64
 }
 
 func (header *DatabaseHeader) Serialize(serial util.Serialize) error {
@@ -195,6 +212,10 @@ func (header *DatabaseHeader) Serialize(serial util.Serialize) error {
 	if err != nil {
 		return err
 	}
+	err = util.Write[uint64](header._version, serial)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -215,6 +236,15 @@ func (header *DatabaseHeader) Deserialize(deserial *BufferedDeserializer) error
 	if err != nil {
 		return err
 	}
+	err = util.Read[uint64](&header._version, deserial)
+	if err != nil {
+		return err
+	}
+	//the header of the version 1 has no version.
+	//the rest of the header is zero.
+	if header._version == 0 {
+		header._version = 1
+	}
 	return nil
 }
 
@@ -223,7 +253,10 @@ const (
 	MAGIC_BYTE_OFFSET uint64 = BLOCK_HEADER_SIZE
 	FLAG_COUNT        uint64 = 4
 	magic                    = "plan"
-	VERSION_NUMBER    uint64 = 1
+	//2: the checkpoint has the macros of the schema
+	VERSION_NUMBER uint64 = 2
+	//the first version that has the macros in the checkpoint
+	VERSION_MACRO uint64 = 2
 )
 
 type MainHeader struct {
@@ -262,10 +295,21 @@ func (header *MainHeader) Serialize(serial util.Serialize) error {
 }
 
 func (header *MainHeader) Deserialize(deserial util.Deserialize) error {
-	err := util.Read[uint64](&header._versionNumber, deserial)
+	err := deserial.ReadData(header._magicByte[:], int(MAGIC_BYTE_SIZE))
+	if err != nil {
+		return err
+	}
+	if string(header._magicByte[:]) != magic {
+		return fmt.Errorf("not a database file")
+	}
+	err = util.Read[uint64](&header._versionNumber, deserial)
 	if err != nil {
 		return err
 	}
+	if header._versionNumber > VERSION_NUMBER {
+		return fmt.Errorf("unsupported database version %d. the latest is %d",
+			header._versionNumber, VERSION_NUMBER)
+	}
 	//field reader
 	reader, err := NewFieldReader(deserial)
 	if err != nil {
@@ -17,6 +17,7 @@ type BlockMgr interface {
 	MarkBlockAsModified(BlockID)
 	IncreaseBlockReferenceCount(BlockID)
 	GetMetaBlock() BlockID
+	GetVersion() uint64
 	Read(block *Block) error
 	Write2(*FileBuffer, BlockID) error
 	Write(block *Block) error
@@ -94,6 +95,11 @@ func (impl *MemoryBlockMgr) GetMetaBlock() BlockID {
 	panic("implement me")
 }
 
+func (impl *MemoryBlockMgr) GetVersion() uint64 {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (impl *MemoryBlockMgr) Read(block *Block) error {
 	//TODO implement me
 	panic("implement me")
@@ -201,7 +207,9 @@ type FileBlockMgr struct {
 	_maxBlock       BlockID
 	_freeListId     BlockID
 	_iterationCount uint64
-	_blockLock      sync.Mutex
+	//the version of the active checkpoint
+	_version   uint64
+	_blockLock sync.Mutex
 }
 
 func NewFileBlockMgr(
@@ -281,6 +289,8 @@ func (mgr *FileBlockMgr) ConvertToPersistent(
 	util.AssertFunc(oldBlock._buffer != nil)
 	util.AssertFunc(oldBlock._buffer.AllocSize() <= BLOCK_ALLOC_SIZE)
 
+	//the cached block of the reused id is stale
+	mgr.invalidateBlock(id)
 	//register new block
 	newBlock := mgr.RegisterBlock(id, false)
 	util.AssertFunc(newBlock._state.Load().(BlockState) == UNLOADED)
@@ -354,6 +364,7 @@ func (mgr *FileBlockMgr) CreateNewDatabase() error {
 	h1._metaBlock = -1
 	h1._freeList = -1
 	h1._blockCount = 0
+	h1._version = VERSION_NUMBER
 	err = SerializeDatabaseHeader(&h1, mgr._headerBuffer)
 	if err != nil {
 		return err
@@ -368,6 +379,7 @@ func (mgr *FileBlockMgr) CreateNewDatabase() error {
 	h2._metaBlock = -1
 	h2._freeList = -1
 	h2._blockCount = 0
+	h2._version = VERSION_NUMBER
 	err = SerializeDatabaseHeader(&h2, mgr._headerBuffer)
 	if err != nil {
 		return err
@@ -383,6 +395,7 @@ func (mgr *FileBlockMgr) CreateNewDatabase() error {
 	mgr._iterationCount = 0
 	mgr._activeHeader = 1
 	mgr._maxBlock = 0
+	mgr._version = VERSION_NUMBER
 	return nil
 }
 
@@ -465,6 +478,7 @@ func (mgr *FileBlockMgr) Init(header *DatabaseHeader) {
 	mgr._freeListId = header._freeList
 	mgr._metaBlock = header._metaBlock
 	mgr._iterationCount = header._iteration
+	mgr._version = header._version
 	mgr._maxBlock = BlockID(header._blockCount)
 }
 
@@ -570,6 +584,8 @@ func (mgr *FileBlockMgr) WriteHeader(header *DatabaseHeader) error {
 		header._freeList = -1
 	}
 	header._blockCount = uint64(mgr._maxBlock)
+	//the checkpoint is written in the latest version
+	header._version = VERSION_NUMBER
 	err := mgr._handle.Sync()
 	if err != nil {
 		return err
@@ -592,6 +608,7 @@ func (mgr *FileBlockMgr) WriteHeader(header *DatabaseHeader) error {
 		return err
 	}
 	mgr._activeHeader = (mgr._activeHeader + 1) % 2
+	mgr._version = header._version
 	return mgr._handle.Sync()
 }
 
@@ -631,9 +648,20 @@ func (mgr *FileBlockMgr) GetFreeBlockId() BlockID {
 	return block
 }
 func (mgr *FileBlockMgr) Write(block *Block) error {
+	mgr.invalidateBlock(block.id)
 	return mgr.Write2(block.FileBuffer, block.id)
 }
 
+// invalidateBlock unloads the cached block of the id
+func (mgr *FileBlockMgr) invalidateBlock(id BlockID) {
+	mgr._blocksLock.Lock()
+	handle, ok := mgr._blocks[id]
+	mgr._blocksLock.Unlock()
+	if ok {
+		mgr._bufferMgr.Invalidate(handle)
+	}
+}
+
 func (mgr *FileBlockMgr) Write2(buffer *FileBuffer, id BlockID) error {
 	return mgr.ChecksumAndWrite(
 		buffer,
@@ -686,6 +714,10 @@ func (mgr *FileBlockMgr) GetMetaBlock() BlockID {
 	return mgr._metaBlock
 }
 
+func (mgr *FileBlockMgr) GetVersion() uint64 {
+	return mgr._version
+}
+
 func (mgr *FileBlockMgr) TotalBlocks() uint64 {
 	mgr._blockLock.Lock()
 	defer mgr._blockLock.Unlock()
@@ -15,8 +15,11 @@
 package storage
 
 import (
+	"container/list"
+	"encoding/binary"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -35,6 +38,8 @@ type AllocateData struct {
 }
 
 type Allocator struct {
+	//the memory is accounted on the buffer manager if it is not nil
+	_bufferMgr *BufferManager
 }
 
 func NewAllocator() *Allocator {
@@ -42,6 +47,7 @@ func NewAllocator() *Allocator {
 }
 
 func (alloc *Allocator) AllocateData(sz uint64) unsafe.Pointer {
+	alloc.reserve(int64(sz))
 	ptr := C.malloc(C.size_t(sz))
 	if ptr == nil {
 		panic(fmt.Sprintf("allocate %d bytes failed.", sz))
@@ -51,9 +57,11 @@ func (alloc *Allocator) AllocateData(sz uint64) unsafe.Pointer {
 
 func (alloc *Allocator) FreeData(ptr unsafe.Pointer, sz uint64) {
 	C.free(ptr)
+	alloc.reserve(-int64(sz))
 }
 
 func (alloc *Allocator) ReallocateData(ptr unsafe.Pointer, oldSz, sz uint64) unsafe.Pointer {
+	alloc.reserve(int64(sz) - int64(oldSz))
 	ptr2 := C.realloc(ptr, C.size_t(sz))
 	if ptr2 == nil {
 		panic(fmt.Sprintf("realloc %d bytes failed.", sz))
@@ -61,6 +69,15 @@ func (alloc *Allocator) ReallocateData(ptr unsafe.Pointer, oldSz, sz uint64) uns
 	return ptr2
 }
 
+func (alloc *Allocator) reserve(sz int64) {
+	if alloc._bufferMgr == nil {
+		return
+	}
+	if err := alloc._bufferMgr.Reserve(sz); err != nil {
+		panic(err)
+	}
+}
+
 func (alloc *Allocator) Allocate(sz uint64) AllocateData {
 	ptr := alloc.AllocateData(sz)
 	return AllocateData{
@@ -76,6 +93,12 @@ type BufferManager struct {
 	_tempId       atomic.Uint64
 	_tempBlockMgr BlockMgr
 	_bufferAlloc  *Allocator
+	//the memory limit in bytes. 0 denotes no limit
+	_memoryLimit atomic.Int64
+	_usedMemory  atomic.Int64
+	//the unpinned persistent blocks. the least recently used one is at the back
+	_queueLock sync.Mutex
+	_queue     *list.List
 }
 
 var GBufferMgr *BufferManager
@@ -88,7 +111,9 @@ func NewBufferManager(tmp string) *BufferManager {
 	ret := &BufferManager{
 		_tempDir:     tmp,
 		_bufferAlloc: NewAllocator(),
+		_queue:       list.New(),
 	}
+	ret._bufferAlloc._bufferMgr = ret
 	ret._tempId.Store(uint64(MAX_BLOCK))
 	ret._tempBlockMgr = NewMemoryBlockMgr(ret)
 
@@ -171,12 +196,15 @@ func (mgr *BufferManager) Pin(handle *BlockHandle) *BufferHandle {
 	handle.Lock()
 	defer handle.Unlock()
 	if handle._state.Load().(BlockState) == LOADED {
+		mgr.dequeue(handle)
 		handle._readers.Add(1)
 		return handle.Load(handle, nil)
 	}
 	util.AssertFunc(handle._readers.Load() == 0)
+	//the block is not pinned if it runs out of memory
+	buf := handle.Load(handle, nil)
 	handle._readers.Store(1)
-	return handle.Load(handle, nil)
+	return buf
 }
 
 func (mgr *BufferManager) Unpin(handle *BlockHandle) {
@@ -188,8 +216,108 @@ func (mgr *BufferManager) Unpin(handle *BlockHandle) {
 	util.AssertFunc(handle._readers.Load() > 0)
 	handle._readers.Add(-1)
 	if handle._readers.Load() == 0 {
-		handle.Close()
+		if handle._blockId < MAX_BLOCK && mgr._memoryLimit.Load() > 0 {
+			//the persistent block stays in memory until it is evicted
+			mgr.enqueue(handle)
+		} else {
+			handle.Close()
+		}
+	}
+}
+
+// Offload writes the temporary block into the temporary directory
+// and releases its memory. The buffer handle is the only pin on the block.
+// The Pin loads the block from the temporary directory again.
+func (mgr *BufferManager) Offload(buf *BufferHandle) error {
+	handle := buf._handle
+	handle.Lock()
+	defer handle.Unlock()
+	util.AssertFunc(handle._blockId >= MAX_BLOCK && !handle._canDestroy)
+	util.AssertFunc(handle._readers.Load() == 1)
+	err := mgr.WriteTemporaryBuffer(handle._blockId, handle._buffer)
+	if err != nil {
+		return err
+	}
+	handle._offloaded = true
+	handle._readers.Store(0)
+	handle.Unload()
+	buf._handle = nil
+	buf._node = nil
+	return nil
+}
+
+// Destroy releases the memory and the temporary file of the block
+// even if it is pinned.
+func (mgr *BufferManager) Destroy(handle *BlockHandle) {
+	handle.Lock()
+	defer handle.Unlock()
+	handle._readers.Store(0)
+	if handle._state.Load().(BlockState) == LOADED {
+		handle._buffer.Close()
+		handle._buffer = nil
+		handle._state.Store(UNLOADED)
 	}
+	if handle._offloaded {
+		mgr.DeleteTemporaryFile(handle._blockId)
+		handle._offloaded = false
+	}
+}
+
+// RequireTemporaryDirectory creates the temporary directory if it does not exist
+func (mgr *BufferManager) RequireTemporaryDirectory() error {
+	mgr._tempLock.Lock()
+	defer mgr._tempLock.Unlock()
+	return os.MkdirAll(mgr._tempDir, 0755)
+}
+
+func (mgr *BufferManager) temporaryFilePath(id BlockID) string {
+	return filepath.Join(mgr._tempDir, fmt.Sprintf("plan_temp_block-%d.block", id))
+}
+
+// WriteTemporaryBuffer writes the buffer into the file of the block
+// in the temporary directory. The size of the buffer goes first.
+func (mgr *BufferManager) WriteTemporaryBuffer(id BlockID, buffer *FileBuffer) error {
+	err := mgr.RequireTemporaryDirectory()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(mgr.temporaryFilePath(id), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	sz := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sz, buffer._size)
+	_, err = file.WriteAt(sz, 0)
+	if err != nil {
+		return err
+	}
+	return buffer.Write(file, uint64(len(sz)))
+}
+
+// ReadTemporaryBuffer reads the buffer of the block from the temporary directory
+func (mgr *BufferManager) ReadTemporaryBuffer(id BlockID) (*FileBuffer, error) {
+	file, err := os.Open(mgr.temporaryFilePath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sz := make([]byte, 8)
+	_, err = file.ReadAt(sz, 0)
+	if err != nil {
+		return nil, err
+	}
+	buffer := mgr.ConstructManagedBuffer(binary.LittleEndian.Uint64(sz), nil, MANAGED_BUFFER)
+	err = buffer.Read(file, uint64(len(sz)))
+	if err != nil {
+		buffer.Close()
+		return nil, err
+	}
+	return buffer, nil
+}
+
+func (mgr *BufferManager) DeleteTemporaryFile(id BlockID) {
+	_ = os.Remove(mgr.temporaryFilePath(id))
 }
 
 type FileBufferType int
@@ -253,6 +381,7 @@ func (fbuf *FileBuffer) Close() {
 	}
 	if fbuf._internalBuffer != nil {
 		fbuf._bufferAlloc.FreeData(fbuf._internalBuffer, fbuf._internalSize)
+		fbuf.Init()
 	}
 }
 
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/daviszhen/plan/pkg/util"
+)
+
+func Test_offload(t *testing.T) {
+	mgr := NewBufferManager(t.TempDir())
+	var block *BlockHandle
+	buf := mgr.Allocate(BLOCK_SIZE, false, &block)
+	data := util.PointerToSlice[byte](buf.Ptr(), int(BLOCK_SIZE))
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	//the block is in the temporary directory
+	require.NoError(t, mgr.Offload(buf))
+	_, err := os.Stat(mgr.temporaryFilePath(block._blockId))
+	require.NoError(t, err)
+	assert.Nil(t, block._buffer)
+
+	//load it again
+	buf = mgr.Pin(block)
+	data = util.PointerToSlice[byte](buf.Ptr(), int(BLOCK_SIZE))
+	for i := range data {
+		if data[i] != byte(i%251) {
+			require.Equal(t, byte(i%251), data[i])
+		}
+	}
+	buf.Close()
+	_, err = os.Stat(mgr.temporaryFilePath(block._blockId))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_evictBlocks(t *testing.T) {
+	mgr := NewBufferManager(t.TempDir())
+	blkMgr := NewFileBlockMgr(mgr, filepath.Join(t.TempDir(), "evict.db"), false)
+	require.NoError(t, blkMgr.CreateNewDatabase())
+	ids := make([]BlockID, 4)
+	for i := range ids {
+		ids[i] = blkMgr.GetFreeBlockId()
+		block := blkMgr.CreateBlock(ids[i], nil)
+		util.Memset(block._buffer, byte(i+1), int(block._size))
+		require.NoError(t, blkMgr.Write(block))
+		block.Close()
+	}
+	used := mgr.UsedMemory()
+	blockSize := int64(BLOCK_ALLOC_SIZE)
+	require.NoError(t, mgr.SetMemoryLimit(used+2*blockSize))
+
+	pin := func(i int) *BufferHandle {
+		buf := mgr.Pin(blkMgr.RegisterBlock(ids[i], false))
+		require.Equal(t, byte(i+1), *(*byte)(buf.Ptr()))
+		return buf
+	}
+	state := func(i int) BlockState {
+		return blkMgr.RegisterBlock(ids[i], false)._state.Load().(BlockState)
+	}
+
+	//the unpinned blocks stay in memory
+	pin(0).Close()
+	pin(1).Close()
+	assert.Equal(t, used+2*blockSize, mgr.UsedMemory())
+	assert.Equal(t, LOADED, state(0))
+
+	//the least recently used block is evicted
+	pin(0).Close()
+	pin(2).Close()
+	assert.Equal(t, LOADED, state(0))
+	assert.Equal(t, UNLOADED, state(1))
+	assert.Equal(t, LOADED, state(2))
+
+	//the pinned blocks can not be evicted
+	b0 := pin(0)
+	b2 := pin(2)
+	require.ErrorContains(t, mgr.Reserve(blockSize), "out of memory")
+	assert.Panics(t, func() { pin(3) })
+	b0.Close()
+	pin(3).Close()
+	assert.Equal(t, UNLOADED, state(0))
+	b2.Close()
+
+	//no block stays without the limit
+	require.NoError(t, mgr.SetMemoryLimit(0))
+	assert.Equal(t, used, mgr.UsedMemory())
+}
@@ -78,6 +78,16 @@ func (cat *Catalog) CreateTable(txn *Txn,
 	return schEnt.CreateTable(txn, info)
 }
 
+func (cat *Catalog) CreateMacro(txn *Txn,
+	info *MacroInfo,
+) (*CatalogEntry, error) {
+	schEnt := cat.GetSchema(txn, info._schema)
+	if schEnt == nil {
+		return nil, fmt.Errorf("no schema %s", info._schema)
+	}
+	return schEnt.CreateMacro(txn, info)
+}
+
 func (cat *Catalog) GetSchema(txn *Txn, schema string) *CatalogEntry {
 	ent := cat._schemas.GetEntry(txn, schema)
 	return ent
@@ -442,6 +452,7 @@ const (
 	CatalogTypeInvalid uint8 = 0
 	CatalogTypeTable   uint8 = 1
 	CatalogTypeSchema  uint8 = 2
+	CatalogTypeMacro   uint8 = 3
 	CatalogTypeDeleted uint8 = 50
 )
 
@@ -457,6 +468,7 @@ type CatalogEntry struct {
 	//for schema entry
 	_catalog *Catalog
 	_tables  *CatalogSet
+	_macros  *CatalogSet
 
 	//for table entry
 	_schema      *CatalogEntry
@@ -464,6 +476,11 @@ type CatalogEntry struct {
 	_storage     *DataTable
 	_colDefs     []*ColumnDefinition
 	_constraints []Constraint
+
+	//for macro entry
+	_params     []string
+	_body       string
+	_tableMacro bool
 }
 
 func (ent *CatalogEntry) GetStorage() *DataTable {
@@ -502,6 +519,19 @@ func (ent *CatalogEntry) CreateTable(
 	return retEnt, nil
 }
 
+func (ent *CatalogEntry) CreateMacro(
+	txn *Txn,
+	info *MacroInfo) (*CatalogEntry, error) {
+	//must be schema entry
+	util.AssertFunc(ent._typ == CatalogTypeSchema)
+	macroEnt := NewMacroEntry(ent._catalog, ent, info)
+	list := NewDependList()
+	for _, dep := range info._depends {
+		list.AddDepend(dep)
+	}
+	return ent.AddEntryInternal(txn, macroEnt, list)
+}
+
 func (ent *CatalogEntry) AddEntryInternal(
 	txn *Txn,
 	tabEnt *CatalogEntry,
@@ -523,6 +553,8 @@ func (ent *CatalogEntry) GetCatalogSet(typ uint8) *CatalogSet {
 	switch typ {
 	case CatalogTypeTable:
 		return ent._tables
+	case CatalogTypeMacro:
+		return ent._macros
 	default:
 		panic("usp")
 	}
@@ -562,6 +594,31 @@ func (ent *CatalogEntry) Serialize(serial util.Serialize) error {
 		if err != nil {
 			return err
 		}
+	case CatalogTypeMacro:
+		//schema
+		err = WriteString(ent._schName, writer)
+		if err != nil {
+			return err
+		}
+		//macro
+		err = WriteString(ent._name, writer)
+		if err != nil {
+			return err
+		}
+		//parameters
+		err = WriteStrings(ent._params, writer)
+		if err != nil {
+			return err
+		}
+		//body
+		err = WriteString(ent._body, writer)
+		if err != nil {
+			return err
+		}
+		err = WriteField[bool](ent._tableMacro, writer)
+		if err != nil {
+			return err
+		}
 	default:
 		panic("usp")
 	}
@@ -612,6 +669,31 @@ func (ent *CatalogEntry) Deserialize(source util.Deserialize) error {
 		if err != nil {
 			return err
 		}
+	case CatalogTypeMacro:
+		//schema
+		ent._schName, err = ReadString(reader)
+		if err != nil {
+			return err
+		}
+		//macro
+		ent._name, err = ReadString(reader)
+		if err != nil {
+			return err
+		}
+		//parameters
+		ent._params, err = ReadStrings(reader)
+		if err != nil {
+			return err
+		}
+		//body
+		ent._body, err = ReadString(reader)
+		if err != nil {
+			return err
+		}
+		err = ReadRequired[bool](&ent._tableMacro, reader)
+		if err != nil {
+			return err
+		}
 	default:
 		panic("usp")
 	}
@@ -699,6 +781,7 @@ func NewSchemaEntry(
 		_catalog: catalog,
 		_name:    schema,
 		_tables:  NewCatalogSet(catalog),
+		_macros:  NewCatalogSet(catalog),
 	}
 
 	return ret
@@ -752,6 +835,74 @@ func NewTableEntry(
 	return ret, nil
 }
 
+// MacroInfo describes the macro.
+// The body is the expression of the scalar macro
+// or the query of the table macro.
+type MacroInfo struct {
+	_schema     string
+	_name       string
+	_params     []string
+	_body       string
+	_tableMacro bool
+	//the tables and the macros used in the body
+	_depends []*CatalogEntry
+}
+
+func NewMacroInfo(schema, name string,
+	params []string,
+	body string,
+	tableMacro bool,
+	depends []*CatalogEntry,
+) *MacroInfo {
+	return &MacroInfo{
+		_schema:     schema,
+		_name:       name,
+		_params:     params,
+		_body:       body,
+		_tableMacro: tableMacro,
+		_depends:    depends,
+	}
+}
+
+func NewMacroEntry(
+	catalog *Catalog,
+	schEnt *CatalogEntry,
+	info *MacroInfo,
+) *CatalogEntry {
+	return &CatalogEntry{
+		_typ:        CatalogTypeMacro,
+		_catalog:    catalog,
+		_schema:     schEnt,
+		_schName:    info._schema,
+		_name:       info._name,
+		_params:     info._params,
+		_body:       info._body,
+		_tableMacro: info._tableMacro,
+	}
+}
+
+// macroInfo returns the info of the deserialized macro entry.
+// the dependencies are not serialized.
+func (ent *CatalogEntry) macroInfo() *MacroInfo {
+	return NewMacroInfo(ent._schName, ent._name, ent._params, ent._body, ent._tableMacro, nil)
+}
+
+func (ent *CatalogEntry) GetName() string {
+	return ent._name
+}
+
+func (ent *CatalogEntry) GetMacroParams() []string {
+	return ent._params
+}
+
+func (ent *CatalogEntry) GetMacroBody() string {
+	return ent._body
+}
+
+func (ent *CatalogEntry) IsTableMacro() bool {
+	return ent._tableMacro
+}
+
 func catalogEntryLess(a, b *CatalogEntry) bool {
 	if a._typ < b._typ {
 		return true
@@ -126,6 +126,27 @@ func (ckpWriter *CheckpointWriter) WriteSchema(schEnt *CatalogEntry) error {
 			return err
 		}
 	}
+
+	//write macro
+	macros := make([]*CatalogEntry, 0)
+	schEnt.Scan(CatalogTypeMacro, func(ent *CatalogEntry) {
+		macros = append(macros, ent)
+	})
+	writer = NewFieldWriter(ckpWriter.GetMetaBlockWriter())
+	err = WriteField[uint32](uint32(len(macros)), writer)
+	if err != nil {
+		return err
+	}
+	err = writer.Finalize()
+	if err != nil {
+		return err
+	}
+	for _, macro := range macros {
+		err = macro.Serialize(ckpWriter.GetMetaBlockWriter())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDatabase replaces the catalog and the storage with
+// the database in the path. it is like restarting the process.
+func openTestDatabase(t *testing.T, path string) {
+	GCatalog = NewCatalog()
+	require.NoError(t, GCatalog.Init())
+	GStorageMgr = NewStorageMgr(path, false)
+	require.NoError(t, GStorageMgr.LoadDatabase())
+}
+
+func Test_macroCheckpoint(t *testing.T) {
+	oldCatalog, oldStorage := GCatalog, GStorageMgr
+	t.Cleanup(func() {
+		GCatalog, GStorageMgr = oldCatalog, oldStorage
+	})
+	path := filepath.Join(t.TempDir(), "macro")
+	openTestDatabase(t, path)
+	assert.Equal(t, VERSION_NUMBER, GStorageMgr._blockMgr.GetVersion())
+
+	txn, err := GTxnMgr.NewTxn("create macro")
+	require.NoError(t, err)
+	_, err = GCatalog.CreateMacro(txn, NewMacroInfo("public", "add1", []string{"x"}, "x + 1", false, nil))
+	require.NoError(t, err)
+	require.NoError(t, GTxnMgr.Commit(txn))
+
+	checkMacro := func() {
+		txn, err := GTxnMgr.NewTxn("lookup macro")
+		require.NoError(t, err)
+		defer GTxnMgr.Rollback(txn)
+		ent := GCatalog.GetEntry(txn, CatalogTypeMacro, "public", "add1")
+		require.NotNil(t, ent)
+		assert.Equal(t, []string{"x"}, ent.GetMacroParams())
+		assert.Equal(t, "x + 1", ent.GetMacroBody())
+	}
+
+	//the wal replay
+	openTestDatabase(t, path)
+	checkMacro()
+
+	//the checkpoint
+	require.NoError(t, GStorageMgr.CreateCheckpoint(false, true))
+	assert.Zero(t, GStorageMgr._wal.GetWalSize())
+	openTestDatabase(t, path)
+	checkMacro()
+}
+
+func Test_databaseHeaderVersion(t *testing.T) {
+	//the header of the version 1 is followed by zeros
+	buf := make([]byte, FILE_HEADER_SIZE)
+	serial := NewBufferedSerialize(buf[:0])
+	old := DatabaseHeader{_iteration: 3, _metaBlock: 7, _freeList: -1, _blockCount: 9}
+	require.NoError(t, old.Serialize(serial))
+	serial.Close()
+
+	var header DatabaseHeader
+	require.NoError(t, header.Deserialize(NewBufferedDeserializer(buf)))
+	assert.Equal(t, uint64(1), header._version)
+	assert.Equal(t, BlockID(7), header._metaBlock)
+}
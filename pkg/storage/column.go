@@ -32,6 +32,9 @@ type ColumnData struct {
 	_version     IdxType
 	_validity    *ColumnData
 	_stats       *SegmentStats
+	//LIST, STRUCT and MAP are stored as the encoded values in VARCHAR.
+	//_typ is VARCHAR then.
+	_nestedTyp common.LType
 }
 
 func NewColumnData(
@@ -42,6 +45,11 @@ func NewColumnData(
 	typ common.LType,
 	parent *ColumnData,
 ) *ColumnData {
+	var nestedTyp common.LType
+	if typ.IsNested() {
+		nestedTyp = typ
+		typ = common.VarcharType()
+	}
 	ret := &ColumnData{
 		_cdType:      ColumnDataTypeStandard,
 		_blockMgr:    mgr,
@@ -52,6 +60,7 @@ func NewColumnData(
 		_version:     0,
 		_parent:      parent,
 		_data:        NewColumnSegmentTree(),
+		_nestedTyp:   nestedTyp,
 	}
 	ret._validity = &ColumnData{
 		_cdType:      ColumnDataTypeValidity,
@@ -109,6 +118,9 @@ func (column *ColumnData) Append(
 	state *ColumnAppendState,
 	vector *chunk.Vector,
 	cnt IdxType) {
+	if column.isNested() {
+		vector = chunk.EncodeVector(vector, int(cnt))
+	}
 	var vdata chunk.UnifiedFormat
 	vector.ToUnifiedFormat(int(cnt), &vdata)
 	column.AppendData(&column._stats._stats, state, &vdata, cnt)
@@ -144,6 +156,10 @@ func (column *ColumnData) AppendData(
 	}
 }
 
+func (column *ColumnData) isNested() bool {
+	return column._nestedTyp.IsNested()
+}
+
 func (column *ColumnData) SetStart(newStart IdxType) {
 	column._start = newStart
 	offset := IdxType(0)
@@ -209,6 +225,23 @@ func (column *ColumnData) ScanVector(
 	result *chunk.Vector,
 	scanCommitted bool,
 	allowUpdates bool,
+) IdxType {
+	if column.isNested() {
+		encoded := chunk.NewFlatVector(column._typ, STANDARD_VECTOR_SIZE)
+		scanCount := column.scanVector(txn, vecIdx, state, encoded, scanCommitted, allowUpdates)
+		chunk.DecodeVector(encoded, result, int(scanCount))
+		return scanCount
+	}
+	return column.scanVector(txn, vecIdx, state, result, scanCommitted, allowUpdates)
+}
+
+func (column *ColumnData) scanVector(
+	txn *Txn,
+	vecIdx IdxType,
+	state *ColumnScanState,
+	result *chunk.Vector,
+	scanCommitted bool,
+	allowUpdates bool,
 ) IdxType {
 	scanCount := column.ScanVector2(state, result, STANDARD_VECTOR_SIZE)
 	column._updateLock.Lock()
@@ -252,7 +285,7 @@ func (column *ColumnData) ScanVector2(
 	if state._internalIdx < state._rowIdx {
 		state._current.Skip(state)
 	}
-	util.AssertFunc(state._current._type == column._typ)
+	util.AssertFunc(state._current._type.Equal(column._typ))
 	initialRemaining := remaining
 	for remaining > 0 {
 		util.AssertFunc(state._rowIdx >= state._current.Start() &&
@@ -327,6 +360,9 @@ func (column *ColumnData) Update(
 	updateVec *chunk.Vector,
 	rowIds []RowType,
 	updateCount IdxType) {
+	if column.isNested() {
+		updateVec = chunk.EncodeVector(updateVec, int(updateCount))
+	}
 	column._updateLock.Lock()
 	defer column._updateLock.Unlock()
 	if column._updates == nil {
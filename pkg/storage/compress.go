@@ -157,7 +157,7 @@ func GetUncompressedCompressFunction(
 	var cfun *CompressFunction
 	switch typ {
 	case common.INT32, common.INT64, common.UINT64,
-		common.BIT, common.DECIMAL, common.DATE:
+		common.BIT, common.DECIMAL, common.DATE, common.INTERVAL:
 		cfun = &CompressFunction{
 			_typ:              CompressTypeUncompressed,
 			_dataType:         typ,
@@ -198,7 +198,7 @@ func GetUncompressedCompressFunction(
 
 func getFixedSizeAppend(typ common.PhyType) CompressAppend {
 	switch typ {
-	case common.INT32, common.INT64, common.BIT, common.UINT64, common.DECIMAL, common.DATE:
+	case common.INT32, common.INT64, common.BIT, common.UINT64, common.DECIMAL, common.DATE, common.INTERVAL:
 		return FixedSizeAppend
 	default:
 		panic("usp")
@@ -208,7 +208,7 @@ func getFixedSizeAppend(typ common.PhyType) CompressAppend {
 
 func getFixedSizeFinalizeAppend(typ common.PhyType) CompressFinalizeAppend {
 	switch typ {
-	case common.INT32, common.INT64, common.BIT, common.UINT64, common.DECIMAL, common.DATE:
+	case common.INT32, common.INT64, common.BIT, common.UINT64, common.DECIMAL, common.DATE, common.INTERVAL:
 		return FixedSizeFinalizeAppend
 	default:
 		panic("usp")
@@ -356,6 +356,26 @@ func getAppender(
 			)
 		}
 		return temp
+	case common.INTERVAL:
+		temp := func(
+			stats *SegmentStats,
+			target unsafe.Pointer,
+			targetOffset IdxType,
+			data *chunk.UnifiedFormat,
+			offset IdxType,
+			count IdxType) {
+			StandardFixedSizeAppend[common.Interval](
+				stats,
+				target,
+				targetOffset,
+				data,
+				offset,
+				count,
+				chunk.IntervalScatterOp{},
+				IntervalStatsOp{},
+			)
+		}
+		return temp
 	default:
 		panic("usp")
 	}
@@ -0,0 +1,140 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+)
+
+/*
+Memory limit of the buffer manager.
+
+The memory of the buffers and the operators is reserved on the
+buffer manager before it is allocated. If the used memory exceeds the
+limit, the unpinned persistent blocks are evicted in the LRU order.
+The evicted block is read from the database file again when it is pinned.
+
+The reservation fails with the out of memory error if nothing more
+can be evicted.
+
+Without the limit, the persistent block is released when it is unpinned.
+*/
+
+// SetMemoryLimit sets the memory limit in bytes. 0 denotes no limit.
+// The blocks are evicted to fit the new limit.
+func (mgr *BufferManager) SetMemoryLimit(limit int64) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid memory limit %d", limit)
+	}
+	old := mgr._memoryLimit.Swap(limit)
+	if limit == 0 {
+		//no block is kept after it is unpinned
+		mgr.evictBlocks(0)
+		return nil
+	}
+	if !mgr.evictBlocks(limit) {
+		mgr._memoryLimit.Store(old)
+		return fmt.Errorf("out of memory: can not set the memory limit to %d bytes (%d bytes used)",
+			limit, mgr._usedMemory.Load())
+	}
+	return nil
+}
+
+func (mgr *BufferManager) MemoryLimit() int64 {
+	return mgr._memoryLimit.Load()
+}
+
+func (mgr *BufferManager) UsedMemory() int64 {
+	return mgr._usedMemory.Load()
+}
+
+// Reserve accounts the memory before it is allocated.
+// The negative size releases the memory.
+func (mgr *BufferManager) Reserve(sz int64) error {
+	used := mgr._usedMemory.Add(sz)
+	limit := mgr._memoryLimit.Load()
+	if sz <= 0 || limit == 0 || used <= limit {
+		return nil
+	}
+	if mgr.evictBlocks(limit) {
+		return nil
+	}
+	mgr._usedMemory.Add(-sz)
+	return fmt.Errorf("out of memory: failed to allocate %d bytes (%d/%d bytes used)",
+		sz, mgr._usedMemory.Load(), limit)
+}
+
+// Release accounts the memory after it is freed
+func (mgr *BufferManager) Release(sz int64) {
+	mgr._usedMemory.Add(-sz)
+}
+
+// evictBlocks unloads the unpinned blocks from the least recently used one
+// until the used memory is under the limit.
+// It returns false if nothing more can be evicted.
+func (mgr *BufferManager) evictBlocks(limit int64) bool {
+	mgr._queueLock.Lock()
+	defer mgr._queueLock.Unlock()
+	elem := mgr._queue.Back()
+	for elem != nil && (limit == 0 || mgr._usedMemory.Load() > limit) {
+		prev := elem.Prev()
+		handle := elem.Value.(*BlockHandle)
+		//the block being pinned or unpinned is skipped
+		if handle.TryLock() {
+			if handle.CanUnload() {
+				mgr._queue.Remove(elem)
+				handle._queueElem = nil
+				handle.Unload()
+			}
+			handle.Unlock()
+		}
+		elem = prev
+	}
+	return limit == 0 || mgr._usedMemory.Load() <= limit
+}
+
+// enqueue puts the unpinned block at the front of the queue.
+// The lock of the block is held.
+func (mgr *BufferManager) enqueue(handle *BlockHandle) {
+	mgr._queueLock.Lock()
+	defer mgr._queueLock.Unlock()
+	if handle._queueElem != nil {
+		mgr._queue.MoveToFront(handle._queueElem)
+		return
+	}
+	handle._queueElem = mgr._queue.PushFront(handle)
+}
+
+// dequeue removes the block from the queue.
+// The lock of the block is held.
+func (mgr *BufferManager) dequeue(handle *BlockHandle) {
+	mgr._queueLock.Lock()
+	defer mgr._queueLock.Unlock()
+	if handle._queueElem != nil {
+		mgr._queue.Remove(handle._queueElem)
+		handle._queueElem = nil
+	}
+}
+
+// Invalidate unloads the unpinned block before its data
+// in the database file is overwritten.
+func (mgr *BufferManager) Invalidate(handle *BlockHandle) {
+	handle.Lock()
+	defer handle.Unlock()
+	mgr.dequeue(handle)
+	if handle.CanUnload() {
+		handle.Unload()
+	}
+}
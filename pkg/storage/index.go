@@ -290,7 +290,7 @@ func (idx *Index) Insert(
 	rowIds *chunk.Vector) error {
 	util.AssertFunc(rowIds.Typ().GetInternalType() == common.UINT64)
 	util.AssertFunc(
-		idx._logicalTypes[0] == input.Data[0].Typ(),
+		idx._logicalTypes[0].Equal(input.Data[0].Typ()),
 	)
 	//one key for one row
 	keys := make([]*IndexKey, input.Card())
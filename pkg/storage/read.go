@@ -143,6 +143,8 @@ func (reader *FieldReader) AddField() {
 
 type FileCheckpointReader struct {
 	_storage *StorageMgr
+	//the version of the checkpoint
+	_version uint64
 }
 
 func NewFileCheckpointReader(
@@ -155,6 +157,7 @@ func NewFileCheckpointReader(
 
 func (reader *FileCheckpointReader) LoadFromStorage() (retErr error) {
 	bmgr := reader._storage._blockMgr
+	reader._version = bmgr.GetVersion()
 	metaBlock := bmgr.GetMetaBlock()
 	if metaBlock < 0 {
 		return nil
@@ -234,6 +237,32 @@ func (reader *FileCheckpointReader) ReadSchema(mReader *MetaBlockReader, txn *Tx
 			return err
 		}
 	}
+
+	if reader._version < VERSION_MACRO {
+		return nil
+	}
+	fReader, err = NewFieldReader(mReader)
+	if err != nil {
+		return err
+	}
+	macroCnt := uint32(0)
+	err = ReadRequired[uint32](&macroCnt, fReader)
+	if err != nil {
+		return err
+	}
+	fReader.Finalize()
+
+	for i := uint32(0); i < macroCnt; i++ {
+		macroEnt := &CatalogEntry{}
+		err = macroEnt.Deserialize(mReader)
+		if err != nil {
+			return err
+		}
+		_, err = GCatalog.CreateMacro(txn, macroEnt.macroInfo())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -31,6 +31,8 @@ func (state *ReplayState) ReplayEntry(txn *Txn, walTyp uint8) error {
 		return state.replayCreateTable(txn)
 	case WAL_CREATE_SCHEMA:
 		return state.replayCreateSchema(txn)
+	case WAL_CREATE_MACRO:
+		return state.replayCreateMacro(txn)
 	case WAL_USE_TABLE:
 		return state.replayUseTable(txn)
 	case WAL_INSERT_TUPLE:
@@ -194,6 +196,19 @@ func (state *ReplayState) replayCreateTable(txn *Txn) error {
 	return err
 }
 
+func (state *ReplayState) replayCreateMacro(txn *Txn) error {
+	macroEnt := &CatalogEntry{}
+	err := macroEnt.Deserialize(state._source)
+	if err != nil {
+		return err
+	}
+	if state._deserializeOnly {
+		return nil
+	}
+	_, err = GCatalog.CreateMacro(txn, macroEnt.macroInfo())
+	return err
+}
+
 func Replay(path string) (bool, error) {
 	fmt.Println("Replay...")
 	start := time.Now()
@@ -112,6 +112,9 @@ func (rg *RowGroup) Append(
 	state *RowGroupAppendState,
 	data *chunk.Chunk,
 	cnt IdxType) {
+	//the stats are checked by the zone map of the scan
+	rg._statsLock.Lock()
+	defer rg._statsLock.Unlock()
 	for i := 0; i < rg.ColumnCount(); i++ {
 		col := rg.GetColumn(i)
 		col.Append(state._states[i], data.Data[i], cnt)
@@ -168,6 +171,9 @@ func (rg *RowGroup) InitScan(state *CollectionScanState) bool {
 	if state._maxRowGroupRow == 0 {
 		return false
 	}
+	if !rg.CheckZonemap(state) {
+		return false
+	}
 	for i, idx := range colIds {
 		if idx != COLUMN_IDENTIFIER_ROW_ID {
 			col := rg.GetColumn(int(idx))
@@ -197,12 +203,24 @@ func (rg *RowGroup) TemplatedScan(txn *Txn, state *CollectionScanState, result *
 		count := IdxType(0)
 		validSel := chunk.NewSelectVector(STANDARD_VECTOR_SIZE)
 		if scanTyp == TableScanTypeRegular {
+			sample := state.GetSample()
+			if sample != nil && !sample.KeepVector(rg.Start()+currentRow) {
+				rg.NextVector(state)
+				continue
+			}
+			if !rg.CheckZonemapVector(state, currentRow, maxCount) {
+				rg.NextVector(state)
+				continue
+			}
 			count = state._rowGroup.GetSelVector(
 				txn,
 				state._vectorIdx,
 				validSel,
 				maxCount,
 			)
+			if sample != nil && count != 0 {
+				count = sample.FilterRows(rg.Start()+currentRow, validSel, count, count == maxCount)
+			}
 			if count == 0 {
 				rg.NextVector(state)
 				continue
@@ -1040,8 +1058,16 @@ func (collect *RowGroupCollection) InitScan(
 		IdxType(collect._totalRows.Load())
 	state.Init(collect._types)
 	for rg != nil && !rg.InitScan(state) {
-		rg = collect._rowGroups.GetNextSegment(nil, rg).(*RowGroup)
+		rg, _ = collect._rowGroups.GetNextSegment(nil, rg).(*RowGroup)
 	}
+	state._rowGroup = rg
+}
+
+func (collect *RowGroupCollection) InitParallelScan(state *ParallelCollectionScanState) {
+	state._collection = collect
+	state._current, _ = collect._rowGroups.GetRootSegment(nil).(*RowGroup)
+	state._maxRow = collect._rowStart +
+		IdxType(collect._totalRows.Load())
 }
 
 func (collect *RowGroupCollection) Delete(
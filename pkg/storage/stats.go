@@ -184,8 +184,8 @@ func numericStatsHasMax(stats *BaseStats) bool {
 
 func numericStatsUpdateMin(stats, other *BaseStats) {
 	util.AssertFunc(stats._typ.Id == other._typ.Id)
-	minVal := stats._numericData._min._value
-	oMinVal := other._numericData._min._value
+	minVal := &stats._numericData._min._value
+	oMinVal := &other._numericData._min._value
 	switch stats._typ.GetInternalType() {
 	case common.BOOL:
 		//false < true
@@ -219,33 +219,33 @@ func numericStatsUpdateMin(stats, other *BaseStats) {
 
 func numericStatsUpdateMax(stats, other *BaseStats) {
 	util.AssertFunc(stats._typ.Id == other._typ.Id)
-	minVal := stats._numericData._min._value
-	oMinVal := other._numericData._min._value
+	maxVal := &stats._numericData._max._value
+	oMaxVal := &other._numericData._max._value
 	switch stats._typ.GetInternalType() {
 	case common.BOOL:
 		//false < true
-		if oMinVal._bool && !minVal._bool {
-			minVal._bool = true
+		if oMaxVal._bool && !maxVal._bool {
+			maxVal._bool = true
 		}
 	case common.INT32:
-		if oMinVal._int32 > minVal._int32 {
-			minVal._int32 = oMinVal._int32
+		if oMaxVal._int32 > maxVal._int32 {
+			maxVal._int32 = oMaxVal._int32
 		}
 	case common.INT64:
-		if oMinVal._int64 > minVal._int64 {
-			minVal._int64 = oMinVal._int64
+		if oMaxVal._int64 > maxVal._int64 {
+			maxVal._int64 = oMaxVal._int64
 		}
 	case common.UINT64:
-		if oMinVal._uint64 > minVal._uint64 {
-			minVal._uint64 = oMinVal._uint64
+		if oMaxVal._uint64 > maxVal._uint64 {
+			maxVal._uint64 = oMaxVal._uint64
 		}
 	case common.DECIMAL:
-		if oMinVal._decimal.Decimal.Cmp(minVal._decimal.Decimal) > 0 {
-			minVal._decimal.Decimal = oMinVal._decimal.Decimal
+		if oMaxVal._decimal.Decimal.Cmp(maxVal._decimal.Decimal) > 0 {
+			maxVal._decimal.Decimal = oMaxVal._decimal.Decimal
 		}
 	case common.DATE:
-		if minVal._date.Less(&oMinVal._date) {
-			minVal._date = oMinVal._date
+		if maxVal._date.Less(&oMaxVal._date) {
+			maxVal._date = oMaxVal._date
 		}
 	default:
 		panic("usp")
@@ -274,8 +274,8 @@ func stringStatsMerge(stats, other *BaseStats) {
 	if other._typ.Id == common.LTID_VALIDITY {
 		return
 	}
-	sdata := stats._stringData
-	osdata := other._stringData
+	sdata := &stats._stringData
+	osdata := &other._stringData
 	if bytes.Compare(osdata._min[:], sdata._min[:]) < 0 {
 		copy(sdata._min[:], osdata._min[:])
 	}
@@ -353,7 +353,10 @@ func (stats *BaseStats) Deserialize(deserial util.Deserialize, lType common.LTyp
 	}
 	switch GetStatsType(lType) {
 	case StatsTypeNumeric:
-		err = numericStatsDeserialize(stats, reader, lType)
+		//the min/max of the date is not written by the old versions
+		if reader._fieldCount < reader._maxFieldCount {
+			err = numericStatsDeserialize(stats, reader, lType)
+		}
 	case StatsTypeString:
 		err = stringStatsDeserialize(stats, reader, lType)
 	}
@@ -375,7 +378,7 @@ func (stats *BaseStats) HasNoNull() bool {
 
 func stringStatsDeserialize(stats *BaseStats, reader *FieldReader, ltyp common.LType) error {
 	stats._typ = ltyp
-	sdata := stats._stringData
+	sdata := &stats._stringData
 	err := ReadBlob(sdata._min[:], reader)
 	if err != nil {
 		return err
@@ -414,6 +417,7 @@ func deserializeNumericStatsValue(
 		*hasStats = false
 		return nil
 	}
+	*hasStats = true
 	switch typ.GetInternalType() {
 	case common.BOOL:
 		err = ReadRequired[bool](&val._value._bool, reader)
@@ -434,7 +438,7 @@ func deserializeNumericStatsValue(
 }
 
 func numericStatsDeserialize(stats *BaseStats, reader *FieldReader, ltyp common.LType) error {
-	ndata := stats._numericData
+	ndata := &stats._numericData
 	stats._typ = ltyp
 	err := deserializeNumericStatsValue(ltyp, reader, &ndata._min, &ndata._hasMin)
 	if err != nil {
@@ -553,6 +557,8 @@ func GetStatsType(typ common.LType) StatsType {
 	case common.FLOAT:
 		fallthrough
 	case common.DOUBLE:
+		fallthrough
+	case common.DATE:
 		return StatsTypeNumeric
 	case common.VARCHAR:
 		return StatsTypeString
@@ -838,6 +844,9 @@ func (stat *ColumnStats) Serialize(serial util.Serialize) error {
 }
 
 func (stat *ColumnStats) Deserialize(deserial util.Deserialize, lType common.LType) error {
+	if lType.IsNested() {
+		lType = common.VarcharType()
+	}
 	err := stat._stats.Deserialize(deserial, lType)
 	if err != nil {
 		return err
@@ -867,6 +876,13 @@ func (stat *ColumnStats) DistinctCount() uint64 {
 }
 
 func NewEmptyColumnStats(lType common.LType) *ColumnStats {
+	if lType.IsNested() {
+		//nested values are stored in VARCHAR.
+		//no distinct stats for them
+		return &ColumnStats{
+			_stats: NewEmptyBaseStats(common.VarcharType()),
+		}
+	}
 	ret := &ColumnStats{
 		_stats:         NewEmptyBaseStats(lType),
 		_distinctStats: NewDistinctStats(),
@@ -1051,6 +1067,13 @@ func (DateStatsOp) Update(stats *BaseStats, newValue *common.Date) {
 	}
 }
 
+// IntervalStatsOp keeps no min/max as the interval has base stats
+type IntervalStatsOp struct {
+}
+
+func (IntervalStatsOp) Update(stats *BaseStats, newValue *common.Interval) {
+}
+
 type StringStatsOp struct {
 }
 
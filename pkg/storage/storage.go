@@ -22,7 +22,7 @@ var GStorageMgr *StorageMgr
 
 func init() {
 	GTxnMgr = NewTxnMgr()
-	GBufferMgr = NewBufferManager(".")
+	GBufferMgr = NewBufferManager(defaultDbPath + ".tmp")
 	GCatalog = NewCatalog()
 	if err := GCatalog.Init(); err != nil {
 		panic(err)
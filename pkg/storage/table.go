@@ -424,6 +424,41 @@ func (table *DataTable) Scan(
 	txn._storage.Scan(state._localState, state.GetColumnIds(), result)
 }
 
+// InitParallelScan prepares the morsels of the committed rows and the local rows of the txn
+func (table *DataTable) InitParallelScan(txn *Txn, state *ParallelTableScanState) {
+	table._rowGroups.InitParallelScan(&state._scanState)
+	if local := txn._storage.getStorage(table); local != nil {
+		local._rowGroups.InitParallelScan(&state._localState)
+	}
+}
+
+// InitLocalScan prepares the scan state of the thread.
+// The rows are assigned by the NextParallelScan.
+func (table *DataTable) InitLocalScan(state *TableScanState, columnIds []IdxType) {
+	state.Init(columnIds)
+	types := make([]common.LType, 0, len(table._colDefs))
+	for _, colDef := range table._colDefs {
+		types = append(types, colDef.Type)
+	}
+	state._tableState.Init(types)
+	state._localState.Init(types)
+}
+
+// NextParallelScan assigns the next morsel to the scan state of the thread.
+// The morsel is a row group. It returns false if no morsel is left.
+func (table *DataTable) NextParallelScan(state *ParallelTableScanState, scanState *TableScanState) bool {
+	if state._scanState.next(scanState._tableState) {
+		scanState._localState._rowGroup = nil
+		return true
+	}
+	scanState._tableState._rowGroup = nil
+	if state._localState.next(scanState._localState) {
+		return true
+	}
+	scanState._localState._rowGroup = nil
+	return false
+}
+
 func (table *DataTable) Fetch(
 	txn *Txn,
 	result *chunk.Chunk,
@@ -926,7 +961,7 @@ func (state *CollectionScanState) Scan(
 		} else {
 			fun := func() {
 				for {
-					state._rowGroup =
+					state._rowGroup, _ =
 						state._rowGroups.GetNextSegment(nil, state._rowGroup).(*RowGroup)
 					if state._rowGroup != nil {
 						if state._rowGroup.Start() >= state._maxRow {
@@ -973,6 +1008,8 @@ type TableScanState struct {
 	_tableState *CollectionScanState
 	_localState *CollectionScanState
 	_columnIds  []IdxType
+	_sample     *ScanSample
+	_filters    *TableFilterSet
 }
 
 func NewTableScanState() *TableScanState {
@@ -991,6 +1028,117 @@ func (state *TableScanState) GetColumnIds() []IdxType {
 	return state._columnIds
 }
 
+// SetSample samples the rows in the scan
+func (state *TableScanState) SetSample(sample *ScanSample) {
+	state._sample = sample
+}
+
+func (state *CollectionScanState) GetSample() *ScanSample {
+	if state._parent == nil {
+		return nil
+	}
+	return state._parent._sample
+}
+
+// SetFilters pushes the filters into the scan. It is called before the scan
+// is initialized.
+func (state *TableScanState) SetFilters(filters *TableFilterSet) {
+	state._filters = filters
+}
+
+func (state *CollectionScanState) GetFilters() *TableFilterSet {
+	if state._parent == nil {
+		return nil
+	}
+	return state._parent._filters
+}
+
+// ScanSample decides the sampled rows by the hash of the seed and the row id.
+// The decision does not depend on the order of the scan.
+type ScanSample struct {
+	// System samples the whole vectors. Otherwise, the single rows (bernoulli).
+	System bool
+	// Percentage is in [0, 100]
+	Percentage float64
+	Seed       int64
+}
+
+// KeepVector returns false if the vector starting at the row is skipped
+func (sample *ScanSample) KeepVector(row IdxType) bool {
+	if !sample.System {
+		return true
+	}
+	return sample.hit(uint64(row/STANDARD_VECTOR_SIZE) ^ 0x5bd1e995)
+}
+
+// FilterRows keeps the sampled rows of the vector starting at the row.
+// The sel is updated in place. It is not initialized if the identity is true.
+// It returns the count of the sampled rows.
+func (sample *ScanSample) FilterRows(
+	row IdxType,
+	sel *chunk.SelectVector,
+	count IdxType,
+	identity bool) IdxType {
+	if sample.System {
+		return count
+	}
+	ret := IdxType(0)
+	for i := 0; i < int(count); i++ {
+		idx := i
+		if !identity {
+			idx = sel.GetIndex(i)
+		}
+		if sample.hit(uint64(row) + uint64(idx)) {
+			sel.SetIndex(int(ret), idx)
+			ret++
+		}
+	}
+	return ret
+}
+
+func (sample *ScanSample) hit(key uint64) bool {
+	//splitmix64
+	x := key + uint64(sample.Seed)*0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11)/(1<<53)*100 < sample.Percentage
+}
+
+// ParallelTableScanState hands out the morsels of the table to the scan threads
+type ParallelTableScanState struct {
+	_scanState  ParallelCollectionScanState
+	_localState ParallelCollectionScanState
+}
+
+type ParallelCollectionScanState struct {
+	_lock       sync.Mutex
+	_collection *RowGroupCollection
+	_current    *RowGroup
+	_maxRow     IdxType
+}
+
+// next assigns the next row group to the scan state
+func (state *ParallelCollectionScanState) next(scanState *CollectionScanState) bool {
+	for {
+		state._lock.Lock()
+		rg := state._current
+		if rg != nil {
+			state._current, _ = state._collection._rowGroups.GetNextSegment(nil, rg).(*RowGroup)
+		}
+		state._lock.Unlock()
+		if rg == nil || rg.Start() >= state._maxRow {
+			return false
+		}
+		//the scan stops at the end of the row group
+		scanState._rowGroups = state._collection._rowGroups
+		scanState._maxRow = min(rg.Start()+IdxType(rg.Count()), state._maxRow)
+		if rg.InitScan(scanState) {
+			return true
+		}
+	}
+}
+
 func ReadTable(table *DataTable, txn *Txn, limit int, callback func(result *chunk.Chunk)) int {
 	scanState := NewTableScanState()
 	colIdx := make([]IdxType, 1+len(table._colDefs))
@@ -0,0 +1,260 @@
+// Copyright 2023-2024 daviszhen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/daviszhen/plan/pkg/chunk"
+	"github.com/daviszhen/plan/pkg/common"
+)
+
+/*
+Zone map of the table scan.
+
+The comparisons between the column and the constant are pushed into
+the TableScanState. The min/max of the column in the row group and
+in the segment are checked before the rows are scanned:
+
+	row group: the row group is skipped if no value can match.
+	vector: the vector is skipped if no segment covering it can match.
+
+The filters only skip the data. They are still evaluated on the
+scanned rows.
+*/
+
+type CompareType uint8
+
+const (
+	CompareEqual CompareType = iota
+	CompareLess
+	CompareLessEqual
+	CompareGreater
+	CompareGreaterEqual
+)
+
+func (cmp CompareType) String() string {
+	switch cmp {
+	case CompareEqual:
+		return "="
+	case CompareLess:
+		return "<"
+	case CompareLessEqual:
+		return "<="
+	case CompareGreater:
+		return ">"
+	case CompareGreaterEqual:
+		return ">="
+	default:
+		panic(fmt.Sprintf("usp compare type %d", cmp))
+	}
+}
+
+// Flip swaps the sides of the comparison. c < col => col > c
+func (cmp CompareType) Flip() CompareType {
+	switch cmp {
+	case CompareLess:
+		return CompareGreater
+	case CompareLessEqual:
+		return CompareGreaterEqual
+	case CompareGreater:
+		return CompareLess
+	case CompareGreaterEqual:
+		return CompareLessEqual
+	default:
+		return cmp
+	}
+}
+
+// ConstantFilter is the comparison: column cmp constant
+type ConstantFilter struct {
+	Cmp      CompareType
+	Constant *chunk.Value
+}
+
+func (filter *ConstantFilter) String() string {
+	return fmt.Sprintf("%s %s", filter.Cmp, filter.Constant)
+}
+
+// CheckStats returns false if no value in the stats can match the filter
+func (filter *ConstantFilter) CheckStats(stats *BaseStats) bool {
+	if filter.Constant.IsNull {
+		//the comparison with NULL is never true
+		return false
+	}
+	if GetStatsType(stats._typ) != StatsTypeNumeric ||
+		!numericStatsHasMin(stats) ||
+		!numericStatsHasMax(stats) {
+		return true
+	}
+	if filter.Constant.Typ.GetInternalType() != stats._typ.GetInternalType() {
+		return true
+	}
+	//constant vs min, constant vs max
+	cmpMin, ok := compareNumericValue(filter.Constant, &stats._numericData._min, stats._typ)
+	if !ok {
+		return true
+	}
+	cmpMax, _ := compareNumericValue(filter.Constant, &stats._numericData._max, stats._typ)
+	switch filter.Cmp {
+	case CompareEqual:
+		return cmpMin >= 0 && cmpMax <= 0
+	case CompareLess:
+		return cmpMin > 0
+	case CompareLessEqual:
+		return cmpMin >= 0
+	case CompareGreater:
+		return cmpMax < 0
+	case CompareGreaterEqual:
+		return cmpMax <= 0
+	default:
+		return true
+	}
+}
+
+// compareNumericValue compares the constant with the value in the stats.
+// It returns false if the type is not supported.
+func compareNumericValue(val *chunk.Value, num *NumericValueUnion, typ common.LType) (int, bool) {
+	switch typ.GetInternalType() {
+	case common.INT32:
+		return compareOrdered(val.I64, int64(num._value._int32)), true
+	case common.INT64:
+		return compareOrdered(val.I64, num._value._int64), true
+	case common.UINT64:
+		return compareOrdered(val.U64, num._value._uint64), true
+	case common.DATE:
+		d := common.Date{
+			Year:  int32(val.I64),
+			Month: int32(val.I64_1),
+			Day:   int32(val.I64_2),
+		}
+		return d.ToDate().Compare(num._value._date.ToDate()), true
+	default:
+		return 0, false
+	}
+}
+
+func compareOrdered[T int64 | uint64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// TableFilterSet maps the index of the scanned column to its filters
+type TableFilterSet struct {
+	Filters map[IdxType][]*ConstantFilter
+}
+
+func NewTableFilterSet() *TableFilterSet {
+	return &TableFilterSet{
+		Filters: make(map[IdxType][]*ConstantFilter),
+	}
+}
+
+func (set *TableFilterSet) PushFilter(colIdx IdxType, filter *ConstantFilter) {
+	set.Filters[colIdx] = append(set.Filters[colIdx], filter)
+}
+
+func (set *TableFilterSet) Empty() bool {
+	return set == nil || len(set.Filters) == 0
+}
+
+// CheckStats returns false if no value in the stats can match all the filters
+func CheckStats(filters []*ConstantFilter, stats *BaseStats) bool {
+	for _, filter := range filters {
+		if !filter.CheckStats(stats) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckZonemap returns false if no row of the column in [start, start+count)
+// can match the filters.
+func (column *ColumnData) CheckZonemap(
+	filters []*ConstantFilter,
+	start IdxType,
+	count IdxType) bool {
+	if column.isNested() || !column.noUpdates() {
+		return true
+	}
+	seg := column.GetSegment(start)
+	if seg == nil {
+		return true
+	}
+	for seg != nil && seg.Start() < start+count {
+		if CheckStats(filters, &seg._stats._stats) {
+			return true
+		}
+		seg, _ = column._data.GetNextSegment(nil, seg).(*ColumnSegment)
+	}
+	return false
+}
+
+// noUpdates returns true if the stats of the segments are not
+// outdated by the updates
+func (column *ColumnData) noUpdates() bool {
+	column._updateLock.Lock()
+	defer column._updateLock.Unlock()
+	return column._updates == nil
+}
+
+// CheckZonemap returns false if no row in the row group can match the filters
+func (rg *RowGroup) CheckZonemap(state *CollectionScanState) bool {
+	filters := state.GetFilters()
+	if filters.Empty() {
+		return true
+	}
+	colIds := state.GetColumnIds()
+	rg._statsLock.Lock()
+	defer rg._statsLock.Unlock()
+	for i, colFilters := range filters.Filters {
+		if colIds[i] == COLUMN_IDENTIFIER_ROW_ID {
+			continue
+		}
+		col := rg.GetColumn(int(colIds[i]))
+		if col.isNested() || !col.noUpdates() {
+			continue
+		}
+		if !CheckStats(colFilters, &col._stats._stats) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckZonemapVector returns false if no row in the vector can match the filters
+func (rg *RowGroup) CheckZonemapVector(state *CollectionScanState, row IdxType, count IdxType) bool {
+	filters := state.GetFilters()
+	if filters.Empty() {
+		return true
+	}
+	colIds := state.GetColumnIds()
+	rg._statsLock.Lock()
+	defer rg._statsLock.Unlock()
+	for i, colFilters := range filters.Filters {
+		if colIds[i] == COLUMN_IDENTIFIER_ROW_ID {
+			continue
+		}
+		col := rg.GetColumn(int(colIds[i]))
+		if !col.CheckZonemap(colFilters, rg.Start()+row, count) {
+			return false
+		}
+	}
+	return true
+}
@@ -888,3 +888,94 @@ func NewInt32ConstVector(v int32, null bool) *chunk.Vector {
 	chunk.SetNullInPhyFormatConst(vec, null)
 	return vec
 }
+
+func Test_scanSample(t *testing.T) {
+	bernoulli := &ScanSample{Percentage: 10, Seed: 42}
+	count := func(sample *ScanSample) int {
+		total := 0
+		for row := IdxType(0); row < 100*STANDARD_VECTOR_SIZE; row += STANDARD_VECTOR_SIZE {
+			if !sample.KeepVector(row) {
+				continue
+			}
+			sel := chunk.NewSelectVector(STANDARD_VECTOR_SIZE)
+			total += int(sample.FilterRows(row, sel, STANDARD_VECTOR_SIZE, true))
+		}
+		return total
+	}
+	cnt := count(bernoulli)
+	assert.InDelta(t, 10*STANDARD_VECTOR_SIZE, cnt, float64(STANDARD_VECTOR_SIZE))
+	assert.Equal(t, cnt, count(&ScanSample{Percentage: 10, Seed: 42}))
+	assert.NotEqual(t, cnt, count(&ScanSample{Percentage: 10, Seed: 43}))
+
+	//the system keeps the whole vectors
+	system := &ScanSample{System: true, Percentage: 50, Seed: 1}
+	assert.Equal(t, 0, count(system)%STANDARD_VECTOR_SIZE)
+	assert.Equal(t, 0, count(&ScanSample{System: true, Percentage: 0}))
+	assert.Equal(t, 100*STANDARD_VECTOR_SIZE, count(&ScanSample{System: true, Percentage: 100}))
+
+	//the rows in the selection
+	sel := chunk.NewSelectVector(STANDARD_VECTOR_SIZE)
+	for i := 0; i < 4; i++ {
+		sel.SetIndex(i, i*2)
+	}
+	all := &ScanSample{Percentage: 100}
+	require.Equal(t, IdxType(4), all.FilterRows(0, sel, 4, false))
+	assert.Equal(t, 6, sel.GetIndex(3))
+}
+
+func Test_zonemap(t *testing.T) {
+	intStats := func(vals ...int32) *BaseStats {
+		stats := NewEmptyBaseStats(common.IntegerType())
+		for i := range vals {
+			Int32StatsOp{}.Update(&stats, &vals[i])
+		}
+		return &stats
+	}
+	filter := func(cmp CompareType, v int64) *ConstantFilter {
+		return &ConstantFilter{
+			Cmp:      cmp,
+			Constant: &chunk.Value{Typ: common.IntegerType(), I64: v},
+		}
+	}
+	stats := intStats(10, 20, 15)
+	assert.True(t, filter(CompareEqual, 10).CheckStats(stats))
+	assert.False(t, filter(CompareEqual, 21).CheckStats(stats))
+	assert.False(t, filter(CompareLess, 10).CheckStats(stats))
+	assert.True(t, filter(CompareLessEqual, 10).CheckStats(stats))
+	assert.False(t, filter(CompareGreater, 20).CheckStats(stats))
+	assert.True(t, filter(CompareGreaterEqual, 20).CheckStats(stats))
+	assert.False(t, CheckStats([]*ConstantFilter{
+		filter(CompareGreater, 12),
+		filter(CompareLess, 5),
+	}, stats))
+	//no value
+	assert.False(t, filter(CompareGreater, 0).CheckStats(intStats()))
+	//the comparison with NULL
+	assert.False(t, (&ConstantFilter{
+		Cmp:      CompareEqual,
+		Constant: &chunk.Value{Typ: common.IntegerType(), IsNull: true},
+	}).CheckStats(stats))
+
+	//the merged stats
+	merged := intStats()
+	merged.Merge(intStats(30, 40))
+	merged.Merge(stats)
+	assert.False(t, filter(CompareLess, 10).CheckStats(merged))
+	assert.True(t, filter(CompareEqual, 35).CheckStats(merged))
+	assert.False(t, filter(CompareGreater, 40).CheckStats(merged))
+
+	//date
+	dateStats := NewEmptyBaseStats(common.DateType())
+	for _, d := range []common.Date{{Year: 1994, Month: 3, Day: 1}, {Year: 1994, Month: 6, Day: 30}} {
+		DateStatsOp{}.Update(&dateStats, &d)
+	}
+	date := func(cmp CompareType, y, m, d int64) *ConstantFilter {
+		return &ConstantFilter{
+			Cmp:      cmp,
+			Constant: &chunk.Value{Typ: common.DateType(), I64: y, I64_1: m, I64_2: d},
+		}
+	}
+	assert.False(t, date(CompareLess, 1994, 3, 1).CheckStats(&dateStats))
+	assert.True(t, date(CompareLess, 1994, 3, 2).CheckStats(&dateStats))
+	assert.False(t, date(CompareGreaterEqual, 1995, 1, 1).CheckStats(&dateStats))
+}
@@ -1020,6 +1020,8 @@ func (commit *CommitState) WriteCatalogEntry(
 	switch parent._typ {
 	case CatalogTypeTable:
 		return commit._log.WriteCreateTable(parent)
+	case CatalogTypeMacro:
+		return commit._log.WriteCreateMacro(parent)
 	case CatalogTypeSchema:
 		if ent._typ == CatalogTypeSchema {
 			//skip alter schema
@@ -29,6 +29,7 @@ func init() {
 const (
 	WAL_CREATE_TABLE  uint8 = 1
 	WAL_CREATE_SCHEMA uint8 = 3
+	WAL_CREATE_MACRO  uint8 = 10
 	WAL_USE_TABLE     uint8 = 25
 	WAL_INSERT_TUPLE  uint8 = 26
 	WAL_DELETE_TUPLE  uint8 = 27
@@ -41,6 +42,8 @@ func walType(walTyp uint8) string {
 	switch walTyp {
 	case WAL_CREATE_SCHEMA:
 		return "WAL_CREATE_SCHEMA"
+	case WAL_CREATE_MACRO:
+		return "WAL_CREATE_MACRO"
 	case WAL_USE_TABLE:
 		return "WAL_USE_TABLE"
 	case WAL_INSERT_TUPLE:
@@ -214,6 +217,17 @@ func (log *WriteAheadLog) WriteCreateTable(ent *CatalogEntry) error {
 	return ent.Serialize(log._writer)
 }
 
+func (log *WriteAheadLog) WriteCreateMacro(ent *CatalogEntry) error {
+	if log._skipWriting {
+		return nil
+	}
+	err := util.Write[uint8](WAL_CREATE_MACRO, log._writer)
+	if err != nil {
+		return err
+	}
+	return ent.Serialize(log._writer)
+}
+
 var _ util.Serialize = new(BufferedFileWriter)
 
 type BufferedFileWriter struct {
@@ -51,7 +51,28 @@ type DebugOptions struct {
 	Count             int  `tag:"count"`
 }
 
+type ExecOptions struct {
+	//the degree of the parallelism. 0 or 1 runs the query serially
+	Threads int `tag:"threads"`
+	//the memory budget of the hash aggregate in bytes. the groups over it
+	//are spilled into the temporary directory. 0 denotes the default
+	AggrMemoryLimit int64 `tag:"aggrMemoryLimit"`
+	//the memory budget of the order by in bytes. the sorted runs over it
+	//are spilled into the temporary directory and merged. 0 denotes the default
+	SortMemoryLimit int64 `tag:"sortMemoryLimit"`
+	//the memory budget of the hash join in bytes. the build side over it
+	//and the probe side are partitioned into the temporary directory. 0 denotes the default
+	JoinMemoryLimit int64 `tag:"joinMemoryLimit"`
+	//the memory limit of the buffer manager in bytes. the unpinned blocks
+	//are evicted over it and the allocation fails at last. 0 denotes no limit
+	MemoryLimit int64 `tag:"memoryLimit"`
+	//the statement is canceled if it runs longer than the timeout in milliseconds.
+	//0 denotes no timeout
+	StatementTimeout int64 `tag:"statementTimeout"`
+}
+
 type Config struct {
 	Tpch1g Tpch1g       `tag:"tpch1g"`
 	Debug  DebugOptions `tag:"debug"`
+	Exec   ExecOptions  `tag:"exec"`
 }